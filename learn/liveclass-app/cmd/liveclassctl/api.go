@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+)
+
+// apiClient calls the LiveClass API as an admin.
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// defaultAPIURL returns LIVECLASS_API_URL, or the local server on the configured port.
+func defaultAPIURL() string {
+	if url := os.Getenv("LIVECLASS_API_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:" + strconv.Itoa(config.Default().Port)
+}
+
+// newAPIClient signs an admin token locally (same JWT_SECRET as the server),
+// so no password is needed. as selects the admin; empty means the oldest one.
+func newAPIClient(baseURL, as string) (*apiClient, error) {
+	var token string
+	err := withStore(func(ctx context.Context, s *store) error {
+		admin, err := s.resolveAdmin(ctx, as)
+		if err != nil {
+			return err
+		}
+		token, err = s.authService.IssueToken(admin)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// do sends a request and decodes a JSON response into out (if non-nil).
+// Non-2xx responses are returned as errors carrying the API's error message.
+func (c *apiClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s (%d)", method, path, apiErr.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// apiFlags creates a flag set with the flags shared by API commands.
+func apiFlags(name string) (fs *flag.FlagSet, api, as *string) {
+	fs = newFlags(name)
+	api = fs.String("api", defaultAPIURL(), "server base URL (LIVECLASS_API_URL)")
+	as = fs.String("as", "", "email or ID of the admin to act as (default: first approved admin)")
+	return fs, api, as
+}
+
+// runRoomsList prints the active rooms on the server.
+func runRoomsList(args []string) error {
+	fs, api, as := apiFlags("rooms list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := newAPIClient(*api, *as)
+	if err != nil {
+		return err
+	}
+
+	var rooms []struct {
+		RoomID        string `json:"roomId"`
+		Participants  int    `json:"participants"`
+		HasPresenter  bool   `json:"hasPresenter"`
+		StreamReady   bool   `json:"streamReady"`
+		ScheduleID    string `json:"scheduleId"`
+		Title         string `json:"title"`
+		PresenterName string `json:"presenterName"`
+	}
+	if err := client.do(http.MethodGet, "/api/admin/rooms", nil, &rooms); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROOM\tPARTICIPANTS\tPRESENTER\tSTREAMING\tSCHEDULE\tTITLE")
+	for _, r := range rooms {
+		presenter := "-"
+		if r.HasPresenter {
+			presenter = r.PresenterName
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%v\t%s\t%s\n", r.RoomID, r.Participants, presenter, r.StreamReady, r.ScheduleID, r.Title)
+	}
+	return tw.Flush()
+}
+
+// runRoomsEnd force-ends live rooms.
+func runRoomsEnd(args []string) error {
+	fs, api, as := apiFlags("rooms end")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	roomIDs := fs.Args()
+	if len(roomIDs) == 0 {
+		return fmt.Errorf("at least one room ID is required")
+	}
+
+	client, err := newAPIClient(*api, *as)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, id := range roomIDs {
+		var result struct {
+			Completed    bool `json:"completed"`
+			Disconnected int  `json:"disconnected"`
+		}
+		if err := client.do(http.MethodPost, "/api/admin/rooms/"+strings.ToUpper(id)+"/end", nil, &result); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Ended %s (class completed: %v, disconnected: %d)\n", strings.ToUpper(id), result.Completed, result.Disconnected)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rooms could not be ended", failed, len(roomIDs))
+	}
+	return nil
+}
+
+// runCacheClear clears repository and response caches on every instance.
+func runCacheClear(args []string) error {
+	fs, api, as := apiFlags("cache clear")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := clearServerCaches(*api, *as); err != nil {
+		return err
+	}
+	fmt.Println("Caches cleared")
+	return nil
+}
+
+// clearServerCaches asks the server to clear its caches on every instance.
+func clearServerCaches(baseURL, as string) error {
+	client, err := newAPIClient(baseURL, as)
+	if err != nil {
+		return err
+	}
+	return client.do(http.MethodPost, "/api/admin/cache/clear", nil, nil)
+}
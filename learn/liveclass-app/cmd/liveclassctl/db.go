@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/migrate"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// commandTimeout bounds every database command.
+const commandTimeout = 2 * time.Minute
+
+// store bundles the database connection and repositories used by the CLI.
+type store struct {
+	db           *database.MongoDB
+	userRepo     *repository.UserRepository
+	batchRepo    *repository.BatchRepository
+	scheduleRepo *repository.ScheduleRepository
+	authService  *auth.Service
+}
+
+// openStore connects to MongoDB using the server's environment configuration.
+// Repository caches are irrelevant for one-shot commands, so they are kept short.
+func openStore() (*store, error) {
+	cfg := config.Default()
+	// Migrations sanitize stored content with the server's allowlists
+	richtext.Configure(cfg.SanitizeRichTags, cfg.SanitizeChatTags, cfg.SanitizeURLSchemes)
+
+	db, err := database.NewMongoDB(cfg.MongoURI, cfg.MongoDBName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	userRepo := repository.NewUserRepositoryWithCache(db, time.Second)
+	return &store{
+		db:           db,
+		userRepo:     userRepo,
+		batchRepo:    repository.NewBatchRepositoryWithCache(db, time.Second),
+		scheduleRepo: repository.NewScheduleRepositoryWithCache(db, time.Second),
+		authService:  auth.NewService(userRepo, cfg.JWTSecret, cfg.JWTExpiryHours),
+	}, nil
+}
+
+// withStore runs fn with an open store and a bounded context.
+func withStore(fn func(ctx context.Context, s *store) error) error {
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer s.db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	return fn(ctx, s)
+}
+
+// findUser resolves a user by ObjectID hex or email.
+func (s *store) findUser(ctx context.Context, ref string) (*models.User, error) {
+	if primitive.IsValidObjectID(ref) {
+		return s.userRepo.FindByID(ctx, ref)
+	}
+	return s.userRepo.FindByEmail(ctx, ref)
+}
+
+// runAdminCreate creates an approved admin account.
+func runAdminCreate(args []string) error {
+	fs := newFlags("admin create")
+	email := fs.String("email", "", "admin email (required)")
+	password := fs.String("password", "", "admin password (required, or LIVECLASS_ADMIN_PASSWORD)")
+	name := fs.String("name", "Administrator", "display name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *password == "" {
+		*password = os.Getenv("LIVECLASS_ADMIN_PASSWORD")
+	}
+	if *email == "" || *password == "" {
+		return errors.New("--email and --password are required")
+	}
+	if len(*password) < 6 {
+		return errors.New("password must be at least 6 characters")
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		admin, err := s.authService.CreateAdmin(ctx, strings.ToLower(strings.TrimSpace(*email)), *password, *name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created admin %s (%s)\n", admin.Email, admin.ID.Hex())
+		return nil
+	})
+}
+
+// runUsersList prints users, optionally filtered by status and role.
+func runUsersList(args []string) error {
+	fs := newFlags("users list")
+	status := fs.String("status", "", "filter by status (pending, approved, rejected, suspended)")
+	role := fs.String("role", "", "filter by role (admin, presenter, student)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		var statusFilter *models.UserStatus
+		var roleFilter *models.UserRole
+		if *status != "" {
+			st := models.UserStatus(*status)
+			statusFilter = &st
+		}
+		if *role != "" {
+			r := models.UserRole(*role)
+			roleFilter = &r
+		}
+
+		users, err := s.userRepo.FindAll(ctx, statusFilter, roleFilter)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tROLE\tSTATUS\tCREATED")
+		for _, u := range users {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				u.ID.Hex(), u.Email, u.Name, u.Role, u.Status, u.CreatedAt.Format("2006-01-02"))
+		}
+		return tw.Flush()
+	})
+}
+
+// runUsersApprove approves users by email or ID.
+func runUsersApprove(args []string) error {
+	fs := newFlags("users approve")
+	by := fs.String("by", "", "email or ID of the approving admin (default: first approved admin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("at least one user email or ID is required")
+	}
+
+	err := withStore(func(ctx context.Context, s *store) error {
+		approver, err := s.resolveAdmin(ctx, *by)
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, ref := range fs.Args() {
+			user, err := s.findUser(ctx, ref)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", ref, err)
+				failed++
+				continue
+			}
+			if user.Status == models.StatusApproved {
+				fmt.Printf("%s is already approved\n", user.Email)
+				continue
+			}
+			if err := s.userRepo.UpdateStatus(ctx, user.ID.Hex(), models.StatusApproved, approver.ID.Hex()); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", ref, err)
+				failed++
+				continue
+			}
+			fmt.Printf("Approved %s (%s)\n", user.Email, user.Role)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d users could not be approved", failed, fs.NArg())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Running servers cache users; clear them so the approval takes effect now
+	if err := clearServerCaches(defaultAPIURL(), ""); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: server caches not cleared (%v); approval applies once cached users expire\n", err)
+	}
+	return nil
+}
+
+// resolveAdmin returns the admin named by ref, or the oldest approved admin when ref is empty.
+func (s *store) resolveAdmin(ctx context.Context, ref string) (*models.User, error) {
+	if ref != "" {
+		user, err := s.findUser(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !user.IsAdmin() || !user.IsApproved() {
+			return nil, fmt.Errorf("%s is not an approved admin", user.Email)
+		}
+		return user, nil
+	}
+
+	role, status := models.RoleAdmin, models.StatusApproved
+	admins, err := s.userRepo.FindAll(ctx, &status, &role)
+	if err != nil {
+		return nil, err
+	}
+	if len(admins) == 0 {
+		return nil, errors.New("no approved admin found; create one with 'liveclassctl admin create'")
+	}
+	// FindAll sorts newest first
+	return &admins[len(admins)-1], nil
+}
+
+// runReindex creates the indexes of every collection.
+func runReindex(args []string) error {
+	if err := newFlags("db reindex").Parse(args); err != nil {
+		return err
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		indexers := []struct {
+			name   string
+			create func(context.Context) error
+		}{
+			{"users", s.userRepo.CreateIndexes},
+			{"batches", s.batchRepo.CreateIndexes},
+			{"schedules", s.scheduleRepo.CreateIndexes},
+			{"recordings", repository.NewRecordingRepository(s.db).CreateIndexes},
+			{"notes", repository.NewNoteRepository(s.db).CreateIndexes},
+			{"direct messages", repository.NewDirectMessageRepository(s.db).CreateIndexes},
+			{"verifications", repository.NewVerificationRepository(s.db).CreateIndexes},
+			{"exam audit", repository.NewExamAuditRepository(s.db).CreateIndexes},
+			{"notifications", repository.NewNotificationRepository(s.db).CreateIndexes},
+			{"storage usage", repository.NewStorageUsageRepository(s.db).CreateIndexes},
+			{"content reports", repository.NewReportRepository(s.db).CreateIndexes},
+			{"legal holds", repository.NewLegalHoldRepository(s.db).CreateIndexes},
+			{"class templates", repository.NewClassTemplateRepository(s.db).CreateIndexes},
+			{"student goals", repository.NewGoalRepository(s.db).CreateIndexes},
+			{"recording consents", repository.NewRecordingConsentRepository(s.db).CreateIndexes},
+			{"room events", repository.NewRoomEventRepository(s.db).CreateIndexes},
+			{"watch progress", repository.NewWatchProgressRepository(s.db).CreateIndexes},
+			{"sessions", repository.NewSessionRepository(s.db).CreateIndexes},
+			{"webhook events", repository.NewWebhookEventRepository(s.db).CreateIndexes},
+			{"subscriptions", repository.NewBillingRepository(s.db).CreateIndexes},
+			{"class rollups", repository.NewClassRollupRepository(s.db).CreateIndexes},
+			{"hand-ins", repository.NewHandInRepository(s.db).CreateIndexes},
+			{"catch-up summaries", repository.NewCatchUpRepository(s.db).CreateIndexes},
+		}
+
+		failed := 0
+		for _, ix := range indexers {
+			if err := ix.create(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", ix.name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("Indexed %s\n", ix.name)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d collections failed to index", failed)
+		}
+		return nil
+	})
+}
+
+// runMigrate applies pending data migrations, or lists them with --dry-run.
+func runMigrate(args []string) error {
+	fs := newFlags("db migrate")
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		if *dryRun {
+			pending, err := migrate.Pending(ctx, s.db)
+			if err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				fmt.Println("No pending migrations")
+			}
+			for _, m := range pending {
+				fmt.Printf("pending  %s  %s\n", m.ID, m.Description)
+			}
+			return nil
+		}
+
+		applied, err := migrate.Run(ctx, s.db)
+		for _, m := range applied {
+			fmt.Printf("applied  %s  %s\n", m.ID, m.Description)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+	})
+}
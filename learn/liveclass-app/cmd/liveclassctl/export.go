@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/export"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// exportFlags holds the flags shared by export commands.
+type exportFlags struct {
+	format string
+	out    string
+}
+
+// table is an export in column order, written as CSV or as JSON objects keyed by column.
+type table struct {
+	columns []export.Column
+	rows    []map[string]string
+}
+
+// parseExportFlags parses the shared export flags plus any registered by extra.
+func parseExportFlags(name string, args []string, extra func(fs *flag.FlagSet)) (*exportFlags, error) {
+	fs := newFlags(name)
+	f := &exportFlags{}
+	fs.StringVar(&f.format, "format", "csv", "output format: csv or json")
+	fs.StringVar(&f.out, "out", "", "output file (default: stdout)")
+	if extra != nil {
+		extra(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if f.format != "csv" && f.format != "json" {
+		return nil, fmt.Errorf("unsupported format %q (use csv or json)", f.format)
+	}
+	return f, nil
+}
+
+// write outputs the table in the requested format.
+func (f *exportFlags) write(t table) error {
+	var out io.Writer = os.Stdout
+	if f.out != "" {
+		file, err := os.Create(f.out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if f.format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t.rows)
+	}
+
+	w := export.NewCSVWriter(out)
+	if err := w.WriteHeader(t.columns); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		if err := w.WriteRow(export.Project(t.columns, row)); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if f.out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d rows to %s\n", len(t.rows), f.out)
+	}
+	return nil
+}
+
+// formatTime formats t as RFC 3339 in UTC, or "" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// runExportUsers exports all users.
+func runExportUsers(args []string) error {
+	f, err := parseExportFlags("export users", args, nil)
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		users, err := s.userRepo.FindAll(ctx, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		t := table{columns: []export.Column{
+			{Key: "id", Header: "ID"},
+			{Key: "email", Header: "Email"},
+			{Key: "name", Header: "Name"},
+			{Key: "role", Header: "Role"},
+			{Key: "status", Header: "Status"},
+			{Key: "createdAt", Header: "Created At"},
+		}}
+		for _, u := range users {
+			t.rows = append(t.rows, map[string]string{
+				"id":        u.ID.Hex(),
+				"email":     u.Email,
+				"name":      u.Name,
+				"role":      string(u.Role),
+				"status":    string(u.Status),
+				"createdAt": formatTime(u.CreatedAt),
+			})
+		}
+		return f.write(t)
+	})
+}
+
+// runExportBatches exports all batches.
+func runExportBatches(args []string) error {
+	f, err := parseExportFlags("export batches", args, nil)
+	if err != nil {
+		return err
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		batches, err := s.batchRepo.FindAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		t := table{columns: []export.Column{
+			{Key: "id", Header: "ID"},
+			{Key: "name", Header: "Name"},
+			{Key: "description", Header: "Description"},
+			{Key: "presenterId", Header: "Presenter ID"},
+			{Key: "students", Header: "Students"},
+			{Key: "createdAt", Header: "Created At"},
+		}}
+		for _, b := range batches {
+			t.rows = append(t.rows, map[string]string{
+				"id":          b.ID.Hex(),
+				"name":        b.Name,
+				"description": b.Description,
+				"presenterId": b.PresenterID.Hex(),
+				"students":    strconv.Itoa(len(b.StudentIDs)),
+				"createdAt":   formatTime(b.CreatedAt),
+			})
+		}
+		return f.write(t)
+	})
+}
+
+// runExportSchedules exports scheduled classes, optionally filtered by status.
+func runExportSchedules(args []string) error {
+	var status string
+	f, err := parseExportFlags("export schedules", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&status, "status", "", "only classes with this status (scheduled, live, completed, cancelled)")
+	})
+	if err != nil {
+		return err
+	}
+
+	statuses := []models.ClassStatus{
+		models.ClassStatusScheduled,
+		models.ClassStatusLive,
+		models.ClassStatusCompleted,
+		models.ClassStatusCancelled,
+	}
+	if status != "" {
+		statuses = []models.ClassStatus{models.ClassStatus(strings.ToLower(status))}
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		t := table{columns: []export.Column{
+			{Key: "id", Header: "ID"},
+			{Key: "title", Header: "Title"},
+			{Key: "batchId", Header: "Batch ID"},
+			{Key: "presenterId", Header: "Presenter ID"},
+			{Key: "startTime", Header: "Start Time"},
+			{Key: "endTime", Header: "End Time"},
+			{Key: "status", Header: "Status"},
+			{Key: "roomId", Header: "Room ID"},
+		}}
+
+		for _, st := range statuses {
+			schedules, err := s.scheduleRepo.FindByStatus(ctx, st)
+			if err != nil {
+				return err
+			}
+			for _, c := range schedules {
+				t.rows = append(t.rows, map[string]string{
+					"id":          c.ID.Hex(),
+					"title":       c.Title,
+					"batchId":     c.BatchID.Hex(),
+					"presenterId": c.PresenterID.Hex(),
+					"startTime":   formatTime(c.StartTime),
+					"endTime":     formatTime(c.EndTime),
+					"status":      string(c.Status),
+					"roomId":      c.RoomID,
+				})
+			}
+		}
+		return f.write(t)
+	})
+}
@@ -0,0 +1,117 @@
+// Package main is liveclassctl, the operations CLI for LiveClass.
+//
+// Data commands (admin, users, db, export) talk to MongoDB directly and use the
+// same environment variables as the server. Live commands (rooms, cache) call
+// the server API as an admin, with a token signed by JWT_SECRET.
+//
+// Usage:
+//
+//	liveclassctl <command> <subcommand> [flags] [args]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command is a node in the CLI command tree. Leaf commands have run set.
+type command struct {
+	name    string
+	usage   string // Arguments and flags shown in help
+	summary string
+	run     func(args []string) error
+	subs    []*command
+}
+
+var root = &command{
+	name: "liveclassctl",
+	subs: []*command{
+		{name: "admin", summary: "Manage admin accounts", subs: []*command{
+			{name: "create", usage: "--email EMAIL --password PASSWORD [--name NAME]", summary: "Create an approved admin account", run: runAdminCreate},
+		}},
+		{name: "users", summary: "List and approve users", subs: []*command{
+			{name: "list", usage: "[--status STATUS] [--role ROLE]", summary: "List users", run: runUsersList},
+			{name: "approve", usage: "EMAIL|ID...", summary: "Approve pending users", run: runUsersApprove},
+		}},
+		{name: "rooms", summary: "Inspect and end live rooms (via API)", subs: []*command{
+			{name: "list", usage: "[--api URL] [--as EMAIL]", summary: "List active rooms on the server", run: runRoomsList},
+			{name: "end", usage: "[--api URL] [--as EMAIL] ROOM_ID...", summary: "Force-end rooms and disconnect everyone", run: runRoomsEnd},
+		}},
+		{name: "cache", summary: "Manage server caches (via API)", subs: []*command{
+			{name: "clear", usage: "[--api URL] [--as EMAIL]", summary: "Clear caches on every instance", run: runCacheClear},
+		}},
+		{name: "db", summary: "Database maintenance", subs: []*command{
+			{name: "reindex", summary: "Create or update all collection indexes", run: runReindex},
+			{name: "migrate", usage: "[--dry-run]", summary: "Apply pending data migrations", run: runMigrate},
+		}},
+		{name: "storage", summary: "Manage encryption of stored files", subs: []*command{
+			{name: "rotate-keys", usage: "[--dry-run]", summary: "Re-wrap file keys with the current master key", run: runStorageRotateKeys},
+			{name: "encrypt", usage: "[--dry-run]", summary: "Encrypt files stored before encryption was enabled", run: runStorageEncrypt},
+		}},
+		{name: "seed", usage: "[--presenters N] [--students N] [--batches N] [--password PASSWORD]", summary: "Generate demo data (requires DEV_MODE=true)", run: runSeed},
+		{name: "export", summary: "Export data as CSV or JSON", subs: []*command{
+			{name: "users", usage: "[--format csv|json] [--out FILE]", summary: "Export users", run: runExportUsers},
+			{name: "batches", usage: "[--format csv|json] [--out FILE]", summary: "Export batches", run: runExportBatches},
+			{name: "schedules", usage: "[--format csv|json] [--out FILE] [--status STATUS]", summary: "Export scheduled classes", run: runExportSchedules},
+		}},
+	},
+}
+
+func main() {
+	if err := dispatch(root, os.Args[1:], root.name); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// dispatch walks the command tree using the leading arguments and runs the matched leaf.
+func dispatch(cmd *command, args []string, path string) error {
+	if cmd.run != nil {
+		return cmd.run(args)
+	}
+
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(cmd, path)
+		if len(args) == 0 {
+			os.Exit(2)
+		}
+		return nil
+	}
+
+	for _, sub := range cmd.subs {
+		if sub.name == args[0] {
+			return dispatch(sub, args[1:], path+" "+sub.name)
+		}
+	}
+
+	printUsage(cmd, path)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// printUsage lists the subcommands of cmd.
+func printUsage(cmd *command, path string) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command>\n\nCommands:\n", path)
+	for _, sub := range cmd.subs {
+		if sub.run != nil {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", sub.name, sub.summary)
+			if sub.usage != "" {
+				fmt.Fprintf(os.Stderr, "  %-10s   %s %s %s\n", "", path, sub.name, sub.usage)
+			}
+			continue
+		}
+		names := make([]string, len(sub.subs))
+		for i, s := range sub.subs {
+			names[i] = s.name
+		}
+		fmt.Fprintf(os.Stderr, "  %-10s %s (%s)\n", sub.name, sub.summary, strings.Join(names, ", "))
+	}
+}
+
+// newFlags creates a flag set for a leaf command that returns parse errors instead of exiting.
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/seed"
+)
+
+// runSeed generates demo data. It only runs with DEV_MODE=true.
+func runSeed(args []string) error {
+	opts := seed.DefaultOptions()
+	opts.StoragePath = config.Default().StoragePath
+
+	fs := newFlags("seed")
+	fs.IntVar(&opts.Presenters, "presenters", opts.Presenters, "number of presenters")
+	fs.IntVar(&opts.Students, "students", opts.Students, "number of students")
+	fs.IntVar(&opts.Batches, "batches", opts.Batches, "number of batches")
+	fs.StringVar(&opts.Password, "password", opts.Password, "password of every demo account")
+	fs.Int64Var(&opts.RandSeed, "rand-seed", opts.RandSeed, "random seed (same seed, same data)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !config.Default().DevMode {
+		return errors.New("seeding is disabled; set DEV_MODE=true (never in production)")
+	}
+	if opts.Presenters < 1 || opts.Students < 0 || opts.Batches < 0 {
+		return errors.New("--presenters must be at least 1; --students and --batches cannot be negative")
+	}
+
+	return withStore(func(ctx context.Context, s *store) error {
+		seeder := seed.NewSeeder(s.userRepo, s.batchRepo, s.scheduleRepo,
+			repository.NewRecordingRepository(s.db), repository.NewNoteRepository(s.db))
+
+		res, err := seeder.Seed(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Seeded %d users, %d batches, %d classes, %d recordings, %d notes\n",
+			res.Users, res.Batches, res.Schedules, res.Recordings, res.Notes)
+		fmt.Printf("Sign in as admin1@%s, presenter1@%s or student1@%s with password %q\n",
+			seed.EmailDomain, seed.EmailDomain, seed.EmailDomain, opts.Password)
+		return nil
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+)
+
+// encryptedDirs are the storage directories holding encrypted files.
+var encryptedDirs = []string{"recordings", "notes"}
+
+// fileRewrite is a change applied to stored files.
+type fileRewrite struct {
+	verb string
+	// pending reports whether a file with the given key needs the change
+	pending func(keyID string, encrypted bool, current string) bool
+	apply   func(e *encryption.Encryptor, ctx context.Context, path string) (bool, error)
+}
+
+// runStorageRotateKeys re-wraps the data keys of encrypted files with the
+// current master key.
+func runStorageRotateKeys(args []string) error {
+	return rewriteStoredFiles("storage rotate-keys", args, fileRewrite{
+		verb: "rotated",
+		pending: func(keyID string, encrypted bool, current string) bool {
+			return encrypted && keyID != current
+		},
+		apply: (*encryption.Encryptor).Rotate,
+	})
+}
+
+// runStorageEncrypt encrypts plaintext files stored before encryption was
+// enabled.
+func runStorageEncrypt(args []string) error {
+	return rewriteStoredFiles("storage encrypt", args, fileRewrite{
+		verb: "encrypted",
+		pending: func(keyID string, encrypted bool, current string) bool {
+			return !encrypted
+		},
+		apply: (*encryption.Encryptor).EncryptFile,
+	})
+}
+
+// rewriteStoredFiles applies rw to every recording and note file, or lists
+// the files it would change with --dry-run.
+func rewriteStoredFiles(name string, args []string, rw fileRewrite) error {
+	flags := newFlags(name)
+	dryRun := flags.Bool("dry-run", false, "list the files that would change without changing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := config.Default()
+	files, err := encryption.FromOptions(encryption.Options{
+		Provider:   cfg.StorageEncryption,
+		Keys:       cfg.StorageEncryptionKeys,
+		KeyID:      cfg.StorageEncryptionKeyID,
+		VaultAddr:  cfg.VaultAddr,
+		VaultKey:   cfg.VaultTransitKey,
+		VaultToken: cfg.VaultToken,
+	})
+	if err != nil {
+		return err
+	}
+	if files == nil {
+		return errors.New("STORAGE_ENCRYPTION is not set")
+	}
+
+	// Files are rewritten one at a time, so there is no overall timeout
+	ctx := context.Background()
+	current, err := files.CurrentKeyID(ctx)
+	if err != nil {
+		return fmt.Errorf("current master key: %w", err)
+	}
+
+	changed, failed := 0, 0
+	for _, dir := range encryptedDirs {
+		root := filepath.Join(cfg.StoragePath, dir)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && path == root {
+				return filepath.SkipDir
+			}
+			if err != nil || d.IsDir() || isTempFile(path) {
+				return err
+			}
+
+			if *dryRun {
+				keyID, encrypted, err := encryption.KeyID(path)
+				if err != nil {
+					return err
+				}
+				if rw.pending(keyID, encrypted, current) {
+					fmt.Printf("pending  %s\n", path)
+					changed++
+				}
+				return nil
+			}
+
+			ok, err := rw.apply(files, ctx, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				failed++
+				return nil
+			}
+			if ok {
+				fmt.Printf("%s  %s\n", rw.verb, path)
+				changed++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d files pending (current key %s)\n", changed, current)
+		return nil
+	}
+	fmt.Printf("%d files %s (current key %s)\n", changed, rw.verb, current)
+	if failed > 0 {
+		return fmt.Errorf("%d files failed", failed)
+	}
+	return nil
+}
+
+// isTempFile reports whether path is a partial file being written by the
+// server or a previous run.
+func isTempFile(path string) bool {
+	for _, suffix := range []string{".rotating", ".restoring", ".partial"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+// Package main is loadtest, a load generator for LiveClass rooms.
+//
+// It spawns synthetic viewers that join rooms over the WebSocket signaling
+// protocol, optionally as headless WebRTC receivers, and reports how long
+// joins, stream offers and media take to arrive. With --presenter it also
+// joins each room as a synthetic presenter streaming a test pattern, so a
+// run needs no browser.
+//
+// Viewers are spread round-robin over the rooms and servers given, which
+// exercises instances behind a load balancer or talking to each other over
+// Redis.
+//
+// Usage:
+//
+//	loadtest --rooms LOAD1,LOAD2 --viewers 200 --presenter --webrtc
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// options are the settings of a run.
+type options struct {
+	servers   []string // WebSocket URLs of the servers
+	rooms     []string
+	viewers   int
+	ramp      time.Duration // Between viewer joins
+	duration  time.Duration // How long viewers stay once all have joined
+	webrtc    bool          // Viewers answer offers and receive media
+	presenter bool          // Stream a test pattern into each room
+	bitrate   int           // Test pattern bitrate in kbit/s
+	token     string        // Join token, for rooms that require sign-in
+	timeout   time.Duration // Per-step timeout (join, offer, connect)
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseOptions parses the command line.
+func parseOptions(args []string) (*options, error) {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	servers := fs.String("url", "ws://localhost:8080/ws", "Comma-separated WebSocket URLs; viewers are spread round-robin")
+	rooms := fs.String("rooms", "LOADTEST", "Comma-separated room IDs; viewers are spread round-robin")
+	opts := &options{}
+	fs.IntVar(&opts.viewers, "viewers", 10, "Number of synthetic viewers")
+	fs.DurationVar(&opts.ramp, "ramp", 50*time.Millisecond, "Delay between viewer joins")
+	fs.DurationVar(&opts.duration, "duration", 30*time.Second, "How long viewers stay after the last one joined")
+	fs.BoolVar(&opts.webrtc, "webrtc", false, "Answer offers with headless WebRTC receivers and count media packets")
+	fs.BoolVar(&opts.presenter, "presenter", false, "Join each room as a synthetic presenter streaming a test pattern")
+	fs.IntVar(&opts.bitrate, "bitrate", 500, "Test pattern video bitrate in kbit/s")
+	fs.StringVar(&opts.token, "token", "", "Token to join with (default: anonymous)")
+	fs.DurationVar(&opts.timeout, "timeout", 15*time.Second, "Timeout of each step (join, offer, connection)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	opts.servers = splitList(*servers)
+	opts.rooms = splitList(*rooms)
+	switch {
+	case len(opts.servers) == 0:
+		return nil, fmt.Errorf("--url is required")
+	case len(opts.rooms) == 0:
+		return nil, fmt.Errorf("--rooms is required")
+	case opts.viewers < 0:
+		return nil, fmt.Errorf("--viewers must not be negative")
+	case opts.bitrate <= 0:
+		return nil, fmt.Errorf("--bitrate must be positive")
+	}
+	for i, room := range opts.rooms {
+		opts.rooms[i] = strings.ToUpper(room)
+	}
+	return opts, nil
+}
+
+// run starts the presenters and viewers, waits for the run to finish and
+// prints the report.
+func run(ctx context.Context, opts *options) error {
+	var presenters []*presenter
+	if opts.presenter {
+		for i, room := range opts.rooms {
+			p, err := startPresenter(ctx, opts, opts.servers[i%len(opts.servers)], room)
+			if err != nil {
+				for _, p := range presenters {
+					p.close()
+				}
+				return fmt.Errorf("presenter of %s: %w", room, err)
+			}
+			presenters = append(presenters, p)
+		}
+		fmt.Fprintf(os.Stderr, "Streaming a test pattern into %d room(s)\n", len(presenters))
+	}
+	defer func() {
+		for _, p := range presenters {
+			p.close()
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Joining %d viewer(s) across %d room(s) and %d server(s)\n",
+		opts.viewers, len(opts.rooms), len(opts.servers))
+
+	results := make([]*viewerResult, opts.viewers)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	started := time.Now()
+spawn:
+	for i := 0; i < opts.viewers; i++ {
+		if i > 0 && opts.ramp > 0 {
+			select {
+			case <-ctx.Done():
+				break spawn
+			case <-time.After(opts.ramp):
+			}
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v := &viewer{
+				opts:   opts,
+				server: opts.servers[i%len(opts.servers)],
+				room:   opts.rooms[i%len(opts.rooms)],
+				name:   fmt.Sprintf("loadtest-viewer-%d", i+1),
+			}
+			results[i] = v.run(ctx, done)
+		}(i)
+	}
+
+	fmt.Fprintf(os.Stderr, "All viewers started in %v, holding for %v\n", time.Since(started).Round(time.Millisecond), opts.duration)
+	select {
+	case <-ctx.Done():
+	case <-time.After(opts.duration):
+	}
+	close(done)
+	wg.Wait()
+
+	var sent uint64
+	for _, p := range presenters {
+		sent += p.sent()
+	}
+	printReport(os.Stdout, opts, results, sent)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Test pattern layout. Each video frame is split over packets whose VP8
+// payload descriptor is followed by a marker and the time the packet was
+// sent, so receivers in the same run can measure delivery latency. The rest
+// of the payload is filler; the frames aren't decodable video.
+const (
+	patternFPS        = 30
+	patternClockRate  = 90000
+	patternMaxPayload = 1100 // Keeps packets under typical path MTUs
+	patternMarker     = "LCLT"
+	patternHeaderLen  = 1 + len(patternMarker) + 8
+)
+
+// patternFrame returns the payloads of one video frame of frameBytes bytes
+// sent at now.
+func patternFrame(frame uint32, frameBytes int, now time.Time) [][]byte {
+	var payloads [][]byte
+	for remaining := frameBytes; remaining > 0 || len(payloads) == 0; {
+		size := min(max(remaining, patternHeaderLen), patternMaxPayload)
+		payload := make([]byte, size)
+		if len(payloads) == 0 {
+			payload[0] = 0x10 // Start of a VP8 partition
+		}
+		copy(payload[1:], patternMarker)
+		binary.BigEndian.PutUint64(payload[1+len(patternMarker):], uint64(now.UnixNano()))
+		for i := patternHeaderLen; i < size; i++ {
+			payload[i] = byte(frame)
+		}
+		payloads = append(payloads, payload)
+		remaining -= size
+	}
+	return payloads
+}
+
+// patternSentAt returns when a test pattern packet was sent, or false if the
+// payload isn't from the test pattern.
+func patternSentAt(payload []byte) (time.Time, bool) {
+	if len(payload) < patternHeaderLen || string(payload[1:1+len(patternMarker)]) != patternMarker {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(payload[1+len(patternMarker):]))), true
+}
+
+// opusSilence is an Opus packet of 20ms of silence.
+var opusSilence = []byte{0xF8, 0xFF, 0xFE}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// presenter is a synthetic presenter: it joins a room as the presenter and
+// streams the test pattern through the server like a browser would.
+type presenter struct {
+	room    string
+	conn    *signalConn
+	pc      *webrtc.PeerConnection
+	video   *webrtc.TrackLocalStaticRTP
+	audio   *webrtc.TrackLocalStaticRTP
+	cancel  context.CancelFunc
+	packets atomic.Uint64
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	pending []webrtc.ICECandidateInit // Candidates received before the answer
+}
+
+// startPresenter joins room on server as the presenter and starts streaming
+// once the server's connection is up.
+func startPresenter(ctx context.Context, opts *options, server, room string) (*presenter, error) {
+	conn, err := dialSignal(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	p := &presenter{room: room, conn: conn}
+
+	if err := conn.send(message{Type: "join", RoomID: room, Name: "loadtest-presenter", IsPresenter: true, Token: opts.token}); err != nil {
+		conn.close()
+		return nil, err
+	}
+	joined, err := awaitJoined(conn, opts.timeout)
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+
+	if err := p.negotiate(joined.ICEServers, opts.timeout); err != nil {
+		p.close()
+		return nil, err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	frameBytes := opts.bitrate * 1000 / 8 / patternFPS
+	p.wg.Add(2)
+	go p.streamVideo(streamCtx, frameBytes)
+	go p.streamAudio(streamCtx)
+	return p, nil
+}
+
+// negotiate offers the test pattern tracks and waits until the server's
+// peer connection is connected.
+func (p *presenter) negotiate(iceServers []webrtc.ICEServer, timeout time.Duration) error {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{ICEServers: iceServers})
+	if err != nil {
+		return err
+	}
+	p.pc = pc
+
+	p.video, err = webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", "loadtest")
+	if err != nil {
+		return err
+	}
+	p.audio, err = webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "loadtest")
+	if err != nil {
+		return err
+	}
+	for _, track := range []webrtc.TrackLocal{p.video, p.audio} {
+		sender, err := pc.AddTrack(track)
+		if err != nil {
+			return err
+		}
+		go drainRTCP(sender)
+	}
+
+	connected := make(chan struct{})
+	failed := make(chan error, 1)
+	var once sync.Once
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			once.Do(func() { close(connected) })
+		case webrtc.PeerConnectionStateFailed:
+			select {
+			case failed <- errors.New("connection failed"):
+			default:
+			}
+		}
+	})
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			p.conn.sendPayload("ice-candidate", c.ToJSON())
+		}
+	})
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	if err := p.conn.sendPayload("offer", offer); err != nil {
+		return err
+	}
+	go p.readSignal(failed)
+
+	select {
+	case <-connected:
+		return nil
+	case err := <-failed:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("not connected after %v", timeout)
+	}
+}
+
+// readSignal handles the server's answer and candidates until the
+// connection closes. Errors go to failed, which keeps only the first.
+func (p *presenter) readSignal(failed chan<- error) {
+	fail := func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+	}
+
+	for {
+		msg, err := p.conn.read()
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		switch msg.Type {
+		case "answer":
+			var answer webrtc.SessionDescription
+			if err := json.Unmarshal(msg.Payload, &answer); err != nil {
+				fail(err)
+				continue
+			}
+			p.mu.Lock()
+			if err := p.pc.SetRemoteDescription(answer); err != nil {
+				fail(err)
+			}
+			for _, candidate := range p.pending {
+				p.pc.AddICECandidate(candidate)
+			}
+			p.pending = nil
+			p.mu.Unlock()
+		case "ice-candidate":
+			p.mu.Lock()
+			if p.pc.RemoteDescription() == nil {
+				var candidate webrtc.ICECandidateInit
+				if json.Unmarshal(msg.Payload, &candidate) == nil {
+					p.pending = append(p.pending, candidate)
+				}
+			} else if err := addCandidate(p.pc, msg.Payload); err != nil {
+				log.Printf("[Presenter %s] Bad ICE candidate: %v", p.room, err)
+			}
+			p.mu.Unlock()
+		case "room-token":
+			p.conn.setRoomToken(msg.RoomToken)
+		case "error":
+			log.Printf("[Presenter %s] Server error: %s", p.room, msg.Message)
+			fail(errors.New(msg.Message))
+		}
+	}
+}
+
+// streamVideo sends test pattern frames of frameBytes at patternFPS.
+func (p *presenter) streamVideo(ctx context.Context, frameBytes int) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(time.Second / patternFPS)
+	defer ticker.Stop()
+
+	var seq uint16
+	var frame uint32
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			payloads := patternFrame(frame, frameBytes, now)
+			for i, payload := range payloads {
+				packet := &rtp.Packet{
+					Header: rtp.Header{
+						Version:        2,
+						SequenceNumber: seq,
+						Timestamp:      frame * (patternClockRate / patternFPS),
+						Marker:         i == len(payloads)-1,
+					},
+					Payload: payload,
+				}
+				if err := p.video.WriteRTP(packet); err != nil {
+					return
+				}
+				seq++
+				p.packets.Add(1)
+			}
+			frame++
+		}
+	}
+}
+
+// streamAudio sends 20ms Opus packets of silence.
+func (p *presenter) streamAudio(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+
+	var seq uint16
+	var timestamp uint32
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			packet := &rtp.Packet{
+				Header:  rtp.Header{Version: 2, SequenceNumber: seq, Timestamp: timestamp},
+				Payload: opusSilence,
+			}
+			if err := p.audio.WriteRTP(packet); err != nil {
+				return
+			}
+			seq++
+			timestamp += 960 // 20ms at 48kHz
+		}
+	}
+}
+
+// sent returns the number of video packets sent.
+func (p *presenter) sent() uint64 {
+	return p.packets.Load()
+}
+
+// close stops streaming and leaves the room.
+func (p *presenter) close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	if p.pc != nil {
+		p.pc.Close()
+	}
+	p.conn.close()
+}
+
+// drainRTCP reads RTCP from a sender until it closes, so interceptors run.
+func drainRTCP(sender *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := sender.Read(buf); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// maxErrorsShown bounds the distinct errors listed in the report.
+const maxErrorsShown = 10
+
+// printReport writes the results of a run: latency percentiles of each step,
+// packet delivery and why viewers failed.
+func printReport(w io.Writer, opts *options, results []*viewerResult, sent uint64) {
+	var join, offer, connect, firstPacket, latency []time.Duration
+	var packets, lost uint64
+	var offers, failures, receiving int
+	failed := map[string]int{}
+	errs := map[string]int{}
+
+	started := 0
+	for _, result := range results {
+		if result == nil {
+			continue // Not started before the run was interrupted
+		}
+		started++
+		if result.err != nil {
+			failed[result.stage]++
+			errs[result.stage+": "+result.err.Error()]++
+		}
+		if result.join > 0 {
+			join = append(join, result.join)
+		}
+		if result.offer > 0 {
+			offer = append(offer, result.offer)
+		}
+		if result.connect > 0 {
+			connect = append(connect, result.connect)
+		}
+		if result.firstPacket > 0 {
+			firstPacket = append(firstPacket, result.firstPacket)
+		}
+		if result.packets > 0 {
+			receiving++
+		}
+		latency = append(latency, result.latencies...)
+		packets += result.packets
+		lost += result.lost
+		offers += result.offers
+		failures += result.failures
+	}
+
+	failedTotal := 0
+	for _, n := range failed {
+		failedTotal += n
+	}
+	fmt.Fprintf(w, "\nViewers: %d started, %d failed, %d offer(s) received, %d connection failure(s)\n",
+		started, failedTotal, offers, failures)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nSTEP\tCOUNT\tP50\tP90\tP99\tMAX")
+	printPercentiles(tw, "join", join)
+	printPercentiles(tw, "offer push", offer)
+	if opts.webrtc {
+		printPercentiles(tw, "connected", connect)
+		printPercentiles(tw, "first packet", firstPacket)
+		printPercentiles(tw, "delivery", latency)
+	}
+	tw.Flush()
+
+	if opts.webrtc {
+		fmt.Fprintf(w, "\nMedia: %d viewer(s) receiving, %d video packet(s) received", receiving, packets)
+		if packets+lost > 0 {
+			fmt.Fprintf(w, ", %d lost (%.2f%%)", lost, float64(lost)*100/float64(packets+lost))
+		}
+		fmt.Fprintln(w)
+	}
+	if sent > 0 {
+		fmt.Fprintf(w, "Test pattern: %d video packet(s) sent\n", sent)
+	}
+
+	if failedTotal == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nFailures:")
+	for _, stage := range []string{stageConnect, stageJoin, stageOffer, stageMedia} {
+		if failed[stage] > 0 {
+			fmt.Fprintf(w, "  %-8s %d\n", stage, failed[stage])
+		}
+	}
+
+	messages := make([]string, 0, len(errs))
+	for msg := range errs {
+		messages = append(messages, msg)
+	}
+	sort.Slice(messages, func(i, j int) bool { return errs[messages[i]] > errs[messages[j]] })
+	for i, msg := range messages {
+		if i == maxErrorsShown {
+			fmt.Fprintf(w, "  ... and %d more\n", len(messages)-i)
+			break
+		}
+		fmt.Fprintf(w, "  %5dx %s\n", errs[msg], msg)
+	}
+}
+
+// printPercentiles writes a row of latency percentiles.
+func printPercentiles(w io.Writer, step string, values []time.Duration) {
+	if len(values) == 0 {
+		fmt.Fprintf(w, "%s\t0\t-\t-\t-\t-\n", step)
+		return
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\n", step, len(values),
+		percentile(values, 50), percentile(values, 90), percentile(values, 99), round(values[len(values)-1]))
+}
+
+// percentile returns the p-th percentile of sorted values (nearest rank).
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (len(sorted)*p + 99) / 100
+	return round(sorted[max(rank, 1)-1])
+}
+
+// round rounds a duration for display.
+func round(d time.Duration) time.Duration {
+	if d >= 10*time.Millisecond {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v3"
+)
+
+// message is a signaling message in either direction. Only the fields the
+// load test uses are decoded.
+type message struct {
+	Type        string          `json:"type"`
+	RoomID      string          `json:"roomId,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	IsPresenter bool            `json:"isPresenter,omitempty"`
+	Token       string          `json:"token,omitempty"`
+	RoomToken   string          `json:"roomToken,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+
+	// Sent by the server
+	StreamReady bool               `json:"streamReady,omitempty"`
+	ICEServers  []webrtc.ICEServer `json:"iceServers,omitempty"`
+	Message     string             `json:"message,omitempty"` // Of "error" messages
+}
+
+// signalConn is a signaling WebSocket connection. Writes are serialized, as
+// pion callbacks send ICE candidates from their own goroutines.
+type signalConn struct {
+	ws        *websocket.Conn
+	mu        sync.Mutex
+	roomToken string // Sent with every message once joined
+}
+
+// dialSignal connects to a server's signaling endpoint.
+func dialSignal(ctx context.Context, url string) (*signalConn, error) {
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &signalConn{ws: ws}, nil
+}
+
+// send writes a message, with the room token once there is one.
+func (c *signalConn) send(msg message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.RoomToken == "" {
+		msg.RoomToken = c.roomToken
+	}
+	return c.ws.WriteJSON(msg)
+}
+
+// setRoomToken replaces the room token, as issued on join and renewed by
+// "room-token" messages.
+func (c *signalConn) setRoomToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roomToken = token
+}
+
+// sendPayload writes a message of the given type carrying v as its payload.
+func (c *signalConn) sendPayload(msgType string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.send(message{Type: msgType, Payload: payload})
+}
+
+// read reads the next message.
+func (c *signalConn) read() (message, error) {
+	var msg message
+	_, data, err := c.ws.ReadMessage()
+	if err != nil {
+		return msg, err
+	}
+	err = json.Unmarshal(data, &msg)
+	return msg, err
+}
+
+// close closes the connection.
+func (c *signalConn) close() {
+	c.ws.Close()
+}
+
+// addCandidate adds a candidate the server trickled to a peer connection.
+func addCandidate(pc *webrtc.PeerConnection, payload json.RawMessage) error {
+	var candidate webrtc.ICECandidateInit
+	if err := json.Unmarshal(payload, &candidate); err != nil {
+		return err
+	}
+	return pc.AddICECandidate(candidate)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// latencySampleEvery is how often a received test pattern packet is sampled
+// for delivery latency.
+const latencySampleEvery = 10
+
+// Stages a viewer can fail at.
+const (
+	stageConnect = "connect"
+	stageJoin    = "join"
+	stageOffer   = "offer"
+	stageMedia   = "media"
+)
+
+// viewerResult is what one viewer measured.
+type viewerResult struct {
+	err   error  // Why the viewer gave up, or nil
+	stage string // Stage of err
+
+	join        time.Duration // Join sent to "joined"
+	offer       time.Duration // Stream available to offer received
+	connect     time.Duration // Offer received to peer connection connected
+	firstPacket time.Duration // Offer received to first video packet
+	offers      int           // Offers received, more than one means reconnects
+	failures    int           // Connection failures and rejoin requests
+
+	packets   uint64
+	lost      uint64
+	latencies []time.Duration // Sampled delivery latency of test pattern packets
+}
+
+// viewer is a synthetic viewer in a room.
+type viewer struct {
+	opts   *options
+	server string
+	room   string
+	name   string
+
+	conn *signalConn
+	pc   *webrtc.PeerConnection
+
+	mu       sync.Mutex // Guards the media fields of result
+	result   viewerResult
+	pending  []webrtc.ICECandidateInit // Candidates received before the offer was applied
+	received bool
+}
+
+// run joins the room and stays until done is closed, the context is
+// cancelled or a step times out.
+func (v *viewer) run(ctx context.Context, done <-chan struct{}) *viewerResult {
+	dialCtx, cancel := context.WithTimeout(ctx, v.opts.timeout)
+	conn, err := dialSignal(dialCtx, v.server)
+	cancel()
+	if err != nil {
+		return v.fail(stageConnect, err)
+	}
+	v.conn = conn
+	defer v.close()
+
+	sentAt := time.Now()
+	if err := conn.send(message{Type: "join", RoomID: v.room, Name: v.name, Token: v.opts.token}); err != nil {
+		return v.fail(stageJoin, err)
+	}
+	joined, err := awaitJoined(conn, v.opts.timeout)
+	if err != nil {
+		return v.fail(stageJoin, err)
+	}
+	v.result.join = time.Since(sentAt)
+
+	var availableAt time.Time
+	if joined.StreamReady {
+		availableAt = time.Now()
+	}
+
+	messages := make(chan message, 16)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(messages)
+		for {
+			msg, err := conn.read()
+			if err != nil {
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// Without a presenter streaming, offers can't arrive in time
+	offerDeadline := time.After(v.opts.timeout)
+	if !joined.StreamReady {
+		offerDeadline = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return v.finish()
+		case <-done:
+			return v.finish()
+		case <-offerDeadline:
+			if v.result.offers == 0 {
+				return v.fail(stageOffer, fmt.Errorf("no offer %v after the stream was available", v.opts.timeout))
+			}
+		case msg, ok := <-messages:
+			if !ok {
+				return v.fail(stageMedia, errors.New("server closed the connection"))
+			}
+			switch msg.Type {
+			case "stream-available":
+				if availableAt.IsZero() {
+					availableAt = time.Now()
+					offerDeadline = time.After(v.opts.timeout)
+				}
+			case "offer":
+				if v.result.offers == 0 && !availableAt.IsZero() {
+					v.result.offer = time.Since(availableAt)
+				}
+				v.result.offers++
+				if v.opts.webrtc {
+					if err := v.answer(joined.ICEServers, msg.Payload); err != nil {
+						return v.fail(stageMedia, err)
+					}
+				}
+			case "ice-candidate":
+				v.addCandidate(msg.Payload)
+			case "room-token":
+				conn.setRoomToken(msg.RoomToken)
+			case "connection-failed", "rejoin-required":
+				v.result.failures++
+			case "error":
+				return v.fail(stageMedia, errors.New(msg.Message))
+			}
+		}
+	}
+}
+
+// answer answers an offer with a new receive-only peer connection. The server
+// creates a new connection for every offer but ICE restarts, which are rare
+// enough in a load test to be handled as a new connection too.
+func (v *viewer) answer(iceServers []webrtc.ICEServer, payload json.RawMessage) error {
+	var offer webrtc.SessionDescription
+	if err := json.Unmarshal(payload, &offer); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.pc != nil {
+		v.pc.Close()
+	}
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{ICEServers: iceServers})
+	if err != nil {
+		return err
+	}
+	v.pc = pc
+	offerAt := time.Now()
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state != webrtc.PeerConnectionStateConnected {
+			return
+		}
+		v.mu.Lock()
+		if v.result.connect == 0 {
+			v.result.connect = time.Since(offerAt)
+		}
+		v.mu.Unlock()
+	})
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c != nil {
+			v.conn.sendPayload("ice-candidate", c.ToJSON())
+		}
+	})
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		v.receive(track, offerAt)
+	})
+
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		return err
+	}
+	for _, candidate := range v.pending {
+		pc.AddICECandidate(candidate)
+	}
+	v.pending = nil
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	return v.conn.sendPayload("answer", answer)
+}
+
+// addCandidate adds a candidate from the server, or queues it until the
+// offer is applied.
+func (v *viewer) addCandidate(payload json.RawMessage) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.pc == nil || v.pc.RemoteDescription() == nil {
+		var candidate webrtc.ICECandidateInit
+		if json.Unmarshal(payload, &candidate) == nil {
+			v.pending = append(v.pending, candidate)
+		}
+		return
+	}
+	addCandidate(v.pc, payload)
+}
+
+// receive reads a track until it ends. Video packets are counted, with
+// sequence gaps as losses and sampled test pattern latency; audio is read
+// and dropped.
+func (v *viewer) receive(track *webrtc.TrackRemote, offerAt time.Time) {
+	video := track.Kind() == webrtc.RTPCodecTypeVideo
+	var last uint16
+	first := true
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			return
+		}
+		if !video {
+			continue
+		}
+		now := time.Now()
+
+		v.mu.Lock()
+		if !v.received {
+			v.received = true
+			v.result.firstPacket = now.Sub(offerAt)
+		}
+		v.result.packets++
+		// Only packets ahead of the last one count; reordered ones aren't losses
+		if gap := packet.SequenceNumber - last; first || gap < 0x8000 {
+			if !first && gap > 1 {
+				v.result.lost += uint64(gap - 1)
+			}
+			last, first = packet.SequenceNumber, false
+		}
+		if v.result.packets%latencySampleEvery == 0 {
+			if sentAt, ok := patternSentAt(packet.Payload); ok {
+				v.result.latencies = append(v.result.latencies, now.Sub(sentAt))
+			}
+		}
+		v.mu.Unlock()
+	}
+}
+
+// finish returns the result of a viewer that stayed until the end.
+func (v *viewer) finish() *viewerResult {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	result := v.result
+	if v.opts.webrtc && result.offers > 0 && result.packets == 0 {
+		result.stage, result.err = stageMedia, errors.New("no media received")
+	}
+	return &result
+}
+
+// fail returns the result of a viewer that gave up at stage.
+func (v *viewer) fail(stage string, err error) *viewerResult {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	result := v.result
+	result.stage, result.err = stage, err
+	return &result
+}
+
+// close leaves the room.
+func (v *viewer) close() {
+	v.mu.Lock()
+	if v.pc != nil {
+		v.pc.Close()
+	}
+	v.mu.Unlock()
+	v.conn.close()
+}
+
+// awaitJoined waits for the server to confirm a join.
+func awaitJoined(conn *signalConn, timeout time.Duration) (message, error) {
+	conn.ws.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.ws.SetReadDeadline(time.Time{})
+
+	for {
+		msg, err := conn.read()
+		if err != nil {
+			return msg, err
+		}
+		switch msg.Type {
+		case "joined":
+			conn.setRoomToken(msg.RoomToken)
+			return msg, nil
+		case "error":
+			return msg, errors.New(msg.Message)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package accounts anonymizes the accounts students asked to deactivate,
+// once their grace period is over.
+package accounts
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// anonymizedName replaces the name of an anonymized account wherever it is
+// shown, such as attendance reports.
+const anonymizedName = "Deleted user"
+
+// Anonymizer removes the personal details of deactivated accounts: name,
+// email, password, preferences, signed-in devices and notifications. The
+// account's ID stays, so batch membership and attendance still count it.
+//
+// Accounts are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Anonymizer struct {
+	userRepo         *repository.UserRepository
+	sessionRepo      *repository.SessionRepository
+	notificationRepo *repository.NotificationRepository
+	attendanceRepo   *repository.AttendanceRepository
+	interval         time.Duration
+}
+
+// NewAnonymizer creates an anonymizer checking for due accounts every interval.
+func NewAnonymizer(
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	notificationRepo *repository.NotificationRepository,
+	attendanceRepo *repository.AttendanceRepository,
+	interval time.Duration,
+) *Anonymizer {
+	return &Anonymizer{
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		notificationRepo: notificationRepo,
+		attendanceRepo:   attendanceRepo,
+		interval:         interval,
+	}
+}
+
+// Run anonymizes accounts whose grace period is over, immediately and then
+// every interval, until ctx is cancelled.
+func (a *Anonymizer) Run(ctx context.Context) {
+	a.anonymizeDue(ctx)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.anonymizeDue(ctx)
+		}
+	}
+}
+
+// anonymizeDue anonymizes the accounts whose grace period is over.
+func (a *Anonymizer) anonymizeDue(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	users, err := a.userRepo.FindDueForDeactivation(ctx, time.Now())
+	if err != nil {
+		log.Printf("[Accounts] Failed to load accounts due for deactivation: %v", err)
+		return
+	}
+	for i := range users {
+		a.anonymize(ctx, &users[i])
+	}
+}
+
+// anonymize anonymizes one account, then removes its personal data kept
+// elsewhere. Failures there are only logged, as the account is already
+// claimed.
+func (a *Anonymizer) anonymize(ctx context.Context, user *models.User) {
+	// Emails are unique, so each account gets its own placeholder
+	email := "deleted-" + user.ID.Hex() + "@invalid"
+	claimed, err := a.userRepo.Anonymize(ctx, user, anonymizedName, email)
+	if err != nil {
+		log.Printf("[Accounts] Failed to anonymize account %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if !claimed {
+		return // Reactivated, or anonymized by another instance
+	}
+
+	if err := a.sessionRepo.DeleteByUser(ctx, user.ID); err != nil {
+		log.Printf("[Accounts] Failed to delete sessions of account %s: %v", user.ID.Hex(), err)
+	}
+	if err := a.notificationRepo.DeleteByUser(ctx, user.ID); err != nil {
+		log.Printf("[Accounts] Failed to delete notifications of account %s: %v", user.ID.Hex(), err)
+	}
+	if err := a.attendanceRepo.RenameUser(ctx, user.ID, anonymizedName); err != nil {
+		log.Printf("[Accounts] Failed to anonymize attendance of account %s: %v", user.ID.Hex(), err)
+	}
+	log.Printf("[Accounts] Anonymized deactivated account %s", user.ID.Hex())
+}
@@ -0,0 +1,368 @@
+// Package alerts watches the health of live classes and alerts admins, and
+// the class presenter, in real time when it degrades: viewers dropping out
+// together, the presenter's connection failing, or media failing to forward.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// alertsRaised counts class health alerts.
+var alertsRaised = metrics.NewCounterVec(
+	"liveclass_class_health_alerts_total",
+	"Class health alerts raised by kind (viewer-drop, presenter-ice, forward-errors).",
+	"kind",
+)
+
+// Kinds of alert
+const (
+	KindViewerDrop    = "viewer-drop"
+	KindPresenterICE  = "presenter-ice"
+	KindForwardErrors = "forward-errors"
+)
+
+const (
+	// checkInterval is how often rooms are checked against the thresholds.
+	checkInterval = 10 * time.Second
+	// dropSettle is how long viewers leaving waits before counting as a
+	// drop, so viewers leaving a class as it ends aren't taken for one: by
+	// then the presenter has left too.
+	dropSettle = 5 * time.Second
+)
+
+// Policy sets when alerts are raised. A zero threshold disables its alert.
+type Policy struct {
+	Window               time.Duration // Span the thresholds are measured over
+	ViewerDropPercent    int           // Of a room's viewers leaving within the window
+	MinViewers           int           // Least viewers a room had for drops to count
+	PresenterICEFailures int           // Presenter connection failures within the window
+	ForwardErrorPercent  int           // Of the packets forwarded within the window
+	MinForwarded         int           // Least packets forwarded within the window for errors to count
+	Cooldown             time.Duration // Least time between alerts of one kind for a room
+	NotifyPresenter      bool          // Alert the class presenter as well as admins
+}
+
+// Engine watches live rooms on this instance, from room events and the SFU's
+// media health reports, and raises an alert when a room crosses a threshold
+// of the policy. Alerts are in-app notifications pushed to connected users,
+// so they arrive while the class is running.
+type Engine struct {
+	policy       Policy
+	notifier     *notify.Notifier
+	scheduleRepo *repository.ScheduleRepository
+	userRepo     *repository.UserRepository
+
+	mu    sync.Mutex
+	rooms map[string]*roomHealth // By room ID
+}
+
+// NewEngine creates an engine alerting under policy.
+func NewEngine(policy Policy, notifier *notify.Notifier, scheduleRepo *repository.ScheduleRepository, userRepo *repository.UserRepository) *Engine {
+	if policy.Window <= 0 {
+		policy.Window = time.Minute
+	}
+	return &Engine{
+		policy:       policy,
+		notifier:     notifier,
+		scheduleRepo: scheduleRepo,
+		userRepo:     userRepo,
+		rooms:        make(map[string]*roomHealth),
+	}
+}
+
+// Enabled reports whether any alert is enabled.
+func (e *Engine) Enabled() bool {
+	return e.policy.ViewerDropPercent > 0 || e.policy.PresenterICEFailures > 0 || e.policy.ForwardErrorPercent > 0
+}
+
+// roomHealth is what a room did within the window.
+type roomHealth struct {
+	sessionID   string
+	presenter   bool          // In the room
+	viewers     []countSample // Viewers after each change; the first may be older than the window
+	drops       []time.Time   // Viewers leaving while the presenter was in the room
+	iceFailures []time.Time
+	forwards    []forwardSample
+	alerted     map[string]time.Time // Latest alert by kind
+}
+
+type countSample struct {
+	at    time.Time
+	count int
+}
+
+type forwardSample struct {
+	at           time.Time
+	sent, failed int
+}
+
+// Register subscribes the engine to room events.
+func (e *Engine) Register(hooks *room.Hooks) {
+	hooks.OnParticipantJoined(e.observe)
+	hooks.OnParticipantLeft(e.observe)
+	hooks.OnClassEnded(e.observe)
+}
+
+// observe records a room event.
+func (e *Engine) observe(ev room.LifecycleEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ev.Type == room.LifecycleEnded {
+		delete(e.rooms, ev.RoomID)
+		return
+	}
+	h := e.roomLocked(ev.RoomID, ev.SessionID)
+	switch ev.Type {
+	case room.LifecyclePresenterJoined:
+		h.presenter = true
+	case room.LifecyclePresenterLeft:
+		h.presenter = false
+	case room.LifecycleViewerLeft:
+		if h.presenter {
+			h.drops = append(h.drops, ev.At)
+		}
+	}
+	h.viewers = append(h.viewers, countSample{at: ev.At, count: ev.Viewers})
+}
+
+// PresenterICEFailed records a presenter's connection failing.
+func (e *Engine) PresenterICEFailed(roomID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if h, ok := e.rooms[roomID]; ok {
+		h.iceFailures = append(h.iceFailures, time.Now())
+	}
+}
+
+// ForwardResults records packets forwarded in a room.
+func (e *Engine) ForwardResults(roomID string, sent, failed int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if h, ok := e.rooms[roomID]; ok {
+		h.forwards = append(h.forwards, forwardSample{at: time.Now(), sent: sent, failed: failed})
+	}
+}
+
+// roomLocked returns the health of a room session, starting afresh for a
+// new session. Callers must hold e.mu.
+func (e *Engine) roomLocked(roomID, sessionID string) *roomHealth {
+	h, ok := e.rooms[roomID]
+	if !ok || h.sessionID != sessionID {
+		h = &roomHealth{sessionID: sessionID, alerted: make(map[string]time.Time)}
+		e.rooms[roomID] = h
+	}
+	return h
+}
+
+// Run checks rooms every checkInterval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context) {
+	if !e.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, a := range e.check(time.Now()) {
+				e.raise(ctx, a)
+			}
+		}
+	}
+}
+
+// alert is a threshold a room crossed.
+type alert struct {
+	roomID string
+	kind   string
+	title  string
+	body   string
+}
+
+// check forgets what happened before the window and returns the alerts
+// rooms are due.
+func (e *Engine) check(now time.Time) []alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var alerts []alert
+	for roomID, h := range e.rooms {
+		h.prune(now.Add(-e.policy.Window - dropSettle))
+		for _, a := range e.checkRoom(h, now) {
+			if now.Sub(h.alerted[a.kind]) < e.policy.Cooldown {
+				continue
+			}
+			h.alerted[a.kind] = now
+			a.roomID = roomID
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// checkRoom returns the alerts a room's health calls for.
+func (e *Engine) checkRoom(h *roomHealth, now time.Time) []alert {
+	var alerts []alert
+	window := formatWindow(e.policy.Window)
+
+	// Viewers dropping out, while the presenter is still there
+	if p := e.policy.ViewerDropPercent; p > 0 && h.presenter {
+		end := now.Add(-dropSettle)
+		start := end.Add(-e.policy.Window)
+		peak := h.peakViewers(start, end)
+		dropped := min(countBetween(h.drops, start, end), peak) // Viewers can rejoin and leave again
+		if dropped > 0 && peak >= max(e.policy.MinViewers, 1) && dropped*100 >= p*peak {
+			alerts = append(alerts, alert{
+				kind:  KindViewerDrop,
+				title: "Viewers dropping out",
+				body:  fmt.Sprintf("%d%% of viewers (%d of %d) dropped in the last %s.", dropped*100/peak, dropped, peak, window),
+			})
+		}
+	}
+
+	// The presenter's connection failing repeatedly
+	if n := e.policy.PresenterICEFailures; n > 0 {
+		if failures := countBetween(h.iceFailures, now.Add(-e.policy.Window), now); failures >= n {
+			alerts = append(alerts, alert{
+				kind:  KindPresenterICE,
+				title: "Presenter connection failing",
+				body:  fmt.Sprintf("The presenter's connection failed %d times in the last %s.", failures, window),
+			})
+		}
+	}
+
+	// Media failing to reach viewers
+	if p := e.policy.ForwardErrorPercent; p > 0 {
+		var sent, failed int
+		for _, f := range h.forwards {
+			if f.at.After(now.Add(-e.policy.Window)) {
+				sent, failed = sent+f.sent, failed+f.failed
+			}
+		}
+		if total := sent + failed; total > 0 && total >= e.policy.MinForwarded && failed*100 >= p*total {
+			alerts = append(alerts, alert{
+				kind:  KindForwardErrors,
+				title: "Media failing to reach viewers",
+				body:  fmt.Sprintf("%d%% of media packets (%d of %d) failed to forward in the last %s.", failed*100/total, failed, total, window),
+			})
+		}
+	}
+	return alerts
+}
+
+// prune forgets what happened before since, keeping the viewer count at
+// that time.
+func (h *roomHealth) prune(since time.Time) {
+	h.drops = after(h.drops, since)
+	h.iceFailures = after(h.iceFailures, since)
+	for len(h.forwards) > 0 && h.forwards[0].at.Before(since) {
+		h.forwards = h.forwards[1:]
+	}
+	for len(h.viewers) > 1 && !h.viewers[1].at.After(since) {
+		h.viewers = h.viewers[1:]
+	}
+}
+
+// peakViewers returns the most viewers the room had between start and end.
+func (h *roomHealth) peakViewers(start, end time.Time) int {
+	peak := 0
+	for i, s := range h.viewers {
+		if s.at.After(end) {
+			break
+		}
+		// A count from before the window held at its start unless replaced
+		if s.at.Before(start) && i+1 < len(h.viewers) && !h.viewers[i+1].at.After(start) {
+			continue
+		}
+		peak = max(peak, s.count)
+	}
+	return peak
+}
+
+// raise logs an alert and notifies admins and, under the policy, the
+// presenter of the class in the room.
+func (e *Engine) raise(ctx context.Context, a alert) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	alertsRaised.WithLabelValues(a.kind).Inc()
+
+	class := "room " + a.roomID
+	schedule, err := e.scheduleRepo.FindByRoomID(ctx, a.roomID)
+	if err != nil {
+		schedule = nil // Not a scheduled class, so there's no presenter to find
+	} else {
+		class = schedule.Title
+	}
+	log.Printf("[Alerts] ⚠️ %s in %s: %s", a.title, class, a.body)
+
+	msg := notify.Message{
+		Category: models.NotificationClassHealth,
+		Title:    a.title + " in " + class,
+		Body:     a.body,
+		Link:     "/admin",
+	}
+	e.notifier.NotifyAdmins(ctx, msg)
+
+	if !e.policy.NotifyPresenter || schedule == nil {
+		return
+	}
+	presenter, err := e.userRepo.FindByID(ctx, schedule.PresenterID.Hex())
+	if err != nil {
+		log.Printf("[Alerts] Failed to load presenter of %s: %v", class, err)
+		return
+	}
+	if presenter.Role == models.RoleAdmin {
+		return // Notified with the admins
+	}
+	msg.Link = ""
+	e.notifier.Notify(ctx, []models.User{*presenter}, msg)
+}
+
+func countBetween(times []time.Time, start, end time.Time) int {
+	n := 0
+	for _, t := range times {
+		if t.After(start) && !t.After(end) {
+			n++
+		}
+	}
+	return n
+}
+
+// after returns the times after since, in order.
+func after(times []time.Time, since time.Time) []time.Time {
+	for len(times) > 0 && !times[0].After(since) {
+		times = times[1:]
+	}
+	return times
+}
+
+// formatWindow describes a window for alert messages, e.g. "minute" or
+// "5 minutes".
+func formatWindow(d time.Duration) string {
+	switch {
+	case d == time.Minute:
+		return "minute"
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
@@ -0,0 +1,31 @@
+// Package analytics exports usage events to an external columnar store
+// (ClickHouse, BigQuery) so reporting doesn't have to query production MongoDB.
+package analytics
+
+import "time"
+
+// EventType is a kind of usage event.
+type EventType string
+
+const (
+	EventJoin   EventType = "join"   // A participant joined a live room
+	EventWatch  EventType = "watch"  // A recording was played from Value bytes of Total
+	EventChat   EventType = "chat"   // Value chat messages were sent in a room since the last flush
+	EventUpload EventType = "upload" // A recording or note of Value bytes was uploaded
+)
+
+// Event is one row in the exported events table. The schema is flat so it
+// maps directly onto a columnar table; unused fields are left empty.
+type Event struct {
+	ID       string    `json:"id"`
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Instance string    `json:"instance"`
+	UserID   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	RoomID   string    `json:"room_id"`
+	RefType  string    `json:"ref_type"` // What RefID identifies, e.g. "recording" or "note"
+	RefID    string    `json:"ref_id"`
+	Value    int64     `json:"value"`
+	Total    int64     `json:"total"`
+}
@@ -0,0 +1,229 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+)
+
+// exportedEvents counts events by outcome (exported, dropped).
+var exportedEvents = metrics.NewCounterVec(
+	"liveclass_analytics_events_total",
+	"Analytics events by outcome (exported, dropped).",
+	"result",
+)
+
+// Options controls batching and retries.
+type Options struct {
+	// BatchSize is the most events written per sink call.
+	BatchSize int
+	// FlushInterval is the longest an event waits before being written.
+	FlushInterval time.Duration
+	// QueueSize is how many events may wait for export; more are dropped so a
+	// slow sink never blocks request handling.
+	QueueSize int
+	// MaxRetries is how often a failed batch is retried before it is dropped.
+	MaxRetries int
+}
+
+// Exporter batches events and writes them to a sink in the background.
+// A nil *Exporter is valid and discards everything, so callers don't need to
+// check whether analytics is configured.
+type Exporter struct {
+	sink     Sink
+	opts     Options
+	instance string
+	queue    chan Event
+	done     chan struct{}
+
+	mu    sync.Mutex
+	chats map[string]int64 // Chat messages per room since the last flush
+}
+
+// NewExporter creates an exporter writing to sink. instance is recorded on
+// every event to tell instances apart.
+func NewExporter(sink Sink, instance string, opts Options) *Exporter {
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 10 * time.Second
+	}
+	if opts.QueueSize < opts.BatchSize {
+		opts.QueueSize = opts.BatchSize
+	}
+	return &Exporter{
+		sink:     sink,
+		opts:     opts,
+		instance: instance,
+		queue:    make(chan Event, opts.QueueSize),
+		done:     make(chan struct{}),
+		chats:    make(map[string]int64),
+	}
+}
+
+// Record queues an event for export. It never blocks; events are dropped when
+// the queue is full.
+func (e *Exporter) Record(event Event) {
+	if e == nil {
+		return
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Instance = e.instance
+
+	select {
+	case e.queue <- event:
+	default:
+		exportedEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// CountChat counts a chat message in a room. Counts are exported per room
+// with each flush rather than as one event per message.
+func (e *Exporter) CountChat(roomID string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.chats[roomID]++
+	e.mu.Unlock()
+}
+
+// Run exports events until ctx is cancelled, then writes what is left.
+func (e *Exporter) Run(ctx context.Context) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, e.opts.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			e.drain(batch)
+			return
+		case event := <-e.queue:
+			batch = append(batch, event)
+			if len(batch) >= e.opts.BatchSize {
+				e.write(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.chunked(append(batch, e.chatEvents()...), func(chunk []Event) {
+				e.write(ctx, chunk)
+			})
+			batch = batch[:0]
+		}
+	}
+}
+
+// Wait blocks until Run has written the remaining events after cancellation,
+// or ctx expires.
+func (e *Exporter) Wait(ctx context.Context) {
+	if e == nil {
+		return
+	}
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+	}
+}
+
+// drain writes the pending batch, queued events and chat counts once without
+// retries, so shutdown isn't held up by an unavailable sink.
+func (e *Exporter) drain(batch []Event) {
+queued:
+	for {
+		select {
+		case event := <-e.queue:
+			batch = append(batch, event)
+		default:
+			break queued
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	e.chunked(append(batch, e.chatEvents()...), func(chunk []Event) {
+		e.send(ctx, chunk)
+	})
+}
+
+// chunked calls fn with consecutive slices of at most BatchSize events.
+func (e *Exporter) chunked(events []Event, fn func([]Event)) {
+	for len(events) > 0 {
+		n := min(len(events), e.opts.BatchSize)
+		fn(events[:n])
+		events = events[n:]
+	}
+}
+
+// write sends a batch, retrying with exponential backoff. The batch is
+// dropped after MaxRetries failures or when ctx is cancelled.
+func (e *Exporter) write(ctx context.Context, batch []Event) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		writeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := e.sink.Write(writeCtx, batch)
+		cancel()
+		if err == nil {
+			exportedEvents.WithLabelValues("exported").Add(uint64(len(batch)))
+			return
+		}
+
+		if attempt >= e.opts.MaxRetries || ctx.Err() != nil {
+			log.Printf("[Analytics] Dropping %d events after %d attempts to %s: %v", len(batch), attempt+1, e.sink.Name(), err)
+			exportedEvents.WithLabelValues("dropped").Add(uint64(len(batch)))
+			return
+		}
+		log.Printf("[Analytics] Write to %s failed (attempt %d), retrying in %v: %v", e.sink.Name(), attempt+1, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// send makes a single write attempt.
+func (e *Exporter) send(ctx context.Context, batch []Event) {
+	if err := e.sink.Write(ctx, batch); err != nil {
+		log.Printf("[Analytics] Dropping %d events on shutdown, write to %s failed: %v", len(batch), e.sink.Name(), err)
+		exportedEvents.WithLabelValues("dropped").Add(uint64(len(batch)))
+		return
+	}
+	exportedEvents.WithLabelValues("exported").Add(uint64(len(batch)))
+}
+
+// chatEvents returns and resets the per-room chat counts.
+func (e *Exporter) chatEvents() []Event {
+	e.mu.Lock()
+	chats := e.chats
+	e.chats = make(map[string]int64)
+	e.mu.Unlock()
+
+	now := time.Now().UTC()
+	events := make([]Event, 0, len(chats))
+	for roomID, count := range chats {
+		events = append(events, Event{
+			ID:       uuid.New().String(),
+			Type:     EventChat,
+			Time:     now,
+			Instance: e.instance,
+			RoomID:   roomID,
+			Value:    count,
+		})
+	}
+	return events
+}
@@ -0,0 +1,232 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink writes batches of events to an external store. A failed write is
+// retried with the same batch, so sinks should deduplicate on Event.ID where
+// the store supports it.
+type Sink interface {
+	// Name identifies the sink in logs.
+	Name() string
+	// Write stores the batch.
+	Write(ctx context.Context, events []Event) error
+}
+
+// ClickHouse writes events through the ClickHouse HTTP interface. The table
+// needs a column per Event field, e.g.:
+//
+//	CREATE TABLE liveclass_events (
+//	    id String, type LowCardinality(String), time DateTime64(3),
+//	    instance String, user_id String, role LowCardinality(String),
+//	    room_id String, ref_type LowCardinality(String), ref_id String,
+//	    value Int64, total Int64
+//	) ENGINE = ReplacingMergeTree ORDER BY (type, time, id)
+type ClickHouse struct {
+	url      string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// NewClickHouse creates a sink inserting into table on the server at baseURL.
+func NewClickHouse(baseURL, table, user, password string) *ClickHouse {
+	query := url.Values{}
+	query.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	query.Set("date_time_input_format", "best_effort")
+
+	return &ClickHouse{
+		url:      strings.TrimSuffix(baseURL, "/") + "/?" + query.Encode(),
+		user:     user,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the sink name.
+func (c *ClickHouse) Name() string {
+	return "clickhouse"
+}
+
+// Write inserts the batch as JSONEachRow.
+func (c *ClickHouse) Write(ctx context.Context, events []Event) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("insert failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// metadataTokenURL is the GCE/GKE/Cloud Run metadata endpoint for the
+// default service account's access token.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// BigQuery writes events with the BigQuery streaming insert API. Event IDs are
+// used as insert IDs so retried batches aren't duplicated.
+//
+// Without a static access token, tokens are fetched from the GCP metadata
+// server, so the service must run on GCP with a service account allowed to
+// insert into the table.
+type BigQuery struct {
+	url    string
+	token  string
+	client *http.Client
+
+	mu          sync.Mutex
+	cached      string
+	cachedUntil time.Time
+}
+
+// NewBigQuery creates a sink inserting into project.dataset.table. token may
+// be empty to use the metadata server.
+func NewBigQuery(project, dataset, table, token string) *BigQuery {
+	return &BigQuery{
+		url: fmt.Sprintf("https://bigquery.googleapis.com/bigquery/v2/projects/%s/datasets/%s/tables/%s/insertAll",
+			url.PathEscape(project), url.PathEscape(dataset), url.PathEscape(table)),
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the sink name.
+func (b *BigQuery) Name() string {
+	return "bigquery"
+}
+
+// Write streams the batch into the table.
+func (b *BigQuery) Write(ctx context.Context, events []Event) error {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("access token: %w", err)
+	}
+
+	type row struct {
+		InsertID string `json:"insertId"`
+		JSON     Event  `json:"json"`
+	}
+	rows := make([]row, len(events))
+	for i, e := range events {
+		rows[i] = row{InsertID: e.ID, JSON: e}
+	}
+	body, err := json.Marshal(map[string]interface{}{"rows": rows})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		InsertErrors []json.RawMessage `json:"insertErrors"`
+		Error        struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusUnauthorized {
+			b.clearToken()
+		}
+		return fmt.Errorf("insert failed (status %d): %s", resp.StatusCode, result.Error.Message)
+	}
+	if len(result.InsertErrors) > 0 {
+		return fmt.Errorf("%d of %d rows rejected: %s", len(result.InsertErrors), len(events), result.InsertErrors[0])
+	}
+	return nil
+}
+
+// accessToken returns the static token or a cached metadata server token.
+func (b *BigQuery) accessToken(ctx context.Context) (string, error) {
+	if b.token != "" {
+		return b.token, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cached != "" && time.Now().Before(b.cachedUntil) {
+		return b.cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+
+	// Refresh a minute early so a token doesn't expire mid-request
+	b.cached = token.AccessToken
+	b.cachedUntil = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return b.cached, nil
+}
+
+// clearToken drops the cached token after it was rejected.
+func (b *BigQuery) clearToken() {
+	b.mu.Lock()
+	b.cached = ""
+	b.mu.Unlock()
+}
@@ -0,0 +1,91 @@
+// Package apiusage tracks how much external clients use the API per day,
+// and alerts admins when a client nears its daily quota.
+package apiusage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// DayFormat is the format of usage days, in UTC.
+const DayFormat = "2006-01-02"
+
+// recordTimeout bounds recording one request, which happens after the
+// response so clients don't wait for it.
+const recordTimeout = 5 * time.Second
+
+// Tracker records the requests of external clients.
+type Tracker struct {
+	usageRepo *repository.APIUsageRepository
+	notifier  *notify.Notifier
+	quotas    map[string]int64 // Requests per day, by client
+	alertAt   float64          // Share of the quota at which admins are alerted
+}
+
+// NewTracker creates a tracker. quotas are daily request quotas by client;
+// admins are alerted once a day when a client's requests reach alertAt
+// (0-1) of its quota. Quotas aren't enforced.
+func NewTracker(usageRepo *repository.APIUsageRepository, notifier *notify.Notifier, quotas map[string]int64, alertAt float64) *Tracker {
+	return &Tracker{
+		usageRepo: usageRepo,
+		notifier:  notifier,
+		quotas:    quotas,
+		alertAt:   alertAt,
+	}
+}
+
+// Quota returns a client's daily request quota, 0 for none.
+func (t *Tracker) Quota(client string) int64 {
+	return t.quotas[client]
+}
+
+// Record adds a request of client answered with status, in the background.
+func (t *Tracker) Record(client string, status int, bytesIn, bytesOut int64) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+		defer cancel()
+
+		day := time.Now().UTC().Format(DayFormat)
+		usage, err := t.usageRepo.Record(ctx, client, day, status >= http.StatusBadRequest, bytesIn, bytesOut)
+		if err != nil {
+			log.Printf("[APIUsage] Failed to record request of %s: %v", client, err)
+			return
+		}
+		t.checkQuota(ctx, usage)
+	}()
+}
+
+// checkQuota alerts admins the first time in a day a client's requests
+// reach the alert share of its quota.
+func (t *Tracker) checkQuota(ctx context.Context, usage *models.APIUsage) {
+	quota := t.quotas[usage.Client]
+	if quota <= 0 || usage.QuotaAlertedAt != nil || float64(usage.Requests) < t.alertAt*float64(quota) {
+		return
+	}
+
+	claimed, err := t.usageRepo.ClaimQuotaAlert(ctx, usage.Client, usage.Day)
+	if err != nil {
+		log.Printf("[APIUsage] Failed to record quota alert of %s: %v", usage.Client, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	log.Printf("[APIUsage] ⚠️ %s made %d of its %d requests for %s", usage.Client, usage.Requests, quota, usage.Day)
+	t.notifier.NotifyAdmins(ctx, notify.Message{
+		Category: models.NotificationAPIQuota,
+		Title:    usage.Client + " is nearing its API quota",
+		Body: fmt.Sprintf("%s has made %d of its %d requests for today (UTC), %.1f%% of them failed.",
+			usage.Client, usage.Requests, quota, 100*usage.ErrorRate()),
+		Link:  "/admin",
+		Email: true,
+	})
+}
@@ -0,0 +1,156 @@
+// Package archive builds the post-class archive bundle (JSON + HTML) with the
+// chat transcript, Q&A, polls, raised hands and the batch materials list.
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// Material is a class material listed in the archive.
+type Material struct {
+	Title       string `json:"title"`
+	FileName    string `json:"fileName"`
+	DownloadURL string `json:"downloadUrl"`
+}
+
+// Archive is the record of a completed class.
+type Archive struct {
+	ScheduleID    string                 `json:"scheduleId"`
+	Title         string                 `json:"title"`
+	BatchName     string                 `json:"batchName"`
+	PresenterName string                 `json:"presenterName"`
+	StartTime     time.Time              `json:"startTime"`
+	EndTime       time.Time              `json:"endTime"`
+	GeneratedAt   time.Time              `json:"generatedAt"`
+	Chat          []room.TranscriptEntry `json:"chat"`
+	Questions     []room.TranscriptEntry `json:"questions"`
+	Polls         []room.TranscriptEntry `json:"polls"`
+	RaisedHands   []room.TranscriptEntry `json:"raisedHands"`
+	Materials     []Material             `json:"materials"`
+	DroppedEvents int                    `json:"droppedEvents,omitempty"`
+}
+
+// New creates an archive and sorts transcript entries into their sections.
+func New(scheduleID, title string, start, end time.Time, entries []room.TranscriptEntry, dropped int) *Archive {
+	a := &Archive{
+		ScheduleID:    scheduleID,
+		Title:         title,
+		StartTime:     start,
+		EndTime:       end,
+		GeneratedAt:   time.Now(),
+		Chat:          []room.TranscriptEntry{},
+		Questions:     []room.TranscriptEntry{},
+		Polls:         []room.TranscriptEntry{},
+		RaisedHands:   []room.TranscriptEntry{},
+		Materials:     []Material{},
+		DroppedEvents: dropped,
+	}
+
+	for _, e := range entries {
+		switch e.Kind {
+		case room.EntryChat:
+			a.Chat = append(a.Chat, e)
+		case room.EntryQuestion:
+			a.Questions = append(a.Questions, e)
+		case room.EntryPoll:
+			a.Polls = append(a.Polls, e)
+		case room.EntryHandRaised:
+			a.RaisedHands = append(a.RaisedHands, e)
+		}
+	}
+
+	return a
+}
+
+// Write stores the archive as {dir}/{scheduleID}.json and .html and returns
+// the path without extension.
+func Write(dir string, a *Archive) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	base := filepath.Join(dir, a.ScheduleID)
+
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode archive: %w", err)
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write archive JSON: %w", err)
+	}
+
+	f, err := os.Create(base + ".html")
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive HTML: %w", err)
+	}
+	defer f.Close()
+
+	if err := htmlTemplate.Execute(f, a); err != nil {
+		return "", fmt.Errorf("failed to render archive HTML: %w", err)
+	}
+
+	return base, nil
+}
+
+var htmlTemplate = template.Must(template.New("archive").Funcs(template.FuncMap{
+	"clock": func(t time.Time) string { return t.Format("15:04:05") },
+	"date":  func(t time.Time) string { return t.Format("Mon, 02 Jan 2006 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} – Class Archive</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 860px; margin: 2rem auto; color: #1f2937; }
+h1 { margin-bottom: 0; }
+.meta { color: #6b7280; margin-top: .25rem; }
+section { margin-top: 2rem; }
+table { width: 100%; border-collapse: collapse; }
+td, th { text-align: left; padding: .35rem .5rem; border-bottom: 1px solid #e5e7eb; vertical-align: top; }
+td.time { white-space: nowrap; color: #6b7280; width: 6rem; }
+.empty { color: #9ca3af; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.BatchName}}{{if .PresenterName}} · {{.PresenterName}}{{end}} · {{date .StartTime}}</p>
+{{if .DroppedEvents}}<p class="meta">{{.DroppedEvents}} events were not recorded because the class log was full.</p>{{end}}
+
+<section>
+<h2>Chat</h2>
+{{if .Chat}}<table>{{range .Chat}}<tr><td class="time">{{clock .At}}</td><th>{{.Name}}</th><td>{{.Text}}</td></tr>{{end}}</table>
+{{else}}<p class="empty">No chat messages.</p>{{end}}
+</section>
+
+<section>
+<h2>Questions</h2>
+{{if .Questions}}<table>{{range .Questions}}<tr><td class="time">{{clock .At}}</td><th>{{.Name}}</th><td>{{.Text}}</td></tr>{{end}}</table>
+{{else}}<p class="empty">No questions.</p>{{end}}
+</section>
+
+<section>
+<h2>Polls</h2>
+{{if .Polls}}<table>{{range .Polls}}<tr><td class="time">{{clock .At}}</td><th>{{.Name}}</th><td>{{.Text}}</td></tr>{{end}}</table>
+{{else}}<p class="empty">No polls.</p>{{end}}
+</section>
+
+<section>
+<h2>Raised hands</h2>
+{{if .RaisedHands}}<table>{{range .RaisedHands}}<tr><td class="time">{{clock .At}}</td><td>{{.Name}}</td></tr>{{end}}</table>
+{{else}}<p class="empty">No raised hands.</p>{{end}}
+</section>
+
+<section>
+<h2>Materials</h2>
+{{if .Materials}}<ul>{{range .Materials}}<li><a href="{{.DownloadURL}}">{{.Title}}</a> ({{.FileName}})</li>{{end}}</ul>
+{{else}}<p class="empty">No materials attached.</p>{{end}}
+</section>
+</body>
+</html>
+`))
@@ -0,0 +1,89 @@
+package attendance
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Summarize sums up the stints of one class per account. Time in the
+// class's lobby before its scheduled start isn't counted as watched. Open
+// stints count until now while the class is live; once it is over they
+// count until its end, as stints an instance that went down never closed.
+func Summarize(schedule *models.ScheduledClass, stints []models.Attendance, now time.Time) map[primitive.ObjectID]*models.AttendanceSummary {
+	live := schedule.EffectiveStatusAt(now) == models.ClassStatusLive
+	until := now
+	if !live && schedule.EndTime.Before(now) {
+		until = schedule.EndTime
+	}
+
+	type interval struct{ from, to time.Time }
+	watched := make(map[primitive.ObjectID][]interval)
+	summaries := make(map[primitive.ObjectID]*models.AttendanceSummary)
+	for _, stint := range stints {
+		if stint.ScheduleID != schedule.ID {
+			continue
+		}
+		s, ok := summaries[stint.UserID]
+		if !ok {
+			s = &models.AttendanceSummary{
+				ScheduleID: schedule.ID.Hex(),
+				ClassTitle: schedule.Title,
+				ClassStart: schedule.StartTime,
+				UserID:     stint.UserID.Hex(),
+				Name:       stint.Name,
+				Attended:   true,
+			}
+			summaries[stint.UserID] = s
+		}
+		s.Joins++
+
+		joined := stint.JoinedAt
+		if s.FirstJoinedAt == nil || joined.Before(*s.FirstJoinedAt) {
+			s.FirstJoinedAt = &joined
+		}
+		left := until
+		if stint.LeftAt != nil {
+			left = *stint.LeftAt
+			if s.LastLeftAt == nil || left.After(*s.LastLeftAt) {
+				s.LastLeftAt = &left
+			}
+		} else if live {
+			s.Present = true
+		}
+
+		if joined.Before(schedule.StartTime) {
+			joined = schedule.StartTime
+		}
+		if left.After(joined) {
+			watched[stint.UserID] = append(watched[stint.UserID], interval{joined, left})
+		}
+	}
+
+	// Overlapping stints, as from two devices, are counted once
+	for userID, intervals := range watched {
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i].from.Before(intervals[j].from) })
+		var total time.Duration
+		current := intervals[0]
+		for _, next := range intervals[1:] {
+			if next.from.After(current.to) {
+				total += current.to.Sub(current.from)
+				current = next
+			} else if next.to.After(current.to) {
+				current.to = next.to
+			}
+		}
+		total += current.to.Sub(current.from)
+		summaries[userID].WatchedSeconds = int64(total.Seconds())
+	}
+	if live {
+		for _, s := range summaries {
+			if s.Present {
+				s.LastLeftAt = nil
+			}
+		}
+	}
+	return summaries
+}
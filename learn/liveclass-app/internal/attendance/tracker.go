@@ -0,0 +1,142 @@
+// Package attendance records who attended live classes and for how long,
+// from viewers joining and leaving class rooms.
+package attendance
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// queueSize bounds the room events waiting to be written.
+const queueSize = 10000
+
+// droppedEvents counts room events attendance couldn't keep up with.
+var droppedEvents = metrics.NewCounter(
+	"liveclass_attendance_events_dropped_total",
+	"Joins and leaves not recorded in attendance because the queue was full.",
+)
+
+// Tracker records attendance stints from room events. Events are written in
+// order in the background, so a leave is never applied before its join.
+type Tracker struct {
+	repo         *repository.AttendanceRepository
+	scheduleRepo *repository.ScheduleRepository
+	queue        chan room.LifecycleEvent
+	done         chan struct{}
+}
+
+// NewTracker creates a tracker writing to repo.
+func NewTracker(repo *repository.AttendanceRepository, scheduleRepo *repository.ScheduleRepository) *Tracker {
+	return &Tracker{
+		repo:         repo,
+		scheduleRepo: scheduleRepo,
+		queue:        make(chan room.LifecycleEvent, queueSize),
+		done:         make(chan struct{}),
+	}
+}
+
+// Register subscribes the tracker to the joins and leaves of every room.
+// Only signed-in viewers are tracked.
+func (t *Tracker) Register(hooks *room.Hooks) {
+	hooks.OnParticipantJoined(func(ev room.LifecycleEvent) {
+		if ev.Type == room.LifecycleViewerJoined && ev.UserID != "" {
+			t.enqueue(ev)
+		}
+	})
+	hooks.OnParticipantLeft(func(ev room.LifecycleEvent) {
+		if ev.Type == room.LifecycleViewerLeft && ev.UserID != "" {
+			t.enqueue(ev)
+		}
+	})
+	hooks.OnClassEnded(t.enqueue)
+}
+
+func (t *Tracker) enqueue(ev room.LifecycleEvent) {
+	select {
+	case t.queue <- ev:
+	default:
+		droppedEvents.Inc()
+	}
+}
+
+// Run writes queued events until ctx is cancelled, then writes what is left.
+func (t *Tracker) Run(ctx context.Context) {
+	defer close(t.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case ev := <-t.queue:
+					t.write(ev)
+				default:
+					return
+				}
+			}
+		case ev := <-t.queue:
+			t.write(ev)
+		}
+	}
+}
+
+// Wait blocks until Run has written the remaining events after
+// cancellation, or ctx expires.
+func (t *Tracker) Wait(ctx context.Context) {
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+	}
+}
+
+// write applies one room event.
+func (t *Tracker) write(ev room.LifecycleEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var err error
+	switch ev.Type {
+	case room.LifecycleViewerJoined:
+		err = t.joined(ctx, ev)
+	case room.LifecycleViewerLeft:
+		err = t.repo.Close(ctx, ev.SessionID, ev.ParticipantID, ev.At)
+	case room.LifecycleEnded:
+		err = t.repo.CloseSession(ctx, ev.SessionID, ev.At)
+	}
+	if err != nil {
+		log.Printf("[Attendance] Failed to record %s in room %s: %v", ev.Type, ev.RoomID, err)
+	}
+}
+
+// joined starts a stint for a viewer joining a class's room. A class's
+// lobby becomes its room, so joining the lobby counts too.
+func (t *Tracker) joined(ctx context.Context, ev room.LifecycleEvent) error {
+	userID, err := primitive.ObjectIDFromHex(ev.UserID)
+	if err != nil {
+		return nil
+	}
+	schedule, err := t.scheduleRepo.FindByRoomID(ctx, ev.RoomID)
+	if err != nil {
+		if schedule, err = t.scheduleRepo.FindByLobbyRoomID(ctx, ev.RoomID); err != nil {
+			return nil // Not a scheduled class
+		}
+	}
+
+	return t.repo.Create(ctx, &models.Attendance{
+		ScheduleID:    schedule.ID,
+		BatchID:       schedule.BatchID,
+		UserID:        userID,
+		Name:          ev.Name,
+		RoomID:        ev.RoomID,
+		SessionID:     ev.SessionID,
+		ParticipantID: ev.ParticipantID,
+		JoinedAt:      ev.At,
+	})
+}
@@ -3,30 +3,44 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Common errors
 var (
-	ErrInvalidCredentials = errors.New("invalid email or password")
-	ErrAccountPending     = errors.New("account is pending approval")
-	ErrAccountRejected    = errors.New("account has been rejected")
-	ErrAccountSuspended   = errors.New("account has been suspended")
-	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrAccountPending      = errors.New("account is pending approval")
+	ErrAccountRejected     = errors.New("account has been rejected")
+	ErrAccountSuspended    = errors.New("account has been suspended")
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrSessionRevoked      = errors.New("session has been signed out")
+	ErrAccountDeactivating = errors.New("account is scheduled for deactivation")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
 )
 
+// sessionLookupTimeout bounds the session check of a token.
+const sessionLookupTimeout = 5 * time.Second
+
 // Claims represents JWT claims.
 type Claims struct {
 	UserID string          `json:"userId"`
 	Email  string          `json:"email"`
 	Name   string          `json:"name"`
 	Role   models.UserRole `json:"role"`
+	// Login session, empty for tokens issued without one (operator tooling)
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -35,6 +49,14 @@ type Service struct {
 	userRepo  *repository.UserRepository
 	jwtSecret []byte
 	jwtExpiry time.Duration
+
+	// Signed-in devices; nil when sessions aren't tracked
+	sessions      *repository.SessionRepository
+	sessionLimits map[models.UserRole]int
+
+	// Lifetime of the access tokens of login sessions, renewed with refresh
+	// tokens; 0 when they last as long as the session
+	accessExpiry time.Duration
 }
 
 // NewService creates a new auth service.
@@ -46,24 +68,52 @@ func NewService(userRepo *repository.UserRepository, jwtSecret string, jwtExpiry
 	}
 }
 
+// SetSessions tracks a session per login in sessions, allowing each role at
+// most limits[role] signed-in devices (0 or missing for no limit). When a
+// login goes over the limit, the oldest sessions are signed out.
+func (s *Service) SetSessions(sessions *repository.SessionRepository, limits map[models.UserRole]int) {
+	s.sessions = sessions
+	s.sessionLimits = limits
+}
+
+// SetAccessTokenTTL makes the access tokens of login sessions expire after
+// ttl, to be renewed with the session's refresh token, so a signed-out
+// session stops working even where tokens aren't checked against it. 0
+// keeps them valid as long as the session.
+func (s *Service) SetAccessTokenTTL(ttl time.Duration) {
+	s.accessExpiry = ttl
+}
+
 // RegisterRequest represents a registration request.
 type RegisterRequest struct {
-	Email    string          `json:"email"`
-	Password string          `json:"password"`
-	Name     string          `json:"name"`
+	Email    string          `json:"email" validate:"required,email,max=254"`
+	Password string          `json:"password" validate:"required,min=6,max=72"`
+	Name     string          `json:"name" validate:"required,max=100"`
 	Role     models.UserRole `json:"role"`
 }
 
 // LoginRequest represents a login request.
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+	Device   string `json:"device" validate:"max=100"` // Optional name for the device, derived from the user agent if empty
+
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
 }
 
 // AuthResponse represents an authentication response.
 type AuthResponse struct {
-	Token string              `json:"token"`
-	User  models.UserResponse `json:"user"`
+	Token     string              `json:"token"`
+	ExpiresAt time.Time           `json:"expiresAt"` // Of the token
+	User      models.UserResponse `json:"user"`
+
+	// Renews the token at POST /api/auth/refresh; empty when sessions
+	// aren't tracked
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	// Sessions signed out because the login went over the device limit
+	SignedOut []models.Session `json:"-"`
 }
 
 // Register creates a new user account.
@@ -94,8 +144,19 @@ func (s *Service) Register(ctx context.Context, req RegisterRequest) (*models.Us
 	return user, nil
 }
 
-// Login authenticates a user and returns a JWT token.
+// Login authenticates a user and returns a JWT token. Accounts in their
+// deactivation grace period get ErrAccountDeactivating; see Reactivate.
 func (s *Service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+	return s.login(ctx, req, false)
+}
+
+// Reactivate authenticates a user like Login, cancelling the deactivation of
+// an account in its grace period.
+func (s *Service) Reactivate(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+	return s.login(ctx, req, true)
+}
+
+func (s *Service) login(ctx context.Context, req LoginRequest, reactivate bool) (*AuthResponse, error) {
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		if errors.Is(err, repository.ErrUserNotFound) {
@@ -119,18 +180,268 @@ func (s *Service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, e
 		return nil, ErrAccountSuspended
 	}
 
+	if user.Deactivating() {
+		if !reactivate {
+			return nil, ErrAccountDeactivating
+		}
+		reactivated, err := s.userRepo.CancelDeactivation(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if !reactivated {
+			return nil, ErrInvalidCredentials // Grace period over
+		}
+	}
+
+	session, refreshToken, signedOut, err := s.startSession(ctx, user, req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate JWT token
-	token, err := s.generateToken(user)
+	token, err := s.generateToken(user, session)
 	if err != nil {
 		return nil, err
 	}
 
 	return &AuthResponse{
-		Token: token,
-		User:  user.ToResponse(),
+		Token:        token,
+		ExpiresAt:    time.Now().Add(s.tokenExpiry(session)),
+		User:         user.ToResponse(),
+		RefreshToken: refreshToken,
+		SignedOut:    signedOut,
 	}, nil
 }
 
+// startSession records the device a user logged in from and signs out their
+// oldest sessions beyond the role's device limit. It returns the session's ID
+// and refresh token, both empty when sessions aren't tracked.
+func (s *Service) startSession(ctx context.Context, user *models.User, req LoginRequest) (string, string, []models.Session, error) {
+	if s.sessions == nil {
+		return "", "", nil, nil
+	}
+
+	device := strings.TrimSpace(req.Device)
+	if device == "" {
+		device = DeviceName(req.UserAgent)
+	}
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return "", "", nil, err
+	}
+	session := &models.Session{
+		UserID:      user.ID,
+		Role:        user.Role,
+		Device:      device,
+		UserAgent:   req.UserAgent,
+		IP:          req.IP,
+		ExpiresAt:   time.Now().Add(s.jwtExpiry),
+		RefreshHash: hashRefreshSecret(secret),
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return "", "", nil, err
+	}
+	refreshToken := session.ID.Hex() + "." + secret
+
+	limit := s.sessionLimits[user.Role]
+	if limit <= 0 {
+		return session.ID.Hex(), refreshToken, nil, nil
+	}
+	active, err := s.sessions.FindActive(ctx, user.ID)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var signedOut []models.Session
+	over := len(active) - limit
+	for _, old := range active {
+		if over <= 0 {
+			break
+		}
+		if old.ID == session.ID {
+			continue
+		}
+		if err := s.sessions.Revoke(ctx, old.ID, models.SessionRevokedLimit); err != nil {
+			return "", "", nil, err
+		}
+		signedOut = append(signedOut, old)
+		over--
+	}
+	return session.ID.Hex(), refreshToken, signedOut, nil
+}
+
+// Refresh renews the access token of the login session a refresh token
+// belongs to, replacing the refresh token too. Presenting a replaced token
+// again signs the session out, as the token must have leaked.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*AuthResponse, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if !ok || err != nil || s.sessions == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	next, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	hash := hashRefreshSecret(secret)
+	rotated, err := s.sessions.RotateRefresh(ctx, id, hash, hashRefreshSecret(next))
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		reused, err := s.sessions.RevokeReused(ctx, id, hash)
+		if err != nil {
+			return nil, err
+		}
+		if reused {
+			return nil, ErrSessionRevoked
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessions.FindByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.FindByID(ctx, session.UserID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if err := refreshable(user); err != nil {
+		reason := models.SessionRevokedSignOut
+		if errors.Is(err, ErrAccountSuspended) {
+			reason = models.SessionRevokedSuspended
+		}
+		if revokeErr := s.sessions.Revoke(ctx, id, reason); revokeErr != nil {
+			return nil, revokeErr
+		}
+		return nil, err
+	}
+
+	token, err := s.generateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		Token:        token,
+		ExpiresAt:    time.Now().Add(s.tokenExpiry(sessionID)),
+		User:         user.ToResponse(),
+		RefreshToken: sessionID + "." + next,
+	}, nil
+}
+
+// refreshable returns why a user's sessions may no longer be renewed, if
+// anything.
+func refreshable(user *models.User) error {
+	switch {
+	case user.Status == models.StatusSuspended:
+		return ErrAccountSuspended
+	case user.Status != models.StatusApproved:
+		return ErrInvalidRefreshToken
+	case user.Deactivating():
+		return ErrAccountDeactivating
+	}
+	return nil
+}
+
+// Logout signs out the login session of an access token. Tokens issued
+// without a session have nothing to sign out.
+func (s *Service) Logout(ctx context.Context, claims *Claims) error {
+	if claims.SessionID == "" || s.sessions == nil {
+		return nil
+	}
+	id, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil
+	}
+	return s.sessions.Revoke(ctx, id, models.SessionRevokedLogout)
+}
+
+// SignOutUser signs out all sessions of a user, returning them so their
+// connections can be closed.
+func (s *Service) SignOutUser(ctx context.Context, userID primitive.ObjectID, reason string) ([]models.Session, error) {
+	if s.sessions == nil {
+		return nil, nil
+	}
+	active, err := s.sessions.FindActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range active {
+		if err := s.sessions.Revoke(ctx, session.ID, reason); err != nil {
+			return nil, err
+		}
+	}
+	return active, nil
+}
+
+// ForgetSession stops trusting this instance's cached copy of a session,
+// after another instance signed it out.
+func (s *Service) ForgetSession(sessionID string) {
+	if s.sessions != nil {
+		s.sessions.Forget(sessionID)
+	}
+}
+
+// newRefreshSecret returns the random part of a refresh token.
+func newRefreshSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshSecret returns how a refresh token's secret is stored.
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Deactivate schedules a user's account to be anonymized after grace,
+// checking their password first, and signs out all their devices. It
+// returns when the account will be anonymized and the sessions signed out.
+func (s *Service) Deactivate(ctx context.Context, user *models.User, password string, grace time.Duration) (time.Time, []models.Session, error) {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return time.Time{}, nil, ErrInvalidCredentials
+	}
+
+	deactivateAt := time.Now().Add(grace)
+	if err := s.userRepo.ScheduleDeactivation(ctx, user, deactivateAt); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	signedOut, err := s.SignOutUser(ctx, user.ID, models.SessionRevokedDeactivated)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return deactivateAt, signedOut, nil
+}
+
+// Sessions returns the signed-in devices of a user, oldest first.
+func (s *Service) Sessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	if s.sessions == nil {
+		return []models.Session{}, nil
+	}
+	return s.sessions.FindActive(ctx, userID)
+}
+
+// SignOutSession signs out one of a user's sessions.
+func (s *Service) SignOutSession(ctx context.Context, userID primitive.ObjectID, sessionID string) error {
+	if s.sessions == nil {
+		return repository.ErrSessionNotFound
+	}
+	session, err := s.sessions.FindByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID || !session.Active(time.Now()) {
+		return repository.ErrSessionNotFound
+	}
+	return s.sessions.Revoke(ctx, session.ID, models.SessionRevokedSignOut)
+}
+
 // ValidateToken validates a JWT token and returns the claims.
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -146,28 +457,88 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	if err := s.checkSession(claims.SessionID); err != nil {
+		return nil, err
+	}
+
 	return claims, nil
 }
 
-// GetUserFromToken retrieves the full user from a token.
+// checkSession returns ErrSessionRevoked if a token's login session was
+// signed out or expired. Tokens without a session are not checked.
+func (s *Service) checkSession(sessionID string) error {
+	if sessionID == "" || s.sessions == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sessionLookupTimeout)
+	defer cancel()
+
+	session, err := s.sessions.FindByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrSessionNotFound) || (err == nil && !session.Active(time.Now())) {
+		return ErrSessionRevoked
+	}
+	return err
+}
+
+// GetUserFromToken retrieves the full user from a token. Tokens of suspended
+// accounts, and accounts being deactivated or already anonymized, are
+// refused.
 func (s *Service) GetUserFromToken(ctx context.Context, tokenString string) (*models.User, error) {
+	user, _, err := s.Authenticate(ctx, tokenString)
+	return user, err
+}
+
+// Authenticate is GetUserFromToken for callers that also need the token's
+// claims, such as its login session.
+func (s *Service) Authenticate(ctx context.Context, tokenString string) (*models.User, *Claims, error) {
 	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+// activeUser loads a user whose token was just validated, refusing
+// accounts that can't sign in.
+func (s *Service) activeUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	switch {
+	case user.Status == models.StatusDeactivated:
+		return nil, ErrInvalidToken
+	case user.Status == models.StatusSuspended:
+		return nil, ErrAccountSuspended
+	case user.Deactivating():
+		return nil, ErrAccountDeactivating
+	}
+	return user, nil
+}
 
-	return s.userRepo.FindByID(ctx, claims.UserID)
+// IssueToken creates a token for a user without a password, for trusted
+// operator tooling that already has database access.
+func (s *Service) IssueToken(user *models.User) (string, error) {
+	return s.generateToken(user, "")
 }
 
-// generateToken creates a JWT token for a user.
-func (s *Service) generateToken(user *models.User) (string, error) {
+// generateToken creates a JWT token for a user, tied to a login session
+// unless sessionID is empty.
+func (s *Service) generateToken(user *models.User, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID: user.ID.Hex(),
-		Email:  user.Email,
-		Name:   user.Name,
-		Role:   user.Role,
+		UserID:    user.ID.Hex(),
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.jwtExpiry)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenExpiry(sessionID))),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -176,6 +547,15 @@ func (s *Service) generateToken(user *models.User) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
+// tokenExpiry returns the lifetime of a token for a login session, or
+// without one if sessionID is empty.
+func (s *Service) tokenExpiry(sessionID string) time.Duration {
+	if sessionID != "" && s.accessExpiry > 0 && s.accessExpiry < s.jwtExpiry {
+		return s.accessExpiry
+	}
+	return s.jwtExpiry
+}
+
 // CreateDefaultAdmin creates the default admin user if none exists.
 func (s *Service) CreateDefaultAdmin(ctx context.Context, email, password, name string) error {
 	exists, err := s.userRepo.ExistsAdmin(ctx)
@@ -186,9 +566,15 @@ func (s *Service) CreateDefaultAdmin(ctx context.Context, email, password, name
 		return nil // Admin already exists
 	}
 
+	_, err = s.CreateAdmin(ctx, email, password, name)
+	return err
+}
+
+// CreateAdmin creates an approved admin account.
+func (s *Service) CreateAdmin(ctx context.Context, email, password, name string) (*models.User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	admin := &models.User{
@@ -199,7 +585,10 @@ func (s *Service) CreateDefaultAdmin(ctx context.Context, email, password, name
 		Status:       models.StatusApproved,
 	}
 
-	return s.userRepo.Create(ctx, admin)
+	if err := s.userRepo.Create(ctx, admin); err != nil {
+		return nil, err
+	}
+	return admin, nil
 }
 
 // ChangePassword changes a user's password.
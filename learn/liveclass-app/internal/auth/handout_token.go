@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// ErrInvalidHandoutToken is returned for handout links that are malformed,
+// expired or signed with another key.
+var ErrInvalidHandoutToken = errors.New("invalid or expired handout link")
+
+// handoutTokenAudience marks handout tokens, on top of their separate key.
+const handoutTokenAudience = "liveclass-handout"
+
+// HandoutClaims let the user a handout link was minted for download one
+// file shared in a live class, without a login token in the URL. A link
+// stops working when the login session it was minted from is signed out.
+type HandoutClaims struct {
+	NoteID    string `json:"note"`
+	UserID    string `json:"uid"`
+	SessionID string `json:"sid,omitempty"` // Login session the link was minted from
+	jwt.RegisteredClaims
+}
+
+// IssueHandoutToken signs a download token for a user and login session
+// to download a handout, valid for ttl.
+func (s *Service) IssueHandoutToken(noteID, userID, sessionID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := &HandoutClaims{
+		NoteID:    noteID,
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{handoutTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.handoutTokenKey())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ValidateHandoutToken checks a handout token's signature, expiry and login
+// session and returns its claims.
+func (s *Service) ValidateHandoutToken(tokenString string) (*HandoutClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &HandoutClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return s.handoutTokenKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(handoutTokenAudience))
+	if err != nil {
+		return nil, ErrInvalidHandoutToken
+	}
+
+	claims, ok := token.Claims.(*HandoutClaims)
+	if !ok || !token.Valid || claims.UserID == "" {
+		return nil, ErrInvalidHandoutToken
+	}
+	if err := s.checkSession(claims.SessionID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// GetUserFromHandoutToken validates a handout token and retrieves the user
+// it was minted for, refusing them as GetUserFromToken does.
+func (s *Service) GetUserFromHandoutToken(ctx context.Context, tokenString string) (*models.User, *HandoutClaims, error) {
+	claims, err := s.ValidateHandoutToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+// handoutTokenKey derives the handout token key from the JWT secret.
+func (s *Service) handoutTokenKey() []byte {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte(handoutTokenAudience))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// ErrInvalidPlaybackToken is returned for playback tokens that are
+// malformed, expired or signed with another key.
+var ErrInvalidPlaybackToken = errors.New("invalid or expired playback token")
+
+// playbackTokenAudience marks playback tokens, on top of their separate key.
+const playbackTokenAudience = "liveclass-playback"
+
+// PlaybackClaims let a player stream one recording for one playback
+// session. Players can't set headers, so the token goes in the stream URL;
+// unlike a login token, one that ends up in a log only streams that
+// recording, for a short while, and stops working when the login session
+// it was minted from is signed out.
+type PlaybackClaims struct {
+	RecordingID string `json:"rec"`
+	UserID      string `json:"uid"`
+	SessionID   string `json:"sid,omitempty"` // Login session the token was minted from
+	jwt.RegisteredClaims
+}
+
+// IssuePlaybackToken signs a playback token for a user to stream a
+// recording, valid for ttl.
+func (s *Service) IssuePlaybackToken(recordingID string, claims *Claims, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	playback := &PlaybackClaims{
+		RecordingID: recordingID,
+		UserID:      claims.UserID,
+		SessionID:   claims.SessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Audience:  jwt.ClaimStrings{playbackTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, playback).SignedString(s.playbackTokenKey())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ValidatePlaybackToken checks a playback token's signature, expiry and
+// login session and returns its claims. Whether it is for the recording
+// requested is up to the caller.
+func (s *Service) ValidatePlaybackToken(tokenString string) (*PlaybackClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &PlaybackClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return s.playbackTokenKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(playbackTokenAudience))
+	if err != nil {
+		return nil, ErrInvalidPlaybackToken
+	}
+
+	claims, ok := token.Claims.(*PlaybackClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidPlaybackToken
+	}
+	if err := s.checkSession(claims.SessionID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// GetUserFromPlaybackToken validates a playback token and retrieves the
+// user it was minted for, refusing them as GetUserFromToken does.
+func (s *Service) GetUserFromPlaybackToken(ctx context.Context, tokenString string) (*models.User, *PlaybackClaims, error) {
+	claims, err := s.ValidatePlaybackToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, claims, nil
+}
+
+// playbackTokenKey derives the playback token key from the JWT secret, so
+// playback tokens never pass as login tokens.
+func (s *Service) playbackTokenKey() []byte {
+	mac := hmac.New(sha256.New, s.jwtSecret)
+	mac.Write([]byte(playbackTokenAudience))
+	return mac.Sum(nil)
+}
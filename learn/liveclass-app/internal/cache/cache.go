@@ -142,69 +142,3 @@ func (c *Cache[T]) deleteExpired() {
 func (c *Cache[T]) Close() {
 	close(c.stopCleanup)
 }
-
-// CacheManager manages multiple caches for different entity types.
-type CacheManager struct {
-	Users     *Cache[interface{}]
-	Batches   *Cache[interface{}]
-	Schedules *Cache[interface{}]
-	Stats     *CacheStats
-}
-
-// CacheStats tracks cache hit/miss statistics.
-type CacheStats struct {
-	mu     sync.RWMutex
-	hits   int64
-	misses int64
-}
-
-// RecordHit increments the hit counter.
-func (s *CacheStats) RecordHit() {
-	s.mu.Lock()
-	s.hits++
-	s.mu.Unlock()
-}
-
-// RecordMiss increments the miss counter.
-func (s *CacheStats) RecordMiss() {
-	s.mu.Lock()
-	s.misses++
-	s.mu.Unlock()
-}
-
-// GetStats returns the current hit/miss counts.
-func (s *CacheStats) GetStats() (hits, misses int64) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.hits, s.misses
-}
-
-// HitRate returns the cache hit rate as a percentage.
-func (s *CacheStats) HitRate() float64 {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	total := s.hits + s.misses
-	if total == 0 {
-		return 0
-	}
-	return float64(s.hits) / float64(total) * 100
-}
-
-// NewCacheManager creates a new cache manager with default settings.
-func NewCacheManager(userTTL, batchTTL, scheduleTTL time.Duration) *CacheManager {
-	cleanupInterval := 1 * time.Minute
-
-	return &CacheManager{
-		Users:     New[interface{}](userTTL, cleanupInterval),
-		Batches:   New[interface{}](batchTTL, cleanupInterval),
-		Schedules: New[interface{}](scheduleTTL, cleanupInterval),
-		Stats:     &CacheStats{},
-	}
-}
-
-// Close closes all caches.
-func (cm *CacheManager) Close() {
-	cm.Users.Close()
-	cm.Batches.Close()
-	cm.Schedules.Close()
-}
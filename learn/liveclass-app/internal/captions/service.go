@@ -0,0 +1,87 @@
+package captions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
+)
+
+// call is a provider request that concurrent callers wait on.
+type call struct {
+	done chan struct{}
+	text string
+	err  error
+}
+
+// Service translates captions, caching results so the same line is sent to
+// the provider once per target language.
+type Service struct {
+	translator Translator
+	cache      *cache.Cache[string]
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// NewService creates a translation service. Translations are cached for cacheTTL.
+func NewService(translator Translator, cacheTTL time.Duration) *Service {
+	return &Service{
+		translator: translator,
+		cache:      cache.New[string](cacheTTL, cacheTTL),
+		inflight:   make(map[string]*call),
+	}
+}
+
+// Provider returns the name of the translation provider.
+func (s *Service) Provider() string {
+	return s.translator.Name()
+}
+
+// Translate returns text translated from source to target. Concurrent requests
+// for the same translation share a single provider call.
+func (s *Service) Translate(ctx context.Context, text, source, target string) (string, error) {
+	if source == target {
+		return text, nil
+	}
+
+	key := cacheKey(text, source, target)
+	if translated, ok := s.cache.Get(key); ok {
+		return translated, nil
+	}
+
+	s.mu.Lock()
+	if c, ok := s.inflight[key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.text, c.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	s.inflight[key] = c
+	s.mu.Unlock()
+
+	c.text, c.err = s.translator.Translate(ctx, text, source, target)
+	if c.err == nil {
+		s.cache.Set(key, c.text)
+	}
+
+	s.mu.Lock()
+	delete(s.inflight, key)
+	s.mu.Unlock()
+	close(c.done)
+
+	return c.text, c.err
+}
+
+// cacheKey identifies a translation without keeping the caption text in the key.
+func cacheKey(text, source, target string) string {
+	sum := sha256.Sum256([]byte(text))
+	return source + ":" + target + ":" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,91 @@
+// Package captions relays live captions and translates them through a
+// pluggable machine-translation provider.
+package captions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Translator translates caption text through an external service.
+type Translator interface {
+	// Name identifies the provider in logs.
+	Name() string
+	// Translate translates text from the source language to the target language.
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// languagePattern matches BCP 47 style codes such as "en", "fil" or "pt-br".
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// NormalizeLanguage lowercases a language code and reports whether it is valid.
+func NormalizeLanguage(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return code, languagePattern.MatchString(code)
+}
+
+// LibreTranslate translates through a LibreTranslate-compatible HTTP API.
+type LibreTranslate struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewLibreTranslate creates a provider for the API at baseURL. apiKey may be
+// empty for self-hosted instances that don't require one.
+func NewLibreTranslate(baseURL, apiKey string) *LibreTranslate {
+	return &LibreTranslate{
+		url:    strings.TrimSuffix(baseURL, "/") + "/translate",
+		apiKey: apiKey,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the provider name.
+func (t *LibreTranslate) Name() string {
+	return "libretranslate"
+}
+
+// Translate calls the /translate endpoint.
+func (t *LibreTranslate) Translate(ctx context.Context, text, source, target string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  source,
+		"target":  target,
+		"format":  "text",
+		"api_key": t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		TranslatedText string `json:"translatedText"`
+		Error          string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translate failed (status %d): %s", resp.StatusCode, result.Error)
+	}
+	return result.TranslatedText, nil
+}
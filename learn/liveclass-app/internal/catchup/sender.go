@@ -0,0 +1,149 @@
+package catchup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Sender sends every student the summary of the week that just ended
+// (Monday to Monday, UTC) as a notification, and by email to students who
+// asked for it. Weeks with nothing to report aren't sent.
+//
+// Summaries are claimed per student and week, so instances sharing the
+// database can all run it.
+type Sender struct {
+	builder     *Builder
+	userRepo    *repository.UserRepository
+	catchUpRepo *repository.CatchUpRepository
+	notifier    *notify.Notifier
+	interval    time.Duration
+	sentWeek    time.Time // Last week every student was handled for on this instance
+}
+
+// NewSender creates a sender checking every interval whether the summaries
+// of the last week are out.
+func NewSender(builder *Builder, userRepo *repository.UserRepository, catchUpRepo *repository.CatchUpRepository, notifier *notify.Notifier, interval time.Duration) *Sender {
+	return &Sender{
+		builder:     builder,
+		userRepo:    userRepo,
+		catchUpRepo: catchUpRepo,
+		notifier:    notifier,
+		interval:    interval,
+	}
+}
+
+// Run sends the last week's summaries not sent yet, immediately and then
+// every interval, until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	s.sendDue(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendDue(ctx)
+		}
+	}
+}
+
+// sendDue sends the summaries of the week that ended last to the students
+// who haven't had theirs.
+func (s *Sender) sendDue(ctx context.Context) {
+	end := models.WeekStart(time.Now())
+	start := end.AddDate(0, 0, -7)
+	if start.Equal(s.sentWeek) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	status := models.StatusApproved
+	role := models.RoleStudent
+	students, err := s.userRepo.FindAll(ctx, &status, &role)
+	if err != nil {
+		log.Printf("[CatchUp] Failed to load students: %v", err)
+		return
+	}
+
+	sent, failed := 0, 0
+	for i := range students {
+		if ctx.Err() != nil {
+			return
+		}
+		ok, err := s.send(ctx, &students[i], start, end)
+		if err != nil {
+			log.Printf("[CatchUp] Failed to send summary to %s: %v", students[i].ID.Hex(), err)
+			failed++
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("[CatchUp] Sent %d summaries for the week of %s", sent, start.Format("2006-01-02"))
+	}
+	// Students that failed are retried on the next pass
+	if failed == 0 {
+		s.sentWeek = start
+	}
+}
+
+// send builds and claims one student's summary, and sends it if there is
+// anything in it. It reports whether it was sent.
+func (s *Sender) send(ctx context.Context, student *models.User, start, end time.Time) (bool, error) {
+	summary, err := s.builder.Build(ctx, student, start, end)
+	if err != nil {
+		return false, err
+	}
+
+	// Empty weeks are claimed too, so they aren't rebuilt every pass
+	if !summary.IsEmpty() {
+		now := time.Now()
+		summary.SentAt = &now
+		summary.Emailed = student.CatchUpEmail
+	}
+	claimed, err := s.catchUpRepo.Claim(ctx, summary)
+	if err != nil || !claimed || summary.SentAt == nil {
+		return false, err
+	}
+
+	s.notifier.Notify(ctx, []models.User{*student}, notify.Message{
+		Category: models.NotificationCatchUp,
+		Title:    fmt.Sprintf("Your week in review: %s", summaryHeadline(summary)),
+		Body:     Text(summary),
+		Link:     "/catch-up",
+		Email:    student.CatchUpEmail,
+	})
+	return true, nil
+}
+
+// summaryHeadline counts what's in a summary, e.g. "2 missed classes, 3 new notes".
+func summaryHeadline(summary *models.CatchUpSummary) string {
+	var parts []string
+	count := func(n int, one, many string) {
+		switch {
+		case n == 1:
+			parts = append(parts, "1 "+one)
+		case n > 1:
+			parts = append(parts, fmt.Sprintf("%d %s", n, many))
+		}
+	}
+	count(len(summary.Missed), "missed class", "missed classes")
+	count(len(summary.NewNotes), "new note", "new notes")
+	count(len(summary.Upcoming), "class coming up", "classes coming up")
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,251 @@
+// Package catchup builds students' weekly catch-up summaries, the classes
+// they missed with links to the recordings, the notes added to their batches
+// and the classes coming up, and sends them out once a week.
+package catchup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// upcomingWindow is how far ahead a summary looks for classes.
+const upcomingWindow = 7 * 24 * time.Hour
+
+// Builder computes catch-up summaries from class attendance, recordings and
+// notes.
+type Builder struct {
+	batchRepo     *repository.BatchRepository
+	scheduleRepo  *repository.ScheduleRepository
+	recordingRepo *repository.RecordingRepository
+	noteRepo      *repository.NoteRepository
+	goalRepo      *repository.GoalRepository
+}
+
+// NewBuilder creates a new Builder.
+func NewBuilder(
+	batchRepo *repository.BatchRepository,
+	scheduleRepo *repository.ScheduleRepository,
+	recordingRepo *repository.RecordingRepository,
+	noteRepo *repository.NoteRepository,
+	goalRepo *repository.GoalRepository,
+) *Builder {
+	return &Builder{
+		batchRepo:     batchRepo,
+		scheduleRepo:  scheduleRepo,
+		recordingRepo: recordingRepo,
+		noteRepo:      noteRepo,
+		goalRepo:      goalRepo,
+	}
+}
+
+// Build returns the student's summary of the period from start to end, with
+// the classes in the seven days after end.
+func (b *Builder) Build(ctx context.Context, student *models.User, start, end time.Time) (*models.CatchUpSummary, error) {
+	summary := &models.CatchUpSummary{
+		UserID:      student.ID,
+		WeekStart:   start,
+		WeekEnd:     end,
+		Missed:      []models.MissedClass{},
+		NewNotes:    []models.CatchUpNote{},
+		Upcoming:    []models.UpcomingClass{},
+		GeneratedAt: time.Now(),
+	}
+
+	batches, err := b.batchRepo.FindByStudent(ctx, student.ID.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load batches: %w", err)
+	}
+	if len(batches) == 0 {
+		return summary, nil
+	}
+
+	batchIDs := make([]string, len(batches))
+	batchObjectIDs := make([]primitive.ObjectID, len(batches))
+	batchNames := make(map[primitive.ObjectID]string, len(batches))
+	for i, batch := range batches {
+		batchIDs[i] = batch.ID.Hex()
+		batchObjectIDs[i] = batch.ID
+		batchNames[batch.ID] = batch.Name
+	}
+	batchName := func(id primitive.ObjectID, snapshot string) string {
+		if name, ok := batchNames[id]; ok {
+			return name
+		}
+		return snapshot
+	}
+
+	if err := b.addMissed(ctx, summary, student, batchIDs, batchName); err != nil {
+		return nil, err
+	}
+
+	notes, err := b.noteRepo.FindByBatches(ctx, batchObjectIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notes: %w", err)
+	}
+	for _, note := range notes {
+		if note.Hidden || note.Unpublished {
+			continue
+		}
+		added := note.CreatedAt
+		if note.PublishedAt != nil {
+			added = *note.PublishedAt
+		}
+		if added.Before(start) || !added.Before(end) {
+			continue
+		}
+		summary.NewNotes = append(summary.NewNotes, models.CatchUpNote{
+			NoteID:      note.ID.Hex(),
+			Title:       note.Title,
+			BatchName:   batchName(note.BatchID, note.BatchName),
+			DownloadURL: "/api/notes/" + note.ID.Hex() + "/download",
+			AddedAt:     added,
+		})
+	}
+	sort.Slice(summary.NewNotes, func(i, j int) bool {
+		return summary.NewNotes[i].AddedAt.Before(summary.NewNotes[j].AddedAt)
+	})
+
+	upcoming, err := b.scheduleRepo.FindByBatches(ctx, batchIDs, end, end.Add(upcomingWindow))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upcoming classes: %w", err)
+	}
+	for _, class := range upcoming {
+		if class.Status == models.ClassStatusCancelled || !class.StartTime.Before(end.Add(upcomingWindow)) {
+			continue
+		}
+		summary.Upcoming = append(summary.Upcoming, models.UpcomingClass{
+			ScheduleID: class.ID.Hex(),
+			Title:      class.Title,
+			BatchName:  batchName(class.BatchID, class.BatchName),
+			StartTime:  class.StartTime,
+			Exam:       class.ExamMode || class.Proctored,
+		})
+	}
+
+	return summary, nil
+}
+
+// addMissed adds the classes of the period that ran without the student,
+// with their recordings and whether the student watched them since.
+func (b *Builder) addMissed(ctx context.Context, summary *models.CatchUpSummary, student *models.User, batchIDs []string, batchName func(primitive.ObjectID, string) string) error {
+	classes, err := b.scheduleRepo.FindByBatches(ctx, batchIDs, summary.WeekStart, summary.WeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to load classes: %w", err)
+	}
+
+	// Only classes that actually ran; a class never started has no one to miss
+	var held []models.ScheduledClass
+	var heldIDs []string
+	for _, class := range classes {
+		if !class.StartTime.Before(summary.WeekEnd) || class.RoomID == "" {
+			continue
+		}
+		if class.EffectiveStatusAt(summary.GeneratedAt) != models.ClassStatusCompleted {
+			continue
+		}
+		held = append(held, class)
+		heldIDs = append(heldIDs, class.ID.Hex())
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	attended, err := b.goalRepo.HadActivity(ctx, student.ID, models.ActivityAttended, heldIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load attendance: %w", err)
+	}
+
+	recordings, err := b.recordingRepo.FindByBatches(ctx, batchIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load recordings: %w", err)
+	}
+	bySchedule := make(map[primitive.ObjectID]models.Recording, len(recordings))
+	var recordingIDs []string
+	for _, rec := range recordings {
+		if rec.Hidden || !rec.AllowsStudent(student.ID) {
+			continue
+		}
+		if _, ok := bySchedule[rec.ScheduleID]; !ok {
+			bySchedule[rec.ScheduleID] = rec
+			recordingIDs = append(recordingIDs, rec.ID.Hex())
+		}
+	}
+
+	watched, err := b.goalRepo.HadActivity(ctx, student.ID, models.ActivityWatched, recordingIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load watched recordings: %w", err)
+	}
+
+	for _, class := range held {
+		if attended[class.ID.Hex()] {
+			continue
+		}
+		missed := models.MissedClass{
+			ScheduleID: class.ID.Hex(),
+			Title:      class.Title,
+			BatchName:  batchName(class.BatchID, class.BatchName),
+			StartTime:  class.StartTime,
+		}
+		if rec, ok := bySchedule[class.ID]; ok {
+			missed.RecordingID = rec.ID.Hex()
+			missed.RecordingURL = "/api/recordings/" + rec.ID.Hex() + "/stream"
+			missed.Watched = watched[rec.ID.Hex()]
+		}
+		summary.Missed = append(summary.Missed, missed)
+	}
+	return nil
+}
+
+// Text renders a summary as plain text for notifications and emails.
+func Text(summary *models.CatchUpSummary) string {
+	var text string
+	line := func(format string, args ...interface{}) {
+		text += fmt.Sprintf(format, args...) + "\n"
+	}
+	when := func(t time.Time) string {
+		return t.UTC().Format("Mon 2 Jan 15:04 MST")
+	}
+
+	if len(summary.Missed) > 0 {
+		line("Classes you missed:")
+		for _, class := range summary.Missed {
+			switch {
+			case class.RecordingURL == "":
+				line("- %s (%s, %s), no recording yet", class.Title, class.BatchName, when(class.StartTime))
+			case class.Watched:
+				line("- %s (%s, %s), recording watched", class.Title, class.BatchName, when(class.StartTime))
+			default:
+				line("- %s (%s, %s), watch the recording: %s", class.Title, class.BatchName, when(class.StartTime), class.RecordingURL)
+			}
+		}
+		line("")
+	}
+	if len(summary.NewNotes) > 0 {
+		line("New notes:")
+		for _, note := range summary.NewNotes {
+			line("- %s (%s): %s", note.Title, note.BatchName, note.DownloadURL)
+		}
+		line("")
+	}
+	if len(summary.Upcoming) > 0 {
+		line("Coming up:")
+		for _, class := range summary.Upcoming {
+			if class.Exam {
+				line("- %s (%s, %s), exam", class.Title, class.BatchName, when(class.StartTime))
+			} else {
+				line("- %s (%s, %s)", class.Title, class.BatchName, when(class.StartTime))
+			}
+		}
+	}
+	if text == "" {
+		return "Nothing to catch up on this week."
+	}
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,181 @@
+package chapters
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Limits of chapter detection
+const (
+	queueSize   = 64
+	backfillMax = 20 // Recordings scanned per backfill pass
+	scanTimeout = 30 * time.Minute
+)
+
+// Policy controls which scene changes become proposed chapters.
+type Policy struct {
+	MinGap      int // Least seconds between chapters
+	MaxChapters int
+}
+
+// Generator proposes chapters for recordings in the background: right after
+// upload, and in periodic passes that pick up recordings uploaded before
+// detection existed or missed while the queue was full.
+//
+// Recordings are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Generator struct {
+	recordingRepo *repository.RecordingRepository
+	detector      *Detector
+	files         *encryption.Encryptor // nil stores files in plaintext
+	policy        Policy
+	interval      time.Duration
+	queue         chan *models.Recording
+}
+
+// NewGenerator creates a generator with a backfill pass every interval (0
+// for none). A nil detector disables it.
+func NewGenerator(recordingRepo *repository.RecordingRepository, detector *Detector, files *encryption.Encryptor, policy Policy, interval time.Duration) *Generator {
+	return &Generator{
+		recordingRepo: recordingRepo,
+		detector:      detector,
+		files:         files,
+		policy:        policy,
+		interval:      interval,
+		queue:         make(chan *models.Recording, queueSize),
+	}
+}
+
+// Enqueue schedules chapter detection for a newly uploaded recording. It
+// never blocks; if the queue is full the next backfill pass picks the
+// recording up.
+func (g *Generator) Enqueue(recording *models.Recording) {
+	if g.detector == nil {
+		return
+	}
+	select {
+	case g.queue <- recording:
+	default:
+		log.Printf("[Chapters] Queue full, %s left for the next pass", recording.ID.Hex())
+	}
+}
+
+// Run scans queued recordings, and any still unscanned immediately and then
+// every interval, until ctx is cancelled.
+func (g *Generator) Run(ctx context.Context) {
+	if g.detector == nil {
+		return
+	}
+
+	var tick <-chan time.Time
+	if g.interval > 0 {
+		g.backfill(ctx)
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case recording := <-g.queue:
+			g.process(ctx, recording)
+		case <-tick:
+			g.backfill(ctx)
+		}
+	}
+}
+
+// backfill scans recordings that haven't been scanned yet.
+func (g *Generator) backfill(ctx context.Context) {
+	findCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	recordings, err := g.recordingRepo.FindWithoutChapterScan(findCtx, backfillMax)
+	cancel()
+	if err != nil {
+		log.Printf("[Chapters] Failed to load unscanned recordings: %v", err)
+		return
+	}
+	for i := range recordings {
+		if ctx.Err() != nil {
+			return
+		}
+		g.process(ctx, &recordings[i])
+	}
+}
+
+// process claims a recording and stores the chapters proposed for it.
+func (g *Generator) process(ctx context.Context, recording *models.Recording) {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	claimed, err := g.recordingRepo.ClaimChapterScan(ctx, recording.ID)
+	if err != nil {
+		log.Printf("[Chapters] Failed to claim %s: %v", recording.ID.Hex(), err)
+		return
+	}
+	if !claimed {
+		return // Handled by another instance
+	}
+
+	// The recording stays claimed on failure, so a broken file isn't
+	// rescanned every pass
+	started := time.Now()
+	scenes, err := g.scan(ctx, recording)
+	if err != nil {
+		log.Printf("[Chapters] No chapters for %s (%s): %v", recording.Title, recording.ID.Hex(), err)
+		return
+	}
+
+	proposed := Propose(scenes, g.policy.MinGap, g.policy.MaxChapters)
+	if len(proposed) < 2 {
+		log.Printf("[Chapters] No scene changes in %s", recording.Title)
+		return
+	}
+	if err := g.recordingRepo.SetProposedChapters(ctx, recording.ID, proposed); err != nil {
+		log.Printf("[Chapters] Failed to save chapters of %s: %v", recording.ID.Hex(), err)
+		return
+	}
+	log.Printf("[Chapters] Proposed %d chapter(s) for %s in %v", len(proposed), recording.Title, time.Since(started).Round(time.Second))
+}
+
+// scan detects the scene changes of a recording. ffmpeg can't read
+// encrypted files, and recordings whose index is at the end can't be read
+// from a pipe, so encrypted recordings are decrypted to a temporary file.
+func (g *Generator) scan(ctx context.Context, recording *models.Recording) ([]Scene, error) {
+	_, encrypted, err := encryption.KeyID(recording.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return g.detector.Detect(ctx, recording.FilePath)
+	}
+
+	src, err := g.files.Open(ctx, recording.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "chapters-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.detector.Detect(ctx, tmp.Name())
+}
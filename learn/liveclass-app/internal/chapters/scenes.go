@@ -0,0 +1,125 @@
+// Package chapters proposes chapter markers for recordings from major visual
+// changes, such as a presenter moving to the next slide, for the presenter
+// to accept or edit.
+package chapters
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// Scene is a major visual change in a recording.
+type Scene struct {
+	At    float64 // Seconds into the recording
+	Score float64 // How much the picture changed, 0 to 1
+}
+
+// Detector finds scene changes with ffmpeg's scene score.
+type Detector struct {
+	ffmpeg    string
+	threshold float64
+}
+
+// NewDetector creates a detector running the ffmpeg binary at path. Frames
+// that differ from the one before by more than threshold (0 to 1) are
+// scene changes.
+func NewDetector(path string, threshold float64) *Detector {
+	return &Detector{ffmpeg: path, threshold: threshold}
+}
+
+// Available reports whether the ffmpeg binary can be found.
+func (d *Detector) Available() bool {
+	_, err := exec.LookPath(d.ffmpeg)
+	return err == nil
+}
+
+// Detect returns the scene changes in the plaintext recording at path. Only
+// one frame a second is compared, which is plenty for slides and keeps long
+// recordings cheap to scan.
+func (d *Detector) Detect(ctx context.Context, path string) ([]Scene, error) {
+	filter := fmt.Sprintf("fps=1,scale=320:-2,select='gt(scene,%g)',metadata=print:file=-", d.threshold)
+	cmd := exec.CommandContext(ctx, d.ffmpeg,
+		"-hide_banner", "-nostats", "-loglevel", "error",
+		"-i", path,
+		"-an", "-sn", "-dn",
+		"-vf", filter,
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseScenes(out), nil
+}
+
+// parseScenes reads the frame metadata printed by ffmpeg's metadata filter:
+// a "frame:N pts:N pts_time:T" line followed by its lavfi.scene_score.
+func parseScenes(out []byte) []Scene {
+	var scenes []Scene
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "pts_time:"); i >= 0 {
+			if fields := strings.Fields(line[i+len("pts_time:"):]); len(fields) > 0 {
+				if at, err := strconv.ParseFloat(fields[0], 64); err == nil {
+					scenes = append(scenes, Scene{At: at})
+				}
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "lavfi.scene_score="); ok && len(scenes) > 0 {
+			scenes[len(scenes)-1].Score, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	return scenes
+}
+
+// Propose turns scene changes into at most maxChapters chapters, at least
+// minGap seconds apart. The biggest changes win where they crowd each other;
+// the first chapter always starts at the beginning.
+func Propose(scenes []Scene, minGap, maxChapters int) []models.Chapter {
+	ranked := append([]Scene(nil), scenes...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+
+	starts := []int{0}
+	for _, scene := range ranked {
+		if len(starts) >= maxChapters {
+			break
+		}
+		at := int(scene.At)
+		crowded := false
+		for _, start := range starts {
+			if abs(at-start) < minGap {
+				crowded = true
+				break
+			}
+		}
+		if !crowded {
+			starts = append(starts, at)
+		}
+	}
+	sort.Ints(starts)
+
+	chapters := make([]models.Chapter, len(starts))
+	for i, start := range starts {
+		chapters[i] = models.Chapter{Start: start, Title: fmt.Sprintf("Chapter %d", i+1)}
+	}
+	return chapters
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
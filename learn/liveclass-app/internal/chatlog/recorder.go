@@ -0,0 +1,181 @@
+// Package chatlog keeps the chat history of live rooms, so students who
+// join late or watch a recording can read what was discussed.
+package chatlog
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Write batching
+const (
+	flushInterval = time.Second
+	batchSize     = 200
+	queueSize     = 10000
+)
+
+// recordedMessages counts chat messages by outcome (stored, dropped).
+var recordedMessages = metrics.NewCounterVec(
+	"liveclass_chat_messages_total",
+	"Chat messages kept in the history by outcome (stored, dropped).",
+	"result",
+)
+
+// change is a message posted, or the deletion of one.
+type change struct {
+	posted    *models.ChatMessage
+	deleted   string // Message ID
+	deletedBy string
+}
+
+// Recorder writes chat messages to the database in the background, with
+// the class of their room.
+type Recorder struct {
+	repo         *repository.ChatMessageRepository
+	scheduleRepo *repository.ScheduleRepository
+	queue        chan change
+	done         chan struct{}
+}
+
+// NewRecorder creates a recorder writing to repo.
+func NewRecorder(repo *repository.ChatMessageRepository, scheduleRepo *repository.ScheduleRepository) *Recorder {
+	return &Recorder{
+		repo:         repo,
+		scheduleRepo: scheduleRepo,
+		queue:        make(chan change, queueSize),
+		done:         make(chan struct{}),
+	}
+}
+
+// Posted queues a message posted in a room. It never blocks; messages are
+// dropped when the queue is full. It is a room.Hooks chat message handler.
+func (r *Recorder) Posted(post room.ChatPost) {
+	r.enqueue(change{posted: &models.ChatMessage{
+		ID:            primitive.NewObjectID(), // Orders messages sent in the same instant
+		MessageID:     post.MessageID,
+		RoomID:        post.RoomID,
+		ParticipantID: post.ParticipantID,
+		UserID:        post.UserID,
+		SenderName:    post.Name,
+		Body:          post.Text,
+		Private:       post.Private,
+		SentAt:        post.At,
+	}})
+}
+
+// Deleted queues the deletion of a message, which is applied after the
+// messages queued before it.
+func (r *Recorder) Deleted(messageID, by string) {
+	r.enqueue(change{deleted: messageID, deletedBy: by})
+}
+
+func (r *Recorder) enqueue(c change) {
+	select {
+	case r.queue <- c:
+	default:
+		recordedMessages.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Run writes queued changes until ctx is cancelled, then writes what is left.
+func (r *Recorder) Run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]change, 0, batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			r.drain(batch)
+			return
+		case c := <-r.queue:
+			batch = append(batch, c)
+			if len(batch) >= batchSize {
+				r.write(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.write(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// Wait blocks until Run has written the remaining changes after
+// cancellation, or ctx expires.
+func (r *Recorder) Wait(ctx context.Context) {
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
+
+// drain writes the pending batch and the queued changes.
+func (r *Recorder) drain(batch []change) {
+	for {
+		select {
+		case c := <-r.queue:
+			batch = append(batch, c)
+		default:
+			r.write(batch)
+			return
+		}
+	}
+}
+
+// write stores a batch: the posted messages first, then the deletions, so
+// a message deleted soon after it was posted is still deleted. Failures are
+// dropped rather than retried, so an unavailable database can't back up
+// the chat.
+func (r *Recorder) write(batch []change) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var posted []models.ChatMessage
+	schedules := make(map[string]primitive.ObjectID) // By room
+	for _, c := range batch {
+		if c.posted == nil {
+			continue
+		}
+		msg := *c.posted
+		scheduleID, ok := schedules[msg.RoomID]
+		if !ok {
+			// Rooms outside scheduled classes keep their messages by room only
+			if schedule, err := r.scheduleRepo.FindByRoomID(ctx, msg.RoomID); err == nil {
+				scheduleID = schedule.ID
+			}
+			schedules[msg.RoomID] = scheduleID
+		}
+		msg.ScheduleID = scheduleID
+		posted = append(posted, msg)
+	}
+
+	if err := r.repo.Append(ctx, posted); err != nil {
+		log.Printf("[ChatLog] Failed to store %d chat messages: %v", len(posted), err)
+		recordedMessages.WithLabelValues("dropped").Add(uint64(len(posted)))
+	} else {
+		recordedMessages.WithLabelValues("stored").Add(uint64(len(posted)))
+	}
+
+	for _, c := range batch {
+		if c.posted != nil {
+			continue
+		}
+		if err := r.repo.MarkDeleted(ctx, c.deleted, c.deletedBy); err != nil {
+			log.Printf("[ChatLog] Failed to delete chat message %s: %v", c.deleted, err)
+		}
+	}
+}
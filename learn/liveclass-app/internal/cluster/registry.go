@@ -0,0 +1,251 @@
+// Package cluster tracks the instances of a multi-instance deployment through
+// heartbeats in Redis and relays operator commands, such as draining, to
+// the instance they target.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"runtime"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/pubsub"
+)
+
+const (
+	keyPrefix      = "instance:"        // Heartbeat key of each instance
+	commandChannel = "cluster:commands" // Commands targeted at one instance
+	missedBeats    = 3                  // Heartbeats missed before an instance drops out
+)
+
+// Commands sent to instances
+const (
+	CommandDrain  = "drain"
+	CommandResume = "resume"
+)
+
+// ErrUnknownInstance is returned for commands to instances that aren't live.
+var ErrUnknownInstance = errors.New("unknown instance")
+
+// Status is an instance as it last announced itself.
+type Status struct {
+	ID             string    `json:"id"`
+	Version        string    `json:"version"`
+	StartedAt      time.Time `json:"startedAt"`
+	HeartbeatAt    time.Time `json:"heartbeatAt"`
+	Draining       bool      `json:"draining"`
+	Rooms          int       `json:"rooms"`
+	Participants   int       `json:"participants"`
+	CPUUtilization float64   `json:"cpuUtilization"` // 0-1, from the last CPU pressure sample
+	UnderPressure  bool      `json:"underPressure"`
+	Goroutines     int       `json:"goroutines"`
+	Self           bool      `json:"self,omitempty"` // The instance that answered
+}
+
+// Load is what an instance is serving and how busy it is.
+type Load struct {
+	Rooms          int
+	Participants   int
+	CPUUtilization float64
+	UnderPressure  bool
+}
+
+// Registry announces this instance to the cluster, lists the live
+// instances and applies commands targeted at this one. Without Redis the
+// cluster is just this instance.
+type Registry struct {
+	ps        *pubsub.RedisPubSub // nil in single-instance mode
+	id        string
+	version   string
+	startedAt time.Time
+	interval  time.Duration
+	load      func() Load
+	onDrain   func(draining bool)
+	draining  atomic.Bool
+}
+
+// NewRegistry creates a registry for the instance id, sending a heartbeat
+// every interval. load reports the instance's load for each heartbeat, and
+// onDrain is called when the instance starts or stops draining.
+func NewRegistry(ps *pubsub.RedisPubSub, id, version string, interval time.Duration, load func() Load, onDrain func(draining bool)) *Registry {
+	r := &Registry{
+		ps:        ps,
+		id:        id,
+		version:   version,
+		startedAt: time.Now(),
+		interval:  interval,
+		load:      load,
+		onDrain:   onDrain,
+	}
+	if ps != nil {
+		ps.Subscribe(commandChannel, func(msg *pubsub.Message) {
+			if msg.Target == r.id {
+				r.apply(msg.Type)
+			}
+		})
+	}
+	return r
+}
+
+// Run sends heartbeats until ctx is cancelled, then removes this instance
+// from the cluster.
+func (r *Registry) Run(ctx context.Context) {
+	if r.ps == nil || r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.beat(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			leaveCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			r.ps.GetClient().Del(leaveCtx, keyPrefix+r.id)
+			cancel()
+			return
+		case <-ticker.C:
+			r.beat(ctx)
+		}
+	}
+}
+
+// Self returns the current status of this instance.
+func (r *Registry) Self() Status {
+	load := r.load()
+	return Status{
+		ID:             r.id,
+		Version:        r.version,
+		StartedAt:      r.startedAt,
+		HeartbeatAt:    time.Now(),
+		Draining:       r.draining.Load(),
+		Rooms:          load.Rooms,
+		Participants:   load.Participants,
+		CPUUtilization: load.CPUUtilization,
+		UnderPressure:  load.UnderPressure,
+		Goroutines:     runtime.NumGoroutine(),
+		Self:           true,
+	}
+}
+
+// Draining reports whether this instance is draining.
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Instances returns the live instances by ID, this one with its current
+// status and the others as of their last heartbeat.
+func (r *Registry) Instances(ctx context.Context) ([]Status, error) {
+	instances := []Status{r.Self()}
+	if r.ps == nil {
+		return instances, nil
+	}
+
+	client := r.ps.GetClient()
+	var keys []string
+	iter := client.Scan(ctx, 0, keyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if iter.Val() != keyPrefix+r.id {
+			keys = append(keys, iter.Val())
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(keys) > 0 {
+		values, err := client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue // Expired since the scan
+			}
+			var status Status
+			if err := json.Unmarshal([]byte(data), &status); err != nil {
+				continue
+			}
+			instances = append(instances, status)
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
+
+// Drain starts or stops draining an instance. Commands for other instances
+// go through Redis; the instance applies them when it gets them.
+func (r *Registry) Drain(ctx context.Context, instanceID string, draining bool) error {
+	command := CommandResume
+	if draining {
+		command = CommandDrain
+	}
+
+	if instanceID == r.id {
+		r.apply(command)
+		return nil
+	}
+	if r.ps == nil {
+		return ErrUnknownInstance
+	}
+
+	exists, err := r.ps.GetClient().Exists(ctx, keyPrefix+instanceID).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrUnknownInstance
+	}
+	return r.ps.Publish(ctx, commandChannel, &pubsub.Message{Type: command, Target: instanceID})
+}
+
+// apply runs a command targeted at this instance and announces the result
+// right away, so the cluster status reflects it.
+func (r *Registry) apply(command string) {
+	var draining bool
+	switch command {
+	case CommandDrain:
+		draining = true
+	case CommandResume:
+		draining = false
+	default:
+		log.Printf("[Cluster] Unknown command: %s", command)
+		return
+	}
+
+	if r.draining.Swap(draining) == draining {
+		return
+	}
+	if draining {
+		log.Printf("🚰 Draining: no new rooms will be opened on this instance")
+	} else {
+		log.Printf("✅ Drain cancelled: this instance accepts new rooms again")
+	}
+	r.onDrain(draining)
+
+	if r.ps != nil && r.interval > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		r.beat(ctx)
+	}
+}
+
+// beat stores this instance's status, expiring after a few missed beats.
+func (r *Registry) beat(ctx context.Context) {
+	status := r.Self()
+	status.Self = false
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+	if err := r.ps.GetClient().Set(ctx, keyPrefix+r.id, data, missedBeats*r.interval).Err(); err != nil {
+		log.Printf("[Cluster] Heartbeat failed: %v", err)
+	}
+}
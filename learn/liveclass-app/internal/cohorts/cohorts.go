@@ -0,0 +1,313 @@
+// Package cohorts compares the batches that ran the same class template, so
+// underperforming cohorts stand out.
+//
+// A background job rolls each finished class up into a models.ClassRollup
+// (who was enrolled, who joined live and for how long, who finished the
+// recording). Reports are computed from the rollups alone.
+package cohorts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/timeline"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UnderperformingGap is how many points below the average cohort's
+// attendance rate a cohort is flagged.
+const UnderperformingGap = 10.0
+
+// Room events read per class; more than any class produces.
+const maxClassEvents = 50000
+
+// Roller keeps the rollups of finished classes up to date.
+type Roller struct {
+	scheduleRepo  *repository.ScheduleRepository
+	batchRepo     *repository.BatchRepository
+	goalRepo      *repository.GoalRepository
+	eventRepo     *repository.RoomEventRepository
+	recordingRepo *repository.RecordingRepository
+	watchRepo     *repository.WatchProgressRepository
+	rollupRepo    *repository.ClassRollupRepository
+	interval      time.Duration
+	window        time.Duration
+}
+
+// NewRoller creates a roller running every interval. Classes that ended
+// within window are rolled up again on each run, as their recordings are
+// still being watched; older ones keep their last rollup.
+func NewRoller(
+	scheduleRepo *repository.ScheduleRepository,
+	batchRepo *repository.BatchRepository,
+	goalRepo *repository.GoalRepository,
+	eventRepo *repository.RoomEventRepository,
+	recordingRepo *repository.RecordingRepository,
+	watchRepo *repository.WatchProgressRepository,
+	rollupRepo *repository.ClassRollupRepository,
+	interval, window time.Duration,
+) *Roller {
+	return &Roller{
+		scheduleRepo:  scheduleRepo,
+		batchRepo:     batchRepo,
+		goalRepo:      goalRepo,
+		eventRepo:     eventRepo,
+		recordingRepo: recordingRepo,
+		watchRepo:     watchRepo,
+		rollupRepo:    rollupRepo,
+		interval:      interval,
+		window:        window,
+	}
+}
+
+// Run rolls up classes immediately, backfilling older classes without a
+// rollup, then every interval until ctx is cancelled.
+func (r *Roller) Run(ctx context.Context) {
+	r.rollUp(ctx, true)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.rollUp(ctx, false)
+		}
+	}
+}
+
+// rollUp rolls up the classes that ended within the window and, when
+// backfilling, older ones that have no rollup yet.
+func (r *Roller) rollUp(ctx context.Context, backfill bool) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	classes, err := r.scheduleRepo.FindEndedFromTemplates(ctx, now.Add(-r.window), now)
+	if err != nil {
+		log.Printf("[Cohorts] Failed to load recent classes: %v", err)
+		return
+	}
+
+	if backfill {
+		older, err := r.scheduleRepo.FindEndedFromTemplates(ctx, time.Time{}, now.Add(-r.window))
+		if err != nil {
+			log.Printf("[Cohorts] Failed to load older classes: %v", err)
+			return
+		}
+		ids := make([]primitive.ObjectID, len(older))
+		for i := range older {
+			ids[i] = older[i].ID
+		}
+		done, err := r.rollupRepo.RolledUp(ctx, ids)
+		if err != nil {
+			log.Printf("[Cohorts] Failed to check rollups: %v", err)
+			return
+		}
+		for _, class := range older {
+			if !done[class.ID] {
+				classes = append(classes, class)
+			}
+		}
+	}
+
+	rolled := 0
+	for i := range classes {
+		if err := r.rollUpClass(ctx, &classes[i]); err != nil {
+			log.Printf("[Cohorts] Failed to roll up class %s: %v", classes[i].ID.Hex(), err)
+			continue
+		}
+		rolled++
+	}
+	if rolled > 0 {
+		log.Printf("[Cohorts] Rolled up %d classes", rolled)
+	}
+}
+
+// rollUpClass aggregates one class. Enrollment is the batch's at the time
+// of the rollup.
+func (r *Roller) rollUpClass(ctx context.Context, class *models.ScheduledClass) error {
+	batch, err := r.batchRepo.FindByID(ctx, class.BatchID.Hex())
+	if errors.Is(err, repository.ErrBatchNotFound) {
+		return nil // Deleted batches have no cohort to compare
+	}
+	if err != nil {
+		return err
+	}
+	enrolled := make(map[primitive.ObjectID]bool, len(batch.StudentIDs))
+	for _, id := range batch.StudentIDs {
+		enrolled[id] = true
+	}
+
+	rollup := &models.ClassRollup{
+		ScheduleID:  class.ID,
+		TemplateID:  class.TemplateID,
+		BatchID:     class.BatchID,
+		StartTime:   class.StartTime,
+		StudentIDs:  batch.StudentIDs,
+		AttendeeIDs: []primitive.ObjectID{},
+		ComputedAt:  time.Now(),
+	}
+	if rollup.StudentIDs == nil {
+		rollup.StudentIDs = []primitive.ObjectID{}
+	}
+
+	attendees, err := r.goalRepo.Participants(ctx, models.ActivityAttended, class.ID.Hex())
+	if err != nil {
+		return err
+	}
+	for _, id := range attendees {
+		if enrolled[id] {
+			rollup.AttendeeIDs = append(rollup.AttendeeIDs, id)
+		}
+	}
+
+	if class.RoomID != "" {
+		from := class.StartTime.Add(-models.LobbyWindow)
+		events, err := r.eventRepo.FindByRoom(ctx, strings.ToUpper(class.RoomID), from, class.EndTime.Add(6*time.Hour), maxClassEvents)
+		if err != nil {
+			return err
+		}
+		seconds := timeline.ViewerSeconds(events)
+		for _, id := range rollup.AttendeeIDs {
+			rollup.LiveMinutes += seconds[id.Hex()] / 60
+		}
+		for _, session := range timeline.Sessions(events) {
+			if session.PeakViewers > rollup.PeakViewers {
+				rollup.PeakViewers = session.PeakViewers
+			}
+		}
+	}
+
+	recording, err := r.recordingRepo.FindBySchedule(ctx, class.ID.Hex())
+	if err != nil && !errors.Is(err, repository.ErrRecordingNotFound) {
+		return err
+	}
+	if recording != nil {
+		rollup.HasRecording = true
+		progress, err := r.watchRepo.FindByRecording(ctx, recording.ID)
+		if err != nil {
+			return err
+		}
+		for _, p := range progress {
+			if p.Completed && enrolled[p.UserID] {
+				rollup.RecordingCompletions++
+			}
+		}
+	}
+
+	return r.rollupRepo.Save(ctx, rollup)
+}
+
+// Compare computes the stats of each batch in rollups, ordered by their
+// first class, and the average cohort's attendance rate. Batch names are
+// left for the caller.
+func Compare(rollups []models.ClassRollup) ([]models.CohortStats, float64) {
+	type tally struct {
+		stats       models.CohortStats
+		seats       int
+		attended    int
+		minutes     float64
+		peakTotal   int
+		recSeats    int
+		completions int
+		enrolledIn  map[primitive.ObjectID]int
+		attendedIn  map[primitive.ObjectID]int
+	}
+
+	byBatch := make(map[primitive.ObjectID]*tally)
+	var order []*tally
+	for _, rollup := range rollups {
+		t, ok := byBatch[rollup.BatchID]
+		if !ok {
+			t = &tally{
+				stats:      models.CohortStats{BatchID: rollup.BatchID.Hex(), FirstClass: rollup.StartTime},
+				enrolledIn: make(map[primitive.ObjectID]int),
+				attendedIn: make(map[primitive.ObjectID]int),
+			}
+			byBatch[rollup.BatchID] = t
+			order = append(order, t)
+		}
+
+		t.stats.Classes++
+		if rollup.StartTime.Before(t.stats.FirstClass) {
+			t.stats.FirstClass = rollup.StartTime
+		}
+		if rollup.StartTime.After(t.stats.LastClass) {
+			t.stats.LastClass = rollup.StartTime
+		}
+		t.seats += len(rollup.StudentIDs)
+		t.attended += len(rollup.AttendeeIDs)
+		t.minutes += rollup.LiveMinutes
+		t.peakTotal += rollup.PeakViewers
+		if rollup.HasRecording {
+			t.recSeats += len(rollup.StudentIDs)
+			t.completions += rollup.RecordingCompletions
+		}
+		for _, id := range rollup.StudentIDs {
+			t.enrolledIn[id]++
+		}
+		for _, id := range rollup.AttendeeIDs {
+			t.attendedIn[id]++
+		}
+	}
+
+	cohorts := make([]models.CohortStats, 0, len(order))
+	var rateTotal float64
+	for _, t := range order {
+		s := t.stats
+		s.Students = len(t.enrolledIn)
+		s.AttendanceRate = percent(t.attended, t.seats)
+		s.RecordingCompletionRate = percent(t.completions, t.recSeats)
+		s.AveragePeakViewers = round1(float64(t.peakTotal) / float64(s.Classes))
+		if t.attended > 0 {
+			s.MinutesPerAttendee = round1(t.minutes / float64(t.attended))
+		}
+		for id, classes := range t.enrolledIn {
+			share := float64(t.attendedIn[id]) / float64(classes)
+			bucket := int(share * 4)
+			if bucket > 3 {
+				bucket = 3
+			}
+			s.AttendanceDistribution[bucket]++
+		}
+		rateTotal += s.AttendanceRate
+		cohorts = append(cohorts, s)
+	}
+
+	var average float64
+	if len(cohorts) > 0 {
+		average = round1(rateTotal / float64(len(cohorts)))
+	}
+	for i := range cohorts {
+		cohorts[i].AttendanceDelta = round1(cohorts[i].AttendanceRate - average)
+		cohorts[i].Underperforming = len(cohorts) > 1 && cohorts[i].AttendanceDelta <= -UnderperformingGap
+	}
+
+	sort.SliceStable(cohorts, func(i, j int) bool {
+		return cohorts[i].FirstClass.Before(cohorts[j].FirstClass)
+	})
+	return cohorts, average
+}
+
+// percent returns part of whole as a percentage with one decimal.
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return round1(float64(part) * 100 / float64(whole))
+}
+
+// round1 rounds to one decimal.
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
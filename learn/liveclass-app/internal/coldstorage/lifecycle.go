@@ -0,0 +1,274 @@
+package coldstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/lock"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/variants"
+)
+
+const (
+	// archiveBatchSize bounds how many recordings one pass archives.
+	archiveBatchSize = 20
+	// restorePollInterval is how often pending restores are checked.
+	restorePollInterval = 5 * time.Minute
+	// recordingLockTTL bounds how long an instance may spend moving one
+	// recording before another may take it over.
+	recordingLockTTL = 30 * time.Minute
+)
+
+// ErrNotArchived is returned when restoring a recording that isn't archived.
+var ErrNotArchived = errors.New("recording is not archived")
+
+// Lifecycle archives recordings older than a cutoff to a cold tier, restores
+// them on request and notifies the requesting users when they are playable.
+//
+// Recordings are claimed with conditional status updates, so instances
+// sharing the database and storage path can all run it. Moving a recording
+// is also done under a lock on it, so two instances don't upload it twice or
+// write the same file while restoring it.
+type Lifecycle struct {
+	recordingRepo *repository.RecordingRepository
+	userRepo      *repository.UserRepository
+	notifier      *notify.Notifier
+	locks         *lock.Locker
+	tier          Tier
+	archiveAfter  time.Duration
+	interval      time.Duration
+	wake          chan struct{}
+}
+
+// NewLifecycle creates a lifecycle archiving recordings recorded more than
+// archiveAfter ago, checking every interval.
+func NewLifecycle(
+	recordingRepo *repository.RecordingRepository,
+	userRepo *repository.UserRepository,
+	notifier *notify.Notifier,
+	locks *lock.Locker,
+	tier Tier,
+	archiveAfter time.Duration,
+	interval time.Duration,
+) *Lifecycle {
+	return &Lifecycle{
+		recordingRepo: recordingRepo,
+		userRepo:      userRepo,
+		notifier:      notifier,
+		locks:         locks,
+		tier:          tier,
+		archiveAfter:  archiveAfter,
+		interval:      interval,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Run archives and restores until ctx is cancelled.
+func (l *Lifecycle) Run(ctx context.Context) {
+	l.archive(ctx)
+	l.restore(ctx)
+
+	archiveTicker := time.NewTicker(l.interval)
+	defer archiveTicker.Stop()
+	restoreTicker := time.NewTicker(min(l.interval, restorePollInterval))
+	defer restoreTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-archiveTicker.C:
+			l.archive(ctx)
+		case <-restoreTicker.C:
+			l.restore(ctx)
+		case <-l.wake:
+			l.restore(ctx)
+		}
+	}
+}
+
+// RequestRestore starts restoring an archived recording for user and returns
+// it with its restore ETA. Requests for a recording already being restored
+// keep the original ETA and add user to those notified.
+func (l *Lifecycle) RequestRestore(ctx context.Context, recording *models.Recording, user *models.User) (*models.Recording, error) {
+	if l == nil || !recording.IsArchived() {
+		return nil, ErrNotArchived
+	}
+
+	var eta time.Time
+	if recording.Status == models.RecordingStatusArchived {
+		wait, err := l.tier.Restore(ctx, recording.ArchiveKey)
+		if err != nil {
+			return nil, fmt.Errorf("start restore: %w", err)
+		}
+		// Copying back and the polling delay come on top of the tier's estimate
+		eta = time.Now().Add(wait + restorePollInterval)
+	}
+
+	updated, err := l.recordingRepo.RequestRestore(ctx, recording.ID, eta, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[ColdStorage] Restore of recording %s requested by %s, ETA %s", recording.ID.Hex(), user.Name, updated.RestoreETA)
+
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	return updated, nil
+}
+
+// Delete removes the archived copy of a recording, if any.
+func (l *Lifecycle) Delete(ctx context.Context, recording *models.Recording) {
+	if l == nil || recording.ArchiveKey == "" {
+		return
+	}
+	if err := l.tier.Delete(ctx, recording.ArchiveKey); err != nil {
+		log.Printf("[ColdStorage] Failed to delete archived copy of recording %s: %v", recording.ID.Hex(), err)
+	}
+}
+
+// archive moves eligible recordings to the cold tier, oldest first.
+func (l *Lifecycle) archive(ctx context.Context) {
+	recordings, err := l.recordingRepo.FindArchivable(ctx, time.Now().Add(-l.archiveAfter), archiveBatchSize)
+	if err != nil {
+		log.Printf("[ColdStorage] Failed to find recordings to archive: %v", err)
+		return
+	}
+
+	for i := range recordings {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := l.withLock(ctx, &recordings[i], l.archiveOne); err != nil {
+			log.Printf("[ColdStorage] Failed to archive recording %s: %v", recordings[i].ID.Hex(), err)
+		}
+	}
+}
+
+// withLock runs fn on a recording under its lock. Recordings another
+// instance is moving are skipped.
+func (l *Lifecycle) withLock(ctx context.Context, recording *models.Recording, fn func(context.Context, *models.Recording) error) error {
+	held, err := l.locks.TryAcquire(ctx, "recording:"+recording.ID.Hex(), recordingLockTTL)
+	if errors.Is(err, lock.ErrHeld) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lock: %w", err)
+	}
+	defer held.Release(context.WithoutCancel(ctx))
+	return fn(ctx, recording)
+}
+
+// archiveOne uploads a recording and removes the local file once the
+// recording is marked archived.
+func (l *Lifecycle) archiveOne(ctx context.Context, recording *models.Recording) error {
+	file, err := os.Open(recording.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	key := path.Join("recordings", recording.FileName)
+	if err := l.tier.Put(ctx, key, file, stat.Size()); err != nil {
+		return fmt.Errorf("upload to %s: %w", l.tier.Name(), err)
+	}
+
+	archived, err := l.recordingRepo.SetArchived(ctx, recording.ID, key)
+	if err != nil || !archived {
+		return err // Another instance archived it, or it changed meanwhile
+	}
+
+	file.Close()
+	if err := os.Remove(recording.FilePath); err != nil {
+		log.Printf("[ColdStorage] Archived recording %s but failed to remove %s: %v", recording.ID.Hex(), recording.FilePath, err)
+	}
+	// Variants are made again from the restored file
+	variants.Remove(recording.Variants)
+	log.Printf("[ColdStorage] Archived recording %s (%d bytes) to %s", recording.ID.Hex(), stat.Size(), l.tier.Name())
+	return nil
+}
+
+// restore copies back recordings whose restore has completed in the tier.
+func (l *Lifecycle) restore(ctx context.Context) {
+	recordings, err := l.recordingRepo.FindByStatus(ctx, models.RecordingStatusRestoring)
+	if err != nil {
+		log.Printf("[ColdStorage] Failed to find pending restores: %v", err)
+		return
+	}
+
+	for i := range recordings {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := l.withLock(ctx, &recordings[i], l.restoreOne); err != nil {
+			log.Printf("[ColdStorage] Failed to restore recording %s: %v", recordings[i].ID.Hex(), err)
+		}
+	}
+}
+
+// restoreOne copies a recording back to local disk once the tier has it
+// readable, then notifies the users who asked for it.
+func (l *Lifecycle) restoreOne(ctx context.Context, recording *models.Recording) error {
+	ready, err := l.tier.Ready(ctx, recording.ArchiveKey)
+	if err != nil || !ready {
+		return err
+	}
+
+	src, err := l.tier.Get(ctx, recording.ArchiveKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := recording.FilePath + ".restoring"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, recording.FilePath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	restored, err := l.recordingRepo.SetRestored(ctx, recording.ID)
+	if err != nil || !restored {
+		return err
+	}
+	log.Printf("[ColdStorage] Restored recording %s", recording.ID.Hex())
+
+	var users []models.User
+	for _, id := range recording.RestoreRequestedBy {
+		if user, err := l.userRepo.FindByID(ctx, id.Hex()); err == nil {
+			users = append(users, *user)
+		}
+	}
+	l.notifier.Notify(ctx, users, notify.Message{
+		Category: models.NotificationRestored,
+		Title:    "Recording available",
+		Body:     fmt.Sprintf("%q has been restored and can be watched again.", recording.Title),
+	})
+	return nil
+}
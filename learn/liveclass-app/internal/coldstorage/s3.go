@@ -0,0 +1,302 @@
+package coldstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// restoreTimes are the documented upper bounds for restores from archival S3
+// storage classes, by class and retrieval tier.
+var restoreTimes = map[string]map[string]time.Duration{
+	"GLACIER": {
+		"Expedited": 5 * time.Minute,
+		"Standard":  5 * time.Hour,
+		"Bulk":      12 * time.Hour,
+	},
+	"DEEP_ARCHIVE": {
+		"Standard": 12 * time.Hour,
+		"Bulk":     48 * time.Hour,
+	},
+}
+
+// S3 is a tier in an S3-compatible bucket, using a storage class such as
+// STANDARD_IA, GLACIER_IR, GLACIER or DEEP_ARCHIVE. Objects in GLACIER and
+// DEEP_ARCHIVE must be restored before they can be read; other classes are
+// readable immediately.
+type S3 struct {
+	endpoint     string
+	bucket       string
+	region       string
+	accessKey    string
+	secretKey    string
+	storageClass string
+	restoreTier  string // Expedited, Standard or Bulk
+	restoreDays  int    // How long a restored copy stays readable
+	client       *http.Client
+}
+
+// NewS3 creates a tier in bucket at endpoint (e.g. https://s3.eu-west-1.amazonaws.com),
+// using path-style requests signed with the given credentials.
+func NewS3(endpoint, bucket, region, accessKey, secretKey, storageClass, restoreTier string, restoreDays int) *S3 {
+	if restoreDays < 1 {
+		restoreDays = 1
+	}
+	return &S3{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		bucket:       bucket,
+		region:       region,
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		storageClass: strings.ToUpper(storageClass),
+		restoreTier:  restoreTier,
+		restoreDays:  restoreDays,
+		// No overall timeout, as uploads and downloads of large files take long;
+		// requests are bounded by their context.
+		client: &http.Client{},
+	}
+}
+
+// Name returns the tier name.
+func (s *S3) Name() string {
+	return "s3"
+}
+
+// Put uploads the object with the configured storage class.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	req, err := s.request(ctx, http.MethodPut, key, "", r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if s.storageClass != "" {
+		req.Header.Set("X-Amz-Storage-Class", s.storageClass)
+	}
+	return s.do(req, "", http.StatusOK)
+}
+
+// Restore starts a restore for archival storage classes. A restore already in
+// progress is not an error.
+func (s *S3) Restore(ctx context.Context, key string) (time.Duration, error) {
+	times, archival := restoreTimes[s.storageClass]
+	if !archival {
+		return 0, nil
+	}
+
+	body := []byte(fmt.Sprintf(
+		"<RestoreRequest><Days>%d</Days><GlacierJobParameters><Tier>%s</Tier></GlacierJobParameters></RestoreRequest>",
+		s.restoreDays, s.restoreTier))
+	req, err := s.request(ctx, http.MethodPost, key, "restore=", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = int64(len(body))
+	sum := md5.Sum(body)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+
+	payload := sha256.Sum256(body)
+	if err := s.do(req, hex.EncodeToString(payload[:]), http.StatusAccepted, http.StatusOK, http.StatusConflict); err != nil {
+		return 0, err
+	}
+
+	eta, ok := times[s.restoreTier]
+	if !ok {
+		eta = times["Standard"]
+	}
+	return eta, nil
+}
+
+// Ready reports whether the object is readable: always for non-archival
+// classes, otherwise once a restore has completed.
+func (s *S3) Ready(ctx context.Context, key string) (bool, error) {
+	req, err := s.request(ctx, http.MethodHead, key, "", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := s.send(req, "")
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("head %s: status %d", key, resp.StatusCode)
+	}
+
+	if _, archival := restoreTimes[resp.Header.Get("X-Amz-Storage-Class")]; !archival {
+		return true, nil
+	}
+	return strings.Contains(resp.Header.Get("X-Amz-Restore"), `ongoing-request="false"`), nil
+}
+
+// Get downloads the object.
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.send(req, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the object.
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, "", nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req, "", http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
+
+// request builds a path-style request for key.
+func (s *S3) request(ctx context.Context, method, key, query string, body io.Reader) (*http.Request, error) {
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+	}
+	rawURL := s.endpoint + "/" + awsEscape(s.bucket) + "/" + strings.Join(segments, "/")
+	if query != "" {
+		rawURL += "?" + query
+	}
+	return http.NewRequestWithContext(ctx, method, rawURL, body)
+}
+
+// do signs and sends req and checks the status against ok.
+func (s *S3) do(req *http.Request, payloadHash string, ok ...int) error {
+	resp, err := s.send(req, payloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, status := range ok {
+		if resp.StatusCode == status {
+			io.Copy(io.Discard, resp.Body)
+			return nil
+		}
+	}
+	return responseError(resp)
+}
+
+// send signs and sends req. payloadHash is the hex SHA-256 of the body, or
+// empty to send the body unsigned.
+func (s *S3) send(req *http.Request, payloadHash string) (*http.Response, error) {
+	if payloadHash == "" {
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+	s.sign(req, payloadHash, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers to req.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers: host plus every x-amz-* and content-md5 header
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-md5" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires.
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), values[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything but unreserved characters.
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// responseError describes a failed S3 response.
+func responseError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: status %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,103 @@
+// Package coldstorage moves old recordings to a cheaper storage tier and
+// restores them on request.
+package coldstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Tier is a storage backend for archived files, addressed by key.
+type Tier interface {
+	// Name identifies the tier in logs.
+	Name() string
+	// Put stores size bytes from r under key.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Restore starts making key readable and returns how long that is expected
+	// to take. Tiers that are always readable return 0.
+	Restore(ctx context.Context, key string) (time.Duration, error)
+	// Ready reports whether key can be read.
+	Ready(ctx context.Context, key string) (bool, error)
+	// Get opens key for reading.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Dir is a tier backed by a directory, typically a mount of cheaper storage
+// (network or object storage gateway). Files are always readable, so restores
+// complete on the next lifecycle pass.
+type Dir struct {
+	root string
+}
+
+// NewDir creates a tier storing files under root.
+func NewDir(root string) *Dir {
+	return &Dir{root: root}
+}
+
+// Name returns the tier name.
+func (d *Dir) Name() string {
+	return "dir"
+}
+
+// Put writes the file, replacing any existing one.
+func (d *Dir) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write under a temporary name so a partial file is never taken as complete
+	tmp := path + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Restore is a no-op; files are always readable.
+func (d *Dir) Restore(ctx context.Context, key string) (time.Duration, error) {
+	return 0, nil
+}
+
+// Ready reports whether the file exists.
+func (d *Dir) Ready(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(d.path(key))
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Get opens the file.
+func (d *Dir) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(d.path(key))
+}
+
+// Delete removes the file.
+func (d *Dir) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps a key to a file below root.
+func (d *Dir) path(key string) string {
+	return filepath.Join(d.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
@@ -0,0 +1,99 @@
+// Package composite combines a class's screen and camera recordings into a
+// single picture-in-picture recording, so viewers get the slides and the
+// presenter in one file.
+package composite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Corners the camera inset can be placed in
+const (
+	TopLeft     = "top-left"
+	TopRight    = "top-right"
+	BottomLeft  = "bottom-left"
+	BottomRight = "bottom-right"
+)
+
+// Layout places the camera inset over the screen.
+type Layout struct {
+	Position     string // Corner of the inset; bottom-right if unknown
+	WidthPercent int    // Inset width as a percent of the screen's
+	Margin       int    // Pixels between the inset and the screen's edges
+}
+
+// filter returns the ffmpeg filter graph that scales the camera (input 1)
+// relative to the screen (input 0) and overlays it. The screen keeps
+// playing after the camera ends.
+func (l Layout) filter() string {
+	width := l.WidthPercent
+	if width <= 0 || width > 100 {
+		width = 25
+	}
+	m := l.Margin
+	if m < 0 {
+		m = 0
+	}
+
+	x, y := fmt.Sprintf("main_w-overlay_w-%d", m), fmt.Sprintf("main_h-overlay_h-%d", m)
+	switch l.Position {
+	case TopLeft:
+		x, y = fmt.Sprint(m), fmt.Sprint(m)
+	case TopRight:
+		y = fmt.Sprint(m)
+	case BottomLeft:
+		x = fmt.Sprint(m)
+	}
+
+	// Dimensions are kept even, as H.264 requires
+	return fmt.Sprintf(
+		"[1:v][0:v]scale2ref=w='trunc(main_w*%d/200)*2':h='trunc(ow/a/2)*2'[cam][screen];"+
+			"[screen][cam]overlay=x=%s:y=%s:eof_action=pass,format=yuv420p[out]",
+		width, x, y)
+}
+
+// Compositor composes recordings with ffmpeg.
+type Compositor struct {
+	ffmpeg string
+	layout Layout
+}
+
+// NewCompositor creates a compositor running the ffmpeg binary at path.
+func NewCompositor(path string, layout Layout) *Compositor {
+	return &Compositor{ffmpeg: path, layout: layout}
+}
+
+// Available reports whether the ffmpeg binary can be found.
+func (c *Compositor) Available() bool {
+	if c == nil || c.ffmpeg == "" {
+		return false
+	}
+	_, err := exec.LookPath(c.ffmpeg)
+	return err == nil
+}
+
+// Compose writes an MP4 of the plaintext screen recording with the camera
+// recording inset to out. The screen recording's audio is kept.
+func (c *Compositor) Compose(ctx context.Context, screen, camera, out string) error {
+	cmd := exec.CommandContext(ctx, c.ffmpeg,
+		"-hide_banner", "-nostats", "-loglevel", "error", "-y",
+		"-i", screen,
+		"-i", camera,
+		"-filter_complex", c.layout.filter(),
+		"-map", "[out]", "-map", "0:a?",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+		"-c:a", "aac", "-b:a", "128k",
+		"-movflags", "+faststart",
+		"-f", "mp4", out,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package composite
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/chapters"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/variants"
+)
+
+// Limits of compositing
+const (
+	queueSize      = 16
+	backfillMax    = 10 // Recordings composited per backfill pass
+	composeTimeout = 2 * time.Hour
+)
+
+// Processor composites the camera recordings uploaded with screen
+// recordings in the background: right after upload, and in periodic passes
+// that pick up recordings missed while the queue was full or interrupted by
+// a restart. Recordings stay processing until it is done, then their
+// chapters are proposed from the composite and its variants are made.
+//
+// Recordings are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Processor struct {
+	recordingRepo *repository.RecordingRepository
+	compositor    *Compositor
+	files         *encryption.Encryptor // nil stores files in plaintext
+	chapters      *chapters.Generator
+	variants      *variants.Generator
+	interval      time.Duration
+	queue         chan *models.Recording
+}
+
+// NewProcessor creates a processor with a backfill pass every interval (0
+// for none). A nil compositor disables it.
+func NewProcessor(recordingRepo *repository.RecordingRepository, compositor *Compositor, files *encryption.Encryptor, chapterGenerator *chapters.Generator, variantGenerator *variants.Generator, interval time.Duration) *Processor {
+	return &Processor{
+		recordingRepo: recordingRepo,
+		compositor:    compositor,
+		files:         files,
+		chapters:      chapterGenerator,
+		variants:      variantGenerator,
+		interval:      interval,
+		queue:         make(chan *models.Recording, queueSize),
+	}
+}
+
+// Enabled reports whether camera recordings are composited.
+func (p *Processor) Enabled() bool {
+	return p.compositor != nil
+}
+
+// Enqueue schedules compositing for a newly uploaded recording. It never
+// blocks; if the queue is full the next backfill pass picks the recording
+// up.
+func (p *Processor) Enqueue(recording *models.Recording) {
+	if p.compositor == nil {
+		return
+	}
+	select {
+	case p.queue <- recording:
+	default:
+		log.Printf("[Composite] Queue full, %s left for the next pass", recording.ID.Hex())
+	}
+}
+
+// Run composites queued recordings, and any still waiting immediately and
+// then every interval, until ctx is cancelled.
+func (p *Processor) Run(ctx context.Context) {
+	if p.compositor == nil {
+		return
+	}
+
+	var tick <-chan time.Time
+	if p.interval > 0 {
+		p.backfill(ctx)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case recording := <-p.queue:
+			p.process(ctx, recording)
+		case <-tick:
+			p.backfill(ctx)
+		}
+	}
+}
+
+// backfill composites recordings still waiting for it.
+func (p *Processor) backfill(ctx context.Context) {
+	findCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	recordings, err := p.recordingRepo.FindPendingComposites(findCtx, time.Now().Add(-composeTimeout), backfillMax)
+	cancel()
+	if err != nil {
+		log.Printf("[Composite] Failed to load waiting recordings: %v", err)
+		return
+	}
+	for i := range recordings {
+		if ctx.Err() != nil {
+			return
+		}
+		p.process(ctx, &recordings[i])
+	}
+}
+
+// process claims a recording and replaces its file with the composite. On
+// failure the screen recording is kept as it was uploaded.
+func (p *Processor) process(ctx context.Context, recording *models.Recording) {
+	claimed, err := p.recordingRepo.ClaimComposite(ctx, recording.ID, time.Now().Add(-composeTimeout))
+	if err != nil {
+		log.Printf("[Composite] Failed to claim %s: %v", recording.ID.Hex(), err)
+		return
+	}
+	if !claimed {
+		return // Handled by another instance
+	}
+
+	composeCtx, cancel := context.WithTimeout(ctx, composeTimeout)
+	started := time.Now()
+	filePath, fileSize, err := p.compose(composeCtx, recording)
+	cancel()
+	if ctx.Err() != nil {
+		// Shutting down; the claim goes stale and a later pass retries
+		os.Remove(filePath)
+		return
+	}
+
+	fileName := filepath.Base(filePath)
+	mimeType := "video/mp4"
+	if err != nil {
+		log.Printf("[Composite] Keeping the screen recording of %s (%s): %v", recording.Title, recording.ID.Hex(), err)
+		fileName, filePath, fileSize, mimeType = "", "", 0, ""
+	}
+
+	finished, err := p.recordingRepo.FinishComposite(context.WithoutCancel(ctx), recording.ID, fileName, filePath, fileSize, mimeType)
+	if err != nil || !finished {
+		if err != nil {
+			log.Printf("[Composite] Failed to save the composite of %s: %v", recording.ID.Hex(), err)
+		}
+		os.Remove(filePath)
+		return
+	}
+
+	os.Remove(recording.CameraPath)
+	if fileName != "" {
+		os.Remove(recording.FilePath)
+		log.Printf("[Composite] Composited %s in %v", recording.Title, time.Since(started).Round(time.Second))
+		recording.FileName, recording.FilePath, recording.FileSize, recording.MimeType = fileName, filePath, fileSize, mimeType
+	}
+	recording.Status = models.RecordingStatusReady
+	p.chapters.Enqueue(recording)
+	p.variants.Enqueue(recording)
+}
+
+// compose writes the composite of a recording next to it, encrypted when
+// enabled, and returns its path and size. ffmpeg reads and writes
+// plaintext, so temporary files are used throughout.
+func (p *Processor) compose(ctx context.Context, recording *models.Recording) (string, int64, error) {
+	screen, cleanup, err := p.plaintext(ctx, recording.FilePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer cleanup()
+	camera, cleanup, err := p.plaintext(ctx, recording.CameraPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer cleanup()
+
+	out, err := os.CreateTemp("", "composite-*.mp4")
+	if err != nil {
+		return "", 0, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	if err := p.compositor.Compose(ctx, screen, camera, out.Name()); err != nil {
+		return "", 0, err
+	}
+
+	base := strings.TrimSuffix(recording.FilePath, filepath.Ext(recording.FilePath))
+	filePath := base + "_composite.mp4"
+	fileSize, err := p.store(ctx, out.Name(), filePath)
+	if err != nil {
+		os.Remove(filePath)
+		return "", 0, err
+	}
+	return filePath, fileSize, nil
+}
+
+// store copies the plaintext file at src to dst, encrypted when enabled.
+func (p *Processor) store(ctx context.Context, src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := p.files.Create(ctx, dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// plaintext returns a path ffmpeg can read the file at path from: the file
+// itself, or a decrypted temporary copy removed by cleanup.
+func (p *Processor) plaintext(ctx context.Context, path string) (string, func(), error) {
+	_, encrypted, err := encryption.KeyID(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !encrypted {
+		return path, func() {}, nil
+	}
+
+	src, err := p.files.Open(ctx, path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "composite-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
+}
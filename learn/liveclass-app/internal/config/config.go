@@ -3,7 +3,9 @@ package config
 
 import (
 	"os"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +15,7 @@ type Config struct {
 	Host       string
 	Port       int
 	InstanceID string // Unique instance ID for multi-instance deployments
+	Version    string // Build version, reported in the cluster status
 
 	// HTTP Server performance settings
 	ReadTimeout       time.Duration
@@ -21,12 +24,100 @@ type Config struct {
 	RequestTimeout    time.Duration
 	EnableCompression bool
 
+	// Access log of every request ("" to disable, "stdout", "file" or
+	// "syslog"), and the share of requests logged per route pattern for
+	// busy routes
+	AccessLog           string
+	AccessLogFile       string
+	AccessLogSyslogAddr string
+	AccessLogSampling   map[string]float64
+
+	// Bearer token Prometheus scrapes /metrics with; admins can read it
+	// with their login token either way
+	MetricsToken string
+
+	// WebSocket permessage-deflate and message size limit
+	WSCompressionEnabled   bool
+	WSCompressionLevel     int
+	WSCompressionThreshold int
+	WSMaxMessageBytes      int64
+
+	// Server-sent event streams: events kept per user for resuming, and for
+	// how long after their last stream closed
+	SSEBacklog      int
+	SSEResumeWindow time.Duration
+
 	// WebRTC configuration
 	STUNServers  []string
 	TURNServers  []string
 	TURNUsername string
 	TURNPassword string
 
+	// Rooms whose media only goes through the TURN servers ("*" for all)
+	TURNRelayOnlyRooms []string
+
+	// Regional TURN clusters; clients get the closest TURNRegionsPerClient
+	TURNRegions          []TURNRegion
+	TURNRegionsPerClient int
+
+	// Client geolocation for TURN region selection
+	GeoIPDBPath         string // CSV of network,country,latitude,longitude
+	GeoIPCountryHeader  string // Country code header set by a CDN, e.g. CF-IPCountry
+	GeoIPTrustForwarded bool   // Take the client address from X-Forwarded-For
+
+	// ICE restart retry budget and circuit breaker
+	ICERestartMaxAttempts      int
+	ICERestartBaseBackoff      time.Duration
+	ICERestartMaxBackoff       time.Duration
+	ICERestartBreakerThreshold int
+	ICERestartBreakerCooldown  time.Duration
+
+	// ICE candidates gathered by the SFU (network types, ports, NAT 1:1 mapping)
+	ICENetworkTypes         []string
+	ICEUDPPortMin           int
+	ICEUDPPortMax           int
+	ICEUDPMuxPort           int
+	ICETCPPort              int
+	ICENAT1To1IPs           []string
+	ICENAT1To1CandidateType string
+
+	// Presenter uplink adaptation (ask for less video when the uplink is lossy)
+	UplinkAdaptInterval        time.Duration
+	UplinkAdaptHighLossPercent int
+	UplinkAdaptLowLossPercent  int
+	UplinkAdaptSustain         int
+
+	// Presenter bitrate cap (keep forwarding within the server's capacity as rooms grow)
+	PresenterBitrateCapInterval time.Duration
+	PresenterMaxBitrateKbps     int
+	PresenterMinBitrateKbps     int
+	EgressBudgetKbps            int
+
+	// Speaking indicators from presenter audio levels
+	SpeakingThresholdDBov int
+	SpeakingHold          time.Duration
+	SpeakingInterval      time.Duration
+
+	// How long a disconnected presenter has to reconnect before the stream ends
+	PresenterGracePeriod time.Duration
+
+	// Rooms without messages or media for RoomIdleTimeout are closed; dead
+	// connections and idle rooms are checked every RoomReapInterval
+	RoomIdleTimeout  time.Duration
+	RoomReapInterval time.Duration
+
+	// How often live rooms' connection quality is sampled for the network
+	// quality heatmap of session reports
+	QualitySampleInterval time.Duration
+
+	// Room tokens binding signaling messages to the participant who joined
+	RoomTokenTTL      time.Duration
+	RoomTokenRequired bool
+
+	// Retransmits of critical signaling messages to clients that acknowledge them
+	SignalingAckTimeout     time.Duration
+	SignalingMaxRetransmits int
+
 	// MongoDB configuration
 	MongoURI           string
 	MongoDBName        string
@@ -36,10 +127,28 @@ type Config struct {
 	MongoConnTimeout   time.Duration
 	MongoSocketTimeout time.Duration
 
+	// Budgets of single repository reads and writes (0 for no limit)
+	MongoReadTimeout  time.Duration
+	MongoWriteTimeout time.Duration
+
+	// Read preference for heavy list and report queries (timelines,
+	// engagement, cohort and storage reports), e.g. "secondaryPreferred",
+	// and how stale the secondary serving them may be (0 for no limit)
+	MongoReportReadPreference string
+	MongoReportMaxStaleness   time.Duration
+
+	// Queries slower than this are logged and explained (0 disables), every
+	// QueryExplainInterval
+	SlowQueryThreshold   time.Duration
+	QueryExplainInterval time.Duration
+
 	// Redis configuration (for multi-instance)
 	RedisEnabled bool
 	RedisURL     string
 
+	// How often instances announce themselves to the cluster status API
+	ClusterHeartbeatInterval time.Duration
+
 	// Cache configuration
 	CacheEnabled       bool
 	UserCacheTTL       time.Duration
@@ -47,18 +156,256 @@ type Config struct {
 	ScheduleCacheTTL   time.Duration
 	CacheCleanupPeriod time.Duration
 
-	// JWT configuration
+	// Preload caches at startup, for at most the budget
+	CacheWarmupEnabled bool
+	CacheWarmupBudget  time.Duration
+
+	// HTTP response cache for hot read endpoints
+	HTTPCacheEnabled      bool
+	HTTPCacheBatchesTTL   time.Duration
+	HTTPCacheSchedulesTTL time.Duration
+
+	// JWT configuration: JWTExpiryHours is the lifetime of a login session,
+	// AccessTokenTTL of its access tokens (0 for the whole session)
 	JWTSecret      string
 	JWTExpiryHours int
+	AccessTokenTTL time.Duration
+
+	// Signed-in devices allowed per account by role (0 for no limit)
+	SessionLimitStudent   int
+	SessionLimitPresenter int
+	SessionLimitAdmin     int
+
+	// Students' own account deactivation: how long they have to change their
+	// mind, and how often accounts past it are anonymized
+	DeactivationGrace             time.Duration
+	DeactivationAnonymizeInterval time.Duration
 
 	// Default admin credentials
 	AdminEmail    string
 	AdminPassword string
 	AdminName     string
 
+	// CPU pressure: degrade live rooms after Sustain samples at or above High,
+	// restore after Sustain samples at or below Low (utilization 0-1)
+	CPUPressureInterval    time.Duration
+	CPUPressureHigh        float64
+	CPUPressureLow         float64
+	CPUPressureSustain     int
+	DegradedMaxHeight      int
+	DegradedMaxFramerate   int
+	DegradedMaxBitrateKbps int
+
+	// Re-streaming live classes to RTMP/SRT targets with ffmpeg (disabled
+	// when RestreamFFmpegPath is empty)
+	RestreamFFmpegPath   string
+	RestreamVideoKbps    int
+	RestreamAllowedHosts []string // Required when RestreamFFmpegPath is set
+
 	// Storage configuration
 	StoragePath string
 
+	// Storage usage reports and alert thresholds (bytes)
+	StorageReportInterval  time.Duration
+	StorageAlertThresholds []int64
+
+	// How often stored batch/presenter name snapshots are reconciled
+	NameReconcileInterval time.Duration
+
+	// How often notes held back until a publish time are checked
+	NotePublishInterval time.Duration
+
+	// How often the last week's catch-up summaries are checked for students
+	// who haven't had theirs
+	CatchUpInterval time.Duration
+
+	// How long links to files shared in a live class work
+	HandoutLinkTTL time.Duration
+
+	// Largest image a student can hand in during a live class (bytes)
+	HandInMaxSize int64
+
+	// How often finished template classes are rolled up for cohort
+	// comparisons, and for how long after a class its rollup is refreshed
+	CohortRollupInterval time.Duration
+	CohortRollupWindow   time.Duration
+
+	// Widths of the resized copies made of image notes, and how often images
+	// still without them are looked for
+	NoteImageWidths           []int
+	NoteImageBackfillInterval time.Duration
+
+	// PDF copies of Word and PowerPoint notes, for previews. A converter URL
+	// takes precedence over a local LibreOffice; both empty disable it.
+	NotePDFConverterURL     string
+	NotePDFSofficePath      string
+	NotePDFBackfillInterval time.Duration
+
+	// Chapters proposed from scene changes in recordings, found with ffmpeg
+	ChaptersFFmpegPath       string // Empty disables detection
+	ChaptersSceneThreshold   int    // Percent of the picture that must change
+	ChaptersMinGap           time.Duration
+	ChaptersMax              int
+	ChaptersBackfillInterval time.Duration
+
+	// Camera recordings uploaded with a screen recording are inset into it
+	// picture-in-picture with ffmpeg
+	CompositeFFmpegPath       string // Empty keeps only the screen recording
+	CompositePosition         string // top-left, top-right, bottom-left or bottom-right
+	CompositeWidthPercent     int    // Inset width, percent of the screen's
+	CompositeMargin           int    // Pixels from the screen's edges
+	CompositeBackfillInterval time.Duration
+
+	// 480p and audio-only copies of recordings, made with ffmpeg
+	VariantsFFmpegPath       string // Empty disables them
+	VariantsBackfillInterval time.Duration
+
+	// Segments of recordings played into live classes, re-encoded with ffmpeg
+	CoWatchFFmpegPath string // Empty disables it
+	CoWatchVideoKbps  int
+
+	// Live classes can be recorded on the server, from the media the SFU
+	// forwards, instead of by the presenter's browser
+	ServerRecordingEnabled bool
+
+	// Working hours that schedule suggestions are made within
+	WorkingDays       []string
+	WorkingHoursStart string // HH:MM
+	WorkingHoursEnd   string // HH:MM
+	WorkingTimezone   string // IANA name, e.g. "Asia/Kolkata"
+
+	// Share of a recording a student must watch for it to count as completed
+	RecordingCompletionPercent int
+
+	// Cold storage for old recordings (disabled when ColdStorageBackend is empty)
+	ColdStorageBackend     string // "dir" or "s3"
+	ColdStorageDir         string
+	ColdStorageS3Endpoint  string
+	ColdStorageS3Bucket    string
+	ColdStorageS3Region    string
+	ColdStorageS3AccessKey string
+	ColdStorageS3SecretKey string
+	ColdStorageS3Class     string
+	ColdStorageRestoreTier string
+	ColdStorageRestoreDays int
+	RecordingArchiveAfter  time.Duration
+	ColdStorageInterval    time.Duration
+
+	// CDN for recording downloads (disabled when CDNBaseURL is empty)
+	CDNBaseURL    string
+	CDNSigningKey string
+	CDNURLTTL     time.Duration
+
+	// Recording stream tokens: how long a playback token outlasts the
+	// recording, whether it only works from the first client address to use
+	// it, and whether login tokens are still accepted in stream URLs
+	PlaybackTokenTTL        time.Duration
+	PlaybackTokenPinClient  bool
+	StreamLoginTokenInQuery bool
+
+	// Encryption at rest for recording and note files (disabled when empty)
+	StorageEncryption      string // "env" or "vault"
+	StorageEncryptionKeys  string // "id:base64key,..." for env
+	StorageEncryptionKeyID string // Master key for new files; defaults to the first
+	VaultAddr              string
+	VaultToken             string
+	VaultTransitKey        string
+
+	// Notification categories that come through users' quiet hours, and how
+	// often notifications held back by quiet hours are checked
+	NotifyUrgentCategories []string
+	NotifyReleaseInterval  time.Duration
+
+	// Notifications to students as their classes are scheduled, about to
+	// start, live, and their recordings and materials published
+	NotifyClassEmails       bool          // Email them too
+	NotifyClassReminderLead time.Duration // Before the start; 0 sends no reminders
+	NotifyClassInterval     time.Duration
+
+	// Outgoing email (SMTP); emails are only logged when SMTPHost is empty
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Default portal branding, until an admin customizes it
+	BrandingOrg         string // Organization whose branding this instance serves
+	BrandingPortalName  string
+	BrandingEmailFooter string
+
+	// Identity verification provider (webhook with shared secret)
+	VerificationProvider      string
+	VerificationWebhookSecret string
+
+	// Webhook inbox for external systems (payments, an LMS)
+	WebhookSecrets   []string      // "source=secret" entries; sources without one are refused
+	WebhookRoutes    []string      // "source:type=action" entries
+	WebhookTolerance time.Duration // How far a request's signing time may be from its arrival
+	WebhookRetention time.Duration
+
+	// Alerts on the health of live classes, measured over AlertWindow; a
+	// zero threshold disables its alert
+	AlertWindow               time.Duration
+	AlertViewerDropPercent    int
+	AlertViewerDropMinViewers int
+	AlertPresenterICEFailures int
+	AlertForwardErrorPercent  int
+	AlertForwardMinPackets    int
+	AlertCooldown             time.Duration
+	AlertNotifyPresenter      bool
+
+	// Daily request quotas of external API clients, e.g. "webhook:lms";
+	// admins are alerted at APIQuotaAlertPercent of a quota
+	APIQuotas            map[string]int64
+	APIQuotaAlertPercent int
+
+	// Client-side telemetry from the SPA: share of events kept by type, and
+	// the size of the capped collection holding them
+	ClientEventSampleRates map[string]float64
+	ClientEventsMaxMB      int
+
+	// Billing: seat limits and plan features (everything is allowed when disabled)
+	BillingEnabled bool
+	BillingGrace   time.Duration
+
+	// Content with this many open reports is hidden until an admin reviews it (0 disables)
+	ReportHideThreshold int
+
+	// Live caption translation (disabled when TranslationURL is empty)
+	TranslationURL      string
+	TranslationAPIKey   string
+	TranslationCacheTTL time.Duration
+
+	// Quiz drafts from class transcripts (disabled when QuizGenURL is empty)
+	QuizGenURL          string
+	QuizGenAPIKey       string
+	QuizGenMaxQuestions int
+	QuizGenTimeout      time.Duration
+
+	// HTML allowed in user content; empty lists use the richtext defaults
+	SanitizeRichTags   []string // Descriptions and rendered Markdown
+	SanitizeChatTags   []string // Chat and direct messages
+	SanitizeURLSchemes []string
+
+	// Usage analytics export (disabled when AnalyticsSink is empty)
+	AnalyticsSink               string // "clickhouse" or "bigquery"
+	AnalyticsClickHouseURL      string
+	AnalyticsClickHouseTable    string
+	AnalyticsClickHouseUser     string
+	AnalyticsClickHousePassword string
+	AnalyticsBigQueryProject    string
+	AnalyticsBigQueryDataset    string
+	AnalyticsBigQueryTable      string
+	AnalyticsBigQueryToken      string
+	AnalyticsBatchSize          int
+	AnalyticsFlushInterval      time.Duration
+	AnalyticsQueueSize          int
+	AnalyticsMaxRetries         int
+
+	// Development mode enables tooling that must never run in production (demo data seeding)
+	DevMode bool
+
 	// Graceful shutdown
 	ShutdownTimeout time.Duration
 }
@@ -69,6 +416,7 @@ func Default() *Config {
 		Host:       getEnv("HOST", ""),
 		Port:       getEnvInt("PORT", 8080),
 		InstanceID: getEnv("INSTANCE_ID", generateInstanceID()),
+		Version:    getEnv("APP_VERSION", buildVersion()),
 
 		// HTTP Server performance - optimized timeouts
 		ReadTimeout:       time.Duration(getEnvInt("READ_TIMEOUT_SEC", 30)) * time.Second,
@@ -77,6 +425,23 @@ func Default() *Config {
 		RequestTimeout:    time.Duration(getEnvInt("REQUEST_TIMEOUT_SEC", 15)) * time.Second,
 		EnableCompression: getEnvBool("ENABLE_COMPRESSION", true),
 
+		// Access log - probes and scrapes are sampled, server errors never
+		AccessLog:           getEnv("ACCESS_LOG", ""),
+		AccessLogFile:       getEnv("ACCESS_LOG_FILE", "access.log"),
+		AccessLogSyslogAddr: getEnv("ACCESS_LOG_SYSLOG_ADDR", ""),
+		AccessLogSampling:   getEnvRates("ACCESS_LOG_SAMPLING", "/api/health=0.01,/api/ready=0.01,/metrics=0.01"),
+		MetricsToken:        getEnv("METRICS_TOKEN", ""),
+
+		// WebSocket compression - only frames above the threshold are deflated
+		WSCompressionEnabled:   getEnvBool("WS_COMPRESSION_ENABLED", true),
+		WSCompressionLevel:     getEnvInt("WS_COMPRESSION_LEVEL", 1),
+		WSCompressionThreshold: getEnvInt("WS_COMPRESSION_MIN_BYTES", 512),
+		WSMaxMessageBytes:      int64(getEnvInt("WS_MAX_MESSAGE_KB", 256)) * 1024,
+
+		// EventSource reconnects within seconds; the window covers flaky networks
+		SSEBacklog:      getEnvInt("SSE_BACKLOG", 100),
+		SSEResumeWindow: time.Duration(getEnvInt("SSE_RESUME_WINDOW_SEC", 300)) * time.Second,
+
 		// STUN servers
 		STUNServers: []string{
 			"stun:stun.l.google.com:19302",
@@ -89,6 +454,68 @@ func Default() *Config {
 		TURNUsername: getEnv("TURN_USERNAME", ""),
 		TURNPassword: getEnv("TURN_PASSWORD", ""),
 
+		// Relay-only rooms, e.g. for networks that must not see peer addresses
+		TURNRelayOnlyRooms: getEnvSlice("TURN_RELAY_ONLY_ROOMS", []string{}),
+
+		// Regional TURN clusters, selected per client by location
+		TURNRegions:          getTURNRegions(),
+		TURNRegionsPerClient: getEnvInt("TURN_REGIONS_PER_CLIENT", 2),
+		GeoIPDBPath:          getEnv("GEOIP_DB_PATH", ""),
+		GeoIPCountryHeader:   getEnv("GEOIP_COUNTRY_HEADER", ""),
+		GeoIPTrustForwarded:  getEnvBool("GEOIP_TRUST_FORWARDED", false),
+
+		// ICE restarts - per-viewer budget, then fall back to a full rejoin
+		ICERestartMaxAttempts:      getEnvInt("ICE_RESTART_MAX_ATTEMPTS", 3),
+		ICERestartBaseBackoff:      time.Duration(getEnvInt("ICE_RESTART_BACKOFF_MS", 500)) * time.Millisecond,
+		ICERestartMaxBackoff:       time.Duration(getEnvInt("ICE_RESTART_MAX_BACKOFF_MS", 5000)) * time.Millisecond,
+		ICERestartBreakerThreshold: getEnvInt("ICE_RESTART_BREAKER_THRESHOLD", 20),
+		ICERestartBreakerCooldown:  time.Duration(getEnvInt("ICE_RESTART_BREAKER_COOLDOWN_SEC", 30)) * time.Second,
+
+		// SFU ICE candidates - defaults gather on every UDP interface and port
+		ICENetworkTypes:         getEnvSlice("ICE_NETWORK_TYPES", []string{}),
+		ICEUDPPortMin:           getEnvInt("ICE_UDP_PORT_MIN", 0),
+		ICEUDPPortMax:           getEnvInt("ICE_UDP_PORT_MAX", 0),
+		ICEUDPMuxPort:           getEnvInt("ICE_UDP_MUX_PORT", 0),
+		ICETCPPort:              getEnvInt("ICE_TCP_PORT", 0),
+		ICENAT1To1IPs:           getEnvSlice("ICE_NAT_1TO1_IPS", []string{}),
+		ICENAT1To1CandidateType: getEnv("ICE_NAT_1TO1_CANDIDATE_TYPE", "host"),
+
+		// Presenter uplink adaptation (0 interval disables)
+		UplinkAdaptInterval:        time.Duration(getEnvInt("UPLINK_ADAPT_INTERVAL_SEC", 2)) * time.Second,
+		UplinkAdaptHighLossPercent: getEnvInt("UPLINK_ADAPT_HIGH_LOSS_PERCENT", 5),
+		UplinkAdaptLowLossPercent:  getEnvInt("UPLINK_ADAPT_LOW_LOSS_PERCENT", 1),
+		UplinkAdaptSustain:         getEnvInt("UPLINK_ADAPT_SUSTAIN_SAMPLES", 3),
+
+		// Presenter bitrate cap (0 interval disables); the budget is for all
+		// rooms' video, 0 for no limit beyond the maximum
+		PresenterBitrateCapInterval: time.Duration(getEnvInt("PRESENTER_BITRATE_CAP_INTERVAL_SEC", 2)) * time.Second,
+		PresenterMaxBitrateKbps:     getEnvInt("PRESENTER_MAX_BITRATE_KBPS", 2500),
+		PresenterMinBitrateKbps:     getEnvInt("PRESENTER_MIN_BITRATE_KBPS", 300),
+		EgressBudgetKbps:            getEnvInt("EGRESS_BUDGET_KBPS", 800000),
+
+		// Active speaker highlighting; audio at or above -50 dBov is speech
+		SpeakingThresholdDBov: getEnvInt("SPEAKING_THRESHOLD_DBOV", 50),
+		SpeakingHold:          time.Duration(getEnvInt("SPEAKING_HOLD_MS", 800)) * time.Millisecond,
+		SpeakingInterval:      time.Duration(getEnvInt("SPEAKING_INTERVAL_MS", 500)) * time.Millisecond,
+
+		// Presenter reconnection grace period (0 ends the stream immediately)
+		PresenterGracePeriod: time.Duration(getEnvInt("PRESENTER_GRACE_SEC", 30)) * time.Second,
+
+		// Idle rooms close and their class completes (0 keeps idle rooms open)
+		RoomIdleTimeout:  time.Duration(getEnvInt("ROOM_IDLE_TIMEOUT_MIN", 30)) * time.Minute,
+		RoomReapInterval: time.Duration(getEnvInt("ROOM_REAP_INTERVAL_SEC", 60)) * time.Second,
+
+		// Quality samples in room timelines (0 disables the heatmap)
+		QualitySampleInterval: time.Duration(getEnvInt("QUALITY_SAMPLE_INTERVAL_SEC", 20)) * time.Second,
+
+		// Room tokens; required once all clients send them back
+		RoomTokenTTL:      time.Duration(getEnvInt("ROOM_TOKEN_TTL_MIN", 30)) * time.Minute,
+		RoomTokenRequired: getEnvBool("ROOM_TOKEN_REQUIRED", true),
+
+		// Signaling acknowledgements (0 timeout disables retransmits)
+		SignalingAckTimeout:     time.Duration(getEnvInt("SIGNALING_ACK_TIMEOUT_MS", 1500)) * time.Millisecond,
+		SignalingMaxRetransmits: getEnvInt("SIGNALING_MAX_RETRANSMITS", 3),
+
 		// MongoDB - optimized connection pool
 		MongoURI:           getEnv("MONGO_URI", "mongodb://localhost:27017"),
 		MongoDBName:        getEnv("MONGO_DB_NAME", "liveclass"),
@@ -98,10 +525,26 @@ func Default() *Config {
 		MongoConnTimeout:   time.Duration(getEnvInt("MONGO_CONN_TIMEOUT_SEC", 10)) * time.Second,
 		MongoSocketTimeout: time.Duration(getEnvInt("MONGO_SOCKET_TIMEOUT_SEC", 30)) * time.Second,
 
+		// Kept below the request timeout, so a stalled query fails the
+		// request cleanly instead of outliving it
+		MongoReadTimeout:  time.Duration(getEnvInt("MONGO_READ_TIMEOUT_MS", 10000)) * time.Millisecond,
+		MongoWriteTimeout: time.Duration(getEnvInt("MONGO_WRITE_TIMEOUT_MS", 10000)) * time.Millisecond,
+
+		// Writes and auth lookups always use the primary
+		MongoReportReadPreference: getEnv("MONGO_REPORT_READ_PREFERENCE", "primary"),
+		MongoReportMaxStaleness:   time.Duration(getEnvInt("MONGO_REPORT_MAX_STALENESS_SEC", 0)) * time.Second,
+
+		// Slow query patterns and index suggestions are in the admin diagnostics
+		SlowQueryThreshold:   time.Duration(getEnvInt("SLOW_QUERY_THRESHOLD_MS", 500)) * time.Millisecond,
+		QueryExplainInterval: time.Duration(getEnvInt("QUERY_EXPLAIN_INTERVAL_MIN", 30)) * time.Minute,
+
 		// Redis - for multi-instance deployments
 		RedisEnabled: getEnvBool("REDIS_ENABLED", false),
 		RedisURL:     getEnv("REDIS_URL", "redis://localhost:6379"),
 
+		// Instances missing three heartbeats drop out of the cluster status
+		ClusterHeartbeatInterval: time.Duration(getEnvInt("CLUSTER_HEARTBEAT_INTERVAL_SEC", 10)) * time.Second,
+
 		// Cache - fast in-memory caching (or Redis if enabled)
 		CacheEnabled:       getEnvBool("CACHE_ENABLED", true),
 		UserCacheTTL:       time.Duration(getEnvInt("USER_CACHE_TTL_SEC", 300)) * time.Second,    // 5 minutes
@@ -109,18 +552,236 @@ func Default() *Config {
 		ScheduleCacheTTL:   time.Duration(getEnvInt("SCHEDULE_CACHE_TTL_SEC", 30)) * time.Second, // 30 seconds
 		CacheCleanupPeriod: time.Duration(getEnvInt("CACHE_CLEANUP_SEC", 60)) * time.Second,      // 1 minute
 
+		// Warm-up delays taking traffic, so it's off unless deploys need it
+		CacheWarmupEnabled: getEnvBool("CACHE_WARMUP_ENABLED", false),
+		CacheWarmupBudget:  time.Duration(getEnvInt("CACHE_WARMUP_BUDGET_SEC", 10)) * time.Second,
+
+		// HTTP response cache - per user, invalidated on writes (0 TTL disables a route)
+		HTTPCacheEnabled:      getEnvBool("HTTP_CACHE_ENABLED", true),
+		HTTPCacheBatchesTTL:   time.Duration(getEnvInt("HTTP_CACHE_BATCHES_TTL_SEC", 60)) * time.Second,
+		HTTPCacheSchedulesTTL: time.Duration(getEnvInt("HTTP_CACHE_SCHEDULES_TTL_SEC", 15)) * time.Second,
+
 		// JWT defaults
 		JWTSecret:      getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
 		JWTExpiryHours: getEnvInt("JWT_EXPIRY_HOURS", 72),
 
+		// Short-lived access tokens need clients that refresh them; older ones
+		// keep working with the default (0: valid for the whole session)
+		AccessTokenTTL: time.Duration(getEnvInt("ACCESS_TOKEN_TTL_MIN", 15)) * time.Minute,
+
+		// Logging in on more devices signs out the oldest, curbing account sharing
+		SessionLimitStudent:   getEnvInt("SESSION_LIMIT_STUDENT", 2),
+		SessionLimitPresenter: getEnvInt("SESSION_LIMIT_PRESENTER", 0),
+		SessionLimitAdmin:     getEnvInt("SESSION_LIMIT_ADMIN", 0),
+
+		// Deactivated accounts are anonymized after the grace period (0 interval disables)
+		DeactivationGrace:             time.Duration(getEnvInt("DEACTIVATION_GRACE_DAYS", 14)) * 24 * time.Hour,
+		DeactivationAnonymizeInterval: time.Duration(getEnvInt("DEACTIVATION_ANONYMIZE_INTERVAL_MIN", 60)) * time.Minute,
+
 		// Default admin (created on first run)
 		AdminEmail:    getEnv("ADMIN_EMAIL", "admin@liveclass.com"),
 		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
 		AdminName:     getEnv("ADMIN_NAME", "Administrator"),
 
+		// CPU pressure feedback loop (0 interval disables it)
+		CPUPressureInterval:    time.Duration(getEnvInt("CPU_PRESSURE_INTERVAL_SEC", 5)) * time.Second,
+		CPUPressureHigh:        float64(getEnvInt("CPU_PRESSURE_HIGH_PERCENT", 85)) / 100,
+		CPUPressureLow:         float64(getEnvInt("CPU_PRESSURE_LOW_PERCENT", 60)) / 100,
+		CPUPressureSustain:     getEnvInt("CPU_PRESSURE_SUSTAIN_SAMPLES", 3),
+		DegradedMaxHeight:      getEnvInt("DEGRADED_MAX_HEIGHT", 480),
+		DegradedMaxFramerate:   getEnvInt("DEGRADED_MAX_FRAMERATE", 15),
+		DegradedMaxBitrateKbps: getEnvInt("DEGRADED_MAX_BITRATE_KBPS", 600),
+
+		// Re-streaming is opt-in: it encodes video, which is costly
+		RestreamFFmpegPath:   getEnv("RESTREAM_FFMPEG_PATH", ""),
+		RestreamVideoKbps:    getEnvInt("RESTREAM_VIDEO_BITRATE_KBPS", 2500),
+		RestreamAllowedHosts: getEnvSlice("RESTREAM_ALLOWED_HOSTS", nil),
+
 		// Storage (for recordings)
 		StoragePath: getEnv("STORAGE_PATH", "./storage"),
 
+		// Daily storage usage report; admins are alerted when usage crosses a threshold
+		StorageReportInterval:  time.Duration(getEnvInt("STORAGE_REPORT_INTERVAL_HOURS", 24)) * time.Hour,
+		StorageAlertThresholds: getEnvSizesGB("STORAGE_ALERT_THRESHOLDS_GB"),
+
+		// Name snapshots are also reconciled right after batch and user writes
+		NameReconcileInterval: time.Duration(getEnvInt("NAME_RECONCILE_INTERVAL_MIN", 60)) * time.Minute,
+
+		// Held-back notes are also published as soon as their class ends
+		NotePublishInterval: time.Duration(getEnvInt("NOTE_PUBLISH_INTERVAL_SEC", 60)) * time.Second,
+
+		// Summaries go out once a week; the interval only bounds the delay after Monday 00:00 UTC
+		CatchUpInterval: time.Duration(getEnvInt("CATCH_UP_INTERVAL_MIN", 60)) * time.Minute,
+
+		// Viewers joining later fetch the class's handouts with fresh links
+		HandoutLinkTTL: time.Duration(getEnvInt("HANDOUT_LINK_TTL_MIN", 120)) * time.Minute,
+
+		// Phone photos of worked problems rarely exceed a few megabytes
+		HandInMaxSize: int64(getEnvInt("HAND_IN_MAX_MB", 10)) << 20,
+
+		// Recent rollups are refreshed as students finish recordings
+		CohortRollupInterval: time.Duration(getEnvInt("COHORT_ROLLUP_INTERVAL_MIN", 60)) * time.Minute,
+		CohortRollupWindow:   time.Duration(getEnvInt("COHORT_ROLLUP_WINDOW_DAYS", 14)) * 24 * time.Hour,
+
+		// Images are resized right after upload; the backfill catches older ones
+		NoteImageWidths:           getEnvInts("NOTE_IMAGE_WIDTHS", []int{320, 640, 1280}),
+		NoteImageBackfillInterval: time.Duration(getEnvInt("NOTE_IMAGE_BACKFILL_INTERVAL_MIN", 10)) * time.Minute,
+
+		// Documents are converted right after upload; the backfill catches older ones
+		NotePDFConverterURL:     getEnv("NOTE_PDF_CONVERTER_URL", ""),
+		NotePDFSofficePath:      getEnv("NOTE_PDF_SOFFICE_PATH", "soffice"),
+		NotePDFBackfillInterval: time.Duration(getEnvInt("NOTE_PDF_BACKFILL_INTERVAL_MIN", 10)) * time.Minute,
+
+		// Recordings are scanned right after upload; the backfill catches older ones
+		ChaptersFFmpegPath:       getEnv("CHAPTERS_FFMPEG_PATH", "ffmpeg"),
+		ChaptersSceneThreshold:   getEnvInt("CHAPTERS_SCENE_THRESHOLD_PERCENT", 40),
+		ChaptersMinGap:           time.Duration(getEnvInt("CHAPTERS_MIN_GAP_SEC", 120)) * time.Second,
+		ChaptersMax:              getEnvInt("CHAPTERS_MAX", 30),
+		ChaptersBackfillInterval: time.Duration(getEnvInt("CHAPTERS_BACKFILL_INTERVAL_MIN", 30)) * time.Minute,
+
+		CompositeFFmpegPath:       getEnv("COMPOSITE_FFMPEG_PATH", "ffmpeg"),
+		CompositePosition:         getEnv("COMPOSITE_POSITION", "bottom-right"),
+		CompositeWidthPercent:     getEnvInt("COMPOSITE_WIDTH_PERCENT", 25),
+		CompositeMargin:           getEnvInt("COMPOSITE_MARGIN_PX", 24),
+		CompositeBackfillInterval: time.Duration(getEnvInt("COMPOSITE_BACKFILL_INTERVAL_MIN", 15)) * time.Minute,
+
+		VariantsFFmpegPath:       getEnv("VARIANTS_FFMPEG_PATH", "ffmpeg"),
+		VariantsBackfillInterval: time.Duration(getEnvInt("VARIANTS_BACKFILL_INTERVAL_MIN", 30)) * time.Minute,
+
+		CoWatchFFmpegPath: getEnv("CO_WATCH_FFMPEG_PATH", "ffmpeg"),
+		CoWatchVideoKbps:  getEnvInt("CO_WATCH_VIDEO_BITRATE_KBPS", 1500),
+
+		ServerRecordingEnabled: getEnvBool("SERVER_RECORDING_ENABLED", true),
+
+		WorkingDays:       getEnvSlice("WORKING_DAYS", []string{"mon", "tue", "wed", "thu", "fri"}),
+		WorkingHoursStart: getEnv("WORKING_HOURS_START", "09:00"),
+		WorkingHoursEnd:   getEnv("WORKING_HOURS_END", "18:00"),
+		WorkingTimezone:   getEnv("WORKING_TIMEZONE", "UTC"),
+
+		// Measured from player heartbeats, not from streaming the file
+		RecordingCompletionPercent: getEnvInt("RECORDING_COMPLETION_PERCENT", 90),
+
+		// Recordings older than the cutoff move to a cheaper tier until requested
+		ColdStorageBackend:     getEnv("COLD_STORAGE_BACKEND", ""),
+		ColdStorageDir:         getEnv("COLD_STORAGE_DIR", ""),
+		ColdStorageS3Endpoint:  getEnv("COLD_STORAGE_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		ColdStorageS3Bucket:    getEnv("COLD_STORAGE_S3_BUCKET", ""),
+		ColdStorageS3Region:    getEnv("COLD_STORAGE_S3_REGION", "us-east-1"),
+		ColdStorageS3AccessKey: getEnv("COLD_STORAGE_S3_ACCESS_KEY", ""),
+		ColdStorageS3SecretKey: getEnv("COLD_STORAGE_S3_SECRET_KEY", ""),
+		ColdStorageS3Class:     getEnv("COLD_STORAGE_S3_CLASS", "GLACIER"),
+		ColdStorageRestoreTier: getEnv("COLD_STORAGE_RESTORE_TIER", "Standard"),
+		ColdStorageRestoreDays: getEnvInt("COLD_STORAGE_RESTORE_DAYS", 7),
+		RecordingArchiveAfter:  time.Duration(getEnvInt("RECORDING_ARCHIVE_AFTER_DAYS", 180)) * 24 * time.Hour,
+		ColdStorageInterval:    time.Duration(getEnvInt("COLD_STORAGE_INTERVAL_MIN", 60)) * time.Minute,
+
+		// Players are redirected to signed CDN URLs instead of streaming here
+		CDNBaseURL:    getEnv("CDN_BASE_URL", ""),
+		CDNSigningKey: getEnv("CDN_SIGNING_KEY", ""),
+		CDNURLTTL:     time.Duration(getEnvInt("CDN_URL_TTL_MIN", 240)) * time.Minute,
+
+		// Players stream with per-playback tokens; ?token= only for older clients
+		PlaybackTokenTTL:        time.Duration(getEnvInt("PLAYBACK_TOKEN_TTL_MIN", 15)) * time.Minute,
+		PlaybackTokenPinClient:  getEnvBool("PLAYBACK_TOKEN_PIN_CLIENT", true),
+		StreamLoginTokenInQuery: getEnvBool("STREAM_LOGIN_TOKEN_IN_QUERY", false),
+
+		// Recording and note files encrypted with keys from env or Vault transit
+		StorageEncryption:      getEnv("STORAGE_ENCRYPTION", ""),
+		StorageEncryptionKeys:  getEnv("STORAGE_ENCRYPTION_KEYS", ""),
+		StorageEncryptionKeyID: getEnv("STORAGE_ENCRYPTION_KEY_ID", ""),
+		VaultAddr:              getEnv("VAULT_ADDR", "http://127.0.0.1:8200"),
+		VaultToken:             getEnv("VAULT_TOKEN", ""),
+		VaultTransitKey:        getEnv("VAULT_TRANSIT_KEY", "liveclass-storage"),
+
+		// Users can mute urgent notifications too in their own settings
+		NotifyUrgentCategories: getEnvSlice("NOTIFY_URGENT_CATEGORIES", []string{"class-starting", "class-health"}),
+		NotifyReleaseInterval:  time.Duration(getEnvInt("NOTIFY_RELEASE_INTERVAL_SEC", 60)) * time.Second,
+
+		NotifyClassEmails:       getEnvBool("NOTIFY_CLASS_EMAILS", true),
+		NotifyClassReminderLead: time.Duration(getEnvInt("NOTIFY_CLASS_REMINDER_MIN", 15)) * time.Minute,
+		NotifyClassInterval:     time.Duration(getEnvInt("NOTIFY_CLASS_INTERVAL_SEC", 60)) * time.Second,
+
+		// SMTP for notification emails
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "LiveClass <no-reply@liveclass.local>"),
+
+		// White-label branding defaults; admins can override them at runtime
+		BrandingOrg:         getEnv("BRANDING_ORG", "default"),
+		BrandingPortalName:  getEnv("BRANDING_PORTAL_NAME", "LiveClass"),
+		BrandingEmailFooter: getEnv("BRANDING_EMAIL_FOOTER", ""),
+
+		// Identity verification for proctored classes (provider disabled without a secret)
+		VerificationProvider:      getEnv("VERIFICATION_PROVIDER", "external"),
+		VerificationWebhookSecret: getEnv("VERIFICATION_WEBHOOK_SECRET", ""),
+
+		// Webhook inbox (disabled without secrets)
+		WebhookSecrets:   getEnvSlice("WEBHOOK_SECRETS", nil),
+		WebhookRoutes:    getEnvSlice("WEBHOOK_ROUTES", nil),
+		WebhookTolerance: time.Duration(getEnvInt("WEBHOOK_TOLERANCE_SEC", 300)) * time.Second,
+		WebhookRetention: time.Duration(getEnvInt("WEBHOOK_RETENTION_DAYS", 30)) * 24 * time.Hour,
+
+		// Class health alerts to admins and presenters
+		AlertWindow:               time.Duration(getEnvInt("ALERT_WINDOW_SEC", 60)) * time.Second,
+		AlertViewerDropPercent:    getEnvInt("ALERT_VIEWER_DROP_PERCENT", 50),
+		AlertViewerDropMinViewers: getEnvInt("ALERT_VIEWER_DROP_MIN_VIEWERS", 5),
+		AlertPresenterICEFailures: getEnvInt("ALERT_PRESENTER_ICE_FAILURES", 2),
+		AlertForwardErrorPercent:  getEnvInt("ALERT_FORWARD_ERROR_PERCENT", 5),
+		AlertForwardMinPackets:    getEnvInt("ALERT_FORWARD_MIN_PACKETS", 1000),
+		AlertCooldown:             time.Duration(getEnvInt("ALERT_COOLDOWN_MIN", 10)) * time.Minute,
+		AlertNotifyPresenter:      getEnvBool("ALERT_NOTIFY_PRESENTER", true),
+
+		// API usage per external client
+		APIQuotas:            getEnvCounts("API_QUOTAS", ""),
+		APIQuotaAlertPercent: getEnvInt("API_QUOTA_ALERT_PERCENT", 80),
+
+		// Errors are kept in full; page views are plentiful
+		ClientEventSampleRates: getEnvRates("CLIENT_EVENT_SAMPLE_RATES", "page_view=0.25"),
+		ClientEventsMaxMB:      getEnvInt("CLIENT_EVENTS_MAX_MB", 64),
+
+		// Billing (subscriptions are billed to BRANDING_ORG)
+		BillingEnabled: getEnvBool("BILLING_ENABLED", false),
+		BillingGrace:   time.Duration(getEnvInt("BILLING_GRACE_DAYS", 3)) * 24 * time.Hour,
+
+		// Content moderation
+		ReportHideThreshold: getEnvInt("REPORT_HIDE_THRESHOLD", 3),
+
+		// Caption translation (LibreTranslate-compatible API)
+		TranslationURL:      getEnv("TRANSLATION_URL", ""),
+		TranslationAPIKey:   getEnv("TRANSLATION_API_KEY", ""),
+		TranslationCacheTTL: time.Duration(getEnvInt("TRANSLATION_CACHE_TTL_MIN", 60)) * time.Minute,
+
+		// Quiz generation from transcripts (external endpoint)
+		QuizGenURL:          getEnv("QUIZ_GEN_URL", ""),
+		QuizGenAPIKey:       getEnv("QUIZ_GEN_API_KEY", ""),
+		QuizGenMaxQuestions: getEnvInt("QUIZ_GEN_MAX_QUESTIONS", 10),
+		QuizGenTimeout:      time.Duration(getEnvInt("QUIZ_GEN_TIMEOUT_SEC", 120)) * time.Second,
+
+		// Rich text allowlists
+		SanitizeRichTags:   getEnvSlice("SANITIZE_RICH_TAGS", nil),
+		SanitizeChatTags:   getEnvSlice("SANITIZE_CHAT_TAGS", nil),
+		SanitizeURLSchemes: getEnvSlice("SANITIZE_URL_SCHEMES", nil),
+
+		// Analytics export to a columnar store
+		AnalyticsSink:               getEnv("ANALYTICS_SINK", ""),
+		AnalyticsClickHouseURL:      getEnv("ANALYTICS_CLICKHOUSE_URL", "http://localhost:8123"),
+		AnalyticsClickHouseTable:    getEnv("ANALYTICS_CLICKHOUSE_TABLE", "liveclass_events"),
+		AnalyticsClickHouseUser:     getEnv("ANALYTICS_CLICKHOUSE_USER", ""),
+		AnalyticsClickHousePassword: getEnv("ANALYTICS_CLICKHOUSE_PASSWORD", ""),
+		AnalyticsBigQueryProject:    getEnv("ANALYTICS_BIGQUERY_PROJECT", ""),
+		AnalyticsBigQueryDataset:    getEnv("ANALYTICS_BIGQUERY_DATASET", "liveclass"),
+		AnalyticsBigQueryTable:      getEnv("ANALYTICS_BIGQUERY_TABLE", "events"),
+		AnalyticsBigQueryToken:      getEnv("ANALYTICS_BIGQUERY_TOKEN", ""),
+		AnalyticsBatchSize:          getEnvInt("ANALYTICS_BATCH_SIZE", 500),
+		AnalyticsFlushInterval:      time.Duration(getEnvInt("ANALYTICS_FLUSH_INTERVAL_SEC", 10)) * time.Second,
+		AnalyticsQueueSize:          getEnvInt("ANALYTICS_QUEUE_SIZE", 10000),
+		AnalyticsMaxRetries:         getEnvInt("ANALYTICS_MAX_RETRIES", 5),
+
+		// Development tooling (demo data seeding)
+		DevMode: getEnvBool("DEV_MODE", false),
+
 		// Graceful shutdown
 		ShutdownTimeout: time.Duration(getEnvInt("SHUTDOWN_TIMEOUT_SEC", 30)) * time.Second,
 	}
@@ -132,6 +793,61 @@ func generateInstanceID() string {
 	return hostname + "-" + strconv.FormatInt(time.Now().UnixNano()%10000, 10)
 }
 
+// buildVersion returns the VCS revision the binary was built from, or "dev".
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return setting.Value[:12]
+		}
+	}
+	return "dev"
+}
+
+// TURNRegion is a regional cluster of TURN servers.
+type TURNRegion struct {
+	Name      string
+	URLs      []string
+	Username  string
+	Password  string
+	Lat, Lon  float64
+	HasCoords bool
+	Countries []string
+}
+
+// getTURNRegions reads the regions named in TURN_REGIONS. Each region NAME is
+// configured with TURN_REGION_<NAME>_URLS, _LOCATION ("lat,lon"), _COUNTRIES
+// and optionally _USERNAME/_PASSWORD (defaulting to TURN_USERNAME/PASSWORD).
+// NAME is upper-cased with dashes replaced by underscores.
+func getTURNRegions() []TURNRegion {
+	var regions []TURNRegion
+	for _, name := range getEnvSlice("TURN_REGIONS", nil) {
+		prefix := "TURN_REGION_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		region := TURNRegion{
+			Name:      name,
+			URLs:      getEnvSlice(prefix+"URLS", nil),
+			Username:  getEnv(prefix+"USERNAME", getEnv("TURN_USERNAME", "")),
+			Password:  getEnv(prefix+"PASSWORD", getEnv("TURN_PASSWORD", "")),
+			Countries: getEnvSlice(prefix+"COUNTRIES", nil),
+		}
+		if len(region.URLs) == 0 {
+			continue
+		}
+		if coords := getEnvSlice(prefix+"LOCATION", nil); len(coords) == 2 {
+			lat, latErr := strconv.ParseFloat(coords[0], 64)
+			lon, lonErr := strconv.ParseFloat(coords[1], 64)
+			if latErr == nil && lonErr == nil {
+				region.Lat, region.Lon, region.HasCoords = lat, lon, true
+			}
+		}
+		regions = append(regions, region)
+	}
+	return regions
+}
+
 // getEnvSlice retrieves a comma-separated environment variable as a slice.
 func getEnvSlice(key string, defaultVal []string) []string {
 	if val := os.Getenv(key); val != "" {
@@ -148,6 +864,65 @@ func getEnvSlice(key string, defaultVal []string) []string {
 	return defaultVal
 }
 
+// getEnvSizesGB retrieves a comma-separated list of sizes in GB (e.g. "50,100,250")
+// as bytes. Invalid or non-positive values are skipped.
+func getEnvSizesGB(key string) []int64 {
+	var sizes []int64
+	for _, s := range getEnvSlice(key, nil) {
+		if gb, err := strconv.ParseFloat(s, 64); err == nil && gb > 0 {
+			sizes = append(sizes, int64(gb*(1<<30)))
+		}
+	}
+	return sizes
+}
+
+// getEnvInts retrieves a comma-separated list of positive integers or
+// returns a default value.
+func getEnvInts(key string, defaultVal []int) []int {
+	var result []int
+	for _, s := range getEnvSlice(key, nil) {
+		if i, err := strconv.Atoi(s); err == nil && i > 0 {
+			result = append(result, i)
+		}
+	}
+	if len(result) == 0 {
+		return defaultVal
+	}
+	return result
+}
+
+// getEnvRates parses "key=rate" pairs, e.g. "/api/health=0.01", skipping
+// rates outside 0-1.
+func getEnvRates(key, defaultVal string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, pair := range splitAndTrim(getEnv(key, defaultVal), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if rate, err := strconv.ParseFloat(stringsTrim(value), 64); err == nil && rate >= 0 && rate <= 1 {
+			rates[stringsTrim(name)] = rate
+		}
+	}
+	return rates
+}
+
+// getEnvCounts parses "key=count" pairs, e.g. "webhook:lms=10000",
+// skipping counts that aren't positive.
+func getEnvCounts(key, defaultVal string) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, pair := range splitAndTrim(getEnv(key, defaultVal), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if count, err := strconv.ParseInt(stringsTrim(value), 10, 64); err == nil && count > 0 {
+			counts[stringsTrim(name)] = count
+		}
+	}
+	return counts
+}
+
 // splitAndTrim splits a string and trims whitespace from each part.
 func splitAndTrim(s, sep string) []string {
 	parts := make([]string, 0)
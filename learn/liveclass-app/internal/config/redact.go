@@ -0,0 +1,116 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces a secret that is set.
+const redacted = "[redacted]"
+
+// secretSuffixes mark the fields holding credentials or keys.
+var secretSuffixes = []string{"Password", "Secret", "Secrets", "SecretKey", "AccessKey", "APIKey", "SigningKey", "EncryptionKeys", "Token"}
+
+// Setting is one field of the effective configuration.
+type Setting struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// Redacted returns the configuration in field order, so related settings
+// stay together, with secrets replaced and passwords removed from URLs.
+// Durations are given as strings such as "1m30s".
+func (c *Config) Redacted() []Setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	settings := make([]Setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		settings = append(settings, Setting{Name: name, Value: redactValue(name, v.Field(i).Interface())})
+	}
+	return settings
+}
+
+// redactValue returns a field's value fit to show.
+func redactValue(name string, value interface{}) interface{} {
+	switch val := value.(type) {
+	case time.Duration:
+		return val.String()
+	case []TURNRegion:
+		regions := make([]TURNRegion, len(val))
+		for i, region := range val {
+			region.Password = redactSecret(region.Password)
+			regions[i] = region
+		}
+		return regions
+	}
+
+	if !isSecret(name) {
+		if s, ok := value.(string); ok && (strings.HasSuffix(name, "URI") || strings.HasSuffix(name, "URL") || strings.HasSuffix(name, "Addr")) {
+			return redactURL(s)
+		}
+		return value
+	}
+
+	switch val := value.(type) {
+	case string:
+		return redactSecret(val)
+	case []string:
+		// "name=secret" entries keep their name
+		entries := make([]string, len(val))
+		for i, entry := range val {
+			if key, _, ok := strings.Cut(entry, "="); ok {
+				entries[i] = key + "=" + redacted
+			} else {
+				entries[i] = redacted
+			}
+		}
+		return entries
+	}
+	return redacted
+}
+
+// isSecret reports whether the named field holds a secret.
+func isSecret(name string) bool {
+	for _, suffix := range secretSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecret hides a secret, leaving it empty when unset so a missing
+// secret still shows.
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL removes the password from a URL, and the values of query
+// parameters that look like credentials. Unparsable URLs are hidden entirely.
+func redactURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return redacted
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			lower := strings.ToLower(key)
+			if strings.Contains(lower, "pass") || strings.Contains(lower, "secret") || strings.Contains(lower, "token") || strings.Contains(lower, "key") {
+				query.Set(key, "xxxxx")
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
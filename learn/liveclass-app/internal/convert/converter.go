@@ -0,0 +1,141 @@
+// Package convert makes PDF copies of Word and PowerPoint notes, so clients
+// can preview every document the way they preview PDFs.
+package convert
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Converter converts office documents to PDF.
+type Converter interface {
+	// Name identifies the converter in logs.
+	Name() string
+	// Convert writes a PDF of the plaintext document at path to dst. The
+	// path's extension tells the document's format.
+	Convert(ctx context.Context, path string, dst io.Writer) error
+}
+
+// LibreOffice converts with a local headless LibreOffice.
+type LibreOffice struct {
+	soffice string
+}
+
+// NewLibreOffice creates a converter running the soffice binary at path.
+func NewLibreOffice(path string) *LibreOffice {
+	return &LibreOffice{soffice: path}
+}
+
+// Name returns the converter name.
+func (c *LibreOffice) Name() string {
+	return "libreoffice"
+}
+
+// Available reports whether the soffice binary can be found.
+func (c *LibreOffice) Available() bool {
+	_, err := exec.LookPath(c.soffice)
+	return err == nil
+}
+
+// Convert runs soffice with a profile of its own, since concurrent runs
+// sharing one fail.
+func (c *LibreOffice) Convert(ctx context.Context, path string, dst io.Writer) error {
+	dir, err := os.MkdirTemp("", "convert-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.CommandContext(ctx, c.soffice,
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(dir, "profile")),
+		"--headless", "--norestore",
+		"--convert-to", "pdf",
+		"--outdir", dir,
+		path,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("soffice: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	out, err := os.Open(filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".pdf"))
+	if err != nil {
+		return fmt.Errorf("soffice wrote no PDF: %s", strings.TrimSpace(stderr.String()))
+	}
+	defer out.Close()
+
+	_, err = io.Copy(dst, out)
+	return err
+}
+
+// Gotenberg converts through a Gotenberg-compatible HTTP service.
+type Gotenberg struct {
+	url    string
+	client *http.Client
+}
+
+// NewGotenberg creates a converter for the service at baseURL.
+func NewGotenberg(baseURL string, timeout time.Duration) *Gotenberg {
+	return &Gotenberg{
+		url:    strings.TrimSuffix(baseURL, "/") + "/forms/libreoffice/convert",
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the converter name.
+func (c *Gotenberg) Name() string {
+	return "gotenberg"
+}
+
+// Convert uploads the document to the LibreOffice route. The upload is
+// streamed, so large decks aren't held in memory.
+func (c *Gotenberg) Convert(ctx context.Context, path string, dst io.Writer) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	body, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		part, err := form.CreateFormFile("files", filepath.Base(path))
+		if err == nil {
+			_, err = io.Copy(part, src)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("convert failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
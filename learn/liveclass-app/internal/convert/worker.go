@@ -0,0 +1,215 @@
+package convert
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Limits of PDF conversion
+const (
+	queueSize      = 128
+	backfillMax    = 50 // Documents converted per backfill pass
+	convertTimeout = 5 * time.Minute
+	staleClaim     = 30 * time.Minute // Conversions claimed this long ago are retried
+)
+
+// Worker converts document notes to PDF in the background: right after
+// upload, and in periodic passes that pick up documents uploaded before
+// conversion existed, missed while the queue was full or interrupted.
+//
+// Notes are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Worker struct {
+	noteRepo  *repository.NoteRepository
+	converter Converter
+	files     *encryption.Encryptor // nil stores files in plaintext
+	interval  time.Duration
+	queue     chan *models.Note
+}
+
+// NewWorker creates a worker with a backfill pass every interval (0 for
+// none). A nil converter disables it.
+func NewWorker(noteRepo *repository.NoteRepository, converter Converter, files *encryption.Encryptor, interval time.Duration) *Worker {
+	return &Worker{
+		noteRepo:  noteRepo,
+		converter: converter,
+		files:     files,
+		interval:  interval,
+		queue:     make(chan *models.Note, queueSize),
+	}
+}
+
+// Enabled reports whether a converter is configured.
+func (w *Worker) Enabled() bool {
+	return w.converter != nil
+}
+
+// Enqueue schedules conversion of a newly uploaded note. Other file types
+// are ignored. It never blocks; if the queue is full the next backfill pass
+// picks the note up.
+func (w *Worker) Enqueue(note *models.Note) {
+	if w.converter == nil || !note.Convertible() {
+		return
+	}
+	select {
+	case w.queue <- note:
+	default:
+		log.Printf("[Convert] Queue full, %s left for the next pass", note.ID.Hex())
+	}
+}
+
+// Run converts queued notes, and any documents still without a PDF
+// immediately and then every interval, until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	if w.converter == nil {
+		return
+	}
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		w.backfill(ctx)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case note := <-w.queue:
+			w.process(ctx, note)
+		case <-tick:
+			w.backfill(ctx)
+		}
+	}
+}
+
+// backfill converts documents that don't have a PDF yet.
+func (w *Worker) backfill(ctx context.Context) {
+	findCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	notes, err := w.noteRepo.FindUnconverted(findCtx, time.Now().Add(-staleClaim), backfillMax)
+	cancel()
+	if err != nil {
+		log.Printf("[Convert] Failed to load documents without a PDF: %v", err)
+		return
+	}
+	for _, note := range notes {
+		if ctx.Err() != nil {
+			return
+		}
+		w.process(ctx, note)
+	}
+}
+
+// process claims a note, converts it and records the outcome.
+func (w *Worker) process(ctx context.Context, note *models.Note) {
+	ctx, cancel := context.WithTimeout(ctx, convertTimeout)
+	defer cancel()
+
+	claimed, err := w.noteRepo.ClaimPDF(ctx, note.ID, time.Now().Add(-staleClaim))
+	if err != nil {
+		log.Printf("[Convert] Failed to claim %s: %v", note.ID.Hex(), err)
+		return
+	}
+	if !claimed {
+		return // Handled by another instance
+	}
+
+	started := time.Now()
+	pdf := models.NotePDF{Status: models.NotePDFReady, ClaimedAt: started}
+	pdf.FilePath = strings.TrimSuffix(note.FilePath, filepath.Ext(note.FilePath)) + "_preview.pdf"
+	pdf.FileSize, err = w.convert(ctx, note, pdf.FilePath)
+	if err != nil {
+		// Failed conversions aren't retried, so a broken document isn't
+		// converted every pass
+		log.Printf("[Convert] No PDF for %s (%s): %v", note.Title, note.ID.Hex(), err)
+		pdf = models.NotePDF{Status: models.NotePDFFailed, Error: "The document couldn't be converted", ClaimedAt: started}
+	}
+	now := time.Now()
+	pdf.ConvertedAt = &now
+
+	// Saved with a fresh context, so a timed-out conversion is still marked failed
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer saveCancel()
+	if err := w.noteRepo.SetPDF(saveCtx, note.ID, pdf); err != nil {
+		log.Printf("[Convert] Failed to save PDF of %s: %v", note.ID.Hex(), err)
+		if pdf.FilePath != "" {
+			os.Remove(pdf.FilePath)
+		}
+		return
+	}
+	if pdf.Status == models.NotePDFReady {
+		log.Printf("[Convert] Converted %s with %s in %v", note.Title, w.converter.Name(), time.Since(started).Round(time.Millisecond))
+	}
+}
+
+// convert writes the PDF of a note to path, encrypted when enabled, and
+// returns its size. Converters can't read encrypted files and need the
+// document's extension, so the upload is decrypted to a temporary file
+// named like the original.
+func (w *Worker) convert(ctx context.Context, note *models.Note, path string) (int64, error) {
+	src, err := w.files.Open(ctx, note.FilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dir, err := os.MkdirTemp("", "convert-src-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	ext := strings.ToLower(filepath.Ext(note.FileName))
+	if ext == "" {
+		ext = filepath.Ext(note.FilePath)
+	}
+	tmp, err := os.Create(filepath.Join(dir, "document"+ext))
+	if err != nil {
+		return 0, err
+	}
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	dst, err := w.files.Create(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	counter := &countingWriter{w: dst}
+	err = w.converter.Convert(ctx, tmp.Name(), counter)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// countingWriter counts the plaintext bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
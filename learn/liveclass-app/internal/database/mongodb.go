@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -15,8 +16,19 @@ import (
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+
+	// Same database with the read preference for heavy list and report
+	// queries; Database when those stay on the primary
+	reports *mongo.Database
+
+	// Budgets of single repository operations (0 for none)
+	readTimeout  time.Duration
+	writeTimeout time.Duration
 }
 
+// minMaxStaleness is the smallest max staleness MongoDB accepts.
+const minMaxStaleness = 90 * time.Second
+
 // ConnectionConfig holds MongoDB connection pool settings.
 type ConnectionConfig struct {
 	URI                    string
@@ -28,6 +40,22 @@ type ConnectionConfig struct {
 	ServerSelectionTimeout time.Duration
 	SocketTimeout          time.Duration
 	MaxConnecting          uint64
+
+	// Read preference mode for report reads (see ReportCollection), e.g.
+	// "secondaryPreferred"; empty or "primary" keeps them on the primary.
+	// ReportMaxStaleness skips secondaries lagging further behind (0 for no
+	// limit, otherwise at least 90s).
+	ReportReadPreference string
+	ReportMaxStaleness   time.Duration
+
+	// Longest a single read or write operation may take, so a stalled
+	// query ends before the request that issued it (0 for no limit; see
+	// ReadContext and WriteContext)
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// Observes every command sent, e.g. to log slow queries (optional)
+	Monitor *event.CommandMonitor
 }
 
 // DefaultConnectionConfig returns optimized default connection settings.
@@ -42,6 +70,8 @@ func DefaultConnectionConfig(uri, dbName string) *ConnectionConfig {
 		ServerSelectionTimeout: 5 * time.Second,  // Fast server selection
 		SocketTimeout:          30 * time.Second, // Socket timeout
 		MaxConnecting:          10,               // Maximum concurrent connecting
+		ReadTimeout:            10 * time.Second, // Budget of a single read
+		WriteTimeout:           10 * time.Second, // Budget of a single write
 	}
 }
 
@@ -52,6 +82,11 @@ func NewMongoDB(uri, dbName string) (*MongoDB, error) {
 
 // NewMongoDBWithConfig creates a new MongoDB connection with custom settings.
 func NewMongoDBWithConfig(cfg *ConnectionConfig) (*MongoDB, error) {
+	reportPref, err := reportReadPref(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
@@ -70,6 +105,9 @@ func NewMongoDBWithConfig(cfg *ConnectionConfig) (*MongoDB, error) {
 		// Use direct connection for single server setups (faster)
 		SetRetryWrites(true).
 		SetRetryReads(true)
+	if cfg.Monitor != nil {
+		clientOpts.SetMonitor(cfg.Monitor)
+	}
 
 	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
@@ -81,10 +119,41 @@ func NewMongoDBWithConfig(cfg *ConnectionConfig) (*MongoDB, error) {
 		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
-	return &MongoDB{
-		Client:   client,
-		Database: client.Database(cfg.DBName),
-	}, nil
+	db := &MongoDB{
+		Client:       client,
+		Database:     client.Database(cfg.DBName),
+		readTimeout:  cfg.ReadTimeout,
+		writeTimeout: cfg.WriteTimeout,
+	}
+	db.reports = db.Database
+	if reportPref != nil {
+		db.reports = client.Database(cfg.DBName, options.Database().SetReadPreference(reportPref))
+	}
+	return db, nil
+}
+
+// reportReadPref returns the read preference for report reads, or nil when
+// they stay on the primary.
+func reportReadPref(cfg *ConnectionConfig) (*readpref.ReadPref, error) {
+	if cfg.ReportReadPreference == "" {
+		return nil, nil
+	}
+	mode, err := readpref.ModeFromString(cfg.ReportReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report read preference %q", cfg.ReportReadPreference)
+	}
+	if mode == readpref.PrimaryMode {
+		return nil, nil
+	}
+
+	var opts []readpref.Option
+	if cfg.ReportMaxStaleness > 0 {
+		if cfg.ReportMaxStaleness < minMaxStaleness {
+			return nil, fmt.Errorf("report max staleness must be at least %v", minMaxStaleness)
+		}
+		opts = append(opts, readpref.WithMaxStaleness(cfg.ReportMaxStaleness))
+	}
+	return readpref.New(mode, opts...)
 }
 
 // Close disconnects from MongoDB.
@@ -99,6 +168,37 @@ func (m *MongoDB) Collection(name string) *mongo.Collection {
 	return m.Database.Collection(name)
 }
 
+// ReportCollection returns a collection for heavy list and report queries,
+// which may be served by a secondary and so lag behind recent writes. Writes,
+// auth lookups and reads that must see a write just made use Collection.
+func (m *MongoDB) ReportCollection(name string) *mongo.Collection {
+	if m.reports == nil {
+		return m.Collection(name)
+	}
+	return m.reports.Collection(name)
+}
+
+// ReadContext returns a context for a single read operation, cancelled
+// after the read budget or when ctx is. Callers must call cancel once the
+// results are consumed.
+func (m *MongoDB) ReadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withBudget(ctx, m.readTimeout)
+}
+
+// WriteContext returns a context for a single write operation, cancelled
+// after the write budget or when ctx is.
+func (m *MongoDB) WriteContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withBudget(ctx, m.writeTimeout)
+}
+
+// withBudget limits ctx to budget, if set.
+func withBudget(ctx context.Context, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, budget)
+}
+
 // HealthCheck performs a quick database health check.
 func (m *MongoDB) HealthCheck(ctx context.Context) error {
 	return m.Client.Ping(ctx, readpref.Primary())
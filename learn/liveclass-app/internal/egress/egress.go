@@ -0,0 +1,257 @@
+// Package egress re-streams live classes to external RTMP and SRT targets,
+// such as an unlisted YouTube broadcast for students who don't fit in the
+// class. The presenter's media is handed to ffmpeg, which encodes it for
+// the target.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// States of a re-stream
+const (
+	StateStarting = "starting"
+	StateLive     = "live"
+	StateStopped  = "stopped"
+	StateFailed   = "failed"
+)
+
+var (
+	// ErrUnavailable is returned when re-streaming isn't configured.
+	ErrUnavailable = errors.New("re-streaming is not available")
+	// ErrInvalidTarget is returned for targets that aren't rtmp(s):// or srt:// URLs.
+	ErrInvalidTarget = errors.New("target must be an rtmp://, rtmps:// or srt:// URL")
+	// ErrTargetNotAllowed is returned for targets on hosts that aren't allowed.
+	ErrTargetNotAllowed = errors.New("target host is not allowed")
+	// ErrNoAllowedHosts is returned for a manager running ffmpeg without
+	// hosts to allow, which would let it publish anywhere.
+	ErrNoAllowedHosts = errors.New("re-streaming needs the hosts targets may be on")
+	// ErrRunning is returned when a room is already being re-streamed.
+	ErrRunning = errors.New("room is already being re-streamed")
+	// ErrNotRunning is returned when a room isn't being re-streamed.
+	ErrNotRunning = errors.New("room is not being re-streamed")
+)
+
+// Status is the state of a room's latest re-stream.
+type Status struct {
+	RoomID    string     `json:"roomId"`
+	Target    string     `json:"target"` // Without its stream key
+	State     string     `json:"state"`
+	StartedBy string     `json:"startedBy"`
+	StartedAt time.Time  `json:"startedAt"`
+	LiveAt    *time.Time `json:"liveAt,omitempty"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	Reason    string     `json:"reason,omitempty"` // Why it stopped
+	Error     string     `json:"error,omitempty"`  // Why it failed
+}
+
+// Active reports whether the re-stream is starting or live.
+func (s Status) Active() bool {
+	return s.State == StateStarting || s.State == StateLive
+}
+
+// Manager runs a room's re-stream, at most one per room.
+type Manager struct {
+	ffmpeg       string
+	videoKbps    int
+	allowedHosts []string
+	keyframe     func(*room.Room) error
+
+	mu       sync.Mutex
+	sessions map[string]*session // By room ID, kept after they end for their status
+}
+
+// NewManager creates a manager running the ffmpeg binary at path, encoding
+// video at videoKbps. Targets must be on one of allowedHosts, which can only
+// be empty when path is, with re-streaming off.
+func NewManager(path string, videoKbps int, allowedHosts []string) (*Manager, error) {
+	if path != "" && len(allowedHosts) == 0 {
+		return nil, ErrNoAllowedHosts
+	}
+	return &Manager{
+		ffmpeg:       path,
+		videoKbps:    videoKbps,
+		allowedHosts: allowedHosts,
+		sessions:     make(map[string]*session),
+	}, nil
+}
+
+// SetKeyframeRequester sets how a room's presenter is asked for a keyframe,
+// so the encoder can start without waiting for one. Set it before starting
+// any re-stream.
+func (m *Manager) SetKeyframeRequester(keyframe func(*room.Room) error) {
+	m.keyframe = keyframe
+}
+
+// Available reports whether the ffmpeg binary can be found.
+func (m *Manager) Available() bool {
+	if m == nil || m.ffmpeg == "" {
+		return false
+	}
+	_, err := exec.LookPath(m.ffmpeg)
+	return err == nil
+}
+
+// Start re-streams a live room to target for the user startedBy.
+func (m *Manager) Start(r *room.Room, target, startedBy string) (Status, error) {
+	if !m.Available() {
+		return Status{}, ErrUnavailable
+	}
+	format, err := m.checkTarget(target)
+	if err != nil {
+		return Status{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current, ok := m.sessions[r.ID]; ok && current.status().Active() {
+		return current.status(), ErrRunning
+	}
+	s, err := newSession(m, r, target, format, startedBy)
+	if err != nil {
+		return Status{}, err
+	}
+	m.sessions[r.ID] = s
+	go s.run()
+
+	log.Printf("[Egress] Re-streaming room %s to %s for %s", r.ID, s.status().Target, startedBy)
+	return s.status(), nil
+}
+
+// Stop stops a room's re-stream, for reason, and returns its final status.
+func (m *Manager) Stop(roomID, reason string) (Status, error) {
+	m.mu.Lock()
+	s, ok := m.sessions[roomID]
+	m.mu.Unlock()
+
+	if !ok || !s.status().Active() {
+		return Status{}, ErrNotRunning
+	}
+	return s.stop(reason), nil
+}
+
+// Status returns the state of a room's latest re-stream.
+func (m *Manager) Status(roomID string) (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[roomID]
+	if !ok {
+		return Status{}, false
+	}
+	return s.status(), true
+}
+
+// End stops the re-stream of a room session because the class ended, and
+// forgets it. Re-streams of a newer room with the same ID are left alone.
+func (m *Manager) End(roomID, sessionID string) {
+	m.mu.Lock()
+	s, ok := m.sessions[roomID]
+	if ok && s.room.SessionID == sessionID {
+		delete(m.sessions, roomID)
+	} else {
+		ok = false
+	}
+	m.mu.Unlock()
+
+	if ok && s.status().Active() {
+		s.stop("class ended")
+	}
+}
+
+// StopAll stops every re-stream, for shutdown.
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	sessions := make([]*session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		sessions = append(sessions, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		if s.status().Active() {
+			s.stop("server shutting down")
+		}
+	}
+}
+
+// checkTarget checks that target is a URL ffmpeg can publish to and
+// returns the container it takes.
+func (m *Manager) checkTarget(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Hostname() == "" {
+		return "", ErrInvalidTarget
+	}
+	var format string
+	switch u.Scheme {
+	case "rtmp", "rtmps":
+		format = "flv"
+	case "srt":
+		format = "mpegts"
+	default:
+		return "", ErrInvalidTarget
+	}
+
+	host := strings.ToLower(u.Hostname())
+	allowed := false
+	for _, h := range m.allowedHosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed || !publicHost(host) {
+		return "", fmt.Errorf("%w: %s", ErrTargetNotAllowed, host)
+	}
+	return format, nil
+}
+
+// publicHost reports whether host resolves only to public addresses, so an
+// allowed domain pointed at the server's own network is still refused.
+func publicHost(host string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		ip := addr.IP
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+			return false
+		}
+	}
+	return true
+}
+
+// redactTarget hides the secrets in a target: the stream key, the last
+// segment of an RTMP path, and the query of an SRT URL.
+func redactTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	if u.Scheme != "srt" {
+		if i := strings.LastIndex(u.Path, "/"); i >= 0 && i < len(u.Path)-1 {
+			u.Path = u.Path[:i] + "/****"
+		}
+	}
+	return u.String()
+}
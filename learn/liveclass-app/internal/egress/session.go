@@ -0,0 +1,330 @@
+package egress
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+const (
+	// liveAfter is how long ffmpeg must keep running for a re-stream to
+	// count as live; bad targets and keys fail well within it.
+	liveAfter = 5 * time.Second
+	// stopWait is how long ffmpeg gets to flush the target after being
+	// interrupted before it is killed.
+	stopWait = 10 * time.Second
+	// stderrTail is how much of ffmpeg's output is kept to explain a failure.
+	stderrTail = 1024
+)
+
+// Payload types of the presenter's media as ffmpeg is told to expect it;
+// the presenter's negotiated ones are rewritten to these.
+const (
+	videoPayloadType = 96  // VP8
+	audioPayloadType = 111 // Opus
+)
+
+// session is one re-stream of a room. The presenter's RTP is sent to
+// ffmpeg over local UDP, described to it by an SDP.
+type session struct {
+	manager *Manager
+	room    *room.Room
+	target  string
+	format  string // ffmpeg output container
+	tapID   string // Among the room's media taps
+
+	video *rtpSender
+	audio *rtpSender
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	state  Status
+	reason string // Set when stopped on purpose
+}
+
+func newSession(m *Manager, r *room.Room, target, format, startedBy string) (*session, error) {
+	video, err := newRTPSender(videoPayloadType)
+	if err != nil {
+		return nil, err
+	}
+	audio, err := newRTPSender(audioPayloadType)
+	if err != nil {
+		video.close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startedAt := time.Now()
+	return &session{
+		manager: m,
+		room:    r,
+		target:  target,
+		format:  format,
+		tapID:   "egress:" + startedAt.Format(time.RFC3339Nano),
+		video:   video,
+		audio:   audio,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		state: Status{
+			RoomID:    r.ID,
+			Target:    redactTarget(target),
+			State:     StateStarting,
+			StartedBy: startedBy,
+			StartedAt: startedAt,
+		},
+	}, nil
+}
+
+// WriteRTP sends a packet of the presenter's media to ffmpeg.
+func (s *session) WriteRTP(video bool, pkt []byte) {
+	if video {
+		s.video.write(pkt)
+	} else {
+		s.audio.write(pkt)
+	}
+}
+
+// status returns a copy of the session's state.
+func (s *session) status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// stop interrupts ffmpeg, waits for it to finish and returns the final
+// state.
+func (s *session) stop(reason string) Status {
+	s.mu.Lock()
+	if s.reason == "" {
+		s.reason = reason
+	}
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.done
+	return s.status()
+}
+
+// run runs ffmpeg until it exits or the session is stopped.
+func (s *session) run() {
+	defer close(s.done)
+	defer s.video.close()
+	defer s.audio.close()
+
+	tail := &tailWriter{max: stderrTail}
+	cmd := exec.CommandContext(s.ctx, s.manager.ffmpeg, s.args()...)
+	cmd.Stdin = strings.NewReader(s.sdp())
+	cmd.Stderr = tail
+	// Interrupted rather than killed, so the target gets a clean end of stream
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = stopWait
+
+	if err := cmd.Start(); err != nil {
+		s.finish(fmt.Errorf("start ffmpeg: %w", err), "")
+		return
+	}
+
+	s.room.AddMediaTap(s.tapID, s)
+	defer s.room.RemoveMediaTap(s.tapID)
+
+	live := time.AfterFunc(liveAfter, func() {
+		s.mu.Lock()
+		if s.state.State == StateStarting {
+			now := time.Now()
+			s.state.State = StateLive
+			s.state.LiveAt = &now
+		}
+		s.mu.Unlock()
+		s.requestKeyframe()
+	})
+	defer live.Stop()
+	// Until ffmpeg sees a keyframe it can't decode the video
+	time.AfterFunc(time.Second, s.requestKeyframe)
+
+	err := cmd.Wait()
+	s.finish(err, tail.String())
+}
+
+// finish records how the session ended: stopped when asked to, failed
+// otherwise.
+func (s *session) finish(err error, output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.state.EndedAt = &now
+	if s.reason != "" {
+		s.state.State = StateStopped
+		s.state.Reason = s.reason
+		log.Printf("[Egress] Stopped re-streaming room %s: %s", s.room.ID, s.reason)
+		return
+	}
+
+	s.state.State = StateFailed
+	s.state.Error = strings.TrimSpace(output)
+	if s.state.Error == "" {
+		s.state.Error = "ffmpeg exited"
+		if err != nil {
+			s.state.Error = err.Error()
+		}
+	}
+	log.Printf("[Egress] ❌ Re-stream of room %s failed: %s", s.room.ID, s.state.Error)
+}
+
+// requestKeyframe asks the presenter for a keyframe, unless the session
+// has ended.
+func (s *session) requestKeyframe() {
+	if s.ctx.Err() != nil || s.manager.keyframe == nil {
+		return
+	}
+	if err := s.manager.keyframe(s.room); err != nil {
+		log.Printf("[Egress] Couldn't request a keyframe in room %s: %v", s.room.ID, err)
+	}
+}
+
+// sdp describes the presenter's media as sent to ffmpeg.
+func (s *session) sdp() string {
+	return strings.Join([]string{
+		"v=0",
+		"o=- 0 0 IN IP4 127.0.0.1",
+		"s=" + s.room.ID,
+		"c=IN IP4 127.0.0.1",
+		"t=0 0",
+		fmt.Sprintf("m=video %d RTP/AVP %d", s.video.port, videoPayloadType),
+		fmt.Sprintf("a=rtpmap:%d VP8/90000", videoPayloadType),
+		fmt.Sprintf("m=audio %d RTP/AVP %d", s.audio.port, audioPayloadType),
+		fmt.Sprintf("a=rtpmap:%d opus/48000/2", audioPayloadType),
+		"",
+	}, "\r\n")
+}
+
+// args are ffmpeg's arguments: the SDP on stdin in, H.264 and AAC out, as
+// broadcast platforms expect.
+func (s *session) args() []string {
+	kbps := s.manager.videoKbps
+	return []string{
+		"-hide_banner", "-nostats", "-loglevel", "error",
+		"-protocol_whitelist", "pipe,udp,rtp",
+		"-f", "sdp", "-i", "pipe:0",
+		"-c:v", "libx264", "-preset", "veryfast", "-tune", "zerolatency",
+		"-pix_fmt", "yuv420p", "-g", "60",
+		"-b:v", strconv.Itoa(kbps) + "k",
+		"-maxrate", strconv.Itoa(kbps) + "k",
+		"-bufsize", strconv.Itoa(2*kbps) + "k",
+		"-c:a", "aac", "-b:a", "128k", "-ar", "44100",
+		"-f", s.format, s.target,
+	}
+}
+
+// rtpSender sends RTP packets to a local UDP port ffmpeg listens on.
+type rtpSender struct {
+	port        int
+	payloadType byte
+
+	mu   sync.Mutex
+	conn *net.UDPConn
+	buf  []byte
+}
+
+// newRTPSender finds a free port for ffmpeg to listen on, along with the
+// one after it, which ffmpeg takes for RTCP.
+func newRTPSender(payloadType byte) (*rtpSender, error) {
+	port, err := freePortPair()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	if err != nil {
+		return nil, err
+	}
+	return &rtpSender{port: port, payloadType: payloadType, conn: conn, buf: make([]byte, 1500)}, nil
+}
+
+// write sends a copy of pkt with its payload type rewritten. Packets sent
+// before ffmpeg listens are lost, which is fine for a live stream.
+func (s *rtpSender) write(pkt []byte) {
+	if len(pkt) < 12 || len(pkt) > len(s.buf) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return
+	}
+	n := copy(s.buf, pkt)
+	s.buf[1] = s.buf[1]&0x80 | s.payloadType // Keep the marker bit
+	_, _ = s.conn.Write(s.buf[:n])
+}
+
+func (s *rtpSender) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// freePortPair returns an even local UDP port that is free along with the
+// one after it.
+func freePortPair() (int, error) {
+	for i := 0; i < 20; i++ {
+		rtp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			return 0, err
+		}
+		port := rtp.LocalAddr().(*net.UDPAddr).Port
+		if port%2 != 0 {
+			rtp.Close()
+			continue
+		}
+		rtcp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port + 1})
+		rtp.Close()
+		if err != nil {
+			continue
+		}
+		rtcp.Close()
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free UDP port pair for ffmpeg")
+}
+
+// tailWriter keeps the last max bytes written to it.
+type tailWriter struct {
+	max int
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.buf)
+}
@@ -0,0 +1,383 @@
+// Package encryption encrypts stored files at rest with AES-256-GCM.
+//
+// Each file has its own random data key, wrapped with a master key from a
+// KeyProvider and stored in the file header. The content is sealed in
+// fixed-size chunks so any byte range can be decrypted without reading the
+// whole file, which keeps video seeking cheap. Rotating a master key only
+// rewrites file headers.
+//
+// Layout:
+//
+//	"LCE\x01" | key ID length (1) | key ID | wrapped key length (2) | wrapped key | chunks...
+//
+// Chunk i seals up to chunkSize bytes with nonce i; its additional data
+// marks the last chunk, so truncated files fail to decrypt.
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	chunkSize  = 64 * 1024
+	tagSize    = 16
+	sealedSize = chunkSize + tagSize
+)
+
+// magic starts every encrypted file.
+var magic = []byte("LCE\x01")
+
+var (
+	// ErrNotConfigured is returned when opening an encrypted file without keys.
+	ErrNotConfigured = errors.New("file is encrypted but encryption is not configured")
+	// ErrCorrupt is returned for encrypted files with an invalid layout.
+	ErrCorrupt = errors.New("encrypted file is corrupt")
+)
+
+// File is a stored file opened for reading, encrypted or not.
+type File interface {
+	io.ReadSeekCloser
+	// Size returns the plaintext size.
+	Size() int64
+	ModTime() time.Time
+}
+
+// Encryptor creates and opens stored files. A nil Encryptor writes
+// plaintext; files are read transparently whether they are encrypted or not,
+// so enabling encryption needs no migration.
+type Encryptor struct {
+	keys KeyProvider
+}
+
+// New creates an encryptor wrapping data keys with keys.
+func New(keys KeyProvider) *Encryptor {
+	return &Encryptor{keys: keys}
+}
+
+// Provider returns the name of the key provider, or "" when disabled.
+func (e *Encryptor) Provider() string {
+	if e == nil {
+		return ""
+	}
+	return e.keys.Name()
+}
+
+// CurrentKeyID returns the ID of the master key used for new files.
+func (e *Encryptor) CurrentKeyID(ctx context.Context) (string, error) {
+	if e == nil {
+		return "", ErrNotConfigured
+	}
+	return e.keys.CurrentKeyID(ctx)
+}
+
+// Create creates the file at path for writing, encrypted when enabled. The
+// file is complete once Close returns without error.
+func (e *Encryptor) Create(ctx context.Context, path string) (io.WriteCloser, error) {
+	if e == nil {
+		return os.Create(path)
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := e.keys.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	header, err := encodeHeader(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &writer{f: f, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// Open opens the file at path for reading, decrypting it if it is encrypted.
+func (e *Encryptor) Open(ctx context.Context, path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	h, err := readHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if h == nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &plainFile{File: f, stat: stat}, nil
+	}
+	if e == nil {
+		f.Close()
+		return nil, ErrNotConfigured
+	}
+
+	dataKey, err := e.keys.Unwrap(ctx, h.keyID, h.wrapped)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	body := stat.Size() - h.length
+	if body < tagSize {
+		f.Close()
+		return nil, ErrCorrupt
+	}
+	chunks := (body + sealedSize - 1) / sealedSize
+	return &reader{
+		f:          f,
+		aead:       aead,
+		dataStart:  h.length,
+		chunks:     chunks,
+		size:       body - chunks*tagSize,
+		modTime:    stat.ModTime(),
+		chunkIndex: -1,
+	}, nil
+}
+
+// KeyID returns the master key ID of an encrypted file, and false for a
+// plaintext file.
+func KeyID(path string) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	h, err := readHeader(f)
+	if err != nil || h == nil {
+		return "", false, err
+	}
+	return h.keyID, true, nil
+}
+
+// header is the decoded header of an encrypted file.
+type header struct {
+	keyID   string
+	wrapped []byte
+	length  int64 // Encoded length in bytes
+}
+
+// encodeHeader encodes a file header.
+func encodeHeader(keyID string, wrapped []byte) ([]byte, error) {
+	if len(keyID) == 0 || len(keyID) > 255 || len(wrapped) > 65535 {
+		return nil, fmt.Errorf("key ID or wrapped key too long")
+	}
+	var b bytes.Buffer
+	b.Write(magic)
+	b.WriteByte(byte(len(keyID)))
+	b.WriteString(keyID)
+	binary.Write(&b, binary.BigEndian, uint16(len(wrapped)))
+	b.Write(wrapped)
+	return b.Bytes(), nil
+}
+
+// readHeader reads the header at the start of f, returning nil for a
+// plaintext file.
+func readHeader(f io.Reader) (*header, error) {
+	prefix := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(f, prefix); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil // Too short to be encrypted
+		}
+		return nil, err
+	}
+	if !bytes.Equal(prefix[:len(magic)], magic) {
+		return nil, nil
+	}
+
+	keyID := make([]byte, prefix[len(magic)])
+	var wrappedLen uint16
+	if _, err := io.ReadFull(f, keyID); err != nil {
+		return nil, ErrCorrupt
+	}
+	if err := binary.Read(f, binary.BigEndian, &wrappedLen); err != nil {
+		return nil, ErrCorrupt
+	}
+	wrapped := make([]byte, wrappedLen)
+	if _, err := io.ReadFull(f, wrapped); err != nil {
+		return nil, ErrCorrupt
+	}
+	return &header{
+		keyID:   string(keyID),
+		wrapped: wrapped,
+		length:  int64(len(prefix) + len(keyID) + 2 + len(wrapped)),
+	}, nil
+}
+
+// nonce returns the nonce of chunk i.
+func nonce(i int64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], uint64(i))
+	return n
+}
+
+// chunkAD returns the additional data of a chunk.
+func chunkAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// writer seals written data chunk by chunk.
+type writer struct {
+	f     *os.File
+	aead  cipher.AEAD
+	buf   []byte // Pending plaintext
+	index int64  // Next chunk
+	err   error
+}
+
+// Write buffers p, sealing full chunks. A full chunk is only sealed once
+// more data arrives, since the last chunk is sealed differently.
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if w.err = w.seal(false); w.err != nil {
+				return written, w.err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk and closes the file.
+func (w *writer) Close() error {
+	if w.err == nil {
+		w.err = w.seal(true)
+	}
+	if err := w.f.Close(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// seal encrypts and writes the buffered chunk.
+func (w *writer) seal(last bool) error {
+	sealed := w.aead.Seal(nil, nonce(w.index), w.buf, chunkAD(last))
+	w.index++
+	w.buf = w.buf[:0]
+	_, err := w.f.Write(sealed)
+	return err
+}
+
+// reader decrypts an encrypted file, one chunk at a time.
+type reader struct {
+	f         *os.File
+	aead      cipher.AEAD
+	dataStart int64 // Offset of the first chunk
+	chunks    int64
+	size      int64 // Plaintext size
+	modTime   time.Time
+	pos       int64
+
+	chunk      []byte // Decrypted chunk chunkIndex
+	chunkIndex int64
+}
+
+func (r *reader) Size() int64        { return r.size }
+func (r *reader) ModTime() time.Time { return r.modTime }
+func (r *reader) Close() error       { return r.f.Close() }
+
+// Read decrypts from the current position.
+func (r *reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	index := r.pos / chunkSize
+	if index != r.chunkIndex {
+		if err := r.load(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk[r.pos-index*chunkSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// Seek sets the plaintext position.
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// load reads and decrypts chunk index.
+func (r *reader) load(index int64) error {
+	sealed := make([]byte, sealedSize)
+	n, err := r.f.ReadAt(sealed, r.dataStart+index*sealedSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	chunk, err := r.aead.Open(sealed[:0], nonce(index), sealed[:n], chunkAD(index == r.chunks-1))
+	if err != nil {
+		return ErrCorrupt
+	}
+	r.chunk, r.chunkIndex = chunk, index
+	return nil
+}
+
+// plainFile is an unencrypted file.
+type plainFile struct {
+	*os.File
+	stat os.FileInfo
+}
+
+func (f *plainFile) Size() int64        { return f.stat.Size() }
+func (f *plainFile) ModTime() time.Time { return f.stat.ModTime() }
@@ -0,0 +1,268 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyProvider wraps and unwraps per-file data keys with master keys it
+// manages, e.g. keys from the environment or a KMS.
+type KeyProvider interface {
+	// Name identifies the provider in logs.
+	Name() string
+	// CurrentKeyID returns the ID of the master key used for new files.
+	// Files wrapped with another key are re-wrapped by key rotation.
+	CurrentKeyID(ctx context.Context) (string, error)
+	// Wrap encrypts a data key with the current master key.
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a data key wrapped with the master key keyID.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// ErrUnknownKey is returned when unwrapping with a master key the provider
+// doesn't have.
+var ErrUnknownKey = errors.New("unknown master key")
+
+// EnvKeys is a key provider holding AES-256 master keys in memory, typically
+// from the environment.
+type EnvKeys struct {
+	keys    map[string][]byte
+	current string
+}
+
+// ParseEnvKeys parses master keys from "id:base64key,id:base64key". current
+// selects the key for new files; when empty, the first key is used. Old keys
+// are kept in the list until files have been rotated off them.
+func ParseEnvKeys(spec, current string) (*EnvKeys, error) {
+	k := &EnvKeys{keys: make(map[string][]byte), current: current}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("master key %q: expected id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %q: must be 32 bytes, got %d", id, len(key))
+		}
+		k.keys[id] = key
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	if len(k.keys) == 0 {
+		return nil, errors.New("no master keys configured")
+	}
+	if _, ok := k.keys[k.current]; !ok {
+		return nil, fmt.Errorf("current master key %q is not configured", k.current)
+	}
+	return k, nil
+}
+
+// Name returns the provider name.
+func (k *EnvKeys) Name() string {
+	return "env"
+}
+
+// CurrentKeyID returns the ID of the key for new files.
+func (k *EnvKeys) CurrentKeyID(ctx context.Context) (string, error) {
+	return k.current, nil
+}
+
+// Wrap seals the data key with the current master key using AES-GCM.
+func (k *EnvKeys) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	aead, err := newGCM(k.keys[k.current])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.current, aead.Seal(nonce, nonce, dataKey, []byte(k.current)), nil
+}
+
+// Unwrap opens a data key sealed by Wrap.
+func (k *EnvKeys) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, []byte(keyID))
+}
+
+// Vault is a key provider using the transit secrets engine of HashiCorp
+// Vault (or OpenBao) as a KMS. Master keys never leave Vault; rotating the
+// transit key there creates a new version, which becomes the current key ID.
+type Vault struct {
+	addr   string
+	token  string
+	key    string // Transit key name
+	client *http.Client
+}
+
+// NewVault creates a provider for the transit key named key at addr, e.g.
+// https://vault.example.com:8200.
+func NewVault(addr, token, key string) *Vault {
+	return &Vault{
+		addr:   strings.TrimSuffix(addr, "/"),
+		token:  token,
+		key:    key,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the provider name.
+func (v *Vault) Name() string {
+	return "vault"
+}
+
+// CurrentKeyID returns the latest version of the transit key, as
+// "<key>:v<version>".
+func (v *Vault) CurrentKeyID(ctx context.Context) (string, error) {
+	var resp struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := v.call(ctx, http.MethodGet, "/v1/transit/keys/"+v.key, nil, &resp); err != nil {
+		return "", err
+	}
+	return v.key + ":v" + strconv.Itoa(resp.Data.LatestVersion), nil
+}
+
+// Wrap encrypts the data key with the latest version of the transit key.
+func (v *Vault) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := v.call(ctx, http.MethodPost, "/v1/transit/encrypt/"+v.key, body, &resp); err != nil {
+		return "", nil, err
+	}
+
+	// Ciphertexts look like "vault:v3:...", naming the key version used
+	parts := strings.SplitN(resp.Data.Ciphertext, ":", 3)
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("unexpected ciphertext format from vault")
+	}
+	return v.key + ":" + parts[1], []byte(resp.Data.Ciphertext), nil
+}
+
+// Unwrap decrypts a data key wrapped by Wrap.
+func (v *Vault) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if name, _, _ := strings.Cut(keyID, ":"); name != v.key {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"ciphertext": string(wrapped)}
+	if err := v.call(ctx, http.MethodPost, "/v1/transit/decrypt/"+v.key, body, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// call sends a request to the Vault API and decodes the JSON response.
+func (v *Vault) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// newGCM returns AES-GCM for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Options selects and configures a key provider.
+type Options struct {
+	Provider   string // "env", "vault" or "" to disable encryption
+	Keys       string // Master keys for env, see ParseEnvKeys
+	KeyID      string // Current master key for env
+	VaultAddr  string
+	VaultKey   string // Transit key name
+	VaultToken string
+}
+
+// FromOptions returns an encryptor for opts, or nil when encryption is
+// disabled.
+func FromOptions(opts Options) (*Encryptor, error) {
+	switch opts.Provider {
+	case "":
+		return nil, nil
+	case "env":
+		keys, err := ParseEnvKeys(opts.Keys, opts.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		return New(keys), nil
+	case "vault":
+		if opts.VaultToken == "" {
+			return nil, errors.New("vault token is not set")
+		}
+		return New(NewVault(opts.VaultAddr, opts.VaultToken, opts.VaultKey)), nil
+	}
+	return nil, fmt.Errorf("unknown key provider %q", opts.Provider)
+}
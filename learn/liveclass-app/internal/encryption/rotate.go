@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Rotate re-wraps the data key of an encrypted file with the current master
+// key, rewriting only the header. It reports whether the file changed;
+// plaintext files and files already on the current key are left alone.
+func (e *Encryptor) Rotate(ctx context.Context, path string) (bool, error) {
+	if e == nil {
+		return false, ErrNotConfigured
+	}
+	current, err := e.keys.CurrentKeyID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	h, err := readHeader(f)
+	if err != nil || h == nil || h.keyID == current {
+		return false, err
+	}
+
+	dataKey, err := e.keys.Unwrap(ctx, h.keyID, h.wrapped)
+	if err != nil {
+		return false, fmt.Errorf("unwrap data key: %w", err)
+	}
+	keyID, wrapped, err := e.keys.Wrap(ctx, dataKey)
+	if err != nil {
+		return false, fmt.Errorf("wrap data key: %w", err)
+	}
+	newHeader, err := encodeHeader(keyID, wrapped)
+	if err != nil {
+		return false, err
+	}
+
+	// The chunks are unchanged, so copy them after the new header
+	err = replace(path, func(tmp string) error {
+		dst, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(newHeader); err != nil {
+			dst.Close()
+			return err
+		}
+		if _, err := io.Copy(dst, f); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+	return err == nil, err
+}
+
+// EncryptFile encrypts a plaintext file in place, e.g. files stored before
+// encryption was enabled. It reports whether the file changed; encrypted
+// files are left alone.
+func (e *Encryptor) EncryptFile(ctx context.Context, path string) (bool, error) {
+	if e == nil {
+		return false, ErrNotConfigured
+	}
+	src, err := e.Open(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	if _, plain := src.(*plainFile); !plain {
+		return false, nil
+	}
+
+	err = replace(path, func(tmp string) error {
+		dst, err := e.Create(ctx, tmp)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+	return err == nil, err
+}
+
+// replace rewrites path by writing a temporary file with write and renaming
+// it over path. The modification time is kept so HTTP caches stay valid.
+func replace(path string, write func(tmp string) error) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".rotating"
+	err = write(tmp)
+	if err == nil {
+		err = os.Chtimes(tmp, stat.ModTime(), stat.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
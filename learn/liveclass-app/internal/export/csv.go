@@ -0,0 +1,53 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// csvFlushEvery controls how often rows are flushed to the client.
+const csvFlushEvery = 100
+
+// CSVWriter streams rows as RFC 4180 CSV.
+type CSVWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+// NewCSVWriter creates a CSV writer on top of out.
+func NewCSVWriter(out io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(out)}
+}
+
+// WriteHeader writes the header row.
+func (c *CSVWriter) WriteHeader(columns []Column) error {
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = EscapeFormula(col.Header)
+	}
+	return c.w.Write(headers)
+}
+
+// WriteRow writes a data row, flushing periodically so large exports stream.
+// Cells that could run as formulas are escaped.
+func (c *CSVWriter) WriteRow(values []string) error {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = EscapeFormula(v)
+	}
+	if err := c.w.Write(escaped); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%csvFlushEvery == 0 {
+		c.w.Flush()
+		return c.w.Error()
+	}
+	return nil
+}
+
+// Close flushes any buffered rows.
+func (c *CSVWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
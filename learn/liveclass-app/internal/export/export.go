@@ -0,0 +1,86 @@
+// Package export provides streaming tabular writers (CSV, XLSX) for data exports.
+package export
+
+import (
+	"strings"
+)
+
+// Column describes a single exportable column.
+type Column struct {
+	Key    string // Stable identifier used in ?columns= selection
+	Header string // Human-readable header written to the file
+}
+
+// Writer streams rows of a table to an underlying output.
+type Writer interface {
+	// WriteHeader writes the header row. It must be called before WriteRow.
+	WriteHeader(columns []Column) error
+	// WriteRow writes a single data row in column order.
+	WriteRow(values []string) error
+	// Close flushes buffered data and finalizes the output.
+	Close() error
+}
+
+// SelectColumns returns the subset of available columns named in keys, in the
+// order requested. Unknown keys are ignored. If keys is empty or matches
+// nothing, all available columns are returned.
+func SelectColumns(available []Column, keys []string) []Column {
+	if len(keys) == 0 {
+		return available
+	}
+
+	byKey := make(map[string]Column, len(available))
+	for _, c := range available {
+		byKey[c.Key] = c
+	}
+
+	selected := make([]Column, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if c, ok := byKey[k]; ok && !seen[k] {
+			selected = append(selected, c)
+			seen[k] = true
+		}
+	}
+
+	if len(selected) == 0 {
+		return available
+	}
+	return selected
+}
+
+// ParseColumns parses a comma-separated ?columns= query value.
+func ParseColumns(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	keys := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			keys = append(keys, p)
+		}
+	}
+	return keys
+}
+
+// EscapeFormula prefixes a cell starting with =, +, -, @, a tab or a
+// carriage return with a single quote, so spreadsheet apps show it as text
+// rather than run it as a formula. Exports carry text users typed, such as
+// names and class titles.
+func EscapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Project maps a record (keyed by column key) onto the selected columns.
+func Project(columns []Column, record map[string]string) []string {
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = record[c.Key]
+	}
+	return values
+}
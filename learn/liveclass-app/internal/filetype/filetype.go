@@ -0,0 +1,210 @@
+// Package filetype identifies uploaded files by their content rather than the
+// Content-Type the client claims, and checks that the file name agrees.
+package filetype
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Detected content types beyond those of http.DetectContentType.
+const (
+	oleStorage = "application/x-ole-storage" // Legacy Office documents (.doc, .xls, .ppt)
+	docx       = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	xlsx       = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	pptx       = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	matroska   = "video/x-matroska"
+	quickTime  = "video/quicktime"
+)
+
+// sniffLen is how much of a file is read to detect its type.
+const sniffLen = 512
+
+var oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Errors returned by Set.Check.
+var (
+	ErrUnsupported = errors.New("file type not supported")
+	ErrMismatch    = errors.New("file extension doesn't match its content")
+)
+
+// Kind is an accepted file type.
+type Kind struct {
+	Ext      string   // Normalized extension, e.g. ".jpg"
+	MimeType string   // Type stored and served to clients
+	Aliases  []string // Other extensions of the type, e.g. ".jpeg"
+	Content  string   // Type the content must be detected as
+}
+
+// Set is the file types accepted for one kind of upload.
+type Set []Kind
+
+// Notes are the file types accepted for class notes.
+var Notes = Set{
+	{Ext: ".pdf", MimeType: "application/pdf", Content: "application/pdf"},
+	{Ext: ".doc", MimeType: "application/msword", Content: oleStorage},
+	{Ext: ".docx", MimeType: docx, Content: docx},
+	{Ext: ".xls", MimeType: "application/vnd.ms-excel", Content: oleStorage},
+	{Ext: ".xlsx", MimeType: xlsx, Content: xlsx},
+	{Ext: ".ppt", MimeType: "application/vnd.ms-powerpoint", Content: oleStorage},
+	{Ext: ".pptx", MimeType: pptx, Content: pptx},
+	{Ext: ".jpg", MimeType: "image/jpeg", Aliases: []string{".jpeg"}, Content: "image/jpeg"},
+	{Ext: ".png", MimeType: "image/png", Content: "image/png"},
+	{Ext: ".gif", MimeType: "image/gif", Content: "image/gif"},
+	{Ext: ".webp", MimeType: "image/webp", Content: "image/webp"},
+	{Ext: ".txt", MimeType: "text/plain", Aliases: []string{".text"}, Content: "text/plain"},
+}
+
+// Photos are the file types accepted for pictures taken on a phone or
+// webcam, such as live class hand-ins.
+var Photos = Set{
+	{Ext: ".jpg", MimeType: "image/jpeg", Aliases: []string{".jpeg"}, Content: "image/jpeg"},
+	{Ext: ".png", MimeType: "image/png", Content: "image/png"},
+	{Ext: ".webp", MimeType: "image/webp", Content: "image/webp"},
+}
+
+// Recordings are the file types accepted for class recordings.
+var Recordings = Set{
+	{Ext: ".webm", MimeType: "video/webm", Content: "video/webm"},
+	{Ext: ".mkv", MimeType: matroska, Content: matroska},
+	{Ext: ".mp4", MimeType: "video/mp4", Aliases: []string{".m4v"}, Content: "video/mp4"},
+	{Ext: ".mov", MimeType: quickTime, Aliases: []string{".qt"}, Content: quickTime},
+}
+
+// Check detects the type of a file of size bytes named filename and returns
+// its kind. The extension must match the content; a file without one is
+// given the extension of its content, using the client's Content-Type hint
+// to tell legacy Office formats apart.
+func (s Set) Check(f io.ReaderAt, size int64, filename, hint string) (Kind, error) {
+	content := Detect(f, size)
+
+	var candidates []Kind
+	for _, kind := range s {
+		if kind.Content == content {
+			candidates = append(candidates, kind)
+		}
+	}
+	if len(candidates) == 0 {
+		return Kind{}, ErrUnsupported
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		if len(candidates) == 1 {
+			return candidates[0], nil
+		}
+		hint, _, _ = mime.ParseMediaType(hint)
+		for _, kind := range candidates {
+			if kind.MimeType == hint {
+				return kind, nil
+			}
+		}
+		return Kind{}, ErrMismatch
+	}
+
+	for _, kind := range candidates {
+		if kind.matches(ext) {
+			return kind, nil
+		}
+	}
+	return Kind{}, ErrMismatch
+}
+
+// Names returns the extensions of the set, for error messages.
+func (s Set) Names() string {
+	names := make([]string, len(s))
+	for i, kind := range s {
+		names[i] = kind.Ext
+	}
+	return strings.Join(names, ", ")
+}
+
+// Rename replaces the extension of a file name with the kind's.
+func (k Kind) Rename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + k.Ext
+}
+
+// matches reports whether ext (lower case) is an extension of the kind.
+func (k Kind) matches(ext string) bool {
+	if ext == k.Ext {
+		return true
+	}
+	for _, alias := range k.Aliases {
+		if ext == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect returns the content type of a file of size bytes, without
+// parameters. It extends http.DetectContentType with Office documents,
+// Matroska and QuickTime video.
+func Detect(f io.ReaderAt, size int64) string {
+	head := make([]byte, sniffLen)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "application/octet-stream"
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, oleMagic):
+		return oleStorage
+	case isQuickTime(head):
+		return quickTime
+	}
+
+	content, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	switch content {
+	case "application/zip":
+		return officeOpenXML(f, size)
+	case "video/webm":
+		// WebM is a subset of Matroska; the EBML header names which one
+		if bytes.Contains(head[:min(len(head), 64)], []byte("matroska")) {
+			return matroska
+		}
+	}
+	return content
+}
+
+// officeOpenXML tells apart the Office Open XML formats, which are zip
+// archives, by their main part. Other archives are plain zip files.
+func officeOpenXML(f io.ReaderAt, size int64) string {
+	archive, err := zip.NewReader(f, size)
+	if err != nil {
+		return "application/zip"
+	}
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			return docx
+		case "xl/workbook.xml":
+			return xlsx
+		case "ppt/presentation.xml":
+			return pptx
+		}
+	}
+	return "application/zip"
+}
+
+// isQuickTime reports whether head starts a QuickTime movie: an ftyp box of
+// the "qt  " brand, or one of the atoms older movies start with.
+func isQuickTime(head []byte) bool {
+	if len(head) < 12 {
+		return false
+	}
+	switch string(head[4:8]) {
+	case "ftyp":
+		return string(head[8:12]) == "qt  "
+	case "moov", "mdat", "wide", "free", "skip":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+// Package geoip locates clients by IP address, from a CSV network database
+// and/or a country header set by a CDN or load balancer.
+package geoip
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Location is where a client appears to be. Country is an ISO 3166-1 alpha-2
+// code; coordinates are only meaningful when HasCoords is set.
+type Location struct {
+	Country   string  `json:"country,omitempty"`
+	Lat       float64 `json:"lat,omitempty"`
+	Lon       float64 `json:"lon,omitempty"`
+	HasCoords bool    `json:"-"`
+}
+
+// block is a network and its location.
+type block struct {
+	prefix netip.Prefix
+	loc    Location
+}
+
+// DB maps networks to locations. Networks must not overlap.
+type DB struct {
+	blocks []block // Sorted by first address
+}
+
+// Open loads a CSV database with lines of "network,country,latitude,longitude",
+// e.g. "81.2.69.0/24,GB,51.5142,-0.0931". Latitude and longitude may be
+// empty. A header line and lines starting with # are skipped. GeoLite2 City
+// CSVs can be converted to this format by joining blocks with locations.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db := &DB{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(fields[0]))
+		if err != nil {
+			if line == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		var loc Location
+		if len(fields) > 1 {
+			loc.Country = strings.ToUpper(strings.TrimSpace(fields[1]))
+		}
+		if len(fields) > 3 {
+			lat, latErr := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+			lon, lonErr := strconv.ParseFloat(strings.TrimSpace(fields[3]), 64)
+			if latErr == nil && lonErr == nil {
+				loc.Lat, loc.Lon, loc.HasCoords = lat, lon, true
+			}
+		}
+		db.blocks = append(db.blocks, block{prefix: prefix.Masked(), loc: loc})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(db.blocks, func(i, j int) bool {
+		return db.blocks[i].prefix.Addr().Less(db.blocks[j].prefix.Addr())
+	})
+	return db, nil
+}
+
+// Len returns the number of networks.
+func (db *DB) Len() int {
+	return len(db.blocks)
+}
+
+// Lookup returns the location of the network containing ip.
+func (db *DB) Lookup(ip netip.Addr) (Location, bool) {
+	if db == nil || !ip.IsValid() {
+		return Location{}, false
+	}
+	ip = ip.Unmap()
+
+	// Last network starting at or before ip
+	i := sort.Search(len(db.blocks), func(i int) bool {
+		return ip.Less(db.blocks[i].prefix.Addr())
+	}) - 1
+	if i >= 0 && db.blocks[i].prefix.Contains(ip) {
+		return db.blocks[i].loc, true
+	}
+	return Location{}, false
+}
+
+// Locator finds the location of HTTP clients.
+type Locator struct {
+	db             *DB    // May be nil
+	countryHeader  string // e.g. CF-IPCountry or CloudFront-Viewer-Country
+	trustForwarded bool   // Use X-Forwarded-For as the client address
+}
+
+// NewLocator creates a locator. db may be nil to rely on countryHeader only.
+func NewLocator(db *DB, countryHeader string, trustForwarded bool) *Locator {
+	return &Locator{db: db, countryHeader: countryHeader, trustForwarded: trustForwarded}
+}
+
+// ClientIP returns the client address of r: the first X-Forwarded-For entry
+// when forwarded headers are trusted, otherwise the peer address.
+func (l *Locator) ClientIP(r *http.Request) netip.Addr {
+	if l.trustForwarded {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			if ip, err := netip.ParseAddr(strings.TrimSpace(first)); err == nil {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, _ := netip.ParseAddr(host)
+	return ip
+}
+
+// Locate returns the location of the client of r. The database is consulted
+// first; the country header fills in the country when it has no answer.
+func (l *Locator) Locate(r *http.Request) Location {
+	return l.LocateIP(r, l.ClientIP(r))
+}
+
+// LocateIP returns the location of ip, falling back to the country header
+// of r.
+func (l *Locator) LocateIP(r *http.Request, ip netip.Addr) Location {
+	loc, _ := l.db.Lookup(ip)
+	if loc.Country == "" && l.countryHeader != "" {
+		country := strings.ToUpper(strings.TrimSpace(r.Header.Get(l.countryHeader)))
+		if len(country) == 2 && country != "XX" {
+			loc.Country = country
+		}
+	}
+	return loc
+}
@@ -0,0 +1,148 @@
+// Package httpcache caches responses of hot read endpoints per user, with
+// per-route TTLs and tag-based invalidation shared across instances via pub/sub.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/pubsub"
+)
+
+// invalidateChannel is the pub/sub channel used to invalidate tags on other instances.
+const invalidateChannel = "httpcache:invalidate"
+
+var cacheRequests = metrics.NewCounterVec(
+	"liveclass_http_cache_requests_total",
+	"Cacheable API requests by tag and result (hit, miss).",
+	"tag", "result",
+)
+
+// entry is a cached response.
+type entry struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Cache stores API responses keyed by tag, user and request URI.
+type Cache struct {
+	store *cache.Cache[*entry]
+	ps    *pubsub.RedisPubSub
+}
+
+// New creates a new response cache. ps may be nil in single-instance mode.
+func New(ps *pubsub.RedisPubSub) *Cache {
+	c := &Cache{
+		store: cache.New[*entry](time.Minute, time.Minute),
+		ps:    ps,
+	}
+
+	if ps != nil {
+		ps.Subscribe(invalidateChannel, func(msg *pubsub.Message) {
+			var tag string
+			if err := json.Unmarshal(msg.Payload, &tag); err == nil {
+				c.invalidateLocal(tag)
+			}
+		})
+	}
+
+	return c
+}
+
+// Wrap caches successful GET responses of next for ttl. Responses are scoped by
+// the key returned by scope (typically the user ID); requests for which scope
+// returns "" are not cached.
+func (c *Cache) Wrap(tag string, ttl time.Duration, scope func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || ttl <= 0 {
+			next(w, r)
+			return
+		}
+
+		owner := scope(r)
+		if owner == "" {
+			next(w, r)
+			return
+		}
+		key := tag + ":" + owner + ":" + r.URL.RequestURI()
+
+		if e, ok := c.store.Get(key); ok {
+			cacheRequests.WithLabelValues(tag, "hit").Inc()
+			for k, v := range e.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(e.status)
+			w.Write(e.body)
+			return
+		}
+
+		cacheRequests.WithLabelValues(tag, "miss").Inc()
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		w.Header().Set("X-Cache", "MISS")
+		next(rec, r)
+
+		if rec.status == http.StatusOK {
+			header := w.Header().Clone()
+			header.Del("X-Cache")
+			c.store.SetWithExpiration(key, &entry{
+				status: rec.status,
+				header: header,
+				body:   rec.body.Bytes(),
+			}, ttl)
+		}
+	}
+}
+
+// Invalidate drops every cached response with the tag, on this and other instances.
+func (c *Cache) Invalidate(tag string) {
+	c.invalidateLocal(tag)
+
+	if c.ps == nil {
+		return
+	}
+
+	payload, _ := json.Marshal(tag)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := c.ps.Publish(ctx, invalidateChannel, &pubsub.Message{Type: "invalidate", Payload: payload}); err != nil {
+		log.Printf("[HTTPCache] Failed to publish invalidation of %s: %v", tag, err)
+	}
+}
+
+// Clear drops every cached response on this instance.
+func (c *Cache) Clear() {
+	c.store.Clear()
+}
+
+// invalidateLocal drops cached responses with the tag on this instance.
+func (c *Cache) invalidateLocal(tag string) {
+	c.store.DeletePrefix(tag + ":")
+}
+
+// recorder captures the response while passing it through.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code.
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records and writes the body.
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,138 @@
+// Package ice builds per-client ICE server lists, picking the TURN regions
+// closest to each client.
+package ice
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/geoip"
+)
+
+// Server is an ICE server in the shape of the browser's RTCIceServer.
+type Server struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Region is a cluster of TURN servers.
+type Region struct {
+	Name       string
+	URLs       []string
+	Username   string
+	Credential string
+	// Where the cluster is, for distance-based selection
+	Lat, Lon  float64
+	HasCoords bool
+	// Countries (ISO 3166-1 alpha-2) always served by this region first
+	Countries []string
+}
+
+// Ways a selection was made.
+const (
+	ReasonOverride = "override" // Region forced by the caller
+	ReasonCountry  = "country"  // Region lists the client's country
+	ReasonDistance = "distance" // Closest region to the client's coordinates
+	ReasonDefault  = "default"  // Client location unknown; configured order
+)
+
+// Selection is the ICE configuration chosen for a client.
+type Selection struct {
+	Regions    []string       `json:"regions"` // Chosen TURN regions, preferred first
+	Reason     string         `json:"reason"`
+	Location   geoip.Location `json:"location"`
+	ICEServers []Server       `json:"iceServers"`
+}
+
+// Selector picks TURN regions for clients.
+type Selector struct {
+	stun      []string
+	regions   []Region
+	perClient int
+}
+
+// NewSelector creates a selector returning the STUN servers plus the
+// perClient best TURN regions for each client (at least one).
+func NewSelector(stun []string, regions []Region, perClient int) *Selector {
+	if perClient < 1 {
+		perClient = 1
+	}
+	return &Selector{stun: stun, regions: regions, perClient: perClient}
+}
+
+// Regions returns the configured regions.
+func (s *Selector) Regions() []Region {
+	return s.regions
+}
+
+// Select returns the ICE configuration for a client at loc. A non-empty
+// region forces that region first, e.g. for testing a cluster.
+func (s *Selector) Select(loc geoip.Location, region string) Selection {
+	ranked := make([]Region, len(s.regions))
+	copy(ranked, s.regions)
+	reason := ReasonDefault
+
+	// Stable sorts keep the configured order among equally good regions
+	if loc.HasCoords {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return distance(loc, ranked[i]) < distance(loc, ranked[j])
+		})
+		reason = ReasonDistance
+	}
+	if loc.Country != "" {
+		serves := func(r Region) bool { return contains(r.Countries, loc.Country) }
+		sort.SliceStable(ranked, func(i, j int) bool { return serves(ranked[i]) && !serves(ranked[j]) })
+		if len(ranked) > 0 && serves(ranked[0]) {
+			reason = ReasonCountry
+		}
+	}
+	if region != "" {
+		forced := func(r Region) bool { return strings.EqualFold(r.Name, region) }
+		sort.SliceStable(ranked, func(i, j int) bool { return forced(ranked[i]) && !forced(ranked[j]) })
+		if len(ranked) > 0 && forced(ranked[0]) {
+			reason = ReasonOverride
+		}
+	}
+
+	sel := Selection{
+		Regions:  []string{},
+		Reason:   reason,
+		Location: loc,
+	}
+	if len(s.stun) > 0 {
+		sel.ICEServers = append(sel.ICEServers, Server{URLs: s.stun})
+	}
+	for _, r := range ranked[:min(len(ranked), s.perClient)] {
+		sel.Regions = append(sel.Regions, r.Name)
+		sel.ICEServers = append(sel.ICEServers, Server{URLs: r.URLs, Username: r.Username, Credential: r.Credential})
+	}
+	return sel
+}
+
+// distance returns the great-circle distance in km from loc to a region, or
+// +Inf when the region has no coordinates.
+func distance(loc geoip.Location, r Region) float64 {
+	if !r.HasCoords {
+		return math.Inf(1)
+	}
+	const earthRadiusKm = 6371
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(r.Lat - loc.Lat)
+	dLon := rad(r.Lon - loc.Lon)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(loc.Lat))*math.Cos(rad(r.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// contains reports whether list contains s, ignoring case.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
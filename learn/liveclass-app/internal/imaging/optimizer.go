@@ -0,0 +1,248 @@
+// Package imaging makes smaller copies of uploaded images, so clients such
+// as phones browsing image-heavy note lists can download just what they show.
+package imaging
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Limits of variant generation
+const (
+	maxPixels   = 50_000_000 // Larger images aren't decoded, to bound memory
+	queueSize   = 256
+	backfillMax = 100 // Images handled per backfill pass
+	jpegQuality = 80
+)
+
+// Optimizer generates resized variants of image notes in the background:
+// right after upload, and in periodic passes that pick up images uploaded
+// before variants existed or missed while the queue was full.
+//
+// Notes are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Optimizer struct {
+	noteRepo *repository.NoteRepository
+	files    *encryption.Encryptor // nil stores files in plaintext
+	widths   []int
+	interval time.Duration
+	queue    chan *models.Note
+}
+
+// NewOptimizer creates an optimizer making variants of the given widths,
+// with a backfill pass every interval (0 for none).
+func NewOptimizer(noteRepo *repository.NoteRepository, files *encryption.Encryptor, widths []int, interval time.Duration) *Optimizer {
+	widths = append([]int(nil), widths...)
+	sort.Ints(widths)
+	return &Optimizer{
+		noteRepo: noteRepo,
+		files:    files,
+		widths:   widths,
+		interval: interval,
+		queue:    make(chan *models.Note, queueSize),
+	}
+}
+
+// Enqueue schedules variants for a newly uploaded note. Other file types
+// are ignored. It never blocks; if the queue is full the next backfill pass
+// picks the note up.
+func (o *Optimizer) Enqueue(note *models.Note) {
+	if note.FileType != models.NoteTypeImage || len(o.widths) == 0 {
+		return
+	}
+	select {
+	case o.queue <- note:
+	default:
+		log.Printf("[Imaging] Queue full, %s left for the next pass", note.ID.Hex())
+	}
+}
+
+// Run generates variants of queued notes, and of any images still without
+// variants immediately and then every interval, until ctx is cancelled.
+func (o *Optimizer) Run(ctx context.Context) {
+	if len(o.widths) == 0 {
+		return
+	}
+
+	var tick <-chan time.Time
+	if o.interval > 0 {
+		o.backfill(ctx)
+		ticker := time.NewTicker(o.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case note := <-o.queue:
+			o.process(ctx, note)
+		case <-tick:
+			o.backfill(ctx)
+		}
+	}
+}
+
+// backfill generates variants of images that don't have them yet.
+func (o *Optimizer) backfill(ctx context.Context) {
+	findCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	notes, err := o.noteRepo.FindImagesWithoutVariants(findCtx, backfillMax)
+	cancel()
+	if err != nil {
+		log.Printf("[Imaging] Failed to load images without variants: %v", err)
+		return
+	}
+	for _, note := range notes {
+		if ctx.Err() != nil {
+			return
+		}
+		o.process(ctx, note)
+	}
+}
+
+// process claims a note and stores its variants.
+func (o *Optimizer) process(ctx context.Context, note *models.Note) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	claimed, err := o.noteRepo.ClaimVariants(ctx, note.ID)
+	if err != nil {
+		log.Printf("[Imaging] Failed to claim %s: %v", note.ID.Hex(), err)
+		return
+	}
+	if !claimed {
+		return // Handled by another instance
+	}
+
+	variants, err := o.generate(ctx, note)
+	if err != nil {
+		// The note stays claimed, so a broken image isn't retried every pass
+		log.Printf("[Imaging] No variants for %s (%s): %v", note.Title, note.ID.Hex(), err)
+		return
+	}
+	if len(variants) == 0 {
+		return
+	}
+	if err := o.noteRepo.SetVariants(ctx, note.ID, variants); err != nil {
+		log.Printf("[Imaging] Failed to save variants of %s: %v", note.ID.Hex(), err)
+		for _, v := range variants {
+			os.Remove(v.FilePath)
+		}
+		return
+	}
+	log.Printf("[Imaging] Made %d variant(s) of %s", len(variants), note.Title)
+}
+
+// generate writes a variant of the note for each configured width narrower
+// than the image. JPEG images and opaque PNGs become JPEGs; PNGs with
+// transparency stay PNGs.
+func (o *Optimizer) generate(ctx context.Context, note *models.Note) ([]models.ImageVariant, error) {
+	if note.MimeType != "image/jpeg" && note.MimeType != "image/png" {
+		return nil, fmt.Errorf("%s can't be resized", note.MimeType)
+	}
+
+	file, err := o.files.Open(ctx, note.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%dx%d image is too large", config.Width, config.Height)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	opaque := note.MimeType == "image/jpeg"
+	if img, ok := src.(interface{ Opaque() bool }); ok && img.Opaque() {
+		opaque = true
+	}
+	ext, mimeType := ".png", "image/png"
+	if opaque {
+		ext, mimeType = ".jpg", "image/jpeg"
+	}
+
+	bounds := src.Bounds()
+	var variants []models.ImageVariant
+	for _, width := range o.widths {
+		if width >= bounds.Dx() {
+			break
+		}
+		height := max(1, (bounds.Dy()*width+bounds.Dx()/2)/bounds.Dx())
+		path := strings.TrimSuffix(note.FilePath, filepath.Ext(note.FilePath)) + fmt.Sprintf("_w%d", width) + ext
+
+		size, err := o.write(ctx, path, resize(src, width, height), opaque)
+		if err != nil {
+			for _, v := range variants {
+				os.Remove(v.FilePath)
+			}
+			return nil, err
+		}
+		variants = append(variants, models.ImageVariant{
+			Width:    width,
+			Height:   height,
+			FilePath: path,
+			FileSize: size,
+			MimeType: mimeType,
+		})
+	}
+	return variants, nil
+}
+
+// write encodes img to path, encrypted when enabled, and returns its size.
+func (o *Optimizer) write(ctx context.Context, path string, img image.Image, asJPEG bool) (int64, error) {
+	dst, err := o.files.Create(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	counter := &countingWriter{w: dst}
+	if asJPEG {
+		err = jpeg.Encode(counter, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(counter, img)
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// countingWriter counts the plaintext bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,177 @@
+// Package lock provides named locks for critical sections that must not run
+// on two instances at once, such as starting a class. With Redis they hold
+// across the cluster; without it, within this instance.
+//
+// A lock expires after its TTL, so a holder that stalls can lose it while
+// still running. Each lock carries a fencing token, greater than those of
+// earlier holders of the same name; writes that must not be overtaken
+// should be made conditional on it.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/pubsub"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix    = "lock:"
+	fenceSuffix  = ":fence"
+	retryBackoff = 50 * time.Millisecond
+)
+
+// ErrHeld is returned when a lock is held by someone else.
+var ErrHeld = errors.New("lock is held elsewhere")
+
+// release deletes a lock only if it's still ours, so a holder whose lock
+// expired doesn't release the next holder's.
+var release = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// fence hands out the next fencing token of a lock. Tokens never fall
+// behind the clock (in microseconds), so they keep increasing even if Redis
+// loses the counter.
+var fence = redis.NewScript(`
+local token = redis.call("INCR", KEYS[1])
+local now = tonumber(ARGV[1])
+if token < now then
+	redis.call("SET", KEYS[1], now)
+	token = now
+end
+return token`)
+
+// Locker hands out locks.
+type Locker struct {
+	ps *pubsub.RedisPubSub // nil in single-instance mode
+
+	// Single-instance mode
+	mu        sync.Mutex
+	held      map[string]localLock
+	lastToken int64 // Shared by all names, which keeps each name's increasing
+}
+
+// localLock is a lock held in single-instance mode.
+type localLock struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewLocker creates a locker. Without Redis, locks only exclude holders on
+// this instance.
+func NewLocker(ps *pubsub.RedisPubSub) *Locker {
+	return &Locker{
+		ps:   ps,
+		held: make(map[string]localLock),
+	}
+}
+
+// Lock is a held lock.
+type Lock struct {
+	locker *Locker
+	name   string
+	value  string // Identifies this holder
+	token  int64
+}
+
+// TryAcquire takes the lock name for ttl, failing with ErrHeld if someone
+// else holds it.
+func (l *Locker) TryAcquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	value, err := randomValue()
+	if err != nil {
+		return nil, err
+	}
+	lock := &Lock{locker: l, name: name, value: value}
+
+	if l.ps == nil {
+		if !l.acquireLocal(lock, ttl) {
+			return nil, ErrHeld
+		}
+		return lock, nil
+	}
+
+	client := l.ps.GetClient()
+	ok, err := client.SetNX(ctx, keyPrefix+name, value, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrHeld
+	}
+	lock.token, err = fence.Run(ctx, client, []string{keyPrefix + name + fenceSuffix}, time.Now().UnixMicro()).Int64()
+	if err != nil {
+		lock.Release(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Acquire takes the lock name for ttl, waiting for it while someone else
+// holds it. It fails with ErrHeld if the lock is still held when ctx is
+// done.
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquire(ctx, name, ttl)
+		if !errors.Is(err, ErrHeld) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ErrHeld
+		case <-time.After(retryBackoff):
+		}
+	}
+}
+
+// acquireLocal takes a lock in single-instance mode.
+func (l *Locker) acquireLocal(lock *Lock, ttl time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if held, ok := l.held[lock.name]; ok && now.Before(held.expiresAt) {
+		return false
+	}
+	l.held[lock.name] = localLock{value: lock.value, expiresAt: now.Add(ttl)}
+
+	l.lastToken = max(l.lastToken+1, now.UnixMicro())
+	lock.token = l.lastToken
+	return true
+}
+
+// Token returns the lock's fencing token.
+func (lock *Lock) Token() int64 {
+	return lock.token
+}
+
+// Release gives the lock up. It does nothing if the lock has expired and
+// passed to someone else.
+func (lock *Lock) Release(ctx context.Context) error {
+	l := lock.locker
+	if l.ps == nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if held, ok := l.held[lock.name]; ok && held.value == lock.value {
+			delete(l.held, lock.name)
+		}
+		return nil
+	}
+	return release.Run(ctx, l.ps.GetClient(), []string{keyPrefix + lock.name}, lock.value).Err()
+}
+
+// randomValue returns a value identifying one holder of a lock.
+func randomValue() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
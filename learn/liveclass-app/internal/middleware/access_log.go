@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Access log outputs
+const (
+	AccessLogStdout = "stdout"
+	AccessLogFile   = "file"
+	AccessLogSyslog = "syslog"
+)
+
+// AccessEntry is one line of the access log.
+type AccessEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Route      string    `json:"route"` // Pattern the request matched, not its path
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"durationMs"`
+	Bytes      int64     `json:"bytes"` // Response body as sent, after compression
+	UserID     string    `json:"userId,omitempty"`
+	RequestID  string    `json:"requestId,omitempty"`
+	Sampled    float64   `json:"sampled,omitempty"` // Share of the route's requests logged, when below 1
+}
+
+// AccessLogger writes a JSON line per request. Busy routes can be sampled;
+// server errors are always logged.
+type AccessLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	route  func(r *http.Request) string
+	userID func(r *http.Request) string
+	rates  map[string]float64
+}
+
+// NewAccessLogger creates a logger writing to out. route returns the
+// pattern a request matches and userID the user making it ("" for none);
+// userID is only called for requests that are logged, once handled, and
+// can read the user with User. rates maps route
+// patterns to the share of their requests logged; other routes are logged
+// in full.
+func NewAccessLogger(out io.Writer, route, userID func(r *http.Request) string, rates map[string]float64) *AccessLogger {
+	return &AccessLogger{out: out, route: route, userID: userID, rates: rates}
+}
+
+// OpenAccessLog opens an access log output: stdout, the file at path
+// (appended to) or syslog at addr ("" for the local daemon, otherwise
+// "udp://host:port" or "tcp://host:port").
+func OpenAccessLog(output, path, addr string) (io.WriteCloser, error) {
+	switch output {
+	case AccessLogStdout:
+		return nopCloser{os.Stdout}, nil
+	case AccessLogFile:
+		if path == "" {
+			return nil, fmt.Errorf("access log file not set")
+		}
+		return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	case AccessLogSyslog:
+		return openSyslog(addr)
+	default:
+		return nil, fmt.Errorf("unknown access log output %q", output)
+	}
+}
+
+// Handler logs the requests handled by next.
+func (l *AccessLogger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// Looked up first, since handlers may rewrite the path
+		route := l.route(r)
+		rec := &accessRecorder{ResponseWriter: w}
+		r = r.WithContext(TrackUser(r.Context()))
+
+		next.ServeHTTP(rec, r)
+
+		status := rec.Status()
+		rate, sampled := l.rates[route]
+		if sampled && status < http.StatusInternalServerError && rand.Float64() >= rate {
+			return
+		}
+		entry := AccessEntry{
+			Time:       start.UTC(),
+			Method:     r.Method,
+			Route:      route,
+			Status:     status,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:      rec.bytes.Load(),
+			UserID:     l.userID(r),
+			RequestID:  GetRequestID(r.Context()),
+		}
+		if sampled && rate < 1 && status < http.StatusInternalServerError {
+			entry.Sampled = rate
+		}
+		l.write(entry)
+	})
+}
+
+// write appends an entry to the log.
+func (l *AccessLogger) write(entry AccessEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(data)
+}
+
+// accessRecorder records the status and size of a response. It passes
+// flushes and hijacks through, for server-sent events and WebSockets.
+type accessRecorder struct {
+	http.ResponseWriter
+	status atomic.Int32
+	bytes  atomic.Int64
+}
+
+func (w *accessRecorder) WriteHeader(status int) {
+	w.status.CompareAndSwap(0, int32(status))
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessRecorder) Write(b []byte) (int, error) {
+	w.status.CompareAndSwap(0, http.StatusOK)
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes.Add(int64(n))
+	return n, err
+}
+
+// Status returns the response status; 200 if the handler wrote nothing.
+func (w *accessRecorder) Status() int {
+	if status := w.status.Load(); status != 0 {
+		return int(status)
+	}
+	return http.StatusOK
+}
+
+func (w *accessRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *accessRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.status.CompareAndSwap(0, http.StatusSwitchingProtocols)
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *accessRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// nopCloser keeps stdout open when the access log is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
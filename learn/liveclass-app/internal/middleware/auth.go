@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+const (
+	userKey     contextKey = "user"
+	userSlotKey contextKey = "userSlot"
+)
+
+// Authenticator resolves the user a login token belongs to, refusing
+// tokens of signed-out sessions and of accounts that can't sign in.
+type Authenticator interface {
+	GetUserFromToken(ctx context.Context, token string) (*models.User, error)
+}
+
+// Auth authenticates requests once and puts the user in their context, for
+// handlers to read with User.
+type Auth struct {
+	authenticator Authenticator
+}
+
+// NewAuth creates an Auth checking tokens with authenticator.
+func NewAuth(authenticator Authenticator) *Auth {
+	return &Auth{authenticator: authenticator}
+}
+
+// RequireAuth refuses requests without a valid login token.
+func (a *Auth) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return a.require(next, Token)
+}
+
+// RequireAuthAllowingQuery is RequireAuth for routes browsers open without
+// custom headers, such as EventSource streams, which may carry the login
+// token in ?token= instead.
+func (a *Auth) RequireAuthAllowingQuery(next http.HandlerFunc) http.HandlerFunc {
+	return a.require(next, QueryToken)
+}
+
+// require refuses requests without a valid login token, read with token.
+func (a *Auth) require(next http.HandlerFunc, token func(*http.Request) string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := User(r.Context()); ok {
+			next(w, r)
+			return
+		}
+
+		token := token(r)
+		if token == "" {
+			writeError(w, "Authorization required", http.StatusUnauthorized)
+			return
+		}
+		user, err := a.authenticator.GetUserFromToken(r.Context(), token)
+		if err != nil {
+			writeError(w, "Invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(WithUser(r.Context(), user)))
+	}
+}
+
+// RequireRole returns middleware refusing requests unless they are from a
+// user with one of roles.
+func (a *Auth) RequireRole(roles ...models.UserRole) func(http.HandlerFunc) http.HandlerFunc {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	message := strings.Join(names, " or ") + " access required"
+	message = strings.ToUpper(message[:1]) + message[1:]
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return a.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+			user, _ := User(r.Context())
+			for _, role := range roles {
+				if user.Role == role {
+					next(w, r)
+					return
+				}
+			}
+			writeError(w, message, http.StatusForbidden)
+		})
+	}
+}
+
+// WithUser returns a copy of ctx carrying an authenticated user.
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	if slot, ok := ctx.Value(userSlotKey).(*atomic.Pointer[models.User]); ok {
+		slot.Store(user)
+	}
+	return context.WithValue(ctx, userKey, user)
+}
+
+// TrackUser returns a copy of ctx in which User also reports the user the
+// request is authenticated as further in, for middleware that runs before
+// authentication and looks once the handler returned, such as the access
+// log.
+func TrackUser(ctx context.Context) context.Context {
+	return context.WithValue(ctx, userSlotKey, new(atomic.Pointer[models.User]))
+}
+
+// User returns the user authenticated for a request, if any.
+func User(ctx context.Context) (*models.User, bool) {
+	user, ok := ctx.Value(userKey).(*models.User)
+	if !ok {
+		if slot, tracked := ctx.Value(userSlotKey).(*atomic.Pointer[models.User]); tracked {
+			user, ok = slot.Load(), true
+		}
+	}
+	return user, ok && user != nil
+}
+
+// Token returns the login token in a request's Authorization header.
+func Token(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		parts := strings.Split(authHeader, " ")
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// QueryToken returns the login token of a request, from its Authorization
+// header or else its token query parameter. Only routes that browsers can't
+// send headers to may use it: tokens in URLs end up in logs and history.
+func QueryToken(r *http.Request) string {
+	if token := Token(r); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// writeError sends a JSON error response, as the API handlers do.
+func writeError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package middleware
+
+import (
+	"errors"
+	"io"
+)
+
+// openSyslog is not implemented on this platform; log to a file instead.
+func openSyslog(addr string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
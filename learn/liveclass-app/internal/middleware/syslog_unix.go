@@ -0,0 +1,18 @@
+//go:build unix
+
+package middleware
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// openSyslog connects to the syslog daemon at addr, or the local one.
+func openSyslog(addr string) (io.WriteCloser, error) {
+	var network string
+	if scheme, host, ok := strings.Cut(addr, "://"); ok {
+		network, addr = scheme, host
+	}
+	return syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, "liveclass-access")
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Usage reports each request handled by next to record, with its status
+// and the bytes read from its body and written in the response.
+func Usage(record func(r *http.Request, status int, bytesIn, bytesOut int64)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &countingBody{ReadCloser: r.Body}
+			r.Body = body
+			rec := &accessRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			record(r, rec.Status(), body.n.Load(), rec.bytes.Load())
+		})
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,157 @@
+// Package migrate applies versioned data migrations to MongoDB. Applied
+// migrations are recorded so each one runs exactly once per database.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const migrationsCollection = "schema_migrations"
+
+// Migration is a single, idempotent change to stored data.
+type Migration struct {
+	ID          string // Sortable, never reused (e.g. "2026-10-16-schedule-exam-defaults")
+	Description string
+	Up          func(ctx context.Context, db *database.MongoDB) error
+}
+
+// Record marks a migration as applied.
+type Record struct {
+	ID          string    `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+// All lists migrations in the order they are applied. Append only.
+var All = []Migration{
+	{
+		ID:          "2026-10-16-schedule-exam-defaults",
+		Description: "Backfill proctored, examMode and lateEntryMinutes on schedules",
+		Up: func(ctx context.Context, db *database.MongoDB) error {
+			schedules := db.Collection("scheduled_classes")
+			for field, value := range map[string]interface{}{
+				"proctored":        false,
+				"examMode":         false,
+				"lateEntryMinutes": 0,
+			} {
+				filter := bson.M{field: bson.M{"$exists": false}}
+				if _, err := schedules.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: value}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "2026-10-16-batch-dm-defaults",
+		Description: "Backfill directMessagesDisabled on batches",
+		Up: func(ctx context.Context, db *database.MongoDB) error {
+			filter := bson.M{"directMessagesDisabled": bson.M{"$exists": false}}
+			_, err := db.Collection("batches").UpdateMany(ctx, filter, bson.M{"$set": bson.M{"directMessagesDisabled": false}})
+			return err
+		},
+	},
+	{
+		ID:          "2026-10-16-sanitize-descriptions",
+		Description: "Strip unsafe HTML from stored batch, class, recording, note and template descriptions",
+		Up: func(ctx context.Context, db *database.MongoDB) error {
+			for _, name := range []string{"batches", "scheduled_classes", "recordings", "notes", "class_templates"} {
+				if err := sanitizeField(ctx, db.Collection(name), "description"); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// sanitizeField rewrites field with richtext.Rich wherever it contains markup.
+func sanitizeField(ctx context.Context, collection *mongo.Collection, field string) error {
+	cursor, err := collection.Find(ctx, bson.M{field: bson.M{"$regex": "<"}},
+		options.Find().SetProjection(bson.M{field: 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		value, _ := doc[field].(string)
+		if clean := richtext.Rich.Sanitize(value); clean != value {
+			if _, err := collection.UpdateByID(ctx, doc["_id"], bson.M{"$set": bson.M{field: clean}}); err != nil {
+				return err
+			}
+		}
+	}
+	return cursor.Err()
+}
+
+// Applied returns the records of migrations already applied, keyed by ID.
+func Applied(ctx context.Context, db *database.MongoDB) (map[string]Record, error) {
+	cursor, err := db.Collection(migrationsCollection).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]Record, len(records))
+	for _, r := range records {
+		applied[r.ID] = r
+	}
+	return applied, nil
+}
+
+// Pending returns the migrations that have not been applied yet, in order.
+func Pending(ctx context.Context, db *database.MongoDB) ([]Migration, error) {
+	applied, err := Applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range All {
+		if _, ok := applied[m.ID]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Run applies all pending migrations in order and returns the ones applied.
+// It stops at the first failure; migrations before it stay recorded.
+func Run(ctx context.Context, db *database.MongoDB) ([]Migration, error) {
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		if err := m.Up(ctx, db); err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.ID, err)
+		}
+
+		record := Record{ID: m.ID, Description: m.Description, AppliedAt: time.Now()}
+		if _, err := db.Collection(migrationsCollection).InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return done, fmt.Errorf("record migration %s: %w", m.ID, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
@@ -0,0 +1,31 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIUsage is what an external client of the API used on one day (UTC).
+type APIUsage struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Client    string             `bson:"client" json:"client"` // e.g. "webhook:lms"
+	Day       string             `bson:"day" json:"day"`       // 2006-01-02
+	Requests  int64              `bson:"requests" json:"requests"`
+	Errors    int64              `bson:"errors" json:"errors"`     // Answered with a 4xx or 5xx
+	BytesIn   int64              `bson:"bytesIn" json:"bytesIn"`   // Request bodies
+	BytesOut  int64              `bson:"bytesOut" json:"bytesOut"` // Response bodies
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"-"`
+
+	QuotaAlertedAt *time.Time `bson:"quotaAlertedAt,omitempty" json:"quotaAlertedAt,omitempty"`
+}
+
+// ErrorRate returns the share of requests that failed.
+func (u *APIUsage) ErrorRate() float64 {
+	if u.Requests == 0 {
+		return 0
+	}
+	return float64(u.Errors) / float64(u.Requests)
+}
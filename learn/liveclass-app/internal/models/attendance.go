@@ -0,0 +1,41 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attendance is one stint of a signed-in viewer in a live class, from
+// joining the class's room until leaving it. Rejoining starts a new stint.
+type Attendance struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID    primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	BatchID       primitive.ObjectID `bson:"batchId" json:"batchId"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"`
+	Name          string             `bson:"name" json:"name"` // As joined
+	RoomID        string             `bson:"roomId" json:"roomId"`
+	SessionID     string             `bson:"sessionId" json:"-"`
+	ParticipantID string             `bson:"participantId" json:"-"`
+	JoinedAt      time.Time          `bson:"joinedAt" json:"joinedAt"`
+	LeftAt        *time.Time         `bson:"leftAt,omitempty" json:"leftAt,omitempty"` // nil while in the room
+}
+
+// AttendanceSummary is one account's attendance of a class over all its
+// stints. Overlapping stints, as from two devices, are counted once.
+type AttendanceSummary struct {
+	ScheduleID     string     `json:"scheduleId"`
+	ClassTitle     string     `json:"classTitle,omitempty"`
+	ClassStart     time.Time  `json:"classStart,omitempty"`
+	UserID         string     `json:"userId"`
+	Name           string     `json:"name"`
+	Email          string     `json:"email,omitempty"`
+	Enrolled       bool       `json:"enrolled"`
+	Attended       bool       `json:"attended"`
+	Present        bool       `json:"present"` // In the room now
+	Joins          int        `json:"joins"`
+	FirstJoinedAt  *time.Time `json:"firstJoinedAt,omitempty"`
+	LastLeftAt     *time.Time `json:"lastLeftAt,omitempty"`
+	WatchedSeconds int64      `json:"watchedSeconds"`
+}
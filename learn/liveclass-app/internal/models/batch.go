@@ -17,6 +17,38 @@ type Batch struct {
 	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
 	CreatedBy   primitive.ObjectID   `bson:"createdBy" json:"createdBy"`
+
+	// DirectMessagesDisabled blocks student-initiated DMs (e.g. during exams)
+	DirectMessagesDisabled bool `bson:"directMessagesDisabled" json:"directMessagesDisabled"`
+
+	// AssistantIDs are teaching assistants: they can moderate chat and the room,
+	// upload notes and view attendance, but only inside this batch
+	AssistantIDs []primitive.ObjectID `bson:"assistantIds" json:"assistantIds"`
+
+	// Snapshot of the presenter's name, kept current by the name reconciler
+	PresenterName string `bson:"presenterName,omitempty" json:"presenterName,omitempty"`
+
+	// Groups are named subsets of the students, e.g. teams or lab sections,
+	// that materials can be targeted at
+	Groups []BatchGroup `bson:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// BatchGroup is a named group of a batch's students.
+type BatchGroup struct {
+	ID         primitive.ObjectID   `bson:"_id" json:"id"`
+	Name       string               `bson:"name" json:"name"`
+	StudentIDs []primitive.ObjectID `bson:"studentIds" json:"studentIds"`
+	CreatedAt  time.Time            `bson:"createdAt" json:"createdAt"`
+}
+
+// HasStudent checks if a student is in the group.
+func (g *BatchGroup) HasStudent(studentID primitive.ObjectID) bool {
+	for _, id := range g.StudentIDs {
+		if id == studentID {
+			return true
+		}
+	}
+	return false
 }
 
 // BatchResponse is the API response for a batch.
@@ -27,18 +59,25 @@ type BatchResponse struct {
 	PresenterID   string    `json:"presenterId"`
 	PresenterName string    `json:"presenterName,omitempty"`
 	StudentCount  int       `json:"studentCount"`
+	AssistantIDs  []string  `json:"assistantIds"`
 	CreatedAt     time.Time `json:"createdAt"`
+
+	DirectMessagesDisabled bool `json:"directMessagesDisabled"`
 }
 
 // ToResponse converts Batch to BatchResponse.
 func (b *Batch) ToResponse() BatchResponse {
 	return BatchResponse{
-		ID:           b.ID.Hex(),
-		Name:         b.Name,
-		Description:  b.Description,
-		PresenterID:  b.PresenterID.Hex(),
-		StudentCount: len(b.StudentIDs),
-		CreatedAt:    b.CreatedAt,
+		ID:            b.ID.Hex(),
+		Name:          b.Name,
+		Description:   b.Description,
+		PresenterID:   b.PresenterID.Hex(),
+		PresenterName: b.PresenterName,
+		StudentCount:  len(b.StudentIDs),
+		AssistantIDs:  hexIDs(b.AssistantIDs),
+		CreatedAt:     b.CreatedAt,
+
+		DirectMessagesDisabled: b.DirectMessagesDisabled,
 	}
 }
 
@@ -56,3 +95,49 @@ func (b *Batch) HasStudent(studentID string) bool {
 	return false
 }
 
+// HasAssistant checks if a user is a teaching assistant of the batch.
+func (b *Batch) HasAssistant(userID string) bool {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false
+	}
+	for _, id := range b.AssistantIDs {
+		if id == objID {
+			return true
+		}
+	}
+	return false
+}
+
+// Group returns the group with the given ID, or nil.
+func (b *Batch) Group(groupID primitive.ObjectID) *BatchGroup {
+	for i := range b.Groups {
+		if b.Groups[i].ID == groupID {
+			return &b.Groups[i]
+		}
+	}
+	return nil
+}
+
+// InGroups reports whether a student is in any of the groups, or no groups
+// are given. Groups that no longer exist match no one.
+func (b *Batch) InGroups(studentID primitive.ObjectID, groupIDs []primitive.ObjectID) bool {
+	if len(groupIDs) == 0 {
+		return true
+	}
+	for _, id := range groupIDs {
+		if g := b.Group(id); g != nil && g.HasStudent(studentID) {
+			return true
+		}
+	}
+	return false
+}
+
+// hexIDs converts object IDs to their hex strings.
+func hexIDs(ids []primitive.ObjectID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.Hex()
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlanFeature is a capability a billing plan can include.
+type PlanFeature string
+
+const (
+	FeatureRecording PlanFeature = "recording" // Class recordings
+	FeatureHLS       PlanFeature = "hls"       // HLS delivery of recordings
+)
+
+// Plan is a billing plan: how many students it seats and what it includes.
+type Plan struct {
+	ID        string        `bson:"_id" json:"id"` // Slug, e.g. "standard"
+	Name      string        `bson:"name" json:"name"`
+	Seats     int           `bson:"seats" json:"seats"`         // Students per subscription; 0 is unlimited
+	TrialDays int           `bson:"trialDays" json:"trialDays"` // Trial length of new subscriptions
+	Features  []PlanFeature `bson:"features" json:"features"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// HasFeature reports whether the plan includes a feature.
+func (p *Plan) HasFeature(feature PlanFeature) bool {
+	for _, f := range p.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionStatus is the billing state of a subscription.
+type SubscriptionStatus string
+
+const (
+	SubscriptionTrialing  SubscriptionStatus = "trialing"
+	SubscriptionActive    SubscriptionStatus = "active"
+	SubscriptionPastDue   SubscriptionStatus = "past_due" // Payment failed; the provider is retrying
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+)
+
+// Subscription puts an organization, or one of its batches, on a plan. A
+// batch's own subscription takes precedence over the organization's.
+type Subscription struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Org     string             `bson:"org" json:"org"`
+	BatchID primitive.ObjectID `bson:"batchId,omitempty" json:"batchId,omitempty"` // Zero for the organization's subscription
+	PlanID  string             `bson:"planId" json:"planId"`
+	Status  SubscriptionStatus `bson:"status" json:"status"`
+	Seats   int                `bson:"seats" json:"seats"` // Seats bought; 0 uses the plan's
+
+	TrialEndsAt      *time.Time `bson:"trialEndsAt,omitempty" json:"trialEndsAt,omitempty"`
+	CurrentPeriodEnd *time.Time `bson:"currentPeriodEnd,omitempty" json:"currentPeriodEnd,omitempty"` // Paid until
+
+	// Set for subscriptions managed by a payment provider's webhooks
+	Provider   string `bson:"provider,omitempty" json:"provider,omitempty"`
+	ExternalID string `bson:"externalId,omitempty" json:"externalId,omitempty"`
+
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Active reports whether the subscription entitles its holder to its plan
+// at now. Paid periods are honored for grace past their end, so a renewal
+// reported late doesn't cut off classes.
+func (s *Subscription) Active(now time.Time, grace time.Duration) bool {
+	switch s.Status {
+	case SubscriptionTrialing:
+		return s.TrialEndsAt == nil || now.Before(*s.TrialEndsAt)
+	case SubscriptionActive, SubscriptionPastDue:
+		return s.CurrentPeriodEnd == nil || now.Before(s.CurrentPeriodEnd.Add(grace))
+	default:
+		return false
+	}
+}
+
+// Entitlement is what a subscription grants next to what is used of it.
+type Entitlement struct {
+	SubscriptionID   string             `json:"subscriptionId"`
+	Org              string             `json:"org"`
+	BatchID          string             `json:"batchId,omitempty"`
+	BatchName        string             `json:"batchName,omitempty"`
+	PlanID           string             `json:"planId"`
+	PlanName         string             `json:"planName"`
+	Status           SubscriptionStatus `json:"status"`
+	Active           bool               `json:"active"`
+	Seats            int                `json:"seats"` // 0 is unlimited
+	SeatsUsed        int                `json:"seatsUsed"`
+	Features         []PlanFeature      `json:"features"`
+	TrialEndsAt      *time.Time         `json:"trialEndsAt,omitempty"`
+	CurrentPeriodEnd *time.Time         `json:"currentPeriodEnd,omitempty"`
+}
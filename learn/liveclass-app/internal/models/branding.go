@@ -0,0 +1,18 @@
+// Package models defines data models for the application.
+package models
+
+import "time"
+
+// Branding customizes the portal for a white-label deployment.
+type Branding struct {
+	Org           string    `bson:"_id" json:"-"` // Instance the branding applies to
+	PortalName    string    `bson:"portalName" json:"portalName"`
+	PrimaryColor  string    `bson:"primaryColor,omitempty" json:"primaryColor,omitempty"` // CSS hex color
+	AccentColor   string    `bson:"accentColor,omitempty" json:"accentColor,omitempty"`
+	EmailFooter   string    `bson:"emailFooter,omitempty" json:"emailFooter,omitempty"` // Plain text appended to emails
+	LogoPath      string    `bson:"logoPath,omitempty" json:"-"`                        // Internal path, not exposed
+	LogoMimeType  string    `bson:"logoMimeType,omitempty" json:"-"`
+	LogoURL       string    `bson:"-" json:"logoUrl,omitempty"`
+	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
+	UpdatedByName string    `bson:"updatedByName,omitempty" json:"updatedByName,omitempty"`
+}
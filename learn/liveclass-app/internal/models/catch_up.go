@@ -0,0 +1,61 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CatchUpSummary is a student's summary of one week: the classes they
+// missed, with the recordings to catch up from, the notes added to their
+// batches, and the classes coming up next.
+type CatchUpSummary struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	WeekStart   time.Time          `bson:"weekStart" json:"weekStart"` // Inclusive
+	WeekEnd     time.Time          `bson:"weekEnd" json:"weekEnd"`     // Exclusive
+	Missed      []MissedClass      `bson:"missed" json:"missed"`
+	NewNotes    []CatchUpNote      `bson:"newNotes" json:"newNotes"`
+	Upcoming    []UpcomingClass    `bson:"upcoming" json:"upcoming"` // Classes in the seven days after WeekEnd
+	GeneratedAt time.Time          `bson:"generatedAt" json:"generatedAt"`
+
+	// Set when the summary was sent; empty weeks are stored unsent
+	SentAt  *time.Time `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	Emailed bool       `bson:"emailed,omitempty" json:"emailed,omitempty"`
+}
+
+// MissedClass is a class that ran without the student.
+type MissedClass struct {
+	ScheduleID   string    `bson:"scheduleId" json:"scheduleId"`
+	Title        string    `bson:"title" json:"title"`
+	BatchName    string    `bson:"batchName,omitempty" json:"batchName,omitempty"`
+	StartTime    time.Time `bson:"startTime" json:"startTime"`
+	RecordingID  string    `bson:"recordingId,omitempty" json:"recordingId,omitempty"`
+	RecordingURL string    `bson:"recordingUrl,omitempty" json:"recordingUrl,omitempty"`
+	Watched      bool      `bson:"watched" json:"watched"` // Caught up on the recording already
+}
+
+// CatchUpNote is a note that became available to the student.
+type CatchUpNote struct {
+	NoteID      string    `bson:"noteId" json:"noteId"`
+	Title       string    `bson:"title" json:"title"`
+	BatchName   string    `bson:"batchName,omitempty" json:"batchName,omitempty"`
+	DownloadURL string    `bson:"downloadUrl" json:"downloadUrl"`
+	AddedAt     time.Time `bson:"addedAt" json:"addedAt"` // Published, or uploaded if never held back
+}
+
+// UpcomingClass is a class the student is expected at. Exams and proctored
+// classes are the week's deadlines.
+type UpcomingClass struct {
+	ScheduleID string    `bson:"scheduleId" json:"scheduleId"`
+	Title      string    `bson:"title" json:"title"`
+	BatchName  string    `bson:"batchName,omitempty" json:"batchName,omitempty"`
+	StartTime  time.Time `bson:"startTime" json:"startTime"`
+	Exam       bool      `bson:"exam" json:"exam"`
+}
+
+// IsEmpty reports whether there is nothing to catch up on or prepare for.
+func (s *CatchUpSummary) IsEmpty() bool {
+	return len(s.Missed) == 0 && len(s.NewNotes) == 0 && len(s.Upcoming) == 0
+}
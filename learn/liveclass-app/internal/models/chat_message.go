@@ -0,0 +1,27 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatMessage is a chat message posted in a live room, kept so students
+// who join late or watch the recording can read the discussion.
+type ChatMessage struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MessageID     string             `bson:"messageId" json:"messageId"` // As sent to the room
+	RoomID        string             `bson:"roomId" json:"roomId"`
+	ScheduleID    primitive.ObjectID `bson:"scheduleId,omitempty" json:"scheduleId,omitempty"` // Zero outside scheduled classes
+	ParticipantID string             `bson:"participantId" json:"senderId"`
+	UserID        string             `bson:"userId,omitempty" json:"-"` // Empty for anonymous joins
+	SenderName    string             `bson:"senderName" json:"senderName"`
+	Body          string             `bson:"body" json:"message"`                        // Sanitized
+	Private       bool               `bson:"private,omitempty" json:"private,omitempty"` // Sent to the presenter only, during an exam
+	SentAt        time.Time          `bson:"sentAt" json:"sentAt"`
+
+	// Set when the message is deleted, which also clears the body
+	DeletedAt *time.Time `bson:"deletedAt,omitempty" json:"-"`
+	DeletedBy string     `bson:"deletedBy,omitempty" json:"-"`
+}
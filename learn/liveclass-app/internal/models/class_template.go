@@ -0,0 +1,45 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Class template limits.
+const (
+	MaxTemplateObjectives = 20
+	MaxTemplateMaterials  = 20
+)
+
+// LearningObjective is something a class sets out to teach. The ID is kept
+// when a template is edited so coverage reports roll up across versions.
+type LearningObjective struct {
+	ID   string `bson:"id" json:"id"`
+	Text string `bson:"text" json:"text" validate:"required,max=300"`
+}
+
+// ClassMaterial is a link handed out with a class (slides, reading, etc.).
+type ClassMaterial struct {
+	Title string `bson:"title" json:"title" validate:"required,max=200"`
+	URL   string `bson:"url" json:"url" validate:"required,url"`
+}
+
+// ClassTemplate is a reusable class outline a presenter schedules from.
+type ClassTemplate struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Title           string              `bson:"title" json:"title"`
+	Description     string              `bson:"description" json:"description"`
+	DurationMinutes int                 `bson:"durationMinutes" json:"durationMinutes"`
+	Objectives      []LearningObjective `bson:"objectives" json:"objectives"`
+	Materials       []ClassMaterial     `bson:"materials" json:"materials"`
+	PresenterID     primitive.ObjectID  `bson:"presenterId" json:"presenterId"`
+	CreatedAt       time.Time           `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Duration returns the template's default class length.
+func (t *ClassTemplate) Duration() time.Duration {
+	return time.Duration(t.DurationMinutes) * time.Minute
+}
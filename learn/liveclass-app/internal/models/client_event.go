@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Client-side telemetry event types
+const (
+	ClientEventPageView    = "page_view"    // A route of the SPA was shown
+	ClientEventPlayerError = "player_error" // A recording or live stream failed to play
+	ClientEventJoinFailure = "join_failure" // Joining a live class failed
+)
+
+// ClientEvent is telemetry reported by the SPA, for failures that never
+// reach the server otherwise.
+type ClientEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type        string             `bson:"type" json:"type"`
+	Path        string             `bson:"path,omitempty" json:"path,omitempty"`       // SPA route
+	Message     string             `bson:"message,omitempty" json:"message,omitempty"` // Error message
+	Code        string             `bson:"code,omitempty" json:"code,omitempty"`       // e.g. a media error code or ICE state
+	RoomID      string             `bson:"roomId,omitempty" json:"roomId,omitempty"`
+	RecordingID string             `bson:"recordingId,omitempty" json:"recordingId,omitempty"`
+	SessionID   string             `bson:"sessionId,omitempty" json:"sessionId,omitempty"` // One page load, chosen by the client
+	Release     string             `bson:"release,omitempty" json:"release,omitempty"`     // SPA build
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Role        UserRole           `bson:"role" json:"role"`
+	UserAgent   string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	Instance    string             `bson:"instance" json:"instance"`
+	SampleRate  float64            `bson:"sampleRate" json:"sampleRate"` // Share of such events kept
+	OccurredAt  time.Time          `bson:"occurredAt" json:"occurredAt"` // Client clock, bounded by ReceivedAt
+	ReceivedAt  time.Time          `bson:"receivedAt" json:"receivedAt"`
+}
+
+// Weight is how many events this one stands for, given sampling.
+func (e *ClientEvent) Weight() float64 {
+	if e.SampleRate <= 0 {
+		return 1
+	}
+	return 1 / e.SampleRate
+}
@@ -0,0 +1,52 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ClassRollup is the attendance and engagement of one class scheduled from
+// a template, aggregated after the class so cohort reports don't rescan raw
+// activity. Rollups of recent classes are refreshed while their recordings
+// are still being watched.
+type ClassRollup struct {
+	ScheduleID primitive.ObjectID `bson:"_id" json:"scheduleId"`
+	TemplateID primitive.ObjectID `bson:"templateId" json:"templateId"`
+	BatchID    primitive.ObjectID `bson:"batchId" json:"batchId"`
+	StartTime  time.Time          `bson:"startTime" json:"startTime"`
+
+	StudentIDs  []primitive.ObjectID `bson:"studentIds" json:"-"`            // Enrolled in the batch
+	AttendeeIDs []primitive.ObjectID `bson:"attendeeIds" json:"-"`           // Enrolled students who joined live
+	LiveMinutes float64              `bson:"liveMinutes" json:"liveMinutes"` // Attendees' time in the room, summed
+	PeakViewers int                  `bson:"peakViewers" json:"peakViewers"`
+
+	HasRecording         bool `bson:"hasRecording" json:"hasRecording"`
+	RecordingCompletions int  `bson:"recordingCompletions" json:"recordingCompletions"` // Enrolled students who finished the recording
+
+	ComputedAt time.Time `bson:"computedAt" json:"computedAt"`
+}
+
+// CohortStats compares one batch's run of a template with the others.
+// Rates are percentages.
+type CohortStats struct {
+	BatchID    string    `json:"batchId"`
+	BatchName  string    `json:"batchName"`
+	Classes    int       `json:"classes"`
+	FirstClass time.Time `json:"firstClass"`
+	LastClass  time.Time `json:"lastClass"`
+	Students   int       `json:"students"` // Enrolled in any of the classes
+
+	AttendanceRate          float64 `json:"attendanceRate"`          // Enrolled seats filled live, across classes
+	MinutesPerAttendee      float64 `json:"minutesPerAttendee"`      // Average time in class of those who joined
+	RecordingCompletionRate float64 `json:"recordingCompletionRate"` // Over classes with a recording
+	AveragePeakViewers      float64 `json:"averagePeakViewers"`
+
+	// Students by the share of their classes they attended: under 25%,
+	// 25-49%, 50-74% and 75% or more
+	AttendanceDistribution [4]int `json:"attendanceDistribution"`
+
+	AttendanceDelta float64 `json:"attendanceDelta"` // Points above or below the average cohort
+	Underperforming bool    `json:"underperforming"`
+}
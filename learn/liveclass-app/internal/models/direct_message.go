@@ -0,0 +1,55 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxDirectMessageLength is the maximum length of a direct message body.
+const MaxDirectMessageLength = 4000
+
+// DirectMessage represents a private 1:1 message between a presenter and a student
+// within the context of a batch.
+type DirectMessage struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BatchID     primitive.ObjectID `bson:"batchId" json:"batchId"`
+	SenderID    primitive.ObjectID `bson:"senderId" json:"senderId"`
+	RecipientID primitive.ObjectID `bson:"recipientId" json:"recipientId"`
+	Body        string             `bson:"body" json:"body"`
+	ReadAt      *time.Time         `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// DirectMessageResponse is the API response for a direct message.
+type DirectMessageResponse struct {
+	ID          string     `json:"id"`
+	BatchID     string     `json:"batchId"`
+	SenderID    string     `json:"senderId"`
+	SenderName  string     `json:"senderName,omitempty"`
+	RecipientID string     `json:"recipientId"`
+	Body        string     `json:"body"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// ToResponse converts DirectMessage to DirectMessageResponse.
+func (m *DirectMessage) ToResponse() DirectMessageResponse {
+	return DirectMessageResponse{
+		ID:          m.ID.Hex(),
+		BatchID:     m.BatchID.Hex(),
+		SenderID:    m.SenderID.Hex(),
+		RecipientID: m.RecipientID.Hex(),
+		Body:        m.Body,
+		ReadAt:      m.ReadAt,
+		CreatedAt:   m.CreatedAt,
+	}
+}
+
+// UnreadCount is the number of unread direct messages from one sender in one batch.
+type UnreadCount struct {
+	BatchID  string `json:"batchId"`
+	SenderID string `json:"senderId"`
+	Count    int    `json:"count"`
+}
@@ -0,0 +1,36 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Exam audit event types recorded by the server. Clients may report their own
+// events (e.g. "focus-lost", "focus-regained") which are stored as-is.
+const (
+	ExamEventJoin            = "join"
+	ExamEventLeave           = "leave"
+	ExamEventSessionReplaced = "session-replaced"
+	ExamEventLateEntryDenied = "late-entry-denied"
+)
+
+// Exam audit event sources.
+const (
+	ExamSourceServer = "server"
+	ExamSourceClient = "client"
+)
+
+// ExamAuditEvent is a single entry in the audit trail of an exam-mode class.
+type ExamAuditEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID    primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	UserID        primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	ParticipantID string             `bson:"participantId,omitempty" json:"participantId,omitempty"`
+	Name          string             `bson:"name" json:"name"`
+	Event         string             `bson:"event" json:"event"`
+	Detail        string             `bson:"detail,omitempty" json:"detail,omitempty"`
+	Source        string             `bson:"source" json:"source"`
+	At            time.Time          `bson:"at" json:"at"`
+}
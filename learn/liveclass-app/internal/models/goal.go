@@ -0,0 +1,78 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GoalKind is what a weekly goal counts.
+type GoalKind string
+
+const (
+	GoalAttendClasses   GoalKind = "attend-classes"
+	GoalWatchRecordings GoalKind = "watch-recordings"
+)
+
+// MaxGoalTarget bounds a weekly goal target.
+const MaxGoalTarget = 50
+
+// Activity returns the kind of activity that counts towards the goal.
+func (k GoalKind) Activity() ActivityKind {
+	switch k {
+	case GoalAttendClasses:
+		return ActivityAttended
+	case GoalWatchRecordings:
+		return ActivityWatched
+	}
+	return ""
+}
+
+// Goal is a student's weekly target. A student has at most one goal per kind.
+type Goal struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Kind      GoalKind           `bson:"kind" json:"kind"`
+	Target    int                `bson:"target" json:"target"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// ActivityKind is a kind of tracked student activity.
+type ActivityKind string
+
+const (
+	ActivityAttended ActivityKind = "attended" // Joined a live class; RefID is the schedule ID
+	ActivityWatched  ActivityKind = "watched"  // Completed a recording, see WatchProgress; RefID is the recording ID
+)
+
+// Activity records that a student attended a class or watched a recording in
+// a given week. Repeats within the same week count once.
+type Activity struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Kind      ActivityKind       `bson:"kind" json:"kind"`
+	RefID     string             `bson:"refId" json:"refId"`
+	WeekStart time.Time          `bson:"weekStart" json:"weekStart"`
+	At        time.Time          `bson:"at" json:"at"`
+}
+
+// GoalStatus is a goal's progress for the current week.
+type GoalStatus struct {
+	Kind     GoalKind `json:"kind"`
+	Target   int      `json:"target"`
+	Progress int      `json:"progress"`
+	Met      bool     `json:"met"`
+	// Streak is the number of consecutive weeks the goal was met, counting
+	// the current week once it is met.
+	Streak int `json:"streak"`
+}
+
+// WeekStart returns the start of the week (Monday 00:00 UTC) containing t.
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(day.Weekday()) + 6) % 7 // Days since Monday
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,24 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HandIn is an image of their work a student submitted during a live class,
+// kept with the class for grading afterwards.
+type HandIn struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID  primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	BatchID     primitive.ObjectID `bson:"batchId" json:"batchId"`
+	StudentID   primitive.ObjectID `bson:"studentId" json:"studentId"`
+	StudentName string             `bson:"studentName" json:"studentName"`
+	Caption     string             `bson:"caption,omitempty" json:"caption,omitempty"`
+	FilePath    string             `bson:"filePath" json:"-"` // Don't expose internal path
+	FileSize    int64              `bson:"fileSize" json:"fileSize"`
+	MimeType    string             `bson:"mimeType" json:"mimeType"`
+	Spotlighted bool               `bson:"spotlighted" json:"spotlighted"` // Shown to everyone in the room
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,60 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HoldContentType is the kind of content that can be placed under legal hold.
+type HoldContentType string
+
+const (
+	HoldContentRecording HoldContentType = "recording"
+	HoldContentNote      HoldContentType = "note"
+	HoldContentArchive   HoldContentType = "archive" // Chat archive of a class, keyed by schedule ID
+)
+
+// Legal hold audit actions.
+const (
+	LegalHoldPlaced        = "placed"
+	LegalHoldReleased      = "released"
+	LegalHoldDeleteBlocked = "delete-blocked"
+	LegalHoldEditBlocked   = "edit-blocked"
+)
+
+// LegalHold keeps a piece of content from being changed or deleted until an
+// admin lifts it.
+type LegalHold struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ContentType   HoldContentType    `bson:"contentType" json:"contentType"`
+	ContentID     string             `bson:"contentId" json:"contentId"`
+	Title         string             `bson:"title" json:"title"` // Content title when the hold was placed
+	Reason        string             `bson:"reason" json:"reason"`
+	CaseReference string             `bson:"caseReference,omitempty" json:"caseReference,omitempty"`
+	Active        bool               `bson:"active" json:"active"`
+
+	PlacedBy     primitive.ObjectID `bson:"placedBy" json:"placedBy"`
+	PlacedByName string             `bson:"placedByName" json:"placedByName"`
+	PlacedAt     time.Time          `bson:"placedAt" json:"placedAt"`
+
+	ReleasedBy     primitive.ObjectID `bson:"releasedBy,omitempty" json:"releasedBy,omitempty"`
+	ReleasedByName string             `bson:"releasedByName,omitempty" json:"releasedByName,omitempty"`
+	ReleasedAt     *time.Time         `bson:"releasedAt,omitempty" json:"releasedAt,omitempty"`
+	ReleaseReason  string             `bson:"releaseReason,omitempty" json:"releaseReason,omitempty"`
+}
+
+// LegalHoldEvent is an append-only audit record of a hold action or of a
+// change that a hold blocked.
+type LegalHoldEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	HoldID      primitive.ObjectID `bson:"holdId,omitempty" json:"holdId,omitempty"`
+	ContentType HoldContentType    `bson:"contentType" json:"contentType"`
+	ContentID   string             `bson:"contentId" json:"contentId"`
+	Action      string             `bson:"action" json:"action"`
+	ActorID     primitive.ObjectID `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	ActorName   string             `bson:"actorName" json:"actorName"`
+	Detail      string             `bson:"detail,omitempty" json:"detail,omitempty"`
+	At          time.Time          `bson:"at" json:"at"`
+}
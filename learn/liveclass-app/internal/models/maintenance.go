@@ -0,0 +1,51 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaintenanceWindow is a period an admin set aside for maintenance, when
+// live classes may be disrupted. Classes overlapping a blocking window
+// can't be scheduled; other windows only warn.
+type MaintenanceWindow struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title     string             `bson:"title" json:"title"`
+	Message   string             `bson:"message,omitempty" json:"message,omitempty"` // Shown on banners of affected classes
+	StartTime time.Time          `bson:"startTime" json:"startTime"`
+	EndTime   time.Time          `bson:"endTime" json:"endTime"`
+	Blocking  bool               `bson:"blocking" json:"blocking"`
+
+	CreatedBy     primitive.ObjectID `bson:"createdBy" json:"createdBy"`
+	CreatedByName string             `bson:"createdByName" json:"createdByName"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Overlaps reports whether the window overlaps the period from start to end.
+func (m *MaintenanceWindow) Overlaps(start, end time.Time) bool {
+	return m.StartTime.Before(end) && m.EndTime.After(start)
+}
+
+// Notice returns the window as shown on the banner of an affected class.
+func (m *MaintenanceWindow) Notice() MaintenanceNotice {
+	return MaintenanceNotice{
+		ID:        m.ID.Hex(),
+		Title:     m.Title,
+		Message:   m.Message,
+		StartTime: m.StartTime,
+		EndTime:   m.EndTime,
+		Blocking:  m.Blocking,
+	}
+}
+
+// MaintenanceNotice is a maintenance window overlapping a class.
+type MaintenanceNotice struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message,omitempty"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Blocking  bool      `json:"blocking"`
+}
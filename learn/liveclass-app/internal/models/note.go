@@ -17,11 +17,20 @@ const (
 	NoteTypeOther    NoteType = "other"
 )
 
+// NoteLibrary is the library a note is shared from for reuse across batches.
+type NoteLibrary string
+
+const (
+	NoteLibraryPersonal   NoteLibrary = "personal"   // Reusable by its uploader
+	NoteLibraryDepartment NoteLibrary = "department" // Reusable by all presenters
+)
+
 // Note represents a document/note uploaded by presenters or admins.
 type Note struct {
 	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Title        string             `bson:"title" json:"title"`
 	Description  string             `bson:"description,omitempty" json:"description"`
+	Topic        string             `bson:"topic,omitempty" json:"topic,omitempty"` // e.g. "Week 1/Slides"
 	FileName     string             `bson:"fileName" json:"fileName"`
 	FilePath     string             `bson:"filePath" json:"-"` // Don't expose internal path
 	FileSize     int64              `bson:"fileSize" json:"fileSize"`
@@ -35,6 +44,122 @@ type Note struct {
 	DownloadURL  string             `bson:"-" json:"downloadUrl"` // Generated, not stored
 	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
+	Hidden       bool               `bson:"hidden,omitempty" json:"hidden,omitempty"` // Hidden pending moderation review
+
+	// Materials held back from students until after a class, e.g. answer keys
+	Unpublished       bool                `bson:"unpublished,omitempty" json:"unpublished,omitempty"`
+	PublishScheduleID *primitive.ObjectID `bson:"publishScheduleId,omitempty" json:"publishScheduleId,omitempty"` // Published when this class ends
+	PublishAt         *time.Time          `bson:"publishAt,omitempty" json:"publishAt,omitempty"`                 // Published at this time, if not before
+	PublishedAt       *time.Time          `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
+
+	// Groups of BatchID the note is for; empty for all of its students.
+	// Students of batches the note is linked to aren't affected.
+	GroupIDs []primitive.ObjectID `bson:"groupIds,omitempty" json:"groupIds,omitempty"`
+
+	// Handed out in the live room of this class; published when it ends
+	HandoutScheduleID *primitive.ObjectID `bson:"handoutScheduleId,omitempty" json:"handoutScheduleId,omitempty"`
+
+	// Library items are attached to more batches by reference, not copied
+	Library        NoteLibrary          `bson:"library,omitempty" json:"library,omitempty"`
+	Department     string               `bson:"department,omitempty" json:"department,omitempty"`
+	LinkedBatchIDs []primitive.ObjectID `bson:"linkedBatchIds,omitempty" json:"linkedBatchIds,omitempty"`
+
+	// Smaller copies of images, made in the background after upload
+	Variants   []ImageVariant `bson:"variants,omitempty" json:"variants,omitempty"`
+	VariantsAt *time.Time     `bson:"variantsAt,omitempty" json:"-"` // When variants were claimed for generation
+
+	// PDF copy of Word and PowerPoint documents, converted in the background
+	// after upload so they can be previewed like PDFs
+	PDF *NotePDF `bson:"pdf,omitempty" json:"pdf,omitempty"`
+}
+
+// NotePDFStatus is where a note's PDF conversion is.
+type NotePDFStatus string
+
+const (
+	NotePDFConverting NotePDFStatus = "converting"
+	NotePDFReady      NotePDFStatus = "ready"
+	NotePDFFailed     NotePDFStatus = "failed"
+)
+
+// NotePDF is the PDF conversion of a document note.
+type NotePDF struct {
+	Status      NotePDFStatus `bson:"status" json:"status"`
+	FilePath    string        `bson:"filePath,omitempty" json:"-"`
+	FileSize    int64         `bson:"fileSize,omitempty" json:"fileSize,omitempty"`
+	Error       string        `bson:"error,omitempty" json:"error,omitempty"`
+	ClaimedAt   time.Time     `bson:"claimedAt" json:"-"`
+	ConvertedAt *time.Time    `bson:"convertedAt,omitempty" json:"convertedAt,omitempty"`
+}
+
+// ConvertibleMimeTypes are the Word and PowerPoint formats converted to PDF.
+var ConvertibleMimeTypes = []string{
+	"application/msword",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// Convertible reports whether the note is a document converted to PDF.
+func (n *Note) Convertible() bool {
+	for _, mimeType := range ConvertibleMimeTypes {
+		if n.MimeType == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageVariant is a resized copy of an image note, served to clients asking
+// for a smaller width.
+type ImageVariant struct {
+	Width    int    `bson:"width" json:"width"`
+	Height   int    `bson:"height" json:"height"`
+	FilePath string `bson:"filePath" json:"-"`
+	FileSize int64  `bson:"fileSize" json:"fileSize"`
+	MimeType string `bson:"mimeType" json:"mimeType"`
+}
+
+// FilePaths returns the stored files of the note: the upload, its variants
+// and its PDF copy.
+func (n *Note) FilePaths() []string {
+	paths := []string{n.FilePath}
+	for _, v := range n.Variants {
+		paths = append(paths, v.FilePath)
+	}
+	if n.PDF != nil && n.PDF.FilePath != "" {
+		paths = append(paths, n.PDF.FilePath)
+	}
+	return paths
+}
+
+// Variant returns the smallest variant at least width pixels wide, or nil if
+// the original should be served.
+func (n *Note) Variant(width int) *ImageVariant {
+	var best *ImageVariant
+	for i := range n.Variants {
+		v := &n.Variants[i]
+		if v.Width >= width && (best == nil || v.Width < best.Width) {
+			best = v
+		}
+	}
+	return best
+}
+
+// BatchIDs returns the batch the note was uploaded to and the batches it is
+// linked to.
+func (n *Note) BatchIDs() []primitive.ObjectID {
+	return append([]primitive.ObjectID{n.BatchID}, n.LinkedBatchIDs...)
+}
+
+// InBatch checks if the note was uploaded or linked to a batch.
+func (n *Note) InBatch(batchID primitive.ObjectID) bool {
+	for _, id := range n.BatchIDs() {
+		if id == batchID {
+			return true
+		}
+	}
+	return false
 }
 
 // GetNoteType determines the note type from MIME type.
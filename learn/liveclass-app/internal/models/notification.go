@@ -0,0 +1,122 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotificationCategory groups notifications by what triggered them.
+type NotificationCategory string
+
+const (
+	NotificationStorageAlert   NotificationCategory = "storage-alert"
+	NotificationContentHidden  NotificationCategory = "content-hidden"
+	NotificationRestored       NotificationCategory = "recording-restored"
+	NotificationNotesPublished NotificationCategory = "notes-published"
+	NotificationSessionRevoked NotificationCategory = "session-revoked"
+	NotificationClassStarting  NotificationCategory = "class-starting"
+	NotificationClassScheduled NotificationCategory = "class-scheduled"
+	NotificationClassReminder  NotificationCategory = "class-reminder"
+	NotificationRecordingReady NotificationCategory = "recording-ready"
+	NotificationCatchUp        NotificationCategory = "catch-up"
+	NotificationMaintenance    NotificationCategory = "maintenance-conflict"
+	NotificationAPIQuota       NotificationCategory = "api-quota"
+	NotificationClassHealth    NotificationCategory = "class-health"
+)
+
+// Notification is an in-app notification for a single user.
+type Notification struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID   `bson:"userId" json:"userId"`
+	Category  NotificationCategory `bson:"category" json:"category"`
+	Title     string               `bson:"title" json:"title"`
+	Body      string               `bson:"body" json:"body"`
+	Link      string               `bson:"link,omitempty" json:"link,omitempty"`
+	ReadAt    *time.Time           `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	CreatedAt time.Time            `bson:"createdAt" json:"createdAt"`
+
+	// Set while held back by the user's quiet hours
+	DeferredUntil *time.Time `bson:"deferredUntil,omitempty" json:"-"`
+	Email         bool       `bson:"email,omitempty" json:"-"` // Emailed once delivered
+}
+
+// QuietHours are when a user doesn't want to be disturbed. Notifications
+// arriving then are held back until they end, except urgent ones, which
+// come through unless MuteUrgent is set.
+type QuietHours struct {
+	Enabled  bool   `bson:"enabled" json:"enabled"`
+	Start    string `bson:"start" json:"start"`       // Daily, "22:00" in Timezone
+	End      string `bson:"end" json:"end"`           // "07:00"; before Start spans midnight
+	Timezone string `bson:"timezone" json:"timezone"` // IANA name, e.g. "Asia/Kolkata"
+
+	MuteUrgent        bool       `bson:"muteUrgent" json:"muteUrgent"`
+	DoNotDisturbUntil *time.Time `bson:"doNotDisturbUntil,omitempty" json:"doNotDisturbUntil,omitempty"`
+}
+
+// Until returns when the quiet time around now ends, or the zero time if
+// the user can be disturbed now. Do not disturb running into the daily
+// window is quiet until the window ends.
+func (q *QuietHours) Until(now time.Time) time.Time {
+	if q == nil {
+		return time.Time{}
+	}
+
+	var until time.Time
+	if q.DoNotDisturbUntil != nil && now.Before(*q.DoNotDisturbUntil) {
+		until = *q.DoNotDisturbUntil
+	}
+	if q.Enabled {
+		from := now
+		if !until.IsZero() {
+			from = until
+		}
+		if end, ok := q.windowEnd(from); ok {
+			until = end
+		}
+	}
+	return until
+}
+
+// windowEnd returns the end of the daily window t falls in, if it does.
+// Times are evaluated in the user's timezone, so windows follow DST.
+func (q *QuietHours) windowEnd(t time.Time) (time.Time, bool) {
+	start, err := ParseClock(q.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := ParseClock(q.End)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	at := func(days, minutes int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, minutes/60, minutes%60, 0, 0, loc)
+	}
+
+	switch {
+	case start < end && minute >= start && minute < end:
+		return at(0, end), true
+	case start > end && minute >= start: // Evening part of a window spanning midnight
+		return at(1, end), true
+	case start > end && minute < end:
+		return at(0, end), true
+	}
+	return time.Time{}, false
+}
+
+// ParseClock parses a time of day like "22:30" into minutes after midnight.
+func ParseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
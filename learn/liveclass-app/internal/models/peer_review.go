@@ -0,0 +1,86 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PeerReviewStatus is where the peer review of a class is.
+type PeerReviewStatus string
+
+// Peer review statuses
+const (
+	PeerReviewOpen     PeerReviewStatus = "open"     // Classmates are reviewing
+	PeerReviewReleased PeerReviewStatus = "released" // Scores are visible to students
+)
+
+// RubricCriterion is something reviewers score, from 0 to MaxPoints.
+type RubricCriterion struct {
+	Title       string `bson:"title" json:"title" validate:"required,max=100"`
+	Description string `bson:"description,omitempty" json:"description,omitempty" validate:"max=500"`
+	MaxPoints   int    `bson:"maxPoints" json:"maxPoints" validate:"min=1,max=100"`
+}
+
+// PeerReviewRound is the peer review of the work handed in to a class. Once
+// the class is over, each hand-in goes anonymously to a few classmates, who
+// score it against the rubric. The presenter moderates the reviews and
+// releases the scores to students.
+type PeerReviewRound struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID       primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	BatchID          primitive.ObjectID `bson:"batchId" json:"batchId"`
+	Rubric           []RubricCriterion  `bson:"rubric" json:"rubric"`
+	ReviewsPerHandIn int                `bson:"reviewsPerHandIn" json:"reviewsPerHandIn"`
+	DueAt            time.Time          `bson:"dueAt" json:"dueAt"` // Reviews are accepted until then
+	Status           PeerReviewStatus   `bson:"status" json:"status"`
+	CreatedBy        primitive.ObjectID `bson:"createdBy" json:"createdBy"`
+	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
+	ReleasedAt       *time.Time         `bson:"releasedAt,omitempty" json:"releasedAt,omitempty"`
+}
+
+// MaxPoints returns the most a hand-in can score.
+func (r *PeerReviewRound) MaxPoints() int {
+	total := 0
+	for _, criterion := range r.Rubric {
+		total += criterion.MaxPoints
+	}
+	return total
+}
+
+// AcceptsReviews reports whether reviews can be submitted at t.
+func (r *PeerReviewRound) AcceptsReviews(t time.Time) bool {
+	return r.Status == PeerReviewOpen && t.Before(r.DueAt)
+}
+
+// PeerReview is a classmate's review of a hand-in. Reviewers aren't told
+// whose work they review, nor students who reviewed theirs.
+type PeerReview struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	RoundID     primitive.ObjectID  `bson:"roundId" json:"roundId"`
+	HandInID    primitive.ObjectID  `bson:"handInId" json:"handInId"`
+	AuthorID    primitive.ObjectID  `bson:"authorId" json:"authorId"` // Student who handed the work in
+	ReviewerID  primitive.ObjectID  `bson:"reviewerId" json:"reviewerId"`
+	Scores      []int               `bson:"scores,omitempty" json:"scores,omitempty"` // Per rubric criterion
+	Comment     string              `bson:"comment,omitempty" json:"comment,omitempty"`
+	SubmittedAt *time.Time          `bson:"submittedAt,omitempty" json:"submittedAt,omitempty"`
+	Excluded    bool                `bson:"excluded" json:"excluded"` // Left out of the scores by the presenter
+	ModeratedBy *primitive.ObjectID `bson:"moderatedBy,omitempty" json:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time          `bson:"moderatedAt,omitempty" json:"moderatedAt,omitempty"`
+	CreatedAt   time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// Submitted reports whether the reviewer has scored the hand-in.
+func (p *PeerReview) Submitted() bool {
+	return p.SubmittedAt != nil
+}
+
+// Total returns the points the review gives.
+func (p *PeerReview) Total() int {
+	total := 0
+	for _, score := range p.Scores {
+		total += score
+	}
+	return total
+}
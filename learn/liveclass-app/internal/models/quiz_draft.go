@@ -0,0 +1,57 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QuizDraftStatus is where a quiz draft is in the presenter's review.
+type QuizDraftStatus string
+
+// Quiz draft statuses
+const (
+	QuizDraftPending   QuizDraftStatus = "pending"
+	QuizDraftApproved  QuizDraftStatus = "approved"
+	QuizDraftDiscarded QuizDraftStatus = "discarded"
+)
+
+// QuizQuestion is a question of a quiz draft. Questions without choices are
+// open-ended; otherwise Answer is the index of the correct choice.
+type QuizQuestion struct {
+	Prompt      string   `bson:"prompt" json:"prompt"`
+	Choices     []string `bson:"choices,omitempty" json:"choices,omitempty"`
+	Answer      int      `bson:"answer" json:"answer"`
+	Explanation string   `bson:"explanation,omitempty" json:"explanation,omitempty"`
+}
+
+// Valid reports whether the question has a prompt and, if it has choices,
+// at least two of them and an answer among them.
+func (q *QuizQuestion) Valid() bool {
+	if q.Prompt == "" {
+		return false
+	}
+	if len(q.Choices) == 0 {
+		return true
+	}
+	return len(q.Choices) >= 2 && q.Answer >= 0 && q.Answer < len(q.Choices)
+}
+
+// QuizDraft holds quiz questions generated from a class transcript, waiting
+// for the presenter to edit and approve them or discard them. Students
+// never see drafts.
+type QuizDraft struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID  primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	BatchID     primitive.ObjectID `bson:"batchId" json:"batchId"`
+	PresenterID primitive.ObjectID `bson:"presenterId" json:"presenterId"`
+	ClassTitle  string             `bson:"classTitle" json:"classTitle"`
+	Provider    string             `bson:"provider" json:"provider"` // The generator that drafted it
+	Status      QuizDraftStatus    `bson:"status" json:"status"`
+	Questions   []QuizQuestion     `bson:"questions" json:"questions"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+
+	ReviewedBy *primitive.ObjectID `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time          `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+}
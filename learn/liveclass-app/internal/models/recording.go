@@ -15,6 +15,8 @@ const (
 	RecordingStatusProcessing RecordingStatus = "processing"
 	RecordingStatusReady      RecordingStatus = "ready"
 	RecordingStatusFailed     RecordingStatus = "failed"
+	RecordingStatusArchived   RecordingStatus = "archived"  // Moved to cold storage
+	RecordingStatusRestoring  RecordingStatus = "restoring" // Being restored from cold storage
 )
 
 // Recording represents a recorded class session.
@@ -34,39 +36,121 @@ type Recording struct {
 	RecordedAt  time.Time          `bson:"recordedAt" json:"recordedAt"`
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	Hidden      bool               `bson:"hidden,omitempty" json:"hidden,omitempty"` // Hidden pending moderation review
+
+	// Students of the batch allowed to watch, such as those of a remedial
+	// session; empty means the whole batch
+	AllowedStudents []primitive.ObjectID `bson:"allowedStudents,omitempty" json:"-"`
+
+	// Cold storage
+	ArchiveKey         string               `bson:"archiveKey,omitempty" json:"-"` // Object key in the cold tier
+	ArchivedAt         *time.Time           `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"`
+	RestoreETA         *time.Time           `bson:"restoreEta,omitempty" json:"restoreEta,omitempty"`
+	RestoreRequestedBy []primitive.ObjectID `bson:"restoreRequestedBy,omitempty" json:"-"` // Notified when the restore completes
+	RestoredAt         *time.Time           `bson:"restoredAt,omitempty" json:"restoredAt,omitempty"`
+
+	// Snapshots of the batch and presenter names, kept current by the name reconciler
+	BatchName     string `bson:"batchName,omitempty" json:"batchName,omitempty"`
+	PresenterName string `bson:"presenterName,omitempty" json:"presenterName,omitempty"`
+
+	// Consent given by the students of the class, when the presenter asked for it
+	Consent *RecordingConsent `bson:"consent,omitempty" json:"consent,omitempty"`
+
+	// Chapters shown in the player. Proposed chapters are detected from scene
+	// changes and wait for the presenter to accept or edit them.
+	Chapters          []Chapter  `bson:"chapters,omitempty" json:"chapters,omitempty"`
+	ProposedChapters  []Chapter  `bson:"proposedChapters,omitempty" json:"-"`
+	ChaptersScannedAt *time.Time `bson:"chaptersScannedAt,omitempty" json:"-"` // Set when detection is claimed
+
+	// Camera recording uploaded with a screen recording, while it is being
+	// composited into it
+	CameraPath         string     `bson:"cameraPath,omitempty" json:"-"`
+	CompositeClaimedAt *time.Time `bson:"compositeClaimedAt,omitempty" json:"-"`
+
+	// Smaller copies for students on slow or metered connections, made once
+	// the recording is ready
+	Variants          []RecordingVariant `bson:"variants,omitempty" json:"variants,omitempty"`
+	VariantsClaimedAt *time.Time         `bson:"variantsClaimedAt,omitempty" json:"-"` // Set when generation is claimed
+
+	// Set when the students were told the recording can be watched
+	AnnouncedAt *time.Time `bson:"announcedAt,omitempty" json:"-"`
+}
+
+// Names of recording variants
+const (
+	RecordingVariant480p  = "480p"  // Video scaled down to 480 lines
+	RecordingVariantAudio = "audio" // Audio only
+)
+
+// RecordingVariant is a smaller copy of a recording.
+type RecordingVariant struct {
+	Name     string `bson:"name" json:"name"`
+	FilePath string `bson:"filePath" json:"-"`
+	FileSize int64  `bson:"fileSize" json:"fileSize"`
+	MimeType string `bson:"mimeType" json:"mimeType"`
+}
+
+// Chapter marks where a section of a recording starts.
+type Chapter struct {
+	Start int    `bson:"start" json:"start" validate:"min=0"` // Seconds into the recording
+	Title string `bson:"title" json:"title" validate:"required,max=200"`
 }
 
 // RecordingResponse is the API response for a recording.
 type RecordingResponse struct {
-	ID            string          `json:"id"`
-	ScheduleID    string          `json:"scheduleId"`
-	BatchID       string          `json:"batchId"`
-	BatchName     string          `json:"batchName,omitempty"`
-	PresenterID   string          `json:"presenterId"`
-	PresenterName string          `json:"presenterName,omitempty"`
-	Title         string          `json:"title"`
-	Description   string          `json:"description"`
-	FileSize      int64           `json:"fileSize"`
-	Duration      int             `json:"duration"`
-	Status        RecordingStatus `json:"status"`
-	RecordedAt    time.Time       `json:"recordedAt"`
-	StreamURL     string          `json:"streamUrl,omitempty"`
+	ID            string             `json:"id"`
+	ScheduleID    string             `json:"scheduleId"`
+	BatchID       string             `json:"batchId"`
+	BatchName     string             `json:"batchName,omitempty"`
+	PresenterID   string             `json:"presenterId"`
+	PresenterName string             `json:"presenterName,omitempty"`
+	Title         string             `json:"title"`
+	Description   string             `json:"description"`
+	FileSize      int64              `json:"fileSize"`
+	Duration      int                `json:"duration"`
+	Status        RecordingStatus    `json:"status"`
+	RecordedAt    time.Time          `json:"recordedAt"`
+	StreamURL     string             `json:"streamUrl,omitempty"`
+	Hidden        bool               `json:"hidden,omitempty"`
+	ArchivedAt    *time.Time         `json:"archivedAt,omitempty"`
+	RestoreETA    *time.Time         `json:"restoreEta,omitempty"`
+	Consent       *ConsentSummary    `json:"consent,omitempty"`
+	Chapters      []Chapter          `json:"chapters,omitempty"`
+	Variants      []RecordingVariant `json:"variants,omitempty"` // Streamed with ?variant=
+	Progress      *WatchSummary      `json:"progress,omitempty"` // The student's own, when listing
+
+	// Whether only some students of the batch may watch, and which; the
+	// students are only shown to the presenter and admins
+	Restricted      bool     `json:"restricted,omitempty"`
+	AllowedStudents []string `json:"allowedStudents,omitempty"`
 }
 
 // ToResponse converts Recording to RecordingResponse.
 func (r *Recording) ToResponse() RecordingResponse {
-	return RecordingResponse{
-		ID:          r.ID.Hex(),
-		ScheduleID:  r.ScheduleID.Hex(),
-		BatchID:     r.BatchID.Hex(),
-		PresenterID: r.PresenterID.Hex(),
-		Title:       r.Title,
-		Description: r.Description,
-		FileSize:    r.FileSize,
-		Duration:    r.Duration,
-		Status:      r.Status,
-		RecordedAt:  r.RecordedAt,
+	resp := RecordingResponse{
+		ID:            r.ID.Hex(),
+		ScheduleID:    r.ScheduleID.Hex(),
+		BatchID:       r.BatchID.Hex(),
+		BatchName:     r.BatchName,
+		PresenterID:   r.PresenterID.Hex(),
+		PresenterName: r.PresenterName,
+		Title:         r.Title,
+		Description:   r.Description,
+		FileSize:      r.FileSize,
+		Duration:      r.Duration,
+		Status:        r.Status,
+		RecordedAt:    r.RecordedAt,
+		Hidden:        r.Hidden,
+		Restricted:    r.IsRestricted(),
+		ArchivedAt:    r.ArchivedAt,
+		RestoreETA:    r.RestoreETA,
+		Chapters:      r.Chapters,
+		Variants:      r.Variants,
 	}
+	if r.Consent != nil {
+		resp.Consent = r.Consent.Summary()
+	}
+	return resp
 }
 
 // IsReady checks if the recording is ready for playback.
@@ -74,6 +158,42 @@ func (r *Recording) IsReady() bool {
 	return r.Status == RecordingStatusReady
 }
 
+// Variant returns the recording's variant with the given name, if made.
+func (r *Recording) Variant(name string) (*RecordingVariant, bool) {
+	for i := range r.Variants {
+		if r.Variants[i].Name == name {
+			return &r.Variants[i], true
+		}
+	}
+	return nil, false
+}
+
+// IsRestricted reports whether only some students of the batch may watch
+// the recording.
+func (r *Recording) IsRestricted() bool {
+	return len(r.AllowedStudents) > 0
+}
+
+// AllowsStudent reports whether a student of the recording's batch may
+// watch it.
+func (r *Recording) AllowsStudent(studentID primitive.ObjectID) bool {
+	if !r.IsRestricted() {
+		return true
+	}
+	for _, id := range r.AllowedStudents {
+		if id == studentID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsArchived checks if the recording's file is in cold storage (archived or
+// being restored) rather than on local disk.
+func (r *Recording) IsArchived() bool {
+	return r.Status == RecordingStatusArchived || r.Status == RecordingStatusRestoring
+}
+
 // FormatDuration returns duration as a formatted string (e.g., "1h 30m").
 func (r *Recording) FormatDuration() string {
 	hours := r.Duration / 3600
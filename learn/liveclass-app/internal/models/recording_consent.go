@@ -0,0 +1,52 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RecordingConsent is a request for consent to record a live class, with the
+// answers of the students present. The request in effect when a recording is
+// uploaded is copied onto the Recording.
+type RecordingConsent struct {
+	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID           primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	RoomID               string             `bson:"roomId" json:"roomId"`
+	RequestedBy          primitive.ObjectID `bson:"requestedBy,omitempty" json:"requestedBy,omitempty"`
+	RequestedByName      string             `bson:"requestedByName" json:"requestedByName"`
+	ExcludeNonConsenting bool               `bson:"excludeNonConsenting" json:"excludeNonConsenting"` // Leave out the audio of students who didn't consent
+	RequestedAt          time.Time          `bson:"requestedAt" json:"requestedAt"`
+	Responses            []ConsentResponse  `bson:"responses" json:"responses"`
+}
+
+// ConsentResponse is a student's answer to a recording consent request. A
+// student answering again replaces their earlier answer.
+type ConsentResponse struct {
+	ParticipantID string             `bson:"participantId" json:"participantId"`
+	UserID        primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	Name          string             `bson:"name" json:"name"`
+	Consented     bool               `bson:"consented" json:"consented"`
+	RespondedAt   time.Time          `bson:"respondedAt" json:"respondedAt"`
+}
+
+// ConsentSummary counts the answers to a recording consent request.
+type ConsentSummary struct {
+	Consented            int  `json:"consented"`
+	Declined             int  `json:"declined"`
+	ExcludeNonConsenting bool `json:"excludeNonConsenting"`
+}
+
+// Summary counts the answers.
+func (c *RecordingConsent) Summary() *ConsentSummary {
+	summary := &ConsentSummary{ExcludeNonConsenting: c.ExcludeNonConsenting}
+	for _, resp := range c.Responses {
+		if resp.Consented {
+			summary.Consented++
+		} else {
+			summary.Declined++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,91 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReportContentType is the kind of content a report is about.
+type ReportContentType string
+
+const (
+	ReportContentChat      ReportContentType = "chat"
+	ReportContentNote      ReportContentType = "note"
+	ReportContentRecording ReportContentType = "recording"
+)
+
+// ReportStatus tracks a report through the moderation queue.
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusDismissed ReportStatus = "dismissed"
+	ReportStatusActioned  ReportStatus = "actioned"
+)
+
+// Moderation actions an admin can take on reported content.
+const (
+	ModerationDismiss = "dismiss"
+	ModerationDelete  = "delete"
+	ModerationSuspend = "suspend"
+)
+
+// ReportReasons are the reasons a user can pick when reporting content.
+var ReportReasons = []string{"spam", "harassment", "inappropriate", "copyright", "other"}
+
+// MaxReportDetailsLength bounds the free-text details of a report.
+const MaxReportDetailsLength = 1000
+
+// Report is a user's complaint about a chat message, note or recording.
+type Report struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ContentType ReportContentType  `bson:"contentType" json:"contentType"`
+	ContentID   string             `bson:"contentId" json:"contentId"`
+	RoomID      string             `bson:"roomId,omitempty" json:"roomId,omitempty"` // Chat only
+
+	// Snapshot of the content when it was reported, so the queue stays
+	// reviewable after a live chat message is gone
+	AuthorID   primitive.ObjectID `bson:"authorId,omitempty" json:"authorId,omitempty"`
+	AuthorName string             `bson:"authorName" json:"authorName"`
+	Excerpt    string             `bson:"excerpt" json:"excerpt"`
+
+	ReporterID   primitive.ObjectID `bson:"reporterId" json:"reporterId"`
+	ReporterName string             `bson:"reporterName" json:"reporterName"`
+	Reason       string             `bson:"reason" json:"reason"`
+	Details      string             `bson:"details,omitempty" json:"details,omitempty"`
+
+	Status     ReportStatus       `bson:"status" json:"status"`
+	Action     string             `bson:"action,omitempty" json:"action,omitempty"`
+	ResolvedBy primitive.ObjectID `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time         `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// ModerationItem is a piece of reported content in the admin moderation
+// queue, with its open reports grouped together.
+type ModerationItem struct {
+	ContentType     ReportContentType `json:"contentType"`
+	ContentID       string            `json:"contentId"`
+	RoomID          string            `json:"roomId,omitempty"`
+	AuthorID        string            `json:"authorId,omitempty"`
+	AuthorName      string            `json:"authorName"`
+	Excerpt         string            `json:"excerpt"`
+	Hidden          bool              `json:"hidden"`
+	ReportCount     int               `json:"reportCount"`
+	Reasons         map[string]int    `json:"reasons"`
+	Reports         []Report          `json:"reports"`
+	FirstReportedAt time.Time         `json:"firstReportedAt"`
+	LastReportedAt  time.Time         `json:"lastReportedAt"`
+}
+
+// ValidReportReason reports whether reason is one of ReportReasons.
+func ValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RoomEvent is an entry in the append-only lifecycle stream of live rooms:
+// rooms being created and ended, participants joining and leaving, the
+// presenter's stream becoming ready, and samples of connection quality. See
+// room.Lifecycle* for the event types.
+type RoomEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Type          string             `bson:"type" json:"type"`
+	RoomID        string             `bson:"roomId" json:"roomId"`
+	SessionID     string             `bson:"sessionId" json:"sessionId"` // One room from creation until it ends
+	Instance      string             `bson:"instance" json:"instance"`   // Server instance hosting the room
+	ParticipantID string             `bson:"participantId,omitempty" json:"participantId,omitempty"`
+	UserID        string             `bson:"userId,omitempty" json:"userId,omitempty"`
+	Name          string             `bson:"name,omitempty" json:"name,omitempty"`
+	Viewers       int                `bson:"viewers" json:"viewers"` // Viewers in the room after the event
+	At            time.Time          `bson:"at" json:"at"`
+
+	// Set on quality samples
+	Quality *QualitySample `bson:"quality,omitempty" json:"quality,omitempty"`
+}
+
+// QualitySample is the connection quality of a live room at one moment:
+// the loss on the presenter's uplink and how many viewers were in each
+// quality band.
+type QualitySample struct {
+	UplinkLossPercent float64 `bson:"uplinkLossPercent" json:"uplinkLossPercent"`
+	Uplink            string  `bson:"uplink" json:"uplink"` // good, fair, poor or unknown
+	Good              int     `bson:"good" json:"good"`
+	Fair              int     `bson:"fair" json:"fair"`
+	Poor              int     `bson:"poor" json:"poor"`
+	Disconnected      int     `bson:"disconnected" json:"disconnected"`
+}
@@ -28,8 +28,25 @@ type ScheduledClass struct {
 	EndTime     time.Time          `bson:"endTime" json:"endTime"`
 	Status      ClassStatus        `bson:"status" json:"status"`
 	RoomID      string             `bson:"roomId,omitempty" json:"roomId,omitempty"`
+	Proctored   bool               `bson:"proctored" json:"proctored"` // Students must verify identity before joining
+	ExamMode    bool               `bson:"examMode" json:"examMode"`   // Locked chat, no late entry, single device
+	LateEntry   int                `bson:"lateEntryMinutes" json:"lateEntryMinutes"`
+	ArchivePath string             `bson:"archivePath,omitempty" json:"-"` // Base path of the class archive bundle
+	LobbyRoomID string             `bson:"lobbyRoomId,omitempty" json:"-"` // Pre-class lobby, the live room once started
+	StatusFence int64              `bson:"statusFence,omitempty" json:"-"` // Fencing token of the lock the status was last set under
+	AnnouncedAt *time.Time         `bson:"announcedAt,omitempty" json:"-"` // Set when the students were told of the class
+	RemindedFor *time.Time         `bson:"remindedFor,omitempty" json:"-"` // Start time the students were last reminded of
 	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+
+	// Copied from the class template it was scheduled from, if any
+	TemplateID primitive.ObjectID  `bson:"templateId,omitempty" json:"templateId,omitempty"`
+	Objectives []LearningObjective `bson:"objectives,omitempty" json:"objectives,omitempty"`
+	Materials  []ClassMaterial     `bson:"materials,omitempty" json:"materials,omitempty"`
+
+	// Snapshots of the batch and presenter names, kept current by the name reconciler
+	BatchName     string `bson:"batchName,omitempty" json:"batchName,omitempty"`
+	PresenterName string `bson:"presenterName,omitempty" json:"presenterName,omitempty"`
 }
 
 // ScheduledClassResponse is the API response for a scheduled class.
@@ -46,30 +63,122 @@ type ScheduledClassResponse struct {
 	Status        ClassStatus `json:"status"`
 	RoomID        string      `json:"roomId,omitempty"`
 	CanJoin       bool        `json:"canJoin"`
+	Proctored     bool        `json:"proctored"`
+	ExamMode      bool        `json:"examMode"`
+	LateEntry     int         `json:"lateEntryMinutes,omitempty"`
+	ArchiveURL    string      `json:"archiveUrl,omitempty"`
+
+	TemplateID string              `json:"templateId,omitempty"`
+	Objectives []LearningObjective `json:"objectives,omitempty"`
+	Materials  []ClassMaterial     `json:"materials,omitempty"`
+
+	// Countdowns as of ServerTime, so clients don't depend on their own clock
+	ServerTime         time.Time `json:"serverTime"`
+	StartsInSeconds    int64     `json:"startsInSeconds"`    // 0 once started
+	EndsInSeconds      int64     `json:"endsInSeconds"`      // 0 once ended
+	JoinOpensInSeconds int64     `json:"joinOpensInSeconds"` // 0 once the join window is open
+
+	// Maintenance windows overlapping the class, for a banner
+	Maintenance []MaintenanceNotice `json:"maintenance,omitempty"`
 }
 
+// JoinWindow is how long before its start time a class can be joined.
+const JoinWindow = 15 * time.Minute
+
+// LobbyWindow is how long before its start time a class's lobby opens.
+const LobbyWindow = 30 * time.Minute
+
 // ToResponse converts ScheduledClass to ScheduledClassResponse.
 func (s *ScheduledClass) ToResponse() ScheduledClassResponse {
+	return s.ToResponseAt(time.Now())
+}
+
+// ResponseFor converts ScheduledClass to the response a user with role sees
+// at now. Students aren't told the room of a proctored class; joining hands
+// it out once their identity is verified.
+func (s *ScheduledClass) ResponseFor(role UserRole, now time.Time) ScheduledClassResponse {
+	resp := s.ToResponseAt(now)
+	if s.Proctored && role == RoleStudent {
+		resp.RoomID = ""
+	}
+	return resp
+}
+
+// ToResponseAt converts ScheduledClass to ScheduledClassResponse with status
+// and countdowns evaluated at now.
+func (s *ScheduledClass) ToResponseAt(now time.Time) ScheduledClassResponse {
+	var archiveURL string
+	if s.ArchivePath != "" {
+		archiveURL = "/api/schedules/" + s.ID.Hex() + "/archive"
+	}
+
+	var templateID string
+	if !s.TemplateID.IsZero() {
+		templateID = s.TemplateID.Hex()
+	}
+
 	return ScheduledClassResponse{
-		ID:          s.ID.Hex(),
-		Title:       s.Title,
-		Description: s.Description,
-		BatchID:     s.BatchID.Hex(),
-		PresenterID: s.PresenterID.Hex(),
-		StartTime:   s.StartTime,
-		EndTime:     s.EndTime,
-		Status:      s.EffectiveStatus(),
-		RoomID:      s.RoomID,
-		CanJoin:     s.CanJoin(),
+		ID:            s.ID.Hex(),
+		Title:         s.Title,
+		Description:   s.Description,
+		BatchID:       s.BatchID.Hex(),
+		BatchName:     s.BatchName,
+		PresenterID:   s.PresenterID.Hex(),
+		PresenterName: s.PresenterName,
+		StartTime:     s.StartTime,
+		EndTime:       s.EndTime,
+		Status:        s.EffectiveStatusAt(now),
+		RoomID:        s.RoomID,
+		CanJoin:       s.CanJoinAt(now),
+		Proctored:     s.Proctored,
+		ExamMode:      s.ExamMode,
+		LateEntry:     s.LateEntry,
+		ArchiveURL:    archiveURL,
+		TemplateID:    templateID,
+		Objectives:    s.Objectives,
+		Materials:     s.Materials,
+
+		ServerTime:         now,
+		StartsInSeconds:    s.SecondsUntilStart(now),
+		EndsInSeconds:      s.SecondsUntilEnd(now),
+		JoinOpensInSeconds: secondsUntil(now, s.StartTime.Add(-JoinWindow)),
 	}
 }
 
+// SecondsUntilStart returns the whole seconds from now until the class starts, or 0 if it has.
+func (s *ScheduledClass) SecondsUntilStart(now time.Time) int64 {
+	return secondsUntil(now, s.StartTime)
+}
+
+// SecondsUntilLobbyOpens returns the whole seconds from now until the class's
+// lobby opens, or 0 if it has.
+func (s *ScheduledClass) SecondsUntilLobbyOpens(now time.Time) int64 {
+	return secondsUntil(now, s.StartTime.Add(-LobbyWindow))
+}
+
+// SecondsUntilEnd returns the whole seconds from now until the class ends, or 0 if it has.
+func (s *ScheduledClass) SecondsUntilEnd(now time.Time) int64 {
+	return secondsUntil(now, s.EndTime)
+}
+
+// secondsUntil returns the whole seconds from now until t, rounded up, or 0 if t has passed.
+func secondsUntil(now, t time.Time) int64 {
+	d := t.Sub(now)
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
 // EffectiveStatus returns the actual status considering time constraints.
 // If a class is marked "live" but time is over, return "completed".
 // If a class is "scheduled" but time is over, return "completed".
 func (s *ScheduledClass) EffectiveStatus() ClassStatus {
-	now := time.Now()
+	return s.EffectiveStatusAt(time.Now())
+}
 
+// EffectiveStatusAt returns the effective status at the given time.
+func (s *ScheduledClass) EffectiveStatusAt(now time.Time) ClassStatus {
 	// If already completed or cancelled, return as-is
 	if s.Status == ClassStatusCompleted || s.Status == ClassStatusCancelled {
 		return s.Status
@@ -86,8 +195,12 @@ func (s *ScheduledClass) EffectiveStatus() ClassStatus {
 
 // CanJoin checks if the class can be joined (within 15 min before start or during class).
 func (s *ScheduledClass) CanJoin() bool {
-	now := time.Now()
-	effectiveStatus := s.EffectiveStatus()
+	return s.CanJoinAt(time.Now())
+}
+
+// CanJoinAt checks if the class can be joined at the given time.
+func (s *ScheduledClass) CanJoinAt(now time.Time) bool {
+	effectiveStatus := s.EffectiveStatusAt(now)
 
 	// Can't join completed or cancelled classes
 	if effectiveStatus == ClassStatusCompleted || effectiveStatus == ClassStatusCancelled {
@@ -100,7 +213,7 @@ func (s *ScheduledClass) CanJoin() bool {
 	}
 
 	// Can join scheduled class within 15 min before start until end time
-	joinWindow := s.StartTime.Add(-15 * time.Minute)
+	joinWindow := s.StartTime.Add(-JoinWindow)
 	return now.After(joinWindow) && now.Before(s.EndTime)
 }
 
@@ -109,6 +222,11 @@ func (s *ScheduledClass) IsUpcoming() bool {
 	return s.Status == ClassStatusScheduled && time.Now().Before(s.StartTime)
 }
 
+// LateEntryDeadline returns the last moment a student may first join an exam.
+func (s *ScheduledClass) LateEntryDeadline() time.Time {
+	return s.StartTime.Add(time.Duration(s.LateEntry) * time.Minute)
+}
+
 // Duration returns the class duration.
 func (s *ScheduledClass) Duration() time.Duration {
 	return s.EndTime.Sub(s.StartTime)
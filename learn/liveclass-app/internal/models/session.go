@@ -0,0 +1,45 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a signed-in device of an account, created at login. Tokens carry
+// the session ID so a session can be signed out before its token expires.
+type Session struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"`
+	Role          UserRole           `bson:"role" json:"role"`
+	Device        string             `bson:"device" json:"device"` // Name shown in the list of signed-in devices
+	UserAgent     string             `bson:"userAgent,omitempty" json:"userAgent,omitempty"`
+	IP            string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt     time.Time          `bson:"expiresAt" json:"expiresAt"` // When the session's token expires
+	RevokedAt     *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	RevokedReason string             `bson:"revokedReason,omitempty" json:"revokedReason,omitempty"`
+
+	// Refresh tokens renewing the session's access tokens, stored hashed.
+	// Each refresh replaces the token; presenting the replaced one again
+	// means it leaked, and signs the session out.
+	RefreshHash         string     `bson:"refreshHash,omitempty" json:"-"`
+	PreviousRefreshHash string     `bson:"previousRefreshHash,omitempty" json:"-"`
+	RefreshedAt         *time.Time `bson:"refreshedAt,omitempty" json:"refreshedAt,omitempty"`
+}
+
+// Reasons a session was signed out.
+const (
+	SessionRevokedLimit       = "device-limit"  // A newer login exceeded the account's device limit
+	SessionRevokedSignOut     = "signed-out"    // The user signed the device out
+	SessionRevokedDeactivated = "deactivated"   // The user asked for the account to be deactivated
+	SessionRevokedLogout      = "logged-out"    // The user logged out on the device
+	SessionRevokedReuse       = "refresh-reuse" // A replaced refresh token was presented again
+	SessionRevokedSuspended   = "suspended"     // An admin suspended the account
+)
+
+// Active reports whether the session can still be used at t.
+func (s *Session) Active(t time.Time) bool {
+	return s.RevokedAt == nil && t.Before(s.ExpiresAt)
+}
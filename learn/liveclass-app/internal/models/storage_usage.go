@@ -0,0 +1,30 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StorageUsageEntry is the storage used by one batch or presenter.
+type StorageUsageEntry struct {
+	ID    primitive.ObjectID `bson:"id" json:"id"`
+	Name  string             `bson:"name" json:"name"`
+	Bytes int64              `bson:"bytes" json:"bytes"`
+	Files int                `bson:"files" json:"files"`
+}
+
+// StorageUsageSnapshot is the daily storage usage report.
+type StorageUsageSnapshot struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Date           time.Time           `bson:"date" json:"date"`             // Day of the report (UTC midnight)
+	TotalBytes     int64               `bson:"totalBytes" json:"totalBytes"` // Everything under the storage path
+	RecordingBytes int64               `bson:"recordingBytes" json:"recordingBytes"`
+	NoteBytes      int64               `bson:"noteBytes" json:"noteBytes"`
+	OtherBytes     int64               `bson:"otherBytes" json:"otherBytes"` // Archives, verification photos, untracked files
+	Batches        []StorageUsageEntry `bson:"batches" json:"batches"`
+	Presenters     []StorageUsageEntry `bson:"presenters" json:"presenters"`
+	AlertedBytes   int64               `bson:"alertedBytes,omitempty" json:"-"` // Highest threshold already alerted for this day
+	CreatedAt      time.Time           `bson:"createdAt" json:"createdAt"`
+}
@@ -24,6 +24,11 @@ const (
 	StatusApproved  UserStatus = "approved"
 	StatusRejected  UserStatus = "rejected"
 	StatusSuspended UserStatus = "suspended"
+
+	// StatusDeactivated is an account anonymized after its owner asked for
+	// it to be deactivated. Its ID stays, so batch membership and attendance
+	// still count it.
+	StatusDeactivated UserStatus = "deactivated"
 )
 
 // User represents a user in the system.
@@ -38,6 +43,9 @@ type User struct {
 	UpdatedAt    time.Time          `bson:"updatedAt" json:"updatedAt"`
 	ApprovedBy   primitive.ObjectID `bson:"approvedBy,omitempty" json:"approvedBy,omitempty"`
 	ApprovedAt   *time.Time         `bson:"approvedAt,omitempty" json:"approvedAt,omitempty"`
+	QuietHours   *QuietHours        `bson:"quietHours,omitempty" json:"quietHours,omitempty"`
+	CatchUpEmail bool               `bson:"catchUpEmail,omitempty" json:"catchUpEmail,omitempty"` // Weekly catch-up summary by email too
+	DeactivateAt *time.Time         `bson:"deactivateAt,omitempty" json:"deactivateAt,omitempty"` // Anonymized then unless the owner signs in to reactivate
 }
 
 // UserResponse is the safe user response without sensitive data.
@@ -72,6 +80,12 @@ func (u *User) CanAccessClassroom() bool {
 	return u.Status == StatusApproved && (u.Role == RolePresenter || u.Role == RoleStudent)
 }
 
+// Deactivating checks if the owner asked for the account to be deactivated
+// and it is still in its grace period.
+func (u *User) Deactivating() bool {
+	return u.DeactivateAt != nil && u.Status != StatusDeactivated
+}
+
 // IsAdmin checks if user is admin.
 func (u *User) IsAdmin() bool {
 	return u.Role == RoleAdmin
@@ -0,0 +1,47 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VerificationStatus is the state of a student's identity verification for a class.
+type VerificationStatus string
+
+const (
+	VerificationPending  VerificationStatus = "pending"
+	VerificationVerified VerificationStatus = "verified"
+	VerificationRejected VerificationStatus = "rejected"
+)
+
+// VerificationMethod is how a student proved their identity.
+type VerificationMethod string
+
+const (
+	VerificationMethodPhoto    VerificationMethod = "photo"
+	VerificationMethodProvider VerificationMethod = "provider"
+)
+
+// IdentityVerification records a student's identity check for a proctored class.
+type IdentityVerification struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID primitive.ObjectID `bson:"scheduleId" json:"scheduleId"`
+	StudentID  primitive.ObjectID `bson:"studentId" json:"studentId"`
+	Method     VerificationMethod `bson:"method" json:"method"`
+	Status     VerificationStatus `bson:"status" json:"status"`
+	PhotoPath  string             `bson:"photoPath,omitempty" json:"-"` // Don't expose internal path
+	Provider   string             `bson:"provider,omitempty" json:"provider,omitempty"`
+	Reference  string             `bson:"reference,omitempty" json:"reference,omitempty"`
+	Reason     string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	ReviewedBy primitive.ObjectID `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	VerifiedAt *time.Time         `bson:"verifiedAt,omitempty" json:"verifiedAt,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// IsVerified returns true if the student may join the proctored class.
+func (v *IdentityVerification) IsVerified() bool {
+	return v.Status == VerificationVerified
+}
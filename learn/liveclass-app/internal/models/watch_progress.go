@@ -0,0 +1,81 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WatchProgress is a student's verified watching of a recording, built from
+// the heartbeats the player sends while playing. Streaming alone can't tell a
+// download from watching.
+type WatchProgress struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"userId" json:"userId"`
+	RecordingID    primitive.ObjectID `bson:"recordingId" json:"recordingId"`
+	WatchedSeconds float64            `bson:"watchedSeconds" json:"watchedSeconds"` // Verified playback time, rewatching included
+	Watched        []WatchedRange     `bson:"watched" json:"watched"`               // Distinct parts watched, sorted and merged
+	Position       float64            `bson:"position" json:"position"`             // Last reported playback position
+	HeartbeatAt    time.Time          `bson:"heartbeatAt" json:"heartbeatAt"`
+	Completed      bool               `bson:"completed" json:"completed"`
+	CompletedAt    *time.Time         `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// WatchedRange is a part of a recording, in seconds from the start.
+type WatchedRange struct {
+	Start float64 `bson:"start" json:"start"`
+	End   float64 `bson:"end" json:"end"`
+}
+
+// WatchSummary is a student's progress through a recording.
+type WatchSummary struct {
+	WatchedSeconds float64 `json:"watchedSeconds"`
+	Percent        float64 `json:"percent"` // Share of the recording watched at least once
+	Position       float64 `json:"position"`
+	Completed      bool    `json:"completed"`
+}
+
+// AddWatched marks [start, end] as watched.
+func (p *WatchProgress) AddWatched(start, end float64) {
+	ranges := append(p.Watched, WatchedRange{Start: start, End: end})
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	merged := ranges[:1]
+	for _, rng := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if rng.Start <= last.End {
+			last.End = math.Max(last.End, rng.End)
+			continue
+		}
+		merged = append(merged, rng)
+	}
+	p.Watched = merged
+}
+
+// Coverage returns the share, from 0 to 100, of a recording of duration
+// seconds watched at least once.
+func (p *WatchProgress) Coverage(duration int) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	covered := 0.0
+	for _, rng := range p.Watched {
+		covered += math.Min(rng.End, float64(duration)) - math.Min(rng.Start, float64(duration))
+	}
+	return math.Min(100, math.Round(covered/float64(duration)*1000)/10)
+}
+
+// Summary summarizes the progress through a recording of duration seconds.
+func (p *WatchProgress) Summary(duration int) *WatchSummary {
+	return &WatchSummary{
+		WatchedSeconds: math.Round(p.WatchedSeconds),
+		Percent:        p.Coverage(duration),
+		Position:       p.Position,
+		Completed:      p.Completed,
+	}
+}
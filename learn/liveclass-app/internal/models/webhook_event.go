@@ -0,0 +1,38 @@
+// Package models defines data models for the application.
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookEventStatus is the processing state of an inbound webhook event.
+type WebhookEventStatus string
+
+// Webhook event states. Failed events are processed again if the sender
+// retries them; processed ones are answered from the stored result.
+const (
+	WebhookEventProcessing WebhookEventStatus = "processing"
+	WebhookEventProcessed  WebhookEventStatus = "processed"
+	WebhookEventFailed     WebhookEventStatus = "failed"
+)
+
+// WebhookEvent is an event an external system (payments, an LMS) posted to
+// the webhook inbox. Events are unique per source and event ID, so retried
+// deliveries are applied once.
+type WebhookEvent struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Source      string                 `bson:"source" json:"source"`
+	EventID     string                 `bson:"eventId" json:"eventId"` // Sender's ID of the event
+	Type        string                 `bson:"type" json:"type"`
+	Action      string                 `bson:"action" json:"action"`
+	Status      WebhookEventStatus     `bson:"status" json:"status"`
+	Attempts    int                    `bson:"attempts" json:"attempts"`
+	Result      map[string]interface{} `bson:"result,omitempty" json:"result,omitempty"`
+	Error       string                 `bson:"error,omitempty" json:"error,omitempty"`
+	ReceivedAt  time.Time              `bson:"receivedAt" json:"receivedAt"`
+	ClaimedAt   time.Time              `bson:"claimedAt" json:"-"` // When processing last started
+	ProcessedAt *time.Time             `bson:"processedAt,omitempty" json:"processedAt,omitempty"`
+	ExpiresAt   time.Time              `bson:"expiresAt" json:"-"` // When the record is dropped, ending deduplication
+}
@@ -0,0 +1,113 @@
+// Package names keeps the batch and presenter name snapshots stored on
+// batches, classes and recordings in line with the source documents.
+package names
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Reconciler rewrites stale name snapshots after renames. A pass only writes
+// documents whose snapshot differs, so it is cheap when nothing changed and
+// also backfills documents written before snapshots existed.
+type Reconciler struct {
+	batchRepo     *repository.BatchRepository
+	userRepo      *repository.UserRepository
+	scheduleRepo  *repository.ScheduleRepository
+	recordingRepo *repository.RecordingRepository
+	interval      time.Duration
+	wake          chan struct{}
+}
+
+// NewReconciler creates a reconciler running a full pass every interval.
+func NewReconciler(
+	batchRepo *repository.BatchRepository,
+	userRepo *repository.UserRepository,
+	scheduleRepo *repository.ScheduleRepository,
+	recordingRepo *repository.RecordingRepository,
+	interval time.Duration,
+) *Reconciler {
+	return &Reconciler{
+		batchRepo:     batchRepo,
+		userRepo:      userRepo,
+		scheduleRepo:  scheduleRepo,
+		recordingRepo: recordingRepo,
+		interval:      interval,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests a pass soon, e.g. after a batch or user was written.
+// Triggers arriving during a pass are coalesced into one more pass.
+func (r *Reconciler) Trigger() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles immediately, then every interval and when triggered, until
+// ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	r.reconcile(ctx)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reconcile(ctx)
+		case <-r.wake:
+			r.reconcile(ctx)
+		}
+	}
+}
+
+// reconcile runs one pass over all batches and presenters.
+func (r *Reconciler) reconcile(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	var updated int64
+	add := func(n int64, err error) {
+		if err != nil {
+			log.Printf("[Names] Failed to update snapshots: %v", err)
+		}
+		updated += n
+	}
+
+	batches, err := r.batchRepo.FindAll(ctx)
+	if err != nil {
+		log.Printf("[Names] Failed to load batches: %v", err)
+		return
+	}
+	for _, b := range batches {
+		add(r.scheduleRepo.SetBatchName(ctx, b.ID, b.Name))
+		add(r.recordingRepo.SetBatchName(ctx, b.ID, b.Name))
+	}
+
+	// Classes are run by presenters, or by admins on their behalf
+	for _, role := range []models.UserRole{models.RolePresenter, models.RoleAdmin} {
+		users, err := r.userRepo.FindAll(ctx, nil, &role)
+		if err != nil {
+			log.Printf("[Names] Failed to load %s users: %v", role, err)
+			continue
+		}
+		for _, u := range users {
+			add(r.batchRepo.SetPresenterName(ctx, u.ID, u.Name))
+			add(r.scheduleRepo.SetPresenterName(ctx, u.ID, u.Name))
+			add(r.recordingRepo.SetPresenterName(ctx, u.ID, u.Name))
+		}
+	}
+
+	if updated > 0 {
+		log.Printf("[Names] Updated %d name snapshots", updated)
+	}
+}
@@ -0,0 +1,144 @@
+// Package notes publishes class materials that presenters held back until
+// after a class, such as answer keys.
+package notes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Publisher publishes held-back notes when their class ends or their publish
+// time passes, and notifies the students of the batch.
+//
+// Notes are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Publisher struct {
+	noteRepo    *repository.NoteRepository
+	batchRepo   *repository.BatchRepository
+	userRepo    *repository.UserRepository
+	classEmails *notify.ClassEmails
+	interval    time.Duration
+}
+
+// NewPublisher creates a publisher checking for due notes every interval.
+func NewPublisher(
+	noteRepo *repository.NoteRepository,
+	batchRepo *repository.BatchRepository,
+	userRepo *repository.UserRepository,
+	classEmails *notify.ClassEmails,
+	interval time.Duration,
+) *Publisher {
+	return &Publisher{
+		noteRepo:    noteRepo,
+		batchRepo:   batchRepo,
+		userRepo:    userRepo,
+		classEmails: classEmails,
+		interval:    interval,
+	}
+}
+
+// Run publishes notes whose publish time has passed, immediately and then
+// every interval, until ctx is cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	p.publishDue(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.publishDue(ctx)
+		}
+	}
+}
+
+// PublishForClass publishes the notes held back until the class ends.
+func (p *Publisher) PublishForClass(ctx context.Context, scheduleID primitive.ObjectID) {
+	notes, err := p.noteRepo.FindUnpublishedForClass(ctx, scheduleID)
+	if err != nil {
+		log.Printf("[Notes] Failed to load notes to publish after class %s: %v", scheduleID.Hex(), err)
+		return
+	}
+	p.publish(ctx, notes)
+}
+
+// publishDue publishes the notes whose publish time has passed.
+func (p *Publisher) publishDue(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	notes, err := p.noteRepo.FindDueForPublish(ctx, time.Now())
+	if err != nil {
+		log.Printf("[Notes] Failed to load notes due for publishing: %v", err)
+		return
+	}
+	p.publish(ctx, notes)
+}
+
+// publish publishes notes and notifies each batch once.
+func (p *Publisher) publish(ctx context.Context, notes []*models.Note) {
+	published := make(map[primitive.ObjectID][]*models.Note)
+	for _, note := range notes {
+		ok, err := p.noteRepo.Publish(ctx, note.ID)
+		if err != nil {
+			log.Printf("[Notes] Failed to publish %s: %v", note.ID.Hex(), err)
+			continue
+		}
+		if !ok {
+			continue // Published by another instance
+		}
+		log.Printf("[Notes] Published: %s for batch %s", note.Title, note.BatchName)
+		published[note.BatchID] = append(published[note.BatchID], note)
+	}
+
+	for batchID, batchNotes := range published {
+		p.notifyBatch(ctx, batchID, batchNotes)
+	}
+}
+
+// notifyBatch tells the students of a batch that notes were published.
+// Notes targeted at groups are only announced to their members.
+func (p *Publisher) notifyBatch(ctx context.Context, batchID primitive.ObjectID, notes []*models.Note) {
+	batch, err := p.batchRepo.FindByID(ctx, batchID.Hex())
+	if err != nil {
+		log.Printf("[Notes] Failed to load batch %s: %v", batchID.Hex(), err)
+		return
+	}
+
+	// Students seeing the same notes get the same message
+	recipients := make(map[string][]models.User)
+	seen := make(map[string][]*models.Note)
+	for _, id := range batch.StudentIDs {
+		var visible []*models.Note
+		key := ""
+		for _, note := range notes {
+			if batch.InGroups(id, note.GroupIDs) {
+				visible = append(visible, note)
+				key += note.ID.Hex()
+			}
+		}
+		if len(visible) == 0 {
+			continue
+		}
+		user, err := p.userRepo.FindByID(ctx, id.Hex())
+		if err != nil {
+			continue
+		}
+
+		recipients[key] = append(recipients[key], *user)
+		seen[key] = visible
+	}
+
+	for key, students := range recipients {
+		p.classEmails.NotesPublished(ctx, students, batch, seen[key])
+	}
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// announceLookback bounds how long after they were created classes and
+	// recordings are announced, so those from before announcements were
+	// turned on aren't.
+	announceLookback = 6 * time.Hour
+	// announceBatch bounds the classes and recordings announced per run.
+	announceBatch = 200
+)
+
+// ClassEmails tells the students of a batch about its classes as they go
+// through their lifecycle: when a class is scheduled, shortly before it
+// starts, when it goes live, and when its recording or materials are
+// published. Messages are rendered from the embedded templates and
+// delivered in-app and, when enabled, by email.
+//
+// Classes and recordings are claimed with conditional updates, so instances
+// sharing the database can all run it.
+type ClassEmails struct {
+	notifier      *Notifier
+	scheduleRepo  *repository.ScheduleRepository
+	recordingRepo *repository.RecordingRepository
+	batchRepo     *repository.BatchRepository
+	email         bool
+	lead          time.Duration
+	interval      time.Duration
+}
+
+// NewClassEmails creates a ClassEmails checking for classes to announce or
+// remind of every interval. Students are reminded lead before a class
+// starts; a zero lead sends no reminders. Messages are only emailed when
+// email is set.
+func NewClassEmails(
+	notifier *Notifier,
+	scheduleRepo *repository.ScheduleRepository,
+	recordingRepo *repository.RecordingRepository,
+	batchRepo *repository.BatchRepository,
+	email bool,
+	lead, interval time.Duration,
+) *ClassEmails {
+	return &ClassEmails{
+		notifier:      notifier,
+		scheduleRepo:  scheduleRepo,
+		recordingRepo: recordingRepo,
+		batchRepo:     batchRepo,
+		email:         email,
+		lead:          lead,
+		interval:      interval,
+	}
+}
+
+// Run announces new classes and recordings and sends reminders,
+// immediately and then every interval, until ctx is cancelled.
+func (c *ClassEmails) Run(ctx context.Context) {
+	c.runOnce(ctx)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.runOnce(ctx)
+		}
+	}
+}
+
+func (c *ClassEmails) runOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	c.announceClasses(ctx)
+	c.remindClasses(ctx)
+	c.announceRecordings(ctx)
+}
+
+// announceClasses tells students of the classes scheduled for their batch.
+func (c *ClassEmails) announceClasses(ctx context.Context) {
+	schedules, err := c.scheduleRepo.FindUnannounced(ctx, time.Now().Add(-announceLookback), announceBatch)
+	if err != nil {
+		log.Printf("[Notify] Failed to load classes to announce: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		claimed, err := c.scheduleRepo.ClaimAnnouncement(ctx, schedule.ID)
+		if err != nil {
+			log.Printf("[Notify] Failed to claim announcement of class %s: %v", schedule.ID.Hex(), err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		c.notifyBatch(ctx, schedule.BatchID, nil, models.NotificationClassScheduled, map[string]any{
+			"Class": schedule,
+		})
+	}
+}
+
+// remindClasses reminds students of the classes starting within lead.
+// Classes scheduled within lead of their start aren't reminded of, since
+// students were just told of them.
+func (c *ClassEmails) remindClasses(ctx context.Context) {
+	if c.lead <= 0 {
+		return
+	}
+
+	now := time.Now()
+	schedules, err := c.scheduleRepo.FindStartingBetween(ctx, now, now.Add(c.lead))
+	if err != nil {
+		log.Printf("[Notify] Failed to load classes to remind of: %v", err)
+		return
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		if schedule.RemindedFor != nil && schedule.RemindedFor.Equal(schedule.StartTime) {
+			continue
+		}
+		if schedule.CreatedAt.After(schedule.StartTime.Add(-c.lead)) {
+			continue
+		}
+		claimed, err := c.scheduleRepo.ClaimReminder(ctx, schedule.ID, schedule.StartTime)
+		if err != nil {
+			log.Printf("[Notify] Failed to claim reminder of class %s: %v", schedule.ID.Hex(), err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		c.notifyBatch(ctx, schedule.BatchID, nil, models.NotificationClassReminder, map[string]any{
+			"Class":   schedule,
+			"Minutes": int(c.lead / time.Minute),
+		})
+	}
+}
+
+// announceRecordings tells students of the recordings ready to watch.
+// Recordings restricted to some students are only announced to them.
+func (c *ClassEmails) announceRecordings(ctx context.Context) {
+	recordings, err := c.recordingRepo.FindUnannounced(ctx, time.Now().Add(-announceLookback), announceBatch)
+	if err != nil {
+		log.Printf("[Notify] Failed to load recordings to announce: %v", err)
+		return
+	}
+
+	for i := range recordings {
+		recording := &recordings[i]
+		claimed, err := c.recordingRepo.ClaimAnnouncement(ctx, recording.ID)
+		if err != nil {
+			log.Printf("[Notify] Failed to claim announcement of recording %s: %v", recording.ID.Hex(), err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		c.notifyBatch(ctx, recording.BatchID, recording.AllowedStudents, models.NotificationRecordingReady, map[string]any{
+			"Recording": recording,
+		})
+	}
+}
+
+// ClassStarted tells the students of a class's batch that it has started.
+// It is urgent by default, so it comes through quiet hours.
+func (c *ClassEmails) ClassStarted(ctx context.Context, schedule *models.ScheduledClass) {
+	c.notifyBatch(ctx, schedule.BatchID, nil, models.NotificationClassStarting, map[string]any{
+		"Class": schedule,
+	})
+}
+
+// NotesPublished tells students that notes of their batch were published.
+func (c *ClassEmails) NotesPublished(ctx context.Context, students []models.User, batch *models.Batch, notes []*models.Note) {
+	c.send(ctx, students, models.NotificationNotesPublished, map[string]any{
+		"Batch": batch,
+		"Notes": notes,
+	})
+}
+
+// notifyBatch sends the message of a category to the students of a batch,
+// or only those of them in only when it isn't empty.
+func (c *ClassEmails) notifyBatch(ctx context.Context, batchID primitive.ObjectID, only []primitive.ObjectID, category models.NotificationCategory, data map[string]any) {
+	batch, err := c.batchRepo.FindByID(ctx, batchID.Hex())
+	if err != nil {
+		log.Printf("[Notify] Failed to load batch %s: %v", batchID.Hex(), err)
+		return
+	}
+
+	ids := batch.StudentIDs
+	if len(only) > 0 {
+		ids = only
+	}
+	var students []models.User
+	for _, id := range ids {
+		if user, err := c.notifier.userRepo.FindByID(ctx, id.Hex()); err == nil {
+			students = append(students, *user)
+		}
+	}
+
+	data["Batch"] = batch
+	c.send(ctx, students, category, data)
+}
+
+// send renders the message of a category and sends it to students.
+func (c *ClassEmails) send(ctx context.Context, students []models.User, category models.NotificationCategory, data map[string]any) {
+	if len(students) == 0 {
+		return
+	}
+
+	msg, err := Templated(category, data)
+	if err != nil {
+		log.Printf("[Notify] Failed to render %s notification: %v", category, err)
+		return
+	}
+	msg.Email = c.email
+	c.notifier.Notify(ctx, students, msg)
+}
@@ -0,0 +1,91 @@
+// Package notify delivers notifications to users in-app (persisted and pushed
+// over live connections) and by email.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email is a plain-text email message.
+type Email struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// SMTPMailer sends email through an SMTP server using PLAIN auth when a
+// username is configured.
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates a new SMTPMailer.
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     host + ":" + strconv.Itoa(port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send sends the email to all recipients.
+func (m *SMTPMailer) Send(ctx context.Context, email Email) error {
+	if len(email.To) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	if err := smtp.SendMail(m.addr, auth, m.from, email.To, m.message(email)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// message renders the RFC 5322 message, stripping line breaks from headers.
+func (m *SMTPMailer) message(email Email) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var b strings.Builder
+	b.WriteString("From: " + header.Replace(m.from) + "\r\n")
+	b.WriteString("To: " + header.Replace(strings.Join(email.To, ", ")) + "\r\n")
+	b.WriteString("Subject: " + header.Replace(email.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(email.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// LogMailer logs emails instead of sending them. It is used when SMTP isn't configured.
+type LogMailer struct{}
+
+// Send logs the email.
+func (LogMailer) Send(_ context.Context, email Email) error {
+	log.Printf("[Notify] SMTP not configured, email not sent to %d recipient(s): %s", len(email.To), email.Subject)
+	return nil
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// Message is a notification to deliver to one or more users.
+type Message struct {
+	Category models.NotificationCategory
+	Title    string
+	Body     string
+	Link     string
+	Email    bool // Also send the notification by email
+}
+
+// Branding supplies the portal name and footer of outgoing emails.
+type Branding interface {
+	EmailBranding(ctx context.Context) (portalName, footer string)
+}
+
+// Notifier stores in-app notifications, pushes them to connected users and
+// optionally emails them. Notifications for users in their quiet hours are
+// stored but held back until a Releaser delivers them.
+type Notifier struct {
+	repo     *repository.NotificationRepository
+	userRepo *repository.UserRepository
+	hub      *room.Hub
+	mailer   Mailer
+	branding Branding
+	urgent   map[models.NotificationCategory]bool
+}
+
+// NewNotifier creates a new Notifier. branding may be nil for unbranded
+// emails. Notifications of the urgent categories come through quiet hours
+// unless the user mutes them too.
+func NewNotifier(repo *repository.NotificationRepository, userRepo *repository.UserRepository, hub *room.Hub, mailer Mailer, branding Branding, urgent []string) *Notifier {
+	urgentCategories := make(map[models.NotificationCategory]bool, len(urgent))
+	for _, category := range urgent {
+		urgentCategories[models.NotificationCategory(category)] = true
+	}
+
+	return &Notifier{
+		repo:     repo,
+		userRepo: userRepo,
+		hub:      hub,
+		mailer:   mailer,
+		branding: branding,
+		urgent:   urgentCategories,
+	}
+}
+
+// UrgentCategories returns the categories that come through quiet hours.
+func (n *Notifier) UrgentCategories() []models.NotificationCategory {
+	categories := make([]models.NotificationCategory, 0, len(n.urgent))
+	for category := range n.urgent {
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+// QuietUntil returns when a user's quiet time around now ends for
+// notifications of category, or the zero time if they're delivered now.
+func (n *Notifier) QuietUntil(user *models.User, category models.NotificationCategory, now time.Time) time.Time {
+	if user.QuietHours == nil || (n.urgent[category] && !user.QuietHours.MuteUrgent) {
+		return time.Time{}
+	}
+	return user.QuietHours.Until(now)
+}
+
+// Notify delivers the message to the given users. Failures for one user are
+// logged and don't stop delivery to the others.
+func (n *Notifier) Notify(ctx context.Context, users []models.User, msg Message) {
+	recipients := make([]string, 0, len(users))
+	now := time.Now()
+
+	for i := range users {
+		user := &users[i]
+		notification := &models.Notification{
+			UserID:   user.ID,
+			Category: msg.Category,
+			Title:    msg.Title,
+			Body:     msg.Body,
+			Link:     msg.Link,
+		}
+		if until := n.QuietUntil(user, msg.Category, now); !until.IsZero() {
+			notification.DeferredUntil = &until
+			notification.Email = msg.Email
+		}
+		if err := n.repo.Create(ctx, notification); err != nil {
+			log.Printf("[Notify] Failed to store notification for %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		if notification.DeferredUntil != nil {
+			continue
+		}
+
+		n.push(notification)
+		if msg.Email && user.Email != "" {
+			recipients = append(recipients, user.Email)
+		}
+	}
+
+	n.email(ctx, msg, recipients)
+}
+
+// push sends a notification to the user's open connections.
+func (n *Notifier) push(notification *models.Notification) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "notification",
+		"payload": notification,
+	})
+	n.hub.SendToUser(notification.UserID.Hex(), data)
+}
+
+// email sends the message to each recipient.
+func (n *Notifier) email(ctx context.Context, msg Message, recipients []string) {
+	if len(recipients) == 0 {
+		return
+	}
+
+	subject, body := n.renderEmail(ctx, msg)
+	// One email per recipient so addresses aren't disclosed to each other
+	for _, to := range recipients {
+		if err := n.mailer.Send(ctx, Email{To: []string{to}, Subject: subject, Body: body}); err != nil {
+			log.Printf("[Notify] Failed to email %q notification: %v", msg.Title, err)
+		}
+	}
+}
+
+// renderEmail returns the email subject and body for msg, tagged with the
+// portal name and ending with the footer when branding is set.
+func (n *Notifier) renderEmail(ctx context.Context, msg Message) (string, string) {
+	subject, body := msg.Title, msg.Body
+	if msg.Link != "" {
+		body += "\n\n" + msg.Link
+	}
+	if n.branding == nil {
+		return subject, body
+	}
+
+	portalName, footer := n.branding.EmailBranding(ctx)
+	if portalName != "" {
+		subject = "[" + portalName + "] " + subject
+	}
+	if footer != "" {
+		body += "\n\n-- \n" + footer
+	}
+	return subject, body
+}
+
+// NotifyAdmins delivers the message to every approved admin.
+func (n *Notifier) NotifyAdmins(ctx context.Context, msg Message) {
+	status := models.StatusApproved
+	role := models.RoleAdmin
+
+	admins, err := n.userRepo.FindAll(ctx, &status, &role)
+	if err != nil {
+		log.Printf("[Notify] Failed to load admins: %v", err)
+		return
+	}
+
+	n.Notify(ctx, admins, msg)
+}
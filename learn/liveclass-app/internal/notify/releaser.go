@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// releaseBatch bounds the held-back notifications delivered per run.
+const releaseBatch = 500
+
+// Releaser delivers notifications held back by quiet hours once they end.
+//
+// Notifications are claimed with conditional updates, so instances sharing
+// the database can all run it.
+type Releaser struct {
+	notifier *Notifier
+	interval time.Duration
+}
+
+// NewReleaser creates a releaser checking for due notifications every
+// interval.
+func NewReleaser(notifier *Notifier, interval time.Duration) *Releaser {
+	return &Releaser{notifier: notifier, interval: interval}
+}
+
+// Run delivers due notifications, immediately and then every interval,
+// until ctx is cancelled.
+func (r *Releaser) Run(ctx context.Context) {
+	r.releaseDue(ctx)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.releaseDue(ctx)
+		}
+	}
+}
+
+// releaseDue delivers the notifications whose quiet hours have ended.
+func (r *Releaser) releaseDue(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	n := r.notifier
+	due, err := n.repo.FindDeferredDue(ctx, time.Now(), releaseBatch)
+	if err != nil {
+		log.Printf("[Notify] Failed to load held-back notifications: %v", err)
+		return
+	}
+
+	delivered := 0
+	for i := range due {
+		notification := &due[i]
+		released, err := n.repo.Release(ctx, notification.ID)
+		if err != nil {
+			log.Printf("[Notify] Failed to release notification %s: %v", notification.ID.Hex(), err)
+			continue
+		}
+		if !released {
+			continue
+		}
+
+		email := notification.Email
+		notification.DeferredUntil, notification.Email = nil, false
+		n.push(notification)
+		delivered++
+
+		if !email {
+			continue
+		}
+		user, err := n.userRepo.FindByID(ctx, notification.UserID.Hex())
+		if err != nil || user.Email == "" {
+			continue
+		}
+		n.email(ctx, Message{
+			Category: notification.Category,
+			Title:    notification.Title,
+			Body:     notification.Body,
+			Link:     notification.Link,
+		}, []string{user.Email})
+	}
+
+	if delivered > 0 {
+		log.Printf("[Notify] Delivered %d notification(s) held back by quiet hours", delivered)
+	}
+}
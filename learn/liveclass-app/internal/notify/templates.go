@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// templateFS holds the templates of notifications, one per category in
+// "<category>.tmpl", each defining its "title" and "body".
+//
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templateFuncs = template.FuncMap{
+	// when formats a time for messages, e.g. "Mon, Jan 2 15:04 UTC"
+	"when": func(t time.Time) string {
+		return t.UTC().Format("Mon, Jan 2 15:04 MST")
+	},
+	// minutes rounds seconds up to whole minutes
+	"minutes": func(seconds int) int {
+		return (seconds + 59) / 60
+	},
+}
+
+var templates = loadTemplates()
+
+// loadTemplates parses the embedded templates by category.
+func loadTemplates() map[models.NotificationCategory]*template.Template {
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+
+	parsed := make(map[models.NotificationCategory]*template.Template, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		category := models.NotificationCategory(strings.TrimSuffix(name, ".tmpl"))
+		parsed[category] = template.Must(template.New(name).Funcs(templateFuncs).ParseFS(templateFS, "templates/"+name))
+	}
+	return parsed
+}
+
+// Templated returns the message of a category, with its title and body
+// rendered from the category's template with data.
+func Templated(category models.NotificationCategory, data any) (Message, error) {
+	tmpl, ok := templates[category]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for %s notifications", category)
+	}
+
+	var title, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&title, "title", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s title: %w", category, err)
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render %s body: %w", category, err)
+	}
+
+	return Message{
+		Category: category,
+		Title:    strings.TrimSpace(title.String()),
+		Body:     strings.TrimSpace(body.String()),
+	}, nil
+}
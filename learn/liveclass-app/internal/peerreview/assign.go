@@ -0,0 +1,54 @@
+// Package peerreview distributes the work handed in to a class among
+// classmates for review, and turns their reviews into scores.
+package peerreview
+
+import (
+	"math/rand/v2"
+	"sort"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Assign picks up to n reviewers for each hand-in among the students who
+// handed in work, never its author, and returns the reviews to collect.
+// Hand-ins are spread so each student gets about the same number to review,
+// and no student reviews the same hand-in twice.
+func Assign(handIns []models.HandIn, n int) []models.PeerReview {
+	var reviewers []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for _, handIn := range handIns {
+		if !seen[handIn.StudentID] {
+			seen[handIn.StudentID] = true
+			reviewers = append(reviewers, handIn.StudentID)
+		}
+	}
+
+	// Shuffled, so ties in load are broken differently each time
+	order := rand.Perm(len(handIns))
+	rand.Shuffle(len(reviewers), func(i, j int) { reviewers[i], reviewers[j] = reviewers[j], reviewers[i] })
+
+	load := make(map[primitive.ObjectID]int, len(reviewers))
+	var reviews []models.PeerReview
+	for _, i := range order {
+		handIn := handIns[i]
+
+		candidates := make([]primitive.ObjectID, 0, len(reviewers))
+		for _, reviewer := range reviewers {
+			if reviewer != handIn.StudentID {
+				candidates = append(candidates, reviewer)
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool { return load[candidates[a]] < load[candidates[b]] })
+
+		for _, reviewer := range candidates[:min(n, len(candidates))] {
+			load[reviewer]++
+			reviews = append(reviews, models.PeerReview{
+				HandInID:   handIn.ID,
+				AuthorID:   handIn.StudentID,
+				ReviewerID: reviewer,
+			})
+		}
+	}
+	return reviews
+}
@@ -0,0 +1,105 @@
+package peerreview
+
+import (
+	"math"
+	"sort"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// outlierShare is how far, as a share of the most a hand-in can score,
+	// a review's total can be from the median of the hand-in's reviews
+	// before it is flagged for moderation.
+	outlierShare = 0.25
+	// minReviewsForOutliers is the fewest reviews of a hand-in in which an
+	// outlier can be told apart.
+	minReviewsForOutliers = 3
+)
+
+// Score is what a hand-in scored, from the reviews the presenter kept.
+type Score struct {
+	HandInID  primitive.ObjectID `json:"handInId"`
+	Criteria  []float64          `json:"criteria"` // Mean per rubric criterion
+	Total     float64            `json:"total"`
+	MaxPoints int                `json:"maxPoints"`
+	Reviews   int                `json:"reviews"` // Reviews counted
+}
+
+// Scores returns the score of each hand-in with reviews, from its
+// submitted reviews that weren't excluded.
+func Scores(round *models.PeerReviewRound, reviews []models.PeerReview) map[primitive.ObjectID]*Score {
+	scores := make(map[primitive.ObjectID]*Score)
+	for _, review := range reviews {
+		score, ok := scores[review.HandInID]
+		if !ok {
+			score = &Score{
+				HandInID:  review.HandInID,
+				Criteria:  make([]float64, len(round.Rubric)),
+				MaxPoints: round.MaxPoints(),
+			}
+			scores[review.HandInID] = score
+		}
+		if !review.Submitted() || review.Excluded || len(review.Scores) != len(round.Rubric) {
+			continue
+		}
+		for i, points := range review.Scores {
+			score.Criteria[i] += float64(points)
+		}
+		score.Reviews++
+	}
+
+	for _, score := range scores {
+		if score.Reviews == 0 {
+			continue
+		}
+		for i := range score.Criteria {
+			score.Criteria[i] = round2(score.Criteria[i] / float64(score.Reviews))
+			score.Total += score.Criteria[i]
+		}
+		score.Total = round2(score.Total)
+	}
+	return scores
+}
+
+// Outliers returns the IDs of submitted reviews whose total is far from
+// the median of the hand-in's reviews, for the presenter to check.
+// Excluded reviews are still flagged, so they can be told apart.
+func Outliers(round *models.PeerReviewRound, reviews []models.PeerReview) map[primitive.ObjectID]bool {
+	totals := make(map[primitive.ObjectID][]int)
+	for _, review := range reviews {
+		if review.Submitted() {
+			totals[review.HandInID] = append(totals[review.HandInID], review.Total())
+		}
+	}
+
+	limit := outlierShare * float64(round.MaxPoints())
+	outliers := make(map[primitive.ObjectID]bool)
+	for _, review := range reviews {
+		handInTotals := totals[review.HandInID]
+		if !review.Submitted() || len(handInTotals) < minReviewsForOutliers {
+			continue
+		}
+		if math.Abs(float64(review.Total())-median(handInTotals)) > limit {
+			outliers[review.ID] = true
+		}
+	}
+	return outliers
+}
+
+// median returns the median of values.
+func median(values []int) float64 {
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return float64(sorted[mid])
+	}
+	return float64(sorted[mid-1]+sorted[mid]) / 2
+}
+
+// round2 rounds to two decimals.
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,152 @@
+// Package pressure watches the server's CPU utilization and reports sustained
+// overload, so live rooms can shed optional work before media quality suffers.
+package pressure
+
+import (
+	"context"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+)
+
+var (
+	cpuUtilization = metrics.NewGauge(
+		"liveclass_cpu_utilization_ratio",
+		"Process CPU utilization over the last sample, as a fraction of all cores.",
+	)
+	underPressureGauge = metrics.NewGauge(
+		"liveclass_cpu_pressure",
+		"1 while the server is degrading rooms due to CPU pressure, else 0.",
+	)
+	pressureTransitions = metrics.NewCounterVec(
+		"liveclass_cpu_pressure_transitions_total",
+		"CPU pressure state changes by new state (degraded, restored).",
+		"state",
+	)
+)
+
+// Config controls sampling and hysteresis.
+type Config struct {
+	Interval time.Duration // Time between samples
+	High     float64       // Utilization (0-1) at or above which a sample counts as high
+	Low      float64       // Utilization (0-1) at or below which a sample counts as low
+	Sustain  int           // Consecutive high (or low) samples needed to change state
+}
+
+// Monitor samples process CPU utilization and calls onChange when the server
+// enters or leaves the pressure state.
+type Monitor struct {
+	cfg      Config
+	onChange func(underPressure bool, utilization float64)
+
+	mu            sync.RWMutex
+	underPressure bool
+	utilization   float64 // Of the last sample
+	highStreak    int
+	lowStreak     int
+}
+
+// NewMonitor creates a new CPU pressure monitor.
+func NewMonitor(cfg Config, onChange func(underPressure bool, utilization float64)) *Monitor {
+	if cfg.Sustain < 1 {
+		cfg.Sustain = 1
+	}
+	return &Monitor{cfg: cfg, onChange: onChange}
+}
+
+// Run samples until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	lastCPU, err := processCPUTime()
+	if err != nil {
+		log.Printf("⚠️ CPU pressure monitor disabled: %v", err)
+		return
+	}
+	lastWall := time.Now()
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cpu, err := processCPUTime()
+			if err != nil {
+				continue
+			}
+			now := time.Now()
+
+			wall := now.Sub(lastWall) * time.Duration(runtime.NumCPU())
+			if wall > 0 {
+				m.observe(float64(cpu-lastCPU) / float64(wall))
+			}
+			lastCPU, lastWall = cpu, now
+		}
+	}
+}
+
+// UnderPressure reports whether the server is currently degrading rooms.
+func (m *Monitor) UnderPressure() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.underPressure
+}
+
+// Utilization returns the CPU utilization (0-1) of the last sample.
+func (m *Monitor) Utilization() float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.utilization
+}
+
+// observe records a utilization sample and changes state after enough
+// consecutive samples beyond the thresholds.
+func (m *Monitor) observe(utilization float64) {
+	cpuUtilization.Set(utilization)
+
+	m.mu.Lock()
+	m.utilization = utilization
+	switch {
+	case utilization >= m.cfg.High:
+		m.highStreak++
+		m.lowStreak = 0
+	case utilization <= m.cfg.Low:
+		m.lowStreak++
+		m.highStreak = 0
+	default:
+		m.highStreak, m.lowStreak = 0, 0
+	}
+
+	changed := false
+	if !m.underPressure && m.highStreak >= m.cfg.Sustain {
+		m.underPressure, changed = true, true
+	} else if m.underPressure && m.lowStreak >= m.cfg.Sustain {
+		m.underPressure, changed = false, true
+	}
+	underPressure := m.underPressure
+	m.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if underPressure {
+		underPressureGauge.Set(1)
+		pressureTransitions.WithLabelValues("degraded").Inc()
+		log.Printf("🔥 CPU pressure: %.0f%% utilization, degrading live rooms", utilization*100)
+	} else {
+		underPressureGauge.Set(0)
+		pressureTransitions.WithLabelValues("restored").Inc()
+		log.Printf("✅ CPU pressure subsided: %.0f%% utilization, restoring live rooms", utilization*100)
+	}
+
+	if m.onChange != nil {
+		m.onChange(underPressure, utilization)
+	}
+}
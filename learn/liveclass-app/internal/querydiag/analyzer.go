@@ -0,0 +1,204 @@
+package querydiag
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// explainTop is how many of the slowest patterns each analysis explains.
+const explainTop = 10
+
+// rangeOperators don't pin a field to one value, so their fields go after
+// equality and sort fields in a suggested index.
+var rangeOperators = map[string]bool{
+	"$gt": true, "$gte": true, "$lt": true, "$lte": true,
+	"$ne": true, "$nin": true, "$exists": true, "$regex": true,
+}
+
+// Analyzer explains the slowest patterns of a profiler.
+type Analyzer struct {
+	profiler *Profiler
+	db       *mongo.Database
+	interval time.Duration
+}
+
+// NewAnalyzer creates an analyzer explaining patterns in db every interval.
+func NewAnalyzer(profiler *Profiler, db *mongo.Database, interval time.Duration) *Analyzer {
+	return &Analyzer{profiler: profiler, db: db, interval: interval}
+}
+
+// Run explains the slowest patterns every interval until ctx is cancelled.
+// Nothing is slow at startup, so the first run waits an interval.
+func (a *Analyzer) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Analyze(ctx)
+		}
+	}
+}
+
+// Analyze explains the slowest patterns and logs the indexes they miss.
+func (a *Analyzer) Analyze(ctx context.Context) {
+	patterns := a.profiler.Patterns()
+	if len(patterns) > explainTop {
+		patterns = patterns[:explainTop]
+	}
+
+	for i := range patterns {
+		pattern := &patterns[i]
+		plan, stages, err := a.explain(ctx, pattern)
+		now := time.Now()
+
+		suggestion := ""
+		if err == nil && (stages["COLLSCAN"] || stages["SORT"]) {
+			suggestion = suggestIndex(pattern.filter, pattern.sort)
+			if suggestion != "" {
+				log.Printf("[SlowQuery] %s on %s runs %s; consider an index %s", pattern.Filter, pattern.Collection, plan, suggestion)
+			}
+		}
+
+		a.profiler.update(pattern, func(stored *Pattern) {
+			stored.ExplainedAt = &now
+			stored.Plan, stored.SuggestedIndex, stored.ExplainError = plan, suggestion, ""
+			if err != nil {
+				stored.ExplainError = err.Error()
+			}
+		})
+	}
+}
+
+// explain returns the winning plan of a pattern's last filter and sort as
+// a find, e.g. "IXSCAN batchId_1 > FETCH", and its stages.
+func (a *Analyzer) explain(ctx context.Context, pattern *Pattern) (string, map[string]bool, error) {
+	find := bson.D{{Key: "find", Value: pattern.Collection}}
+	if len(pattern.filter) > 0 {
+		find = append(find, bson.E{Key: "filter", Value: pattern.filter})
+	}
+	if len(pattern.sort) > 0 {
+		find = append(find, bson.E{Key: "sort", Value: pattern.sort})
+	}
+
+	var result struct {
+		QueryPlanner struct {
+			WinningPlan bson.Raw `bson:"winningPlan"`
+		} `bson:"queryPlanner"`
+	}
+	err := a.db.RunCommand(ctx, bson.D{
+		{Key: "explain", Value: find},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&result)
+	if err != nil {
+		return "", nil, err
+	}
+
+	plan := result.QueryPlanner.WinningPlan
+	if queryPlan, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		plan = queryPlan // Slot-based execution nests the plan
+	}
+	if len(plan) == 0 {
+		return "", nil, fmt.Errorf("explain returned no plan")
+	}
+
+	var names []string
+	stages := make(map[string]bool)
+	for stage := plan; len(stage) > 0; {
+		name, _ := stage.Lookup("stage").StringValueOK()
+		stages[name] = true
+		if index, ok := stage.Lookup("indexName").StringValueOK(); ok {
+			name += " " + index
+		}
+		names = append(names, name)
+
+		next, ok := stage.Lookup("inputStage").DocumentOK()
+		if !ok {
+			// Follow the first branch of OR and merge stages
+			if inputs, ok := stage.Lookup("inputStages").ArrayOK(); ok {
+				if first, err := inputs.IndexErr(0); err == nil {
+					next, _ = first.Value().DocumentOK()
+				}
+			}
+		}
+		stage = next
+	}
+
+	// Stages are listed from the root; show them in execution order
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return strings.Join(names, " > "), stages, nil
+}
+
+// suggestIndex returns an index for a filter and sort following the
+// equality, sort, range rule, e.g. "{batchId: 1, startTime: -1}", or "" when
+// there are no fields to index.
+func suggestIndex(filter, sortDoc bson.Raw) string {
+	var equality, ranges []string
+	kind := make(map[string]string) // Field to "equality", "range" or "sort"
+
+	elements, _ := filter.Elements()
+	for _, element := range elements {
+		field := element.Key()
+		if strings.HasPrefix(field, "$") || kind[field] != "" {
+			continue // $and, $or, $text etc. aren't analyzed
+		}
+
+		kind[field] = "equality"
+		if ops, ok := element.Value().DocumentOK(); ok {
+			opElements, _ := ops.Elements()
+			for _, op := range opElements {
+				if rangeOperators[op.Key()] {
+					kind[field] = "range"
+				}
+			}
+		}
+		if kind[field] == "range" {
+			ranges = append(ranges, field)
+		} else {
+			equality = append(equality, field)
+		}
+	}
+
+	keys := make([]string, 0, len(elements))
+	for _, field := range equality {
+		keys = append(keys, field+": 1")
+	}
+
+	// Sorting on an equality field is a no-op; a range field that is also
+	// sorted on takes the sort position
+	sortElements, _ := sortDoc.Elements()
+	for _, element := range sortElements {
+		field := element.Key()
+		if kind[field] == "equality" {
+			continue
+		}
+		kind[field] = "sort"
+		direction := 1
+		if v, ok := element.Value().AsInt64OK(); ok && v < 0 {
+			direction = -1
+		}
+		keys = append(keys, fmt.Sprintf("%s: %d", field, direction))
+	}
+
+	for _, field := range ranges {
+		if kind[field] == "range" {
+			keys = append(keys, field+": 1")
+		}
+	}
+
+	if len(keys) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(keys, ", ") + "}"
+}
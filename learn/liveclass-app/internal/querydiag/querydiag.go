@@ -0,0 +1,311 @@
+// Package querydiag finds slow MongoDB queries and the indexes they miss.
+//
+// A Profiler watches every command the driver sends (see Monitor) and logs
+// reads and writes slower than a threshold, grouped into patterns by
+// collection and filter shape: the filter with its values replaced by "?",
+// so logs carry no user data. An Analyzer periodically explains the slowest
+// patterns and suggests an index for those that scan the collection or sort
+// in memory.
+package querydiag
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// maxPatterns bounds the slow query patterns kept; later new patterns are
+// only logged.
+const maxPatterns = 500
+
+// filterFields are where each watched command keeps its filter. Other
+// commands aren't profiled.
+var filterFields = map[string]string{
+	"find":          "filter",
+	"count":         "query",
+	"distinct":      "query",
+	"findAndModify": "query",
+	"aggregate":     "pipeline", // The first $match stage
+	"update":        "updates",  // The first statement's q
+	"delete":        "deletes",  // The first statement's q
+}
+
+// Pattern is a group of slow queries of the same shape.
+type Pattern struct {
+	Command    string        `json:"command"`
+	Collection string        `json:"collection"`
+	Filter     string        `json:"filter"` // Shape with values replaced by "?"
+	Sort       string        `json:"sort,omitempty"`
+	Count      int           `json:"count"`
+	Total      time.Duration `json:"-"`
+	Max        time.Duration `json:"-"`
+	TotalMs    int64         `json:"totalMs"`
+	MaxMs      int64         `json:"maxMs"`
+	LastSeen   time.Time     `json:"lastSeen"`
+
+	// Set once the pattern has been explained
+	Plan           string     `json:"plan,omitempty"` // e.g. "IXSCAN batchId_1 > FETCH"
+	SuggestedIndex string     `json:"suggestedIndex,omitempty"`
+	ExplainedAt    *time.Time `json:"explainedAt,omitempty"`
+	ExplainError   string     `json:"explainError,omitempty"`
+
+	// Last filter and sort seen, to explain the pattern with
+	filter bson.Raw
+	sort   bson.Raw
+}
+
+// started is a watched command in flight.
+type started struct {
+	command    string
+	collection string
+	filter     bson.Raw
+	sort       bson.Raw
+}
+
+// Profiler collects slow query patterns. A nil Profiler is disabled.
+type Profiler struct {
+	threshold time.Duration
+
+	mu       sync.Mutex
+	inFlight map[int64]started
+	patterns map[string]*Pattern
+}
+
+// NewProfiler creates a profiler for queries slower than threshold. It
+// returns nil when threshold is zero.
+func NewProfiler(threshold time.Duration) *Profiler {
+	if threshold <= 0 {
+		return nil
+	}
+	return &Profiler{
+		threshold: threshold,
+		inFlight:  make(map[int64]started),
+		patterns:  make(map[string]*Pattern),
+	}
+}
+
+// Threshold returns the duration above which queries are slow.
+func (p *Profiler) Threshold() time.Duration {
+	return p.threshold
+}
+
+// Monitor returns the command monitor to connect with, or nil when the
+// profiler is disabled.
+func (p *Profiler) Monitor() *event.CommandMonitor {
+	if p == nil {
+		return nil
+	}
+	return &event.CommandMonitor{
+		Started: p.started,
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			p.finished(e.RequestID, e.Duration)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			p.finished(e.RequestID, e.Duration)
+		},
+	}
+}
+
+// started remembers the filter of a watched command.
+func (p *Profiler) started(_ context.Context, e *event.CommandStartedEvent) {
+	field, ok := filterFields[e.CommandName]
+	if !ok || e.DatabaseName == "admin" || e.DatabaseName == "config" {
+		return
+	}
+	collection, ok := e.Command.Lookup(e.CommandName).StringValueOK()
+	if !ok {
+		return
+	}
+
+	// The command is only valid during the callback, so keep copies
+	filter, sortDoc := commandFilter(e.Command, e.CommandName, field)
+	p.mu.Lock()
+	p.inFlight[e.RequestID] = started{
+		command:    e.CommandName,
+		collection: collection,
+		filter:     clone(filter),
+		sort:       clone(sortDoc),
+	}
+	p.mu.Unlock()
+}
+
+// finished records a watched command that took longer than the threshold.
+func (p *Profiler) finished(requestID int64, took time.Duration) {
+	p.mu.Lock()
+	cmd, ok := p.inFlight[requestID]
+	delete(p.inFlight, requestID)
+	if !ok || took < p.threshold {
+		p.mu.Unlock()
+		return
+	}
+
+	filter, sortShape := shape(cmd.filter), sortOrder(cmd.sort)
+	key := cmd.command + " " + cmd.collection + " " + filter + " " + sortShape
+	pattern, ok := p.patterns[key]
+	if !ok && len(p.patterns) < maxPatterns {
+		pattern = &Pattern{Command: cmd.command, Collection: cmd.collection, Filter: filter, Sort: sortShape}
+		p.patterns[key] = pattern
+	}
+	if pattern != nil {
+		pattern.Count++
+		pattern.Total += took
+		if took > pattern.Max {
+			pattern.Max = took
+		}
+		pattern.LastSeen = time.Now()
+		pattern.filter, pattern.sort = cmd.filter, cmd.sort
+	}
+	p.mu.Unlock()
+
+	log.Printf("[SlowQuery] %s %s took %v: filter=%s sort=%s", cmd.command, cmd.collection, took.Round(time.Millisecond), filter, sortShape)
+}
+
+// Patterns returns copies of the slow query patterns, by total time spent.
+func (p *Profiler) Patterns() []Pattern {
+	p.mu.Lock()
+	patterns := make([]Pattern, 0, len(p.patterns))
+	for _, pattern := range p.patterns {
+		copied := *pattern
+		copied.TotalMs = copied.Total.Milliseconds()
+		copied.MaxMs = copied.Max.Milliseconds()
+		patterns = append(patterns, copied)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].Total > patterns[j].Total
+	})
+	return patterns
+}
+
+// update applies fn to the pattern with the key of pattern, if still kept.
+func (p *Profiler) update(pattern *Pattern, fn func(*Pattern)) {
+	key := pattern.Command + " " + pattern.Collection + " " + pattern.Filter + " " + pattern.Sort
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stored, ok := p.patterns[key]; ok {
+		fn(stored)
+	}
+}
+
+// commandFilter returns the filter and sort of a command.
+func commandFilter(command bson.Raw, name, field string) (bson.Raw, bson.Raw) {
+	value, err := command.LookupErr(field)
+	if err != nil {
+		return nil, nil
+	}
+
+	switch name {
+	case "aggregate":
+		stages, ok := value.ArrayOK()
+		if !ok {
+			return nil, nil
+		}
+		first, err := stages.IndexErr(0)
+		if err != nil {
+			return nil, nil
+		}
+		stage, _ := first.Value().DocumentOK()
+		match, _ := stage.Lookup("$match").DocumentOK()
+		return match, nil
+	case "update", "delete":
+		statements, ok := value.ArrayOK()
+		if !ok {
+			return nil, nil
+		}
+		first, err := statements.IndexErr(0)
+		if err != nil {
+			return nil, nil
+		}
+		statement, _ := first.Value().DocumentOK()
+		q, _ := statement.Lookup("q").DocumentOK()
+		return q, nil
+	}
+
+	filter, _ := value.DocumentOK()
+	sortDoc, _ := command.Lookup("sort").DocumentOK()
+	return filter, sortDoc
+}
+
+// shape returns a filter in shell notation with its values replaced by "?",
+// keeping the structure of operators, e.g. {batchId: ?, endTime: {$gte: ?}}.
+func shape(doc bson.Raw) string {
+	if len(doc) == 0 {
+		return "{}"
+	}
+	var b strings.Builder
+	writeShape(&b, doc, false)
+	return b.String()
+}
+
+// sortOrder returns a sort document in shell notation, or "" for none.
+func sortOrder(doc bson.Raw) string {
+	if len(doc) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	writeShape(&b, doc, true)
+	return b.String()
+}
+
+// writeShape writes the shape of doc, or doc itself when keepValues is set.
+func writeShape(b *strings.Builder, doc bson.Raw, keepValues bool) {
+	elements, err := doc.Elements()
+	if err != nil {
+		b.WriteString("?")
+		return
+	}
+
+	b.WriteString("{")
+	for i, element := range elements {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		key := element.Key()
+		b.WriteString(key + ": ")
+
+		value := element.Value()
+		switch {
+		case keepValues:
+			if n, ok := value.AsInt64OK(); ok {
+				b.WriteString(strconv.FormatInt(n, 10))
+			} else {
+				b.WriteString(value.String())
+			}
+		case value.Type == bson.TypeEmbeddedDocument:
+			writeShape(b, value.Document(), false)
+		case value.Type == bson.TypeArray && (key == "$and" || key == "$or" || key == "$nor"):
+			values, _ := value.Array().Values()
+			b.WriteString("[")
+			for j, v := range values {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				if sub, ok := v.DocumentOK(); ok {
+					writeShape(b, sub, false)
+				} else {
+					b.WriteString("?")
+				}
+			}
+			b.WriteString("]")
+		default:
+			b.WriteString("?")
+		}
+	}
+	b.WriteString("}")
+}
+
+// clone copies a document out of a buffer the driver reuses.
+func clone(doc bson.Raw) bson.Raw {
+	if doc == nil {
+		return nil
+	}
+	return append(bson.Raw(nil), doc...)
+}
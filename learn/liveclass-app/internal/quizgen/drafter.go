@@ -0,0 +1,119 @@
+package quizgen
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// minSpeechLines is the least captioned speech worth drafting a quiz from.
+const minSpeechLines = 10
+
+// Drafter drafts a quiz for each class that ends with enough captioned
+// speech, and stores it for the presenter to review.
+type Drafter struct {
+	generator    Generator
+	draftRepo    *repository.QuizDraftRepository
+	maxQuestions int
+	timeout      time.Duration
+}
+
+// NewDrafter creates a drafter asking for up to maxQuestions questions per
+// class and waiting up to timeout for them. A nil generator disables it.
+func NewDrafter(generator Generator, draftRepo *repository.QuizDraftRepository, maxQuestions int, timeout time.Duration) *Drafter {
+	return &Drafter{
+		generator:    generator,
+		draftRepo:    draftRepo,
+		maxQuestions: maxQuestions,
+		timeout:      timeout,
+	}
+}
+
+// Enabled reports whether a generator is configured.
+func (d *Drafter) Enabled() bool {
+	return d.generator != nil
+}
+
+// DraftForClass drafts a quiz from the transcript of a class that just
+// ended. Failures are logged; the class is unaffected.
+func (d *Drafter) DraftForClass(schedule *models.ScheduledClass, entries []room.TranscriptEntry) {
+	if d.generator == nil {
+		return
+	}
+
+	lines, speech := transcriptLines(entries)
+	if speech < minSpeechLines {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	generated, err := d.generator.Generate(ctx, Request{
+		ClassTitle:   schedule.Title,
+		MaxQuestions: d.maxQuestions,
+		Transcript:   lines,
+	})
+	if err != nil {
+		log.Printf("[QuizGen] %s failed for class %s: %v", d.generator.Name(), schedule.ID.Hex(), err)
+		return
+	}
+
+	questions := make([]models.QuizQuestion, 0, len(generated))
+	for _, g := range generated {
+		q := models.QuizQuestion{
+			Prompt:      strings.TrimSpace(g.Prompt),
+			Choices:     g.Choices,
+			Answer:      g.Answer,
+			Explanation: strings.TrimSpace(g.Explanation),
+		}
+		if !q.Valid() {
+			continue
+		}
+		questions = append(questions, q)
+		if len(questions) == d.maxQuestions {
+			break
+		}
+	}
+	if len(questions) == 0 {
+		log.Printf("[QuizGen] %s drafted no usable questions for class %s", d.generator.Name(), schedule.ID.Hex())
+		return
+	}
+
+	draft := &models.QuizDraft{
+		ScheduleID:  schedule.ID,
+		BatchID:     schedule.BatchID,
+		PresenterID: schedule.PresenterID,
+		ClassTitle:  schedule.Title,
+		Provider:    d.generator.Name(),
+		Status:      models.QuizDraftPending,
+		Questions:   questions,
+	}
+	if err := d.draftRepo.Create(ctx, draft); err != nil {
+		log.Printf("[QuizGen] Failed to store quiz draft for class %s: %v", schedule.ID.Hex(), err)
+		return
+	}
+	log.Printf("[QuizGen] Drafted %d questions for class %s", len(questions), schedule.ID.Hex())
+}
+
+// transcriptLines returns the captions and chat of a transcript as lines
+// for a generator, and how many of them are speech.
+func transcriptLines(entries []room.TranscriptEntry) ([]Line, int) {
+	var lines []Line
+	speech := 0
+	for _, e := range entries {
+		switch e.Kind {
+		case room.EntryCaption:
+			lines = append(lines, Line{Kind: LineSpeech, Text: e.Text, At: e.At})
+			speech++
+		case room.EntryChat:
+			lines = append(lines, Line{Kind: LineChat, Text: e.Text, At: e.At})
+		}
+	}
+	return lines, speech
+}
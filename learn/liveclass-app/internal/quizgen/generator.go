@@ -0,0 +1,108 @@
+// Package quizgen drafts quiz questions from class transcripts through a
+// pluggable external generator, for presenters to review.
+package quizgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kinds of transcript lines sent to a generator
+const (
+	LineSpeech = "speech" // The presenter's captioned speech
+	LineChat   = "chat"
+)
+
+// Line is a line of a class transcript. Lines carry no names, so generators
+// never see who said what.
+type Line struct {
+	Kind string    `json:"kind"`
+	Text string    `json:"text"`
+	At   time.Time `json:"at"`
+}
+
+// Request is a class transcript to draft questions from.
+type Request struct {
+	ClassTitle   string `json:"classTitle"`
+	MaxQuestions int    `json:"maxQuestions"`
+	Transcript   []Line `json:"transcript"`
+}
+
+// Question is a drafted question. Questions without choices are open-ended;
+// otherwise Answer is the index of the correct choice.
+type Question struct {
+	Prompt      string   `json:"prompt"`
+	Choices     []string `json:"choices,omitempty"`
+	Answer      int      `json:"answer"`
+	Explanation string   `json:"explanation,omitempty"`
+}
+
+// Generator drafts quiz questions through an external service.
+type Generator interface {
+	// Name identifies the provider in logs and on drafts.
+	Name() string
+	// Generate drafts questions from a class transcript.
+	Generate(ctx context.Context, req Request) ([]Question, error)
+}
+
+// HTTPGenerator drafts questions through an HTTP endpoint that takes a
+// Request as JSON and answers {"questions": [...]}.
+type HTTPGenerator struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPGenerator creates a provider for the endpoint at url. apiKey, sent
+// as a bearer token, may be empty for endpoints that don't require one.
+func NewHTTPGenerator(url, apiKey string, timeout time.Duration) *HTTPGenerator {
+	return &HTTPGenerator{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the provider name.
+func (g *HTTPGenerator) Name() string {
+	return "http"
+}
+
+// Generate posts the transcript to the endpoint.
+func (g *HTTPGenerator) Generate(ctx context.Context, r Request) ([]Question, error) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Questions []Question `json:"questions"`
+		Error     string     `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("generate failed (status %d): %s", resp.StatusCode, result.Error)
+	}
+	return result.Questions, nil
+}
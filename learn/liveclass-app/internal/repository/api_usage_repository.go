@@ -0,0 +1,119 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const apiUsageCollection = "api_usage"
+
+// apiUsageRetention is how long daily API usage is kept.
+const apiUsageRetention = 180 * 24 * time.Hour
+
+// APIUsageRepository handles daily API usage per external client.
+type APIUsageRepository struct {
+	db *database.MongoDB
+}
+
+// NewAPIUsageRepository creates a new APIUsageRepository.
+func NewAPIUsageRepository(db *database.MongoDB) *APIUsageRepository {
+	return &APIUsageRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the API usage collection.
+func (r *APIUsageRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		// One document per client and day
+		{
+			Keys:    bson.D{{Key: "client", Value: 1}, {Key: "day", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Usage of every client over a range of days
+		{Keys: bson.D{{Key: "day", Value: 1}}},
+		// Expire old days
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+	_, err := r.db.Collection(apiUsageCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Record adds a request to a client's usage on day and returns the day's
+// usage so far.
+func (r *APIUsageRepository) Record(ctx context.Context, client, day string, failed bool, bytesIn, bytesOut int64) (*models.APIUsage, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	var failures int64
+	if failed {
+		failures = 1
+	}
+	now := time.Now()
+	update := bson.M{
+		"$inc": bson.M{
+			"requests": 1,
+			"errors":   failures,
+			"bytesIn":  bytesIn,
+			"bytesOut": bytesOut,
+		},
+		"$set":         bson.M{"updatedAt": now},
+		"$setOnInsert": bson.M{"expiresAt": now.Add(apiUsageRetention)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	usage := &models.APIUsage{}
+	err := r.db.Collection(apiUsageCollection).FindOneAndUpdate(ctx, bson.M{"client": client, "day": day}, update, opts).Decode(usage)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return usage, nil
+}
+
+// ClaimQuotaAlert records that admins are being alerted about a client
+// nearing its quota on day. It returns false if they already were, so each
+// client is alerted at most once a day across instances.
+func (r *APIUsageRepository) ClaimQuotaAlert(ctx context.Context, client, day string) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	filter := bson.M{"client": client, "day": day, "quotaAlertedAt": bson.M{"$exists": false}}
+	result, err := r.db.Collection(apiUsageCollection).UpdateOne(ctx, filter, bson.M{"$set": bson.M{"quotaAlertedAt": time.Now()}})
+	if err != nil {
+		return false, dbErr(err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// FindRange returns the usage from day from to day to, inclusive, by day
+// and client. An empty client returns every client's.
+func (r *APIUsageRepository) FindRange(ctx context.Context, from, to, client string) ([]models.APIUsage, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	filter := bson.M{"day": bson.M{"$gte": from, "$lte": to}}
+	if client != "" {
+		filter["client"] = client
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "day", Value: 1}, {Key: "client", Value: 1}})
+
+	cursor, err := r.db.Collection(apiUsageCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	usage := []models.APIUsage{}
+	if err := cursor.All(ctx, &usage); err != nil {
+		return nil, dbErr(err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,117 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const attendanceCollection = "attendance"
+
+// AttendanceRepository handles the attendance stints of live classes.
+type AttendanceRepository struct {
+	db *database.MongoDB
+}
+
+// NewAttendanceRepository creates a new AttendanceRepository.
+func NewAttendanceRepository(db *database.MongoDB) *AttendanceRepository {
+	return &AttendanceRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the attendance collection.
+func (r *AttendanceRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(attendanceCollection)
+
+	indexes := []mongo.IndexModel{
+		// Attendance of a class
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "joinedAt", Value: 1}}},
+		// A student's attendance, newest first
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "joinedAt", Value: -1}}},
+		// Closing stints when participants leave
+		{Keys: bson.D{{Key: "sessionId", Value: 1}, {Key: "participantId", Value: 1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new stint.
+func (r *AttendanceRepository) Create(ctx context.Context, stint *models.Attendance) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	stint.ID = primitive.NewObjectID()
+	_, err := r.db.Collection(attendanceCollection).InsertOne(ctx, stint)
+	return dbErr(err)
+}
+
+// Close ends a participant's open stint in a room session.
+func (r *AttendanceRepository) Close(ctx context.Context, sessionID, participantID string, at time.Time) error {
+	return r.close(ctx, bson.M{"sessionId": sessionID, "participantId": participantID}, at)
+}
+
+// CloseSession ends every stint still open in a room session.
+func (r *AttendanceRepository) CloseSession(ctx context.Context, sessionID string, at time.Time) error {
+	return r.close(ctx, bson.M{"sessionId": sessionID}, at)
+}
+
+func (r *AttendanceRepository) close(ctx context.Context, filter bson.M, at time.Time) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	filter["leftAt"] = bson.M{"$exists": false}
+	_, err := r.db.Collection(attendanceCollection).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"leftAt": at}})
+	return dbErr(err)
+}
+
+// FindBySchedules returns the stints of classes, oldest first.
+func (r *AttendanceRepository) FindBySchedules(ctx context.Context, scheduleIDs []primitive.ObjectID) ([]models.Attendance, error) {
+	if len(scheduleIDs) == 0 {
+		return []models.Attendance{}, nil
+	}
+	return r.find(ctx, bson.M{"scheduleId": bson.M{"$in": scheduleIDs}},
+		options.Find().SetSort(bson.D{{Key: "joinedAt", Value: 1}}))
+}
+
+// FindByUser returns up to limit of a user's stints, newest first.
+func (r *AttendanceRepository) FindByUser(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.Attendance, error) {
+	return r.find(ctx, bson.M{"userId": userID},
+		options.Find().SetSort(bson.D{{Key: "joinedAt", Value: -1}}).SetLimit(limit))
+}
+
+func (r *AttendanceRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Attendance, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	cursor, err := r.db.Collection(attendanceCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	stints := []models.Attendance{}
+	if err := cursor.All(ctx, &stints); err != nil {
+		return nil, dbErr(err)
+	}
+	return stints, nil
+}
+
+// RenameUser replaces the name a user joined classes under, keeping the
+// stints themselves.
+func (r *AttendanceRepository) RenameUser(ctx context.Context, userID primitive.ObjectID, name string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	_, err := r.db.Collection(attendanceCollection).UpdateMany(ctx,
+		bson.M{"userId": userID},
+		bson.M{"$set": bson.M{"name": name}},
+	)
+	return dbErr(err)
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
 	"github.com/jinshatcp/brightline-academy/learn/internal/database"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,38 +16,43 @@ import (
 
 const batchesCollection = "batches"
 
-// Batch cache key prefixes
+// Batch cache namespaces
 const (
-	batchByIDPrefix        = "batch:id:"
-	batchByPresenterPrefix = "batch:presenter:"
-	batchByStudentPrefix   = "batch:student:"
-	batchAllKey            = "batch:all"
+	batchByID           cacheNamespace[*models.Batch]  = "batch:id:"
+	batchesByPresenter  cacheNamespace[[]models.Batch] = "batch:presenter:"
+	batchesByStudent    cacheNamespace[[]models.Batch] = "batch:student:"
+	batchesByAssistant  cacheNamespace[[]models.Batch] = "batch:assistant:"
+	batchesAllNamespace cacheNamespace[[]models.Batch] = "batch:all"
 )
 
+// batchesAll is the cache key of the list of all batches.
+var batchesAll = batchesAllNamespace.key("")
+
 // Batch errors
 var (
 	ErrBatchNotFound = errors.New("batch not found")
+	ErrGroupNotFound = errors.New("group not found")
 )
 
 // BatchRepository handles batch data operations with caching.
 type BatchRepository struct {
-	db    *database.MongoDB
-	cache *cache.Cache[interface{}]
+	db      *database.MongoDB
+	batches *cachedRepo[*models.Batch]
+	lists   *cachedRepo[[]models.Batch]
+	writeHooks
 }
 
 // NewBatchRepository creates a new BatchRepository.
 func NewBatchRepository(db *database.MongoDB) *BatchRepository {
-	return &BatchRepository{
-		db:    db,
-		cache: cache.New[interface{}](1*time.Minute, 30*time.Second),
-	}
+	return NewBatchRepositoryWithCache(db, 1*time.Minute)
 }
 
 // NewBatchRepositoryWithCache creates a new BatchRepository with custom cache TTL.
 func NewBatchRepositoryWithCache(db *database.MongoDB, cacheTTL time.Duration) *BatchRepository {
 	return &BatchRepository{
-		db:    db,
-		cache: cache.New[interface{}](cacheTTL, 30*time.Second),
+		db:      db,
+		batches: newCachedRepo[*models.Batch](cacheTTL, 30*time.Second, ErrBatchNotFound),
+		lists:   newCachedRepo[[]models.Batch](cacheTTL, 30*time.Second, nil),
 	}
 }
 
@@ -63,6 +67,9 @@ func (r *BatchRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "studentIds", Value: 1}},
 		},
+		{
+			Keys: bson.D{{Key: "assistantIds", Value: 1}},
+		},
 		{
 			Keys: bson.D{{Key: "createdAt", Value: -1}},
 		},
@@ -74,6 +81,9 @@ func (r *BatchRepository) CreateIndexes(ctx context.Context) error {
 
 // Create creates a new batch.
 func (r *BatchRepository) Create(ctx context.Context, batch *models.Batch) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(batchesCollection)
 
 	batch.ID = primitive.NewObjectID()
@@ -88,166 +98,142 @@ func (r *BatchRepository) Create(ctx context.Context, batch *models.Batch) error
 		// Invalidate list caches
 		r.invalidateListCaches()
 		// Cache the new batch
-		r.cache.Set(batchByIDPrefix+batch.ID.Hex(), batch)
+		r.batches.set(batchByID.key(batch.ID.Hex()), batch)
 	}
-	return err
+	return dbErr(err)
 }
 
 // FindByID finds a batch by ID with caching.
 func (r *BatchRepository) FindByID(ctx context.Context, id string) (*models.Batch, error) {
-	cacheKey := batchByIDPrefix + id
-
-	// Try cache first
-	if cached, found := r.cache.Get(cacheKey); found {
-		if batch, ok := cached.(*models.Batch); ok {
-			return batch, nil
-		}
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrBatchNotFound
 	}
 
-	collection := r.db.Collection(batchesCollection)
+	return r.batches.load(ctx, batchByID.key(id), func(ctx context.Context) (*models.Batch, error) {
+		ctx, cancel := r.db.ReadContext(ctx)
+		defer cancel()
 
-	var batch models.Batch
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&batch)
-	if err == mongo.ErrNoDocuments {
-		return nil, ErrBatchNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, &batch)
-
-	return &batch, nil
+		var batch models.Batch
+		err := r.db.Collection(batchesCollection).FindOne(ctx, bson.M{"_id": objectID}).Decode(&batch)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrBatchNotFound
+		}
+		if err != nil {
+			return nil, dbErr(err)
+		}
+		return &batch, nil
+	})
 }
 
 // FindAll returns all batches with caching.
 func (r *BatchRepository) FindAll(ctx context.Context) ([]models.Batch, error) {
-	// Try cache first
-	if cached, found := r.cache.Get(batchAllKey); found {
-		if batches, ok := cached.([]models.Batch); ok {
-			return batches, nil
-		}
-	}
-
-	collection := r.db.Collection(batchesCollection)
-
-	opts := options.Find().
-		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-		SetBatchSize(100)
-
-	cursor, err := collection.Find(ctx, bson.M{}, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var batches []models.Batch
-	if err := cursor.All(ctx, &batches); err != nil {
-		return nil, err
-	}
-
-	// Cache the result and individual batches
-	r.cache.Set(batchAllKey, batches)
-	for i := range batches {
-		r.cache.Set(batchByIDPrefix+batches[i].ID.Hex(), &batches[i])
-	}
-
-	return batches, nil
+	return r.findList(ctx, batchesAll, bson.M{})
 }
 
 // FindByPresenter returns batches for a specific presenter with caching.
 func (r *BatchRepository) FindByPresenter(ctx context.Context, presenterID string) ([]models.Batch, error) {
-	cacheKey := batchByPresenterPrefix + presenterID
-
-	// Try cache first
-	if cached, found := r.cache.Get(cacheKey); found {
-		if batches, ok := cached.([]models.Batch); ok {
-			return batches, nil
-		}
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(presenterID)
 	if err != nil {
 		return nil, err
 	}
+	return r.findList(ctx, batchesByPresenter.key(presenterID), bson.M{"presenterId": objectID})
+}
 
-	collection := r.db.Collection(batchesCollection)
-
-	opts := options.Find().
-		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-		SetBatchSize(100)
-
-	cursor, err := collection.Find(ctx, bson.M{"presenterId": objectID}, opts)
+// FindByStudent returns batches containing a specific student with caching.
+func (r *BatchRepository) FindByStudent(ctx context.Context, studentID string) ([]models.Batch, error) {
+	objectID, err := primitive.ObjectIDFromHex(studentID)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(ctx)
+	return r.findList(ctx, batchesByStudent.key(studentID), bson.M{"studentIds": objectID})
+}
 
-	var batches []models.Batch
-	if err := cursor.All(ctx, &batches); err != nil {
+// FindByAssistant returns batches where the user is a teaching assistant, with caching.
+func (r *BatchRepository) FindByAssistant(ctx context.Context, userID string) ([]models.Batch, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
 		return nil, err
 	}
+	return r.findList(ctx, batchesByAssistant.key(userID), bson.M{"assistantIds": objectID})
+}
 
-	// Cache the result
-	r.cache.Set(cacheKey, batches)
+// findList returns the batches matching filter, newest first, from the
+// cache under key or the database. Batches loaded are also cached by ID.
+func (r *BatchRepository) findList(ctx context.Context, key cacheKey[[]models.Batch], filter bson.M) ([]models.Batch, error) {
+	return r.lists.load(ctx, key, func(ctx context.Context) ([]models.Batch, error) {
+		ctx, cancel := r.db.ReadContext(ctx)
+		defer cancel()
 
-	return batches, nil
-}
+		opts := options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+			SetBatchSize(100)
 
-// FindByStudent returns batches containing a specific student with caching.
-func (r *BatchRepository) FindByStudent(ctx context.Context, studentID string) ([]models.Batch, error) {
-	cacheKey := batchByStudentPrefix + studentID
+		cursor, err := r.db.Collection(batchesCollection).Find(ctx, filter, opts)
+		if err != nil {
+			return nil, dbErr(err)
+		}
+		defer cursor.Close(ctx)
 
-	// Try cache first
-	if cached, found := r.cache.Get(cacheKey); found {
-		if batches, ok := cached.([]models.Batch); ok {
-			return batches, nil
+		var batches []models.Batch
+		if err := cursor.All(ctx, &batches); err != nil {
+			return nil, dbErr(err)
 		}
-	}
+		for i := range batches {
+			r.batches.set(batchByID.key(batches[i].ID.Hex()), &batches[i])
+		}
+		return batches, nil
+	})
+}
 
-	objectID, err := primitive.ObjectIDFromHex(studentID)
+// Warm loads every batch into the cache, with each presenter's, student's
+// and assistant's list of batches, from a single query. It returns how many
+// batches were loaded.
+func (r *BatchRepository) Warm(ctx context.Context) (int, error) {
+	// Start from the database, not whatever is cached
+	r.lists.delete(batchesAll)
+	batches, err := r.FindAll(ctx)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	collection := r.db.Collection(batchesCollection)
-
-	opts := options.Find().
-		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-		SetBatchSize(100)
-
-	cursor, err := collection.Find(ctx, bson.M{"studentIds": objectID}, opts)
-	if err != nil {
-		return nil, err
+	byPresenter := make(map[string][]models.Batch)
+	byStudent := make(map[string][]models.Batch)
+	byAssistant := make(map[string][]models.Batch)
+	for _, batch := range batches { // Newest first, like the queries
+		byPresenter[batch.PresenterID.Hex()] = append(byPresenter[batch.PresenterID.Hex()], batch)
+		for _, id := range batch.StudentIDs {
+			byStudent[id.Hex()] = append(byStudent[id.Hex()], batch)
+		}
+		for _, id := range batch.AssistantIDs {
+			byAssistant[id.Hex()] = append(byAssistant[id.Hex()], batch)
+		}
 	}
-	defer cursor.Close(ctx)
 
-	var batches []models.Batch
-	if err := cursor.All(ctx, &batches); err != nil {
-		return nil, err
+	for id, list := range byPresenter {
+		r.lists.set(batchesByPresenter.key(id), list)
 	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, batches)
-
-	return batches, nil
+	for id, list := range byStudent {
+		r.lists.set(batchesByStudent.key(id), list)
+	}
+	for id, list := range byAssistant {
+		r.lists.set(batchesByAssistant.key(id), list)
+	}
+	return len(batches), nil
 }
 
 // Update updates a batch and invalidates caches.
 func (r *BatchRepository) Update(ctx context.Context, batch *models.Batch) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(batchesCollection)
 
 	batch.UpdatedAt = time.Now()
 
 	result, err := collection.ReplaceOne(ctx, bson.M{"_id": batch.ID}, batch)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrBatchNotFound
@@ -255,13 +241,16 @@ func (r *BatchRepository) Update(ctx context.Context, batch *models.Batch) error
 
 	// Invalidate and update caches
 	r.invalidateBatchCaches(batch.ID.Hex())
-	r.cache.Set(batchByIDPrefix+batch.ID.Hex(), batch)
+	r.batches.set(batchByID.key(batch.ID.Hex()), batch)
 
 	return nil
 }
 
 // AddStudents adds students to a batch and invalidates caches.
 func (r *BatchRepository) AddStudents(ctx context.Context, batchID string, studentIDs []string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(batchID)
 	if err != nil {
 		return ErrBatchNotFound
@@ -271,7 +260,7 @@ func (r *BatchRepository) AddStudents(ctx context.Context, batchID string, stude
 	for i, id := range studentIDs {
 		oid, err := primitive.ObjectIDFromHex(id)
 		if err != nil {
-			return err
+			return dbErr(err)
 		}
 		studentObjectIDs[i] = oid
 	}
@@ -285,7 +274,7 @@ func (r *BatchRepository) AddStudents(ctx context.Context, batchID string, stude
 
 	result, err := collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrBatchNotFound
@@ -294,14 +283,44 @@ func (r *BatchRepository) AddStudents(ctx context.Context, batchID string, stude
 	// Invalidate caches
 	r.invalidateBatchCaches(batchID)
 	for _, sid := range studentIDs {
-		r.cache.Delete(batchByStudentPrefix + sid)
+		r.lists.delete(batchesByStudent.key(sid))
 	}
 
 	return nil
 }
 
+// DistinctStudents returns the students enrolled in any batch except the
+// excluded ones, each once.
+func (r *BatchRepository) DistinctStudents(ctx context.Context, excludeBatchIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(batchesCollection)
+
+	filter := bson.M{}
+	if len(excludeBatchIDs) > 0 {
+		filter["_id"] = bson.M{"$nin": excludeBatchIDs}
+	}
+
+	values, err := collection.Distinct(ctx, "studentIds", filter)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+
+	students := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			students = append(students, id)
+		}
+	}
+	return students, nil
+}
+
 // RemoveStudent removes a student from a batch and invalidates caches.
 func (r *BatchRepository) RemoveStudent(ctx context.Context, batchID, studentID string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	batchObjID, err := primitive.ObjectIDFromHex(batchID)
 	if err != nil {
 		return ErrBatchNotFound
@@ -309,7 +328,7 @@ func (r *BatchRepository) RemoveStudent(ctx context.Context, batchID, studentID
 
 	studentObjID, err := primitive.ObjectIDFromHex(studentID)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 
 	collection := r.db.Collection(batchesCollection)
@@ -321,7 +340,90 @@ func (r *BatchRepository) RemoveStudent(ctx context.Context, batchID, studentID
 
 	result, err := collection.UpdateOne(ctx, bson.M{"_id": batchObjID}, update)
 	if err != nil {
-		return err
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrBatchNotFound
+	}
+
+	// Students leave the batch's groups with it. The filter only matches
+	// batches with groups, as $[] fails on a missing array.
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": batchObjID, "groups.studentIds": studentObjID},
+		bson.M{"$pull": bson.M{"groups.$[].studentIds": studentObjID}})
+
+	// Invalidate caches
+	r.invalidateBatchCaches(batchID)
+	r.lists.delete(batchesByStudent.key(studentID))
+
+	return dbErr(err)
+}
+
+// AddAssistants adds teaching assistants to a batch and invalidates caches.
+func (r *BatchRepository) AddAssistants(ctx context.Context, batchID string, userIDs []string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(batchID)
+	if err != nil {
+		return ErrBatchNotFound
+	}
+
+	assistantObjectIDs := make([]primitive.ObjectID, len(userIDs))
+	for i, id := range userIDs {
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return dbErr(err)
+		}
+		assistantObjectIDs[i] = oid
+	}
+
+	collection := r.db.Collection(batchesCollection)
+
+	update := bson.M{
+		"$addToSet": bson.M{"assistantIds": bson.M{"$each": assistantObjectIDs}},
+		"$set":      bson.M{"updatedAt": time.Now()},
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrBatchNotFound
+	}
+
+	// Invalidate caches
+	r.invalidateBatchCaches(batchID)
+
+	return nil
+}
+
+// RemoveAssistant removes a teaching assistant from a batch and invalidates caches.
+func (r *BatchRepository) RemoveAssistant(ctx context.Context, batchID, userID string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	batchObjID, err := primitive.ObjectIDFromHex(batchID)
+	if err != nil {
+		return ErrBatchNotFound
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return dbErr(err)
+	}
+
+	collection := r.db.Collection(batchesCollection)
+
+	update := bson.M{
+		"$pull": bson.M{"assistantIds": userObjID},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": batchObjID}, update)
+	if err != nil {
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrBatchNotFound
@@ -329,13 +431,103 @@ func (r *BatchRepository) RemoveStudent(ctx context.Context, batchID, studentID
 
 	// Invalidate caches
 	r.invalidateBatchCaches(batchID)
-	r.cache.Delete(batchByStudentPrefix + studentID)
 
 	return nil
 }
 
+// AddGroup adds a group to a batch and invalidates caches.
+func (r *BatchRepository) AddGroup(ctx context.Context, batchID string, group *models.BatchGroup) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(batchID)
+	if err != nil {
+		return ErrBatchNotFound
+	}
+
+	group.ID = primitive.NewObjectID()
+	group.CreatedAt = time.Now()
+	if group.StudentIDs == nil {
+		group.StudentIDs = []primitive.ObjectID{}
+	}
+
+	update := bson.M{
+		"$push": bson.M{"groups": group},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}
+	result, err := r.db.Collection(batchesCollection).UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrBatchNotFound
+	}
+
+	r.invalidateBatchCaches(batchID)
+	return nil
+}
+
+// RenameGroup renames a group of a batch and invalidates caches.
+func (r *BatchRepository) RenameGroup(ctx context.Context, batchID string, groupID primitive.ObjectID, name string) error {
+	return r.updateGroup(ctx, batchID, groupID, bson.M{
+		"$set": bson.M{"groups.$.name": name, "updatedAt": time.Now()},
+	})
+}
+
+// AddGroupMembers adds students to a group of a batch and invalidates caches.
+func (r *BatchRepository) AddGroupMembers(ctx context.Context, batchID string, groupID primitive.ObjectID, studentIDs []primitive.ObjectID) error {
+	return r.updateGroup(ctx, batchID, groupID, bson.M{
+		"$addToSet": bson.M{"groups.$.studentIds": bson.M{"$each": studentIDs}},
+		"$set":      bson.M{"updatedAt": time.Now()},
+	})
+}
+
+// RemoveGroupMember removes a student from a group of a batch and
+// invalidates caches.
+func (r *BatchRepository) RemoveGroupMember(ctx context.Context, batchID string, groupID, studentID primitive.ObjectID) error {
+	return r.updateGroup(ctx, batchID, groupID, bson.M{
+		"$pull": bson.M{"groups.$.studentIds": studentID},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	})
+}
+
+// DeleteGroup removes a group from a batch and invalidates caches.
+func (r *BatchRepository) DeleteGroup(ctx context.Context, batchID string, groupID primitive.ObjectID) error {
+	return r.updateGroup(ctx, batchID, groupID, bson.M{
+		"$pull": bson.M{"groups": bson.M{"_id": groupID}},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	})
+}
+
+// updateGroup applies update to the batch holding the group, which the
+// positional operator refers to.
+func (r *BatchRepository) updateGroup(ctx context.Context, batchID string, groupID primitive.ObjectID, update bson.M) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(batchID)
+	if err != nil {
+		return ErrBatchNotFound
+	}
+
+	filter := bson.M{"_id": objectID, "groups._id": groupID}
+	result, err := r.db.Collection(batchesCollection).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrGroupNotFound
+	}
+
+	r.invalidateBatchCaches(batchID)
+	return nil
+}
+
 // Delete deletes a batch and invalidates caches.
 func (r *BatchRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return ErrBatchNotFound
@@ -345,7 +537,7 @@ func (r *BatchRepository) Delete(ctx context.Context, id string) error {
 
 	result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.DeletedCount == 0 {
 		return ErrBatchNotFound
@@ -360,18 +552,21 @@ func (r *BatchRepository) Delete(ctx context.Context, id string) error {
 
 // invalidateBatchCaches invalidates caches for a specific batch.
 func (r *BatchRepository) invalidateBatchCaches(batchID string) {
-	r.cache.Delete(batchByIDPrefix + batchID)
+	r.batches.delete(batchByID.key(batchID))
 	r.invalidateListCaches()
 }
 
 // invalidateListCaches invalidates all list caches.
 func (r *BatchRepository) invalidateListCaches() {
-	r.cache.Delete(batchAllKey)
-	r.cache.DeletePrefix(batchByPresenterPrefix)
-	r.cache.DeletePrefix(batchByStudentPrefix)
+	r.lists.delete(batchesAll)
+	r.lists.deleteNamespace(batchesByPresenter)
+	r.lists.deleteNamespace(batchesByStudent)
+	r.lists.deleteNamespace(batchesByAssistant)
+	r.fireWrite()
 }
 
 // ClearCache clears all cached batches.
 func (r *BatchRepository) ClearCache() {
-	r.cache.Clear()
+	r.batches.clear()
+	r.lists.clear()
 }
@@ -0,0 +1,269 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	plansCollection         = "billing_plans"
+	subscriptionsCollection = "subscriptions"
+)
+
+// Billing errors
+var (
+	ErrPlanNotFound         = errors.New("plan not found")
+	ErrPlanInUse            = errors.New("plan has subscriptions")
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+)
+
+// BillingRepository handles billing plans and subscriptions.
+type BillingRepository struct {
+	db *database.MongoDB
+}
+
+// NewBillingRepository creates a new BillingRepository.
+func NewBillingRepository(db *database.MongoDB) *BillingRepository {
+	return &BillingRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the subscriptions collection.
+func (r *BillingRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		// One subscription per batch, and one without a batch per org
+		{
+			Keys:    bson.D{{Key: "org", Value: 1}, {Key: "batchId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Provider webhooks refer to subscriptions by the provider's ID
+		{
+			Keys: bson.D{{Key: "provider", Value: 1}, {Key: "externalId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"externalId": bson.M{"$gt": ""}}),
+		},
+		{Keys: bson.D{{Key: "planId", Value: 1}}},
+	}
+
+	_, err := r.db.Collection(subscriptionsCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// FindPlans returns all plans by name.
+func (r *BillingRepository) FindPlans(ctx context.Context) ([]models.Plan, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := r.db.Collection(plansCollection).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	plans := []models.Plan{}
+	if err := cursor.All(ctx, &plans); err != nil {
+		return nil, dbErr(err)
+	}
+	return plans, nil
+}
+
+// FindPlan returns a plan by ID.
+func (r *BillingRepository) FindPlan(ctx context.Context, id string) (*models.Plan, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	plan := &models.Plan{}
+	err := r.db.Collection(plansCollection).FindOne(ctx, bson.M{"_id": id}).Decode(plan)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPlanNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return plan, nil
+}
+
+// SavePlan creates a plan or replaces the one with its ID.
+func (r *BillingRepository) SavePlan(ctx context.Context, plan *models.Plan) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(plansCollection)
+
+	now := time.Now()
+	plan.UpdatedAt = now
+	if existing, err := r.FindPlan(ctx, plan.ID); err == nil {
+		plan.CreatedAt = existing.CreatedAt
+	} else {
+		plan.CreatedAt = now
+	}
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": plan.ID}, plan, options.Replace().SetUpsert(true))
+	return dbErr(err)
+}
+
+// DeletePlan deletes a plan no subscription is on.
+func (r *BillingRepository) DeletePlan(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	inUse, err := r.db.Collection(subscriptionsCollection).CountDocuments(ctx, bson.M{"planId": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return dbErr(err)
+	}
+	if inUse > 0 {
+		return ErrPlanInUse
+	}
+
+	result, err := r.db.Collection(plansCollection).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrPlanNotFound
+	}
+	return nil
+}
+
+// subscriptionFilter matches the subscription of a batch, or the
+// organization's own when batchID is zero.
+func subscriptionFilter(org string, batchID primitive.ObjectID) bson.M {
+	if batchID.IsZero() {
+		return bson.M{"org": org, "batchId": bson.M{"$exists": false}}
+	}
+	return bson.M{"org": org, "batchId": batchID}
+}
+
+// FindSubscriptions returns the subscriptions of an organization.
+func (r *BillingRepository) FindSubscriptions(ctx context.Context, org string) ([]models.Subscription, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := r.db.Collection(subscriptionsCollection).Find(ctx, bson.M{"org": org}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	subscriptions := []models.Subscription{}
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, dbErr(err)
+	}
+	return subscriptions, nil
+}
+
+// FindSubscription returns the subscription of a batch, or the
+// organization's own when batchID is zero.
+func (r *BillingRepository) FindSubscription(ctx context.Context, org string, batchID primitive.ObjectID) (*models.Subscription, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	subscription := &models.Subscription{}
+	err := r.db.Collection(subscriptionsCollection).FindOne(ctx, subscriptionFilter(org, batchID)).Decode(subscription)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return subscription, nil
+}
+
+// FindSubscriptionByExternalID returns a subscription by the ID its payment
+// provider knows it by.
+func (r *BillingRepository) FindSubscriptionByExternalID(ctx context.Context, provider, externalID string) (*models.Subscription, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	subscription := &models.Subscription{}
+	err := r.db.Collection(subscriptionsCollection).FindOne(ctx, bson.M{"provider": provider, "externalId": externalID}).Decode(subscription)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return subscription, nil
+}
+
+// SaveSubscription creates the subscription of its org and batch, or
+// replaces the terms of the existing one, and returns the stored result.
+func (r *BillingRepository) SaveSubscription(ctx context.Context, subscription *models.Subscription) (*models.Subscription, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(subscriptionsCollection)
+
+	now := time.Now()
+	set := bson.M{
+		"planId":           subscription.PlanID,
+		"status":           subscription.Status,
+		"seats":            subscription.Seats,
+		"trialEndsAt":      subscription.TrialEndsAt,
+		"currentPeriodEnd": subscription.CurrentPeriodEnd,
+		"provider":         subscription.Provider,
+		"externalId":       subscription.ExternalID,
+		"updatedAt":        now,
+	}
+	setOnInsert := bson.M{"createdAt": now}
+	if !subscription.BatchID.IsZero() {
+		setOnInsert["batchId"] = subscription.BatchID
+	}
+
+	update := bson.M{"$set": set, "$setOnInsert": setOnInsert}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	stored := &models.Subscription{}
+	err := collection.FindOneAndUpdate(ctx, subscriptionFilter(subscription.Org, subscription.BatchID), update, opts).Decode(stored)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return stored, nil
+}
+
+// UpdateSubscriptionStatus changes the status of a subscription.
+func (r *BillingRepository) UpdateSubscriptionStatus(ctx context.Context, id primitive.ObjectID, status models.SubscriptionStatus) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	result, err := r.db.Collection(subscriptionsCollection).UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{"status": status, "updatedAt": time.Now()},
+	})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// DeleteSubscription deletes a subscription.
+func (r *BillingRepository) DeleteSubscription(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSubscriptionNotFound
+	}
+
+	result, err := r.db.Collection(subscriptionsCollection).DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
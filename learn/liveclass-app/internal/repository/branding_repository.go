@@ -0,0 +1,59 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const brandingCollection = "branding"
+
+// Branding errors
+var (
+	ErrBrandingNotFound = errors.New("branding not found")
+)
+
+// BrandingRepository handles the branding of each organization, one
+// document per org.
+type BrandingRepository struct {
+	db *database.MongoDB
+}
+
+// NewBrandingRepository creates a new BrandingRepository.
+func NewBrandingRepository(db *database.MongoDB) *BrandingRepository {
+	return &BrandingRepository{db: db}
+}
+
+// Find returns the branding of org.
+func (r *BrandingRepository) Find(ctx context.Context, org string) (*models.Branding, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	branding := &models.Branding{}
+	err := r.db.Collection(brandingCollection).FindOne(ctx, bson.M{"_id": org}).Decode(branding)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBrandingNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return branding, nil
+}
+
+// Save stores the branding of its org, replacing any earlier one.
+func (r *BrandingRepository) Save(ctx context.Context, branding *models.Branding) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	branding.UpdatedAt = time.Now()
+	_, err := r.db.Collection(brandingCollection).ReplaceOne(ctx,
+		bson.M{"_id": branding.Org}, branding, options.Replace().SetUpsert(true))
+	return dbErr(err)
+}
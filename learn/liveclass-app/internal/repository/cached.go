@@ -0,0 +1,119 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
+	"golang.org/x/sync/singleflight"
+)
+
+// negativeCacheTTL is how long a lookup that found nothing is remembered.
+// It's short, so a document created on another instance shows up soon.
+const negativeCacheTTL = 10 * time.Second
+
+// cacheNamespace is a family of cache keys for values of type T, e.g.
+// batches by ID. Each namespace has its own prefix, so keys of different
+// namespaces never collide.
+type cacheNamespace[T any] string
+
+// key returns the key of id in the namespace.
+func (n cacheNamespace[T]) key(id string) cacheKey[T] {
+	return cacheKey[T]{s: string(n) + id}
+}
+
+// cacheKey is the key of a cached value of type T. Keys are only built from
+// namespaces, so a key can't be used to read a value of another type.
+type cacheKey[T any] struct {
+	s string
+}
+
+// cacheEntry is a cached value, or the record that there is none.
+type cacheEntry[T any] struct {
+	value   T
+	missing bool
+}
+
+// cachedRepo caches values of type T that a repository loads. Concurrent
+// misses on the same key share one load, and lookups failing with the
+// repository's not-found error are remembered for negativeCacheTTL.
+type cachedRepo[T any] struct {
+	store    *cache.Cache[cacheEntry[T]]
+	notFound error // nil when nothing is negatively cached
+	flight   singleflight.Group
+}
+
+// newCachedRepo creates a cache whose values expire after ttl.
+func newCachedRepo[T any](ttl, cleanupInterval time.Duration, notFound error) *cachedRepo[T] {
+	return &cachedRepo[T]{
+		store:    cache.New[cacheEntry[T]](ttl, cleanupInterval),
+		notFound: notFound,
+	}
+}
+
+// get returns the cached value of key.
+func (c *cachedRepo[T]) get(key cacheKey[T]) (T, bool) {
+	entry, found := c.store.Get(key.s)
+	if !found || entry.missing {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// set caches the value of key.
+func (c *cachedRepo[T]) set(key cacheKey[T], value T) {
+	c.store.Set(key.s, cacheEntry[T]{value: value})
+}
+
+// delete removes key, including a record that it wasn't found.
+func (c *cachedRepo[T]) delete(key cacheKey[T]) {
+	c.store.Delete(key.s)
+}
+
+// deleteNamespace removes every key of a namespace.
+func (c *cachedRepo[T]) deleteNamespace(ns cacheNamespace[T]) {
+	c.store.DeletePrefix(string(ns))
+}
+
+// clear removes everything.
+func (c *cachedRepo[T]) clear() {
+	c.store.Clear()
+}
+
+// load returns the value of key from the cache, or loads and caches it with
+// fn. fn runs once for concurrent misses on a key, detached from the
+// caller's cancellation so one caller giving up doesn't fail the others;
+// it should bound itself with the database's read budget.
+func (c *cachedRepo[T]) load(ctx context.Context, key cacheKey[T], fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if entry, found := c.store.Get(key.s); found {
+		if entry.missing {
+			return zero, c.notFound
+		}
+		return entry.value, nil
+	}
+
+	result := c.flight.DoChan(key.s, func() (interface{}, error) {
+		value, err := fn(context.WithoutCancel(ctx))
+		switch {
+		case err == nil:
+			c.set(key, value)
+		case c.notFound != nil && errors.Is(err, c.notFound):
+			c.store.SetWithExpiration(key.s, cacheEntry[T]{missing: true}, negativeCacheTTL)
+		}
+		return value, err
+	})
+
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		return zero, dbErr(ctx.Err())
+	}
+}
@@ -0,0 +1,83 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const catchUpCollection = "catch_up_summaries"
+
+// CatchUpRepository keeps the weekly catch-up summaries sent to students.
+type CatchUpRepository struct {
+	db *database.MongoDB
+}
+
+// NewCatchUpRepository creates a new CatchUpRepository.
+func NewCatchUpRepository(db *database.MongoDB) *CatchUpRepository {
+	return &CatchUpRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the catch-up summaries collection.
+func (r *CatchUpRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(catchUpCollection)
+
+	indexes := []mongo.IndexModel{
+		// One summary per student and week
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "weekStart", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Claim stores a student's summary for a week. It returns false if one was
+// already stored, so only one instance sends it.
+func (r *CatchUpRepository) Claim(ctx context.Context, summary *models.CatchUpSummary) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(catchUpCollection)
+
+	summary.ID = primitive.NewObjectID()
+
+	_, err := collection.InsertOne(ctx, summary)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, dbErr(err)
+}
+
+// FindSent returns up to limit summaries sent to a student, newest first.
+func (r *CatchUpRepository) FindSent(ctx context.Context, userID primitive.ObjectID, limit int64) ([]models.CatchUpSummary, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(catchUpCollection)
+
+	filter := bson.M{"userId": userID, "sentAt": bson.M{"$exists": true}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "weekStart", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	summaries := []models.CatchUpSummary{}
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, dbErr(err)
+	}
+	return summaries, nil
+}
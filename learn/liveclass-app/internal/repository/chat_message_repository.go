@@ -0,0 +1,116 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const chatMessagesCollection = "chat_messages"
+
+// ChatMessageRepository handles the chat history of live rooms.
+type ChatMessageRepository struct {
+	db *database.MongoDB
+}
+
+// NewChatMessageRepository creates a new ChatMessageRepository.
+func NewChatMessageRepository(db *database.MongoDB) *ChatMessageRepository {
+	return &ChatMessageRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the chat messages collection.
+func (r *ChatMessageRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(chatMessagesCollection)
+
+	indexes := []mongo.IndexModel{
+		// History of a class, newest first
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "sentAt", Value: -1}}},
+		// Deleting a message by the ID the room knows it by
+		{Keys: bson.D{{Key: "messageId", Value: 1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Append stores posted messages.
+func (r *ChatMessageRepository) Append(ctx context.Context, messages []models.ChatMessage) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	if len(messages) == 0 {
+		return nil
+	}
+	collection := r.db.Collection(chatMessagesCollection)
+
+	docs := make([]interface{}, len(messages))
+	for i := range messages {
+		docs[i] = messages[i]
+	}
+
+	_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return dbErr(err)
+}
+
+// MarkDeleted deletes a message, keeping a record of who deleted it but
+// not what it said. Deleting an unknown message is not an error.
+func (r *ChatMessageRepository) MarkDeleted(ctx context.Context, messageID, by string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(chatMessagesCollection)
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"messageId": messageID, "deletedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"body": "", "deletedAt": time.Now(), "deletedBy": by}},
+	)
+	return dbErr(err)
+}
+
+// FindBySchedule returns up to limit messages of a class that weren't
+// deleted, newest first. If before is non-zero, only messages sent before
+// then are returned. Private messages are left out unless includePrivate,
+// except those userID sent.
+func (r *ChatMessageRepository) FindBySchedule(ctx context.Context, scheduleID primitive.ObjectID, userID string, includePrivate bool, before time.Time, limit int64) ([]models.ChatMessage, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(chatMessagesCollection)
+
+	filter := bson.M{
+		"scheduleId": scheduleID,
+		"deletedAt":  bson.M{"$exists": false},
+	}
+	if !includePrivate {
+		filter["$or"] = []bson.M{
+			{"private": bson.M{"$ne": true}},
+			{"userId": userID},
+		}
+	}
+	if !before.IsZero() {
+		filter["sentAt"] = bson.M{"$lt": before}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "sentAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.ChatMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, dbErr(err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,93 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const classRollupsCollection = "class_rollups"
+
+// ClassRollupRepository handles the per-class rollups behind cohort reports.
+type ClassRollupRepository struct {
+	db *database.MongoDB
+}
+
+// NewClassRollupRepository creates a new ClassRollupRepository.
+func NewClassRollupRepository(db *database.MongoDB) *ClassRollupRepository {
+	return &ClassRollupRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the class_rollups collection.
+func (r *ClassRollupRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "templateId", Value: 1}, {Key: "startTime", Value: 1}}},
+	}
+	_, err := r.db.Collection(classRollupsCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Save stores the rollup of a class, replacing an earlier one.
+func (r *ClassRollupRepository) Save(ctx context.Context, rollup *models.ClassRollup) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	_, err := r.db.Collection(classRollupsCollection).ReplaceOne(ctx,
+		bson.M{"_id": rollup.ScheduleID}, rollup, options.Replace().SetUpsert(true))
+	return dbErr(err)
+}
+
+// FindByTemplate returns the rollups of the classes scheduled from a
+// template, oldest first, from the report read preference.
+func (r *ClassRollupRepository) FindByTemplate(ctx context.Context, templateID primitive.ObjectID) ([]models.ClassRollup, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}})
+	cursor, err := r.db.ReportCollection(classRollupsCollection).Find(ctx, bson.M{"templateId": templateID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	rollups := []models.ClassRollup{}
+	if err := cursor.All(ctx, &rollups); err != nil {
+		return nil, dbErr(err)
+	}
+	return rollups, nil
+}
+
+// RolledUp returns which of the classes already have a rollup.
+func (r *ClassRollupRepository) RolledUp(ctx context.Context, scheduleIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	done := make(map[primitive.ObjectID]bool)
+	if len(scheduleIDs) == 0 {
+		return done, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := r.db.Collection(classRollupsCollection).Find(ctx, bson.M{"_id": bson.M{"$in": scheduleIDs}}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, dbErr(err)
+		}
+		done[doc.ID] = true
+	}
+	return done, dbErr(cursor.Err())
+}
@@ -0,0 +1,156 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const classTemplatesCollection = "class_templates"
+
+// Class template errors
+var (
+	ErrTemplateNotFound = errors.New("class template not found")
+)
+
+// ClassTemplateRepository handles class template data operations.
+type ClassTemplateRepository struct {
+	db *database.MongoDB
+}
+
+// NewClassTemplateRepository creates a new ClassTemplateRepository.
+func NewClassTemplateRepository(db *database.MongoDB) *ClassTemplateRepository {
+	return &ClassTemplateRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the class templates collection.
+func (r *ClassTemplateRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(classTemplatesCollection)
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "presenterId", Value: 1}, {Key: "title", Value: 1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create inserts a new template.
+func (r *ClassTemplateRepository) Create(ctx context.Context, template *models.ClassTemplate) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(classTemplatesCollection)
+
+	template.ID = primitive.NewObjectID()
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = template.CreatedAt
+
+	_, err := collection.InsertOne(ctx, template)
+	return dbErr(err)
+}
+
+// FindByID finds a template by ID.
+func (r *ClassTemplateRepository) FindByID(ctx context.Context, id string) (*models.ClassTemplate, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrTemplateNotFound
+	}
+
+	collection := r.db.Collection(classTemplatesCollection)
+
+	var template models.ClassTemplate
+	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&template)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return &template, nil
+}
+
+// FindByPresenter returns a presenter's templates sorted by title.
+func (r *ClassTemplateRepository) FindByPresenter(ctx context.Context, presenterID primitive.ObjectID) ([]models.ClassTemplate, error) {
+	return r.find(ctx, bson.M{"presenterId": presenterID})
+}
+
+// FindAll returns every template sorted by title.
+func (r *ClassTemplateRepository) FindAll(ctx context.Context) ([]models.ClassTemplate, error) {
+	return r.find(ctx, bson.M{})
+}
+
+// find returns the templates matching filter, sorted by title.
+func (r *ClassTemplateRepository) find(ctx context.Context, filter bson.M) ([]models.ClassTemplate, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(classTemplatesCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "title", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	templates := []models.ClassTemplate{}
+	if err := cursor.All(ctx, &templates); err != nil {
+		return nil, dbErr(err)
+	}
+	return templates, nil
+}
+
+// Update saves a template's editable fields.
+func (r *ClassTemplateRepository) Update(ctx context.Context, template *models.ClassTemplate) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(classTemplatesCollection)
+
+	template.UpdatedAt = time.Now()
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": template.ID}, bson.M{"$set": bson.M{
+		"title":           template.Title,
+		"description":     template.Description,
+		"durationMinutes": template.DurationMinutes,
+		"objectives":      template.Objectives,
+		"materials":       template.Materials,
+		"updatedAt":       template.UpdatedAt,
+	}})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// Delete removes a template. Classes scheduled from it keep their copy of the
+// objectives and materials.
+func (r *ClassTemplateRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(classTemplatesCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const clientEventsCollection = "client_events"
+
+// ClientEventRepository handles telemetry reported by the SPA. Events live
+// in a capped collection, so the oldest make room for new ones without a
+// cleanup job.
+type ClientEventRepository struct {
+	db *database.MongoDB
+}
+
+// NewClientEventRepository creates a new ClientEventRepository.
+func NewClientEventRepository(db *database.MongoDB) *ClientEventRepository {
+	return &ClientEventRepository{db: db}
+}
+
+// CreateCollection creates the capped collection of client events, holding
+// at most maxBytes. An existing collection is left as it is.
+func (r *ClientEventRepository) CreateCollection(ctx context.Context, maxBytes int64) error {
+	opts := options.CreateCollection().SetCapped(true).SetSizeInBytes(maxBytes)
+	err := r.db.Database.CreateCollection(ctx, clientEventsCollection, opts)
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists" {
+		return nil
+	}
+	return err
+}
+
+// CreateIndexes creates necessary indexes for the client events collection.
+func (r *ClientEventRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		// Events of a type over a time range
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "receivedAt", Value: -1}}},
+		// Events over a time range
+		{Keys: bson.D{{Key: "receivedAt", Value: -1}}},
+		// Events of a user
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "receivedAt", Value: -1}}},
+	}
+	_, err := r.db.Collection(clientEventsCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// InsertMany stores a batch of events.
+func (r *ClientEventRepository) InsertMany(ctx context.Context, events []models.ClientEvent) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	docs := make([]interface{}, len(events))
+	for i := range events {
+		events[i].ID = primitive.NewObjectID()
+		docs[i] = events[i]
+	}
+	_, err := r.db.Collection(clientEventsCollection).InsertMany(ctx, docs)
+	return dbErr(err)
+}
+
+// ClientEventFilter selects client events. Empty fields match everything.
+type ClientEventFilter struct {
+	Type     string
+	UserID   primitive.ObjectID
+	RoomID   string
+	From, To time.Time // Received in [From, To)
+}
+
+func (f ClientEventFilter) query() bson.M {
+	query := bson.M{"receivedAt": bson.M{"$gte": f.From, "$lt": f.To}}
+	if f.Type != "" {
+		query["type"] = f.Type
+	}
+	if !f.UserID.IsZero() {
+		query["userId"] = f.UserID
+	}
+	if f.RoomID != "" {
+		query["roomId"] = f.RoomID
+	}
+	return query
+}
+
+// Find returns up to limit events matching filter, newest first.
+func (r *ClientEventRepository) Find(ctx context.Context, filter ClientEventFilter, limit int64) ([]models.ClientEvent, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "receivedAt", Value: -1}}).SetLimit(limit)
+	cursor, err := r.db.ReportCollection(clientEventsCollection).Find(ctx, filter.query(), opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.ClientEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, dbErr(err)
+	}
+	return events, nil
+}
+
+// ClientEventCount is how many events of a kind were reported. Estimated
+// makes up for sampling.
+type ClientEventCount struct {
+	Type      string    `json:"type"`
+	Code      string    `json:"code,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Day       string    `json:"day,omitempty"` // YYYY-MM-DD, UTC
+	Count     int       `json:"count"`
+	Estimated float64   `json:"estimated"`
+	Users     int       `json:"users"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// CountByDay returns the events matching filter per type and day.
+func (r *ClientEventRepository) CountByDay(ctx context.Context, filter ClientEventFilter) ([]ClientEventCount, error) {
+	return r.countQuery(ctx, filter.query(), bson.M{
+		"type": "$type",
+		"day":  bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$receivedAt"}},
+	}, bson.D{{Key: "_id.day", Value: 1}, {Key: "_id.type", Value: 1}}, 0)
+}
+
+// TopErrors returns the limit most frequent errors matching filter, by
+// type, code and message. Page views aren't errors and are left out.
+func (r *ClientEventRepository) TopErrors(ctx context.Context, filter ClientEventFilter, limit int64) ([]ClientEventCount, error) {
+	query := filter.query()
+	if filter.Type == "" {
+		query["type"] = bson.M{"$ne": models.ClientEventPageView}
+	}
+	return r.countQuery(ctx, query, bson.M{
+		"type":    "$type",
+		"code":    "$code",
+		"message": "$message",
+	}, bson.D{{Key: "estimated", Value: -1}}, limit)
+}
+
+// countQuery groups the events matching query by key, counting events,
+// sampling-weighted events and distinct users.
+func (r *ClientEventRepository) countQuery(ctx context.Context, query bson.M, key bson.M, sort bson.D, limit int64) ([]ClientEventCount, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       key,
+			"count":     bson.M{"$sum": 1},
+			"estimated": bson.M{"$sum": bson.M{"$divide": bson.A{1, "$sampleRate"}}},
+			"users":     bson.M{"$addToSet": "$userId"},
+			"lastSeen":  bson.M{"$max": "$receivedAt"},
+		}}},
+		{{Key: "$sort", Value: sort}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+
+	cursor, err := r.db.ReportCollection(clientEventsCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Key struct {
+			Type    string `bson:"type"`
+			Code    string `bson:"code"`
+			Message string `bson:"message"`
+			Day     string `bson:"day"`
+		} `bson:"_id"`
+		Count     int                  `bson:"count"`
+		Estimated float64              `bson:"estimated"`
+		Users     []primitive.ObjectID `bson:"users"`
+		LastSeen  time.Time            `bson:"lastSeen"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, dbErr(err)
+	}
+
+	counts := make([]ClientEventCount, len(rows))
+	for i, row := range rows {
+		counts[i] = ClientEventCount{
+			Type:      row.Key.Type,
+			Code:      row.Key.Code,
+			Message:   row.Key.Message,
+			Day:       row.Key.Day,
+			Count:     row.Count,
+			Estimated: row.Estimated,
+			Users:     len(row.Users),
+			LastSeen:  row.LastSeen,
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,165 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const directMessagesCollection = "direct_messages"
+
+// DirectMessageRepository handles direct message persistence.
+type DirectMessageRepository struct {
+	db *database.MongoDB
+}
+
+// NewDirectMessageRepository creates a new DirectMessageRepository.
+func NewDirectMessageRepository(db *database.MongoDB) *DirectMessageRepository {
+	return &DirectMessageRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the direct messages collection.
+func (r *DirectMessageRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(directMessagesCollection)
+
+	indexes := []mongo.IndexModel{
+		// Conversation lookups
+		{
+			Keys: bson.D{{Key: "batchId", Value: 1}, {Key: "senderId", Value: 1}, {Key: "recipientId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		// Unread counts
+		{
+			Keys: bson.D{{Key: "recipientId", Value: 1}, {Key: "readAt", Value: 1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new direct message.
+func (r *DirectMessageRepository) Create(ctx context.Context, msg *models.DirectMessage) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(directMessagesCollection)
+
+	msg.ID = primitive.NewObjectID()
+	msg.CreatedAt = time.Now()
+
+	_, err := collection.InsertOne(ctx, msg)
+	return dbErr(err)
+}
+
+// FindConversation returns messages between two users in a batch, newest first.
+// If before is non-zero, only messages created before that time are returned.
+func (r *DirectMessageRepository) FindConversation(ctx context.Context, batchID, userA, userB primitive.ObjectID, before time.Time, limit int64) ([]models.DirectMessage, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(directMessagesCollection)
+
+	filter := bson.M{
+		"batchId": batchID,
+		"$or": []bson.M{
+			{"senderId": userA, "recipientId": userB},
+			{"senderId": userB, "recipientId": userA},
+		},
+	}
+	if !before.IsZero() {
+		filter["createdAt"] = bson.M{"$lt": before}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	messages := []models.DirectMessage{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, dbErr(err)
+	}
+
+	return messages, nil
+}
+
+// MarkConversationRead marks all unread messages from sender to recipient in a batch as read.
+// It returns the number of messages that were updated.
+func (r *DirectMessageRepository) MarkConversationRead(ctx context.Context, batchID, senderID, recipientID primitive.ObjectID) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(directMessagesCollection)
+
+	filter := bson.M{
+		"batchId":     batchID,
+		"senderId":    senderID,
+		"recipientId": recipientID,
+		"readAt":      bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{"readAt": time.Now()}}
+
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, dbErr(err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// CountUnread returns unread message counts for a recipient grouped by batch and sender.
+func (r *DirectMessageRepository) CountUnread(ctx context.Context, recipientID primitive.ObjectID) ([]models.UnreadCount, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(directMessagesCollection)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"recipientId": recipientID,
+			"readAt":      bson.M{"$exists": false},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"batchId": "$batchId", "senderId": "$senderId"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			BatchID  primitive.ObjectID `bson:"batchId"`
+			SenderID primitive.ObjectID `bson:"senderId"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, dbErr(err)
+	}
+
+	counts := make([]models.UnreadCount, len(rows))
+	for i, row := range rows {
+		counts[i] = models.UnreadCount{
+			BatchID:  row.ID.BatchID.Hex(),
+			SenderID: row.ID.SenderID.Hex(),
+			Count:    row.Count,
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,98 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const examAuditCollection = "exam_audit_events"
+
+// ExamAuditRepository handles the exam-mode audit trail.
+type ExamAuditRepository struct {
+	db *database.MongoDB
+}
+
+// NewExamAuditRepository creates a new ExamAuditRepository.
+func NewExamAuditRepository(db *database.MongoDB) *ExamAuditRepository {
+	return &ExamAuditRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the exam audit collection.
+func (r *ExamAuditRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(examAuditCollection)
+
+	indexes := []mongo.IndexModel{
+		// Audit trail of a class in time order
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "at", Value: 1}}},
+		// Prior joins of a student (late-entry rejoin check)
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "userId", Value: 1}, {Key: "event", Value: 1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Record appends an event to the audit trail.
+func (r *ExamAuditRepository) Record(ctx context.Context, event *models.ExamAuditEvent) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(examAuditCollection)
+
+	event.ID = primitive.NewObjectID()
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	_, err := collection.InsertOne(ctx, event)
+	return dbErr(err)
+}
+
+// FindBySchedule returns the audit trail of a class, oldest first.
+func (r *ExamAuditRepository) FindBySchedule(ctx context.Context, scheduleID primitive.ObjectID) ([]models.ExamAuditEvent, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(examAuditCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"scheduleId": scheduleID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.ExamAuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, dbErr(err)
+	}
+	return events, nil
+}
+
+// HasJoined reports whether the user has joined the class before, which lets a
+// student whose connection dropped back in after the late-entry deadline.
+func (r *ExamAuditRepository) HasJoined(ctx context.Context, scheduleID, userID primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(examAuditCollection)
+
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"scheduleId": scheduleID,
+		"userId":     userID,
+		"event":      models.ExamEventJoin,
+		"source":     models.ExamSourceServer,
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, dbErr(err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,239 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	goalsCollection    = "goals"
+	activityCollection = "student_activity"
+)
+
+// Goal errors
+var (
+	ErrGoalNotFound = errors.New("goal not found")
+)
+
+// GoalRepository handles student goals and the activity they are measured against.
+type GoalRepository struct {
+	db *database.MongoDB
+}
+
+// NewGoalRepository creates a new GoalRepository.
+func NewGoalRepository(db *database.MongoDB) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the goals and activity collections.
+func (r *GoalRepository) CreateIndexes(ctx context.Context) error {
+	goals := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "kind", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+	if _, err := r.db.Collection(goalsCollection).Indexes().CreateMany(ctx, goals); err != nil {
+		return err
+	}
+
+	activity := []mongo.IndexModel{
+		// Each class or recording counts once per week
+		{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "kind", Value: 1},
+				{Key: "weekStart", Value: 1},
+				{Key: "refId", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		// Who attended a class, for cohort rollups
+		{Keys: bson.D{{Key: "kind", Value: 1}, {Key: "refId", Value: 1}}},
+	}
+	_, err := r.db.Collection(activityCollection).Indexes().CreateMany(ctx, activity)
+	return err
+}
+
+// SetGoal creates or updates a student's goal of the given kind.
+func (r *GoalRepository) SetGoal(ctx context.Context, userID primitive.ObjectID, kind models.GoalKind, target int) (*models.Goal, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(goalsCollection)
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{"target": target, "updatedAt": now},
+		"$setOnInsert": bson.M{
+			"_id":       primitive.NewObjectID(),
+			"createdAt": now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var goal models.Goal
+	err := collection.FindOneAndUpdate(ctx, bson.M{"userId": userID, "kind": kind}, update, opts).Decode(&goal)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return &goal, nil
+}
+
+// DeleteGoal removes a student's goal of the given kind.
+func (r *GoalRepository) DeleteGoal(ctx context.Context, userID primitive.ObjectID, kind models.GoalKind) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(goalsCollection)
+
+	result, err := collection.DeleteOne(ctx, bson.M{"userId": userID, "kind": kind})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrGoalNotFound
+	}
+	return nil
+}
+
+// FindGoals returns a student's goals.
+func (r *GoalRepository) FindGoals(ctx context.Context, userID primitive.ObjectID) ([]models.Goal, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(goalsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "kind", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	goals := []models.Goal{}
+	if err := cursor.All(ctx, &goals); err != nil {
+		return nil, dbErr(err)
+	}
+	return goals, nil
+}
+
+// RecordActivity records that a student attended a class or watched a
+// recording. Repeats within the same week are ignored.
+func (r *GoalRepository) RecordActivity(ctx context.Context, userID primitive.ObjectID, kind models.ActivityKind, refID string, at time.Time) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(activityCollection)
+
+	weekStart := models.WeekStart(at)
+	filter := bson.M{"userId": userID, "kind": kind, "weekStart": weekStart, "refId": refID}
+	update := bson.M{"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "at": at}}
+
+	_, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil // Recorded concurrently
+	}
+	return dbErr(err)
+}
+
+// Participants returns the users with activity of a kind on a class or
+// recording, read from the report read preference.
+func (r *GoalRepository) Participants(ctx context.Context, kind models.ActivityKind, refID string) ([]primitive.ObjectID, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.ReportCollection(activityCollection)
+
+	values, err := collection.Distinct(ctx, "userId", bson.M{"kind": kind, "refId": refID})
+	if err != nil {
+		return nil, dbErr(err)
+	}
+
+	users := make([]primitive.ObjectID, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(primitive.ObjectID); ok {
+			users = append(users, id)
+		}
+	}
+	return users, nil
+}
+
+// HadActivity returns which of the given classes or recordings a student
+// had activity of a kind on.
+func (r *GoalRepository) HadActivity(ctx context.Context, userID primitive.ObjectID, kind models.ActivityKind, refIDs []string) (map[string]bool, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	found := make(map[string]bool)
+	if len(refIDs) == 0 {
+		return found, nil
+	}
+
+	collection := r.db.Collection(activityCollection)
+
+	values, err := collection.Distinct(ctx, "refId", bson.M{
+		"userId": userID,
+		"kind":   kind,
+		"refId":  bson.M{"$in": refIDs},
+	})
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			found[id] = true
+		}
+	}
+	return found, nil
+}
+
+// WeeklyCounts returns how many distinct classes or recordings a student had
+// per week since the given week, keyed by week start.
+func (r *GoalRepository) WeeklyCounts(ctx context.Context, userID primitive.ObjectID, kind models.ActivityKind, since time.Time) (map[time.Time]int, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(activityCollection)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"userId":    userID,
+			"kind":      kind,
+			"weekStart": bson.M{"$gte": since},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$weekStart",
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		WeekStart time.Time `bson:"_id"`
+		Count     int       `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, dbErr(err)
+	}
+
+	counts := make(map[time.Time]int, len(rows))
+	for _, row := range rows {
+		counts[models.WeekStart(row.WeekStart)] = row.Count
+	}
+	return counts, nil
+}
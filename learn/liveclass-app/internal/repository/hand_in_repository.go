@@ -0,0 +1,122 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const handInsCollection = "hand_ins"
+
+// ErrHandInNotFound is returned when a hand-in doesn't exist.
+var ErrHandInNotFound = errors.New("hand-in not found")
+
+// HandInRepository handles work students submit during live classes.
+type HandInRepository struct {
+	db *database.MongoDB
+}
+
+// NewHandInRepository creates a new HandInRepository.
+func NewHandInRepository(db *database.MongoDB) *HandInRepository {
+	return &HandInRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the hand_ins collection.
+func (r *HandInRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		// A class's gallery, and each student's submissions to it
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "studentId", Value: 1}}},
+	}
+	_, err := r.db.Collection(handInsCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new hand-in.
+func (r *HandInRepository) Create(ctx context.Context, handIn *models.HandIn) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	handIn.ID = primitive.NewObjectID()
+	handIn.CreatedAt = time.Now()
+	_, err := r.db.Collection(handInsCollection).InsertOne(ctx, handIn)
+	return dbErr(err)
+}
+
+// FindByID returns a hand-in of a class.
+func (r *HandInRepository) FindByID(ctx context.Context, scheduleID, id primitive.ObjectID) (*models.HandIn, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	handIn := &models.HandIn{}
+	err := r.db.Collection(handInsCollection).FindOne(ctx, bson.M{"_id": id, "scheduleId": scheduleID}).Decode(handIn)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrHandInNotFound
+	}
+	return handIn, dbErr(err)
+}
+
+// FindBySchedule returns the hand-ins of a class, oldest first.
+func (r *HandInRepository) FindBySchedule(ctx context.Context, scheduleID primitive.ObjectID) ([]models.HandIn, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := r.db.Collection(handInsCollection).Find(ctx, bson.M{"scheduleId": scheduleID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	handIns := []models.HandIn{}
+	if err := cursor.All(ctx, &handIns); err != nil {
+		return nil, dbErr(err)
+	}
+	return handIns, nil
+}
+
+// CountByStudent returns how many hand-ins a student submitted to a class.
+func (r *HandInRepository) CountByStudent(ctx context.Context, scheduleID, studentID primitive.ObjectID) (int64, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	count, err := r.db.Collection(handInsCollection).CountDocuments(ctx, bson.M{"scheduleId": scheduleID, "studentId": studentID})
+	return count, dbErr(err)
+}
+
+// Spotlight makes a hand-in the one shown to everyone in its class, or
+// clears the class's spotlight when id is nil.
+func (r *HandInRepository) Spotlight(ctx context.Context, scheduleID primitive.ObjectID, id *primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(handInsCollection)
+
+	clear := bson.M{"scheduleId": scheduleID, "spotlighted": true}
+	if id != nil {
+		clear["_id"] = bson.M{"$ne": *id}
+	}
+	if _, err := collection.UpdateMany(ctx, clear, bson.M{"$set": bson.M{"spotlighted": false}}); err != nil {
+		return dbErr(err)
+	}
+	if id == nil {
+		return nil
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": *id, "scheduleId": scheduleID}, bson.M{"$set": bson.M{"spotlighted": true}})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrHandInNotFound
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package repository
+
+import "sync"
+
+// writeHooks holds callbacks run after a repository write, e.g. to invalidate
+// HTTP response caches built from the repository's data.
+type writeHooks struct {
+	hooks []func()
+	mu    sync.RWMutex
+}
+
+// OnWrite registers fn to run after every write.
+func (h *writeHooks) OnWrite(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hooks = append(h.hooks, fn)
+}
+
+// fireWrite runs the registered write hooks.
+func (h *writeHooks) fireWrite() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, fn := range h.hooks {
+		fn()
+	}
+}
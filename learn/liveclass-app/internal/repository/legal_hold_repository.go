@@ -0,0 +1,204 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	legalHoldsCollection      = "legal_holds"
+	legalHoldEventsCollection = "legal_hold_events"
+)
+
+// Legal hold errors
+var (
+	ErrLegalHoldNotFound = errors.New("legal hold not found")
+	ErrAlreadyOnHold     = errors.New("content is already under legal hold")
+)
+
+// LegalHoldRepository handles legal holds and their audit log.
+type LegalHoldRepository struct {
+	db *database.MongoDB
+}
+
+// NewLegalHoldRepository creates a new LegalHoldRepository.
+func NewLegalHoldRepository(db *database.MongoDB) *LegalHoldRepository {
+	return &LegalHoldRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the legal hold collections.
+func (r *LegalHoldRepository) CreateIndexes(ctx context.Context) error {
+	holds := []mongo.IndexModel{
+		// At most one active hold per piece of content
+		{
+			Keys: bson.D{{Key: "contentType", Value: 1}, {Key: "contentId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{Keys: bson.D{{Key: "active", Value: 1}, {Key: "placedAt", Value: -1}}},
+	}
+	if _, err := r.db.Collection(legalHoldsCollection).Indexes().CreateMany(ctx, holds); err != nil {
+		return err
+	}
+
+	events := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "contentType", Value: 1}, {Key: "contentId", Value: 1}, {Key: "at", Value: 1}}},
+		{Keys: bson.D{{Key: "at", Value: -1}}},
+	}
+	_, err := r.db.Collection(legalHoldEventsCollection).Indexes().CreateMany(ctx, events)
+	return err
+}
+
+// Place creates an active hold.
+func (r *LegalHoldRepository) Place(ctx context.Context, hold *models.LegalHold) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(legalHoldsCollection)
+
+	hold.ID = primitive.NewObjectID()
+	hold.Active = true
+	hold.PlacedAt = time.Now()
+
+	_, err := collection.InsertOne(ctx, hold)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyOnHold
+	}
+	return dbErr(err)
+}
+
+// Release lifts an active hold and returns it.
+func (r *LegalHoldRepository) Release(ctx context.Context, id string, by primitive.ObjectID, byName, reason string) (*models.LegalHold, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrLegalHoldNotFound
+	}
+
+	collection := r.db.Collection(legalHoldsCollection)
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"active":         false,
+		"releasedBy":     by,
+		"releasedByName": byName,
+		"releasedAt":     now,
+		"releaseReason":  reason,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var hold models.LegalHold
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": objectID, "active": true}, update, opts).Decode(&hold)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrLegalHoldNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return &hold, nil
+}
+
+// FindActive returns the active hold on a piece of content, or ErrLegalHoldNotFound.
+func (r *LegalHoldRepository) FindActive(ctx context.Context, contentType models.HoldContentType, contentID string) (*models.LegalHold, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(legalHoldsCollection)
+
+	var hold models.LegalHold
+	err := collection.FindOne(ctx, bson.M{
+		"contentType": contentType,
+		"contentId":   contentID,
+		"active":      true,
+	}).Decode(&hold)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrLegalHoldNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return &hold, nil
+}
+
+// FindAll returns holds, newest first. With activeOnly, released holds are left out.
+func (r *LegalHoldRepository) FindAll(ctx context.Context, activeOnly bool) ([]models.LegalHold, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(legalHoldsCollection)
+
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "placedAt", Value: -1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	holds := []models.LegalHold{}
+	if err := cursor.All(ctx, &holds); err != nil {
+		return nil, dbErr(err)
+	}
+	return holds, nil
+}
+
+// RecordEvent appends an entry to the hold audit log. Entries are never updated or deleted.
+func (r *LegalHoldRepository) RecordEvent(ctx context.Context, event *models.LegalHoldEvent) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(legalHoldEventsCollection)
+
+	event.ID = primitive.NewObjectID()
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	_, err := collection.InsertOne(ctx, event)
+	return dbErr(err)
+}
+
+// FindEvents returns audit log entries, oldest first. Empty contentType and
+// contentID return the whole log.
+func (r *LegalHoldRepository) FindEvents(ctx context.Context, contentType models.HoldContentType, contentID string) ([]models.LegalHoldEvent, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(legalHoldEventsCollection)
+
+	filter := bson.M{}
+	if contentType != "" {
+		filter["contentType"] = contentType
+	}
+	if contentID != "" {
+		filter["contentId"] = contentID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.LegalHoldEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, dbErr(err)
+	}
+	return events, nil
+}
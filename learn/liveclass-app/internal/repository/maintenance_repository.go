@@ -0,0 +1,106 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maintenanceWindowsCollection = "maintenance_windows"
+
+// Maintenance window errors
+var (
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+)
+
+// MaintenanceRepository handles maintenance windows.
+type MaintenanceRepository struct {
+	db *database.MongoDB
+}
+
+// NewMaintenanceRepository creates a new MaintenanceRepository.
+func NewMaintenanceRepository(db *database.MongoDB) *MaintenanceRepository {
+	return &MaintenanceRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the maintenance windows collection.
+func (r *MaintenanceRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "endTime", Value: 1}, {Key: "startTime", Value: 1}}},
+	}
+	_, err := r.db.Collection(maintenanceWindowsCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new maintenance window.
+func (r *MaintenanceRepository) Create(ctx context.Context, window *models.MaintenanceWindow) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	window.ID = primitive.NewObjectID()
+	window.CreatedAt = time.Now()
+
+	_, err := r.db.Collection(maintenanceWindowsCollection).InsertOne(ctx, window)
+	return dbErr(err)
+}
+
+// FindOverlapping returns the windows overlapping the period from start to
+// end, earliest first.
+func (r *MaintenanceRepository) FindOverlapping(ctx context.Context, start, end time.Time) ([]models.MaintenanceWindow, error) {
+	return r.find(ctx, bson.M{
+		"startTime": bson.M{"$lt": end},
+		"endTime":   bson.M{"$gt": start},
+	})
+}
+
+// FindUpcoming returns the windows that haven't ended, earliest first.
+func (r *MaintenanceRepository) FindUpcoming(ctx context.Context) ([]models.MaintenanceWindow, error) {
+	return r.find(ctx, bson.M{"endTime": bson.M{"$gt": time.Now()}})
+}
+
+// find returns the windows matching filter, earliest first.
+func (r *MaintenanceRepository) find(ctx context.Context, filter bson.M) ([]models.MaintenanceWindow, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}})
+	cursor, err := r.db.Collection(maintenanceWindowsCollection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	windows := []models.MaintenanceWindow{}
+	if err := cursor.All(ctx, &windows); err != nil {
+		return nil, dbErr(err)
+	}
+	return windows, nil
+}
+
+// Delete removes a maintenance window.
+func (r *MaintenanceRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrMaintenanceWindowNotFound
+	}
+
+	result, err := r.db.Collection(maintenanceWindowsCollection).DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrMaintenanceWindowNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setNameSnapshot sets nameField to name on the documents of collection whose
+// idField is id and whose snapshot differs (or is missing). It returns how
+// many documents changed.
+func setNameSnapshot(ctx context.Context, db *database.MongoDB, collection, idField string, id primitive.ObjectID, nameField, name string) (int64, error) {
+	result, err := db.Collection(collection).UpdateMany(ctx,
+		bson.M{idField: id, nameField: bson.M{"$ne": name}},
+		bson.M{"$set": bson.M{nameField: name}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// SetPresenterName updates the presenter name snapshot on a presenter's batches.
+func (r *BatchRepository) SetPresenterName(ctx context.Context, presenterID primitive.ObjectID, name string) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	n, err := setNameSnapshot(ctx, r.db, batchesCollection, "presenterId", presenterID, "presenterName", name)
+	if n > 0 {
+		r.ClearCache()
+		r.fireWrite()
+	}
+	return n, dbErr(err)
+}
+
+// SetBatchName updates the batch name snapshot on a batch's classes.
+func (r *ScheduleRepository) SetBatchName(ctx context.Context, batchID primitive.ObjectID, name string) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	n, err := setNameSnapshot(ctx, r.db, schedulesCollection, "batchId", batchID, "batchName", name)
+	if n > 0 {
+		r.ClearCache()
+		r.fireWrite()
+	}
+	return n, dbErr(err)
+}
+
+// SetPresenterName updates the presenter name snapshot on a presenter's classes.
+func (r *ScheduleRepository) SetPresenterName(ctx context.Context, presenterID primitive.ObjectID, name string) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	n, err := setNameSnapshot(ctx, r.db, schedulesCollection, "presenterId", presenterID, "presenterName", name)
+	if n > 0 {
+		r.ClearCache()
+		r.fireWrite()
+	}
+	return n, dbErr(err)
+}
+
+// SetBatchName updates the batch name snapshot on a batch's recordings.
+func (r *RecordingRepository) SetBatchName(ctx context.Context, batchID primitive.ObjectID, name string) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	n, err := setNameSnapshot(ctx, r.db, recordingsCollection, "batchId", batchID, "batchName", name)
+	if n > 0 {
+		r.ClearCache()
+	}
+	return n, dbErr(err)
+}
+
+// SetPresenterName updates the presenter name snapshot on a presenter's recordings.
+func (r *RecordingRepository) SetPresenterName(ctx context.Context, presenterID primitive.ObjectID, name string) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	n, err := setNameSnapshot(ctx, r.db, recordingsCollection, "presenterId", presenterID, "presenterName", name)
+	if n > 0 {
+		r.ClearCache()
+	}
+	return n, dbErr(err)
+}
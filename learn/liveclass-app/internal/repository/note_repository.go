@@ -5,7 +5,7 @@ import (
 	"context"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -14,20 +14,22 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Note cache key prefix
-const noteByIDPrefix = "note:id:"
+// noteByID is the cache namespace of notes.
+const noteByID cacheNamespace[*models.Note] = "note:id:"
 
 // NoteRepository handles note database operations with caching.
 type NoteRepository struct {
+	db         *database.MongoDB
 	collection *mongo.Collection
-	cache      *cache.Cache[*models.Note]
+	notes      *cachedRepo[*models.Note]
 }
 
 // NewNoteRepository creates a new note repository.
-func NewNoteRepository(db *mongo.Database) *NoteRepository {
+func NewNoteRepository(db *database.MongoDB) *NoteRepository {
 	return &NoteRepository{
+		db:         db,
 		collection: db.Collection("notes"),
-		cache:      cache.New[*models.Note](2*time.Minute, 1*time.Minute),
+		notes:      newCachedRepo[*models.Note](2*time.Minute, 1*time.Minute, mongo.ErrNoDocuments),
 	}
 }
 
@@ -47,6 +49,36 @@ func (r *NoteRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "batchId", Value: 1}, {Key: "createdAt", Value: -1}},
 		},
+		// Notes waiting to be published after a class or at a time
+		{
+			Keys:    bson.D{{Key: "publishScheduleId", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"unpublished": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "publishAt", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"unpublished": true}),
+		},
+		// Handouts of a class
+		{
+			Keys:    bson.D{{Key: "handoutScheduleId", Value: 1}, {Key: "createdAt", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"handoutScheduleId": bson.M{"$exists": true}}),
+		},
+		// Library items linked to other batches
+		{
+			Keys: bson.D{{Key: "linkedBatchIds", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "library", Value: 1}, {Key: "department", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		// Images waiting for their variants
+		{
+			Keys: bson.D{{Key: "fileType", Value: 1}, {Key: "variantsAt", Value: 1}},
+		},
+		// Documents waiting for their PDF copy
+		{
+			Keys: bson.D{{Key: "mimeType", Value: 1}, {Key: "pdf.status", Value: 1}},
+		},
 	}
 
 	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
@@ -55,95 +87,103 @@ func (r *NoteRepository) CreateIndexes(ctx context.Context) error {
 
 // Create inserts a new note into the database.
 func (r *NoteRepository) Create(ctx context.Context, note *models.Note) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	note.CreatedAt = time.Now()
 	note.UpdatedAt = time.Now()
 
 	result, err := r.collection.InsertOne(ctx, note)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 
 	note.ID = result.InsertedID.(primitive.ObjectID)
 
 	// Cache the new note
-	r.cache.Set(noteByIDPrefix+note.ID.Hex(), note)
+	r.notes.set(noteByID.key(note.ID.Hex()), note)
 
 	return nil
 }
 
 // FindByID retrieves a note by its ID with caching.
 func (r *NoteRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Note, error) {
-	cacheKey := noteByIDPrefix + id.Hex()
-
-	// Try cache first
-	if note, found := r.cache.Get(cacheKey); found {
-		return note, nil
-	}
-
-	var note models.Note
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&note)
-	if err != nil {
-		return nil, err
-	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, &note)
-
-	return &note, nil
+	return r.notes.load(ctx, noteByID.key(id.Hex()), func(ctx context.Context) (*models.Note, error) {
+		ctx, cancel := r.db.ReadContext(ctx)
+		defer cancel()
+
+		var note models.Note
+		if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&note); err != nil {
+			return nil, dbErr(err)
+		}
+		return &note, nil
+	})
 }
 
 // FindAll retrieves all notes (for admin).
 func (r *NoteRepository) FindAll(ctx context.Context) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	opts := options.Find().
 		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
 		SetBatchSize(100)
 
 	cursor, err := r.collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var notes []*models.Note
 	if err = cursor.All(ctx, &notes); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual notes
 	for _, note := range notes {
-		r.cache.Set(noteByIDPrefix+note.ID.Hex(), note)
+		r.notes.set(noteByID.key(note.ID.Hex()), note)
 	}
 
 	return notes, nil
 }
 
-// FindByBatch retrieves all notes for a specific batch.
+// FindByBatch retrieves all notes for a specific batch, including library
+// items linked to it.
 func (r *NoteRepository) FindByBatch(ctx context.Context, batchID primitive.ObjectID) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	opts := options.Find().
 		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
 		SetBatchSize(100)
 
-	cursor, err := r.collection.Find(ctx, bson.M{"batchId": batchID}, opts)
+	filter := bson.M{"$or": []bson.M{{"batchId": batchID}, {"linkedBatchIds": batchID}}}
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var notes []*models.Note
 	if err = cursor.All(ctx, &notes); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual notes
 	for _, note := range notes {
-		r.cache.Set(noteByIDPrefix+note.ID.Hex(), note)
+		r.notes.set(noteByID.key(note.ID.Hex()), note)
 	}
 
 	return notes, nil
 }
 
-// FindByBatches retrieves all notes for multiple batches (for students in multiple batches).
+// FindByBatches retrieves all notes for multiple batches (for students in multiple batches),
+// including library items linked to them.
 func (r *NoteRepository) FindByBatches(ctx context.Context, batchIDs []primitive.ObjectID) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	if len(batchIDs) == 0 {
 		return []*models.Note{}, nil
 	}
@@ -152,20 +192,24 @@ func (r *NoteRepository) FindByBatches(ctx context.Context, batchIDs []primitive
 		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
 		SetBatchSize(100)
 
-	cursor, err := r.collection.Find(ctx, bson.M{"batchId": bson.M{"$in": batchIDs}}, opts)
+	filter := bson.M{"$or": []bson.M{
+		{"batchId": bson.M{"$in": batchIDs}},
+		{"linkedBatchIds": bson.M{"$in": batchIDs}},
+	}}
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var notes []*models.Note
 	if err = cursor.All(ctx, &notes); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual notes
 	for _, note := range notes {
-		r.cache.Set(noteByIDPrefix+note.ID.Hex(), note)
+		r.notes.set(noteByID.key(note.ID.Hex()), note)
 	}
 
 	return notes, nil
@@ -173,24 +217,27 @@ func (r *NoteRepository) FindByBatches(ctx context.Context, batchIDs []primitive
 
 // FindByUploader retrieves all notes uploaded by a specific user.
 func (r *NoteRepository) FindByUploader(ctx context.Context, uploaderID primitive.ObjectID) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	opts := options.Find().
 		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
 		SetBatchSize(100)
 
 	cursor, err := r.collection.Find(ctx, bson.M{"uploaderId": uploaderID}, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var notes []*models.Note
 	if err = cursor.All(ctx, &notes); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual notes
 	for _, note := range notes {
-		r.cache.Set(noteByIDPrefix+note.ID.Hex(), note)
+		r.notes.set(noteByID.key(note.ID.Hex()), note)
 	}
 
 	return notes, nil
@@ -198,40 +245,349 @@ func (r *NoteRepository) FindByUploader(ctx context.Context, uploaderID primitiv
 
 // Update updates an existing note and invalidates cache.
 func (r *NoteRepository) Update(ctx context.Context, note *models.Note) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	note.UpdatedAt = time.Now()
 
+	set := bson.M{
+		"title":       note.Title,
+		"description": note.Description,
+		"updatedAt":   note.UpdatedAt,
+	}
+	update := bson.M{"$set": set}
+	if len(note.GroupIDs) > 0 {
+		set["groupIds"] = note.GroupIDs
+	} else {
+		update["$unset"] = bson.M{"groupIds": ""}
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": note.ID}, update)
+	if err == nil {
+		// Update cache
+		r.notes.set(noteByID.key(note.ID.Hex()), note)
+	}
+	return dbErr(err)
+}
+
+// SetHidden hides a note from everyone but admins (or restores it) and invalidates cache.
+func (r *NoteRepository) SetHidden(ctx context.Context, id primitive.ObjectID, hidden bool) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	update := bson.M{
 		"$set": bson.M{
-			"title":       note.Title,
-			"description": note.Description,
-			"updatedAt":   note.UpdatedAt,
+			"hidden":    hidden,
+			"updatedAt": time.Now(),
 		},
 	}
 
-	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": note.ID}, update)
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
 	if err == nil {
-		// Update cache
-		r.cache.Set(noteByIDPrefix+note.ID.Hex(), note)
+		r.notes.delete(noteByID.key(id.Hex()))
 	}
-	return err
+	return dbErr(err)
+}
+
+// FindLibrary retrieves a presenter's personal library items and the
+// department library items, of one department if department isn't empty.
+func (r *NoteRepository) FindLibrary(ctx context.Context, uploaderID primitive.ObjectID, department string) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	shared := bson.M{"library": models.NoteLibraryDepartment}
+	if department != "" {
+		shared["department"] = department
+	}
+	filter := bson.M{"$or": []bson.M{
+		{"library": models.NoteLibraryPersonal, "uploaderId": uploaderID},
+		shared,
+	}}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetBatchSize(100)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var notes []*models.Note
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, dbErr(err)
+	}
+
+	// Cache individual notes
+	for _, note := range notes {
+		r.notes.set(noteByID.key(note.ID.Hex()), note)
+	}
+
+	return notes, nil
+}
+
+// SetLibrary adds a note to a library, or removes it with an empty library,
+// and invalidates cache. Removing a note from its library keeps its links.
+func (r *NoteRepository) SetLibrary(ctx context.Context, id primitive.ObjectID, library models.NoteLibrary, department string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	set := bson.M{"updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if library == "" {
+		update["$unset"] = bson.M{"library": "", "department": ""}
+	} else {
+		set["library"] = library
+		set["department"] = department
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err == nil {
+		r.notes.delete(noteByID.key(id.Hex()))
+	}
+	return dbErr(err)
+}
+
+// LinkBatch attaches a note to another batch and invalidates cache.
+func (r *NoteRepository) LinkBatch(ctx context.Context, id, batchID primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	update := bson.M{
+		"$addToSet": bson.M{"linkedBatchIds": batchID},
+		"$set":      bson.M{"updatedAt": time.Now()},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err == nil {
+		r.notes.delete(noteByID.key(id.Hex()))
+	}
+	return dbErr(err)
+}
+
+// UnlinkBatch detaches a note from a linked batch and invalidates cache.
+func (r *NoteRepository) UnlinkBatch(ctx context.Context, id, batchID primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	update := bson.M{
+		"$pull": bson.M{"linkedBatchIds": batchID},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err == nil {
+		r.notes.delete(noteByID.key(id.Hex()))
+	}
+	return dbErr(err)
+}
+
+// FindUnpublishedForClass retrieves the notes to publish when a class ends.
+func (r *NoteRepository) FindUnpublishedForClass(ctx context.Context, scheduleID primitive.ObjectID) ([]*models.Note, error) {
+	return r.findUnpublished(ctx, bson.M{"unpublished": true, "publishScheduleId": scheduleID})
+}
+
+// FindHandouts retrieves the notes handed out in a class, oldest first.
+func (r *NoteRepository) FindHandouts(ctx context.Context, scheduleID primitive.ObjectID) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"handoutScheduleId": scheduleID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	notes := []*models.Note{}
+	if err := cursor.All(ctx, &notes); err != nil {
+		return nil, dbErr(err)
+	}
+	return notes, nil
+}
+
+// FindDueForPublish retrieves the notes whose publish time has passed.
+func (r *NoteRepository) FindDueForPublish(ctx context.Context, now time.Time) ([]*models.Note, error) {
+	return r.findUnpublished(ctx, bson.M{"unpublished": true, "publishAt": bson.M{"$lte": now}})
+}
+
+// findUnpublished retrieves unpublished notes matching filter.
+func (r *NoteRepository) findUnpublished(ctx context.Context, filter bson.M) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var notes []*models.Note
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, dbErr(err)
+	}
+	return notes, nil
+}
+
+// Publish makes an unpublished note visible to students and invalidates
+// cache. It reports false if the note was already published, so concurrent
+// publishers notify only once.
+func (r *NoteRepository) Publish(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	update := bson.M{
+		"$set":   bson.M{"publishedAt": now, "updatedAt": now},
+		"$unset": bson.M{"unpublished": ""},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "unpublished": true}, update)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.notes.delete(noteByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FindImagesWithoutVariants retrieves up to limit image notes whose variants
+// haven't been generated yet.
+func (r *NoteRepository) FindImagesWithoutVariants(ctx context.Context, limit int64) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"fileType":   models.NoteTypeImage,
+		"variantsAt": bson.M{"$exists": false},
+	}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var notes []*models.Note
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, dbErr(err)
+	}
+	return notes, nil
+}
+
+// ClaimVariants marks a note's variants as being generated. It reports false
+// if they were already claimed, so only one instance generates them.
+func (r *NoteRepository) ClaimVariants(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "variantsAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"variantsAt": time.Now()}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.notes.delete(noteByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// SetVariants stores the generated variants of a note and invalidates cache.
+func (r *NoteRepository) SetVariants(ctx context.Context, id primitive.ObjectID, variants []models.ImageVariant) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"variants": variants}})
+	if err == nil {
+		r.notes.delete(noteByID.key(id.Hex()))
+	}
+	return dbErr(err)
+}
+
+// FindUnconverted retrieves up to limit documents without a PDF copy, and
+// whose conversion was claimed before staleBefore without finishing.
+func (r *NoteRepository) FindUnconverted(ctx context.Context, staleBefore time.Time, limit int64) ([]*models.Note, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"mimeType": bson.M{"$in": models.ConvertibleMimeTypes},
+		"$or":      unconvertedFilter(staleBefore),
+	}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var notes []*models.Note
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, dbErr(err)
+	}
+	return notes, nil
+}
+
+// ClaimPDF marks a note's PDF copy as being converted. It reports false if
+// it was already claimed, unless that claim is from before staleBefore, so
+// only one instance converts it and an interrupted conversion is retried.
+func (r *NoteRepository) ClaimPDF(ctx context.Context, id primitive.ObjectID, staleBefore time.Time) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "$or": unconvertedFilter(staleBefore)},
+		bson.M{"$set": bson.M{"pdf": models.NotePDF{Status: models.NotePDFConverting, ClaimedAt: time.Now()}}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.notes.delete(noteByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// unconvertedFilter matches notes without a PDF copy or a conversion in
+// progress since staleBefore.
+func unconvertedFilter(staleBefore time.Time) bson.A {
+	return bson.A{
+		bson.M{"pdf.status": bson.M{"$exists": false}},
+		bson.M{"pdf.status": models.NotePDFConverting, "pdf.claimedAt": bson.M{"$lt": staleBefore}},
+	}
+}
+
+// SetPDF stores the outcome of a note's PDF conversion and invalidates cache.
+func (r *NoteRepository) SetPDF(ctx context.Context, id primitive.ObjectID, pdf models.NotePDF) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"pdf": pdf}})
+	if err == nil {
+		r.notes.delete(noteByID.key(id.Hex()))
+	}
+	return dbErr(err)
 }
 
 // Delete removes a note by its ID and invalidates cache.
 func (r *NoteRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	if err == nil {
 		// Invalidate cache
-		r.cache.Delete(noteByIDPrefix + id.Hex())
+		r.notes.delete(noteByID.key(id.Hex()))
 	}
-	return err
+	return dbErr(err)
 }
 
 // CountByBatch returns the number of notes in a batch.
 func (r *NoteRepository) CountByBatch(ctx context.Context, batchID primitive.ObjectID) (int64, error) {
-	return r.collection.CountDocuments(ctx, bson.M{"batchId": batchID})
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"batchId": batchID})
+	return count, dbErr(err)
 }
 
 // ClearCache clears all cached notes.
 func (r *NoteRepository) ClearCache() {
-	r.cache.Clear()
+	r.notes.clear()
 }
@@ -0,0 +1,209 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const notificationsCollection = "notifications"
+
+// notificationRetention is how long notifications are kept before MongoDB expires them.
+const notificationRetention = 90 * 24 * time.Hour
+
+// NotificationRepository handles in-app notification persistence.
+type NotificationRepository struct {
+	db *database.MongoDB
+}
+
+// NewNotificationRepository creates a new NotificationRepository.
+func NewNotificationRepository(db *database.MongoDB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the notifications collection.
+func (r *NotificationRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(notificationsCollection)
+
+	indexes := []mongo.IndexModel{
+		// A user's notifications, newest first
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		// Unread counts
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "readAt", Value: 1}},
+		},
+		// Notifications held back by quiet hours, by when they're due
+		{
+			Keys:    bson.D{{Key: "deferredUntil", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		// Expire old notifications
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(notificationRetention.Seconds())),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new notification.
+func (r *NotificationRepository) Create(ctx context.Context, n *models.Notification) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	n.ID = primitive.NewObjectID()
+	n.CreatedAt = time.Now()
+
+	_, err := collection.InsertOne(ctx, n)
+	return dbErr(err)
+}
+
+// FindByUser returns a user's delivered notifications, newest first.
+func (r *NotificationRepository) FindByUser(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit int64) ([]models.Notification, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	filter := bson.M{"userId": userID, "deferredUntil": bson.M{"$exists": false}}
+	if unreadOnly {
+		filter["readAt"] = bson.M{"$exists": false}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, dbErr(err)
+	}
+	return notifications, nil
+}
+
+// MarkRead marks the given notifications of a user as read, or all of them if ids is empty.
+// It returns the number of notifications that were updated.
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	filter := bson.M{
+		"userId":        userID,
+		"readAt":        bson.M{"$exists": false},
+		"deferredUntil": bson.M{"$exists": false},
+	}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"readAt": time.Now()}})
+	if err != nil {
+		return 0, dbErr(err)
+	}
+	return result.ModifiedCount, nil
+}
+
+// CountUnread returns the number of unread delivered notifications of a user.
+func (r *NotificationRepository) CountUnread(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"userId":        userID,
+		"readAt":        bson.M{"$exists": false},
+		"deferredUntil": bson.M{"$exists": false},
+	})
+	return count, dbErr(err)
+}
+
+// FindDeferredDue returns up to limit held-back notifications whose quiet
+// hours ended by now, oldest first.
+func (r *NotificationRepository) FindDeferredDue(ctx context.Context, now time.Time, limit int64) ([]models.Notification, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "deferredUntil", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, bson.M{"deferredUntil": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, dbErr(err)
+	}
+	return notifications, nil
+}
+
+// Release marks a held-back notification delivered. It returns false if it
+// was already released, e.g. by another instance.
+func (r *NotificationRepository) Release(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "deferredUntil": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deferredUntil": "", "email": ""}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// RescheduleDeferred moves the release of a user's held-back notifications
+// to until, after their quiet hours change.
+func (r *NotificationRepository) RescheduleDeferred(ctx context.Context, userID primitive.ObjectID, until time.Time) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"userId": userID, "deferredUntil": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{"deferredUntil": until}},
+	)
+	return dbErr(err)
+}
+
+// DeleteByUser removes all notifications of a user.
+func (r *NotificationRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(notificationsCollection)
+
+	_, err := collection.DeleteMany(ctx, bson.M{"userId": userID})
+	return dbErr(err)
+}
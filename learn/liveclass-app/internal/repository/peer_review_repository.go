@@ -0,0 +1,200 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	peerReviewRoundsCollection = "peer_review_rounds"
+	peerReviewsCollection      = "peer_reviews"
+)
+
+// Peer review errors
+var (
+	ErrPeerReviewNotFound = errors.New("peer review not found")
+	ErrPeerReviewExists   = errors.New("class already has a peer review")
+	ErrPeerReviewClosed   = errors.New("peer review is closed")
+)
+
+// PeerReviewRepository handles the peer review of classes' hand-ins.
+type PeerReviewRepository struct {
+	db *database.MongoDB
+}
+
+// NewPeerReviewRepository creates a new PeerReviewRepository.
+func NewPeerReviewRepository(db *database.MongoDB) *PeerReviewRepository {
+	return &PeerReviewRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the peer review collections.
+func (r *PeerReviewRepository) CreateIndexes(ctx context.Context) error {
+	// One round per class
+	rounds := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.db.Collection(peerReviewRoundsCollection).Indexes().CreateMany(ctx, rounds); err != nil {
+		return err
+	}
+
+	reviews := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "roundId", Value: 1}, {Key: "handInId", Value: 1}}},
+		{Keys: bson.D{{Key: "roundId", Value: 1}, {Key: "reviewerId", Value: 1}}},
+	}
+	_, err := r.db.Collection(peerReviewsCollection).Indexes().CreateMany(ctx, reviews)
+	return err
+}
+
+// CreateRound stores a new round with the reviews to collect, failing with
+// ErrPeerReviewExists if the class already has one.
+func (r *PeerReviewRepository) CreateRound(ctx context.Context, round *models.PeerReviewRound, reviews []models.PeerReview) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	round.ID = primitive.NewObjectID()
+	round.Status = models.PeerReviewOpen
+	round.CreatedAt = now
+
+	_, err := r.db.Collection(peerReviewRoundsCollection).InsertOne(ctx, round)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPeerReviewExists
+	}
+	if err != nil || len(reviews) == 0 {
+		return dbErr(err)
+	}
+
+	docs := make([]interface{}, len(reviews))
+	for i := range reviews {
+		reviews[i].ID = primitive.NewObjectID()
+		reviews[i].RoundID = round.ID
+		reviews[i].CreatedAt = now
+		docs[i] = reviews[i]
+	}
+	if _, err := r.db.Collection(peerReviewsCollection).InsertMany(ctx, docs); err != nil {
+		// Without its reviews the round is useless; drop it so it can be started again
+		r.db.Collection(peerReviewRoundsCollection).DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": round.ID})
+		return dbErr(err)
+	}
+	return nil
+}
+
+// FindRound returns the peer review of a class.
+func (r *PeerReviewRepository) FindRound(ctx context.Context, scheduleID primitive.ObjectID) (*models.PeerReviewRound, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	round := &models.PeerReviewRound{}
+	err := r.db.Collection(peerReviewRoundsCollection).FindOne(ctx, bson.M{"scheduleId": scheduleID}).Decode(round)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPeerReviewNotFound
+	}
+	return round, dbErr(err)
+}
+
+// FindReviews returns the reviews of a round.
+func (r *PeerReviewRepository) FindReviews(ctx context.Context, roundID primitive.ObjectID) ([]models.PeerReview, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "handInId", Value: 1}, {Key: "createdAt", Value: 1}})
+	cursor, err := r.db.Collection(peerReviewsCollection).Find(ctx, bson.M{"roundId": roundID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.PeerReview{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, dbErr(err)
+	}
+	return reviews, nil
+}
+
+// FindReview returns a review of a round.
+func (r *PeerReviewRepository) FindReview(ctx context.Context, roundID primitive.ObjectID, id string) (*models.PeerReview, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPeerReviewNotFound
+	}
+
+	review := &models.PeerReview{}
+	err = r.db.Collection(peerReviewsCollection).FindOne(ctx, bson.M{"_id": objectID, "roundId": roundID}).Decode(review)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPeerReviewNotFound
+	}
+	return review, dbErr(err)
+}
+
+// Submit saves a reviewer's scores and comment, replacing any they
+// submitted before.
+func (r *PeerReviewRepository) Submit(ctx context.Context, roundID, id, reviewerID primitive.ObjectID, scores []int, comment string) (*models.PeerReview, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"scores":      scores,
+		"comment":     comment,
+		"submittedAt": time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	review := &models.PeerReview{}
+	err := r.db.Collection(peerReviewsCollection).FindOneAndUpdate(ctx, bson.M{"_id": id, "roundId": roundID, "reviewerId": reviewerID}, update, opts).Decode(review)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPeerReviewNotFound
+	}
+	return review, dbErr(err)
+}
+
+// Moderate leaves a review out of the scores or takes it back in.
+func (r *PeerReviewRepository) Moderate(ctx context.Context, roundID, id primitive.ObjectID, excluded bool, moderator primitive.ObjectID) (*models.PeerReview, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"excluded":    excluded,
+		"moderatedBy": moderator,
+		"moderatedAt": time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	review := &models.PeerReview{}
+	err := r.db.Collection(peerReviewsCollection).FindOneAndUpdate(ctx, bson.M{"_id": id, "roundId": roundID}, update, opts).Decode(review)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPeerReviewNotFound
+	}
+	return review, dbErr(err)
+}
+
+// Release makes the scores of an open round visible to students, failing
+// with ErrPeerReviewClosed if it was already released.
+func (r *PeerReviewRepository) Release(ctx context.Context, roundID primitive.ObjectID) (*models.PeerReviewRound, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":     models.PeerReviewReleased,
+		"releasedAt": time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	round := &models.PeerReviewRound{}
+	err := r.db.Collection(peerReviewRoundsCollection).FindOneAndUpdate(ctx, bson.M{"_id": roundID, "status": models.PeerReviewOpen}, update, opts).Decode(round)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPeerReviewClosed
+	}
+	return round, dbErr(err)
+}
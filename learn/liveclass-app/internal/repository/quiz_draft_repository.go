@@ -0,0 +1,108 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const quizDraftsCollection = "quiz_drafts"
+
+// Quiz draft errors
+var (
+	ErrQuizDraftNotFound = errors.New("quiz draft not found")
+)
+
+// QuizDraftRepository handles quiz drafts generated from class transcripts.
+type QuizDraftRepository struct {
+	db *database.MongoDB
+}
+
+// NewQuizDraftRepository creates a new QuizDraftRepository.
+func NewQuizDraftRepository(db *database.MongoDB) *QuizDraftRepository {
+	return &QuizDraftRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the quiz drafts collection.
+func (r *QuizDraftRepository) CreateIndexes(ctx context.Context) error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+	_, err := r.db.Collection(quizDraftsCollection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new quiz draft.
+func (r *QuizDraftRepository) Create(ctx context.Context, draft *models.QuizDraft) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	draft.ID = primitive.NewObjectID()
+	draft.CreatedAt = time.Now()
+
+	_, err := r.db.Collection(quizDraftsCollection).InsertOne(ctx, draft)
+	return dbErr(err)
+}
+
+// FindBySchedule returns the quiz drafts of a class, newest first.
+func (r *QuizDraftRepository) FindBySchedule(ctx context.Context, scheduleID primitive.ObjectID) ([]models.QuizDraft, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.db.Collection(quizDraftsCollection).Find(ctx, bson.M{"scheduleId": scheduleID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	drafts := []models.QuizDraft{}
+	if err := cursor.All(ctx, &drafts); err != nil {
+		return nil, dbErr(err)
+	}
+	return drafts, nil
+}
+
+// Review saves the presenter's edits to a draft of a class and its new
+// status. Nil questions leave the draft's questions as they are.
+func (r *QuizDraftRepository) Review(ctx context.Context, scheduleID primitive.ObjectID, id string, questions []models.QuizQuestion, status models.QuizDraftStatus, reviewer primitive.ObjectID) (*models.QuizDraft, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrQuizDraftNotFound
+	}
+
+	set := bson.M{
+		"status":     status,
+		"reviewedBy": reviewer,
+		"reviewedAt": time.Now(),
+	}
+	if questions != nil {
+		set["questions"] = questions
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var draft models.QuizDraft
+	err = r.db.Collection(quizDraftsCollection).FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID, "scheduleId": scheduleID},
+		bson.M{"$set": set},
+		opts,
+	).Decode(&draft)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrQuizDraftNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return &draft, nil
+}
@@ -0,0 +1,102 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const recordingConsentCollection = "recording_consents"
+
+// ErrConsentNotFound is returned when a class has no recording consent request.
+var ErrConsentNotFound = errors.New("recording consent not found")
+
+// RecordingConsentRepository handles recording consent requests and answers.
+type RecordingConsentRepository struct {
+	db *database.MongoDB
+}
+
+// NewRecordingConsentRepository creates a new RecordingConsentRepository.
+func NewRecordingConsentRepository(db *database.MongoDB) *RecordingConsentRepository {
+	return &RecordingConsentRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the recording consent collection.
+func (r *RecordingConsentRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(recordingConsentCollection)
+
+	indexes := []mongo.IndexModel{
+		// Latest request of a class
+		{Keys: bson.D{{Key: "scheduleId", Value: 1}, {Key: "requestedAt", Value: -1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new consent request.
+func (r *RecordingConsentRepository) Create(ctx context.Context, consent *models.RecordingConsent) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingConsentCollection)
+
+	consent.ID = primitive.NewObjectID()
+	if consent.RequestedAt.IsZero() {
+		consent.RequestedAt = time.Now()
+	}
+	if consent.Responses == nil {
+		consent.Responses = []models.ConsentResponse{}
+	}
+
+	_, err := collection.InsertOne(ctx, consent)
+	return dbErr(err)
+}
+
+// SetResponse records a participant's answer, replacing any earlier answer
+// from the same participant.
+func (r *RecordingConsentRepository) SetResponse(ctx context.Context, id primitive.ObjectID, resp models.ConsentResponse) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingConsentCollection)
+
+	// Two updates, as one update can't both pull and push the same array
+	filter := bson.M{"_id": id}
+	if _, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$pull": bson.M{"responses": bson.M{"participantId": resp.ParticipantID}},
+	}); err != nil {
+		return dbErr(err)
+	}
+	_, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$push": bson.M{"responses": resp},
+	})
+	return dbErr(err)
+}
+
+// LatestForSchedule returns the most recent consent request of a class.
+func (r *RecordingConsentRepository) LatestForSchedule(ctx context.Context, scheduleID primitive.ObjectID) (*models.RecordingConsent, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingConsentCollection)
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "requestedAt", Value: -1}})
+	var consent models.RecordingConsent
+	err := collection.FindOne(ctx, bson.M{"scheduleId": scheduleID}, opts).Decode(&consent)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrConsentNotFound
+		}
+		return nil, dbErr(err)
+	}
+	return &consent, nil
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
 	"github.com/jinshatcp/brightline-academy/learn/internal/database"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,10 +16,10 @@ import (
 
 const recordingsCollection = "recordings"
 
-// Recording cache key prefixes
+// Recording cache namespaces
 const (
-	recordingByIDPrefix       = "recording:id:"
-	recordingBySchedulePrefix = "recording:schedule:"
+	recordingByID       cacheNamespace[*models.Recording] = "recording:id:"
+	recordingBySchedule cacheNamespace[*models.Recording] = "recording:schedule:"
 )
 
 // Recording errors
@@ -28,17 +27,25 @@ var (
 	ErrRecordingNotFound = errors.New("recording not found")
 )
 
+// listedStatuses are shown in batch recording lists; archived recordings stay
+// listed so students can request them back.
+var listedStatuses = []models.RecordingStatus{
+	models.RecordingStatusReady,
+	models.RecordingStatusArchived,
+	models.RecordingStatusRestoring,
+}
+
 // RecordingRepository handles recording data operations with caching.
 type RecordingRepository struct {
-	db    *database.MongoDB
-	cache *cache.Cache[*models.Recording]
+	db         *database.MongoDB
+	recordings *cachedRepo[*models.Recording]
 }
 
 // NewRecordingRepository creates a new RecordingRepository.
 func NewRecordingRepository(db *database.MongoDB) *RecordingRepository {
 	return &RecordingRepository{
-		db:    db,
-		cache: cache.New[*models.Recording](2*time.Minute, 1*time.Minute),
+		db:         db,
+		recordings: newCachedRepo[*models.Recording](2*time.Minute, 1*time.Minute, ErrRecordingNotFound),
 	}
 }
 
@@ -62,6 +69,9 @@ func (r *RecordingRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "recordedAt", Value: -1}},
 		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: 1}},
+		},
 		// Compound index for common query
 		{
 			Keys: bson.D{{Key: "batchId", Value: 1}, {Key: "status", Value: 1}, {Key: "recordedAt", Value: -1}},
@@ -74,6 +84,9 @@ func (r *RecordingRepository) CreateIndexes(ctx context.Context) error {
 
 // Create creates a new recording.
 func (r *RecordingRepository) Create(ctx context.Context, recording *models.Recording) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(recordingsCollection)
 
 	recording.ID = primitive.NewObjectID()
@@ -82,86 +95,71 @@ func (r *RecordingRepository) Create(ctx context.Context, recording *models.Reco
 
 	_, err := collection.InsertOne(ctx, recording)
 	if err == nil {
-		r.cache.Set(recordingByIDPrefix+recording.ID.Hex(), recording)
+		r.recordings.set(recordingByID.key(recording.ID.Hex()), recording)
+		r.recordings.delete(recordingBySchedule.key(recording.ScheduleID.Hex()))
 	}
-	return err
+	return dbErr(err)
 }
 
 // FindByID finds a recording by ID with caching.
 func (r *RecordingRepository) FindByID(ctx context.Context, id string) (*models.Recording, error) {
-	cacheKey := recordingByIDPrefix + id
-
-	// Try cache first
-	if recording, found := r.cache.Get(cacheKey); found {
-		return recording, nil
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrRecordingNotFound
 	}
 
-	collection := r.db.Collection(recordingsCollection)
-
-	var recording models.Recording
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&recording)
-	if err == mongo.ErrNoDocuments {
-		return nil, ErrRecordingNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, &recording)
-
-	return &recording, nil
+	return r.recordings.load(ctx, recordingByID.key(id), func(ctx context.Context) (*models.Recording, error) {
+		return r.findOne(ctx, bson.M{"_id": objectID})
+	})
 }
 
 // FindBySchedule finds a recording by schedule ID with caching.
 func (r *RecordingRepository) FindBySchedule(ctx context.Context, scheduleID string) (*models.Recording, error) {
-	cacheKey := recordingBySchedulePrefix + scheduleID
-
-	// Try cache first
-	if recording, found := r.cache.Get(cacheKey); found {
-		return recording, nil
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(scheduleID)
 	if err != nil {
 		return nil, ErrRecordingNotFound
 	}
 
-	collection := r.db.Collection(recordingsCollection)
+	return r.recordings.load(ctx, recordingBySchedule.key(scheduleID), func(ctx context.Context) (*models.Recording, error) {
+		recording, err := r.findOne(ctx, bson.M{"scheduleId": objectID})
+		if err == nil {
+			r.recordings.set(recordingByID.key(recording.ID.Hex()), recording)
+		}
+		return recording, err
+	})
+}
+
+// findOne returns the recording matching filter from the database.
+func (r *RecordingRepository) findOne(ctx context.Context, filter bson.M) (*models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
 
 	var recording models.Recording
-	err = collection.FindOne(ctx, bson.M{"scheduleId": objectID}).Decode(&recording)
+	err := r.db.Collection(recordingsCollection).FindOne(ctx, filter).Decode(&recording)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrRecordingNotFound
 	}
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, &recording)
-	r.cache.Set(recordingByIDPrefix+recording.ID.Hex(), &recording)
-
 	return &recording, nil
 }
 
 // FindByBatch returns recordings for a specific batch.
 func (r *RecordingRepository) FindByBatch(ctx context.Context, batchID string) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(batchID)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	collection := r.db.Collection(recordingsCollection)
 
 	filter := bson.M{
 		"batchId": objectID,
-		"status":  models.RecordingStatusReady,
+		"status":  bson.M{"$in": listedStatuses},
 	}
 
 	opts := options.Find().
@@ -170,18 +168,18 @@ func (r *RecordingRepository) FindByBatch(ctx context.Context, batchID string) (
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var recordings []models.Recording
 	if err := cursor.All(ctx, &recordings); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual recordings
 	for i := range recordings {
-		r.cache.Set(recordingByIDPrefix+recordings[i].ID.Hex(), &recordings[i])
+		r.recordings.set(recordingByID.key(recordings[i].ID.Hex()), &recordings[i])
 	}
 
 	return recordings, nil
@@ -189,6 +187,9 @@ func (r *RecordingRepository) FindByBatch(ctx context.Context, batchID string) (
 
 // FindByBatches returns recordings for multiple batches.
 func (r *RecordingRepository) FindByBatches(ctx context.Context, batchIDs []string) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	objectIDs := make([]primitive.ObjectID, 0, len(batchIDs))
 	for _, id := range batchIDs {
 		oid, err := primitive.ObjectIDFromHex(id)
@@ -206,7 +207,7 @@ func (r *RecordingRepository) FindByBatches(ctx context.Context, batchIDs []stri
 
 	filter := bson.M{
 		"batchId": bson.M{"$in": objectIDs},
-		"status":  models.RecordingStatusReady,
+		"status":  bson.M{"$in": listedStatuses},
 	}
 
 	opts := options.Find().
@@ -215,18 +216,18 @@ func (r *RecordingRepository) FindByBatches(ctx context.Context, batchIDs []stri
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var recordings []models.Recording
 	if err := cursor.All(ctx, &recordings); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual recordings
 	for i := range recordings {
-		r.cache.Set(recordingByIDPrefix+recordings[i].ID.Hex(), &recordings[i])
+		r.recordings.set(recordingByID.key(recordings[i].ID.Hex()), &recordings[i])
 	}
 
 	return recordings, nil
@@ -234,9 +235,12 @@ func (r *RecordingRepository) FindByBatches(ctx context.Context, batchIDs []stri
 
 // FindByPresenter returns recordings by a specific presenter.
 func (r *RecordingRepository) FindByPresenter(ctx context.Context, presenterID string) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(presenterID)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	collection := r.db.Collection(recordingsCollection)
@@ -247,18 +251,18 @@ func (r *RecordingRepository) FindByPresenter(ctx context.Context, presenterID s
 
 	cursor, err := collection.Find(ctx, bson.M{"presenterId": objectID}, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var recordings []models.Recording
 	if err := cursor.All(ctx, &recordings); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual recordings
 	for i := range recordings {
-		r.cache.Set(recordingByIDPrefix+recordings[i].ID.Hex(), &recordings[i])
+		r.recordings.set(recordingByID.key(recordings[i].ID.Hex()), &recordings[i])
 	}
 
 	return recordings, nil
@@ -266,6 +270,9 @@ func (r *RecordingRepository) FindByPresenter(ctx context.Context, presenterID s
 
 // FindAll returns all recordings (for admin).
 func (r *RecordingRepository) FindAll(ctx context.Context) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(recordingsCollection)
 
 	opts := options.Find().
@@ -274,18 +281,18 @@ func (r *RecordingRepository) FindAll(ctx context.Context) ([]models.Recording,
 
 	cursor, err := collection.Find(ctx, bson.M{}, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var recordings []models.Recording
 	if err := cursor.All(ctx, &recordings); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual recordings
 	for i := range recordings {
-		r.cache.Set(recordingByIDPrefix+recordings[i].ID.Hex(), &recordings[i])
+		r.recordings.set(recordingByID.key(recordings[i].ID.Hex()), &recordings[i])
 	}
 
 	return recordings, nil
@@ -293,26 +300,32 @@ func (r *RecordingRepository) FindAll(ctx context.Context) ([]models.Recording,
 
 // Update updates a recording and invalidates cache.
 func (r *RecordingRepository) Update(ctx context.Context, recording *models.Recording) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(recordingsCollection)
 
 	recording.UpdatedAt = time.Now()
 
 	result, err := collection.ReplaceOne(ctx, bson.M{"_id": recording.ID}, recording)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrRecordingNotFound
 	}
 
 	// Update cache
-	r.cache.Set(recordingByIDPrefix+recording.ID.Hex(), recording)
+	r.recordings.set(recordingByID.key(recording.ID.Hex()), recording)
 
 	return nil
 }
 
 // UpdateStatus updates the status of a recording and invalidates cache.
 func (r *RecordingRepository) UpdateStatus(ctx context.Context, id string, status models.RecordingStatus) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return ErrRecordingNotFound
@@ -329,20 +342,528 @@ func (r *RecordingRepository) UpdateStatus(ctx context.Context, id string, statu
 
 	result, err := collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
 	if err != nil {
-		return err
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRecordingNotFound
+	}
+
+	// Invalidate cache
+	r.recordings.delete(recordingByID.key(id))
+
+	return nil
+}
+
+// SetHidden hides a recording from everyone but admins (or restores it) and invalidates cache.
+func (r *RecordingRepository) SetHidden(ctx context.Context, id string, hidden bool) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrRecordingNotFound
+	}
+
+	collection := r.db.Collection(recordingsCollection)
+
+	update := bson.M{
+		"$set": bson.M{
+			"hidden":    hidden,
+			"updatedAt": time.Now(),
+		},
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrRecordingNotFound
 	}
 
 	// Invalidate cache
-	r.cache.Delete(recordingByIDPrefix + id)
+	r.recordings.delete(recordingByID.key(id))
+
+	return nil
+}
+
+// FindArchivable returns up to limit ready recordings recorded before cutoff,
+// skipping those restored from cold storage after cutoff.
+func (r *RecordingRepository) FindArchivable(ctx context.Context, cutoff time.Time, limit int64) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	filter := bson.M{
+		"status":     models.RecordingStatusReady,
+		"recordedAt": bson.M{"$lt": cutoff},
+		"$or": []bson.M{
+			{"restoredAt": bson.M{"$exists": false}},
+			{"restoredAt": bson.M{"$lt": cutoff}},
+		},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "recordedAt", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var recordings []models.Recording
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, dbErr(err)
+	}
+	return recordings, nil
+}
+
+// FindByStatus returns all recordings with the given status.
+func (r *RecordingRepository) FindByStatus(ctx context.Context, status models.RecordingStatus) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	cursor, err := collection.Find(ctx, bson.M{"status": status})
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var recordings []models.Recording
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, dbErr(err)
+	}
+	return recordings, nil
+}
+
+// SetArchived marks a ready recording as moved to cold storage under key.
+// It returns false if the recording was no longer ready (e.g. another instance
+// archived it first).
+func (r *RecordingRepository) SetArchived(ctx context.Context, id primitive.ObjectID, key string) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":     models.RecordingStatusArchived,
+			"archiveKey": key,
+			"archivedAt": now,
+			"updatedAt":  now,
+		},
+		// Variants are removed with the file and made again after a restore
+		"$unset": bson.M{"restoredAt": "", "restoreEta": "", "restoreRequestedBy": "", "variants": "", "variantsClaimedAt": ""},
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "status": models.RecordingStatusReady}, update)
+	if err != nil {
+		return false, dbErr(err)
+	}
+
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// RequestRestore moves an archived recording to restoring with the given ETA
+// and adds userID to the users notified on completion. For a recording
+// already being restored, only the user is added. It returns the updated
+// recording.
+func (r *RecordingRepository) RequestRestore(ctx context.Context, id primitive.ObjectID, eta time.Time, userID primitive.ObjectID) (*models.Recording, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	now := time.Now()
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.RecordingStatusArchived},
+		bson.M{
+			"$set":      bson.M{"status": models.RecordingStatusRestoring, "restoreEta": eta, "updatedAt": now},
+			"$addToSet": bson.M{"restoreRequestedBy": userID},
+		})
+	if err != nil {
+		return nil, dbErr(err)
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var recording models.Recording
+	err = collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.RecordingStatusRestoring},
+		bson.M{"$addToSet": bson.M{"restoreRequestedBy": userID}},
+		opts).Decode(&recording)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRecordingNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return &recording, nil
+}
+
+// SetRestored marks a restoring recording as ready again. It returns false if
+// the recording was no longer being restored.
+func (r *RecordingRepository) SetRestored(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":     models.RecordingStatusReady,
+			"restoredAt": now,
+			"updatedAt":  now,
+		},
+		"$unset": bson.M{"restoreEta": "", "restoreRequestedBy": ""},
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "status": models.RecordingStatusRestoring}, update)
+	if err != nil {
+		return false, dbErr(err)
+	}
+
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FindWithoutChapterScan returns up to limit ready recordings whose scene
+// changes haven't been looked for yet, newest first.
+func (r *RecordingRepository) FindWithoutChapterScan(ctx context.Context, limit int64) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	filter := bson.M{
+		"status":            models.RecordingStatusReady,
+		"chaptersScannedAt": bson.M{"$exists": false},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "recordedAt", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var recordings []models.Recording
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, dbErr(err)
+	}
+	return recordings, nil
+}
+
+// ClaimChapterScan marks a recording's scene changes as being looked for. It
+// reports false if they already were, so only one instance scans it.
+func (r *RecordingRepository) ClaimChapterScan(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "chaptersScannedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"chaptersScannedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FindWithoutVariants returns up to limit ready recordings whose variants
+// haven't been made yet, newest first.
+func (r *RecordingRepository) FindWithoutVariants(ctx context.Context, limit int64) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	filter := bson.M{
+		"status":            models.RecordingStatusReady,
+		"variantsClaimedAt": bson.M{"$exists": false},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "recordedAt", Value: -1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var recordings []models.Recording
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, dbErr(err)
+	}
+	return recordings, nil
+}
+
+// ClaimVariants marks a recording's variants as being made. It reports false
+// if they already were, so only one instance makes them.
+func (r *RecordingRepository) ClaimVariants(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.RecordingStatusReady, "variantsClaimedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"variantsClaimedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FindUnannounced returns up to limit ready, visible recordings created
+// since, whose students haven't been told of them, oldest first.
+func (r *RecordingRepository) FindUnannounced(ctx context.Context, since time.Time, limit int64) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	filter := bson.M{
+		"status":      models.RecordingStatusReady,
+		"hidden":      bson.M{"$ne": true},
+		"createdAt":   bson.M{"$gte": since},
+		"announcedAt": bson.M{"$exists": false},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var recordings []models.Recording
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, dbErr(err)
+	}
+	return recordings, nil
+}
+
+// ClaimAnnouncement marks a recording as told to its students. It reports
+// false if it already was, so only one instance tells them.
+func (r *RecordingRepository) ClaimAnnouncement(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "announcedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"announcedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// SetVariants stores the variants made of a recording, if it is still ready
+// with the file they were made from. It reports false if not, so the caller
+// can remove them.
+func (r *RecordingRepository) SetVariants(ctx context.Context, id primitive.ObjectID, filePath string, variants []models.RecordingVariant) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.RecordingStatusReady, "filePath": filePath},
+		bson.M{"$set": bson.M{"variants": variants}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.MatchedCount > 0, nil
+}
 
+// SetProposedChapters stores the chapters proposed for a recording and
+// invalidates cache.
+func (r *RecordingRepository) SetProposedChapters(ctx context.Context, id primitive.ObjectID, chapters []models.Chapter) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"proposedChapters": chapters}})
+	if err == nil {
+		r.recordings.delete(recordingByID.key(id.Hex()))
+	}
+	return dbErr(err)
+}
+
+// SetChapters replaces a recording's chapters, settling any proposal, and
+// invalidates cache.
+func (r *RecordingRepository) SetChapters(ctx context.Context, id primitive.ObjectID, chapters []models.Chapter) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	update := bson.M{
+		"$set":   bson.M{"chapters": chapters, "updatedAt": time.Now()},
+		"$unset": bson.M{"proposedChapters": ""},
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRecordingNotFound
+	}
+
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return nil
+}
+
+// FindPendingComposites returns up to limit recordings waiting for their
+// camera recording to be composited in, oldest first. Recordings claimed
+// before staleBefore are included, as their compositing was interrupted.
+func (r *RecordingRepository) FindPendingComposites(ctx context.Context, staleBefore time.Time, limit int64) ([]models.Recording, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	filter := bson.M{
+		"status":     models.RecordingStatusProcessing,
+		"cameraPath": bson.M{"$exists": true},
+		"$or": []bson.M{
+			{"compositeClaimedAt": bson.M{"$exists": false}},
+			{"compositeClaimedAt": bson.M{"$lt": staleBefore}},
+		},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var recordings []models.Recording
+	if err := cursor.All(ctx, &recordings); err != nil {
+		return nil, dbErr(err)
+	}
+	return recordings, nil
+}
+
+// ClaimComposite marks a recording as being composited. It reports false if
+// another instance claimed it after staleBefore, or it no longer waits.
+func (r *RecordingRepository) ClaimComposite(ctx context.Context, id primitive.ObjectID, staleBefore time.Time) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	filter := bson.M{
+		"_id":        id,
+		"status":     models.RecordingStatusProcessing,
+		"cameraPath": bson.M{"$exists": true},
+		"$or": []bson.M{
+			{"compositeClaimedAt": bson.M{"$exists": false}},
+			{"compositeClaimedAt": bson.M{"$lt": staleBefore}},
+		},
+	}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"compositeClaimedAt": time.Now()}})
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FinishComposite makes a recording that was being composited ready. With a
+// file name it now points at the composite file; without, the screen
+// recording is kept. It returns false if the recording no longer waits,
+// such as when it was deleted meanwhile.
+func (r *RecordingRepository) FinishComposite(ctx context.Context, id primitive.ObjectID, fileName, filePath string, fileSize int64, mimeType string) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	set := bson.M{"status": models.RecordingStatusReady, "updatedAt": time.Now()}
+	if fileName != "" {
+		set["fileName"] = fileName
+		set["filePath"] = filePath
+		set["fileSize"] = fileSize
+		set["mimeType"] = mimeType
+	}
+	update := bson.M{
+		"$set":   set,
+		"$unset": bson.M{"cameraPath": "", "compositeClaimedAt": ""},
+	}
+	filter := bson.M{"_id": id, "status": models.RecordingStatusProcessing}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.recordings.delete(recordingByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// SetAllowedStudents restricts a recording to the given students of its
+// batch, or opens it to the whole batch again if there are none, and
+// invalidates cache.
+func (r *RecordingRepository) SetAllowedStudents(ctx context.Context, id primitive.ObjectID, studentIDs []primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(recordingsCollection)
+
+	update := bson.M{
+		"$set": bson.M{"allowedStudents": studentIDs, "updatedAt": time.Now()},
+	}
+	if len(studentIDs) == 0 {
+		update = bson.M{
+			"$set":   bson.M{"updatedAt": time.Now()},
+			"$unset": bson.M{"allowedStudents": ""},
+		}
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRecordingNotFound
+	}
+
+	r.recordings.delete(recordingByID.key(id.Hex()))
 	return nil
 }
 
 // Delete deletes a recording and invalidates cache.
 func (r *RecordingRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return ErrRecordingNotFound
@@ -352,19 +873,19 @@ func (r *RecordingRepository) Delete(ctx context.Context, id string) error {
 
 	result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.DeletedCount == 0 {
 		return ErrRecordingNotFound
 	}
 
 	// Invalidate cache
-	r.cache.Delete(recordingByIDPrefix + id)
+	r.recordings.delete(recordingByID.key(id))
 
 	return nil
 }
 
 // ClearCache clears all cached recordings.
 func (r *RecordingRepository) ClearCache() {
-	r.cache.Clear()
+	r.recordings.clear()
 }
@@ -0,0 +1,155 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const reportsCollection = "content_reports"
+
+// Report errors
+var (
+	ErrAlreadyReported = errors.New("content already reported by this user")
+)
+
+// ReportRepository handles content reports and the moderation queue.
+type ReportRepository struct {
+	db *database.MongoDB
+}
+
+// NewReportRepository creates a new ReportRepository.
+func NewReportRepository(db *database.MongoDB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the reports collection.
+func (r *ReportRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(reportsCollection)
+
+	indexes := []mongo.IndexModel{
+		// One open report per user per piece of content
+		{
+			Keys: bson.D{{Key: "contentType", Value: 1}, {Key: "contentId", Value: 1}, {Key: "reporterId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": models.ReportStatusOpen}),
+		},
+		// Moderation queue
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create files a new open report.
+func (r *ReportRepository) Create(ctx context.Context, report *models.Report) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(reportsCollection)
+
+	report.ID = primitive.NewObjectID()
+	report.Status = models.ReportStatusOpen
+	report.CreatedAt = time.Now()
+
+	_, err := collection.InsertOne(ctx, report)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyReported
+	}
+	return dbErr(err)
+}
+
+// CountOpen returns the number of open reports against a piece of content.
+func (r *ReportRepository) CountOpen(ctx context.Context, contentType models.ReportContentType, contentID string) (int64, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(reportsCollection)
+
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"contentType": contentType,
+		"contentId":   contentID,
+		"status":      models.ReportStatusOpen,
+	})
+	return count, dbErr(err)
+}
+
+// FindOpen returns all open reports, oldest first.
+func (r *ReportRepository) FindOpen(ctx context.Context) ([]models.Report, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(reportsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"status": models.ReportStatusOpen}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	reports := []models.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, dbErr(err)
+	}
+	return reports, nil
+}
+
+// FindOpenByContent returns the open reports against a piece of content, oldest first.
+func (r *ReportRepository) FindOpenByContent(ctx context.Context, contentType models.ReportContentType, contentID string) ([]models.Report, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(reportsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{
+		"contentType": contentType,
+		"contentId":   contentID,
+		"status":      models.ReportStatusOpen,
+	}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	reports := []models.Report{}
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, dbErr(err)
+	}
+	return reports, nil
+}
+
+// Resolve closes every open report against a piece of content with the given
+// outcome and returns how many were closed.
+func (r *ReportRepository) Resolve(ctx context.Context, contentType models.ReportContentType, contentID string, status models.ReportStatus, action string, resolvedBy primitive.ObjectID) (int64, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(reportsCollection)
+
+	now := time.Now()
+	result, err := collection.UpdateMany(ctx, bson.M{
+		"contentType": contentType,
+		"contentId":   contentID,
+		"status":      models.ReportStatusOpen,
+	}, bson.M{"$set": bson.M{
+		"status":     status,
+		"action":     action,
+		"resolvedBy": resolvedBy,
+		"resolvedAt": now,
+	}})
+	if err != nil {
+		return 0, dbErr(err)
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,94 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const roomEventCollection = "room_events"
+
+// roomEventRetention is how long room lifecycle events are kept.
+const roomEventRetention = 90 * 24 * time.Hour
+
+// RoomEventRepository handles the append-only lifecycle stream of live rooms.
+type RoomEventRepository struct {
+	db *database.MongoDB
+}
+
+// NewRoomEventRepository creates a new RoomEventRepository.
+func NewRoomEventRepository(db *database.MongoDB) *RoomEventRepository {
+	return &RoomEventRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the room event collection.
+func (r *RoomEventRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(roomEventCollection)
+
+	indexes := []mongo.IndexModel{
+		// Timeline of a room in time order
+		{Keys: bson.D{{Key: "roomId", Value: 1}, {Key: "at", Value: 1}}},
+		// Events of one session of a room
+		{Keys: bson.D{{Key: "sessionId", Value: 1}, {Key: "at", Value: 1}}},
+		// Expire old events
+		{
+			Keys:    bson.D{{Key: "at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(roomEventRetention.Seconds())),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Append adds events to the stream. Events are never updated.
+func (r *RoomEventRepository) Append(ctx context.Context, events []models.RoomEvent) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	if len(events) == 0 {
+		return nil
+	}
+	collection := r.db.Collection(roomEventCollection)
+
+	docs := make([]interface{}, len(events))
+	for i := range events {
+		docs[i] = events[i]
+	}
+
+	_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return dbErr(err)
+}
+
+// FindByRoom returns up to limit events of a room between from and to,
+// oldest first. It reads from the report read preference.
+func (r *RoomEventRepository) FindByRoom(ctx context.Context, roomID string, from, to time.Time, limit int64) ([]models.RoomEvent, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.ReportCollection(roomEventCollection)
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{
+		"roomId": roomID,
+		"at":     bson.M{"$gte": from, "$lte": to},
+	}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.RoomEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, dbErr(err)
+	}
+	return events, nil
+}
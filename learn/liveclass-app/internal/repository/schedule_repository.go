@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
 	"github.com/jinshatcp/brightline-academy/learn/internal/database"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,38 +17,38 @@ import (
 
 const schedulesCollection = "scheduled_classes"
 
-// Schedule cache key prefixes
+// Schedule cache namespaces
 const (
-	scheduleByIDPrefix     = "schedule:id:"
-	scheduleByRoomPrefix   = "schedule:room:"
-	scheduleByBatchPrefix  = "schedule:batch:"
-	scheduleUpcomingPrefix = "schedule:upcoming:"
+	scheduleByID     cacheNamespace[*models.ScheduledClass]  = "schedule:id:"
+	scheduleByRoom   cacheNamespace[*models.ScheduledClass]  = "schedule:room:"
+	schedulesByBatch cacheNamespace[[]models.ScheduledClass] = "schedule:batch:"
 )
 
 // Schedule errors
 var (
 	ErrScheduleNotFound = errors.New("scheduled class not found")
+	ErrScheduleFenced   = errors.New("scheduled class was updated under a later lock")
 )
 
 // ScheduleRepository handles scheduled class data operations with caching.
 type ScheduleRepository struct {
-	db    *database.MongoDB
-	cache *cache.Cache[interface{}]
+	db        *database.MongoDB
+	schedules *cachedRepo[*models.ScheduledClass]
+	lists     *cachedRepo[[]models.ScheduledClass]
+	writeHooks
 }
 
 // NewScheduleRepository creates a new ScheduleRepository.
 func NewScheduleRepository(db *database.MongoDB) *ScheduleRepository {
-	return &ScheduleRepository{
-		db:    db,
-		cache: cache.New[interface{}](30*time.Second, 15*time.Second),
-	}
+	return NewScheduleRepositoryWithCache(db, 30*time.Second)
 }
 
 // NewScheduleRepositoryWithCache creates a new ScheduleRepository with custom cache TTL.
 func NewScheduleRepositoryWithCache(db *database.MongoDB, cacheTTL time.Duration) *ScheduleRepository {
 	return &ScheduleRepository{
-		db:    db,
-		cache: cache.New[interface{}](cacheTTL, 15*time.Second),
+		db:        db,
+		schedules: newCachedRepo[*models.ScheduledClass](cacheTTL, 15*time.Second, ErrScheduleNotFound),
+		lists:     newCachedRepo[[]models.ScheduledClass](cacheTTL, 15*time.Second, nil),
 	}
 }
 
@@ -73,6 +72,9 @@ func (r *ScheduleRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "status", Value: 1}},
 		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: 1}},
+		},
 		// Compound indexes for common queries
 		{
 			Keys: bson.D{{Key: "batchId", Value: 1}, {Key: "startTime", Value: 1}},
@@ -80,6 +82,11 @@ func (r *ScheduleRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "presenterId", Value: 1}, {Key: "startTime", Value: 1}},
 		},
+		// Pre-class lobbies, looked up when someone joins their room
+		{
+			Keys:    bson.D{{Key: "lobbyRoomId", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
 	}
 
 	_, err := collection.Indexes().CreateMany(ctx, indexes)
@@ -88,6 +95,9 @@ func (r *ScheduleRepository) CreateIndexes(ctx context.Context) error {
 
 // Create creates a new scheduled class.
 func (r *ScheduleRepository) Create(ctx context.Context, schedule *models.ScheduledClass) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(schedulesCollection)
 
 	schedule.ID = primitive.NewObjectID()
@@ -98,80 +108,70 @@ func (r *ScheduleRepository) Create(ctx context.Context, schedule *models.Schedu
 	_, err := collection.InsertOne(ctx, schedule)
 	if err == nil {
 		// Cache the new schedule
-		r.cache.Set(scheduleByIDPrefix+schedule.ID.Hex(), schedule)
+		r.schedules.set(scheduleByID.key(schedule.ID.Hex()), schedule)
+		if schedule.RoomID != "" {
+			r.schedules.delete(scheduleByRoom.key(schedule.RoomID))
+		}
 		// Invalidate list caches
 		r.invalidateListCaches()
 	}
-	return err
+	return dbErr(err)
 }
 
 // FindByID finds a scheduled class by ID with caching.
 func (r *ScheduleRepository) FindByID(ctx context.Context, id string) (*models.ScheduledClass, error) {
-	cacheKey := scheduleByIDPrefix + id
-
-	// Try cache first
-	if cached, found := r.cache.Get(cacheKey); found {
-		if schedule, ok := cached.(*models.ScheduledClass); ok {
-			return schedule, nil
-		}
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrScheduleNotFound
 	}
 
-	collection := r.db.Collection(schedulesCollection)
-
-	var schedule models.ScheduledClass
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&schedule)
-	if err == mongo.ErrNoDocuments {
-		return nil, ErrScheduleNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, &schedule)
+	return r.schedules.load(ctx, scheduleByID.key(id), func(ctx context.Context) (*models.ScheduledClass, error) {
+		return r.findOne(ctx, bson.M{"_id": objectID})
+	})
+}
 
-	return &schedule, nil
+// Reload finds a scheduled class by ID in the database, bypassing the
+// cache, which may not have seen changes made on other instances.
+func (r *ScheduleRepository) Reload(ctx context.Context, id string) (*models.ScheduledClass, error) {
+	r.schedules.delete(scheduleByID.key(id))
+	return r.FindByID(ctx, id)
 }
 
 // FindByRoomID finds a scheduled class by room ID with caching.
 func (r *ScheduleRepository) FindByRoomID(ctx context.Context, roomID string) (*models.ScheduledClass, error) {
-	cacheKey := scheduleByRoomPrefix + roomID
-
-	// Try cache first
-	if cached, found := r.cache.Get(cacheKey); found {
-		if schedule, ok := cached.(*models.ScheduledClass); ok {
-			return schedule, nil
+	return r.schedules.load(ctx, scheduleByRoom.key(roomID), func(ctx context.Context) (*models.ScheduledClass, error) {
+		schedule, err := r.findOne(ctx, bson.M{"roomId": roomID})
+		if err == nil {
+			r.schedules.set(scheduleByID.key(schedule.ID.Hex()), schedule)
 		}
-	}
+		return schedule, err
+	})
+}
 
-	collection := r.db.Collection(schedulesCollection)
+// findOne returns the scheduled class matching filter from the database.
+func (r *ScheduleRepository) findOne(ctx context.Context, filter bson.M) (*models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
 
 	var schedule models.ScheduledClass
-	err := collection.FindOne(ctx, bson.M{"roomId": roomID}).Decode(&schedule)
+	err := r.db.Collection(schedulesCollection).FindOne(ctx, filter).Decode(&schedule)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrScheduleNotFound
 	}
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
-
-	// Cache the result
-	r.cache.Set(cacheKey, &schedule)
-	r.cache.Set(scheduleByIDPrefix+schedule.ID.Hex(), &schedule)
-
 	return &schedule, nil
 }
 
 // FindByPresenter returns scheduled classes for a presenter.
 func (r *ScheduleRepository) FindByPresenter(ctx context.Context, presenterID string, fromDate, toDate time.Time) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(presenterID)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	collection := r.db.Collection(schedulesCollection)
@@ -190,18 +190,18 @@ func (r *ScheduleRepository) FindByPresenter(ctx context.Context, presenterID st
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var schedules []models.ScheduledClass
 	if err := cursor.All(ctx, &schedules); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual schedules
 	for i := range schedules {
-		r.cache.Set(scheduleByIDPrefix+schedules[i].ID.Hex(), &schedules[i])
+		r.schedules.set(scheduleByID.key(schedules[i].ID.Hex()), &schedules[i])
 	}
 
 	return schedules, nil
@@ -209,56 +209,52 @@ func (r *ScheduleRepository) FindByPresenter(ctx context.Context, presenterID st
 
 // FindByBatch returns scheduled classes for a batch with caching.
 func (r *ScheduleRepository) FindByBatch(ctx context.Context, batchID string, fromDate, toDate time.Time) ([]models.ScheduledClass, error) {
-	cacheKey := fmt.Sprintf("%s%s:%d:%d", scheduleByBatchPrefix, batchID, fromDate.Unix(), toDate.Unix())
-
-	// Try cache first
-	if cached, found := r.cache.Get(cacheKey); found {
-		if schedules, ok := cached.([]models.ScheduledClass); ok {
-			return schedules, nil
-		}
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(batchID)
 	if err != nil {
 		return nil, err
 	}
 
-	collection := r.db.Collection(schedulesCollection)
+	key := schedulesByBatch.key(fmt.Sprintf("%s:%d:%d", batchID, fromDate.Unix(), toDate.Unix()))
+	return r.lists.load(ctx, key, func(ctx context.Context) ([]models.ScheduledClass, error) {
+		ctx, cancel := r.db.ReadContext(ctx)
+		defer cancel()
 
-	filter := bson.M{
-		"batchId": objectID,
-		"startTime": bson.M{
-			"$gte": fromDate,
-			"$lte": toDate,
-		},
-	}
+		filter := bson.M{
+			"batchId": objectID,
+			"startTime": bson.M{
+				"$gte": fromDate,
+				"$lte": toDate,
+			},
+		}
 
-	opts := options.Find().
-		SetSort(bson.D{{Key: "startTime", Value: 1}}).
-		SetBatchSize(100)
+		opts := options.Find().
+			SetSort(bson.D{{Key: "startTime", Value: 1}}).
+			SetBatchSize(100)
 
-	cursor, err := collection.Find(ctx, filter, opts)
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var schedules []models.ScheduledClass
-	if err := cursor.All(ctx, &schedules); err != nil {
-		return nil, err
-	}
+		cursor, err := r.db.Collection(schedulesCollection).Find(ctx, filter, opts)
+		if err != nil {
+			return nil, dbErr(err)
+		}
+		defer cursor.Close(ctx)
 
-	// Cache the result and individual schedules
-	r.cache.Set(cacheKey, schedules)
-	for i := range schedules {
-		r.cache.Set(scheduleByIDPrefix+schedules[i].ID.Hex(), &schedules[i])
-	}
+		var schedules []models.ScheduledClass
+		if err := cursor.All(ctx, &schedules); err != nil {
+			return nil, dbErr(err)
+		}
 
-	return schedules, nil
+		// Cache individual schedules
+		for i := range schedules {
+			r.schedules.set(scheduleByID.key(schedules[i].ID.Hex()), &schedules[i])
+		}
+		return schedules, nil
+	})
 }
 
 // FindByBatches returns scheduled classes for multiple batches.
 func (r *ScheduleRepository) FindByBatches(ctx context.Context, batchIDs []string, fromDate, toDate time.Time) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	objectIDs := make([]primitive.ObjectID, 0, len(batchIDs))
 	for _, id := range batchIDs {
 		oid, err := primitive.ObjectIDFromHex(id)
@@ -288,18 +284,18 @@ func (r *ScheduleRepository) FindByBatches(ctx context.Context, batchIDs []strin
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var schedules []models.ScheduledClass
 	if err := cursor.All(ctx, &schedules); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual schedules
 	for i := range schedules {
-		r.cache.Set(scheduleByIDPrefix+schedules[i].ID.Hex(), &schedules[i])
+		r.schedules.set(scheduleByID.key(schedules[i].ID.Hex()), &schedules[i])
 	}
 
 	return schedules, nil
@@ -312,24 +308,227 @@ func (r *ScheduleRepository) FindUpcoming(ctx context.Context, batchIDs []string
 	return r.FindByBatches(ctx, batchIDs, now, endDate)
 }
 
+// Warm loads the classes starting between from and to into the cache, by ID
+// and, for those started, by room. It returns how many were loaded.
+func (r *ScheduleRepository) Warm(ctx context.Context, from, to time.Time) (int, error) {
+	collection := r.db.Collection(schedulesCollection)
+
+	filter := bson.M{"startTime": bson.M{"$gte": from, "$lte": to}}
+	opts := options.Find().SetBatchSize(100)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []models.ScheduledClass
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return 0, err
+	}
+
+	for i := range schedules {
+		r.schedules.set(scheduleByID.key(schedules[i].ID.Hex()), &schedules[i])
+		if schedules[i].RoomID != "" {
+			r.schedules.set(scheduleByRoom.key(schedules[i].RoomID), &schedules[i])
+		}
+	}
+	return len(schedules), nil
+}
+
+// FindByStatus returns all scheduled classes with the given stored status, oldest first.
+func (r *ScheduleRepository) FindByStatus(ctx context.Context, status models.ClassStatus) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"status": status}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []models.ScheduledClass
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, dbErr(err)
+	}
+	return schedules, nil
+}
+
+// FindScheduledBetween returns the classes still to be held that overlap the
+// period from start to end, earliest first.
+func (r *ScheduleRepository) FindScheduledBetween(ctx context.Context, start, end time.Time) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	filter := bson.M{
+		"startTime": bson.M{"$lt": end},
+		"endTime":   bson.M{"$gt": start},
+		"status":    models.ClassStatusScheduled,
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []models.ScheduledClass
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, dbErr(err)
+	}
+	return schedules, nil
+}
+
+// FindUnannounced returns up to limit classes still to be held, created
+// since, whose students haven't been told of them, oldest first.
+func (r *ScheduleRepository) FindUnannounced(ctx context.Context, since time.Time, limit int64) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	filter := bson.M{
+		"status":      models.ClassStatusScheduled,
+		"createdAt":   bson.M{"$gte": since},
+		"startTime":   bson.M{"$gt": time.Now()},
+		"announcedAt": bson.M{"$exists": false},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []models.ScheduledClass
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, dbErr(err)
+	}
+	return schedules, nil
+}
+
+// ClaimAnnouncement marks a class as told to its students. It reports false
+// if it already was, so only one instance tells them.
+func (r *ScheduleRepository) ClaimAnnouncement(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "announcedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"announcedAt": time.Now()}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.schedules.delete(scheduleByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FindStartingBetween returns the classes still to be held that start from
+// start to end, earliest first.
+func (r *ScheduleRepository) FindStartingBetween(ctx context.Context, start, end time.Time) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	filter := bson.M{
+		"startTime": bson.M{"$gte": start, "$lt": end},
+		"status":    models.ClassStatusScheduled,
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []models.ScheduledClass
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, dbErr(err)
+	}
+	return schedules, nil
+}
+
+// ClaimReminder marks the students of a class as reminded of its start time.
+// It reports false if they already were, or the class was rescheduled, so
+// only one instance reminds them. A rescheduled class is reminded of again.
+func (r *ScheduleRepository) ClaimReminder(ctx context.Context, id primitive.ObjectID, startTime time.Time) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "startTime": startTime, "remindedFor": bson.M{"$ne": startTime}},
+		bson.M{"$set": bson.M{"remindedFor": startTime}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.schedules.delete(scheduleByID.key(id.Hex()))
+	return result.ModifiedCount > 0, nil
+}
+
+// FindEndedFromTemplates returns the classes scheduled from a template that
+// ended between from and to and weren't cancelled, oldest first. It reads
+// from the report read preference.
+func (r *ScheduleRepository) FindEndedFromTemplates(ctx context.Context, from, to time.Time) ([]models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.ReportCollection(schedulesCollection)
+
+	filter := bson.M{
+		"templateId": bson.M{"$exists": true},
+		"endTime":    bson.M{"$gte": from, "$lt": to},
+		"status":     bson.M{"$ne": models.ClassStatusCancelled},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	var schedules []models.ScheduledClass
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, dbErr(err)
+	}
+	return schedules, nil
+}
+
 // Update updates a scheduled class and invalidates caches.
 func (r *ScheduleRepository) Update(ctx context.Context, schedule *models.ScheduledClass) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(schedulesCollection)
 
 	schedule.UpdatedAt = time.Now()
 
 	result, err := collection.ReplaceOne(ctx, bson.M{"_id": schedule.ID}, schedule)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrScheduleNotFound
 	}
 
 	// Update cache
-	r.cache.Set(scheduleByIDPrefix+schedule.ID.Hex(), schedule)
+	r.schedules.set(scheduleByID.key(schedule.ID.Hex()), schedule)
 	if schedule.RoomID != "" {
-		r.cache.Set(scheduleByRoomPrefix+schedule.RoomID, schedule)
+		r.schedules.set(scheduleByRoom.key(schedule.RoomID), schedule)
 	}
 	r.invalidateListCaches()
 
@@ -338,6 +537,72 @@ func (r *ScheduleRepository) Update(ctx context.Context, schedule *models.Schedu
 
 // UpdateStatus updates the status of a scheduled class and invalidates caches.
 func (r *ScheduleRepository) UpdateStatus(ctx context.Context, id string, status models.ClassStatus, roomID string) error {
+	return r.updateStatus(ctx, id, status, roomID, 0)
+}
+
+// UpdateStatusFenced updates the status of a scheduled class under a lock
+// with the fencing token fence. It fails with ErrScheduleFenced if the
+// status was already set under a later lock, i.e. this one has expired.
+func (r *ScheduleRepository) UpdateStatusFenced(ctx context.Context, id string, status models.ClassStatus, roomID string, fence int64) error {
+	return r.updateStatus(ctx, id, status, roomID, fence)
+}
+
+// updateStatus sets the status, checking the fencing token unless it's 0.
+func (r *ScheduleRepository) updateStatus(ctx context.Context, id string, status models.ClassStatus, roomID string, fence int64) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrScheduleNotFound
+	}
+
+	collection := r.db.Collection(schedulesCollection)
+
+	filter := bson.M{"_id": objectID}
+	set := bson.M{
+		"status":    status,
+		"roomId":    roomID,
+		"updatedAt": time.Now(),
+	}
+	if fence != 0 {
+		filter["statusFence"] = bson.M{"$not": bson.M{"$gt": fence}}
+		set["statusFence"] = fence
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		if fence == 0 {
+			return ErrScheduleNotFound
+		}
+		exists, err := collection.CountDocuments(ctx, bson.M{"_id": objectID})
+		if err != nil {
+			return dbErr(err)
+		}
+		if exists == 0 {
+			return ErrScheduleNotFound
+		}
+		return ErrScheduleFenced
+	}
+
+	// Invalidate caches
+	r.schedules.delete(scheduleByID.key(id))
+	if roomID != "" {
+		r.schedules.delete(scheduleByRoom.key(roomID))
+	}
+	r.invalidateListCaches()
+
+	return nil
+}
+
+// SetArchivePath links a generated class archive to a scheduled class.
+func (r *ScheduleRepository) SetArchivePath(ctx context.Context, id, archivePath string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return ErrScheduleNotFound
@@ -347,32 +612,85 @@ func (r *ScheduleRepository) UpdateStatus(ctx context.Context, id string, status
 
 	update := bson.M{
 		"$set": bson.M{
-			"status":    status,
-			"roomId":    roomID,
-			"updatedAt": time.Now(),
+			"archivePath": archivePath,
+			"updatedAt":   time.Now(),
 		},
 	}
 
 	result, err := collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrScheduleNotFound
 	}
 
-	// Invalidate caches
-	r.cache.Delete(scheduleByIDPrefix + id)
-	if roomID != "" {
-		r.cache.Delete(scheduleByRoomPrefix + roomID)
-	}
+	// Invalidate caches (the room key isn't known here, so drop all room lookups)
+	r.schedules.delete(scheduleByID.key(id))
+	r.schedules.deleteNamespace(scheduleByRoom)
 	r.invalidateListCaches()
 
 	return nil
 }
 
+// OpenLobby gives a class a lobby room, unless it already has one, and
+// returns the class's lobby room ID.
+func (r *ScheduleRepository) OpenLobby(ctx context.Context, id, roomID string) (string, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", ErrScheduleNotFound
+	}
+
+	collection := r.db.Collection(schedulesCollection)
+
+	// Only the first request sets it, so concurrent openers share one room
+	_, err = collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "lobbyRoomId": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"lobbyRoomId": roomID, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return "", dbErr(err)
+	}
+	r.schedules.delete(scheduleByID.key(id))
+
+	var schedule models.ScheduledClass
+	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrScheduleNotFound
+	}
+	if err != nil {
+		return "", dbErr(err)
+	}
+	return schedule.LobbyRoomID, nil
+}
+
+// FindByLobbyRoomID returns the class whose lobby is in a room. It isn't
+// cached, since the class goes live in the same room.
+func (r *ScheduleRepository) FindByLobbyRoomID(ctx context.Context, roomID string) (*models.ScheduledClass, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(schedulesCollection)
+
+	var schedule models.ScheduledClass
+	err := collection.FindOne(ctx, bson.M{"lobbyRoomId": roomID}).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	return &schedule, nil
+}
+
 // Delete deletes a scheduled class and invalidates caches.
 func (r *ScheduleRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	// Get schedule first to invalidate room cache
 	schedule, _ := r.FindByID(ctx, id)
 
@@ -385,16 +703,16 @@ func (r *ScheduleRepository) Delete(ctx context.Context, id string) error {
 
 	result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.DeletedCount == 0 {
 		return ErrScheduleNotFound
 	}
 
 	// Invalidate caches
-	r.cache.Delete(scheduleByIDPrefix + id)
+	r.schedules.delete(scheduleByID.key(id))
 	if schedule != nil && schedule.RoomID != "" {
-		r.cache.Delete(scheduleByRoomPrefix + schedule.RoomID)
+		r.schedules.delete(scheduleByRoom.key(schedule.RoomID))
 	}
 	r.invalidateListCaches()
 
@@ -403,11 +721,12 @@ func (r *ScheduleRepository) Delete(ctx context.Context, id string) error {
 
 // invalidateListCaches invalidates all list caches.
 func (r *ScheduleRepository) invalidateListCaches() {
-	r.cache.DeletePrefix(scheduleByBatchPrefix)
-	r.cache.DeletePrefix(scheduleUpcomingPrefix)
+	r.lists.deleteNamespace(schedulesByBatch)
+	r.fireWrite()
 }
 
 // ClearCache clears all cached schedules.
 func (r *ScheduleRepository) ClearCache() {
-	r.cache.Clear()
+	r.schedules.clear()
+	r.lists.clear()
 }
@@ -0,0 +1,207 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const sessionsCollection = "sessions"
+
+// sessionCacheTTL bounds how long another instance may keep accepting a
+// session after it was revoked.
+const sessionCacheTTL = 30 * time.Second
+
+// ErrSessionNotFound is returned when a session doesn't exist.
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionByID is the cache namespace of sessions.
+const sessionByID cacheNamespace[*models.Session] = "session:id:"
+
+// SessionRepository handles the signed-in devices of accounts. Sessions are
+// looked up on every authenticated request, so they are cached briefly.
+type SessionRepository struct {
+	db       *database.MongoDB
+	sessions *cachedRepo[*models.Session]
+}
+
+// NewSessionRepository creates a new SessionRepository.
+func NewSessionRepository(db *database.MongoDB) *SessionRepository {
+	return &SessionRepository{
+		db:       db,
+		sessions: newCachedRepo[*models.Session](sessionCacheTTL, time.Minute, ErrSessionNotFound),
+	}
+}
+
+// CreateIndexes creates necessary indexes for the sessions collection.
+func (r *SessionRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(sessionsCollection)
+
+	indexes := []mongo.IndexModel{
+		// Sessions of an account, oldest first
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		// Remove sessions once their token has expired
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Create stores a new session.
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(sessionsCollection)
+
+	session.ID = primitive.NewObjectID()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+
+	if _, err := collection.InsertOne(ctx, session); err != nil {
+		return dbErr(err)
+	}
+	r.sessions.set(sessionByID.key(session.ID.Hex()), session)
+	return nil
+}
+
+// FindByID finds a session by ID.
+func (r *SessionRepository) FindByID(ctx context.Context, id string) (*models.Session, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	return r.sessions.load(ctx, sessionByID.key(id), func(ctx context.Context) (*models.Session, error) {
+		ctx, cancel := r.db.ReadContext(ctx)
+		defer cancel()
+
+		var session models.Session
+		err := r.db.Collection(sessionsCollection).FindOne(ctx, bson.M{"_id": objectID}).Decode(&session)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSessionNotFound
+		}
+		if err != nil {
+			return nil, dbErr(err)
+		}
+		return &session, nil
+	})
+}
+
+// FindActive returns the sessions of a user that aren't revoked or expired,
+// oldest first.
+func (r *SessionRepository) FindActive(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(sessionsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{
+		"userId":    userID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, dbErr(err)
+	}
+	return sessions, nil
+}
+
+// Revoke signs a session out. Revoking a session twice keeps the first reason.
+func (r *SessionRepository) Revoke(ctx context.Context, id primitive.ObjectID, reason string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(sessionsCollection)
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": reason}},
+	)
+	r.sessions.delete(sessionByID.key(id.Hex()))
+	return dbErr(err)
+}
+
+// DeleteByUser removes all sessions of a user, with the devices and
+// addresses they record. Sessions still cached stay usable until they expire
+// from the cache, so revoke active sessions first.
+func (r *SessionRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(sessionsCollection)
+
+	_, err := collection.DeleteMany(ctx, bson.M{"userId": userID})
+	return dbErr(err)
+}
+
+// RotateRefresh replaces a session's refresh token hashed oldHash with one
+// hashed newHash. It reports false if the session is signed out or expired,
+// or oldHash isn't its current token.
+func (r *SessionRepository) RotateRefresh(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(sessionsCollection)
+
+	now := time.Now()
+	result, err := collection.UpdateOne(ctx,
+		bson.M{
+			"_id":         id,
+			"refreshHash": oldHash,
+			"revokedAt":   bson.M{"$exists": false},
+			"expiresAt":   bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"refreshHash": newHash, "previousRefreshHash": oldHash, "refreshedAt": now}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.sessions.delete(sessionByID.key(id.Hex()))
+	return result.ModifiedCount == 1, nil
+}
+
+// RevokeReused signs a session out if hash is its replaced refresh token,
+// reporting whether it was.
+func (r *SessionRepository) RevokeReused(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(sessionsCollection)
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "previousRefreshHash": hash, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "revokedReason": models.SessionRevokedReuse}},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+	r.sessions.delete(sessionByID.key(id.Hex()))
+	return result.ModifiedCount == 1, nil
+}
+
+// Forget drops a session from this instance's cache, after another instance
+// revoked it.
+func (r *SessionRepository) Forget(id string) {
+	r.sessions.delete(sessionByID.key(id))
+}
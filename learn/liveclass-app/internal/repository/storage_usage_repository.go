@@ -0,0 +1,145 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const storageUsageCollection = "storage_usage"
+
+// Storage usage errors
+var (
+	ErrStorageUsageNotFound = errors.New("storage usage report not found")
+)
+
+// StorageUsageRepository handles the daily storage usage history.
+type StorageUsageRepository struct {
+	db *database.MongoDB
+}
+
+// NewStorageUsageRepository creates a new StorageUsageRepository.
+func NewStorageUsageRepository(db *database.MongoDB) *StorageUsageRepository {
+	return &StorageUsageRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the storage usage collection.
+func (r *StorageUsageRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(storageUsageCollection)
+
+	indexes := []mongo.IndexModel{
+		// One report per day
+		{
+			Keys:    bson.D{{Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Save stores the report for its day, replacing an earlier report of the same day.
+// The alert marker of the day is kept.
+func (r *StorageUsageRepository) Save(ctx context.Context, s *models.StorageUsageSnapshot) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(storageUsageCollection)
+
+	s.CreatedAt = time.Now()
+
+	existing := &models.StorageUsageSnapshot{}
+	if err := collection.FindOne(ctx, bson.M{"date": s.Date}).Decode(existing); err == nil {
+		s.ID = existing.ID
+		s.AlertedBytes = existing.AlertedBytes
+	} else {
+		s.ID = primitive.NewObjectID()
+	}
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"date": s.Date}, s, options.Replace().SetUpsert(true))
+	return dbErr(err)
+}
+
+// Latest returns the most recent report.
+func (r *StorageUsageRepository) Latest(ctx context.Context) (*models.StorageUsageSnapshot, error) {
+	return r.findOne(ctx, bson.M{})
+}
+
+// LatestBefore returns the most recent report of a day before the given date.
+func (r *StorageUsageRepository) LatestBefore(ctx context.Context, date time.Time) (*models.StorageUsageSnapshot, error) {
+	return r.findOne(ctx, bson.M{"date": bson.M{"$lt": date}})
+}
+
+// FindSince returns the reports from the given date onwards, oldest first.
+// It reads from the report read preference.
+func (r *StorageUsageRepository) FindSince(ctx context.Context, since time.Time) ([]models.StorageUsageSnapshot, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.ReportCollection(storageUsageCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"date": bson.M{"$gte": since}}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := []models.StorageUsageSnapshot{}
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, dbErr(err)
+	}
+	return snapshots, nil
+}
+
+// ClaimAlert records that the threshold was alerted for the day. It returns
+// false if the same or a higher threshold was already claimed, so only one
+// instance sends the alert in multi-instance deployments.
+func (r *StorageUsageRepository) ClaimAlert(ctx context.Context, date time.Time, threshold int64) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(storageUsageCollection)
+
+	filter := bson.M{
+		"date": date,
+		"$or": []bson.M{
+			{"alertedBytes": bson.M{"$exists": false}},
+			{"alertedBytes": bson.M{"$lt": threshold}},
+		},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"alertedBytes": threshold}})
+	if err != nil {
+		return false, dbErr(err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// findOne returns the newest report matching the filter.
+func (r *StorageUsageRepository) findOne(ctx context.Context, filter bson.M) (*models.StorageUsageSnapshot, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(storageUsageCollection)
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}})
+
+	snapshot := &models.StorageUsageSnapshot{}
+	if err := collection.FindOne(ctx, filter, opts).Decode(snapshot); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrStorageUsageNotFound
+		}
+		return nil, dbErr(err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,30 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrTimeout is returned, wrapping the driver's error, when an operation
+// runs past its read or write budget or the caller's deadline.
+var ErrTimeout = errors.New("database operation timed out")
+
+// IsTimeout reports whether err is a database timeout.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		mongo.IsTimeout(err)
+}
+
+// dbErr marks timeouts with ErrTimeout and returns other errors, such as
+// mongo.ErrNoDocuments, unchanged.
+func dbErr(err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) || !IsTimeout(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
 	"github.com/jinshatcp/brightline-academy/learn/internal/database"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,11 +16,10 @@ import (
 
 const usersCollection = "users"
 
-// Cache key prefixes
+// User cache namespaces
 const (
-	userByIDPrefix    = "user:id:"
-	userByEmailPrefix = "user:email:"
-	usersListPrefix   = "users:list:"
+	userByID    cacheNamespace[*models.User] = "user:id:"
+	userByEmail cacheNamespace[*models.User] = "user:email:"
 )
 
 // Common errors
@@ -33,22 +31,20 @@ var (
 // UserRepository handles user data operations with caching.
 type UserRepository struct {
 	db    *database.MongoDB
-	cache *cache.Cache[*models.User]
+	users *cachedRepo[*models.User]
+	writeHooks
 }
 
 // NewUserRepository creates a new UserRepository.
 func NewUserRepository(db *database.MongoDB) *UserRepository {
-	return &UserRepository{
-		db:    db,
-		cache: cache.New[*models.User](5*time.Minute, 1*time.Minute),
-	}
+	return NewUserRepositoryWithCache(db, 5*time.Minute)
 }
 
 // NewUserRepositoryWithCache creates a new UserRepository with custom cache TTL.
 func NewUserRepositoryWithCache(db *database.MongoDB, cacheTTL time.Duration) *UserRepository {
 	return &UserRepository{
 		db:    db,
-		cache: cache.New[*models.User](cacheTTL, 1*time.Minute),
+		users: newCachedRepo[*models.User](cacheTTL, 1*time.Minute, ErrUserNotFound),
 	}
 }
 
@@ -74,6 +70,11 @@ func (r *UserRepository) CreateIndexes(ctx context.Context) error {
 		{
 			Keys: bson.D{{Key: "status", Value: 1}, {Key: "role", Value: 1}},
 		},
+		// Accounts waiting to be anonymized
+		{
+			Keys:    bson.D{{Key: "deactivateAt", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
 	}
 
 	_, err := collection.Indexes().CreateMany(ctx, indexes)
@@ -82,6 +83,9 @@ func (r *UserRepository) CreateIndexes(ctx context.Context) error {
 
 // Create creates a new user.
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(usersCollection)
 
 	user.ID = primitive.NewObjectID()
@@ -98,68 +102,52 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 		r.cacheUser(user)
 	}
 
-	return err
+	return dbErr(err)
 }
 
 // FindByID finds a user by ID with caching.
 func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
-	cacheKey := userByIDPrefix + id
-
-	// Try cache first
-	if user, found := r.cache.Get(cacheKey); found {
-		return user, nil
-	}
-
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
 
-	collection := r.db.Collection(usersCollection)
-
-	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		return nil, ErrUserNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	// Cache the result
-	r.cacheUser(&user)
-
-	return &user, nil
+	return r.users.load(ctx, userByID.key(id), func(ctx context.Context) (*models.User, error) {
+		return r.findOne(ctx, bson.M{"_id": objectID})
+	})
 }
 
 // FindByEmail finds a user by email with caching.
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
-	cacheKey := userByEmailPrefix + email
-
-	// Try cache first
-	if user, found := r.cache.Get(cacheKey); found {
-		return user, nil
-	}
+	return r.users.load(ctx, userByEmail.key(email), func(ctx context.Context) (*models.User, error) {
+		return r.findOne(ctx, bson.M{"email": email})
+	})
+}
 
-	collection := r.db.Collection(usersCollection)
+// findOne returns the user matching filter from the database and caches it
+// by both ID and email.
+func (r *UserRepository) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
 
 	var user models.User
-	err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	err := r.db.Collection(usersCollection).FindOne(ctx, filter).Decode(&user)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
-	// Cache the result
 	r.cacheUser(&user)
-
 	return &user, nil
 }
 
 // FindAll returns all users with optional filters.
 func (r *UserRepository) FindAll(ctx context.Context, status *models.UserStatus, role *models.UserRole) ([]models.User, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(usersCollection)
 
 	filter := bson.M{}
@@ -176,13 +164,13 @@ func (r *UserRepository) FindAll(ctx context.Context, status *models.UserStatus,
 
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 	defer cursor.Close(ctx)
 
 	var users []models.User
 	if err := cursor.All(ctx, &users); err != nil {
-		return nil, err
+		return nil, dbErr(err)
 	}
 
 	// Cache individual users
@@ -201,6 +189,9 @@ func (r *UserRepository) FindPendingUsers(ctx context.Context) ([]models.User, e
 
 // UpdateStatus updates a user's status and invalidates cache.
 func (r *UserRepository) UpdateStatus(ctx context.Context, userID string, status models.UserStatus, approvedBy string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	objectID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return ErrUserNotFound
@@ -222,29 +213,42 @@ func (r *UserRepository) UpdateStatus(ctx context.Context, userID string, status
 		update["$set"].(bson.M)["approvedAt"] = &now
 	}
 
-	result, err := collection.UpdateOne(ctx, bson.M{"_id": objectID}, update)
-	if err != nil {
-		return err
+	// The email is returned so the login lookup isn't left with the old status
+	var updated struct {
+		Email string `bson:"email"`
 	}
-	if result.MatchedCount == 0 {
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"email": 1})
+	err = collection.FindOneAndUpdate(ctx, bson.M{"_id": objectID}, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
 		return ErrUserNotFound
 	}
+	if err != nil {
+		return dbErr(err)
+	}
 
 	// Invalidate cache
-	r.invalidateUserCache(userID)
+	r.invalidateUserCache(userID, updated.Email)
 
 	return nil
 }
 
 // Update updates user fields and invalidates cache.
 func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(usersCollection)
 
 	user.UpdatedAt = time.Now()
 
+	// A changed email leaves the old one's entry behind
+	if cached, ok := r.users.get(userByID.key(user.ID.Hex())); ok && cached.Email != user.Email {
+		r.users.delete(userByEmail.key(cached.Email))
+	}
+
 	result, err := collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.MatchedCount == 0 {
 		return ErrUserNotFound
@@ -252,12 +256,16 @@ func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
 
 	// Update cache with new data
 	r.cacheUser(user)
+	r.fireWrite()
 
 	return nil
 }
 
 // Delete deletes a user by ID and invalidates cache.
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
 	// Get user first to invalidate email cache
 	user, _ := r.FindByID(ctx, id)
 
@@ -270,25 +278,140 @@ func (r *UserRepository) Delete(ctx context.Context, id string) error {
 
 	result, err := collection.DeleteOne(ctx, bson.M{"_id": objectID})
 	if err != nil {
-		return err
+		return dbErr(err)
 	}
 	if result.DeletedCount == 0 {
 		return ErrUserNotFound
 	}
 
 	// Invalidate cache
-	r.invalidateUserCache(id)
+	email := ""
 	if user != nil {
-		r.cache.Delete(userByEmailPrefix + user.Email)
+		email = user.Email
 	}
+	r.invalidateUserCache(id, email)
 
 	return nil
 }
 
+// ScheduleDeactivation sets when a user's account is to be anonymized.
+func (r *UserRepository) ScheduleDeactivation(ctx context.Context, user *models.User, at time.Time) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(usersCollection)
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "status": bson.M{"$ne": models.StatusDeactivated}},
+		bson.M{"$set": bson.M{"deactivateAt": at, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	r.invalidateUserCache(user.ID.Hex(), user.Email)
+	return nil
+}
+
+// CancelDeactivation clears a scheduled deactivation. It reports false if
+// the grace period already ended, as the account may be anonymized any
+// moment.
+func (r *UserRepository) CancelDeactivation(ctx context.Context, user *models.User) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(usersCollection)
+	result, err := collection.UpdateOne(ctx,
+		bson.M{
+			"_id":          user.ID,
+			"deactivateAt": bson.M{"$gt": time.Now()},
+			"status":       bson.M{"$ne": models.StatusDeactivated},
+		},
+		bson.M{
+			"$set":   bson.M{"updatedAt": time.Now()},
+			"$unset": bson.M{"deactivateAt": ""},
+		},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+
+	r.invalidateUserCache(user.ID.Hex(), user.Email)
+	return result.ModifiedCount == 1, nil
+}
+
+// FindDueForDeactivation returns the accounts whose deactivation grace
+// period ended by now.
+func (r *UserRepository) FindDueForDeactivation(ctx context.Context, now time.Time) ([]models.User, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(usersCollection)
+	cursor, err := collection.Find(ctx, bson.M{
+		"deactivateAt": bson.M{"$lte": now},
+		"status":       bson.M{"$ne": models.StatusDeactivated},
+	})
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, dbErr(err)
+	}
+	return users, nil
+}
+
+// Anonymize replaces the name and email of an account whose deactivation is
+// due and removes its password and preferences, keeping its ID, role and
+// creation time.
+// It reports false if the account was reactivated or anonymized meanwhile,
+// so instances sharing the database don't both anonymize it.
+func (r *UserRepository) Anonymize(ctx context.Context, user *models.User, name, email string) (bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(usersCollection)
+	result, err := collection.UpdateOne(ctx,
+		bson.M{
+			"_id":          user.ID,
+			"deactivateAt": bson.M{"$lte": time.Now()},
+			"status":       bson.M{"$ne": models.StatusDeactivated},
+		},
+		bson.M{
+			"$set": bson.M{
+				"email":        email,
+				"name":         name,
+				"passwordHash": "",
+				"status":       models.StatusDeactivated,
+				"updatedAt":    time.Now(),
+			},
+			"$unset": bson.M{"quietHours": "", "catchUpEmail": "", "deactivateAt": ""},
+		},
+	)
+	if err != nil {
+		return false, dbErr(err)
+	}
+
+	r.invalidateUserCache(user.ID.Hex(), user.Email)
+	if result.ModifiedCount == 0 {
+		return false, nil
+	}
+	r.fireWrite()
+	return true, nil
+}
+
 // CountByRole counts users by role.
 func (r *UserRepository) CountByRole(ctx context.Context, role models.UserRole) (int64, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
 	collection := r.db.Collection(usersCollection)
-	return collection.CountDocuments(ctx, bson.M{"role": role})
+	count, err := collection.CountDocuments(ctx, bson.M{"role": role})
+	return count, dbErr(err)
 }
 
 // ExistsAdmin checks if an admin user exists.
@@ -297,18 +420,30 @@ func (r *UserRepository) ExistsAdmin(ctx context.Context) (bool, error) {
 	return count > 0, err
 }
 
+// Warm loads the approved users into the cache, so the first requests after
+// a start don't all go to the database. It returns how many were loaded.
+func (r *UserRepository) Warm(ctx context.Context) (int, error) {
+	status := models.StatusApproved
+	users, err := r.FindAll(ctx, &status, nil)
+	return len(users), err
+}
+
 // cacheUser caches a user by both ID and email.
 func (r *UserRepository) cacheUser(user *models.User) {
-	r.cache.Set(userByIDPrefix+user.ID.Hex(), user)
-	r.cache.Set(userByEmailPrefix+user.Email, user)
+	r.users.set(userByID.key(user.ID.Hex()), user)
+	r.users.set(userByEmail.key(user.Email), user)
 }
 
-// invalidateUserCache invalidates user cache by ID.
-func (r *UserRepository) invalidateUserCache(userID string) {
-	r.cache.Delete(userByIDPrefix + userID)
+// invalidateUserCache invalidates the user cache by ID and, when known, by
+// email, which logins read through.
+func (r *UserRepository) invalidateUserCache(userID, email string) {
+	r.users.delete(userByID.key(userID))
+	if email != "" {
+		r.users.delete(userByEmail.key(email))
+	}
 }
 
 // ClearCache clears all cached users.
 func (r *UserRepository) ClearCache() {
-	r.cache.Clear()
+	r.users.clear()
 }
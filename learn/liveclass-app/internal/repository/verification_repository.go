@@ -0,0 +1,159 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const verificationsCollection = "identity_verifications"
+
+// Verification errors
+var (
+	ErrVerificationNotFound = errors.New("verification not found")
+)
+
+// VerificationRepository handles identity verification persistence.
+type VerificationRepository struct {
+	db *database.MongoDB
+}
+
+// NewVerificationRepository creates a new VerificationRepository.
+func NewVerificationRepository(db *database.MongoDB) *VerificationRepository {
+	return &VerificationRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the verifications collection.
+func (r *VerificationRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(verificationsCollection)
+
+	indexes := []mongo.IndexModel{
+		// One verification per student per class
+		{
+			Keys:    bson.D{{Key: "scheduleId", Value: 1}, {Key: "studentId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Provider webhook lookups
+		{
+			Keys:    bson.D{{Key: "reference", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Save creates or replaces the student's verification for a class.
+func (r *VerificationRepository) Save(ctx context.Context, v *models.IdentityVerification) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(verificationsCollection)
+
+	now := time.Now()
+	if v.ID.IsZero() {
+		v.ID = primitive.NewObjectID()
+		v.CreatedAt = now
+	}
+	v.UpdatedAt = now
+
+	filter := bson.M{"scheduleId": v.ScheduleID, "studentId": v.StudentID}
+	existing := &models.IdentityVerification{}
+	if err := collection.FindOne(ctx, filter).Decode(existing); err == nil {
+		v.ID = existing.ID
+		v.CreatedAt = existing.CreatedAt
+	}
+
+	_, err := collection.ReplaceOne(ctx, filter, v, options.Replace().SetUpsert(true))
+	return dbErr(err)
+}
+
+// FindByScheduleAndStudent returns a student's verification for a class.
+func (r *VerificationRepository) FindByScheduleAndStudent(ctx context.Context, scheduleID, studentID primitive.ObjectID) (*models.IdentityVerification, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(verificationsCollection)
+
+	v := &models.IdentityVerification{}
+	err := collection.FindOne(ctx, bson.M{"scheduleId": scheduleID, "studentId": studentID}).Decode(v)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrVerificationNotFound
+	}
+	return v, dbErr(err)
+}
+
+// FindByReference returns the verification started with a provider reference.
+func (r *VerificationRepository) FindByReference(ctx context.Context, provider, reference string) (*models.IdentityVerification, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(verificationsCollection)
+
+	v := &models.IdentityVerification{}
+	err := collection.FindOne(ctx, bson.M{"provider": provider, "reference": reference}).Decode(v)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrVerificationNotFound
+	}
+	return v, dbErr(err)
+}
+
+// FindBySchedule returns all verifications for a class, oldest first.
+func (r *VerificationRepository) FindBySchedule(ctx context.Context, scheduleID primitive.ObjectID) ([]models.IdentityVerification, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(verificationsCollection)
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"scheduleId": scheduleID}, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	verifications := []models.IdentityVerification{}
+	if err := cursor.All(ctx, &verifications); err != nil {
+		return nil, dbErr(err)
+	}
+	return verifications, nil
+}
+
+// UpdateStatus sets the outcome of a verification.
+func (r *VerificationRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status models.VerificationStatus, reason string, reviewerID primitive.ObjectID) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(verificationsCollection)
+
+	now := time.Now()
+	set := bson.M{
+		"status":    status,
+		"reason":    reason,
+		"updatedAt": now,
+	}
+	if status == models.VerificationVerified {
+		set["verifiedAt"] = now
+	}
+	if !reviewerID.IsZero() {
+		set["reviewedBy"] = reviewerID
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrVerificationNotFound
+	}
+	return nil
+}
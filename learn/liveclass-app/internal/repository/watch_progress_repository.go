@@ -0,0 +1,131 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const watchProgressCollection = "watch_progress"
+
+// Watch progress errors
+var (
+	ErrWatchProgressNotFound = errors.New("watch progress not found")
+	// ErrWatchProgressChanged is returned when saving progress that another
+	// heartbeat updated since it was loaded.
+	ErrWatchProgressChanged = errors.New("watch progress changed concurrently")
+)
+
+// WatchProgressRepository handles students' verified watching of recordings.
+type WatchProgressRepository struct {
+	db *database.MongoDB
+}
+
+// NewWatchProgressRepository creates a new WatchProgressRepository.
+func NewWatchProgressRepository(db *database.MongoDB) *WatchProgressRepository {
+	return &WatchProgressRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the watch progress collection.
+func (r *WatchProgressRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(watchProgressCollection)
+
+	indexes := []mongo.IndexModel{
+		// One entry per student and recording
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "recordingId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Engagement with a recording
+		{Keys: bson.D{{Key: "recordingId", Value: 1}}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Find returns a student's progress through a recording.
+func (r *WatchProgressRepository) Find(ctx context.Context, userID, recordingID primitive.ObjectID) (*models.WatchProgress, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(watchProgressCollection)
+
+	var progress models.WatchProgress
+	err := collection.FindOne(ctx, bson.M{"userId": userID, "recordingId": recordingID}).Decode(&progress)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrWatchProgressNotFound
+		}
+		return nil, dbErr(err)
+	}
+	return &progress, nil
+}
+
+// Save stores progress loaded with its previous heartbeat time, or new
+// progress with an empty ID. It returns ErrWatchProgressChanged if another
+// heartbeat got there first, so concurrent players can't both be credited
+// for the same time.
+func (r *WatchProgressRepository) Save(ctx context.Context, progress *models.WatchProgress, previousHeartbeat time.Time) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(watchProgressCollection)
+	progress.UpdatedAt = time.Now()
+
+	if progress.ID.IsZero() {
+		progress.ID = primitive.NewObjectID()
+		progress.CreatedAt = progress.UpdatedAt
+		_, err := collection.InsertOne(ctx, progress)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrWatchProgressChanged
+		}
+		return dbErr(err)
+	}
+
+	result, err := collection.ReplaceOne(ctx, bson.M{"_id": progress.ID, "heartbeatAt": previousHeartbeat}, progress)
+	if err != nil {
+		return dbErr(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrWatchProgressChanged
+	}
+	return nil
+}
+
+// FindByUser returns a student's progress through all recordings.
+func (r *WatchProgressRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) ([]models.WatchProgress, error) {
+	return r.find(ctx, r.db.Collection(watchProgressCollection), bson.M{"userId": userID})
+}
+
+// FindByRecording returns every student's progress through a recording,
+// read from the report read preference.
+func (r *WatchProgressRepository) FindByRecording(ctx context.Context, recordingID primitive.ObjectID) ([]models.WatchProgress, error) {
+	return r.find(ctx, r.db.ReportCollection(watchProgressCollection), bson.M{"recordingId": recordingID})
+}
+
+// find returns the progress entries in collection matching filter.
+func (r *WatchProgressRepository) find(ctx context.Context, collection *mongo.Collection, filter bson.M) ([]models.WatchProgress, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	progress := []models.WatchProgress{}
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, dbErr(err)
+	}
+	return progress, nil
+}
@@ -0,0 +1,168 @@
+// Package repository provides data access operations.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const webhookEventsCollection = "webhook_events"
+
+// WebhookEventRepository handles events received by the webhook inbox.
+type WebhookEventRepository struct {
+	db *database.MongoDB
+}
+
+// NewWebhookEventRepository creates a new WebhookEventRepository.
+func NewWebhookEventRepository(db *database.MongoDB) *WebhookEventRepository {
+	return &WebhookEventRepository{db: db}
+}
+
+// CreateIndexes creates necessary indexes for the webhook_events collection.
+func (r *WebhookEventRepository) CreateIndexes(ctx context.Context) error {
+	collection := r.db.Collection(webhookEventsCollection)
+
+	indexes := []mongo.IndexModel{
+		// One record per event, so deliveries can't race each other
+		{
+			Keys:    bson.D{{Key: "source", Value: 1}, {Key: "eventId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Inbox listing, newest first
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "receivedAt", Value: -1}}},
+		// Drop records after the retention period
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// Claim records an event as being processed and reports whether the caller
+// should process it. New events are claimed, as are failed ones being
+// retried and ones whose processing started staleAfter ago without
+// finishing. Otherwise the stored event is returned unclaimed: processed,
+// or in progress on another request.
+func (r *WebhookEventRepository) Claim(ctx context.Context, event *models.WebhookEvent, staleAfter time.Duration) (*models.WebhookEvent, bool, error) {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(webhookEventsCollection)
+
+	now := time.Now()
+	event.ID = primitive.NewObjectID()
+	event.Status = models.WebhookEventProcessing
+	event.Attempts = 1
+	event.ReceivedAt = now
+	event.ClaimedAt = now
+
+	_, err := collection.InsertOne(ctx, event)
+	if err == nil {
+		return event, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, dbErr(err)
+	}
+
+	filter := bson.M{
+		"source":  event.Source,
+		"eventId": event.EventID,
+		"$or": bson.A{
+			bson.M{"status": models.WebhookEventFailed},
+			bson.M{"status": models.WebhookEventProcessing, "claimedAt": bson.M{"$lt": now.Add(-staleAfter)}},
+		},
+	}
+	update := bson.M{
+		"$set":   bson.M{"status": models.WebhookEventProcessing, "claimedAt": now, "expiresAt": event.ExpiresAt},
+		"$unset": bson.M{"error": ""},
+		"$inc":   bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var stored models.WebhookEvent
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&stored)
+	if err == nil {
+		return &stored, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, dbErr(err)
+	}
+
+	if err := collection.FindOne(ctx, bson.M{"source": event.Source, "eventId": event.EventID}).Decode(&stored); err != nil {
+		return nil, false, dbErr(err)
+	}
+	return &stored, false, nil
+}
+
+// Complete marks a claimed event as processed with the action's result.
+func (r *WebhookEventRepository) Complete(ctx context.Context, id primitive.ObjectID, result map[string]interface{}) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(webhookEventsCollection)
+
+	_, err := collection.UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{
+			"status":      models.WebhookEventProcessed,
+			"result":      result,
+			"processedAt": time.Now(),
+		},
+	})
+	return dbErr(err)
+}
+
+// Fail marks a claimed event as failed, so a retry processes it again.
+func (r *WebhookEventRepository) Fail(ctx context.Context, id primitive.ObjectID, reason string) error {
+	ctx, cancel := r.db.WriteContext(ctx)
+	defer cancel()
+
+	collection := r.db.Collection(webhookEventsCollection)
+
+	_, err := collection.UpdateByID(ctx, id, bson.M{
+		"$set": bson.M{
+			"status": models.WebhookEventFailed,
+			"error":  reason,
+		},
+	})
+	return dbErr(err)
+}
+
+// FindRecent returns the newest events, optionally of one source and status.
+// It reads from the report read preference.
+func (r *WebhookEventRepository) FindRecent(ctx context.Context, source string, status models.WebhookEventStatus, limit int) ([]models.WebhookEvent, error) {
+	ctx, cancel := r.db.ReadContext(ctx)
+	defer cancel()
+
+	collection := r.db.ReportCollection(webhookEventsCollection)
+
+	filter := bson.M{}
+	if source != "" {
+		filter["source"] = source
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "receivedAt", Value: -1}}).SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, dbErr(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.WebhookEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, dbErr(err)
+	}
+	return events, nil
+}
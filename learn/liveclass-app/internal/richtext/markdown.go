@@ -0,0 +1,138 @@
+package richtext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Markdown renders a Markdown subset to HTML sanitized by the policy.
+//
+// Supported: paragraphs (single line breaks become <br>), # headings (h3-h6), > block
+// quotes, - * + and 1. lists, --- rules, ``` fenced code, `code`, **bold**,
+// *italic* / _italic_, ~~strikethrough~~ and [links](url). Raw HTML in the
+// source is escaped rather than passed through.
+func (p *Policy) Markdown(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var b strings.Builder
+	var para []string
+	list := "" // "ul" or "ol" while inside a list
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + strings.Join(para, "<br>") + "</p>")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">")
+			list = ""
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushPara()
+			closeList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>")
+
+		case trimmed == "":
+			flushPara()
+			closeList()
+
+		case headingPattern.MatchString(trimmed):
+			flushPara()
+			closeList()
+			// Headings start at h3 as the content is shown inside a page
+			m := headingPattern.FindStringSubmatch(trimmed)
+			tag := "h" + string(rune('0'+min(len(m[1])+2, 6)))
+			b.WriteString("<" + tag + ">" + inline(m[2]) + "</" + tag + ">")
+
+		case rulePattern.MatchString(trimmed):
+			flushPara()
+			closeList()
+			b.WriteString("<hr>")
+
+		case strings.HasPrefix(trimmed, ">"):
+			flushPara()
+			closeList()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				text := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, inline(strings.TrimSpace(text)))
+			}
+			i--
+			b.WriteString("<blockquote><p>" + strings.Join(quote, "<br>") + "</p></blockquote>")
+
+		case listItemPattern.MatchString(trimmed):
+			flushPara()
+			m := listItemPattern.FindStringSubmatch(trimmed)
+			kind := "ul"
+			if m[2] != "" {
+				kind = "ol"
+			}
+			if list != kind {
+				closeList()
+				b.WriteString("<" + kind + ">")
+				list = kind
+			}
+			b.WriteString("<li>" + inline(m[3]) + "</li>")
+
+		default:
+			closeList()
+			para = append(para, inline(trimmed))
+		}
+	}
+	flushPara()
+	closeList()
+
+	return p.Sanitize(b.String())
+}
+
+// Block patterns
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	rulePattern     = regexp.MustCompile(`^(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	listItemPattern = regexp.MustCompile(`^(?:([-*+])|(\d{1,9})[.)])\s+(.*)$`)
+)
+
+// Inline patterns, applied to escaped text
+var (
+	codeSpanPattern = regexp.MustCompile("`([^`]+)`")
+	linkPattern     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	emPattern       = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	delPattern      = regexp.MustCompile(`~~([^~]+)~~`)
+)
+
+// inline escapes text and renders inline formatting. Code spans are set aside
+// first so formatting characters inside them are kept literally.
+func inline(text string) string {
+	var spans []string
+	text = strings.ReplaceAll(text, "\x00", "") // Used as the code span placeholder
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(m string) string {
+		spans = append(spans, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return "\x00"
+	})
+
+	text = html.EscapeString(text)
+	text = linkPattern.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = strongPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emPattern.ReplaceAllString(text, "<em>$1$2</em>")
+	text = delPattern.ReplaceAllString(text, "<del>$1</del>")
+
+	for _, span := range spans {
+		text = strings.Replace(text, "\x00", span, 1)
+	}
+	return text
+}
@@ -0,0 +1,200 @@
+// Package richtext sanitizes user-supplied HTML against tag allowlists and
+// renders a Markdown subset to sanitized HTML.
+package richtext
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// safeTags lists every tag an allowlist may enable, with the attributes kept
+// on it. Tags outside this set (script, img, iframe, ...) can't be enabled by
+// configuration.
+var safeTags = map[string][]string{
+	"p": nil, "br": nil, "hr": nil,
+	"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil, "s": nil, "del": nil,
+	"sub": nil, "sup": nil, "code": nil, "pre": nil, "blockquote": nil,
+	"ul": nil, "ol": nil, "li": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"a": {"href", "title"},
+}
+
+// voidTags have no closing tag.
+var voidTags = map[string]bool{"br": true, "hr": true}
+
+// droppedContent are elements removed together with everything inside them.
+var droppedContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "noembed": true, "noframes": true, "template": true,
+	"textarea": true, "title": true, "xmp": true, "svg": true, "math": true,
+	"select": true,
+}
+
+// Default allowlists
+var (
+	// RichTags are allowed in descriptions and rendered Markdown.
+	RichTags = []string{
+		"p", "br", "hr", "strong", "b", "em", "i", "u", "s", "del", "sub", "sup",
+		"code", "pre", "blockquote", "ul", "ol", "li", "h3", "h4", "h5", "h6", "a",
+	}
+	// ChatTags are allowed in chat and direct messages.
+	ChatTags = []string{"br", "strong", "b", "em", "i", "s", "del", "code", "a"}
+	// URLSchemes are allowed in link targets; relative links are always allowed.
+	URLSchemes = []string{"http", "https", "mailto"}
+)
+
+// Policy sanitizes HTML against an allowlist of tags and link schemes.
+type Policy struct {
+	tags    map[string][]string
+	schemes map[string]bool
+}
+
+// NewPolicy creates a policy allowing the given tags and link schemes. Tags
+// that can't be made safe are ignored with a warning.
+func NewPolicy(tags, schemes []string) *Policy {
+	p := &Policy{
+		tags:    make(map[string][]string, len(tags)),
+		schemes: make(map[string]bool, len(schemes)),
+	}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		attrs, ok := safeTags[tag]
+		if !ok {
+			log.Printf("[RichText] Ignoring tag %q, it can't be allowed safely", tag)
+			continue
+		}
+		p.tags[tag] = attrs
+	}
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(strings.TrimSpace(scheme))] = true
+	}
+	return p
+}
+
+// Policies used by the server; replaced at startup by Configure.
+var (
+	Rich = NewPolicy(RichTags, URLSchemes)
+	Chat = NewPolicy(ChatTags, URLSchemes)
+)
+
+// Configure replaces the Rich and Chat policies. Empty lists keep the defaults.
+func Configure(richTags, chatTags, schemes []string) {
+	if len(richTags) == 0 {
+		richTags = RichTags
+	}
+	if len(chatTags) == 0 {
+		chatTags = ChatTags
+	}
+	if len(schemes) == 0 {
+		schemes = URLSchemes
+	}
+	Rich = NewPolicy(richTags, schemes)
+	Chat = NewPolicy(chatTags, schemes)
+}
+
+// Sanitize returns s with disallowed tags, attributes, comments and link
+// targets removed. Text of removed tags is kept, except inside elements such
+// as script and style which are dropped whole. Unclosed tags are closed.
+//
+// Only "<" is escaped in text, so plain text without markup comes back
+// unchanged and still reads correctly where it is shown as text.
+func (p *Policy) Sanitize(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+
+	z := html.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	var open []string // Allowed elements still open, innermost last
+	dropping := 0     // Depth inside dropped-content elements
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				b.WriteString("</" + open[i] + ">")
+			}
+			return b.String()
+
+		case html.TextToken:
+			if dropping == 0 {
+				b.WriteString(strings.ReplaceAll(string(z.Text()), "<", "&lt;"))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedContent[tok.Data] {
+				if tt == html.StartTagToken {
+					dropping++
+				}
+				continue
+			}
+			attrs, ok := p.tags[tok.Data]
+			if dropping > 0 || !ok {
+				continue
+			}
+			b.WriteString("<" + tok.Data)
+			for _, attr := range tok.Attr {
+				if !contains(attrs, attr.Key) || (attr.Key == "href" && !p.allowedURL(attr.Val)) {
+					continue
+				}
+				b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+			}
+			if tok.Data == "a" {
+				b.WriteString(` rel="nofollow noopener noreferrer"`)
+			}
+			b.WriteString(">")
+			if !voidTags[tok.Data] {
+				open = append(open, tok.Data)
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if droppedContent[tag] {
+				if dropping > 0 {
+					dropping--
+				}
+				continue
+			}
+			if dropping > 0 {
+				continue
+			}
+			// Close back to the matching open element, if any
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tag {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+		// Comments and doctypes are dropped
+	}
+}
+
+// allowedURL reports whether a link target is relative or uses an allowed scheme.
+func (p *Policy) allowedURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || p.schemes[strings.ToLower(u.Scheme)]
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package room
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+)
+
+// Acknowledged signaling metrics, by message type.
+var (
+	signalingRetransmits = metrics.NewCounterVec(
+		"liveclass_signaling_retransmits_total",
+		"Critical signaling messages sent again for lack of an acknowledgement.",
+		"type",
+	)
+	signalingUnacked = metrics.NewCounterVec(
+		"liveclass_signaling_unacked_total",
+		"Critical signaling messages given up on after the last retransmit.",
+		"type",
+	)
+)
+
+// AckPolicy controls retransmits of critical signaling messages (offers,
+// answers, stream availability) to clients that acknowledge them.
+type AckPolicy struct {
+	// Timeout is how long to wait for an acknowledgement before the first
+	// retransmit; it doubles after each one.
+	Timeout time.Duration
+	// MaxRetransmits bounds the retransmits of one message.
+	MaxRetransmits int
+}
+
+// criticalMessage is the wire form of a critical signaling message.
+type criticalMessage struct {
+	Type    string          `json:"type"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// pendingAck is a critical message waiting to be acknowledged.
+type pendingAck struct {
+	seq         uint64
+	data        []byte
+	retransmits int
+	timer       *time.Timer
+}
+
+// signaling tracks a participant's acknowledged signaling. Messages carry
+// increasing sequence numbers in each direction.
+type signaling struct {
+	mu       sync.Mutex
+	policy   *AckPolicy             // nil when the client doesn't acknowledge
+	nextSeq  uint64                 // Of the next message sent
+	pending  map[string]*pendingAck // By message type; a newer message replaces an older one
+	received uint64                 // Highest sequence number received
+}
+
+// SetAcks starts acknowledged signaling with the participant's current
+// connection under policy, or stops it if policy is nil. Messages pending
+// from an earlier connection are dropped.
+func (p *Participant) SetAcks(policy *AckPolicy) {
+	s := &p.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopLocked()
+	if policy != nil && policy.Timeout <= 0 {
+		policy = nil
+	}
+	s.policy = policy
+	s.received = 0
+}
+
+// AcksEnabled reports whether the participant acknowledges critical messages.
+func (p *Participant) AcksEnabled() bool {
+	p.signaling.mu.Lock()
+	defer p.signaling.mu.Unlock()
+
+	return p.signaling.policy != nil
+}
+
+// SendCritical sends a critical signaling message. To a client that
+// acknowledges messages it carries a sequence number and is sent again until
+// acknowledged, replacing any unacknowledged message of the same type.
+func (p *Participant) SendCritical(msgType string, payload json.RawMessage) {
+	s := &p.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conn := p.Conn
+	if s.policy == nil {
+		if conn != nil {
+			data, _ := json.Marshal(criticalMessage{Type: msgType, Payload: payload})
+			conn.Send(data)
+		}
+		return
+	}
+
+	s.nextSeq++
+	data, _ := json.Marshal(criticalMessage{Type: msgType, Seq: s.nextSeq, Payload: payload})
+	if old := s.pending[msgType]; old != nil {
+		old.timer.Stop()
+	}
+	pending := &pendingAck{seq: s.nextSeq, data: data}
+	pending.timer = time.AfterFunc(s.policy.Timeout, func() { p.retransmit(msgType, pending) })
+	if s.pending == nil {
+		s.pending = make(map[string]*pendingAck)
+	}
+	s.pending[msgType] = pending
+
+	if conn != nil {
+		conn.Send(data)
+	}
+}
+
+// retransmit sends a pending message again, or gives up on it once the
+// policy's retransmits are spent or the connection is gone.
+func (p *Participant) retransmit(msgType string, pending *pendingAck) {
+	s := &p.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Acknowledged, replaced or stopped meanwhile
+	if s.pending[msgType] != pending || s.policy == nil {
+		return
+	}
+	conn := p.Conn
+	if conn == nil || !conn.Alive() {
+		delete(s.pending, msgType)
+		return
+	}
+	if pending.retransmits >= s.policy.MaxRetransmits {
+		delete(s.pending, msgType)
+		signalingUnacked.WithLabelValues(msgType).Inc()
+		log.Printf("[Room] %s to participant %s never acknowledged", msgType, p.ID)
+		return
+	}
+
+	pending.retransmits++
+	signalingRetransmits.WithLabelValues(msgType).Inc()
+	conn.Send(pending.data)
+	pending.timer = time.AfterFunc(s.policy.Timeout<<pending.retransmits, func() { p.retransmit(msgType, pending) })
+}
+
+// Ack records the client's acknowledgement of the message numbered seq.
+func (p *Participant) Ack(seq uint64) {
+	s := &p.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for msgType, pending := range s.pending {
+		if pending.seq == seq {
+			pending.timer.Stop()
+			delete(s.pending, msgType)
+			return
+		}
+	}
+}
+
+// ReceiveCritical records a critical message numbered seq from the client,
+// reporting whether it is new rather than a retransmit of one already
+// handled. Messages without a sequence number are always new.
+func (p *Participant) ReceiveCritical(seq uint64) bool {
+	if seq == 0 {
+		return true
+	}
+
+	s := &p.signaling
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seq <= s.received {
+		return false
+	}
+	s.received = seq
+	return true
+}
+
+// stopLocked drops pending messages. Callers must hold s.mu.
+func (s *signaling) stopLocked() {
+	for _, pending := range s.pending {
+		pending.timer.Stop()
+	}
+	s.pending = nil
+}
+
+// BroadcastCriticalToViewers sends a critical signaling message to all
+// non-presenter participants.
+func (r *Room) BroadcastCriticalToViewers(msgType string, payload json.RawMessage) {
+	for _, viewer := range r.GetAllViewers() {
+		viewer.SendCritical(msgType, payload)
+	}
+}
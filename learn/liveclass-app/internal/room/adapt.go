@@ -0,0 +1,53 @@
+package room
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// UplinkAdaptation asks a presenter on a lossy uplink to send less video so
+// their audio stays intelligible. Level 0 means no reduction.
+type UplinkAdaptation struct {
+	Level          int       `json:"level"`
+	MaxHeight      int       `json:"maxHeight,omitempty"`
+	MaxFramerate   int       `json:"maxFramerate,omitempty"`
+	MaxBitrateKbps int       `json:"maxBitrateKbps,omitempty"`
+	ProtectAudio   bool      `json:"protectAudio"`
+	LossPercent    float64   `json:"lossPercent"` // Uplink loss in the sample that triggered the change
+	Since          time.Time `json:"since"`
+}
+
+// SetUplinkAdaptation records the presenter's adaptation state and sends it to
+// the presenter as an "uplink-adaptation" hint. Level 0 clears it.
+func (r *Room) SetUplinkAdaptation(a UplinkAdaptation) {
+	r.mu.Lock()
+	if a.Level == 0 {
+		r.uplinkAdaptation = nil
+	} else {
+		r.uplinkAdaptation = &a
+	}
+	r.mu.Unlock()
+
+	if data, err := json.Marshal(map[string]interface{}{
+		"type":    "uplink-adaptation",
+		"payload": a,
+	}); err == nil {
+		r.SendToPresenter(data)
+	}
+
+	log.Printf("[Room %s] Presenter uplink adaptation level %d (%.1f%% loss)", r.ID, a.Level, a.LossPercent)
+}
+
+// UplinkAdaptation returns the presenter's current adaptation, or nil if the
+// uplink is healthy.
+func (r *Room) UplinkAdaptation() *UplinkAdaptation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.uplinkAdaptation == nil {
+		return nil
+	}
+	a := *r.uplinkAdaptation
+	return &a
+}
@@ -0,0 +1,61 @@
+package room
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// SetCaptionLanguage sets the language a participant wants captions in. An
+// empty language means the presenter's original captions.
+func (r *Room) SetCaptionLanguage(participantID, lang string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.Participants[participantID]; ok {
+		p.captionLang = lang
+	}
+}
+
+// CaptionLanguages returns the distinct caption languages participants have
+// selected, other than source.
+func (r *Room) CaptionLanguages(source string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var langs []string
+	for _, p := range r.Participants {
+		if p.captionLang == "" || p.captionLang == source || seen[p.captionLang] {
+			continue
+		}
+		seen[p.captionLang] = true
+		langs = append(langs, p.captionLang)
+	}
+	return langs
+}
+
+// SendCaption sends a caption to the participants following lang. Captions in
+// the source language also go to everyone who hasn't picked a language.
+func (r *Room) SendCaption(message interface{}, lang, source string) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[Room %s] Error marshaling caption: %v", r.ID, err)
+		return
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.Participants {
+		if p.Conn == nil || p.IsPresenter {
+			continue
+		}
+		want := p.captionLang
+		if want == "" {
+			want = source
+		}
+		if want == lang {
+			p.Conn.Send(data)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package room
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// TypingThrottle is the minimum interval between relayed typing events per participant.
+	TypingThrottle = 2 * time.Second
+	// ReceiptFlushInterval is how often aggregated read receipt counts are broadcast.
+	ReceiptFlushInterval = 1 * time.Second
+	// maxTrackedMessages bounds the number of chat messages tracked for receipts.
+	maxTrackedMessages = 500
+)
+
+// ChatActivity tracks ephemeral chat signals (typing indicators and read receipts)
+// for a room. Receipts are aggregated into per-message counts and flushed on an
+// interval so a large class doesn't turn every read into a broadcast.
+type ChatActivity struct {
+	lastTyping map[string]time.Time
+	senders    map[string]string              // messageID -> senderID
+	readers    map[string]map[string]struct{} // messageID -> reader participant IDs
+	order      []string                       // message IDs, oldest first
+	pending    map[string]struct{}            // message IDs with unflushed receipt changes
+	flushing   bool
+	mu         sync.Mutex
+}
+
+// NewChatActivity creates an empty chat activity tracker.
+func NewChatActivity() *ChatActivity {
+	return &ChatActivity{
+		lastTyping: make(map[string]time.Time),
+		senders:    make(map[string]string),
+		readers:    make(map[string]map[string]struct{}),
+		pending:    make(map[string]struct{}),
+	}
+}
+
+// AllowTyping reports whether a typing event from the participant should be relayed.
+func (c *ChatActivity) AllowTyping(participantID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if last, ok := c.lastTyping[participantID]; ok && now.Sub(last) < TypingThrottle {
+		return false
+	}
+	c.lastTyping[participantID] = now
+	return true
+}
+
+// TrackMessage registers a chat message so read receipts can be counted for it.
+func (c *ChatActivity) TrackMessage(messageID, senderID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.senders[messageID] = senderID
+	c.readers[messageID] = make(map[string]struct{})
+	c.order = append(c.order, messageID)
+
+	// Forget the oldest messages once the window is full
+	for len(c.order) > maxTrackedMessages {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.senders, oldest)
+		delete(c.readers, oldest)
+		delete(c.pending, oldest)
+	}
+}
+
+// MarkRead records that a participant has read a message. It returns true if
+// the caller should schedule a flush of pending receipt counts.
+func (c *ChatActivity) MarkRead(messageID, participantID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	readers, ok := c.readers[messageID]
+	if !ok || c.senders[messageID] == participantID {
+		return false
+	}
+	if _, already := readers[participantID]; already {
+		return false
+	}
+	readers[participantID] = struct{}{}
+	c.pending[messageID] = struct{}{}
+
+	if c.flushing {
+		return false
+	}
+	c.flushing = true
+	return true
+}
+
+// TakePendingCounts returns the current read counts for messages that changed
+// since the last flush and resets the pending set.
+func (c *ChatActivity) TakePendingCounts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[string]int, len(c.pending))
+	for id := range c.pending {
+		counts[id] = len(c.readers[id])
+	}
+	c.pending = make(map[string]struct{})
+	c.flushing = false
+	return counts
+}
+
+// Has reports whether a message is still tracked.
+func (c *ChatActivity) Has(messageID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.senders[messageID]
+	return ok
+}
+
+// Remove stops tracking a deleted message. It returns false if the message is unknown.
+func (c *ChatActivity) Remove(messageID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.senders[messageID]; !ok {
+		return false
+	}
+	delete(c.senders, messageID)
+	delete(c.readers, messageID)
+	delete(c.pending, messageID)
+	return true
+}
+
+// Forget drops per-participant typing state when a participant leaves.
+func (c *ChatActivity) Forget(participantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.lastTyping, participantID)
+}
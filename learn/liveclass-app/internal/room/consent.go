@@ -0,0 +1,197 @@
+package room
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	// ErrConsentNotRequested is returned when starting a recording before
+	// students were asked for consent.
+	ErrConsentNotRequested = errors.New("ask students for recording consent before recording")
+	// ErrRecordingInProgress is returned when asking for consent again while
+	// recording, which would reset the answers the recording is made under.
+	ErrRecordingInProgress = errors.New("a recording is in progress")
+)
+
+// consentRequest is the recording consent request in effect in a room.
+type consentRequest struct {
+	id                   string
+	excludeNonConsenting bool
+	requestedBy          string
+	requestedAt          time.Time
+	answers              map[string]bool // Participant ID -> consented
+}
+
+// ConsentPrompt asks a student for consent to record the class.
+type ConsentPrompt struct {
+	RequestID            string    `json:"requestId"`
+	RequestedBy          string    `json:"requestedBy"`
+	ExcludeNonConsenting bool      `json:"excludeNonConsenting"` // Declining keeps the student's audio out of the recording
+	RequestedAt          time.Time `json:"requestedAt"`
+}
+
+// ConsentStatus is the presenter's view of the answers from the students
+// currently in the room.
+type ConsentStatus struct {
+	RequestID            string            `json:"requestId"`
+	ExcludeNonConsenting bool              `json:"excludeNonConsenting"`
+	Recording            bool              `json:"recording"`
+	Consented            []ParticipantInfo `json:"consented"`
+	Declined             []ParticipantInfo `json:"declined"`
+	Pending              []ParticipantInfo `json:"pending"`
+	// Participants whose audio the recorder must leave out, when excluding
+	// non-consenting students. Students who haven't answered are excluded.
+	Excluded []string `json:"excluded"`
+}
+
+// RequestRecordingConsent starts a new consent request, discarding earlier
+// answers, and sends a "recording-consent-request" prompt to every student.
+func (r *Room) RequestRecordingConsent(id, requestedBy string, excludeNonConsenting bool) error {
+	r.mu.Lock()
+	if r.recording {
+		r.mu.Unlock()
+		return ErrRecordingInProgress
+	}
+	r.consent = &consentRequest{
+		id:                   id,
+		excludeNonConsenting: excludeNonConsenting,
+		requestedBy:          requestedBy,
+		requestedAt:          time.Now(),
+		answers:              make(map[string]bool),
+	}
+	prompt := r.consent.prompt()
+	r.mu.Unlock()
+
+	r.BroadcastToViewers(map[string]interface{}{
+		"type":    "recording-consent-request",
+		"payload": prompt,
+	})
+
+	log.Printf("[Room %s] Recording consent requested by %s (exclude non-consenting: %v)", r.ID, requestedBy, excludeNonConsenting)
+	return nil
+}
+
+// RecordConsent records a student's answer to the consent request requestID.
+// It returns false if that request is no longer in effect.
+func (r *Room) RecordConsent(participantID, requestID string, consented bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.consent == nil || r.consent.id != requestID {
+		return false
+	}
+	r.consent.answers[participantID] = consented
+	return true
+}
+
+// ConsentPrompt returns the prompt a student joining now should answer, or
+// nil if there is no request or they already answered it.
+func (r *Room) ConsentPrompt(p *Participant) *ConsentPrompt {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.consent == nil || p.IsPresenter {
+		return nil
+	}
+	if _, answered := r.consent.answers[p.ID]; answered {
+		return nil
+	}
+	prompt := r.consent.prompt()
+	return &prompt
+}
+
+// ConsentStatus returns the answers to the current consent request, or nil
+// if consent wasn't requested.
+func (r *Room) ConsentStatus() *ConsentStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.consent == nil {
+		return nil
+	}
+
+	status := &ConsentStatus{
+		RequestID:            r.consent.id,
+		ExcludeNonConsenting: r.consent.excludeNonConsenting,
+		Recording:            r.recording,
+		Consented:            make([]ParticipantInfo, 0),
+		Declined:             make([]ParticipantInfo, 0),
+		Pending:              make([]ParticipantInfo, 0),
+		Excluded:             make([]string, 0),
+	}
+	for _, p := range r.Participants {
+		if p.IsPresenter {
+			continue
+		}
+		consented, answered := r.consent.answers[p.ID]
+		switch {
+		case !answered:
+			status.Pending = append(status.Pending, p.Info())
+		case consented:
+			status.Consented = append(status.Consented, p.Info())
+		default:
+			status.Declined = append(status.Declined, p.Info())
+		}
+		if status.ExcludeNonConsenting && !consented {
+			status.Excluded = append(status.Excluded, p.ID)
+		}
+	}
+	return status
+}
+
+// SendConsentStatus sends the current answers to the presenter as a
+// "recording-consent-status" message.
+func (r *Room) SendConsentStatus() {
+	status := r.ConsentStatus()
+	if status == nil {
+		return
+	}
+	if data, err := json.Marshal(map[string]interface{}{
+		"type":    "recording-consent-status",
+		"payload": status,
+	}); err == nil {
+		r.SendToPresenter(data)
+	}
+}
+
+// SetRecording marks the class as being recorded or not and tells everyone
+// with a "recording-state" message. Recording can only start once students
+// were asked for consent.
+func (r *Room) SetRecording(recording bool) error {
+	r.mu.Lock()
+	if recording && r.consent == nil {
+		r.mu.Unlock()
+		return ErrConsentNotRequested
+	}
+	r.recording = recording
+	r.mu.Unlock()
+
+	r.BroadcastToAll(map[string]interface{}{
+		"type":    "recording-state",
+		"payload": map[string]bool{"recording": recording},
+	}, "")
+
+	log.Printf("[Room %s] Recording: %v", r.ID, recording)
+	return nil
+}
+
+// IsRecording reports whether the class is being recorded.
+func (r *Room) IsRecording() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.recording
+}
+
+// prompt returns the prompt for the request.
+func (c *consentRequest) prompt() ConsentPrompt {
+	return ConsentPrompt{
+		RequestID:            c.id,
+		RequestedBy:          c.requestedBy,
+		ExcludeNonConsenting: c.excludeNonConsenting,
+		RequestedAt:          c.requestedAt,
+	}
+}
@@ -0,0 +1,42 @@
+package room
+
+import (
+	"sort"
+	"time"
+)
+
+// ParticipantStatus is a participant as the presenter's control panel shows
+// them: who they are, their media connection and whether their hand is up.
+type ParticipantStatus struct {
+	ParticipantInfo
+	State        ConnectionState `json:"state"`
+	SignedIn     bool            `json:"signedIn"`
+	HandRaisedAt *time.Time      `json:"handRaisedAt,omitempty"`
+}
+
+// ParticipantStatuses returns every participant's status, the presenter
+// first and the others by name.
+func (r *Room) ParticipantStatuses() []ParticipantStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]ParticipantStatus, 0, len(r.Participants))
+	for _, p := range r.Participants {
+		status := ParticipantStatus{
+			ParticipantInfo: p.Info(),
+			State:           p.GetState(),
+			SignedIn:        p.UserID != "",
+		}
+		if at, ok := r.raisedHands[p.ID]; ok {
+			status.HandRaisedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].IsPresenter != statuses[j].IsPresenter {
+			return statuses[i].IsPresenter
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
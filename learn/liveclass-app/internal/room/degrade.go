@@ -0,0 +1,71 @@
+package room
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// QualityLimit caps the presenter's outgoing video while the server is under load.
+type QualityLimit struct {
+	MaxHeight      int    `json:"maxHeight"`
+	MaxFramerate   int    `json:"maxFramerate"`
+	MaxBitrateKbps int    `json:"maxBitrateKbps"`
+	Reason         string `json:"reason"`
+}
+
+// Features lists the optional room features clients may use.
+type Features struct {
+	Typing       bool `json:"typing"`
+	ReadReceipts bool `json:"readReceipts"`
+}
+
+// SetQualityLimit degrades the room under the given limit, or restores it when
+// limit is nil. The presenter is told to adapt their encoder and everyone is told
+// which optional features are available. It returns false if nothing changed.
+func (r *Room) SetQualityLimit(limit *QualityLimit) bool {
+	r.mu.Lock()
+	if (r.qualityLimit == nil) == (limit == nil) {
+		r.qualityLimit = limit
+		r.mu.Unlock()
+		return false
+	}
+	r.qualityLimit = limit
+	r.mu.Unlock()
+
+	if data, err := json.Marshal(map[string]interface{}{
+		"type":    "quality-limit",
+		"payload": limit,
+	}); err == nil {
+		r.SendToPresenter(data)
+	}
+
+	r.BroadcastToAll(map[string]interface{}{
+		"type":    "features",
+		"payload": r.Features(),
+	}, "")
+
+	if limit != nil {
+		log.Printf("[Room %s] Degraded (%s): max %dp@%dfps, typing and read receipts off",
+			r.ID, limit.Reason, limit.MaxHeight, limit.MaxFramerate)
+	} else {
+		log.Printf("[Room %s] Restored full quality and optional features", r.ID)
+	}
+	return true
+}
+
+// QualityLimit returns the active quality limit, or nil if the room isn't degraded.
+func (r *Room) QualityLimit() *QualityLimit {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.qualityLimit
+}
+
+// Features returns the optional features currently enabled in the room.
+func (r *Room) Features() Features {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	enabled := r.qualityLimit == nil
+	return Features{Typing: enabled, ReadReceipts: enabled}
+}
@@ -0,0 +1,55 @@
+package room
+
+import "time"
+
+// ExamPolicy holds the exam-mode rules enforced for a room's class.
+type ExamPolicy struct {
+	ScheduleID        string
+	LateEntryDeadline time.Time
+}
+
+// SetExamPolicy enables exam mode for the room, or disables it when policy is nil.
+func (r *Room) SetExamPolicy(policy *ExamPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.exam = policy
+}
+
+// ExamPolicy returns the room's exam policy, or nil if exam mode is off.
+func (r *Room) ExamPolicy() *ExamPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.exam
+}
+
+// OtherSessions returns the participants of the same account other than p,
+// i.e. the older sessions to replace when only one device is allowed.
+func (r *Room) OtherSessions(p *Participant) []*Participant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := make([]*Participant, 0)
+	if p.UserID == "" {
+		return sessions
+	}
+	for _, other := range r.Participants {
+		if other.ID != p.ID && other.UserID == p.UserID {
+			sessions = append(sessions, other)
+		}
+	}
+	return sessions
+}
+
+// SendToPresenter sends raw data to the presenter. It returns false if there is none.
+func (r *Room) SendToPresenter(data []byte) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.Presenter == nil || r.Presenter.Conn == nil {
+		return false
+	}
+	r.Presenter.Conn.Send(data)
+	return true
+}
@@ -0,0 +1,86 @@
+package room
+
+import (
+	"sort"
+	"time"
+)
+
+// RaisedHand is a participant waiting with a raised hand.
+type RaisedHand struct {
+	ParticipantID string    `json:"participantId"`
+	Name          string    `json:"name"`
+	RaisedAt      time.Time `json:"raisedAt"`
+}
+
+// RaiseHand records that a participant raised their hand. Raising it again
+// keeps their place. It reports false if the hand was already raised.
+func (r *Room) RaiseHand(p *Participant) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.raisedHands[p.ID]; ok {
+		return false
+	}
+	r.raisedHands[p.ID] = time.Now()
+	return true
+}
+
+// LowerHand lowers a participant's hand and tells everyone with a
+// "hand-lowered" message. It reports false if the hand wasn't raised.
+func (r *Room) LowerHand(participantID, by string) bool {
+	r.mu.Lock()
+	_, ok := r.raisedHands[participantID]
+	delete(r.raisedHands, participantID)
+	r.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	r.BroadcastToAll(map[string]interface{}{
+		"type": "hand-lowered",
+		"payload": map[string]string{
+			"participantId": participantID,
+			"loweredBy":     by,
+		},
+	}, "")
+	return true
+}
+
+// LowerAllHands lowers every raised hand and returns how many there were.
+func (r *Room) LowerAllHands(by string) int {
+	r.mu.Lock()
+	lowered := make([]string, 0, len(r.raisedHands))
+	for id := range r.raisedHands {
+		lowered = append(lowered, id)
+	}
+	r.raisedHands = make(map[string]time.Time)
+	r.mu.Unlock()
+
+	if len(lowered) > 0 {
+		r.BroadcastToAll(map[string]interface{}{
+			"type": "hands-lowered",
+			"payload": map[string]interface{}{
+				"participantIds": lowered,
+				"loweredBy":      by,
+			},
+		}, "")
+	}
+	return len(lowered)
+}
+
+// RaisedHands returns the raised hands, longest waiting first.
+func (r *Room) RaisedHands() []RaisedHand {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hands := make([]RaisedHand, 0, len(r.raisedHands))
+	for id, at := range r.raisedHands {
+		hand := RaisedHand{ParticipantID: id, RaisedAt: at}
+		if p, ok := r.Participants[id]; ok {
+			hand.Name = p.Name
+		}
+		hands = append(hands, hand)
+	}
+	sort.Slice(hands, func(i, j int) bool { return hands[i].RaisedAt.Before(hands[j].RaisedAt) })
+	return hands
+}
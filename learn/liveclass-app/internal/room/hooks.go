@@ -0,0 +1,128 @@
+package room
+
+import (
+	"sync"
+	"time"
+)
+
+// ChatPost is a chat message posted in a room.
+type ChatPost struct {
+	RoomID        string
+	SessionID     string
+	MessageID     string
+	ParticipantID string
+	UserID        string // Empty for anonymous joins
+	Name          string
+	Text          string // Sanitized
+	Private       bool   // Sent to the presenter only, during an exam
+	At            time.Time
+}
+
+// Hooks lets optional subsystems react to what happens in rooms, such as
+// attendance or webhooks, without signaling and media code calling each of
+// them. Handlers are called in the order they were registered. Like a
+// LifecycleSink they may be called with the room locked, so they must not
+// block or call back into the room; slow work belongs in a goroutine.
+type Hooks struct {
+	mu                sync.RWMutex
+	participantJoined []func(LifecycleEvent)
+	participantLeft   []func(LifecycleEvent)
+	streamReady       []func(LifecycleEvent)
+	chatMessage       []func(ChatPost)
+	classEnded        []func(LifecycleEvent)
+}
+
+// NewHooks creates an empty hook registry.
+func NewHooks() *Hooks {
+	return &Hooks{}
+}
+
+// OnParticipantJoined registers fn for the presenter or a viewer joining
+// a room.
+func (h *Hooks) OnParticipantJoined(fn func(LifecycleEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.participantJoined = append(h.participantJoined, fn)
+}
+
+// OnParticipantLeft registers fn for the presenter or a viewer leaving a
+// room, including when the room is closed under them.
+func (h *Hooks) OnParticipantLeft(fn func(LifecycleEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.participantLeft = append(h.participantLeft, fn)
+}
+
+// OnStreamReady registers fn for viewers being able to receive the
+// presenter's stream, each time it becomes available.
+func (h *Hooks) OnStreamReady(fn func(LifecycleEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.streamReady = append(h.streamReady, fn)
+}
+
+// OnChatMessage registers fn for chat messages posted in a room.
+func (h *Hooks) OnChatMessage(fn func(ChatPost)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.chatMessage = append(h.chatMessage, fn)
+}
+
+// OnClassEnded registers fn for a room being closed and removed from the
+// hub, whether the class was ended or everyone left.
+func (h *Hooks) OnClassEnded(fn func(LifecycleEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.classEnded = append(h.classEnded, fn)
+}
+
+// lifecycle calls the handlers registered for a lifecycle event, if any.
+func (h *Hooks) lifecycle(event LifecycleEvent) {
+	if h == nil {
+		return
+	}
+
+	h.mu.RLock()
+	var handlers []func(LifecycleEvent)
+	switch event.Type {
+	case LifecyclePresenterJoined, LifecycleViewerJoined:
+		handlers = h.participantJoined
+	case LifecyclePresenterLeft, LifecycleViewerLeft:
+		handlers = h.participantLeft
+	case LifecycleStreamReady:
+		handlers = h.streamReady
+	case LifecycleEnded:
+		handlers = h.classEnded
+	}
+	h.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(event)
+	}
+}
+
+// chat calls the handlers registered for chat messages.
+func (h *Hooks) chat(post ChatPost) {
+	if h == nil {
+		return
+	}
+
+	h.mu.RLock()
+	handlers := h.chatMessage
+	h.mu.RUnlock()
+
+	for _, fn := range handlers {
+		fn(post)
+	}
+}
+
+// PostChat tells the hooks a chat message was posted in the room. Sending
+// it to participants is up to the caller.
+func (r *Room) PostChat(post ChatPost) {
+	post.RoomID = r.ID
+	post.SessionID = r.SessionID
+	if post.At.IsZero() {
+		post.At = time.Now()
+	}
+	r.hooks.chat(post)
+}
@@ -9,12 +9,35 @@ import (
 type Hub struct {
 	rooms map[string]*Room
 	mu    sync.RWMutex
+
+	// Applied to every room, including ones created later, while set
+	qualityLimit *QualityLimit
+
+	// Receives the lifecycle events of rooms created after it is set
+	lifecycle LifecycleSink
+
+	// Handlers of every room's events
+	hooks *Hooks
+
+	// Also receives what's sent to users and sessions, if set
+	streams UserStreams
+
+	// No new rooms are opened while draining
+	draining bool
+}
+
+// UserStreams are connections to users outside rooms, such as server-sent
+// event streams, that get the same messages as the users' room connections.
+type UserStreams interface {
+	Publish(userID string, data []byte) bool
+	CloseSession(sessionID string, data []byte) int
 }
 
 // NewHub creates a new Hub instance.
 func NewHub() *Hub {
 	return &Hub{
 		rooms: make(map[string]*Room),
+		hooks: NewHooks(),
 	}
 }
 
@@ -32,7 +55,11 @@ func (h *Hub) GetOrCreateRoom(roomID string) *Room {
 	}
 
 	room := NewRoom(normalizedID)
+	room.qualityLimit = h.qualityLimit
+	room.lifecycle = h.lifecycle
+	room.hooks = h.hooks
 	h.rooms[normalizedID] = room
+	room.emitLocked(LifecycleCreated, nil)
 	return room
 }
 
@@ -50,7 +77,11 @@ func (h *Hub) RemoveRoom(roomID string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	delete(h.rooms, strings.ToUpper(roomID))
+	normalizedID := strings.ToUpper(roomID)
+	if room, exists := h.rooms[normalizedID]; exists {
+		delete(h.rooms, normalizedID)
+		room.end()
+	}
 }
 
 // RoomCount returns the number of active rooms.
@@ -61,6 +92,75 @@ func (h *Hub) RoomCount() int {
 	return len(h.rooms)
 }
 
+// Rooms returns a snapshot of all active rooms.
+func (h *Hub) Rooms() []*Room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// SetDraining sets whether the instance is draining: rooms already open
+// carry on, but joins that would open a new room are refused so they go to
+// another instance.
+func (h *Hub) SetDraining(draining bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.draining = draining
+}
+
+// Draining reports whether the instance is draining.
+func (h *Hub) Draining() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.draining
+}
+
+// SetLifecycleSink sets where the lifecycle events of rooms created from now
+// on are sent. It is meant to be called once at startup.
+func (h *Hub) SetLifecycleSink(sink LifecycleSink) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lifecycle = sink
+}
+
+// Hooks returns the registry of handlers for the events of every room.
+func (h *Hub) Hooks() *Hooks {
+	return h.hooks
+}
+
+// SetUserStreams sets the streams that also get what's sent to users and
+// sessions. It is meant to be called once at startup.
+func (h *Hub) SetUserStreams(streams UserStreams) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.streams = streams
+}
+
+// SetQualityLimit degrades every room under limit, or restores them when limit
+// is nil. It returns the number of rooms that changed.
+func (h *Hub) SetQualityLimit(limit *QualityLimit) int {
+	h.mu.Lock()
+	h.qualityLimit = limit
+	h.mu.Unlock()
+
+	changed := 0
+	for _, room := range h.Rooms() {
+		if room.SetQualityLimit(limit) {
+			changed++
+		}
+	}
+	return changed
+}
+
 // CleanupEmptyRoom removes a room if it has no participants.
 func (h *Hub) CleanupEmptyRoom(roomID string) {
 	h.mu.Lock()
@@ -70,6 +170,64 @@ func (h *Hub) CleanupEmptyRoom(roomID string) {
 	if room, exists := h.rooms[normalizedID]; exists {
 		if room.ParticipantCount() == 0 {
 			delete(h.rooms, normalizedID)
+			room.end()
+		}
+	}
+}
+
+// SendToUser delivers raw data to all live connections of a user across rooms,
+// and to their user streams.
+// It returns true if the user had at least one live connection.
+func (h *Hub) SendToUser(userID string, data []byte) bool {
+	if userID == "" {
+		return false
+	}
+
+	h.mu.RLock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	streams := h.streams
+	h.mu.RUnlock()
+
+	delivered := false
+	for _, room := range rooms {
+		if room.SendToUser(userID, data) {
+			delivered = true
+		}
+	}
+	if streams != nil && streams.Publish(userID, data) {
+		delivered = true
+	}
+	return delivered
+}
+
+// CloseSession sends data to the connections and user streams opened with a
+// login session, then closes them. It returns how many were closed.
+func (h *Hub) CloseSession(sessionID string, data []byte) int {
+	if sessionID == "" {
+		return 0
+	}
+
+	h.mu.RLock()
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	streams := h.streams
+	h.mu.RUnlock()
+
+	closed := 0
+	if streams != nil {
+		closed += streams.CloseSession(sessionID, data)
+	}
+	for _, room := range rooms {
+		for _, p := range room.sessionParticipants(sessionID) {
+			p.Conn.Send(data)
+			p.Conn.Close()
+			closed++
 		}
 	}
+	return closed
 }
@@ -0,0 +1,96 @@
+package room
+
+import "time"
+
+// Lifecycle event types, recorded so what happened during a class can be
+// reconstructed afterwards.
+const (
+	LifecycleCreated           = "created"
+	LifecyclePresenterJoined   = "presenter-joined"
+	LifecyclePresenterLeft     = "presenter-left"
+	LifecyclePresenterConflict = "presenter-conflict" // The presenter's account joined from another device
+	LifecyclePresenterTakeover = "presenter-takeover" // Presenting moved to the account's other device
+	LifecycleStreamReady       = "stream-ready"       // Viewers can receive the presenter's stream
+	LifecycleViewerJoined      = "viewer-joined"
+	LifecycleViewerLeft        = "viewer-left"
+	LifecycleEnded             = "ended"          // The last participant left and the room was removed
+	LifecycleQualitySample     = "quality-sample" // Connection quality of the presenter and viewers
+)
+
+// LifecycleEvent is something that happened to a room.
+type LifecycleEvent struct {
+	Type          string
+	RoomID        string
+	SessionID     string // Tells successive rooms with the same ID apart
+	ParticipantID string
+	UserID        string
+	Name          string
+	Viewers       int // Viewers in the room after the event
+	At            time.Time
+	Quality       *QualitySample // Set on quality samples
+}
+
+// LifecycleSink receives the lifecycle events of every room. It may be called
+// with the room locked, so it must not block or call back into the room.
+type LifecycleSink func(LifecycleEvent)
+
+// RecordStreamReady records that viewers can now receive the presenter's
+// stream. It is recorded again each time the stream comes back.
+func (r *Room) RecordStreamReady() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.emitLocked(LifecycleStreamReady, r.Presenter)
+}
+
+// RecordPresenterConflict records that the presenter's account tried to join
+// as presenter from another device.
+func (r *Room) RecordPresenterConflict() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.emitLocked(LifecyclePresenterConflict, r.Presenter)
+}
+
+// end records that the room was removed from the hub.
+func (r *Room) end() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	r.emitLocked(LifecycleEnded, nil)
+}
+
+// emitLocked sends a lifecycle event about p, or the room itself if p is nil,
+// to the sink and the hooks. Callers must hold r.mu.
+func (r *Room) emitLocked(eventType string, p *Participant) {
+	if r.lifecycle == nil && r.hooks == nil {
+		return
+	}
+	event := r.eventLocked(eventType, p)
+	if r.lifecycle != nil {
+		r.lifecycle(event)
+	}
+	r.hooks.lifecycle(event)
+}
+
+// eventLocked builds a lifecycle event about p, or the room itself if p is
+// nil. Callers must hold r.mu.
+func (r *Room) eventLocked(eventType string, p *Participant) LifecycleEvent {
+	event := LifecycleEvent{
+		Type:      eventType,
+		RoomID:    r.ID,
+		SessionID: r.SessionID,
+		At:        time.Now(),
+	}
+	if p != nil {
+		event.ParticipantID = p.ID
+		event.UserID = p.UserID
+		event.Name = p.Name
+	}
+	for _, participant := range r.Participants {
+		if !participant.IsPresenter {
+			event.Viewers++
+		}
+	}
+	return event
+}
@@ -0,0 +1,36 @@
+package room
+
+import "time"
+
+// OpenLobby makes the room the lobby of a class starting at startsAt. A lobby
+// has chat but no media until the class goes live.
+func (r *Room) OpenLobby(startsAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lobbyStartsAt = &startsAt
+}
+
+// Lobby returns when the room's class starts if the room is still a lobby.
+func (r *Room) Lobby() (startsAt time.Time, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.lobbyStartsAt == nil {
+		return time.Time{}, false
+	}
+	return *r.lobbyStartsAt, true
+}
+
+// GoLive turns a lobby into the live class, keeping its participants and
+// chat. It reports false if the room wasn't a lobby.
+func (r *Room) GoLive() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lobbyStartsAt == nil {
+		return false
+	}
+	r.lobbyStartsAt = nil
+	return true
+}
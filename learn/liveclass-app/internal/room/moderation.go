@@ -0,0 +1,82 @@
+package room
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// DeleteChatMessage removes a chat message from the live chat and the class
+// transcript and tells everyone to hide it. It returns false if the message is
+// unknown (e.g. too old to still be tracked).
+func (r *Room) DeleteChatMessage(messageID, by string) bool {
+	if !r.Chat.Remove(messageID) {
+		return false
+	}
+	r.Transcript.Remove(messageID)
+
+	r.BroadcastToAll(map[string]interface{}{
+		"type": "chat-deleted",
+		"payload": map[string]interface{}{
+			"messageId": messageID,
+			"deletedBy": by,
+		},
+	}, "")
+
+	log.Printf("[Room %s] Chat message %s deleted by %s", r.ID, messageID, by)
+	return true
+}
+
+// SetChatMessageHidden temporarily hides a chat message from everyone (e.g.
+// after enough reports) or shows it again. It returns false if the message is unknown.
+func (r *Room) SetChatMessageHidden(messageID string, hidden bool) bool {
+	if !r.Chat.Has(messageID) {
+		return false
+	}
+
+	msgType := "chat-restored"
+	if hidden {
+		msgType = "chat-hidden"
+	}
+	r.BroadcastToAll(map[string]interface{}{
+		"type": msgType,
+		"payload": map[string]interface{}{
+			"messageId": messageID,
+		},
+	}, "")
+	return true
+}
+
+// Eject disconnects a participant on behalf of a moderator. Signed-in users
+// can't rejoin until the room closes. Closing the connection ends its read loop,
+// which removes the participant from the room.
+func (r *Room) Eject(p *Participant, by *Participant) {
+	r.mu.Lock()
+	if p.UserID != "" {
+		r.ejected[p.UserID] = struct{}{}
+	}
+	r.mu.Unlock()
+
+	if p.Conn != nil {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":    "removed",
+			"message": "You were removed from the class by " + by.Name,
+		})
+		p.Conn.Send(data)
+		p.Conn.Close()
+	}
+
+	log.Printf("[Room %s] Participant %s removed by %s", r.ID, p.Name, by.Name)
+}
+
+// IsEjected reports whether a moderator removed the account from the room.
+func (r *Room) IsEjected(userID string) bool {
+	if userID == "" {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.ejected[userID]
+	return ok
+}
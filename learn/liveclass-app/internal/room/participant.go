@@ -3,6 +3,7 @@ package room
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/pion/webrtc/v3"
 )
@@ -28,6 +29,9 @@ type Participant struct {
 	ID          string
 	Name        string
 	IsPresenter bool
+	IsAssistant bool   // Teaching assistant of the class's batch
+	UserID      string // Authenticated account ID, empty for anonymous joins
+	SessionID   string // Login session of the account, empty for anonymous joins
 	PeerConn    *webrtc.PeerConnection
 	Conn        Connection
 	VideoTrack  *webrtc.TrackLocalStaticRTP
@@ -40,6 +44,23 @@ type Participant struct {
 	// Pending ICE candidates (received before remote description is set)
 	PendingICE []webrtc.ICECandidateInit
 	iceMu      sync.Mutex
+
+	// RTP packet counters (uplink for the presenter, downlink for viewers)
+	Stats *MediaStats
+
+	// Selected caption language, guarded by the room lock
+	captionLang string
+
+	// Whether the participant's audio currently carries speech
+	speaking atomic.Bool
+
+	// IDs of the room tokens accepted from the participant
+	roomToken     string // Newest
+	prevRoomToken string // Replaced by the newest, valid until it's first used
+	tokenMu       sync.Mutex
+
+	// Acknowledged signaling with the client
+	signaling signaling
 }
 
 // Connection defines the interface for WebSocket communication.
@@ -47,6 +68,7 @@ type Connection interface {
 	Send(message []byte)
 	ReadMessage() ([]byte, error)
 	Close()
+	Alive() bool // False once the client stopped answering or the connection closed
 }
 
 // NewParticipant creates a new participant with the given details.
@@ -58,6 +80,7 @@ func NewParticipant(id, name string, isPresenter bool, conn Connection) *Partici
 		Conn:        conn,
 		ConnState:   StateIdle,
 		PendingICE:  make([]webrtc.ICECandidateInit, 0),
+		Stats:       NewMediaStats(),
 	}
 }
 
@@ -71,6 +94,10 @@ func (p *Participant) Cleanup() {
 	p.PendingICE = nil
 	p.iceMu.Unlock()
 
+	p.signaling.mu.Lock()
+	p.signaling.stopLocked()
+	p.signaling.mu.Unlock()
+
 	if p.PeerConn != nil {
 		p.PeerConn.Close()
 		p.PeerConn = nil
@@ -114,18 +141,60 @@ func (p *Participant) ClearPendingICE() {
 	p.PendingICE = make([]webrtc.ICECandidateInit, 0)
 }
 
+// SetRoomToken makes id the participant's newest room token. When the token
+// is renewed the one it replaces stays valid until the new one is used, so
+// messages already in flight aren't refused; otherwise (on reconnect) older
+// tokens stop working at once.
+func (p *Participant) SetRoomToken(id string, renewed bool) {
+	p.tokenMu.Lock()
+	defer p.tokenMu.Unlock()
+
+	p.prevRoomToken = ""
+	if renewed {
+		p.prevRoomToken = p.roomToken
+	}
+	p.roomToken = id
+}
+
+// UseRoomToken reports whether id is a room token of the participant that
+// is still valid. Using the newest token retires the previous one.
+func (p *Participant) UseRoomToken(id string) bool {
+	p.tokenMu.Lock()
+	defer p.tokenMu.Unlock()
+
+	switch {
+	case id == "":
+		return false
+	case id == p.roomToken:
+		p.prevRoomToken = ""
+		return true
+	default:
+		return id == p.prevRoomToken
+	}
+}
+
 // Info returns a ParticipantInfo struct for JSON serialization.
 func (p *Participant) Info() ParticipantInfo {
 	return ParticipantInfo{
 		ID:          p.ID,
 		Name:        p.Name,
 		IsPresenter: p.IsPresenter,
+		IsAssistant: p.IsAssistant,
+		Speaking:    p.speaking.Load(),
 	}
 }
 
+// CanModerate reports whether the participant may moderate the room
+// (delete chat messages, remove participants).
+func (p *Participant) CanModerate() bool {
+	return p.IsPresenter || p.IsAssistant
+}
+
 // ParticipantInfo represents public participant information for API responses.
 type ParticipantInfo struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	IsPresenter bool   `json:"isPresenter"`
+	IsAssistant bool   `json:"isAssistant,omitempty"`
+	Speaking    bool   `json:"speaking,omitempty"`
 }
@@ -0,0 +1,130 @@
+package room
+
+import (
+	"context"
+	"time"
+)
+
+// Connection quality bands, by packet loss in a sample
+const (
+	fairLossPercent = 2.0 // Loss at or above which a connection is fair
+	poorLossPercent = 8.0 // Loss at or above which it's poor, and video freezes
+
+	// The least uplink traffic in a sample worth judging; a paused camera
+	// shouldn't look like a clean (or lossy) uplink
+	minQualityPackets = 50
+)
+
+// QualityBand rates a connection in a quality sample.
+type QualityBand string
+
+// Quality bands
+const (
+	QualityGood    QualityBand = "good"
+	QualityFair    QualityBand = "fair"
+	QualityPoor    QualityBand = "poor"
+	QualityUnknown QualityBand = "unknown" // Too little traffic to tell
+)
+
+// QualitySample is the connection quality of a room at one moment: the
+// presenter's uplink over the sample interval, and how many viewers were in
+// each band by the loss they last reported. Viewer loss includes what was
+// lost on the uplink, so many poor viewers with a poor uplink point at the
+// presenter, and a few poor viewers with a good one at those viewers.
+type QualitySample struct {
+	UplinkLossPercent float64     `json:"uplinkLossPercent"`
+	Uplink            QualityBand `json:"uplink"`
+	Good              int         `json:"good"`
+	Fair              int         `json:"fair"`
+	Poor              int         `json:"poor"`
+	Disconnected      int         `json:"disconnected"` // Viewers joined but not receiving the stream
+}
+
+// qualityBand returns the band of a connection losing lossPercent.
+func qualityBand(lossPercent float64) QualityBand {
+	switch {
+	case lossPercent >= poorLossPercent:
+		return QualityPoor
+	case lossPercent >= fairLossPercent:
+		return QualityFair
+	default:
+		return QualityGood
+	}
+}
+
+// RecordQuality records a quality sample of the room. Rooms without a
+// stream or without viewers aren't sampled. It reports whether a sample was
+// recorded.
+func (r *Room) RecordQuality() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.lifecycle == nil || r.Presenter == nil || r.Presenter.Stats == nil || !r.StreamReady {
+		return false
+	}
+
+	sample := &QualitySample{Uplink: QualityUnknown}
+	received, lost := r.Presenter.Stats.sampleQuality()
+	if received+lost >= minQualityPackets {
+		sample.UplinkLossPercent = lossPercent(lost, received+lost)
+		sample.Uplink = qualityBand(sample.UplinkLossPercent)
+	}
+
+	viewers := 0
+	for _, p := range r.Participants {
+		if p.IsPresenter || p.Stats == nil {
+			continue
+		}
+		viewers++
+		if p.GetState() != StateConnected {
+			sample.Disconnected++
+			continue
+		}
+		_, _, recent := p.Stats.downlink(0)
+		switch qualityBand(recent) {
+		case QualityPoor:
+			sample.Poor++
+		case QualityFair:
+			sample.Fair++
+		default:
+			sample.Good++
+		}
+	}
+	if viewers == 0 {
+		return false
+	}
+
+	event := r.eventLocked(LifecycleQualitySample, nil)
+	event.Quality = sample
+	r.lifecycle(event)
+	return true
+}
+
+// QualitySampler records a quality sample of every live room at an
+// interval, for the network quality heatmap of class reports.
+type QualitySampler struct {
+	hub      *Hub
+	interval time.Duration
+}
+
+// NewQualitySampler creates a sampler recording the hub's rooms every interval.
+func NewQualitySampler(hub *Hub, interval time.Duration) *QualitySampler {
+	return &QualitySampler{hub: hub, interval: interval}
+}
+
+// Run samples the rooms every interval until ctx is cancelled.
+func (qs *QualitySampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(qs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, r := range qs.hub.Rooms() {
+				r.RecordQuality()
+			}
+		}
+	}
+}
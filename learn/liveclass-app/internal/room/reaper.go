@@ -0,0 +1,111 @@
+package room
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Touch records activity in the room, such as a message from a participant.
+func (r *Room) Touch() {
+	r.lastActivity.Store(time.Now().UnixNano())
+}
+
+// IdleFor returns how long the room has gone without messages from
+// participants or media from the presenter.
+func (r *Room) IdleFor(now time.Time) time.Duration {
+	r.mu.Lock()
+	if r.Presenter != nil && r.Presenter.Stats != nil {
+		if received := r.Presenter.Stats.Received(); received != r.mediaSeen {
+			r.mediaSeen = received
+			r.lastActivity.Store(now.UnixNano())
+		}
+	}
+	r.mu.Unlock()
+
+	return now.Sub(time.Unix(0, r.lastActivity.Load()))
+}
+
+// deadParticipants returns the participants whose connection is dead. A
+// presenter within their reconnection grace period isn't counted.
+func (r *Room) deadParticipants() []*Participant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var dead []*Participant
+	for _, p := range r.Participants {
+		if p.Conn == nil || p.Conn.Alive() {
+			continue
+		}
+		if p.IsPresenter && r.presenterReconnecting {
+			continue
+		}
+		dead = append(dead, p)
+	}
+	return dead
+}
+
+// Reaper removes participants whose connection died without their read
+// loop cleaning up after them, and closes rooms nobody has used for a
+// while, such as a room whose presenter never started streaming.
+type Reaper struct {
+	hub         *Hub
+	idleTimeout time.Duration // 0 keeps idle rooms open
+	interval    time.Duration
+
+	// Removes a dead participant from its room and tells the others
+	onDead func(p *Participant, r *Room)
+	// Called after an idle room is closed
+	onIdle func(r *Room)
+}
+
+// NewReaper creates a reaper checking the hub's rooms every interval.
+func NewReaper(hub *Hub, idleTimeout, interval time.Duration, onDead func(*Participant, *Room), onIdle func(*Room)) *Reaper {
+	return &Reaper{
+		hub:         hub,
+		idleTimeout: idleTimeout,
+		interval:    interval,
+		onDead:      onDead,
+		onIdle:      onIdle,
+	}
+}
+
+// Run checks the rooms every interval until ctx is cancelled.
+func (rp *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(rp.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rp.Reap(time.Now())
+		}
+	}
+}
+
+// Reap removes dead participants and closes idle rooms. Lobbies wait for
+// their class to start, so they aren't closed.
+func (rp *Reaper) Reap(now time.Time) {
+	for _, r := range rp.hub.Rooms() {
+		for _, p := range r.deadParticipants() {
+			log.Printf("[Room %s] Removing %s (%s): connection is dead", r.ID, p.Name, p.ID)
+			p.Conn.Close()
+			rp.onDead(p, r)
+		}
+
+		if rp.idleTimeout <= 0 {
+			continue
+		}
+		if _, lobby := r.Lobby(); lobby {
+			continue
+		}
+		if idle := r.IdleFor(now); idle >= rp.idleTimeout {
+			log.Printf("[Room %s] Closing after %v without activity", r.ID, idle.Round(time.Minute))
+			r.Close("This class was closed because nothing happened in it for a while")
+			rp.hub.CleanupEmptyRoom(r.ID)
+			rp.onIdle(r)
+		}
+	}
+}
@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 // Room represents a live class session where one presenter streams to multiple viewers.
 type Room struct {
 	ID           string
+	SessionID    string // Unique to this room, unlike reusable room IDs
 	Participants map[string]*Participant
 	Presenter    *Participant
 	StreamReady  bool
@@ -16,15 +21,69 @@ type Room struct {
 	// Track if presenter's ICE connection is fully established
 	PresenterICEConnected bool
 
+	// Ephemeral chat signals (typing, read receipts)
+	Chat *ChatActivity
+
+	// Activity log for the class archive
+	Transcript *Transcript
+
+	// Presenter reconnection grace period
+	presenterReconnecting bool
+	presenterGraceTimer   *time.Timer
+
+	// Exam-mode rules, nil for regular classes
+	exam *ExamPolicy
+
+	// Start time of the class while the room is its pre-class lobby
+	lobbyStartsAt *time.Time
+
+	// Set while the server is under CPU pressure
+	qualityLimit *QualityLimit
+
+	// Set while the presenter is asked to back off on a lossy uplink
+	uplinkAdaptation *UplinkAdaptation
+
+	// Accounts removed by a moderator, kept out until the room closes
+	ejected map[string]struct{}
+
+	// Participants with a raised hand, and when they raised it
+	raisedHands map[string]time.Time
+
+	// Recording consent request and whether the class is being recorded
+	consent   *consentRequest
+	recording bool
+
+	// Receives lifecycle events, nil to not record them
+	lifecycle LifecycleSink
+
+	// Extensions' handlers, shared by the hub's rooms
+	hooks *Hooks
+
+	// Receive a copy of the presenter's media, by ID; replaced rather than
+	// changed so media is tapped without taking the lock
+	taps atomic.Pointer[map[string]MediaTap]
+
+	// Last message or media from a participant (unix nanoseconds), and the
+	// presenter's uplink packet count when media was last seen
+	lastActivity atomic.Int64
+	mediaSeen    uint64
+
 	mu sync.RWMutex
 }
 
 // NewRoom creates a new room with the given ID.
 func NewRoom(id string) *Room {
-	return &Room{
+	r := &Room{
 		ID:           id,
+		SessionID:    uuid.New().String(),
 		Participants: make(map[string]*Participant),
+		Chat:         NewChatActivity(),
+		Transcript:   NewTranscript(),
+		ejected:      make(map[string]struct{}),
+		raisedHands:  make(map[string]time.Time),
 	}
+	r.Touch()
+	return r
 }
 
 // AddParticipant adds a participant to the room.
@@ -41,6 +100,12 @@ func (r *Room) AddParticipant(p *Participant) {
 		p.SetState(StateWaiting)
 	}
 
+	if p.IsPresenter {
+		r.emitLocked(LifecyclePresenterJoined, p)
+	} else {
+		r.emitLocked(LifecycleViewerJoined, p)
+	}
+
 	log.Printf("[Room %s] Participant %s (%s) joined (presenter: %v)",
 		r.ID, p.Name, p.ID, p.IsPresenter)
 }
@@ -58,9 +123,12 @@ func (r *Room) RemoveParticipant(participantID string) {
 	wasPresenter := p.IsPresenter
 
 	p.Cleanup()
+	r.Chat.Forget(participantID)
 	delete(r.Participants, participantID)
+	delete(r.raisedHands, participantID)
 
 	if r.Presenter != nil && r.Presenter.ID == participantID {
+		r.stopPresenterGraceLocked()
 		r.Presenter = nil
 		r.StreamReady = false
 		r.PresenterICEConnected = false
@@ -80,6 +148,12 @@ func (r *Room) RemoveParticipant(participantID string) {
 		log.Printf("[Room %s] Presenter left, reset %d viewers to waiting state", r.ID, len(r.Participants))
 	}
 
+	if wasPresenter {
+		r.emitLocked(LifecyclePresenterLeft, p)
+	} else {
+		r.emitLocked(LifecycleViewerLeft, p)
+	}
+
 	if wasPresenter {
 		log.Printf("[Room %s] Presenter %s left", r.ID, participantID)
 	} else {
@@ -112,6 +186,105 @@ func (r *Room) HasPresenter() bool {
 	return r.Presenter != nil
 }
 
+// BeginPresenterGrace keeps the presenter, and the tracks forwarded to viewers,
+// in the room after their connection drops. If they haven't resumed within d,
+// onExpire is called to remove them for good.
+func (r *Room) BeginPresenterGrace(d time.Duration, onExpire func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Presenter == nil {
+		return
+	}
+
+	r.stopPresenterGraceLocked()
+	r.presenterReconnecting = true
+	r.presenterGraceTimer = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		expired := r.presenterReconnecting
+		r.presenterReconnecting = false
+		r.presenterGraceTimer = nil
+		r.mu.Unlock()
+
+		if expired {
+			log.Printf("[Room %s] Presenter did not reconnect within %v", r.ID, d)
+			onExpire()
+		}
+	})
+
+	log.Printf("[Room %s] Presenter %s disconnected, waiting %v for reconnect", r.ID, r.Presenter.ID, d)
+}
+
+// ResumePresenter rebinds a reconnecting presenter to a new connection.
+// It returns nil if no presenter is reconnecting or the account doesn't match.
+func (r *Room) ResumePresenter(conn Connection, userID string) *Participant {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.presenterReconnecting || r.Presenter == nil {
+		return nil
+	}
+	if r.Presenter.UserID != "" && r.Presenter.UserID != userID {
+		return nil
+	}
+
+	r.stopPresenterGraceLocked()
+	r.Presenter.Conn = conn
+
+	log.Printf("[Room %s] Presenter %s reconnected", r.ID, r.Presenter.ID)
+	return r.Presenter
+}
+
+// TakeOverPresenter moves the presenter to a new connection of the same
+// account, such as a second device, keeping the tracks forwarded to viewers
+// until the new device sends its offer. It returns the presenter and the
+// connection it was bound to, or nil if the presenter belongs to another
+// account or to none.
+func (r *Room) TakeOverPresenter(conn Connection, userID, sessionID string) (*Participant, Connection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Presenter == nil || r.Presenter.UserID == "" || r.Presenter.UserID != userID {
+		return nil, nil
+	}
+
+	r.stopPresenterGraceLocked()
+	old := r.Presenter.Conn
+	r.Presenter.Conn = conn
+	r.Presenter.SessionID = sessionID
+	r.emitLocked(LifecyclePresenterTakeover, r.Presenter)
+
+	log.Printf("[Room %s] Presenter %s moved to another device", r.ID, r.Presenter.ID)
+	return r.Presenter, old
+}
+
+// IsPresenterReconnecting returns true while the presenter is in the reconnection grace period.
+func (r *Room) IsPresenterReconnecting() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.presenterReconnecting
+}
+
+// IsBoundTo reports whether the participant is still in the room using the
+// given connection. A resumed presenter is rebound to a new connection,
+// leaving the old one orphaned; a reaped participant is no longer in the room.
+func (r *Room) IsBoundTo(p *Participant, conn Connection) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return p.Conn == conn && r.Participants[p.ID] == p
+}
+
+// stopPresenterGraceLocked cancels a pending grace timer. Callers must hold r.mu.
+func (r *Room) stopPresenterGraceLocked() {
+	if r.presenterGraceTimer != nil {
+		r.presenterGraceTimer.Stop()
+		r.presenterGraceTimer = nil
+	}
+	r.presenterReconnecting = false
+}
+
 // IsStreamReady returns true if the presenter's stream is ready.
 func (r *Room) IsStreamReady() bool {
 	r.mu.RLock()
@@ -225,6 +398,101 @@ func (r *Room) BroadcastToAll(message interface{}, excludeID string) {
 	}
 }
 
+// SendToUser sends raw data to every connection of an authenticated user in the room.
+// It returns true if at least one connection was found.
+func (r *Room) SendToUser(userID string, data []byte) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sent := false
+	for _, p := range r.Participants {
+		if p.UserID == userID && p.Conn != nil {
+			p.Conn.Send(data)
+			sent = true
+		}
+	}
+	return sent
+}
+
+// SendToEach sends every connected participant the message returned for
+// them, for messages that differ per participant. Participants message
+// returns nil for are skipped.
+func (r *Room) SendToEach(message func(p *Participant) interface{}) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.Participants {
+		if p.Conn == nil {
+			continue
+		}
+		msg := message(p)
+		if msg == nil {
+			continue
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("[Room %s] Error marshaling message for %s: %v", r.ID, p.ID, err)
+			continue
+		}
+		p.Conn.Send(data)
+	}
+}
+
+// sessionParticipants returns the connected participants that joined with a
+// login session.
+func (r *Room) sessionParticipants(sessionID string) []*Participant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var participants []*Participant
+	for _, p := range r.Participants {
+		if p.SessionID == sessionID && p.Conn != nil {
+			participants = append(participants, p)
+		}
+	}
+	return participants
+}
+
+// HasUser reports whether an authenticated user is connected to the room.
+func (r *Room) HasUser(userID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.Participants {
+		if p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Close tells every participant that the class has ended and closes their
+// connections. It returns the number of participants that were connected.
+func (r *Room) Close(reason string) int {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "class-ended",
+		"message": reason,
+	})
+
+	r.mu.RLock()
+	conns := make([]Connection, 0, len(r.Participants))
+	for _, p := range r.Participants {
+		if p.Conn != nil {
+			conns = append(conns, p.Conn)
+		}
+	}
+	r.mu.RUnlock()
+
+	// Closing ends each read loop, which removes the participant from the room
+	for _, conn := range conns {
+		conn.Send(data)
+		conn.Close()
+	}
+
+	log.Printf("[Room %s] Closed, disconnected %d participants", r.ID, len(conns))
+	return len(conns)
+}
+
 // GetParticipantInfoList returns a list of participant info for all participants.
 func (r *Room) GetParticipantInfoList() []ParticipantInfo {
 	r.mu.RLock()
@@ -236,3 +504,25 @@ func (r *Room) GetParticipantInfoList() []ParticipantInfo {
 	}
 	return list
 }
+
+// MarkChatRead records a read receipt and schedules an aggregated receipt broadcast.
+func (r *Room) MarkChatRead(messageID, participantID string) {
+	if r.Chat.MarkRead(messageID, participantID) {
+		time.AfterFunc(ReceiptFlushInterval, r.flushReadReceipts)
+	}
+}
+
+// flushReadReceipts broadcasts aggregated read counts for recently read messages.
+func (r *Room) flushReadReceipts() {
+	counts := r.Chat.TakePendingCounts()
+	if len(counts) == 0 {
+		return
+	}
+
+	r.BroadcastToAll(map[string]interface{}{
+		"type": "chat-receipts",
+		"payload": map[string]interface{}{
+			"counts": counts,
+		},
+	}, "")
+}
@@ -0,0 +1,22 @@
+package room
+
+// SpeakingIndicator tells clients whether a participant is speaking, for
+// highlighting the active speaker.
+type SpeakingIndicator struct {
+	ParticipantID string `json:"participantId"`
+	Speaking      bool   `json:"speaking"`
+	Level         int    `json:"level,omitempty"` // Coarse loudness while speaking, 1 (quiet) to 3 (loud)
+}
+
+// SetSpeaking records whether p is speaking and broadcasts a "speaking"
+// indicator to everyone in the room, p included.
+func (r *Room) SetSpeaking(p *Participant, speaking bool, level int) {
+	p.speaking.Store(speaking)
+	if !speaking {
+		level = 0
+	}
+	r.BroadcastToAll(map[string]interface{}{
+		"type":    "speaking",
+		"payload": SpeakingIndicator{ParticipantID: p.ID, Speaking: speaking, Level: level},
+	}, "")
+}
@@ -0,0 +1,216 @@
+package room
+
+import "sync"
+
+// MediaStats tracks RTP packet counters for a participant's media path.
+// For the presenter these describe the uplink (packets received by the SFU);
+// for a viewer they describe the downlink (packets forwarded and the loss the
+// viewer reports back via RTCP).
+type MediaStats struct {
+	received uint64            // presenter: RTP packets received from the uplink
+	lost     uint64            // presenter: packets missing from the uplink sequence
+	lastSeq  map[uint32]uint16 // presenter: last sequence number per SSRC
+
+	sampledReceived uint64 // presenter: received at the last SampleUplink
+	sampledLost     uint64 // presenter: lost at the last SampleUplink
+
+	qualityReceived uint64 // presenter: received at the last quality sample
+	qualityLost     uint64 // presenter: lost at the last quality sample
+
+	baseline     uint64            // viewer: presenter packets received when the viewer attached
+	reportedLost map[uint32]uint32 // viewer: cumulative loss per SSRC from receiver reports
+	fractionLost uint8             // viewer: most recent fraction lost (0-255)
+
+	mu sync.Mutex
+}
+
+// NewMediaStats creates empty media stats.
+func NewMediaStats() *MediaStats {
+	return &MediaStats{
+		lastSeq:      make(map[uint32]uint16),
+		reportedLost: make(map[uint32]uint32),
+	}
+}
+
+// RecordUplinkPacket counts a packet received from the presenter and returns
+// how many packets were detected missing before it.
+func (s *MediaStats) RecordUplinkPacket(ssrc uint32, seq uint16) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.received++
+
+	var missing uint64
+	if last, ok := s.lastSeq[ssrc]; ok {
+		// Only forward jumps count; late (reordered) packets are ignored
+		if diff := seq - last; diff > 0 && diff < 0x8000 {
+			missing = uint64(diff - 1)
+			s.lost += missing
+			s.lastSeq[ssrc] = seq
+		}
+	} else {
+		s.lastSeq[ssrc] = seq
+	}
+	return missing
+}
+
+// Received returns the number of uplink packets received.
+func (s *MediaStats) Received() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received
+}
+
+// SampleUplink returns the uplink packets received and lost since the previous call.
+func (s *MediaStats) SampleUplink() (received, lost uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	received = s.received - s.sampledReceived
+	lost = s.lost - s.sampledLost
+	s.sampledReceived = s.received
+	s.sampledLost = s.lost
+	return received, lost
+}
+
+// sampleQuality returns the uplink packets received and lost since the
+// previous quality sample. It is separate from SampleUplink, which uplink
+// adaptation samples at its own interval.
+func (s *MediaStats) sampleQuality() (received, lost uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	received = s.received - s.qualityReceived
+	lost = s.lost - s.qualityLost
+	s.qualityReceived = s.received
+	s.qualityLost = s.lost
+	return received, lost
+}
+
+// SetBaseline records the presenter packet count when a viewer is attached,
+// so packets forwarded to the viewer can be derived later.
+func (s *MediaStats) SetBaseline(presenterReceived uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.baseline = presenterReceived
+	s.reportedLost = make(map[uint32]uint32)
+	s.fractionLost = 0
+}
+
+// RecordReceiverReport stores a viewer's reported loss for one SSRC and returns
+// the number of newly lost packets since the previous report.
+func (s *MediaStats) RecordReceiverReport(ssrc, totalLost uint32, fractionLost uint8) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.reportedLost[ssrc]
+	s.reportedLost[ssrc] = totalLost
+	s.fractionLost = fractionLost
+
+	if totalLost > prev {
+		return totalLost - prev
+	}
+	return 0
+}
+
+// UplinkStats is the presenter-side view of media stats.
+type UplinkStats struct {
+	PacketsReceived uint64  `json:"packetsReceived"`
+	PacketsLost     uint64  `json:"packetsLost"`
+	LossPercent     float64 `json:"lossPercent"`
+}
+
+// DownlinkStats is the viewer-side view of media stats.
+type DownlinkStats struct {
+	ParticipantID     string          `json:"participantId"`
+	Name              string          `json:"name"`
+	State             ConnectionState `json:"state"`
+	PacketsForwarded  uint64          `json:"packetsForwarded"`
+	PacketsLost       uint64          `json:"packetsLost"`
+	LossPercent       float64         `json:"lossPercent"`
+	RecentLossPercent float64         `json:"recentLossPercent"`
+}
+
+// RoomMediaStats summarises the presenter uplink, its adaptation state and every
+// viewer downlink in a room.
+type RoomMediaStats struct {
+	RoomID     string            `json:"roomId"`
+	Uplink     *UplinkStats      `json:"uplink,omitempty"`
+	Adaptation *UplinkAdaptation `json:"adaptation,omitempty"`
+	Viewers    []DownlinkStats   `json:"viewers"`
+}
+
+// uplink returns a snapshot of presenter stats.
+func (s *MediaStats) uplink() UplinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return UplinkStats{
+		PacketsReceived: s.received,
+		PacketsLost:     s.lost,
+		LossPercent:     lossPercent(s.lost, s.received+s.lost),
+	}
+}
+
+// downlink returns a snapshot of viewer stats given the presenter's current packet count.
+func (s *MediaStats) downlink(presenterReceived uint64) (forwarded, lost uint64, recent float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if presenterReceived > s.baseline {
+		forwarded = presenterReceived - s.baseline
+	}
+	for _, l := range s.reportedLost {
+		lost += uint64(l)
+	}
+	return forwarded, lost, float64(s.fractionLost) * 100 / 256
+}
+
+// MediaStats returns packet stats for the presenter uplink and all viewers.
+func (r *Room) MediaStats() RoomMediaStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := RoomMediaStats{
+		RoomID:  r.ID,
+		Viewers: make([]DownlinkStats, 0, len(r.Participants)),
+	}
+
+	var presenterReceived uint64
+	if r.Presenter != nil {
+		uplink := r.Presenter.Stats.uplink()
+		presenterReceived = uplink.PacketsReceived
+		stats.Uplink = &uplink
+	}
+	if r.uplinkAdaptation != nil {
+		adaptation := *r.uplinkAdaptation
+		stats.Adaptation = &adaptation
+	}
+
+	for _, p := range r.Participants {
+		if p.IsPresenter {
+			continue
+		}
+		forwarded, lost, recent := p.Stats.downlink(presenterReceived)
+		stats.Viewers = append(stats.Viewers, DownlinkStats{
+			ParticipantID:     p.ID,
+			Name:              p.Name,
+			State:             p.GetState(),
+			PacketsForwarded:  forwarded,
+			PacketsLost:       lost,
+			LossPercent:       lossPercent(lost, forwarded),
+			RecentLossPercent: recent,
+		})
+	}
+
+	return stats
+}
+
+// lossPercent returns lost as a percentage of total, or 0 when there's no traffic.
+func lossPercent(lost, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(lost) * 100 / float64(total)
+}
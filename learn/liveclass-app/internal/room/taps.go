@@ -0,0 +1,53 @@
+package room
+
+// MediaTap receives a copy of every RTP packet the presenter sends, such as
+// a re-stream to an external broadcast. WriteRTP is called on the media
+// path: it must not block and must not keep pkt after returning.
+type MediaTap interface {
+	WriteRTP(video bool, pkt []byte)
+}
+
+// AddMediaTap starts sending the presenter's media to tap, replacing any
+// tap with the same ID.
+func (r *Room) AddMediaTap(id string, tap MediaTap) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taps := make(map[string]MediaTap)
+	if current := r.taps.Load(); current != nil {
+		for k, v := range *current {
+			taps[k] = v
+		}
+	}
+	taps[id] = tap
+	r.taps.Store(&taps)
+}
+
+// RemoveMediaTap stops sending the presenter's media to the tap with id.
+func (r *Room) RemoveMediaTap(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := r.taps.Load()
+	if current == nil {
+		return
+	}
+	taps := make(map[string]MediaTap)
+	for k, v := range *current {
+		if k != id {
+			taps[k] = v
+		}
+	}
+	r.taps.Store(&taps)
+}
+
+// TapMedia hands an RTP packet of the presenter to the room's media taps.
+func (r *Room) TapMedia(video bool, pkt []byte) {
+	taps := r.taps.Load()
+	if taps == nil {
+		return
+	}
+	for _, tap := range *taps {
+		tap.WriteRTP(video, pkt)
+	}
+}
@@ -0,0 +1,97 @@
+package room
+
+import (
+	"sync"
+	"time"
+)
+
+// Transcript entry kinds.
+const (
+	EntryChat       = "chat"
+	EntryHandRaised = "hand-raised"
+	EntryQuestion   = "question"
+	EntryPoll       = "poll"
+	EntryCaption    = "caption" // A final caption line of the presenter's speech
+)
+
+// maxTranscriptEntries bounds the in-memory transcript of a single class.
+const maxTranscriptEntries = 10000
+
+// TranscriptEntry is a single recorded classroom event.
+type TranscriptEntry struct {
+	Kind          string      `json:"kind"`
+	MessageID     string      `json:"messageId,omitempty"`
+	ParticipantID string      `json:"participantId"`
+	UserID        string      `json:"-"` // Author account, for moderation only
+	Name          string      `json:"name"`
+	Text          string      `json:"text,omitempty"`
+	Data          interface{} `json:"data,omitempty"`
+	At            time.Time   `json:"at"`
+}
+
+// Transcript records classroom activity (chat, raised hands, Q&A, polls,
+// captions) for the class archive and quiz drafts generated when the class
+// ends.
+type Transcript struct {
+	entries []TranscriptEntry
+	dropped int
+	mu      sync.Mutex
+}
+
+// NewTranscript creates an empty transcript.
+func NewTranscript() *Transcript {
+	return &Transcript{}
+}
+
+// Record appends an entry. Once the transcript is full new entries are dropped
+// and counted so the archive can note the truncation.
+func (t *Transcript) Record(entry TranscriptEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry.At.IsZero() {
+		entry.At = time.Now()
+	}
+	if len(t.entries) >= maxTranscriptEntries {
+		t.dropped++
+		return
+	}
+	t.entries = append(t.entries, entry)
+}
+
+// Entries returns a copy of the recorded entries and the number of dropped entries.
+func (t *Transcript) Entries() ([]TranscriptEntry, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := make([]TranscriptEntry, len(t.entries))
+	copy(entries, t.entries)
+	return entries, t.dropped
+}
+
+// Find returns the entry of a chat message.
+func (t *Transcript) Find(messageID string) (TranscriptEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, e := range t.entries {
+		if e.MessageID == messageID {
+			return e, true
+		}
+	}
+	return TranscriptEntry{}, false
+}
+
+// Remove deletes the entry of a moderated chat message so it's left out of the archive.
+func (t *Transcript) Remove(messageID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, e := range t.entries {
+		if e.MessageID == messageID {
+			t.entries = append(t.entries[:i], t.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
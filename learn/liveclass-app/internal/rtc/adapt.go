@@ -0,0 +1,114 @@
+package rtc
+
+import (
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/webrtc/v3"
+)
+
+// uplinkAdaptations counts presenter adaptation changes, to spot classes
+// struggling with poor presenter connections.
+var uplinkAdaptations = metrics.NewCounterVec(
+	"liveclass_uplink_adaptations_total",
+	"Presenter uplink adaptation changes by direction (down, up).",
+	"direction",
+)
+
+// minSamplePackets is the least uplink traffic in a sample worth judging; a
+// paused camera shouldn't look like a clean (or lossy) uplink.
+const minSamplePackets = 50
+
+// adaptationLevels are the video limits for each adaptation level above 0.
+// Audio is never reduced.
+var adaptationLevels = []room.UplinkAdaptation{
+	{Level: 1, MaxHeight: 480, MaxFramerate: 24, MaxBitrateKbps: 500},
+	{Level: 2, MaxHeight: 240, MaxFramerate: 15, MaxBitrateKbps: 150},
+}
+
+// AdaptationPolicy controls when a presenter on a lossy uplink is asked to
+// reduce video. Its loss thresholds also move viewers of a simulcasting
+// presenter between layers, judged on their receiver reports.
+type AdaptationPolicy struct {
+	// Interval is how often uplink loss is sampled; 0 disables adaptation.
+	Interval time.Duration
+	// HighLossPercent is the sample loss at or above which the uplink is lossy.
+	HighLossPercent float64
+	// LowLossPercent is the sample loss at or below which the uplink is clean.
+	LowLossPercent float64
+	// Sustain is the number of consecutive lossy (or clean) samples needed to
+	// step down (or back up) one level.
+	Sustain int
+}
+
+// monitorUplink samples the loss on a presenter's uplink, the same loss the
+// SFU reports back in its RTCP receiver reports, and steps the presenter's
+// video down while it stays high and back up once it recovers. It runs until
+// the peer connection closes or is replaced.
+func (s *Service) monitorUplink(peerConn *webrtc.PeerConnection, r *room.Room, presenter *room.Participant) {
+	policy := s.adaptation
+	if policy.Interval <= 0 {
+		return
+	}
+
+	// Start from a clean slate for this connection
+	presenter.Stats.SampleUplink()
+
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+
+	level, lossy, clean := 0, 0, 0
+	for range ticker.C {
+		// A renegotiated connection has its own monitor
+		if presenter.PeerConn != peerConn {
+			return
+		}
+		switch peerConn.ConnectionState() {
+		case webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateFailed:
+			if level > 0 {
+				r.SetUplinkAdaptation(room.UplinkAdaptation{Since: time.Now()})
+			}
+			return
+		}
+
+		received, lost := presenter.Stats.SampleUplink()
+		if received+lost < minSamplePackets {
+			continue
+		}
+		loss := float64(lost) * 100 / float64(received+lost)
+
+		switch {
+		case loss >= policy.HighLossPercent:
+			lossy, clean = lossy+1, 0
+		case loss <= policy.LowLossPercent:
+			lossy, clean = 0, clean+1
+		default:
+			lossy, clean = 0, 0
+		}
+
+		next := level
+		if lossy >= policy.Sustain && level < len(adaptationLevels) {
+			next, lossy = level+1, 0
+			uplinkAdaptations.WithLabelValues("down").Inc()
+		} else if clean >= policy.Sustain && level > 0 {
+			next, clean = level-1, 0
+			uplinkAdaptations.WithLabelValues("up").Inc()
+		}
+		if next == level {
+			continue
+		}
+		level = next
+
+		adaptation := room.UplinkAdaptation{Since: time.Now(), LossPercent: loss}
+		if level > 0 {
+			adaptation = adaptationLevels[level-1]
+			adaptation.ProtectAudio = true
+			adaptation.LossPercent = loss
+			adaptation.Since = time.Now()
+		}
+		log.Printf("[RTC] Presenter uplink in room %s at %.1f%% loss, adaptation level %d", r.ID, loss, level)
+		r.SetUplinkAdaptation(adaptation)
+	}
+}
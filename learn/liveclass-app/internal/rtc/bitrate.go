@@ -0,0 +1,211 @@
+package rtc
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+// bitrateCapChanges counts changes to presenter bitrate caps, to see how
+// often rooms outgrow the server's forwarding capacity.
+var bitrateCapChanges = metrics.NewCounterVec(
+	"liveclass_presenter_bitrate_cap_changes_total",
+	"Presenter video bitrate cap changes by direction (down, up).",
+	"direction",
+)
+
+// BitrateCapPolicy caps presenter video so forwarding it to every viewer
+// stays within what the server can send. The forwarding budget is shared
+// fairly between rooms: a room needing less than its share gets the full
+// bitrate, and what it leaves is split among the larger rooms.
+type BitrateCapPolicy struct {
+	// Interval is how often the cap is re-sent to presenters; 0 disables capping.
+	Interval time.Duration
+	// MaxKbps is the cap of a room well within capacity.
+	MaxKbps int
+	// MinKbps is the least a presenter is capped to, even over capacity.
+	MinKbps int
+	// EgressBudgetKbps is the bandwidth the server can spend forwarding
+	// presenter video to viewers across all rooms.
+	EgressBudgetKbps int
+}
+
+// bitrateCaps computes presenter bitrate caps from the viewers in each room.
+type bitrateCaps struct {
+	policy BitrateCapPolicy
+
+	mu      sync.Mutex
+	viewers map[string]int // By room, for rooms with viewers
+	caps    map[string]int // Kbps by room, recomputed on changes
+	changed chan struct{}  // Closed and replaced when room sizes change
+}
+
+func newBitrateCaps(policy BitrateCapPolicy) *bitrateCaps {
+	if policy.Interval <= 0 {
+		return nil
+	}
+	return &bitrateCaps{
+		policy:  policy,
+		viewers: make(map[string]int),
+		caps:    make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// Register subscribes the service to room events: room sizes keep presenter
+// bitrate caps up to date, and presenters leaving drop their simulcast
+// layers and clip splices. Without it every presenter is capped at the
+// policy's maximum.
+func (s *Service) Register(hooks *room.Hooks) {
+	hooks.OnParticipantLeft(s.dropSimulcast)
+	hooks.OnClassEnded(s.dropSimulcast)
+	hooks.OnParticipantLeft(s.dropSplice)
+	hooks.OnClassEnded(s.dropSplice)
+	if s.bitrateCaps == nil {
+		return
+	}
+	hooks.OnParticipantJoined(s.bitrateCaps.observe)
+	hooks.OnParticipantLeft(s.bitrateCaps.observe)
+	hooks.OnClassEnded(s.bitrateCaps.observe)
+}
+
+// observe updates a room's size from a lifecycle event.
+func (c *bitrateCaps) observe(ev room.LifecycleEvent) {
+	viewers := ev.Viewers
+	if ev.Type == room.LifecycleEnded {
+		viewers = 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.viewers[ev.RoomID] == viewers {
+		return
+	}
+	if viewers == 0 {
+		delete(c.viewers, ev.RoomID)
+	} else {
+		c.viewers[ev.RoomID] = viewers
+	}
+	c.recomputeLocked()
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// recomputeLocked shares the budget between rooms, smallest first, so each
+// room gets the lesser of what its viewers need and an equal share of what
+// is left. Callers must hold c.mu.
+func (c *bitrateCaps) recomputeLocked() {
+	rooms := make([]string, 0, len(c.viewers))
+	for id := range c.viewers {
+		rooms = append(rooms, id)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return c.viewers[rooms[i]] < c.viewers[rooms[j]] })
+
+	c.caps = make(map[string]int, len(rooms))
+	remaining := c.policy.EgressBudgetKbps
+	for i, id := range rooms {
+		viewers := c.viewers[id]
+		kbps := c.policy.MaxKbps
+		if c.policy.EgressBudgetKbps > 0 {
+			kbps = min(kbps, remaining/(len(rooms)-i)/viewers)
+		}
+		kbps = max(kbps, c.policy.MinKbps)
+		c.caps[id] = kbps
+		remaining = max(remaining-kbps*viewers, 0)
+	}
+}
+
+// capKbps returns a room's current cap.
+func (c *bitrateCaps) capKbps(roomID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if kbps, ok := c.caps[roomID]; ok {
+		return kbps
+	}
+	return c.policy.MaxKbps
+}
+
+// changes returns a channel closed the next time room sizes change.
+func (c *bitrateCaps) changes() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.changed
+}
+
+// capUplinkBitrate sends the presenter's browser its room's bitrate cap as
+// REMB, re-evaluating it as viewers join and leave and re-sending it every
+// interval. Browsers using transport-wide congestion control still take REMB
+// as an upper bound. It runs until the peer connection closes or is replaced.
+func (s *Service) capUplinkBitrate(peerConn *webrtc.PeerConnection, r *room.Room, presenter *room.Participant) {
+	caps := s.bitrateCaps
+	if caps == nil {
+		return
+	}
+
+	ticker := time.NewTicker(caps.policy.Interval)
+	defer ticker.Stop()
+
+	sent := 0
+	changed := caps.changes()
+	for {
+		select {
+		case <-ticker.C:
+		case <-changed:
+		}
+		changed = caps.changes()
+		kbps := caps.capKbps(r.ID)
+
+		// A renegotiated connection has its own cap
+		if presenter.PeerConn != peerConn {
+			return
+		}
+		switch peerConn.ConnectionState() {
+		case webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateFailed:
+			return
+		case webrtc.PeerConnectionStateConnected:
+		default:
+			continue
+		}
+
+		var ssrcs []uint32
+		for _, receiver := range peerConn.GetReceivers() {
+			for _, track := range receiver.Tracks() {
+				if track.Kind() == webrtc.RTPCodecTypeVideo {
+					ssrcs = append(ssrcs, uint32(track.SSRC()))
+				}
+			}
+		}
+		if len(ssrcs) == 0 {
+			continue
+		}
+		if err := peerConn.WriteRTCP([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{
+			Bitrate: float32(kbps) * 1000,
+			SSRCs:   ssrcs,
+		}}); err != nil {
+			continue
+		}
+
+		if kbps == sent {
+			continue
+		}
+		if sent != 0 {
+			direction := "up"
+			if kbps < sent {
+				direction = "down"
+			}
+			bitrateCapChanges.WithLabelValues(direction).Inc()
+			log.Printf("[RTC] Presenter bitrate in room %s capped at %d kbps", r.ID, kbps)
+		}
+		sent = kbps
+	}
+}
@@ -0,0 +1,675 @@
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+)
+
+// States of a clip played into a room
+const (
+	ClipStatePlaying = "playing"
+	ClipStatePaused  = "paused"
+	ClipStateEnded   = "ended"   // Played to its end
+	ClipStateStopped = "stopped" // Stopped before its end
+	ClipStateFailed  = "failed"
+)
+
+var (
+	// ErrClipPlaying is returned when a room is already playing a clip.
+	ErrClipPlaying = errors.New("a clip is already playing in this room")
+	// ErrNoClip is returned when a room isn't playing a clip.
+	ErrNoClip = errors.New("no clip is playing in this room")
+	// ErrClipsUnavailable is returned when ffmpeg isn't configured.
+	ErrClipsUnavailable = errors.New("clip playback is not available")
+)
+
+const (
+	// clipSource and liveSource label the packets spliced into the
+	// presenter's tracks.
+	clipSource = "clip"
+	liveSource = "live"
+	// clipMTU bounds the payload of the clip's RTP packets, leaving room
+	// for headers and SRTP within common path MTUs.
+	clipMTU = 1200
+	// opusFrameStep is the RTP timestamp gap left between the last audio
+	// packet of one source and the first of the next: a 20 ms Opus frame.
+	opusFrameStep = 960
+)
+
+// ClipStatus is the state of a room's latest clip.
+type ClipStatus struct {
+	RoomID      string     `json:"roomId"`
+	RecordingID string     `json:"recordingId"`
+	Title       string     `json:"title"`
+	State       string     `json:"state"`
+	Start       int        `json:"start"`         // Seconds into the recording
+	End         int        `json:"end,omitempty"` // Seconds into the recording, 0 for its end
+	Position    int        `json:"position"`      // Seconds into the recording
+	StartedBy   string     `json:"startedBy"`
+	StartedAt   time.Time  `json:"startedAt"`
+	EndedAt     *time.Time `json:"endedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// Clip is a segment of a recording to play into a room.
+type Clip struct {
+	RecordingID string
+	Title       string
+	Path        string        // Of the recording's file
+	Input       io.ReadCloser // Read instead of Path when set, as for encrypted files; closed when the clip ends
+	Start       time.Duration
+	End         time.Duration // 0 plays to the end of the recording
+	StartedBy   string
+}
+
+// ClipPlayer plays segments of recordings into live rooms for the class to
+// watch together. ffmpeg re-encodes the segment to VP8 and Opus, and its
+// frames are sent at their own pace on the presenter's tracks in place of
+// the presenter's media, so viewers need no renegotiation and recordings of
+// the class include the clip. Rooms that played a clip keep the presenter's
+// media spliced in behind it, rewritten to one continuous stream.
+type ClipPlayer struct {
+	ffmpeg    string
+	videoKbps int
+	service   *Service
+
+	mu    sync.Mutex
+	clips map[string]*clipSession // By room ID, kept after they end for their status
+}
+
+// NewClipPlayer creates a player sending clips through service, encoding
+// their video at videoKbps with the ffmpeg binary at ffmpegPath. An empty
+// path disables it.
+func NewClipPlayer(service *Service, ffmpegPath string, videoKbps int) *ClipPlayer {
+	return &ClipPlayer{
+		ffmpeg:    ffmpegPath,
+		videoKbps: videoKbps,
+		service:   service,
+		clips:     make(map[string]*clipSession),
+	}
+}
+
+// Available reports whether clips can be played: ffmpeg is configured and
+// can be found.
+func (p *ClipPlayer) Available() bool {
+	if p.ffmpeg == "" {
+		return false
+	}
+	_, err := exec.LookPath(p.ffmpeg)
+	return err == nil
+}
+
+// Register subscribes the player to room events, so clips stop when their
+// presenter leaves or their class ends.
+func (p *ClipPlayer) Register(hooks *room.Hooks) {
+	stop := func(ev room.LifecycleEvent) {
+		if ev.Type == room.LifecyclePresenterLeft || ev.Type == room.LifecycleEnded {
+			go p.StopRoom(ev.RoomID, ev.SessionID)
+		}
+	}
+	hooks.OnParticipantLeft(stop)
+	hooks.OnClassEnded(stop)
+}
+
+// Play starts playing a clip into a live room, whose presenter's stream must
+// be ready. The clip's input is closed on failure.
+func (p *ClipPlayer) Play(r *room.Room, clip Clip) (ClipStatus, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fail := func(status ClipStatus, err error) (ClipStatus, error) {
+		if clip.Input != nil {
+			clip.Input.Close()
+		}
+		return status, err
+	}
+	if current, ok := p.clips[r.ID]; ok && current.active() {
+		return fail(current.status(), ErrClipPlaying)
+	}
+	if p.ffmpeg == "" {
+		return fail(ClipStatus{}, ErrClipsUnavailable)
+	}
+	if presenter := r.GetPresenter(); presenter == nil || presenter.VideoTrack == nil || !r.IsFullyReady() {
+		return fail(ClipStatus{}, ErrStreamNotReady)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := p.command(ctx, clip)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return fail(ClipStatus{}, err)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fail(ClipStatus{}, fmt.Errorf("ffmpeg: %w", err))
+	}
+
+	c := &clipSession{
+		player: p,
+		room:   r,
+		clip:   clip,
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		vp8:    &codecs.VP8Payloader{EnablePictureID: true},
+		ssrc:   rand.Uint32(),
+		state: ClipStatus{
+			RoomID:      r.ID,
+			RecordingID: clip.RecordingID,
+			Title:       clip.Title,
+			State:       ClipStatePlaying,
+			Start:       int(clip.Start.Seconds()),
+			End:         int(clip.End.Seconds()),
+			Position:    int(clip.Start.Seconds()),
+			StartedBy:   clip.StartedBy,
+			StartedAt:   time.Now(),
+		},
+	}
+	p.clips[r.ID] = c
+	p.service.startSplice(r)
+	go c.run(cmd, stdout, stderr)
+
+	log.Printf("[Clips] ▶️ Playing %s from %v into room %s for %s", clip.Title, clip.Start, r.ID, clip.StartedBy)
+	status := c.status()
+	c.broadcast(status)
+	return status, nil
+}
+
+// command returns the ffmpeg command writing the clip's segment to its
+// stdout as WebM, encoded for real-time sending: VP8 with a keyframe every
+// two seconds for viewers recovering from loss, and 48 kHz stereo Opus as
+// WebRTC negotiates it.
+func (p *ClipPlayer) command(ctx context.Context, clip Clip) *exec.Cmd {
+	input := clip.Path
+	if clip.Input != nil {
+		input = "pipe:0"
+	}
+	args := []string{"-hide_banner", "-nostats", "-loglevel", "error",
+		"-ss", seconds(clip.Start), "-i", input}
+	if clip.End > clip.Start {
+		args = append(args, "-t", seconds(clip.End-clip.Start))
+	}
+	args = append(args,
+		"-map", "0:v:0?", "-map", "0:a:0?", "-sn", "-dn",
+		"-vf", "scale=-2:'min(720,ih)',fps=30",
+		"-c:v", "libvpx", "-deadline", "realtime", "-cpu-used", "8",
+		"-b:v", strconv.Itoa(p.videoKbps)+"k", "-g", "60", "-auto-alt-ref", "0",
+		"-c:a", "libopus", "-b:a", "96k", "-ar", "48000", "-ac", "2",
+		"-cluster_time_limit", "500", "-f", "webm", "pipe:1",
+	)
+
+	cmd := exec.CommandContext(ctx, p.ffmpeg, args...)
+	if clip.Input != nil {
+		cmd.Stdin = clip.Input
+	}
+	return cmd
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// Pause pauses a room's clip, holding viewers on its last frame.
+func (p *ClipPlayer) Pause(roomID string) (ClipStatus, error) {
+	c, err := p.current(roomID)
+	if err != nil {
+		return ClipStatus{}, err
+	}
+	return c.pause(true), nil
+}
+
+// Resume resumes a room's paused clip.
+func (p *ClipPlayer) Resume(roomID string) (ClipStatus, error) {
+	c, err := p.current(roomID)
+	if err != nil {
+		return ClipStatus{}, err
+	}
+	return c.pause(false), nil
+}
+
+// Stop stops a room's clip and returns its final status. Viewers go back to
+// the presenter's media.
+func (p *ClipPlayer) Stop(roomID string) (ClipStatus, error) {
+	c, err := p.current(roomID)
+	if err != nil {
+		return ClipStatus{}, err
+	}
+	return c.stop(), nil
+}
+
+// Status returns the state of a room's latest clip.
+func (p *ClipPlayer) Status(roomID string) (ClipStatus, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.clips[roomID]
+	if !ok {
+		return ClipStatus{}, false
+	}
+	return c.status(), true
+}
+
+// StopRoom stops the clip of a room session that ended or lost its
+// presenter. Clips of a newer room with the same ID are left alone.
+func (p *ClipPlayer) StopRoom(roomID, sessionID string) {
+	p.mu.Lock()
+	c, ok := p.clips[roomID]
+	p.mu.Unlock()
+
+	if ok && c.room.SessionID == sessionID && c.active() {
+		c.stop()
+	}
+}
+
+// StopAll stops every clip, for shutdown.
+func (p *ClipPlayer) StopAll() {
+	p.mu.Lock()
+	clips := make([]*clipSession, 0, len(p.clips))
+	for _, c := range p.clips {
+		clips = append(clips, c)
+	}
+	p.mu.Unlock()
+
+	for _, c := range clips {
+		if c.active() {
+			c.stop()
+		}
+	}
+}
+
+// current returns a room's clip if it is playing or paused.
+func (p *ClipPlayer) current(roomID string) (*clipSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.clips[roomID]
+	if !ok || !c.active() {
+		return nil, ErrNoClip
+	}
+	return c, nil
+}
+
+// clipSession is one clip played into a room. run reads ffmpeg's output and
+// sends each frame when it is due, which pauses push back.
+type clipSession struct {
+	player *ClipPlayer
+	room   *room.Room
+	clip   Clip
+	cancel context.CancelFunc
+	wake   chan struct{} // Signalled on pause, resume and stop
+	done   chan struct{}
+
+	vp8      *codecs.VP8Payloader
+	ssrc     uint32
+	videoSeq uint16
+	audioSeq uint16
+
+	mu        sync.Mutex
+	state     ClipStatus
+	stopped   bool      // By a user, rather than ending
+	origin    time.Time // When the clip's first frame was due, moved on by pauses
+	pausedAt  time.Time
+	pausedFor time.Duration // In total, which RTP timestamps include
+}
+
+// status returns a copy of the session's state.
+func (c *clipSession) status() ClipStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *clipSession) active() bool {
+	state := c.status().State
+	return state == ClipStatePlaying || state == ClipStatePaused
+}
+
+// pause pauses or resumes the clip and returns its state.
+func (c *clipSession) pause(paused bool) ClipStatus {
+	c.mu.Lock()
+	switch {
+	case paused && c.state.State == ClipStatePlaying:
+		c.state.State = ClipStatePaused
+		c.pausedAt = time.Now()
+	case !paused && c.state.State == ClipStatePaused:
+		c.state.State = ClipStatePlaying
+		if !c.origin.IsZero() {
+			gap := time.Since(c.pausedAt)
+			c.origin = c.origin.Add(gap)
+			c.pausedFor += gap
+		}
+	default:
+		status := c.state
+		c.mu.Unlock()
+		return status
+	}
+	status := c.state
+	c.mu.Unlock()
+
+	c.signal()
+	c.broadcast(status)
+	log.Printf("[Clips] Clip in room %s %s at %ds", c.room.ID, status.State, status.Position)
+	return status
+}
+
+// stop ends the clip, waits for ffmpeg to exit and returns the final state.
+func (c *clipSession) stop() ClipStatus {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+
+	c.signal()
+	c.cancel()
+	<-c.done
+	return c.status()
+}
+
+func (c *clipSession) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the frames ffmpeg writes until the clip ends or is stopped,
+// then hands the tracks back to the presenter.
+func (c *clipSession) run(cmd *exec.Cmd, stdout io.Reader, stderr *bytes.Buffer) {
+	defer close(c.done)
+
+	reader := newWebMReader(stdout)
+	var readErr error
+	for {
+		frame, err := reader.Next()
+		if err != nil {
+			readErr = err
+			break
+		}
+		if !c.wait(frame.at) {
+			break
+		}
+		c.send(frame)
+	}
+
+	c.cancel()
+	waitErr := cmd.Wait()
+	if c.clip.Input != nil {
+		c.clip.Input.Close()
+	}
+	c.player.service.endSplice(c.room)
+
+	c.mu.Lock()
+	now := time.Now()
+	c.state.EndedAt = &now
+	switch {
+	case c.stopped:
+		c.state.State = ClipStateStopped
+	case readErr == io.EOF && waitErr == nil:
+		c.state.State = ClipStateEnded
+	default:
+		c.state.State = ClipStateFailed
+		c.state.Error = strings.TrimSpace(stderr.String())
+		if c.state.Error == "" && waitErr != nil {
+			c.state.Error = waitErr.Error()
+		} else if c.state.Error == "" {
+			c.state.Error = readErr.Error()
+		}
+	}
+	status := c.state
+	c.mu.Unlock()
+
+	c.broadcast(status)
+	if status.State == ClipStateFailed {
+		log.Printf("[Clips] ❌ Clip in room %s failed: %s", c.room.ID, status.Error)
+		return
+	}
+	log.Printf("[Clips] ⏹️ Clip in room %s %s at %ds", c.room.ID, status.State, status.Position)
+}
+
+// wait blocks until a frame at offset at into the clip is due, reporting
+// false if the clip was stopped first. The first frame sets the pace.
+func (c *clipSession) wait(at time.Duration) bool {
+	for {
+		c.mu.Lock()
+		if c.stopped {
+			c.mu.Unlock()
+			return false
+		}
+		if c.state.State == ClipStatePaused {
+			c.mu.Unlock()
+			<-c.wake
+			continue
+		}
+		if c.origin.IsZero() {
+			c.origin = time.Now().Add(-at)
+		}
+		delay := time.Until(c.origin.Add(at))
+		c.mu.Unlock()
+
+		if delay <= 0 {
+			return true
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-c.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// send packetizes a frame and splices it into the presenter's tracks.
+func (c *clipSession) send(frame webmFrame) {
+	c.mu.Lock()
+	elapsed := frame.at + c.pausedFor
+	c.state.Position = int((c.clip.Start + frame.at).Seconds())
+	c.mu.Unlock()
+
+	if !frame.video {
+		pkt := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				SequenceNumber: c.audioSeq,
+				Timestamp:      uint32(elapsed * opusClockRate / time.Second),
+				SSRC:           c.ssrc + 1,
+			},
+			Payload: frame.data,
+		}
+		c.audioSeq++
+		c.player.service.writeClip(c.room, false, pkt)
+		return
+	}
+
+	payloads := c.vp8.Payload(clipMTU, frame.data)
+	for i, payload := range payloads {
+		pkt := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         i == len(payloads)-1,
+				SequenceNumber: c.videoSeq,
+				Timestamp:      uint32(elapsed * vp8ClockRate / time.Second),
+				SSRC:           c.ssrc,
+			},
+			Payload: payload,
+		}
+		c.videoSeq++
+		c.player.service.writeClip(c.room, true, pkt)
+	}
+}
+
+// broadcast tells everyone in the room the clip's state with a
+// "clip-state" message.
+func (c *clipSession) broadcast(status ClipStatus) {
+	c.room.BroadcastToAll(map[string]interface{}{
+		"type":    "clip-state",
+		"payload": status,
+	}, "")
+}
+
+// roomSplice splices clips into a room's shared presenter tracks. It is
+// created with the room's first clip; from then the presenter's media goes
+// through its outputs too, so each track stays one continuous stream.
+// Simulcast video goes through the room's simulcast group instead.
+type roomSplice struct {
+	mu    sync.Mutex
+	clip  bool // A clip holds the tracks
+	video *layerOutput
+	audio *layerOutput
+}
+
+// outputLocked returns the output to a shared track, starting a new one if
+// the presenter's tracks were replaced. Callers must hold sp.mu.
+func (sp *roomSplice) outputLocked(track *webrtc.TrackLocalStaticRTP, video bool) *layerOutput {
+	o, step := &sp.audio, uint32(opusFrameStep)
+	if video {
+		o, step = &sp.video, 0
+	}
+	if *o == nil || (*o).track != track {
+		*o = &layerOutput{track: track, target: liveSource, step: step}
+		if sp.clip {
+			(*o).target = clipSource
+		}
+	}
+	return *o
+}
+
+// write sends a packet from source to a shared track if the source holds
+// it. It returns the packet as rewritten and whether it was written.
+func (sp *roomSplice) write(track *webrtc.TrackLocalStaticRTP, video bool, source string, pkt *rtp.Packet) (*rtp.Packet, bool) {
+	keyframe := true
+	if video {
+		keyframe, _ = vp8Keyframe(pkt.Payload)
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.outputLocked(track, video).write(source, pkt, keyframe)
+}
+
+// setClip hands the shared tracks to the clip or back to the presenter,
+// switching at the next keyframe of video.
+func (sp *roomSplice) setClip(clip bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	sp.clip = clip
+	target := liveSource
+	if clip {
+		target = clipSource
+	}
+	for _, o := range []*layerOutput{sp.video, sp.audio} {
+		if o != nil {
+			o.target = target
+		}
+	}
+}
+
+// spliceFor returns a room's splice, or nil if it never played a clip.
+func (s *Service) spliceFor(roomID string) *roomSplice {
+	s.splicesMu.Lock()
+	defer s.splicesMu.Unlock()
+
+	return s.splices[roomID]
+}
+
+// startSplice hands a room's shared tracks to a clip.
+func (s *Service) startSplice(r *room.Room) {
+	s.splicesMu.Lock()
+	sp, ok := s.splices[r.ID]
+	if !ok {
+		sp = &roomSplice{}
+		s.splices[r.ID] = sp
+	}
+	s.splicesMu.Unlock()
+
+	sp.setClip(true)
+	if g := s.simulcastFor(r.ID); g != nil {
+		g.setClip(true)
+	}
+}
+
+// endSplice hands a room's shared tracks back to the presenter, whose next
+// keyframe they resume at.
+func (s *Service) endSplice(r *room.Room) {
+	if sp := s.spliceFor(r.ID); sp != nil {
+		sp.setClip(false)
+	}
+	if g := s.simulcastFor(r.ID); g != nil {
+		g.setClip(false)
+	}
+	s.RequestKeyframe(r)
+}
+
+// writeClip sends a packet of a clip on the presenter's shared tracks and
+// to the room's media taps.
+func (s *Service) writeClip(r *room.Room, video bool, pkt *rtp.Packet) {
+	if video {
+		if g := s.simulcastFor(r.ID); g != nil {
+			g.writeClip(pkt)
+			return
+		}
+	}
+	sp := s.spliceFor(r.ID)
+	presenter := r.GetPresenter()
+	if sp == nil || presenter == nil {
+		return
+	}
+	track := presenter.AudioTrack
+	if video {
+		track = presenter.VideoTrack
+	}
+	if track == nil {
+		return
+	}
+	if out, ok := sp.write(track, video, clipSource, pkt); ok {
+		if data, err := out.Marshal(); err == nil {
+			r.TapMedia(video, data)
+		}
+	}
+}
+
+// forwardSpliced forwards a packet of the presenter's unless a clip holds
+// the track, and taps it as forwarded. It returns the packets sent and
+// failed.
+func (s *Service) forwardSpliced(r *room.Room, sp *roomSplice, track *webrtc.TrackLocalStaticRTP, video bool, data []byte) (int, int) {
+	var pkt rtp.Packet
+	if err := pkt.Unmarshal(data); err != nil {
+		return 0, 0
+	}
+	out, ok := sp.write(track, video, liveSource, &pkt)
+	if ok {
+		if data, err := out.Marshal(); err == nil {
+			r.TapMedia(video, data)
+		}
+	}
+	return outputResult(out, ok)
+}
+
+// dropSplice forgets a room's splice once its presenter left.
+func (s *Service) dropSplice(ev room.LifecycleEvent) {
+	if ev.Type != room.LifecyclePresenterLeft && ev.Type != room.LifecycleEnded {
+		return
+	}
+
+	s.splicesMu.Lock()
+	defer s.splicesMu.Unlock()
+
+	delete(s.splices, ev.RoomID)
+}
@@ -0,0 +1,73 @@
+package rtc
+
+import (
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// healthReportInterval is how often a forwarded track's results are passed
+// to the health sink.
+const healthReportInterval = time.Second
+
+// HealthSink receives reports on the health of rooms' media, as alerting
+// watches it. It is called from the media path, so it must not block.
+type HealthSink interface {
+	// PresenterICEFailed reports that a presenter's connection failed.
+	PresenterICEFailed(roomID string)
+	// ForwardResults reports packets of the presenter's media forwarded
+	// in a room since the previous report, and how many of them failed.
+	ForwardResults(roomID string, sent, failed int)
+}
+
+// SetHealthSink sets where reports on rooms' media health go. Set it before
+// any presenter connects.
+func (s *Service) SetHealthSink(sink HealthSink) {
+	s.health = sink
+}
+
+// forwardCounter batches a forwarded track's results for the health sink.
+type forwardCounter struct {
+	sink         HealthSink // nil counts nothing
+	roomID       string
+	sent, failed int
+	since        time.Time
+}
+
+func newForwardCounter(sink HealthSink, roomID string) *forwardCounter {
+	return &forwardCounter{sink: sink, roomID: roomID, since: time.Now()}
+}
+
+// add counts forwarding results, reporting them at most every
+// healthReportInterval.
+func (c *forwardCounter) add(sent, failed int) {
+	if c.sink == nil {
+		return
+	}
+	c.sent += sent
+	c.failed += failed
+	if time.Since(c.since) >= healthReportInterval {
+		c.flush()
+	}
+}
+
+// flush reports the results counted since the last report.
+func (c *forwardCounter) flush() {
+	if c.sink == nil || c.sent+c.failed == 0 {
+		return
+	}
+	c.sink.ForwardResults(c.roomID, c.sent, c.failed)
+	c.sent, c.failed, c.since = 0, 0, time.Now()
+}
+
+// outputResult counts a layer output's write as sent or failed. Packets not
+// of the output's layer count as neither.
+func outputResult(out *rtp.Packet, ok bool) (sent, failed int) {
+	switch {
+	case ok:
+		return 1, 0
+	case out != nil:
+		return 0, 1
+	}
+	return 0, 0
+}
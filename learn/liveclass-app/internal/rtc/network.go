@@ -0,0 +1,153 @@
+package rtc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/logging"
+	"github.com/pion/webrtc/v3"
+)
+
+// tcpReadBufferSize is the per-connection buffer for ICE over TCP.
+const tcpReadBufferSize = 8
+
+// NetworkPolicy controls the ICE candidates the SFU gathers and advertises.
+// The defaults suit a server with a public address; behind Docker or
+// Kubernetes networking pion finds only the container's private addresses,
+// which viewers can't reach without a NAT 1:1 mapping.
+type NetworkPolicy struct {
+	// NetworkTypes are the networks to gather candidates on: udp4, udp6,
+	// tcp4 and tcp6, or udp and tcp for both families. Empty gathers on
+	// all of them, TCP only when there's a TCP port.
+	NetworkTypes []string
+	// UDPPortMin and UDPPortMax bound the ports of UDP host candidates, so
+	// a narrow range can be published from a container. Zero is any port.
+	UDPPortMin int
+	UDPPortMax int
+	// UDPMuxPort, if set, carries the UDP media of every peer connection
+	// on one port instead of a port each, for firewalls that only open
+	// well-known ports. It replaces the port range.
+	UDPMuxPort int
+	// TCPPort is the port passive ICE-TCP candidates are served on, a
+	// fallback for networks that block UDP. It's required for the tcp
+	// network types.
+	TCPPort int
+	// NAT1To1IPs are the public addresses the SFU is reachable on, each
+	// either an IP or "public/private" to map one local address.
+	NAT1To1IPs []string
+	// NAT1To1CandidateType is "host" to advertise the public addresses in
+	// place of the private ones, or "srflx" to add them alongside.
+	NAT1To1CandidateType string
+}
+
+// natSrflx reports whether the NAT mapping adds server reflexive candidates,
+// which pion won't combine with STUN servers of its own.
+func (p NetworkPolicy) natSrflx() bool {
+	return len(p.NAT1To1IPs) > 0 && p.NAT1To1CandidateType == "srflx"
+}
+
+// settingEngine returns a setting engine applying the policy.
+func (p NetworkPolicy) settingEngine() (webrtc.SettingEngine, error) {
+	var s webrtc.SettingEngine
+
+	types, tcp, err := parseNetworkTypes(p.NetworkTypes)
+	if err != nil {
+		return s, err
+	}
+	if len(types) > 0 {
+		s.SetNetworkTypes(types)
+	}
+
+	logger := logging.NewDefaultLoggerFactory().NewLogger("ice")
+
+	if p.UDPMuxPort > 0 && (p.UDPPortMin != 0 || p.UDPPortMax != 0) {
+		return s, fmt.Errorf("a UDP mux port can't be combined with a UDP port range")
+	}
+	if p.UDPMuxPort > 0 {
+		// Listening on every address, the mux offers a candidate per interface
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: p.UDPMuxPort})
+		if err != nil {
+			return s, fmt.Errorf("failed to listen for ICE over UDP: %w", err)
+		}
+		s.SetICEUDPMux(webrtc.NewICEUDPMux(logger, conn))
+	} else if p.UDPPortMin != 0 || p.UDPPortMax != 0 {
+		if p.UDPPortMin < 1 || p.UDPPortMax > 65535 || p.UDPPortMax < p.UDPPortMin {
+			return s, fmt.Errorf("invalid UDP port range %d-%d", p.UDPPortMin, p.UDPPortMax)
+		}
+		if err := s.SetEphemeralUDPPortRange(uint16(p.UDPPortMin), uint16(p.UDPPortMax)); err != nil {
+			return s, err
+		}
+	}
+
+	if tcp && p.TCPPort <= 0 {
+		return s, fmt.Errorf("tcp network types need a TCP port")
+	}
+	if p.TCPPort > 0 && (tcp || len(types) == 0) {
+		listener, err := net.Listen("tcp", ":"+strconv.Itoa(p.TCPPort))
+		if err != nil {
+			return s, fmt.Errorf("failed to listen for ICE over TCP: %w", err)
+		}
+		s.SetICETCPMux(webrtc.NewICETCPMux(logger, listener, tcpReadBufferSize))
+	}
+
+	if len(p.NAT1To1IPs) > 0 {
+		candidateType, err := webrtc.NewICECandidateType(p.NAT1To1CandidateType)
+		if err != nil || (candidateType != webrtc.ICECandidateTypeHost && candidateType != webrtc.ICECandidateTypeSrflx) {
+			return s, fmt.Errorf("NAT 1:1 candidate type must be host or srflx, not %q", p.NAT1To1CandidateType)
+		}
+		for _, mapping := range p.NAT1To1IPs {
+			for _, ip := range strings.Split(mapping, "/") {
+				if net.ParseIP(ip) == nil {
+					return s, fmt.Errorf("invalid NAT 1:1 address %q", mapping)
+				}
+			}
+		}
+		s.SetNAT1To1IPs(p.NAT1To1IPs, candidateType)
+	}
+
+	return s, nil
+}
+
+// newAPI returns a WebRTC API with the default codecs and interceptors, as
+// webrtc.NewPeerConnection uses, and the given settings. It also receives
+// simulcast video.
+func newAPI(settings webrtc.SettingEngine) (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	if err := registerSimulcastExtensions(m); err != nil {
+		return nil, err
+	}
+	i := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
+		return nil, err
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i), webrtc.WithSettingEngine(settings)), nil
+}
+
+// parseNetworkTypes parses ICE network type names, reporting whether any
+// are TCP.
+func parseNetworkTypes(names []string) ([]webrtc.NetworkType, bool, error) {
+	var types []webrtc.NetworkType
+	tcp := false
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		expanded := []string{name}
+		if name == "udp" || name == "tcp" {
+			expanded = []string{name + "4", name + "6"}
+		}
+		for _, raw := range expanded {
+			t, err := webrtc.NewNetworkType(raw)
+			if err != nil {
+				return nil, false, fmt.Errorf("unknown ICE network type %q", name)
+			}
+			types = append(types, t)
+			tcp = tcp || t.Protocol() == "tcp"
+		}
+	}
+	return types, tcp, nil
+}
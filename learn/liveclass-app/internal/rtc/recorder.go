@@ -0,0 +1,517 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+)
+
+// States of a server-side recording
+const (
+	RecordingStateRecording = "recording"
+	RecordingStateStopped   = "stopped"
+	RecordingStateFailed    = "failed"
+)
+
+var (
+	// ErrAlreadyRecording is returned when a room is already being recorded.
+	ErrAlreadyRecording = errors.New("room is already being recorded")
+	// ErrNotRecording is returned when a room isn't being recorded.
+	ErrNotRecording = errors.New("room is not being recorded")
+)
+
+const (
+	// recordQueue is how many packets wait between the media path and the
+	// file; more are dropped rather than holding up viewers.
+	recordQueue = 2048
+	// recordMaxLate is how many packets a missing one is waited for before
+	// the frame it belongs to is given up.
+	recordMaxLate = 128
+	// keyframeRetry is how often a keyframe is asked for while the video
+	// can't continue without one.
+	keyframeRetry = 2 * time.Second
+)
+
+// Clock rates of the presenter's media
+const (
+	vp8ClockRate  = 90000
+	opusClockRate = 48000
+)
+
+// RecordingStatus is the state of a room's latest server-side recording.
+type RecordingStatus struct {
+	RoomID     string     `json:"roomId"`
+	ScheduleID string     `json:"scheduleId"`
+	State      string     `json:"state"`
+	StartedBy  string     `json:"startedBy"`
+	StartedAt  time.Time  `json:"startedAt"`
+	EndedAt    *time.Time `json:"endedAt,omitempty"`
+	Duration   int        `json:"duration"` // Seconds
+	Size       int64      `json:"size"`     // Bytes
+	Error      string     `json:"error,omitempty"`
+}
+
+// Take is a finished recording file, kept until its class ends.
+type Take struct {
+	ScheduleID string
+	RoomID     string
+	Path       string
+	Size       int64
+	Duration   time.Duration
+	StartedAt  time.Time
+	StartedBy  string
+}
+
+// Recorder records the presenter's media in live classes to WebM files, as
+// the SFU forwards it: nothing is decoded or re-encoded. A class can be
+// recorded in several takes; they are handed to the take sink when it ends.
+type Recorder struct {
+	dir      string
+	keyframe func(*room.Room) error
+	sink     func(scheduleID string, takes []Take)
+
+	mu       sync.Mutex
+	sessions map[string]*recordingSession // By room ID, kept after they end for their status
+	takes    map[string][]Take            // By schedule ID, until the class ends
+}
+
+// NewRecorder creates a recorder writing files to dir.
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{
+		dir:      dir,
+		sessions: make(map[string]*recordingSession),
+		takes:    make(map[string][]Take),
+	}
+}
+
+// SetKeyframeRequester sets how a room's presenter is asked for a keyframe,
+// which a recording starts with. Set it before starting any recording.
+func (rec *Recorder) SetKeyframeRequester(keyframe func(*room.Room) error) {
+	rec.keyframe = keyframe
+}
+
+// SetTakeSink sets what is done with a class's takes when it ends, or when
+// the server shuts down during it. The sink owns the files. Set it before
+// starting any recording.
+func (rec *Recorder) SetTakeSink(sink func(scheduleID string, takes []Take)) {
+	rec.sink = sink
+}
+
+// Start records a live room for the class scheduleID, for the user
+// startedBy.
+func (rec *Recorder) Start(r *room.Room, scheduleID, startedBy string) (RecordingStatus, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if current, ok := rec.sessions[r.ID]; ok && current.active() {
+		return current.status(), ErrAlreadyRecording
+	}
+	if err := os.MkdirAll(rec.dir, 0755); err != nil {
+		return RecordingStatus{}, err
+	}
+
+	startedAt := time.Now()
+	name := fmt.Sprintf("%s_%s_live.webm", scheduleID, startedAt.Format("20060102_150405"))
+	path := filepath.Join(rec.dir, name)
+	file, err := os.Create(path + ".part")
+	if err != nil {
+		return RecordingStatus{}, err
+	}
+
+	s := &recordingSession{
+		recorder: rec,
+		room:     r,
+		path:     path,
+		file:     file,
+		webm:     newWebMWriter(file),
+		tapID:    "recording:" + startedAt.Format(time.RFC3339Nano),
+		started:  startedAt,
+		packets:  make(chan tappedPacket, recordQueue),
+		stopped:  make(chan struct{}),
+		done:     make(chan struct{}),
+		state: RecordingStatus{
+			RoomID:     r.ID,
+			ScheduleID: scheduleID,
+			State:      RecordingStateRecording,
+			StartedBy:  startedBy,
+			StartedAt:  startedAt,
+		},
+	}
+	rec.sessions[r.ID] = s
+	r.AddMediaTap(s.tapID, s)
+	go s.run()
+
+	log.Printf("[Recorder] 🔴 Recording room %s for %s", r.ID, startedBy)
+	return s.status(), nil
+}
+
+// Stop stops a room's recording and returns its final status. The file is
+// kept as a take of the class.
+func (rec *Recorder) Stop(roomID string) (RecordingStatus, error) {
+	rec.mu.Lock()
+	s, ok := rec.sessions[roomID]
+	rec.mu.Unlock()
+
+	if !ok || !s.active() {
+		return RecordingStatus{}, ErrNotRecording
+	}
+	return s.stop(), nil
+}
+
+// Status returns the state of a room's latest recording.
+func (rec *Recorder) Status(roomID string) (RecordingStatus, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	s, ok := rec.sessions[roomID]
+	if !ok {
+		return RecordingStatus{}, false
+	}
+	return s.status(), true
+}
+
+// StopRoom stops the recording of a room session that ended, keeping the
+// take until the class ends. Recordings of a newer room with the same ID
+// are left alone.
+func (rec *Recorder) StopRoom(roomID, sessionID string) {
+	rec.mu.Lock()
+	s, ok := rec.sessions[roomID]
+	rec.mu.Unlock()
+
+	if ok && s.room.SessionID == sessionID && s.active() {
+		s.stop()
+	}
+}
+
+// End stops the recording of a class, if any, and hands its takes to the
+// sink in the order they were recorded.
+func (rec *Recorder) End(scheduleID string) {
+	rec.mu.Lock()
+	var sessions []*recordingSession
+	for roomID, s := range rec.sessions {
+		if s.status().ScheduleID == scheduleID {
+			sessions = append(sessions, s)
+			delete(rec.sessions, roomID)
+		}
+	}
+	rec.mu.Unlock()
+
+	for _, s := range sessions {
+		if s.active() {
+			s.stop()
+		}
+	}
+	rec.handOver(scheduleID)
+}
+
+// StopAll stops every recording, for shutdown, and hands all takes to the
+// sink.
+func (rec *Recorder) StopAll() {
+	rec.mu.Lock()
+	sessions := make([]*recordingSession, 0, len(rec.sessions))
+	for _, s := range rec.sessions {
+		sessions = append(sessions, s)
+	}
+	rec.mu.Unlock()
+
+	for _, s := range sessions {
+		if s.active() {
+			s.stop()
+		}
+	}
+
+	rec.mu.Lock()
+	scheduleIDs := make([]string, 0, len(rec.takes))
+	for scheduleID := range rec.takes {
+		scheduleIDs = append(scheduleIDs, scheduleID)
+	}
+	rec.mu.Unlock()
+
+	for _, scheduleID := range scheduleIDs {
+		rec.handOver(scheduleID)
+	}
+}
+
+// handOver passes a class's takes to the sink and forgets them.
+func (rec *Recorder) handOver(scheduleID string) {
+	rec.mu.Lock()
+	takes := rec.takes[scheduleID]
+	delete(rec.takes, scheduleID)
+	rec.mu.Unlock()
+
+	if len(takes) == 0 {
+		return
+	}
+	if rec.sink == nil {
+		log.Printf("[Recorder] ⚠️ No sink for %d recordings of %s, left in %s", len(takes), scheduleID, rec.dir)
+		return
+	}
+	rec.sink(scheduleID, takes)
+}
+
+// addTake keeps a finished file until its class ends.
+func (rec *Recorder) addTake(take Take) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.takes[take.ScheduleID] = append(rec.takes[take.ScheduleID], take)
+}
+
+// tappedPacket is a copy of a presenter RTP packet with its arrival time.
+type tappedPacket struct {
+	video bool
+	data  []byte
+	at    time.Time
+}
+
+// recordingSession is one take of a room's recording. Packets are copied
+// off the media path and written to the file by run.
+type recordingSession struct {
+	recorder *Recorder
+	room     *room.Room
+	path     string // Of the finished file; written to with a .part suffix
+	file     *os.File
+	webm     *webmWriter
+	tapID    string
+	started  time.Time
+
+	packets  chan tappedPacket
+	dropped  atomic.Int64
+	size     atomic.Int64
+	stopped  chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu    sync.Mutex
+	state RecordingStatus
+}
+
+// WriteRTP queues a copy of a packet of the presenter's media.
+func (s *recordingSession) WriteRTP(video bool, pkt []byte) {
+	select {
+	case s.packets <- tappedPacket{video: video, data: append([]byte(nil), pkt...), at: time.Now()}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// status returns a copy of the session's state.
+func (s *recordingSession) status() RecordingStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.state
+	if status.State == RecordingStateRecording {
+		status.Duration = int(time.Since(status.StartedAt).Seconds())
+		status.Size = s.size.Load()
+	}
+	return status
+}
+
+func (s *recordingSession) active() bool {
+	return s.status().State == RecordingStateRecording
+}
+
+// stop ends the take, waits for the file to be finished and returns the
+// final state.
+func (s *recordingSession) stop() RecordingStatus {
+	s.stopOnce.Do(func() { close(s.stopped) })
+	<-s.done
+	return s.status()
+}
+
+// run writes queued packets to the file until the take is stopped or
+// writing fails.
+func (s *recordingSession) run() {
+	defer close(s.done)
+	defer s.room.RemoveMediaTap(s.tapID)
+
+	video := newTrackClock(vp8ClockRate, func() *samplebuilder.SampleBuilder {
+		return samplebuilder.New(recordMaxLate, &codecs.VP8Packet{}, vp8ClockRate)
+	})
+	audio := newTrackClock(opusClockRate, func() *samplebuilder.SampleBuilder {
+		return samplebuilder.New(recordMaxLate, &codecs.OpusPacket{}, opusClockRate)
+	})
+	needKeyframe := true
+	s.requestKeyframe()
+
+	ticker := time.NewTicker(keyframeRetry)
+	defer ticker.Stop()
+
+	var err error
+	for err == nil {
+		select {
+		case p := <-s.packets:
+			var pkt rtp.Packet
+			if pkt.Unmarshal(p.data) != nil {
+				continue
+			}
+			offset := p.at.Sub(s.started)
+			if p.video {
+				// A reconnected presenter starts a new stream, which needs a keyframe
+				if video.follow(pkt.SSRC, pkt.Timestamp, offset) {
+					needKeyframe = true
+				}
+				err = video.push(&pkt, func(frame []byte, t time.Duration, dropped bool) error {
+					if dropped {
+						needKeyframe = true
+					}
+					if needKeyframe && !isVP8Keyframe(frame) {
+						return nil
+					}
+					needKeyframe = false
+					return s.webm.WriteVideo(frame, t)
+				})
+			} else {
+				audio.follow(pkt.SSRC, pkt.Timestamp, offset)
+				err = audio.push(&pkt, func(packet []byte, t time.Duration, _ bool) error {
+					return s.webm.WriteAudio(packet, t)
+				})
+			}
+			s.size.Store(s.webm.Size())
+
+		case <-ticker.C:
+			if needKeyframe || !s.webm.Started() {
+				s.requestKeyframe()
+			}
+
+		case <-s.stopped:
+			s.finish(nil)
+			return
+		}
+	}
+	s.finish(err)
+}
+
+// finish closes the file and records how the take ended. Files with any
+// media are kept as takes of the class, even if writing failed part way.
+func (s *recordingSession) finish(writeErr error) {
+	started := s.webm.Started()
+	closeErr := errors.Join(s.webm.Close(), s.file.Close())
+	partPath := s.file.Name()
+
+	s.mu.Lock()
+	now := time.Now()
+	s.state.EndedAt = &now
+	s.state.Duration = int(s.webm.last.Seconds())
+	s.state.Size = s.webm.Size()
+	s.state.State = RecordingStateStopped
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		s.state.State = RecordingStateFailed
+		s.state.Error = err.Error()
+	} else if !started {
+		s.state.State = RecordingStateFailed
+		s.state.Error = "no video was received"
+	}
+	state := s.state
+	s.mu.Unlock()
+
+	if dropped := s.dropped.Load(); dropped > 0 {
+		log.Printf("[Recorder] ⚠️ Dropped %d packets recording room %s", dropped, s.room.ID)
+	}
+	if !started || closeErr != nil {
+		os.Remove(partPath)
+		log.Printf("[Recorder] ❌ Recording of room %s failed: %s", s.room.ID, state.Error)
+		return
+	}
+	if err := os.Rename(partPath, s.path); err != nil {
+		log.Printf("[Recorder] ❌ Failed to keep the recording of room %s: %v", s.room.ID, err)
+		return
+	}
+	if writeErr != nil {
+		log.Printf("[Recorder] ❌ Recording of room %s stopped early: %v", s.room.ID, writeErr)
+	} else {
+		log.Printf("[Recorder] ⏹️ Stopped recording room %s after %ds", s.room.ID, state.Duration)
+	}
+
+	s.recorder.addTake(Take{
+		ScheduleID: state.ScheduleID,
+		RoomID:     state.RoomID,
+		Path:       s.path,
+		Size:       state.Size,
+		Duration:   s.webm.last,
+		StartedAt:  state.StartedAt,
+		StartedBy:  state.StartedBy,
+	})
+}
+
+// requestKeyframe asks the presenter for a keyframe. Without a presenter
+// the recording waits for one to (re)connect.
+func (s *recordingSession) requestKeyframe() {
+	if s.recorder.keyframe == nil {
+		return
+	}
+	if err := s.recorder.keyframe(s.room); err != nil && !errors.Is(err, ErrNoPresenter) && !errors.Is(err, ErrNoPeerConnection) {
+		log.Printf("[Recorder] Couldn't request a keyframe in room %s: %v", s.room.ID, err)
+	}
+}
+
+// trackClock reassembles one track's frames and places them on the
+// recording's timeline. Each stream of the track is anchored at the time
+// its first packet arrived, which keeps audio and video in step and leaves a
+// gap, rather than overlapping, when the presenter reconnects.
+type trackClock struct {
+	rate       int64
+	newBuilder func() *samplebuilder.SampleBuilder
+
+	builder *samplebuilder.SampleBuilder
+	ssrc    uint32
+	lastTS  uint32
+	ticks   int64         // Since base, unwrapped
+	base    time.Duration // Where the stream starts in the recording
+	last    time.Duration // Of the latest frame
+}
+
+func newTrackClock(rate int64, newBuilder func() *samplebuilder.SampleBuilder) *trackClock {
+	return &trackClock{rate: rate, newBuilder: newBuilder}
+}
+
+// follow starts following a new stream when ssrc changes, anchored at
+// offset into the recording, and reports whether it did.
+func (c *trackClock) follow(ssrc, timestamp uint32, offset time.Duration) bool {
+	if c.builder != nil && ssrc == c.ssrc {
+		return false
+	}
+	c.builder = c.newBuilder()
+	c.ssrc = ssrc
+	c.lastTS = timestamp
+	c.ticks = 0
+	c.base = max(offset, c.last)
+	return true
+}
+
+// push adds a packet and passes each frame it completes to write, with
+// its time and whether packets were lost before it.
+func (c *trackClock) push(pkt *rtp.Packet, write func(frame []byte, t time.Duration, dropped bool) error) error {
+	c.builder.Push(pkt)
+	for {
+		sample, timestamp := c.builder.PopWithTimestamp()
+		if sample == nil {
+			return nil
+		}
+		if err := write(sample.Data, c.at(timestamp), sample.PrevDroppedPackets > 0); err != nil {
+			return err
+		}
+	}
+}
+
+// at returns the time of an RTP timestamp of the current stream.
+func (c *trackClock) at(timestamp uint32) time.Duration {
+	c.ticks += int64(int32(timestamp - c.lastTS))
+	c.lastTS = timestamp
+	t := c.base + time.Duration(c.ticks*int64(time.Second)/c.rate)
+	if t < c.last {
+		t = c.last
+	}
+	c.last = t
+	return t
+}
@@ -0,0 +1,153 @@
+package rtc
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+)
+
+// ICE restart metrics, used to spot systemic STUN/TURN problems.
+var (
+	iceRestarts = metrics.NewCounterVec(
+		"liveclass_ice_restarts_total",
+		"Viewer ICE restarts by result (attempted, succeeded, failed).",
+		"result",
+	)
+	viewerRejoins = metrics.NewCounterVec(
+		"liveclass_viewer_rejoins_total",
+		"Viewers told to perform a full rejoin instead of an ICE restart, by reason.",
+		"reason",
+	)
+	restartBreakerOpen = metrics.NewGauge(
+		"liveclass_ice_restart_breaker_open",
+		"1 while the ICE restart circuit breaker is open.",
+	)
+)
+
+// RetryPolicy controls how viewer ICE restarts are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of ICE restarts allowed per viewer connection.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first restart; it doubles on each attempt.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the restart delay.
+	MaxBackoff time.Duration
+	// BreakerThreshold is the number of consecutive failed restarts (across all
+	// viewers) that opens the circuit breaker.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open.
+	BreakerCooldown time.Duration
+}
+
+// restartTracker enforces the per-viewer retry budget and the service-wide
+// circuit breaker for ICE restarts.
+type restartTracker struct {
+	policy RetryPolicy
+
+	attempts map[string]int  // viewer ID -> restarts since last successful connect
+	pending  map[string]bool // viewer ID -> restart awaiting an outcome
+
+	consecutiveFailures int
+	openUntil           time.Time
+
+	mu sync.Mutex
+}
+
+func newRestartTracker(policy RetryPolicy) *restartTracker {
+	return &restartTracker{
+		policy:   policy,
+		attempts: make(map[string]int),
+		pending:  make(map[string]bool),
+	}
+}
+
+// next reserves a restart attempt for the viewer. It returns the backoff to
+// wait before restarting, or a rejoin reason if no restart should be made.
+func (t *restartTracker) next(viewerID string) (time.Duration, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.breakerOpenLocked() {
+		return 0, "breaker-open"
+	}
+
+	attempt := t.attempts[viewerID]
+	if attempt >= t.policy.MaxAttempts {
+		return 0, "budget-exhausted"
+	}
+	t.attempts[viewerID] = attempt + 1
+	t.pending[viewerID] = true
+
+	backoff := t.policy.BaseBackoff << attempt
+	if backoff > t.policy.MaxBackoff || backoff <= 0 {
+		backoff = t.policy.MaxBackoff
+	}
+	return backoff, ""
+}
+
+// isPending reports whether a restart is in flight for the viewer.
+func (t *restartTracker) isPending(viewerID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending[viewerID]
+}
+
+// connected records that the viewer's ICE connection came up, which counts as a
+// successful restart if one was in flight.
+func (t *restartTracker) connected(viewerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending[viewerID] {
+		iceRestarts.WithLabelValues("succeeded").Inc()
+		t.consecutiveFailures = 0
+	}
+	delete(t.pending, viewerID)
+	delete(t.attempts, viewerID)
+}
+
+// failed records that an in-flight restart did not recover the connection.
+func (t *restartTracker) failed(viewerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.pending[viewerID] {
+		return
+	}
+	delete(t.pending, viewerID)
+	iceRestarts.WithLabelValues("failed").Inc()
+
+	t.consecutiveFailures++
+	if t.policy.BreakerThreshold > 0 && t.consecutiveFailures >= t.policy.BreakerThreshold && !t.breakerOpenLocked() {
+		t.openUntil = time.Now().Add(t.policy.BreakerCooldown)
+		restartBreakerOpen.Set(1)
+		log.Printf("[RTC] ⚠️ ICE restart circuit breaker opened after %d consecutive failures (cooldown %v)",
+			t.consecutiveFailures, t.policy.BreakerCooldown)
+	}
+}
+
+// forget drops all state for a viewer.
+func (t *restartTracker) forget(viewerID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, viewerID)
+	delete(t.attempts, viewerID)
+}
+
+// breakerOpenLocked reports whether the breaker is open, closing it once the
+// cooldown has elapsed. Callers must hold t.mu.
+func (t *restartTracker) breakerOpenLocked() bool {
+	if t.openUntil.IsZero() {
+		return false
+	}
+	if time.Now().Before(t.openUntil) {
+		return true
+	}
+	t.openUntil = time.Time{}
+	t.consecutiveFailures = 0
+	restartBreakerOpen.Set(0)
+	log.Printf("[RTC] ICE restart circuit breaker closed")
+	return false
+}
@@ -0,0 +1,513 @@
+package rtc
+
+import (
+	"encoding/binary"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+)
+
+// simulcastSwitches counts viewers moved between simulcast layers.
+var simulcastSwitches = metrics.NewCounterVec(
+	"liveclass_simulcast_layer_switches_total",
+	"Viewer simulcast layer changes by direction (down, up).",
+	"direction",
+)
+
+// Header extensions a presenter's browser labels simulcast layers with.
+const (
+	sdesMidURI            = "urn:ietf:params:rtp-hdrext:sdes:mid"
+	sdesRTPStreamIDURI    = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+	sdesRepairedStreamURI = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+)
+
+const (
+	// layerStaleAfter is how long a layer may go without packets before it
+	// is treated as paused, as browsers pause layers their uplink can't carry.
+	layerStaleAfter = 2 * time.Second
+	// keyframeRequestInterval is the least time between keyframe requests
+	// for one layer.
+	keyframeRequestInterval = 500 * time.Millisecond
+	// switchTimestampStep is the RTP timestamp gap left between the last
+	// frame of one layer and the first of the next: a frame at 30 fps on
+	// the 90 kHz video clock.
+	switchTimestampStep = 3000
+)
+
+// registerSimulcastExtensions lets a media engine receive rid-labelled
+// simulcast video.
+func registerSimulcastExtensions(m *webrtc.MediaEngine) error {
+	for _, uri := range []string{sdesMidURI, sdesRTPStreamIDURI, sdesRepairedStreamURI} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: uri}, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// simulcastRIDs returns the rids of the video layers an offer sends, or nil
+// if it sends a single stream.
+func simulcastRIDs(offer webrtc.SessionDescription) []string {
+	var rids []string
+	video := false
+	for _, line := range strings.Split(offer.SDP, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "m=") {
+			video = strings.HasPrefix(line, "m=video")
+			continue
+		}
+		if !video || !strings.HasPrefix(line, "a=rid:") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "a=rid:"))
+		if len(fields) >= 2 && fields[1] == "send" {
+			rids = append(rids, fields[0])
+		}
+	}
+	if len(rids) < 2 {
+		return nil
+	}
+	return rids
+}
+
+// simulcastLayer is one video layer of a simulcasting presenter.
+type simulcastLayer struct {
+	rid        string
+	order      int    // In the offer
+	ssrc       uint32 // 0 until its track arrives
+	pixels     int    // Of its latest keyframe, 0 until one arrives
+	lastPacket time.Time
+	lastPLI    time.Time
+}
+
+// layerOutput forwards one layer at a time to a local track, switching
+// layers at keyframes. Sequence numbers and timestamps are rewritten so the
+// track stays one continuous stream across switches.
+type layerOutput struct {
+	track     *webrtc.TrackLocalStaticRTP
+	current   string // rid forwarded, empty until the first keyframe
+	target    string // rid wanted, empty for the top layer
+	step      uint32 // Timestamp gap left at switches, switchTimestampStep if 0
+	started   bool
+	seqOffset uint16
+	tsOffset  uint32
+	lastSeq   uint16
+	lastTS    uint32
+
+	lossy, clean int // Consecutive lossy and clean receiver reports
+}
+
+// write forwards pkt from layer rid if it is the output's layer, switching
+// to the target layer at its keyframes. It returns the packet as rewritten
+// and whether it was written.
+func (o *layerOutput) write(rid string, pkt *rtp.Packet, keyframe bool) (*rtp.Packet, bool) {
+	if rid == o.target && rid != o.current && keyframe {
+		o.current = rid
+		if o.started {
+			step := o.step
+			if step == 0 {
+				step = switchTimestampStep
+			}
+			o.seqOffset = o.lastSeq + 1 - pkt.SequenceNumber
+			o.tsOffset = o.lastTS + step - pkt.Timestamp
+		}
+	}
+	if rid != o.current {
+		return nil, false
+	}
+
+	out := *pkt
+	out.SequenceNumber = pkt.SequenceNumber + o.seqOffset
+	out.Timestamp = pkt.Timestamp + o.tsOffset
+	// The presenter's extension IDs mean nothing to viewers
+	out.Extension = false
+	out.Extensions = nil
+	o.started, o.lastSeq, o.lastTS = true, out.SequenceNumber, out.Timestamp
+
+	if err := o.track.WriteRTP(&out); err != nil {
+		return &out, false
+	}
+	return &out, true
+}
+
+// simulcastGroup forwards a simulcasting presenter's video: the top layer to
+// the shared presenter track and the room's media taps, and each viewer the
+// layer their loss allows on a track of their own. Layers are ranked by the
+// resolution of their keyframes. While a clip plays, every output carries
+// the clip instead.
+type simulcastGroup struct {
+	room     *room.Room
+	peerConn *webrtc.PeerConnection // The presenter's
+	policy   AdaptationPolicy
+
+	mu      sync.Mutex
+	layers  []*simulcastLayer
+	top     *layerOutput            // The shared presenter track
+	viewers map[string]*layerOutput // By viewer ID
+	clip    bool                    // A clip holds the outputs
+}
+
+func newSimulcastGroup(r *room.Room, peerConn *webrtc.PeerConnection, rids []string, shared *webrtc.TrackLocalStaticRTP, policy AdaptationPolicy) *simulcastGroup {
+	g := &simulcastGroup{
+		room:     r,
+		peerConn: peerConn,
+		policy:   policy,
+		top:      &layerOutput{track: shared},
+		viewers:  make(map[string]*layerOutput),
+	}
+	for i, rid := range rids {
+		g.layers = append(g.layers, &simulcastLayer{rid: rid, order: i})
+	}
+	return g
+}
+
+// adopt takes over the outputs of the group of the presenter's previous
+// connection, so viewers stay attached across renegotiation. Outputs wait
+// for a keyframe of the new layers, continuing their streams.
+func (g *simulcastGroup) adopt(old *simulcastGroup) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.top = old.top
+	g.viewers = old.viewers
+	g.clip = old.clip
+	old.viewers = make(map[string]*layerOutput)
+	for _, o := range append(g.outputsLocked(), g.top) {
+		o.current, o.target = "", ""
+		if g.clip {
+			o.current, o.target = clipSource, clipSource
+		}
+	}
+}
+
+// outputsLocked returns the viewers' outputs. Callers must hold g.mu.
+func (g *simulcastGroup) outputsLocked() []*layerOutput {
+	outputs := make([]*layerOutput, 0, len(g.viewers))
+	for _, o := range g.viewers {
+		outputs = append(outputs, o)
+	}
+	return outputs
+}
+
+// trackArrived records the SSRC of a layer's track.
+func (g *simulcastGroup) trackArrived(rid string, ssrc uint32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if layer := g.layerLocked(rid); layer != nil {
+		layer.ssrc = ssrc
+	}
+}
+
+// layerLocked returns the layer with rid, or nil. Callers must hold g.mu.
+func (g *simulcastGroup) layerLocked(rid string) *simulcastLayer {
+	for _, layer := range g.layers {
+		if layer.rid == rid {
+			return layer
+		}
+	}
+	return nil
+}
+
+// rankedLocked returns the layers sending packets, lowest first. Callers
+// must hold g.mu.
+func (g *simulcastGroup) rankedLocked(now time.Time) []*simulcastLayer {
+	ranked := make([]*simulcastLayer, 0, len(g.layers))
+	for _, layer := range g.layers {
+		if layer.ssrc != 0 && now.Sub(layer.lastPacket) < layerStaleAfter {
+			ranked = append(ranked, layer)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].pixels != ranked[j].pixels {
+			return ranked[i].pixels < ranked[j].pixels
+		}
+		return ranked[i].order < ranked[j].order
+	})
+	return ranked
+}
+
+// forward sends a packet of layer rid to every output on that layer, and
+// asks for keyframes of layers outputs are waiting to switch to. It returns
+// the packets sent and failed.
+func (g *simulcastGroup) forward(rid string, pkt *rtp.Packet) (sent, failed int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	layer := g.layerLocked(rid)
+	if layer == nil {
+		return 0, 0
+	}
+	now := time.Now()
+	layer.lastPacket = now
+	keyframe, pixels := vp8Keyframe(pkt.Payload)
+	if keyframe && pixels > 0 {
+		layer.pixels = pixels
+	}
+
+	ranked := g.rankedLocked(now)
+	if len(ranked) == 0 || g.clip {
+		return 0, 0
+	}
+	topRID := ranked[len(ranked)-1].rid
+
+	// The shared track always carries the top layer
+	g.top.target = topRID
+	out, ok := g.top.write(rid, pkt, keyframe)
+	if ok {
+		if data, err := out.Marshal(); err == nil {
+			g.room.TapMedia(true, data)
+		}
+	}
+	sent, failed = outputResult(out, ok)
+	if g.top.current != g.top.target {
+		g.requestKeyframeLocked(g.layerLocked(g.top.target), now)
+	}
+
+	for _, o := range g.viewers {
+		// Viewers of a layer that paused move to the top one
+		if !containsLayer(ranked, o.target) {
+			o.target = topRID
+		}
+		s, f := outputResult(o.write(rid, pkt, keyframe))
+		sent, failed = sent+s, failed+f
+		if o.current != o.target {
+			g.requestKeyframeLocked(g.layerLocked(o.target), now)
+		}
+	}
+	return sent, failed
+}
+
+// setClip hands the outputs to a clip, or back to the layers, which they
+// switch to at their next keyframes.
+func (g *simulcastGroup) setClip(clip bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.setClipLocked(clip)
+}
+
+// setClipLocked is setClip for callers holding g.mu.
+func (g *simulcastGroup) setClipLocked(clip bool) {
+	g.clip = clip
+	for _, o := range append(g.outputsLocked(), g.top) {
+		if clip {
+			o.target = clipSource
+		} else if o.target == clipSource {
+			o.target = ""
+		}
+	}
+}
+
+// writeClip sends a packet of a clip to every output, and the room's media
+// taps. A group the presenter started during the clip is handed to it.
+func (g *simulcastGroup) writeClip(pkt *rtp.Packet) {
+	keyframe, _ := vp8Keyframe(pkt.Payload)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.clip {
+		g.setClipLocked(true)
+	}
+	if out, ok := g.top.write(clipSource, pkt, keyframe); ok {
+		if data, err := out.Marshal(); err == nil {
+			g.room.TapMedia(true, data)
+		}
+	}
+	for _, o := range g.viewers {
+		o.write(clipSource, pkt, keyframe)
+	}
+}
+
+// requestKeyframeLocked asks the presenter for a keyframe of layer, at most
+// once per keyframeRequestInterval. Callers must hold g.mu.
+func (g *simulcastGroup) requestKeyframeLocked(layer *simulcastLayer, now time.Time) {
+	if layer == nil || layer.ssrc == 0 || now.Sub(layer.lastPLI) < keyframeRequestInterval {
+		return
+	}
+	layer.lastPLI = now
+	g.peerConn.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: layer.ssrc}})
+}
+
+// attach gives a viewer a video track of their own, starting on the top
+// layer.
+func (g *simulcastGroup) attach(viewerID string) (*webrtc.TrackLocalStaticRTP, error) {
+	track, err := webrtc.NewTrackLocalStaticRTP(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8},
+		"video",
+		"presenter-stream",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.viewers[viewerID] = &layerOutput{track: track}
+	return track, nil
+}
+
+// detach stops forwarding to a viewer's track, unless the viewer has since
+// been given a newer one.
+func (g *simulcastGroup) detach(viewerID string, track *webrtc.TrackLocalStaticRTP) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if o, ok := g.viewers[viewerID]; ok && o.track == track {
+		delete(g.viewers, viewerID)
+	}
+}
+
+// observeLoss moves a viewer a layer down while their receiver reports stay
+// lossy, and back up once they are clean, under the adaptation policy's
+// thresholds.
+func (g *simulcastGroup) observeLoss(viewerID string, fractionLost uint8) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	o, ok := g.viewers[viewerID]
+	if !ok {
+		return
+	}
+	loss := float64(fractionLost) * 100 / 256
+	switch {
+	case loss >= g.policy.HighLossPercent:
+		o.lossy, o.clean = o.lossy+1, 0
+	case loss <= g.policy.LowLossPercent:
+		o.lossy, o.clean = 0, o.clean+1
+	default:
+		o.lossy, o.clean = 0, 0
+	}
+
+	ranked := g.rankedLocked(time.Now())
+	at := -1
+	for i, layer := range ranked {
+		if layer.rid == o.target {
+			at = i
+		}
+	}
+	if at < 0 {
+		return
+	}
+	sustain := max(g.policy.Sustain, 1)
+	switch {
+	case o.lossy >= sustain && at > 0:
+		o.target, o.lossy = ranked[at-1].rid, 0
+		simulcastSwitches.WithLabelValues("down").Inc()
+	case o.clean >= sustain && at < len(ranked)-1:
+		o.target, o.clean = ranked[at+1].rid, 0
+		simulcastSwitches.WithLabelValues("up").Inc()
+	default:
+		return
+	}
+	log.Printf("[RTC] Viewer %s moving to simulcast layer %s at %.1f%% loss", viewerID, o.target, loss)
+	g.requestKeyframeLocked(g.layerLocked(o.target), time.Now())
+}
+
+// requestKeyframe asks for a keyframe of the layer a viewer is on, as when
+// the viewer reports picture loss.
+func (g *simulcastGroup) requestKeyframe(viewerID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if o, ok := g.viewers[viewerID]; ok {
+		g.requestKeyframeLocked(g.layerLocked(o.current), time.Now())
+	}
+}
+
+func containsLayer(layers []*simulcastLayer, rid string) bool {
+	for _, layer := range layers {
+		if layer.rid == rid {
+			return true
+		}
+	}
+	return false
+}
+
+// vp8Keyframe reports whether payload starts a VP8 keyframe and, if so, its
+// resolution in pixels.
+func vp8Keyframe(payload []byte) (bool, int) {
+	var vp8 codecs.VP8Packet
+	if _, err := vp8.Unmarshal(payload); err != nil {
+		return false, 0
+	}
+	frame := vp8.Payload
+	if vp8.S != 1 || vp8.PID != 0 || len(frame) < 3 || frame[0]&0x01 != 0 {
+		return false, 0
+	}
+	// Keyframe header: 3 byte frame tag, 3 byte start code, then 14 bit
+	// width and height
+	if len(frame) < 10 || frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		return true, 0
+	}
+	width := int(binary.LittleEndian.Uint16(frame[6:8]) & 0x3fff)
+	height := int(binary.LittleEndian.Uint16(frame[8:10]) & 0x3fff)
+	return true, width * height
+}
+
+// simulcastFor returns the simulcast group of a room's presenter, or nil if
+// the presenter sends a single stream.
+func (s *Service) simulcastFor(roomID string) *simulcastGroup {
+	s.simulcastMu.Lock()
+	defer s.simulcastMu.Unlock()
+
+	return s.simulcast[roomID]
+}
+
+// startSimulcast sets up forwarding for a presenter's new connection from
+// the layers its offer sends. Viewers on their own tracks are carried over
+// to the new layers, or pushed a fresh connection if the presenter stopped
+// simulcasting.
+func (s *Service) startSimulcast(r *room.Room, peerConn *webrtc.PeerConnection, presenter *room.Participant, rids []string) {
+	s.simulcastMu.Lock()
+	old := s.simulcast[r.ID]
+	if rids == nil {
+		delete(s.simulcast, r.ID)
+		s.simulcastMu.Unlock()
+
+		if old != nil {
+			for _, viewer := range r.GetAllViewers() {
+				go func(v *room.Participant) {
+					if err := s.pushStreamToViewer(r, v); err != nil {
+						log.Printf("[RTC] Failed to push stream to viewer %s: %v", v.ID, err)
+					}
+				}(viewer)
+			}
+		}
+		return
+	}
+
+	g := newSimulcastGroup(r, peerConn, rids, presenter.VideoTrack, s.adaptation)
+	if old != nil {
+		g.adopt(old)
+	}
+	s.simulcast[r.ID] = g
+	s.simulcastMu.Unlock()
+
+	log.Printf("[RTC] Presenter in room %s simulcasting layers %v", r.ID, rids)
+}
+
+// dropSimulcast forgets a room's simulcast group once its presenter left.
+func (s *Service) dropSimulcast(ev room.LifecycleEvent) {
+	if ev.Type != room.LifecyclePresenterLeft && ev.Type != room.LifecycleEnded {
+		return
+	}
+
+	s.simulcastMu.Lock()
+	defer s.simulcastMu.Unlock()
+
+	delete(s.simulcast, ev.RoomID)
+}
@@ -0,0 +1,185 @@
+package rtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// audioLevelURI is the RTP header extension in which senders report the
+// level of each audio packet (RFC 6464), so the SFU needn't decode audio.
+const audioLevelURI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+// speakingUpdates counts speaking indicators broadcast to rooms.
+var speakingUpdates = metrics.NewCounter(
+	"liveclass_speaking_updates_total",
+	"Speaking indicator updates broadcast to rooms.",
+)
+
+// SpeakingPolicy controls the speaking indicators derived from audio levels.
+type SpeakingPolicy struct {
+	// ThresholdDBov is the quietest level, in -dBov, that counts as speech;
+	// 0 disables indicators. Levels run from 0 (loudest) to 127 (silence).
+	ThresholdDBov int
+	// Hold is how long audio must stay below the threshold before a speaker
+	// stops speaking, bridging pauses between words.
+	Hold time.Duration
+	// Interval is the least time between level updates for a speaker.
+	Interval time.Duration
+}
+
+// newPresenterAPI returns a WebRTC API with the default codecs and
+// interceptors that also negotiates the audio level header extension and
+// receives simulcast video.
+func newPresenterAPI(settings webrtc.SettingEngine) (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: audioLevelURI}, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, err
+	}
+	if err := registerSimulcastExtensions(m); err != nil {
+		return nil, err
+	}
+	i := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
+		return nil, err
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i), webrtc.WithSettingEngine(settings)), nil
+}
+
+// audioLevelExtensionID returns the negotiated ID of the audio level
+// extension on receiver, or 0 if it wasn't negotiated.
+func audioLevelExtensionID(receiver *webrtc.RTPReceiver) uint8 {
+	for _, ext := range receiver.GetParameters().HeaderExtensions {
+		if ext.URI == audioLevelURI {
+			return uint8(ext.ID)
+		}
+	}
+	return 0
+}
+
+// speakingDetector turns the audio levels of one participant's track into
+// speaking indicators. Indicators are sent when speech starts and stops and,
+// while speaking, when the coarse level changes, at most once per interval.
+type speakingDetector struct {
+	policy      SpeakingPolicy
+	room        *room.Room
+	participant *room.Participant
+	extID       uint8
+	packet      rtp.Packet
+
+	mu       sync.Mutex
+	speaking bool
+	level    int // Coarse level last sent
+	peak     int // Loudest level (lowest -dBov) since the last update
+	lastLoud time.Time
+	lastSent time.Time
+	timer    *time.Timer
+}
+
+// newSpeakingDetector returns a detector for audio packets carrying the
+// level in extension extID, or nil when indicators are disabled or the
+// extension wasn't negotiated.
+func (s *Service) newSpeakingDetector(r *room.Room, participant *room.Participant, extID uint8) *speakingDetector {
+	if s.speaking.ThresholdDBov <= 0 || extID == 0 {
+		return nil
+	}
+	return &speakingDetector{policy: s.speaking, room: r, participant: participant, extID: extID, peak: 127}
+}
+
+// observe reads the level of an RTP packet. It is called from the track's
+// read loop only.
+func (d *speakingDetector) observe(buf []byte) {
+	if d == nil || d.packet.Unmarshal(buf) != nil {
+		return
+	}
+	ext := d.packet.GetExtension(d.extID)
+	if len(ext) == 0 {
+		return
+	}
+	dBov := int(ext[0] & 0x7f) // The top bit is the sender's voice activity flag
+	if dBov > d.policy.ThresholdDBov {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.lastLoud = now
+	d.peak = min(d.peak, dBov)
+
+	if !d.speaking {
+		d.speaking = true
+		d.send(now)
+		d.timer = time.AfterFunc(d.policy.Hold, d.expire)
+		return
+	}
+	if now.Sub(d.lastSent) >= d.policy.Interval && coarseLevel(d.peak) != d.level {
+		d.send(now)
+	}
+}
+
+// expire ends speech once audio has been quiet for the hold time.
+func (d *speakingDetector) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.speaking {
+		return
+	}
+	if quiet := time.Since(d.lastLoud); quiet < d.policy.Hold {
+		d.timer.Reset(d.policy.Hold - quiet)
+		return
+	}
+	d.speaking = false
+	d.send(time.Now())
+}
+
+// close ends speech when the track stops.
+func (d *speakingDetector) close() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if d.speaking {
+		d.speaking = false
+		d.send(time.Now())
+	}
+}
+
+// send broadcasts the current state. d.mu must be held.
+func (d *speakingDetector) send(now time.Time) {
+	d.level = 0
+	if d.speaking {
+		d.level = coarseLevel(d.peak)
+	}
+	d.peak = 127
+	d.lastSent = now
+	d.room.SetSpeaking(d.participant, d.speaking, d.level)
+	speakingUpdates.Inc()
+}
+
+// coarseLevel buckets a level in -dBov into 1 (quiet) to 3 (loud).
+func coarseLevel(dBov int) int {
+	switch {
+	case dBov <= 20:
+		return 3
+	case dBov <= 35:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,86 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// allRooms in TURNPolicy.RelayOnlyRooms makes every room relay-only.
+const allRooms = "*"
+
+// TURNPolicy configures the TURN servers the SFU allocates relay candidates
+// on. They let viewers behind symmetric NATs and firewalls that only allow
+// outbound connections to well-known servers reach it.
+type TURNPolicy struct {
+	// Servers are turn: or turns: URLs, sharing one set of credentials.
+	Servers  []string
+	Username string
+	Password string
+	// RelayOnlyRooms are the rooms whose peer connections only use relay
+	// candidates, so all media goes through TURN, e.g. for networks that
+	// must not see participants' addresses. "*" matches every room.
+	RelayOnlyRooms []string
+}
+
+// iceServers returns the TURN servers with their credentials, or an error
+// if a URL or the credentials are missing or invalid.
+func (p TURNPolicy) iceServers() ([]webrtc.ICEServer, error) {
+	if len(p.Servers) == 0 {
+		if len(p.RelayOnlyRooms) > 0 {
+			return nil, errors.New("relay-only rooms require TURN servers")
+		}
+		return nil, nil
+	}
+	if p.Username == "" || p.Password == "" {
+		return nil, errors.New("TURN servers require a username and password")
+	}
+
+	servers := make([]webrtc.ICEServer, len(p.Servers))
+	for i, url := range p.Servers {
+		if !strings.HasPrefix(url, "turn:") && !strings.HasPrefix(url, "turns:") {
+			return nil, fmt.Errorf("invalid TURN server %q: expected a turn: or turns: URL", url)
+		}
+		servers[i] = webrtc.ICEServer{
+			URLs:           []string{url},
+			Username:       p.Username,
+			Credential:     p.Password,
+			CredentialType: webrtc.ICECredentialTypePassword,
+		}
+	}
+	return servers, nil
+}
+
+// relayOnlyRooms returns the set of relay-only rooms and whether every room
+// is.
+func (p TURNPolicy) relayOnlyRooms() (map[string]struct{}, bool) {
+	rooms := make(map[string]struct{}, len(p.RelayOnlyRooms))
+	for _, id := range p.RelayOnlyRooms {
+		if id == allRooms {
+			return nil, true
+		}
+		rooms[strings.ToUpper(id)] = struct{}{}
+	}
+	return rooms, false
+}
+
+// ICETransportPolicy returns the candidates the room's peer connections use:
+// "relay" for relay-only rooms, otherwise "all".
+func (s *Service) ICETransportPolicy(roomID string) webrtc.ICETransportPolicy {
+	if s.relayAll {
+		return webrtc.ICETransportPolicyRelay
+	}
+	if _, ok := s.relayOnly[strings.ToUpper(roomID)]; ok {
+		return webrtc.ICETransportPolicyRelay
+	}
+	return webrtc.ICETransportPolicyAll
+}
+
+// configFor returns the peer connection configuration for a room.
+func (s *Service) configFor(roomID string) webrtc.Configuration {
+	config := s.config
+	config.ICETransportPolicy = s.ICETransportPolicy(roomID)
+	return config
+}
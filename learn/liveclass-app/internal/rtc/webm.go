@@ -0,0 +1,466 @@
+package rtc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// Matroska element IDs used by the WebM writer
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment      = 0x18538067
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idMuxingApp     = 0x4D80
+	idWritingApp    = 0x5741
+	idDuration      = 0x4489
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idFlagLacing        = 0x9C
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idCodecDelay        = 0x56AA
+	idSeekPreRoll       = 0x56BB
+	idVideo             = 0xE0
+	idPixelWidth        = 0xB0
+	idPixelHeight       = 0xBA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster     = 0x1F43B675
+	idTimecode    = 0xE7
+	idSimpleBlock = 0xA3
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+)
+
+// Track numbers in recordings
+const (
+	webmVideoTrack = 1
+	webmAudioTrack = 2
+)
+
+const (
+	// maxClusterSpan keeps block timecodes, 16-bit offsets from their
+	// cluster's in milliseconds, in range, and clusters small enough to
+	// buffer.
+	maxClusterSpan = 5 * time.Second
+	// minClusterSpan is the least a cluster spans before a keyframe starts
+	// a new one, so frequent keyframes don't make tiny clusters.
+	minClusterSpan = time.Second
+	// opusSeekPreRoll is how much audio decoders need before a seek point.
+	opusSeekPreRoll = 80 * time.Millisecond
+)
+
+// errWebMClosed is returned when writing to a finished WebM file.
+var errWebMClosed = errors.New("webm: writer closed")
+
+// webmWriter muxes VP8 frames and Opus packets into a WebM file, the format
+// browsers play recordings in. The header is written with the first video
+// keyframe, which gives the picture size. Clusters are buffered and written
+// whole; Close adds cues and fills in the duration and sizes, so the file
+// can be seeked.
+type webmWriter struct {
+	w io.WriteSeeker
+
+	started     bool
+	closed      bool
+	segmentData int64 // Offset of the segment's data, which positions are relative to
+	durationAt  int64 // Offset of the duration's value
+	cuesSeekAt  int64 // Offset of the cues' position in the seek head
+
+	cluster     bytes.Buffer
+	clusterTime time.Duration
+	clusterOpen bool
+	written     int64 // Bytes written so far
+	last        time.Duration
+	cues        []webmCue
+}
+
+// webmCue points at a cluster starting with a video keyframe.
+type webmCue struct {
+	time     time.Duration
+	position int64 // Relative to the segment's data
+}
+
+func newWebMWriter(w io.WriteSeeker) *webmWriter {
+	return &webmWriter{w: w}
+}
+
+// Started reports whether the header was written, after the first keyframe.
+func (m *webmWriter) Started() bool {
+	return m.started
+}
+
+// Size returns the bytes written so far.
+func (m *webmWriter) Size() int64 {
+	return m.written + int64(m.cluster.Len())
+}
+
+// WriteVideo adds a VP8 frame at t into the recording. Frames before the
+// first keyframe are dropped.
+func (m *webmWriter) WriteVideo(frame []byte, t time.Duration) error {
+	if m.closed {
+		return errWebMClosed
+	}
+	keyframe := isVP8Keyframe(frame)
+	if !m.started {
+		if !keyframe {
+			return nil
+		}
+		width, height, ok := vp8Size(frame)
+		if !ok {
+			return nil
+		}
+		if err := m.writeHeader(width, height); err != nil {
+			return err
+		}
+	}
+
+	if keyframe && (!m.clusterOpen || t-m.clusterTime >= minClusterSpan) {
+		if err := m.flushCluster(); err != nil {
+			return err
+		}
+		m.cues = append(m.cues, webmCue{time: t, position: m.written - m.segmentData})
+	}
+	return m.writeBlock(webmVideoTrack, frame, t, keyframe)
+}
+
+// WriteAudio adds an Opus packet at t into the recording. Packets before the
+// first video keyframe are dropped.
+func (m *webmWriter) WriteAudio(packet []byte, t time.Duration) error {
+	if m.closed {
+		return errWebMClosed
+	}
+	if !m.started {
+		return nil
+	}
+	return m.writeBlock(webmAudioTrack, packet, t, true)
+}
+
+// Close writes the last cluster and the cues and fills in the duration. It
+// doesn't close the underlying file.
+func (m *webmWriter) Close() error {
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	if !m.started {
+		return nil
+	}
+	if err := m.flushCluster(); err != nil {
+		return err
+	}
+
+	cuesAt := m.written - m.segmentData
+	var points []byte
+	for _, cue := range m.cues {
+		points = append(points, ebmlElement(idCuePoint,
+			ebmlUint(idCueTime, uint64(cue.time.Milliseconds())),
+			ebmlElement(idCueTrackPositions,
+				ebmlUint(idCueTrack, webmVideoTrack),
+				ebmlUint(idCueClusterPosition, uint64(cue.position)),
+			),
+		)...)
+	}
+	if len(points) > 0 {
+		if err := m.write(ebmlElement(idCues, points)); err != nil {
+			return err
+		}
+	}
+	end := m.written
+
+	// Fill in the placeholders
+	if len(points) > 0 {
+		if err := m.patch(m.cuesSeekAt, ebmlFixedUint(uint64(cuesAt))); err != nil {
+			return err
+		}
+	}
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(float64(m.last.Milliseconds())))
+	if err := m.patch(m.durationAt, duration); err != nil {
+		return err
+	}
+	if err := m.patch(m.segmentData-8, ebmlFixedSize(uint64(end-m.segmentData))); err != nil {
+		return err
+	}
+	_, err := m.w.Seek(end, io.SeekStart)
+	return err
+}
+
+// writeHeader writes the EBML header and the start of the segment: the
+// seek head, info and tracks.
+func (m *webmWriter) writeHeader(width, height int) error {
+	header := ebmlElement(idEBML,
+		ebmlUint(idEBMLVersion, 1),
+		ebmlUint(idEBMLReadVersion, 1),
+		ebmlUint(idEBMLMaxIDLength, 4),
+		ebmlUint(idEBMLMaxSizeLength, 8),
+		ebmlString(idDocType, "webm"),
+		ebmlUint(idDocTypeVersion, 4),
+		ebmlUint(idDocTypeReadVersion, 2),
+	)
+	// The segment's size is filled in on Close
+	header = append(header, ebmlID(idSegment)...)
+	header = append(header, ebmlFixedSize(math.MaxUint64)...)
+	segmentData := int64(len(header))
+
+	info := ebmlElement(idInfo,
+		ebmlUint(idTimecodeScale, uint64(time.Millisecond)),
+		ebmlString(idMuxingApp, "liveclass"),
+		ebmlString(idWritingApp, "liveclass"),
+		ebmlFloat(idDuration, 0),
+	)
+	tracks := ebmlElement(idTracks,
+		ebmlElement(idTrackEntry,
+			ebmlUint(idTrackNumber, webmVideoTrack),
+			ebmlUint(idTrackUID, webmVideoTrack),
+			ebmlUint(idTrackType, 1),
+			ebmlUint(idFlagLacing, 0),
+			ebmlString(idCodecID, "V_VP8"),
+			ebmlElement(idVideo,
+				ebmlUint(idPixelWidth, uint64(width)),
+				ebmlUint(idPixelHeight, uint64(height)),
+			),
+		),
+		ebmlElement(idTrackEntry,
+			ebmlUint(idTrackNumber, webmAudioTrack),
+			ebmlUint(idTrackUID, webmAudioTrack),
+			ebmlUint(idTrackType, 2),
+			ebmlUint(idFlagLacing, 0),
+			ebmlString(idCodecID, "A_OPUS"),
+			ebmlElement(idCodecPrivate, opusHead()),
+			ebmlUint(idCodecDelay, 0),
+			ebmlUint(idSeekPreRoll, uint64(opusSeekPreRoll)),
+			ebmlElement(idAudio,
+				ebmlFloat(idSamplingFrequency, 48000),
+				ebmlUint(idChannels, 2),
+			),
+		),
+	)
+
+	// Positions are fixed width so the cues' can be filled in on Close
+	seek := func(id uint32, position int64) []byte {
+		return ebmlElement(idSeek,
+			ebmlElement(idSeekID, ebmlID(id)),
+			ebmlElement(idSeekPosition, ebmlFixedUint(uint64(position))),
+		)
+	}
+	seekHeadLen := int64(len(ebmlElement(idSeekHead, seek(idInfo, 0), seek(idTracks, 0), seek(idCues, 0))))
+	infoAt := seekHeadLen
+	tracksAt := infoAt + int64(len(info))
+	seekHead := ebmlElement(idSeekHead, seek(idInfo, infoAt), seek(idTracks, tracksAt), seek(idCues, 0))
+
+	// The cues' position ends the seek head, and the duration the info
+	m.cuesSeekAt = segmentData + seekHeadLen - 8
+	m.durationAt = segmentData + seekHeadLen + int64(len(info)) - 8
+	m.segmentData = segmentData
+
+	out := append(header, seekHead...)
+	out = append(out, info...)
+	out = append(out, tracks...)
+	if err := m.write(out); err != nil {
+		return err
+	}
+	m.started = true
+	return nil
+}
+
+// writeBlock adds a frame to the current cluster, starting a new one when
+// it would span too long.
+func (m *webmWriter) writeBlock(track uint64, frame []byte, t time.Duration, keyframe bool) error {
+	// Tracks are timed separately and may be a little out of step
+	if t < m.clusterTime {
+		t = m.clusterTime
+	}
+	if !m.clusterOpen || t-m.clusterTime >= maxClusterSpan {
+		if err := m.flushCluster(); err != nil {
+			return err
+		}
+	}
+	if !m.clusterOpen {
+		m.clusterTime = t
+		m.clusterOpen = true
+	}
+
+	var flags byte
+	if keyframe {
+		flags = 0x80
+	}
+	block := make([]byte, 0, len(frame)+4)
+	block = append(block, byte(0x80|track))
+	block = binary.BigEndian.AppendUint16(block, uint16((t - m.clusterTime).Milliseconds()))
+	block = append(block, flags)
+	block = append(block, frame...)
+	m.cluster.Write(ebmlElement(idSimpleBlock, block))
+
+	if t > m.last {
+		m.last = t
+	}
+	return nil
+}
+
+// flushCluster writes the buffered cluster, if any.
+func (m *webmWriter) flushCluster() error {
+	if !m.clusterOpen {
+		return nil
+	}
+	m.clusterOpen = false
+	cluster := ebmlElement(idCluster, ebmlUint(idTimecode, uint64(m.clusterTime.Milliseconds())), m.cluster.Bytes())
+	m.cluster.Reset()
+	return m.write(cluster)
+}
+
+func (m *webmWriter) write(p []byte) error {
+	n, err := m.w.Write(p)
+	m.written += int64(n)
+	return err
+}
+
+// patch overwrites bytes written earlier at offset.
+func (m *webmWriter) patch(offset int64, p []byte) error {
+	if _, err := m.w.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := m.w.Write(p)
+	return err
+}
+
+// isVP8Keyframe reports whether a VP8 frame is a keyframe (RFC 6386 9.1).
+func isVP8Keyframe(frame []byte) bool {
+	return len(frame) > 0 && frame[0]&0x01 == 0
+}
+
+// vp8Size returns the picture size in a VP8 keyframe's header.
+func vp8Size(frame []byte) (width, height int, ok bool) {
+	if len(frame) < 10 || frame[3] != 0x9D || frame[4] != 0x01 || frame[5] != 0x2A {
+		return 0, 0, false
+	}
+	width = int(binary.LittleEndian.Uint16(frame[6:8]) & 0x3FFF)
+	height = int(binary.LittleEndian.Uint16(frame[8:10]) & 0x3FFF)
+	return width, height, width > 0 && height > 0
+}
+
+// opusHead returns the Opus identification header for 48kHz stereo
+// (RFC 7845 5.1), as WebRTC negotiates it.
+func opusHead() []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, 2)                            // Version, channels
+	head = binary.LittleEndian.AppendUint16(head, 0)     // Pre-skip
+	head = binary.LittleEndian.AppendUint32(head, 48000) // Input sample rate
+	head = binary.LittleEndian.AppendUint16(head, 0)     // Output gain
+	return append(head, 0)                               // Channel mapping family
+}
+
+// ebmlElement encodes an element containing the concatenated children.
+func ebmlElement(id uint32, children ...[]byte) []byte {
+	size := 0
+	for _, child := range children {
+		size += len(child)
+	}
+	out := ebmlID(id)
+	out = append(out, ebmlSize(uint64(size))...)
+	for _, child := range children {
+		out = append(out, child...)
+	}
+	return out
+}
+
+// ebmlUint encodes an unsigned integer element in as few bytes as it needs.
+func ebmlUint(id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && v>>(8*n) != 0 {
+		n++
+	}
+	data := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		data[i] = byte(v)
+		v >>= 8
+	}
+	return ebmlElement(id, data)
+}
+
+func ebmlFloat(id uint32, v float64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(v))
+	return ebmlElement(id, data)
+}
+
+func ebmlString(id uint32, s string) []byte {
+	return ebmlElement(id, []byte(s))
+}
+
+// ebmlID encodes an element ID, whose length marker is part of its value.
+func ebmlID(id uint32) []byte {
+	switch {
+	case id > 0xFFFFFF:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFFFF:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xFF:
+		return []byte{byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id)}
+	}
+}
+
+// ebmlSize encodes an element size as a variable length integer.
+func ebmlSize(size uint64) []byte {
+	n := 1
+	// All ones is reserved for unknown sizes
+	for n < 8 && size >= 1<<(7*n)-1 {
+		n++
+	}
+	out := make([]byte, n)
+	v := size | 1<<(7*n)
+	for i := n - 1; i >= 0; i-- {
+		out[i] = byte(v)
+		v >>= 8
+	}
+	return out
+}
+
+// ebmlFixedSize encodes a size in 8 bytes, so it can be overwritten later;
+// math.MaxUint64 encodes an unknown size.
+func ebmlFixedSize(size uint64) []byte {
+	if size == math.MaxUint64 {
+		return []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	}
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, size)
+	out[0] = 0x01
+	return out
+}
+
+// ebmlFixedUint encodes an unsigned integer's value in 8 bytes, so it can
+// be overwritten later.
+func ebmlFixedUint(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
@@ -0,0 +1,271 @@
+package rtc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Matroska element IDs the WebM reader needs besides the writer's
+const (
+	idBlockGroup = 0xA0
+	idBlock      = 0xA1
+)
+
+const (
+	// webmMaxElement bounds the elements the reader loads, so a broken
+	// stream can't make it allocate without limit.
+	webmMaxElement = 16 << 20
+	// webmUnknownSize is the size of elements streamed before their size
+	// was known.
+	webmUnknownSize = -1
+)
+
+// errWebMLaced is returned for laced blocks, which ffmpeg doesn't write for
+// VP8 or Opus.
+var errWebMLaced = errors.New("webm: laced blocks are not supported")
+
+// webmFrame is a VP8 frame or Opus packet read from a WebM stream.
+type webmFrame struct {
+	video bool
+	at    time.Duration // From the start of the stream
+	data  []byte
+}
+
+// webmReader reads the frames of a WebM stream in order without seeking, so
+// it can follow ffmpeg's output as it is written. Master elements are
+// entered rather than loaded, as live streams leave their sizes unknown, and
+// only the elements locating frames are parsed.
+type webmReader struct {
+	r      *bufio.Reader
+	scale  time.Duration     // Of timecodes
+	tracks map[uint64]string // Codec IDs by track number
+
+	entryNumber uint64 // Of the track entry being read
+	entryCodec  string
+	cluster     int64 // Timecode of the current cluster
+}
+
+func newWebMReader(r io.Reader) *webmReader {
+	return &webmReader{
+		r:      bufio.NewReaderSize(r, 64<<10),
+		scale:  time.Millisecond,
+		tracks: make(map[uint64]string),
+	}
+}
+
+// Next returns the next VP8 frame or Opus packet, skipping other tracks. It
+// returns io.EOF at the end of the stream.
+func (m *webmReader) Next() (webmFrame, error) {
+	for {
+		id, size, err := m.header()
+		if err != nil {
+			return webmFrame{}, err
+		}
+
+		switch id {
+		case idSegment, idInfo, idTracks, idCluster, idBlockGroup:
+			continue
+		case idTrackEntry:
+			m.entryNumber, m.entryCodec = 0, ""
+			continue
+		}
+		if size == webmUnknownSize {
+			return webmFrame{}, fmt.Errorf("webm: element %#x of unknown size", id)
+		}
+
+		switch id {
+		case idTimecodeScale:
+			v, err := m.uint(size)
+			if err != nil {
+				return webmFrame{}, err
+			}
+			if v > 0 {
+				m.scale = time.Duration(v)
+			}
+		case idTrackNumber:
+			if m.entryNumber, err = m.uint(size); err != nil {
+				return webmFrame{}, err
+			}
+			m.addTrack()
+		case idCodecID:
+			data, err := m.data(size)
+			if err != nil {
+				return webmFrame{}, err
+			}
+			m.entryCodec = string(data)
+			m.addTrack()
+		case idTimecode:
+			v, err := m.uint(size)
+			if err != nil {
+				return webmFrame{}, err
+			}
+			m.cluster = int64(v)
+		case idSimpleBlock, idBlock:
+			frame, ok, err := m.block(size)
+			if err != nil {
+				return webmFrame{}, err
+			}
+			if ok {
+				return frame, nil
+			}
+		default:
+			if _, err := m.r.Discard(int(size)); err != nil {
+				return webmFrame{}, unexpectedEOF(err)
+			}
+		}
+	}
+}
+
+// addTrack records the track entry being read once both its number and
+// codec are known.
+func (m *webmReader) addTrack() {
+	if m.entryNumber != 0 && m.entryCodec != "" {
+		m.tracks[m.entryNumber] = m.entryCodec
+	}
+}
+
+// block reads a block's frame, reporting false for tracks other than VP8
+// and Opus.
+func (m *webmReader) block(size int64) (webmFrame, bool, error) {
+	data, err := m.data(size)
+	if err != nil {
+		return webmFrame{}, false, err
+	}
+	track, n := vint(data)
+	if n == 0 || len(data) < n+3 {
+		return webmFrame{}, false, errors.New("webm: short block")
+	}
+	relative := int16(uint16(data[n])<<8 | uint16(data[n+1]))
+	flags := data[n+2]
+
+	var video bool
+	switch m.tracks[track] {
+	case "V_VP8":
+		video = true
+	case "A_OPUS":
+	default:
+		return webmFrame{}, false, nil
+	}
+	if flags&0x06 != 0 {
+		return webmFrame{}, false, errWebMLaced
+	}
+
+	at := time.Duration(m.cluster+int64(relative)) * m.scale
+	return webmFrame{video: video, at: max(at, 0), data: data[n+3:]}, true, nil
+}
+
+// header reads an element's ID and size, webmUnknownSize if unknown.
+func (m *webmReader) header() (uint32, int64, error) {
+	first, err := m.r.Peek(1)
+	if err != nil {
+		return 0, 0, err // io.EOF between elements ends the stream
+	}
+	idLen := vintLength(first[0])
+	if idLen == 0 || idLen > 4 {
+		return 0, 0, fmt.Errorf("webm: invalid element ID %#x", first[0])
+	}
+	idBytes, err := m.read(idLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	var id uint32
+	for _, b := range idBytes {
+		id = id<<8 | uint32(b)
+	}
+
+	first, err = m.r.Peek(1)
+	if err != nil {
+		return 0, 0, unexpectedEOF(err)
+	}
+	sizeLen := vintLength(first[0])
+	if sizeLen == 0 {
+		return 0, 0, fmt.Errorf("webm: invalid size of element %#x", id)
+	}
+	sizeBytes, err := m.read(sizeLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	size, _ := vint(sizeBytes)
+	if size == 1<<(7*sizeLen)-1 {
+		return id, webmUnknownSize, nil
+	}
+	if size > webmMaxElement {
+		return 0, 0, fmt.Errorf("webm: element %#x of %d bytes is too large", id, size)
+	}
+	return id, int64(size), nil
+}
+
+// uint reads an unsigned integer element's value.
+func (m *webmReader) uint(size int64) (uint64, error) {
+	if size > 8 {
+		return 0, errors.New("webm: integer too long")
+	}
+	data, err := m.data(size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// data reads an element's value into a new slice.
+func (m *webmReader) data(size int64) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(m.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+// read reads n bytes, valid until the next read.
+func (m *webmReader) read(n int) ([]byte, error) {
+	data, err := m.r.Peek(n)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	m.r.Discard(n)
+	return data, nil
+}
+
+// vintLength returns the length of a variable-size integer from its first
+// byte, or 0 if invalid.
+func vintLength(first byte) int {
+	for n := 1; n <= 8; n++ {
+		if first&(0x80>>(n-1)) != 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// vint decodes the variable-size integer at the start of data, without its
+// length marker. It returns its length, 0 if invalid.
+func vint(data []byte) (uint64, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	n := vintLength(data[0])
+	if n == 0 || len(data) < n {
+		return 0, 0
+	}
+	v := uint64(data[0] & (0xFF >> n))
+	for _, b := range data[1:n] {
+		v = v<<8 | uint64(b)
+	}
+	return v, n
+}
+
+// unexpectedEOF turns io.EOF inside an element into io.ErrUnexpectedEOF, so
+// a truncated stream isn't taken for a finished one.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -2,14 +2,19 @@
 package rtc
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
 	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 )
 
@@ -24,6 +29,20 @@ var (
 	ErrNoPeerConnection = errors.New("no peer connection")
 )
 
+// Media forwarding metrics. Uplink loss points at the presenter's connection,
+// downlink loss at individual viewers.
+var (
+	uplinkPackets = metrics.NewCounterVec(
+		"liveclass_rtp_uplink_packets_total",
+		"RTP packets from presenters by result (received, lost).",
+		"result",
+	)
+	downlinkPacketsLost = metrics.NewCounter(
+		"liveclass_rtp_downlink_packets_lost_total",
+		"RTP packets viewers reported lost via RTCP receiver reports.",
+	)
+)
+
 // Message represents a WebSocket signaling message.
 type Message struct {
 	Type    string          `json:"type"`
@@ -32,15 +51,63 @@ type Message struct {
 
 // Service handles WebRTC operations for the live class.
 type Service struct {
-	config webrtc.Configuration
-	mu     sync.Mutex
+	config       webrtc.Configuration
+	api          *webrtc.API // Default codecs and interceptors with the network policy applied
+	presenterAPI *webrtc.API // Also negotiates audio levels; nil falls back to api
+	restarts     *restartTracker
+	adaptation   AdaptationPolicy
+	bitrateCaps  *bitrateCaps // nil when capping is disabled
+	speaking     SpeakingPolicy
+	relayOnly    map[string]struct{} // Rooms limited to relay candidates
+	relayAll     bool                // Every room is
+	mu           sync.Mutex
+
+	simulcast   map[string]*simulcastGroup // By room, while its presenter simulcasts
+	simulcastMu sync.Mutex
+
+	splices   map[string]*roomSplice // By room, once it played a clip
+	splicesMu sync.Mutex
+
+	health HealthSink // nil when nothing watches media health
 }
 
 // NewService creates a new WebRTC service with optimized configuration.
-func NewService(stunServers []string) *Service {
-	iceServers := make([]webrtc.ICEServer, len(stunServers))
-	for i, url := range stunServers {
-		iceServers[i] = webrtc.ICEServer{URLs: []string{url}}
+// The retry policy bounds how many ICE restarts each viewer gets before being
+// asked to rejoin. The adaptation policy decides when presenters on lossy
+// uplinks are asked to reduce video, and the bitrate cap policy how far
+// presenter video is capped as rooms grow. The speaking policy controls the
+// indicators broadcast while the presenter speaks. The network policy decides
+// which ICE candidates the SFU gathers and advertises, and the TURN policy
+// the servers it relays through and the rooms limited to them; an invalid
+// policy is an error.
+func NewService(stunServers []string, turn TURNPolicy, retry RetryPolicy, adaptation AdaptationPolicy, bitrateCap BitrateCapPolicy, speaking SpeakingPolicy, network NetworkPolicy) (*Service, error) {
+	settings, err := network.settingEngine()
+	if err != nil {
+		return nil, err
+	}
+	turnServers, err := turn.iceServers()
+	if err != nil {
+		return nil, err
+	}
+	api, err := newAPI(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// The public address stands in for STUN, and pion refuses both at once
+	if network.natSrflx() {
+		stunServers = nil
+	}
+	iceServers := make([]webrtc.ICEServer, 0, len(stunServers)+len(turnServers))
+	for _, url := range stunServers {
+		iceServers = append(iceServers, webrtc.ICEServer{URLs: []string{url}})
+	}
+	iceServers = append(iceServers, turnServers...)
+	relayOnly, relayAll := turn.relayOnlyRooms()
+
+	presenterAPI, err := newPresenterAPI(settings)
+	if err != nil {
+		log.Printf("[RTC] ⚠️ Audio levels unavailable, speaking indicators disabled: %v", err)
 	}
 
 	return &Service{
@@ -50,7 +117,17 @@ func NewService(stunServers []string) *Service {
 			BundlePolicy:       webrtc.BundlePolicyMaxBundle,
 			RTCPMuxPolicy:      webrtc.RTCPMuxPolicyRequire,
 		},
-	}
+		api:          api,
+		presenterAPI: presenterAPI,
+		restarts:     newRestartTracker(retry),
+		adaptation:   adaptation,
+		bitrateCaps:  newBitrateCaps(bitrateCap),
+		speaking:     speaking,
+		relayOnly:    relayOnly,
+		relayAll:     relayAll,
+		simulcast:    make(map[string]*simulcastGroup),
+		splices:      make(map[string]*roomSplice),
+	}, nil
 }
 
 // HandlePresenterOffer processes a WebRTC offer from the presenter and establishes
@@ -61,18 +138,16 @@ func (s *Service) HandlePresenterOffer(r *room.Room, participant *room.Participa
 
 	log.Printf("[RTC] Processing presenter offer for room %s", r.ID)
 
-	// Clean up any existing peer connection
+	// Clean up any existing peer connection. The local tracks are kept so viewers
+	// stay attached when a presenter renegotiates or resumes after a reconnect.
 	if participant.PeerConn != nil {
 		log.Printf("[RTC] Closing existing presenter peer connection")
 		participant.PeerConn.Close()
 		participant.PeerConn = nil
-		participant.VideoTrack = nil
-		participant.AudioTrack = nil
 	}
-	participant.ClearPendingICE()
 
 	// Create peer connection with default settings (aggressive timeouts were causing ICE failures)
-	peerConn, err := webrtc.NewPeerConnection(s.config)
+	peerConn, err := s.newPresenterPeerConnection(r.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create peer connection: %w", err)
 	}
@@ -85,8 +160,13 @@ func (s *Service) HandlePresenterOffer(r *room.Room, participant *room.Participa
 		return err
 	}
 
+	// Viewers get a layer each if the presenter simulcasts
+	s.startSimulcast(r, peerConn, participant, simulcastRIDs(offer))
+
 	// Set up event handlers
 	s.setupPresenterHandlers(peerConn, r, participant)
+	go s.monitorUplink(peerConn, r, participant)
+	go s.capUplinkBitrate(peerConn, r, participant)
 
 	// Set remote description
 	if err := peerConn.SetRemoteDescription(offer); err != nil {
@@ -94,7 +174,7 @@ func (s *Service) HandlePresenterOffer(r *room.Room, participant *room.Participa
 	}
 	log.Printf("[RTC] Remote description set for presenter")
 
-	// Process any pending ICE candidates
+	// Add the candidates that arrived ahead of the offer
 	s.processPendingICE(participant)
 
 	// Create and set local description (answer)
@@ -114,8 +194,21 @@ func (s *Service) HandlePresenterOffer(r *room.Room, participant *room.Participa
 	return nil
 }
 
+// newPresenterPeerConnection creates a peer connection for a presenter.
+func (s *Service) newPresenterPeerConnection(roomID string) (*webrtc.PeerConnection, error) {
+	if s.presenterAPI == nil {
+		return s.api.NewPeerConnection(s.configFor(roomID))
+	}
+	return s.presenterAPI.NewPeerConnection(s.configFor(roomID))
+}
+
 // createPresenterTracks creates the local tracks for forwarding media to viewers.
+// Existing tracks are reused.
 func (s *Service) createPresenterTracks(participant *room.Participant) error {
+	if participant.VideoTrack != nil && participant.AudioTrack != nil {
+		return nil
+	}
+
 	videoTrack, err := webrtc.NewTrackLocalStaticRTP(
 		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8},
 		"video",
@@ -145,7 +238,7 @@ func (s *Service) setupPresenterHandlers(peerConn *webrtc.PeerConnection, r *roo
 	tracksMu := sync.Mutex{}
 
 	// Handle incoming media tracks from presenter
-	peerConn.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+	peerConn.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 		tracksMu.Lock()
 		tracksReceived++
 		currentTracks := tracksReceived
@@ -155,7 +248,18 @@ func (s *Service) setupPresenterHandlers(peerConn *webrtc.PeerConnection, r *roo
 			track.Kind().String(), track.Codec().MimeType, currentTracks)
 
 		// Start forwarding this track to local track IMMEDIATELY
-		go s.forwardTrack(track, participant)
+		var speaking *speakingDetector
+		if track.Kind() == webrtc.RTPCodecTypeAudio {
+			speaking = s.newSpeakingDetector(r, participant, audioLevelExtensionID(receiver))
+		}
+		var layers *simulcastGroup
+		if track.RID() != "" {
+			if g := s.simulcastFor(r.ID); g != nil && g.peerConn == peerConn {
+				layers = g
+				layers.trackArrived(track.RID(), uint32(track.SSRC()))
+			}
+		}
+		go s.forwardTrack(track, r, participant, speaking, layers)
 
 		// Set stream ready after receiving video track (primary track)
 		if track.Kind() == webrtc.RTPCodecTypeVideo && !r.IsStreamReady() {
@@ -181,6 +285,9 @@ func (s *Service) setupPresenterHandlers(peerConn *webrtc.PeerConnection, r *roo
 		case webrtc.ICEConnectionStateFailed:
 			log.Printf("[RTC] ❌ Presenter ICE failed in room %s", r.ID)
 			r.SetPresenterICEConnected(false)
+			if s.health != nil {
+				s.health.PresenterICEFailed(r.ID)
+			}
 
 		case webrtc.ICEConnectionStateDisconnected:
 			log.Printf("[RTC] ⚠️ Presenter ICE disconnected in room %s", r.ID)
@@ -195,16 +302,19 @@ func (s *Service) setupPresenterHandlers(peerConn *webrtc.PeerConnection, r *roo
 		switch state {
 		case webrtc.PeerConnectionStateConnected:
 			log.Printf("[RTC] ✅ Presenter fully connected in room %s", r.ID)
-		case webrtc.PeerConnectionStateFailed:
-			log.Printf("[RTC] ❌ Presenter connection failed in room %s", r.ID)
-			r.SetStreamReady(false)
-			r.SetPresenterICEConnected(false)
-			r.BroadcastToViewers(Message{Type: "stream-ended"})
-		case webrtc.PeerConnectionStateClosed:
-			log.Printf("[RTC] Presenter connection closed in room %s", r.ID)
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed:
+			// Replaced by a newer offer; the new connection owns the stream state
+			if participant.PeerConn != peerConn && participant.PeerConn != nil {
+				return
+			}
+			log.Printf("[RTC] Presenter connection %s in room %s", state.String(), r.ID)
 			r.SetStreamReady(false)
 			r.SetPresenterICEConnected(false)
-			r.BroadcastToViewers(Message{Type: "stream-ended"})
+
+			// While the presenter is reconnecting viewers keep the "reconnecting" state
+			if !r.IsPresenterReconnecting() {
+				r.BroadcastToViewers(Message{Type: "stream-ended"})
+			}
 		}
 	})
 
@@ -227,9 +337,10 @@ func (s *Service) checkAndPushToViewers(r *room.Room) {
 	}
 
 	log.Printf("[RTC] 🚀 Presenter fully ready in room %s, pushing to waiting viewers", r.ID)
+	r.RecordStreamReady()
 
 	// Notify all viewers that stream is available
-	r.BroadcastToViewers(Message{Type: "stream-available"})
+	r.BroadcastCriticalToViewers("stream-available", nil)
 
 	// Get ALL viewers and push offers to them IMMEDIATELY (more robust than just waiting)
 	// This handles cases where viewer state might be incorrect after reconnections
@@ -273,8 +384,11 @@ func (s *Service) pushStreamToViewer(r *room.Room, viewer *room.Participant) err
 	viewer.ClearPendingICE()
 	viewer.SetState(room.StateConnecting)
 
+	// A fresh connection gets a fresh restart budget
+	s.restarts.forget(viewer.ID)
+
 	// Create peer connection
-	peerConn, err := webrtc.NewPeerConnection(s.config)
+	peerConn, err := s.api.NewPeerConnection(s.configFor(r.ID))
 	if err != nil {
 		viewer.SetState(room.StateFailed)
 		return fmt.Errorf("failed to create peer connection: %w", err)
@@ -282,7 +396,7 @@ func (s *Service) pushStreamToViewer(r *room.Room, viewer *room.Participant) err
 	viewer.PeerConn = peerConn
 
 	// Add presenter's tracks to viewer
-	if err := s.addTracksToViewer(peerConn, presenter); err != nil {
+	if err := s.addTracksToViewer(peerConn, r, presenter, viewer); err != nil {
 		peerConn.Close()
 		viewer.PeerConn = nil
 		viewer.SetState(room.StateFailed)
@@ -303,8 +417,15 @@ func (s *Service) pushStreamToViewer(r *room.Room, viewer *room.Participant) err
 	return nil
 }
 
-// forwardTrack reads RTP packets from the remote track and writes them to the local track.
-func (s *Service) forwardTrack(remoteTrack *webrtc.TrackRemote, participant *room.Participant) {
+// forwardTrack reads RTP packets from the remote track and writes them to the
+// local track and the room's media taps. Audio levels are passed to
+// speaking, which may be nil. Packets of a simulcast layer go to layers.
+// Forwarding results go to the health sink.
+func (s *Service) forwardTrack(remoteTrack *webrtc.TrackRemote, r *room.Room, participant *room.Participant, speaking *speakingDetector, layers *simulcastGroup) {
+	defer speaking.close()
+	results := newForwardCounter(s.health, r.ID)
+	defer results.flush()
+
 	buf := make([]byte, 1500)
 	for {
 		n, _, err := remoteTrack.Read(buf)
@@ -315,27 +436,80 @@ func (s *Service) forwardTrack(remoteTrack *webrtc.TrackRemote, participant *roo
 			return
 		}
 
+		// Count uplink packets and sequence gaps (bytes 2-3 of the RTP header)
+		if n >= 4 {
+			missing := participant.Stats.RecordUplinkPacket(uint32(remoteTrack.SSRC()), binary.BigEndian.Uint16(buf[2:4]))
+			uplinkPackets.WithLabelValues("received").Inc()
+			if missing > 0 {
+				uplinkPackets.WithLabelValues("lost").Add(missing)
+			}
+		}
+
+		if layers != nil {
+			var pkt rtp.Packet
+			if err := pkt.Unmarshal(buf[:n]); err == nil {
+				results.add(layers.forward(remoteTrack.RID(), &pkt))
+			}
+			continue
+		}
+
+		video := remoteTrack.Kind() == webrtc.RTPCodecTypeVideo
 		var localTrack *webrtc.TrackLocalStaticRTP
-		if remoteTrack.Kind() == webrtc.RTPCodecTypeVideo {
+		if video {
 			localTrack = participant.VideoTrack
 		} else {
 			localTrack = participant.AudioTrack
+			speaking.observe(buf[:n])
+		}
+
+		// Rooms that played a clip keep the presenter spliced in behind it
+		if sp := s.spliceFor(r.ID); sp != nil && localTrack != nil {
+			results.add(s.forwardSpliced(r, sp, localTrack, video, buf[:n]))
+			continue
 		}
 
+		r.TapMedia(video, buf[:n])
 		if localTrack != nil {
+			// Writes to viewers that just left fail with ErrClosedPipe
 			if _, err := localTrack.Write(buf[:n]); err != nil && err != io.ErrClosedPipe {
-				// Don't log every write error to avoid spam
+				results.add(0, 1) // Not logged, to avoid spam
+			} else {
+				results.add(1, 0)
 			}
 		}
 	}
 }
 
+// RequestKeyframe asks the presenter's browser for a video keyframe, so a
+// consumer joining mid-stream, such as a re-stream, can start decoding.
+func (s *Service) RequestKeyframe(r *room.Room) error {
+	presenter := r.GetPresenter()
+	if presenter == nil {
+		return ErrNoPresenter
+	}
+	peerConn := presenter.PeerConn
+	if peerConn == nil {
+		return ErrNoPeerConnection
+	}
+	// Every simulcast layer, as the top one feeds consumers
+	var plis []rtcp.Packet
+	for _, receiver := range peerConn.GetReceivers() {
+		for _, track := range receiver.Tracks() {
+			if track.Kind() == webrtc.RTPCodecTypeVideo {
+				plis = append(plis, &rtcp.PictureLossIndication{MediaSSRC: uint32(track.SSRC())})
+			}
+		}
+	}
+	if len(plis) == 0 {
+		return ErrNoVideoTrack
+	}
+	return peerConn.WriteRTCP(plis)
+}
+
 // sendAnswerToPresenter sends the SDP answer to the presenter.
 func (s *Service) sendAnswerToPresenter(peerConn *webrtc.PeerConnection, participant *room.Participant) {
 	answerJSON, _ := json.Marshal(*peerConn.LocalDescription())
-	msg := Message{Type: "answer", Payload: answerJSON}
-	data, _ := json.Marshal(msg)
-	participant.Conn.Send(data)
+	participant.SendCritical("answer", answerJSON)
 
 	log.Printf("[RTC] Answer sent to presenter (ICE trickle)")
 }
@@ -370,25 +544,77 @@ func (s *Service) HandleViewerJoin(r *room.Room, viewer *room.Participant) error
 	return s.pushStreamToViewer(r, viewer)
 }
 
-// addTracksToViewer adds the presenter's tracks to the viewer's peer connection.
-func (s *Service) addTracksToViewer(peerConn *webrtc.PeerConnection, presenter *room.Participant) error {
+// addTracksToViewer adds the presenter's tracks to the viewer's peer connection
+// and starts collecting the viewer's receiver reports. Of a simulcasting
+// presenter the viewer gets a video track of their own.
+func (s *Service) addTracksToViewer(peerConn *webrtc.PeerConnection, r *room.Room, presenter, viewer *room.Participant) error {
+	viewer.Stats.SetBaseline(presenter.Stats.Received())
+
 	if presenter.VideoTrack != nil {
-		if _, err := peerConn.AddTrack(presenter.VideoTrack); err != nil {
+		video := presenter.VideoTrack
+		layers := s.simulcastFor(r.ID)
+		if layers != nil {
+			var err error
+			if video, err = layers.attach(viewer.ID); err != nil {
+				return fmt.Errorf("failed to create video track: %w", err)
+			}
+		}
+		sender, err := peerConn.AddTrack(video)
+		if err != nil {
+			if layers != nil {
+				layers.detach(viewer.ID, video)
+			}
 			return fmt.Errorf("failed to add video track: %w", err)
 		}
+		go s.readViewerRTCP(sender, viewer, layers, video)
 		log.Printf("[RTC] Added video track for viewer")
 	}
 
 	if presenter.AudioTrack != nil {
-		if _, err := peerConn.AddTrack(presenter.AudioTrack); err != nil {
+		sender, err := peerConn.AddTrack(presenter.AudioTrack)
+		if err != nil {
 			return fmt.Errorf("failed to add audio track: %w", err)
 		}
+		go s.readViewerRTCP(sender, viewer, nil, nil)
 		log.Printf("[RTC] Added audio track for viewer")
 	}
 
 	return nil
 }
 
+// readViewerRTCP reads RTCP from a viewer's sender until it closes, recording
+// the packet loss the viewer reports for its downlink. For the viewer's own
+// simulcast track, loss picks the layer forwarded to track and picture loss
+// asks for a keyframe of it.
+func (s *Service) readViewerRTCP(sender *webrtc.RTPSender, viewer *room.Participant, layers *simulcastGroup, track *webrtc.TrackLocalStaticRTP) {
+	if layers != nil {
+		defer layers.detach(viewer.ID, track)
+	}
+	for {
+		packets, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+		for _, pkt := range packets {
+			switch pkt := pkt.(type) {
+			case *rtcp.ReceiverReport:
+				for _, report := range pkt.Reports {
+					if lost := viewer.Stats.RecordReceiverReport(report.SSRC, report.TotalLost, report.FractionLost); lost > 0 {
+						downlinkPacketsLost.Add(uint64(lost))
+					}
+					if layers != nil {
+						layers.observeLoss(viewer.ID, report.FractionLost)
+					}
+				}
+			case *rtcp.PictureLossIndication:
+				if layers != nil {
+					layers.requestKeyframe(viewer.ID)
+				}
+			}
+		}
+	}
+}
+
 // setupViewerHandlers configures event handlers for the viewer's peer connection.
 func (s *Service) setupViewerHandlers(peerConn *webrtc.PeerConnection, viewer *room.Participant, r *room.Room) {
 	peerConn.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
@@ -405,6 +631,10 @@ func (s *Service) setupViewerHandlers(peerConn *webrtc.PeerConnection, viewer *r
 			viewer.Conn.Send(data)
 
 		case webrtc.PeerConnectionStateFailed:
+			if s.restarts.isPending(viewer.ID) {
+				log.Printf("[RTC] Viewer %s connection failed, ICE restart in progress", viewer.ID)
+				return
+			}
 			log.Printf("[RTC] ❌ Viewer %s connection failed", viewer.ID)
 			// Set to waiting so they can be pushed a new stream when ready
 			viewer.SetState(room.StateWaiting)
@@ -426,6 +656,7 @@ func (s *Service) setupViewerHandlers(peerConn *webrtc.PeerConnection, viewer *r
 		case webrtc.PeerConnectionStateClosed:
 			log.Printf("[RTC] Viewer %s connection closed", viewer.ID)
 			viewer.SetState(room.StateIdle)
+			s.restarts.forget(viewer.ID)
 		}
 	})
 
@@ -433,31 +664,16 @@ func (s *Service) setupViewerHandlers(peerConn *webrtc.PeerConnection, viewer *r
 		log.Printf("[RTC] Viewer %s ICE state: %s", viewer.ID, state.String())
 
 		if state == webrtc.ICEConnectionStateFailed {
-			log.Printf("[RTC] Viewer %s ICE failed, attempting ICE restart", viewer.ID)
-			// ICE restart can help recover failed connections
+			// A failure while a restart is in flight means that restart didn't help
+			s.restarts.failed(viewer.ID)
 			if peerConn.ConnectionState() != webrtc.PeerConnectionStateClosed {
-				go func() {
-					offer, err := peerConn.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
-					if err != nil {
-						log.Printf("[RTC] ICE restart offer failed: %v", err)
-						return
-					}
-					if err := peerConn.SetLocalDescription(offer); err != nil {
-						log.Printf("[RTC] ICE restart setLocalDescription failed: %v", err)
-						return
-					}
-					// Send new offer to viewer
-					offerJSON, _ := json.Marshal(*peerConn.LocalDescription())
-					msg := Message{Type: "offer", Payload: offerJSON}
-					data, _ := json.Marshal(msg)
-					viewer.Conn.Send(data)
-					log.Printf("[RTC] ICE restart offer sent to viewer %s", viewer.ID)
-				}()
+				s.scheduleICERestart(peerConn, viewer)
 			}
 		}
 
 		if state == webrtc.ICEConnectionStateConnected {
 			log.Printf("[RTC] ✅ Viewer %s ICE connected", viewer.ID)
+			s.restarts.connected(viewer.ID)
 		}
 	})
 
@@ -470,6 +686,63 @@ func (s *Service) setupViewerHandlers(peerConn *webrtc.PeerConnection, viewer *r
 	})
 }
 
+// scheduleICERestart restarts ICE for a viewer after the policy backoff. When
+// the viewer's retry budget is spent or the circuit breaker is open, the
+// connection is torn down and the viewer is told to perform a full rejoin.
+func (s *Service) scheduleICERestart(peerConn *webrtc.PeerConnection, viewer *room.Participant) {
+	backoff, rejoinReason := s.restarts.next(viewer.ID)
+	if rejoinReason != "" {
+		log.Printf("[RTC] Viewer %s needs a full rejoin (%s)", viewer.ID, rejoinReason)
+		viewerRejoins.WithLabelValues(rejoinReason).Inc()
+		s.requestRejoin(peerConn, viewer, rejoinReason)
+		return
+	}
+
+	log.Printf("[RTC] Viewer %s ICE failed, attempting ICE restart in %v", viewer.ID, backoff)
+	go func() {
+		time.Sleep(backoff)
+
+		// The viewer may have left or been pushed a new connection meanwhile
+		if viewer.PeerConn != peerConn || peerConn.ConnectionState() == webrtc.PeerConnectionStateClosed {
+			s.restarts.forget(viewer.ID)
+			return
+		}
+
+		iceRestarts.WithLabelValues("attempted").Inc()
+		offer, err := peerConn.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+		if err != nil {
+			log.Printf("[RTC] ICE restart offer failed: %v", err)
+			s.restarts.failed(viewer.ID)
+			return
+		}
+		if err := peerConn.SetLocalDescription(offer); err != nil {
+			log.Printf("[RTC] ICE restart setLocalDescription failed: %v", err)
+			s.restarts.failed(viewer.ID)
+			return
+		}
+		// Send new offer to viewer
+		offerJSON, _ := json.Marshal(*peerConn.LocalDescription())
+		viewer.SendCritical("offer", offerJSON)
+		log.Printf("[RTC] ICE restart offer sent to viewer %s", viewer.ID)
+	}()
+}
+
+// requestRejoin closes the viewer's peer connection and asks the client to
+// reconnect from scratch.
+func (s *Service) requestRejoin(peerConn *webrtc.PeerConnection, viewer *room.Participant, reason string) {
+	if viewer.PeerConn == peerConn {
+		viewer.PeerConn = nil
+	}
+	peerConn.Close()
+	viewer.ClearPendingICE()
+	viewer.SetState(room.StateWaiting)
+	s.restarts.forget(viewer.ID)
+
+	payload, _ := json.Marshal(map[string]string{"reason": reason})
+	data, _ := json.Marshal(Message{Type: "rejoin-required", Payload: payload})
+	viewer.Conn.Send(data)
+}
+
 // createAndSendOffer creates an SDP offer and sends it to the viewer.
 func (s *Service) createAndSendOffer(peerConn *webrtc.PeerConnection, viewer *room.Participant) error {
 	offer, err := peerConn.CreateOffer(nil)
@@ -483,9 +756,7 @@ func (s *Service) createAndSendOffer(peerConn *webrtc.PeerConnection, viewer *ro
 
 	// Send offer immediately - ICE candidates will trickle
 	offerJSON, _ := json.Marshal(*peerConn.LocalDescription())
-	msg := Message{Type: "offer", Payload: offerJSON}
-	data, _ := json.Marshal(msg)
-	viewer.Conn.Send(data)
+	viewer.SendCritical("offer", offerJSON)
 	log.Printf("[RTC] Offer sent to viewer %s (ICE trickle)", viewer.ID)
 
 	return nil
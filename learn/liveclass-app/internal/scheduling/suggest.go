@@ -0,0 +1,158 @@
+// Package scheduling suggests open time slots for classes, ranked by how
+// many students they would clash with.
+package scheduling
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WorkingHours is the part of the week classes may be scheduled in.
+type WorkingHours struct {
+	Days     map[time.Weekday]bool
+	Start    time.Duration // Offset from midnight
+	End      time.Duration // Offset from midnight
+	Location *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWorkingHours parses working days ("mon" to "sun"), a daily start
+// and end ("09:00", "18:00") and an IANA time zone ("" for UTC).
+func ParseWorkingHours(days []string, start, end, timezone string) (WorkingHours, error) {
+	wh := WorkingHours{Days: make(map[time.Weekday]bool), Location: time.UTC}
+	for _, day := range days {
+		d, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+		if !ok {
+			return wh, fmt.Errorf("unknown working day %q", day)
+		}
+		wh.Days[d] = true
+	}
+	if len(wh.Days) == 0 {
+		return wh, fmt.Errorf("no working days")
+	}
+
+	var err error
+	if wh.Start, err = parseClock(start); err != nil {
+		return wh, err
+	}
+	if wh.End, err = parseClock(end); err != nil {
+		return wh, err
+	}
+	if wh.End <= wh.Start {
+		return wh, fmt.Errorf("working hours end %s is not after start %s", end, start)
+	}
+	if timezone != "" {
+		if wh.Location, err = time.LoadLocation(timezone); err != nil {
+			return wh, err
+		}
+	}
+	return wh, nil
+}
+
+// parseClock parses "HH:MM" as an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Busy is a period when the presenter, the batch or some of its students
+// have another class.
+type Busy struct {
+	Start, End time.Time
+	Blocks     bool     // The presenter or the whole batch has a class then; no slot may overlap
+	Students   []string // IDs of the batch's students in another class then
+}
+
+// Request describes the class to find slots for.
+type Request struct {
+	Duration time.Duration
+	From, To time.Time     // Window to search; slots start and end inside it
+	Step     time.Duration // Spacing of candidate start times
+	Limit    int
+	Students int // Size of the batch, for the conflict ratio
+}
+
+// Slot is a suggested time for the class.
+type Slot struct {
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	StudentConflicts   int       `json:"studentConflicts"`   // Students with another class overlapping
+	ConflictRatio      float64   `json:"conflictRatio"`      // StudentConflicts over the batch size
+	ConflictingClasses int       `json:"conflictingClasses"` // Other classes overlapping
+}
+
+// Suggest returns up to req.Limit slots inside working hours that don't
+// overlap blocking periods, fewest student conflicts first and earliest
+// first among equals.
+func Suggest(wh WorkingHours, busy []Busy, req Request) []Slot {
+	if req.Step <= 0 {
+		req.Step = 30 * time.Minute
+	}
+
+	var slots []Slot
+	for _, start := range candidates(wh, req) {
+		end := start.Add(req.Duration)
+		slot := Slot{Start: start, End: end}
+		students := make(map[string]bool)
+		blocked := false
+		for _, b := range busy {
+			if !b.Start.Before(end) || !start.Before(b.End) {
+				continue
+			}
+			if b.Blocks {
+				blocked = true
+				break
+			}
+			slot.ConflictingClasses++
+			for _, id := range b.Students {
+				students[id] = true
+			}
+		}
+		if blocked {
+			continue
+		}
+		slot.StudentConflicts = len(students)
+		if req.Students > 0 {
+			slot.ConflictRatio = float64(slot.StudentConflicts) / float64(req.Students)
+		}
+		slots = append(slots, slot)
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].StudentConflicts < slots[j].StudentConflicts
+	})
+	if req.Limit > 0 && len(slots) > req.Limit {
+		slots = slots[:req.Limit]
+	}
+	return slots
+}
+
+// candidates returns the start times, in order, of slots that fit in working
+// hours inside the request window.
+func candidates(wh WorkingHours, req Request) []time.Time {
+	var starts []time.Time
+	from, to := req.From.In(wh.Location), req.To.In(wh.Location)
+
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, wh.Location); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !wh.Days[day.Weekday()] {
+			continue
+		}
+		dayStart, dayEnd := day.Add(wh.Start), day.Add(wh.End)
+		for start := dayStart; !start.Add(req.Duration).After(dayEnd); start = start.Add(req.Step) {
+			if start.Before(from) || start.Add(req.Duration).After(to) {
+				continue
+			}
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
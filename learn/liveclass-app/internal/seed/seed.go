@@ -0,0 +1,324 @@
+// Package seed generates demo data for development and QA environments:
+// users of every role, batches, classes in every status, recordings and notes.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// EmailDomain is the domain of every generated account.
+const EmailDomain = "demo.liveclass.local"
+
+// ErrAlreadySeeded is returned when demo accounts already exist in the database.
+var ErrAlreadySeeded = errors.New("demo data already exists")
+
+// Options controls how much data is generated.
+type Options struct {
+	Presenters  int
+	Students    int
+	Batches     int
+	Password    string // Password of every demo account
+	StoragePath string // Root for placeholder recording and note files
+	RandSeed    int64  // Same seed, same data
+}
+
+// DefaultOptions returns a small but representative data set.
+func DefaultOptions() Options {
+	return Options{
+		Presenters:  3,
+		Students:    24,
+		Batches:     4,
+		Password:    "demo1234",
+		StoragePath: "./storage",
+		RandSeed:    1,
+	}
+}
+
+// Result counts the generated records.
+type Result struct {
+	Users      int
+	Batches    int
+	Schedules  int
+	Recordings int
+	Notes      int
+}
+
+// Seeder writes demo data through the regular repositories.
+type Seeder struct {
+	userRepo      *repository.UserRepository
+	batchRepo     *repository.BatchRepository
+	scheduleRepo  *repository.ScheduleRepository
+	recordingRepo *repository.RecordingRepository
+	noteRepo      *repository.NoteRepository
+}
+
+// NewSeeder creates a new Seeder.
+func NewSeeder(userRepo *repository.UserRepository, batchRepo *repository.BatchRepository, scheduleRepo *repository.ScheduleRepository, recordingRepo *repository.RecordingRepository, noteRepo *repository.NoteRepository) *Seeder {
+	return &Seeder{
+		userRepo:      userRepo,
+		batchRepo:     batchRepo,
+		scheduleRepo:  scheduleRepo,
+		recordingRepo: recordingRepo,
+		noteRepo:      noteRepo,
+	}
+}
+
+var (
+	firstNames = []string{"Aarav", "Maya", "Liam", "Zara", "Noah", "Aisha", "Ethan", "Priya", "Lucas", "Sara", "Omar", "Chloe", "Ravi", "Emma", "Yusuf", "Nina"}
+	lastNames  = []string{"Sharma", "Nair", "Smith", "Khan", "Garcia", "Chen", "Joseph", "Mathew", "Brown", "Ali", "Menon", "Lopez"}
+	subjects   = []string{"Algebra", "Physics", "Chemistry", "Biology", "English Literature", "World History", "Computer Science", "Geography"}
+	topics     = []string{"Introduction", "Problem solving", "Revision", "Lab walkthrough", "Q&A session", "Mock test review", "Case studies", "Deep dive"}
+)
+
+// Seed generates the demo data set. It refuses to run twice against the same
+// database, so QA environments can call it on every deploy.
+func (s *Seeder) Seed(ctx context.Context, opts Options) (*Result, error) {
+	if _, err := s.userRepo.FindByEmail(ctx, demoEmail("presenter", 1)); err == nil {
+		return nil, ErrAlreadySeeded
+	}
+
+	rng := rand.New(rand.NewSource(opts.RandSeed))
+	hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	now := time.Now()
+
+	admin, err := s.createUser(ctx, rng, "admin", 1, models.RoleAdmin, models.StatusApproved, string(hash))
+	if err != nil {
+		return nil, err
+	}
+	res.Users++
+
+	presenters := make([]*models.User, 0, opts.Presenters)
+	for i := 1; i <= opts.Presenters; i++ {
+		p, err := s.createUser(ctx, rng, "presenter", i, models.RolePresenter, models.StatusApproved, string(hash))
+		if err != nil {
+			return nil, err
+		}
+		presenters = append(presenters, p)
+		res.Users++
+	}
+	if len(presenters) == 0 {
+		return res, nil
+	}
+
+	// Most students are approved; a few pending and rejected ones exercise the admin queue
+	students := make([]*models.User, 0, opts.Students)
+	for i := 1; i <= opts.Students; i++ {
+		status := models.StatusApproved
+		switch {
+		case i%10 == 0:
+			status = models.StatusPending
+		case i%13 == 0:
+			status = models.StatusRejected
+		}
+		st, err := s.createUser(ctx, rng, "student", i, models.RoleStudent, status, string(hash))
+		if err != nil {
+			return nil, err
+		}
+		if status == models.StatusApproved {
+			students = append(students, st)
+		}
+		res.Users++
+	}
+
+	for b := 0; b < opts.Batches; b++ {
+		presenter := presenters[b%len(presenters)]
+		subject := subjects[b%len(subjects)]
+
+		batch := &models.Batch{
+			Name:        fmt.Sprintf("%s %d", subject, 2026+b/len(subjects)),
+			Description: fmt.Sprintf("Demo batch for %s taught by %s", subject, presenter.Name),
+			PresenterID: presenter.ID,
+			CreatedBy:   admin.ID,
+
+			PresenterName: presenter.Name,
+		}
+		// Each approved student joins one or two batches
+		for i, st := range students {
+			if i%opts.Batches == b || (i+1)%opts.Batches == b && rng.Intn(2) == 0 {
+				batch.StudentIDs = append(batch.StudentIDs, st.ID)
+			}
+		}
+		if err := s.batchRepo.Create(ctx, batch); err != nil {
+			return nil, fmt.Errorf("create batch: %w", err)
+		}
+		res.Batches++
+
+		if err := s.seedSchedules(ctx, rng, batch, presenter, subject, now, opts.StoragePath, res); err != nil {
+			return nil, err
+		}
+		if err := s.seedNotes(ctx, rng, batch, presenter, subject, opts.StoragePath, res); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// seedSchedules creates past, live, cancelled and upcoming classes for a batch.
+// Completed classes get a recording.
+func (s *Seeder) seedSchedules(ctx context.Context, rng *rand.Rand, batch *models.Batch, presenter *models.User, subject string, now time.Time, storagePath string, res *Result) error {
+	plan := []struct {
+		offset time.Duration
+		status models.ClassStatus
+	}{
+		{-14 * 24 * time.Hour, models.ClassStatusCompleted},
+		{-7 * 24 * time.Hour, models.ClassStatusCompleted},
+		{-3 * 24 * time.Hour, models.ClassStatusCancelled},
+		{-20 * time.Minute, models.ClassStatusLive},
+		{26 * time.Hour, models.ClassStatusScheduled},
+		{3 * 24 * time.Hour, models.ClassStatusScheduled},
+		{7 * 24 * time.Hour, models.ClassStatusScheduled},
+	}
+
+	for i, p := range plan {
+		start := now.Add(p.offset).Truncate(15 * time.Minute)
+		schedule := &models.ScheduledClass{
+			Title:       fmt.Sprintf("%s: %s", subject, topics[rng.Intn(len(topics))]),
+			Description: "Demo class",
+			BatchID:     batch.ID,
+			PresenterID: presenter.ID,
+			StartTime:   start,
+			EndTime:     start.Add(time.Hour),
+			Proctored:   i == len(plan)-1,
+
+			BatchName:     batch.Name,
+			PresenterName: presenter.Name,
+		}
+		if err := s.scheduleRepo.Create(ctx, schedule); err != nil {
+			return fmt.Errorf("create schedule: %w", err)
+		}
+		res.Schedules++
+
+		if p.status != models.ClassStatusScheduled {
+			roomID := ""
+			if p.status != models.ClassStatusCancelled {
+				roomID = strings.ToUpper(primitive.NewObjectID().Hex()[16:])
+			}
+			if err := s.scheduleRepo.UpdateStatus(ctx, schedule.ID.Hex(), p.status, roomID); err != nil {
+				return fmt.Errorf("update schedule status: %w", err)
+			}
+		}
+
+		if p.status == models.ClassStatusCompleted {
+			if err := s.seedRecording(ctx, rng, schedule, storagePath); err != nil {
+				return err
+			}
+			res.Recordings++
+		}
+	}
+
+	return nil
+}
+
+// seedRecording creates a ready recording backed by a small placeholder file.
+// The file is not playable video; it only lets list and download views work.
+func (s *Seeder) seedRecording(ctx context.Context, rng *rand.Rand, schedule *models.ScheduledClass, storagePath string) error {
+	fileName := "demo-" + schedule.ID.Hex() + ".webm"
+	filePath := filepath.Join(storagePath, "recordings", fileName)
+	size, err := writePlaceholder(filePath, "LiveClass demo recording placeholder for "+schedule.Title)
+	if err != nil {
+		return err
+	}
+
+	recording := &models.Recording{
+		ScheduleID:  schedule.ID,
+		BatchID:     schedule.BatchID,
+		PresenterID: schedule.PresenterID,
+		Title:       schedule.Title,
+		Description: "Demo recording",
+		FileName:    fileName,
+		FilePath:    filePath,
+		FileSize:    size,
+		Duration:    45*60 + rng.Intn(15*60),
+		MimeType:    "video/webm",
+		Status:      models.RecordingStatusReady,
+		RecordedAt:  schedule.StartTime,
+
+		BatchName:     schedule.BatchName,
+		PresenterName: schedule.PresenterName,
+	}
+	if err := s.recordingRepo.Create(ctx, recording); err != nil {
+		return fmt.Errorf("create recording: %w", err)
+	}
+	return nil
+}
+
+// seedNotes uploads a syllabus and a worksheet to a batch.
+func (s *Seeder) seedNotes(ctx context.Context, rng *rand.Rand, batch *models.Batch, presenter *models.User, subject string, storagePath string, res *Result) error {
+	for _, title := range []string{"Syllabus", fmt.Sprintf("Worksheet %d", 1+rng.Intn(9))} {
+		fileName := fmt.Sprintf("demo-%s-%s.txt", batch.ID.Hex(), strings.ToLower(strings.ReplaceAll(title, " ", "-")))
+		filePath := filepath.Join(storagePath, "notes", fileName)
+		size, err := writePlaceholder(filePath, fmt.Sprintf("%s - %s\n\nDemo material for %s.\n", subject, title, batch.Name))
+		if err != nil {
+			return err
+		}
+
+		note := &models.Note{
+			Title:        fmt.Sprintf("%s %s", subject, title),
+			Description:  "Demo material",
+			FileName:     fileName,
+			FilePath:     filePath,
+			FileSize:     size,
+			FileType:     models.NoteTypeDocument,
+			MimeType:     "text/plain",
+			BatchID:      batch.ID,
+			BatchName:    batch.Name,
+			UploaderID:   presenter.ID,
+			UploaderName: presenter.Name,
+			UploaderRole: string(presenter.Role),
+		}
+		if err := s.noteRepo.Create(ctx, note); err != nil {
+			return fmt.Errorf("create note: %w", err)
+		}
+		res.Notes++
+	}
+	return nil
+}
+
+// createUser creates a demo account like presenter2@demo.liveclass.local.
+func (s *Seeder) createUser(ctx context.Context, rng *rand.Rand, kind string, n int, role models.UserRole, status models.UserStatus, passwordHash string) (*models.User, error) {
+	user := &models.User{
+		Email:        demoEmail(kind, n),
+		PasswordHash: passwordHash,
+		Name:         firstNames[rng.Intn(len(firstNames))] + " " + lastNames[rng.Intn(len(lastNames))],
+		Role:         role,
+		Status:       status,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("create %s: %w", user.Email, err)
+	}
+	return user, nil
+}
+
+// demoEmail returns the address of the n-th demo account of a kind.
+func demoEmail(kind string, n int) string {
+	return fmt.Sprintf("%s%d@%s", kind, n, EmailDomain)
+}
+
+// writePlaceholder writes content to path, creating parent directories, and returns its size.
+func writePlaceholder(path, content string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return 0, err
+	}
+	return int64(len(content)), nil
+}
@@ -1,9 +1,10 @@
 package server
 
 import (
-	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
@@ -14,37 +15,17 @@ import (
 type AdminHandler struct {
 	authService *auth.Service
 	userRepo    *repository.UserRepository
+	usageRepo   *repository.StorageUsageRepository
+	sessions    *SessionCloser
 }
 
 // NewAdminHandler creates a new AdminHandler.
-func NewAdminHandler(authService *auth.Service, userRepo *repository.UserRepository) *AdminHandler {
+func NewAdminHandler(authService *auth.Service, userRepo *repository.UserRepository, usageRepo *repository.StorageUsageRepository, sessions *SessionCloser) *AdminHandler {
 	return &AdminHandler{
 		authService: authService,
 		userRepo:    userRepo,
-	}
-}
-
-// requireAdmin is middleware that ensures the request is from an admin.
-func (h *AdminHandler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		token := extractToken(r)
-		if token == "" {
-			sendJSONError(w, "Authorization required", http.StatusUnauthorized)
-			return
-		}
-
-		user, err := h.authService.GetUserFromToken(r.Context(), token)
-		if err != nil {
-			sendJSONError(w, "Invalid or expired token", http.StatusUnauthorized)
-			return
-		}
-
-		if !user.IsAdmin() {
-			sendJSONError(w, "Admin access required", http.StatusForbidden)
-			return
-		}
-
-		next(w, r)
+		usageRepo:   usageRepo,
+		sessions:    sessions,
 	}
 }
 
@@ -70,7 +51,7 @@ func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 
 	users, err := h.userRepo.FindAll(r.Context(), statusFilter, roleFilter)
 	if err != nil {
-		sendJSONError(w, "Failed to fetch users", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to fetch users", err)
 		return
 	}
 
@@ -80,7 +61,7 @@ func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 		response[i] = u.ToResponse()
 	}
 
-	sendJSON(w, response, http.StatusOK)
+	sendJSONFields(w, r, response, http.StatusOK)
 }
 
 // GetPendingUsers returns all users pending approval.
@@ -92,7 +73,7 @@ func (h *AdminHandler) GetPendingUsers(w http.ResponseWriter, r *http.Request) {
 
 	users, err := h.userRepo.FindPendingUsers(r.Context())
 	if err != nil {
-		sendJSONError(w, "Failed to fetch pending users", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to fetch pending users", err)
 		return
 	}
 
@@ -104,7 +85,8 @@ func (h *AdminHandler) GetPendingUsers(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, response, http.StatusOK)
 }
 
-// UpdateUserStatus handles approve/reject/suspend actions.
+// UpdateUserStatus handles approve/reject/suspend actions. Suspending a user
+// signs out all their devices at once.
 func (h *AdminHandler) UpdateUserStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut && r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -121,28 +103,23 @@ func (h *AdminHandler) UpdateUserStatus(w http.ResponseWriter, r *http.Request)
 	userID := parts[0]
 
 	var req struct {
-		Status models.UserStatus `json:"status"`
+		Status models.UserStatus `json:"status" validate:"required,oneof=approved rejected suspended"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Validate status
-	if req.Status != models.StatusApproved && req.Status != models.StatusRejected && req.Status != models.StatusSuspended {
-		sendJSONError(w, "Invalid status. Must be: approved, rejected, or suspended", http.StatusBadRequest)
+	admin, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
-	// Get admin user ID from token
-	token := extractToken(r)
-	claims, _ := h.authService.ValidateToken(token)
-	adminID := ""
-	if claims != nil {
-		adminID = claims.UserID
+	var err error
+	if req.Status == models.StatusSuspended {
+		err = h.sessions.Suspend(r.Context(), userID, admin.ID.Hex())
+	} else {
+		err = h.userRepo.UpdateStatus(r.Context(), userID, req.Status, admin.ID.Hex())
 	}
-
-	err := h.userRepo.UpdateStatus(r.Context(), userID, req.Status, adminID)
 	if err != nil {
 		if err == repository.ErrUserNotFound {
 			sendJSONError(w, "User not found", http.StatusNotFound)
@@ -175,9 +152,11 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prevent admin from deleting themselves
-	token := extractToken(r)
-	claims, _ := h.authService.ValidateToken(token)
-	if claims != nil && claims.UserID == userID {
+	admin, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	if admin.ID.Hex() == userID {
 		sendJSONError(w, "Cannot delete your own account", http.StatusBadRequest)
 		return
 	}
@@ -221,6 +200,52 @@ func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		"approvedCount":  len(approved),
 		"presenterCount": len(presenters),
 		"studentCount":   len(students),
+		"storage":        h.storageStats(r),
 	}, http.StatusOK)
 }
 
+// storageTrendDays is how many days of storage history the stats include by default.
+const storageTrendDays = 30
+
+// storageStats returns the latest storage report and the daily usage trend
+// (?storageDays= overrides the number of days, up to a year).
+func (h *AdminHandler) storageStats(r *http.Request) map[string]interface{} {
+	days := storageTrendDays
+	if d, err := strconv.Atoi(r.URL.Query().Get("storageDays")); err == nil && d > 0 && d <= 365 {
+		days = d
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+	history, err := h.usageRepo.FindSince(r.Context(), since)
+	if err != nil || len(history) == 0 {
+		return nil
+	}
+
+	type point struct {
+		Date           time.Time `json:"date"`
+		TotalBytes     int64     `json:"totalBytes"`
+		RecordingBytes int64     `json:"recordingBytes"`
+		NoteBytes      int64     `json:"noteBytes"`
+	}
+	trend := make([]point, len(history))
+	for i, s := range history {
+		trend[i] = point{s.Date, s.TotalBytes, s.RecordingBytes, s.NoteBytes}
+	}
+
+	latest := history[len(history)-1]
+	first := history[0]
+
+	// Average daily growth over the period
+	var dailyGrowth int64
+	if elapsed := int64(latest.Date.Sub(first.Date).Hours() / 24); elapsed > 0 {
+		dailyGrowth = (latest.TotalBytes - first.TotalBytes) / elapsed
+	}
+
+	return map[string]interface{}{
+		"current":          latest,
+		"trend":            trend,
+		"changeBytes":      latest.TotalBytes - first.TotalBytes,
+		"dailyGrowthBytes": dailyGrowth,
+	}
+}
+
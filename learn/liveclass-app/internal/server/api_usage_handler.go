@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/apiusage"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+const (
+	apiUsageDefaultDays = 7
+	apiUsageMaxDays     = 93
+)
+
+// apiUsageDay is a client's usage on one day, with its quota.
+type apiUsageDay struct {
+	models.APIUsage
+	ErrorRate float64 `json:"errorRate"`
+	Quota     int64   `json:"quota,omitempty"` // Requests per day
+}
+
+// apiUsageTotal is a client's usage over the whole range.
+type apiUsageTotal struct {
+	Client    string  `json:"client"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+	BytesIn   int64   `json:"bytesIn"`
+	BytesOut  int64   `json:"bytesOut"`
+	Quota     int64   `json:"quota,omitempty"`
+}
+
+// APIUsageHandler reports how much external clients use the API.
+type APIUsageHandler struct {
+	usageRepo *repository.APIUsageRepository
+	tracker   *apiusage.Tracker
+}
+
+// NewAPIUsageHandler creates a new APIUsageHandler.
+func NewAPIUsageHandler(usageRepo *repository.APIUsageRepository, tracker *apiusage.Tracker) *APIUsageHandler {
+	return &APIUsageHandler{
+		usageRepo: usageRepo,
+		tracker:   tracker,
+	}
+}
+
+// Usage returns the daily usage of each client between ?from= and ?to=
+// (YYYY-MM-DD, UTC, inclusive; the last 7 days by default), optionally for
+// one ?client=, with totals per client (GET /api/admin/api-usage).
+func (h *APIUsageHandler) Usage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to, from := today, today.AddDate(0, 0, 1-apiUsageDefaultDays)
+	var err error
+	if v := query.Get("to"); v != "" {
+		if to, err = time.Parse(apiusage.DayFormat, v); err != nil {
+			sendJSONError(w, "Invalid to date, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = to.AddDate(0, 0, 1-apiUsageDefaultDays)
+	}
+	if v := query.Get("from"); v != "" {
+		if from, err = time.Parse(apiusage.DayFormat, v); err != nil {
+			sendJSONError(w, "Invalid from date, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if from.After(to) {
+		sendJSONError(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) >= apiUsageMaxDays*24*time.Hour {
+		sendJSONError(w, "Date range too long (max 93 days)", http.StatusBadRequest)
+		return
+	}
+
+	usage, err := h.usageRepo.FindRange(r.Context(), from.Format(apiusage.DayFormat), to.Format(apiusage.DayFormat), query.Get("client"))
+	if err != nil {
+		sendStoreError(w, "Failed to fetch API usage", err)
+		return
+	}
+
+	days := make([]apiUsageDay, 0, len(usage))
+	totals := make(map[string]*apiUsageTotal)
+	for _, u := range usage {
+		quota := h.tracker.Quota(u.Client)
+		days = append(days, apiUsageDay{APIUsage: u, ErrorRate: u.ErrorRate(), Quota: quota})
+
+		total, ok := totals[u.Client]
+		if !ok {
+			total = &apiUsageTotal{Client: u.Client, Quota: quota}
+			totals[u.Client] = total
+		}
+		total.Requests += u.Requests
+		total.Errors += u.Errors
+		total.BytesIn += u.BytesIn
+		total.BytesOut += u.BytesOut
+	}
+
+	clients := make([]apiUsageTotal, 0, len(totals))
+	for _, total := range totals {
+		if total.Requests > 0 {
+			total.ErrorRate = float64(total.Errors) / float64(total.Requests)
+		}
+		clients = append(clients, *total)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Requests > clients[j].Requests })
+
+	sendJSON(w, map[string]interface{}{
+		"from":    from.Format(apiusage.DayFormat),
+		"to":      to.Format(apiusage.DayFormat),
+		"clients": clients,
+		"days":    days,
+	}, http.StatusOK)
+}
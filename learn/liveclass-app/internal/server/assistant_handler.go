@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// AssistantHandler manages batch-scoped teaching assistants. Assistants keep
+// their global role; their extra rights apply only inside the batches they assist.
+type AssistantHandler struct {
+	authService  *auth.Service
+	batchRepo    *repository.BatchRepository
+	scheduleRepo *repository.ScheduleRepository
+	userRepo     *repository.UserRepository
+}
+
+// NewAssistantHandler creates a new AssistantHandler.
+func NewAssistantHandler(authService *auth.Service, batchRepo *repository.BatchRepository, scheduleRepo *repository.ScheduleRepository, userRepo *repository.UserRepository) *AssistantHandler {
+	return &AssistantHandler{
+		authService:  authService,
+		batchRepo:    batchRepo,
+		scheduleRepo: scheduleRepo,
+		userRepo:     userRepo,
+	}
+}
+
+// IsRoomAssistant reports whether the user assists the batch of the class running in a room.
+func (h *AssistantHandler) IsRoomAssistant(ctx context.Context, roomID, userID string) bool {
+	if roomID == "" || userID == "" {
+		return false
+	}
+
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, strings.ToUpper(roomID))
+	if err != nil {
+		return false
+	}
+	batch, err := h.batchRepo.FindByID(ctx, schedule.BatchID.Hex())
+	if err != nil {
+		return false
+	}
+	return batch.HasAssistant(userID)
+}
+
+// ListAssistants returns a batch's assistants (GET /api/batches/{id}/assistants).
+// Admin, the batch presenter or one of its assistants.
+func (h *AssistantHandler) ListAssistants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, batch, ok := h.loadBatch(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID && !batch.HasAssistant(user.ID.Hex()) {
+		sendJSONError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	assistants := make([]models.UserResponse, 0, len(batch.AssistantIDs))
+	for _, id := range batch.AssistantIDs {
+		if assistant, err := h.userRepo.FindByID(r.Context(), id.Hex()); err == nil {
+			assistants = append(assistants, assistant.ToResponse())
+		}
+	}
+
+	sendJSON(w, assistants, http.StatusOK)
+}
+
+// AddAssistants adds teaching assistants to a batch (POST /api/batches/{id}/assistants).
+// Admin or the batch presenter only.
+func (h *AssistantHandler) AddAssistants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, batch, ok := h.loadBatch(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the batch presenter can manage assistants", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		UserIDs []string `json:"userIds" validate:"required,max=100,objectid"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Assistants are approved students or presenters; admins already have every right
+	for _, id := range req.UserIDs {
+		assistant, err := h.userRepo.FindByID(r.Context(), id)
+		if err != nil || assistant.Status != models.StatusApproved || assistant.Role == models.RoleAdmin || assistant.ID == batch.PresenterID {
+			sendJSONError(w, "Invalid assistant ID: "+id, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.batchRepo.AddAssistants(r.Context(), batch.ID.Hex(), req.UserIDs); err != nil {
+		sendStoreError(w, "Failed to add assistants", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Assistants added successfully"}, http.StatusOK)
+}
+
+// RemoveAssistant removes a teaching assistant from a batch
+// (DELETE /api/batches/{id}/assistants/{userId}). Admin or the batch presenter only.
+func (h *AssistantHandler) RemoveAssistant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, batch, ok := h.loadBatch(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the batch presenter can manage assistants", http.StatusForbidden)
+		return
+	}
+
+	// Extract user ID from URL: /api/batches/{id}/assistants/{userId}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/batches/"), "/")
+	if len(parts) < 3 || parts[2] == "" {
+		sendJSONError(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.batchRepo.RemoveAssistant(r.Context(), batch.ID.Hex(), parts[2]); err != nil {
+		sendStoreError(w, "Failed to remove assistant", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Assistant removed successfully"}, http.StatusOK)
+}
+
+// loadBatch authenticates the request and loads the batch from /api/batches/{id}/assistants.
+func (h *AssistantHandler) loadBatch(w http.ResponseWriter, r *http.Request) (*models.User, *models.Batch, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/batches/")
+	batchID := strings.Split(path, "/")[0]
+
+	batch, err := h.batchRepo.FindByID(r.Context(), batchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return user, batch, true
+}
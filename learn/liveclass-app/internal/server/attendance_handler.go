@@ -0,0 +1,247 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/attendance"
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/export"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Own attendance page sizes, in classes
+const (
+	defaultMyAttendanceLimit = 50
+	maxMyAttendanceLimit     = 200
+)
+
+// Class attendance export columns (one row per enrolled student or attendee).
+var classAttendanceColumns = []export.Column{
+	{Key: "classId", Header: "Class ID"},
+	{Key: "classTitle", Header: "Class"},
+	{Key: "studentId", Header: "Student ID"},
+	{Key: "studentName", Header: "Student Name"},
+	{Key: "studentEmail", Header: "Student Email"},
+	{Key: "enrolled", Header: "Enrolled"},
+	{Key: "attended", Header: "Attended"},
+	{Key: "joins", Header: "Joins"},
+	{Key: "firstJoined", Header: "First Joined"},
+	{Key: "lastLeft", Header: "Last Left"},
+	{Key: "minutesWatched", Header: "Minutes Watched"},
+}
+
+// AttendanceHandler serves who attended live classes and for how long.
+type AttendanceHandler struct {
+	authService    *auth.Service
+	scheduleRepo   *repository.ScheduleRepository
+	batchRepo      *repository.BatchRepository
+	userRepo       *repository.UserRepository
+	attendanceRepo *repository.AttendanceRepository
+}
+
+// NewAttendanceHandler creates a new AttendanceHandler.
+func NewAttendanceHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, attendanceRepo *repository.AttendanceRepository) *AttendanceHandler {
+	return &AttendanceHandler{
+		authService:    authService,
+		scheduleRepo:   scheduleRepo,
+		batchRepo:      batchRepo,
+		userRepo:       userRepo,
+		attendanceRepo: attendanceRepo,
+	}
+}
+
+// ClassAttendance handles GET /api/schedules/{id}/attendance, the attendance
+// of every enrolled student and anyone else who joined, and
+// GET /api/schedules/{id}/attendance.csv?columns= to export it. Admin, the
+// class presenter or the batch's teaching assistants.
+func (h *AttendanceHandler) ClassAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/attendance[.csv]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), parts[0])
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendStoreError(w, "Batch not found", err)
+		return
+	}
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID && !batch.HasAssistant(user.ID.Hex()) {
+		sendJSONError(w, "Only admin or the class presenter can view attendance", http.StatusForbidden)
+		return
+	}
+
+	stints, err := h.attendanceRepo.FindBySchedules(r.Context(), []primitive.ObjectID{schedule.ID})
+	if err != nil {
+		sendStoreError(w, "Failed to fetch attendance", err)
+		return
+	}
+	rows := h.classRows(r, schedule, batch, attendance.Summarize(schedule, stints, time.Now()))
+
+	if len(parts) >= 2 && parts[1] == "attendance.csv" {
+		h.writeCSV(w, r, schedule, rows)
+		return
+	}
+
+	attended := 0
+	for _, row := range rows {
+		if row.Attended {
+			attended++
+		}
+	}
+	sendJSON(w, map[string]interface{}{
+		"scheduleId": schedule.ID.Hex(),
+		"enrolled":   len(batch.StudentIDs),
+		"attended":   attended,
+		"students":   rows,
+	}, http.StatusOK)
+}
+
+// classRows lists the enrolled students, attended or not, then anyone else
+// who joined, by name.
+func (h *AttendanceHandler) classRows(r *http.Request, schedule *models.ScheduledClass, batch *models.Batch, summaries map[primitive.ObjectID]*models.AttendanceSummary) []models.AttendanceSummary {
+	rows := make([]models.AttendanceSummary, 0, len(batch.StudentIDs)+len(summaries))
+	for _, id := range batch.StudentIDs {
+		row := models.AttendanceSummary{
+			ScheduleID: schedule.ID.Hex(),
+			ClassTitle: schedule.Title,
+			ClassStart: schedule.StartTime,
+			UserID:     id.Hex(),
+		}
+		if s, ok := summaries[id]; ok {
+			row = *s
+			delete(summaries, id)
+		}
+		row.Enrolled = true
+		if student, err := h.userRepo.FindByID(r.Context(), id.Hex()); err == nil {
+			row.Name, row.Email = student.Name, student.Email
+		} else if row.Name == "" {
+			continue // Deleted account that never attended
+		}
+		rows = append(rows, row)
+	}
+
+	var others []models.AttendanceSummary
+	for id, s := range summaries {
+		if attendee, err := h.userRepo.FindByID(r.Context(), id.Hex()); err == nil {
+			s.Name, s.Email = attendee.Name, attendee.Email
+		}
+		others = append(others, *s)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Name < others[j].Name })
+	return append(rows, others...)
+}
+
+// writeCSV streams a class's attendance as CSV.
+func (h *AttendanceHandler) writeCSV(w http.ResponseWriter, r *http.Request, schedule *models.ScheduledClass, rows []models.AttendanceSummary) {
+	columns := export.SelectColumns(classAttendanceColumns, export.ParseColumns(r.URL.Query().Get("columns")))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentName(schedule.Title, "attendance", "csv"))
+
+	writer := export.NewCSVWriter(w)
+	if err := writer.WriteHeader(columns); err != nil {
+		log.Printf("[Attendance] Failed to write export header: %v", err)
+		return
+	}
+	for _, row := range rows {
+		record := map[string]string{
+			"classId":        row.ScheduleID,
+			"classTitle":     row.ClassTitle,
+			"studentId":      row.UserID,
+			"studentName":    row.Name,
+			"studentEmail":   row.Email,
+			"enrolled":       strconv.FormatBool(row.Enrolled),
+			"attended":       strconv.FormatBool(row.Attended),
+			"joins":          strconv.Itoa(row.Joins),
+			"firstJoined":    formatOptionalTime(row.FirstJoinedAt),
+			"lastLeft":       formatOptionalTime(row.LastLeftAt),
+			"minutesWatched": strconv.FormatInt(row.WatchedSeconds/60, 10),
+		}
+		if err := writer.WriteRow(export.Project(columns, record)); err != nil {
+			log.Printf("[Attendance] Export aborted: %v", err)
+			return
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("[Attendance] Failed to finish export: %v", err)
+	}
+}
+
+// MyAttendance handles GET /api/attendance/me?limit=, the caller's
+// attendance of the classes they joined, newest first.
+func (h *AttendanceHandler) MyAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultMyAttendanceLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxMyAttendanceLimit)
+	}
+
+	// A class rarely takes more than a few stints
+	stints, err := h.attendanceRepo.FindByUser(r.Context(), user.ID, int64(limit)*10)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch attendance", err)
+		return
+	}
+
+	var order []primitive.ObjectID
+	bySchedule := make(map[primitive.ObjectID][]models.Attendance)
+	for _, stint := range stints {
+		if _, ok := bySchedule[stint.ScheduleID]; !ok {
+			if len(order) == limit {
+				continue
+			}
+			order = append(order, stint.ScheduleID)
+		}
+		bySchedule[stint.ScheduleID] = append(bySchedule[stint.ScheduleID], stint)
+	}
+
+	now := time.Now()
+	classes := make([]models.AttendanceSummary, 0, len(order))
+	for _, id := range order {
+		schedule, err := h.scheduleRepo.FindByID(r.Context(), id.Hex())
+		if err != nil {
+			continue // Deleted class
+		}
+		if s, ok := attendance.Summarize(schedule, bySchedule[id], now)[user.ID]; ok {
+			s.Name, s.Email = user.Name, user.Email
+			classes = append(classes, *s)
+		}
+	}
+	sendJSON(w, map[string]interface{}{"classes": classes}, http.StatusOK)
+}
+
+// formatOptionalTime formats t as RFC 3339, or empty if nil.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
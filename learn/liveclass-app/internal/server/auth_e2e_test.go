@@ -0,0 +1,189 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/testsupport"
+)
+
+func TestRegisterApproveLogin(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	req := auth.RegisterRequest{
+		Email:    "student@example.com",
+		Password: "correct-horse",
+		Name:     "Student",
+		Role:     models.RoleStudent,
+	}
+	c := srv.Client()
+	user, err := c.Register(ctx, req)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if user.Status != models.StatusPending {
+		t.Fatalf("status after registering = %q, want %q", user.Status, models.StatusPending)
+	}
+
+	if _, err := c.Login(ctx, req.Email, req.Password); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("login pending approval: got %v, want 403", err)
+	}
+
+	if err := srv.Admin(t).SetUserStatus(ctx, user.ID, models.StatusApproved); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+
+	if _, err := c.Login(ctx, req.Email, "wrong-password"); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("login with wrong password: got %v, want 401", err)
+	}
+	if _, err := c.Login(ctx, req.Email, req.Password); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	me, err := c.Me(ctx)
+	if err != nil {
+		t.Fatalf("me: %v", err)
+	}
+	if me.ID != user.ID || me.Role != models.RoleStudent {
+		t.Fatalf("me = %s (%s), want %s (%s)", me.ID, me.Role, user.ID, models.RoleStudent)
+	}
+}
+
+func TestRequestsWithoutTokenAreRefused(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	c := srv.Client()
+	if _, err := c.Me(ctx); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("me without a token: got %v, want 401", err)
+	}
+
+	c.Token = "not-a-token"
+	if _, err := c.Me(ctx); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("me with a bad token: got %v, want 401", err)
+	}
+
+	// Login tokens in URLs are only accepted where browsers can't send headers
+	student, _ := srv.NewUser(t, models.RoleStudent)
+	err := srv.Client().Do(ctx, http.MethodGet, "/api/auth/me?token="+student.Token, nil, nil)
+	if testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("me with a login token in the query: got %v, want 401", err)
+	}
+}
+
+func TestStudentCannotUseAdminRoutes(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	student, user := srv.NewUser(t, models.RoleStudent)
+	if err := student.SetUserStatus(ctx, user.ID, models.StatusApproved); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("student setting a status: got %v, want 403", err)
+	}
+}
+
+func TestMetricsNeedScrapeTokenOrAdmin(t *testing.T) {
+	const scrapeToken = "e2e-scrape-token"
+	srv := testsupport.StartWith(t, deps, func(cfg *config.Config) { cfg.MetricsToken = scrapeToken })
+	ctx := testContext(t)
+
+	scraper := srv.Client()
+	if err := scraper.Do(ctx, http.MethodGet, "/metrics", nil, nil); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("metrics without a token: got %v, want 401", err)
+	}
+	student, _ := srv.NewUser(t, models.RoleStudent)
+	if err := student.Do(ctx, http.MethodGet, "/metrics", nil, nil); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("metrics as a student: got %v, want 403", err)
+	}
+
+	scraper.Token = scrapeToken
+	if err := scraper.Do(ctx, http.MethodGet, "/metrics", nil, nil); err != nil {
+		t.Fatalf("metrics with the scrape token: %v", err)
+	}
+	if err := srv.Admin(t).Do(ctx, http.MethodGet, "/metrics", nil, nil); err != nil {
+		t.Fatalf("metrics as an admin: %v", err)
+	}
+}
+
+func TestSuspendSignsOut(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	student, user := srv.NewUser(t, models.RoleStudent)
+	if _, err := student.Me(ctx); err != nil {
+		t.Fatalf("me before suspending: %v", err)
+	}
+
+	if err := srv.Admin(t).SetUserStatus(ctx, user.ID, models.StatusSuspended); err != nil {
+		t.Fatalf("suspend: %v", err)
+	}
+
+	if _, err := student.Me(ctx); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("me after suspending: got %v, want 401", err)
+	}
+	if _, err := student.Login(ctx, user.Email, "testsupport-user"); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("login after suspending: got %v, want 403", err)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	_, user := srv.NewUser(t, models.RoleStudent)
+	c := srv.Client()
+	login, err := c.Login(ctx, user.Email, "testsupport-user")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if login.RefreshToken == "" {
+		t.Fatal("login returned no refresh token")
+	}
+
+	if err := c.Do(ctx, http.MethodPost, "/api/auth/logout", nil, nil); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+
+	if _, err := c.Me(ctx); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("me after logout: got %v, want 401", err)
+	}
+	refresh := map[string]string{"refreshToken": login.RefreshToken}
+	if err := c.Do(ctx, http.MethodPost, "/api/auth/refresh", refresh, nil); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout: got %v, want 401", err)
+	}
+}
+
+func TestRefreshTokenReuseSignsOut(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	_, user := srv.NewUser(t, models.RoleStudent)
+	c := srv.Client()
+	login, err := c.Login(ctx, user.Email, "testsupport-user")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	var renewed auth.AuthResponse
+	refresh := map[string]string{"refreshToken": login.RefreshToken}
+	if err := c.Do(ctx, http.MethodPost, "/api/auth/refresh", refresh, &renewed); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if renewed.Token == "" || renewed.RefreshToken == login.RefreshToken {
+		t.Fatal("refresh didn't issue a new token pair")
+	}
+	c.Token = renewed.Token
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("me with the renewed token: %v", err)
+	}
+
+	if err := c.Do(ctx, http.MethodPost, "/api/auth/refresh", refresh, nil); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("reusing a refresh token: got %v, want 401", err)
+	}
+	if _, err := c.Me(ctx); testsupport.StatusOf(err) != http.StatusUnauthorized {
+		t.Fatalf("me after a refresh token was reused: got %v, want 401", err)
+	}
+}
@@ -1,23 +1,52 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/geoip"
+	"github.com/jinshatcp/brightline-academy/learn/internal/middleware"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/validate"
 )
 
 // AuthHandler handles authentication endpoints.
 type AuthHandler struct {
 	authService *auth.Service
+	userRepo    *repository.UserRepository
+	sessions    *SessionCloser
+	notifier    *notify.Notifier
+	locator     *geoip.Locator
+
+	// How long a deactivated account can still be reactivated
+	deactivationGrace time.Duration
 }
 
+// retentionNotice tells students what deactivating their account removes
+// and what is kept.
+const retentionNotice = "Until then you can sign in again to reactivate your account. " +
+	"After that your name, email, password, signed-in devices and notifications are deleted. " +
+	"Your batch memberships and class attendance are kept without your personal details, for the academy's records."
+
 // NewAuthHandler creates a new AuthHandler.
-func NewAuthHandler(authService *auth.Service) *AuthHandler {
-	return &AuthHandler{authService: authService}
+func NewAuthHandler(authService *auth.Service, userRepo *repository.UserRepository, sessions *SessionCloser, notifier *notify.Notifier, locator *geoip.Locator, deactivationGrace time.Duration) *AuthHandler {
+	return &AuthHandler{
+		authService:       authService,
+		userRepo:          userRepo,
+		sessions:          sessions,
+		notifier:          notifier,
+		locator:           locator,
+		deactivationGrace: deactivationGrace,
+	}
 }
 
 // Register handles user registration.
@@ -28,19 +57,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req auth.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate required fields
-	if req.Email == "" || req.Password == "" || req.Name == "" {
-		sendJSONError(w, "Email, password, and name are required", http.StatusBadRequest)
-		return
-	}
-
-	if len(req.Password) < 6 {
-		sendJSONError(w, "Password must be at least 6 characters", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -60,27 +77,45 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusCreated)
 }
 
-// Login handles user login.
+// Login handles user login. Accounts being deactivated are refused with
+// when they will be anonymized, so the client can offer to reactivate them.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	h.login(w, r, h.authService.Login)
+}
+
+// Reactivate signs in to an account being deactivated, cancelling its
+// deactivation (POST /api/auth/reactivate, with the login request).
+func (h *AuthHandler) Reactivate(w http.ResponseWriter, r *http.Request) {
+	h.login(w, r, h.authService.Reactivate)
+}
+
+func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request, login func(context.Context, auth.LoginRequest) (*auth.AuthResponse, error)) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req auth.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-
-	if req.Email == "" || req.Password == "" {
-		sendJSONError(w, "Email and password are required", http.StatusBadRequest)
-		return
+	req.UserAgent = r.UserAgent()
+	if ip := h.locator.ClientIP(r); ip.IsValid() {
+		req.IP = ip.String()
 	}
 
-	response, err := h.authService.Login(r.Context(), req)
+	response, err := login(r.Context(), req)
 	if err != nil {
 		switch {
+		case errors.Is(err, auth.ErrAccountDeactivating):
+			body := map[string]interface{}{
+				"error":         "Your account is scheduled for deactivation",
+				"canReactivate": true,
+			}
+			if user, err := h.userRepo.FindByEmail(r.Context(), req.Email); err == nil && user.DeactivateAt != nil {
+				body["deactivateAt"] = user.DeactivateAt
+			}
+			sendJSON(w, body, http.StatusForbidden)
 		case errors.Is(err, auth.ErrInvalidCredentials):
 			sendJSONError(w, "Invalid email or password", http.StatusUnauthorized)
 		case errors.Is(err, auth.ErrAccountPending):
@@ -95,67 +130,242 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(response.SignedOut) > 0 {
+		go h.signedOut(response.SignedOut)
+	}
+
 	sendJSON(w, response, http.StatusOK)
 }
 
-// Me returns the current user's profile.
-func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// signedOut tells the devices signed out by a login over the device limit,
+// closing their live connections, and notifies the account.
+func (h *AuthHandler) signedOut(sessions []models.Session) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, session := range sessions {
+		h.sessions.Close(ctx, session.ID.Hex(), "You were signed out because your account signed in on another device")
+	}
+
+	user, err := h.userRepo.FindByID(ctx, sessions[0].UserID.Hex())
+	if err != nil {
+		log.Printf("[Auth] Failed to load user %s to notify of signed-out devices: %v", sessions[0].UserID.Hex(), err)
+		return
+	}
+	names := make([]string, len(sessions))
+	for i, session := range sessions {
+		names[i] = session.Device
+	}
+	log.Printf("[Auth] Signed out %d device(s) of %s over the device limit", len(sessions), user.Email)
+	h.notifier.Notify(ctx, []models.User{*user}, notify.Message{
+		Category: models.NotificationSessionRevoked,
+		Title:    "Signed out on another device",
+		Body: fmt.Sprintf("Your account signed in on a new device, so %s was signed out. Your account can be signed in on a limited number of devices at once.",
+			strings.Join(names, ", ")),
+	})
+}
+
+// Refresh renews an access token (POST /api/auth/refresh) with the refresh
+// token given at login or the last refresh, returning a new pair. A refresh
+// token used twice signs its session out.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	token := extractToken(r)
-	if token == "" {
-		sendJSONError(w, "Authorization required", http.StatusUnauthorized)
+	var req struct {
+		RefreshToken string `json:"refreshToken" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
+	response, err := h.authService.Refresh(r.Context(), req.RefreshToken)
 	if err != nil {
-		sendJSONError(w, "Invalid or expired token", http.StatusUnauthorized)
+		switch {
+		case errors.Is(err, auth.ErrSessionRevoked):
+			sessionID, _, _ := strings.Cut(req.RefreshToken, ".")
+			log.Printf("[Auth] Refresh token of session %s reused, signing it out", sessionID)
+			h.sessions.Close(r.Context(), sessionID, "This device was signed out for your account's safety")
+			sendJSONError(w, "Session has been signed out", http.StatusUnauthorized)
+		case errors.Is(err, auth.ErrAccountSuspended):
+			sendJSONError(w, "Your account has been suspended", http.StatusForbidden)
+		case errors.Is(err, auth.ErrAccountDeactivating):
+			sendJSONError(w, "Your account is scheduled for deactivation", http.StatusForbidden)
+		case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, repository.ErrSessionNotFound), errors.Is(err, repository.ErrUserNotFound):
+			sendJSONError(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		default:
+			sendStoreError(w, "Failed to refresh token", err)
+		}
 		return
 	}
 
-	sendJSON(w, user.ToResponse(), http.StatusOK)
+	sendJSON(w, response, http.StatusOK)
 }
 
-// ChangePassword handles password change for authenticated users.
-func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+// Logout signs out the device making the request (POST /api/auth/logout),
+// closing its live connections. Its access and refresh tokens stop working.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	token := extractToken(r)
-	if token == "" {
-		sendJSONError(w, "Authorization required", http.StatusUnauthorized)
+	_, claims, err := h.authService.Authenticate(r.Context(), extractToken(r))
+	if err != nil {
+		sendJSONError(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.Logout(r.Context(), claims); err != nil {
+		sendStoreError(w, "Failed to log out", err)
+		return
+	}
+	h.sessions.Close(r.Context(), claims.SessionID, "You logged out")
+
+	sendJSON(w, map[string]string{"message": "Logged out"}, http.StatusOK)
+}
+
+// ListSessions returns the caller's signed-in devices
+// (GET /api/auth/sessions), marking the one making the request.
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	claims, err := h.authService.ValidateToken(token)
+	user, claims, err := h.authService.Authenticate(r.Context(), extractToken(r))
 	if err != nil {
 		sendJSONError(w, "Invalid or expired token", http.StatusUnauthorized)
 		return
 	}
 
+	sessions, err := h.authService.Sessions(r.Context(), user.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to load sessions", err)
+		return
+	}
+
+	type sessionResponse struct {
+		models.Session
+		Current bool `json:"current"`
+	}
+	response := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = sessionResponse{Session: session, Current: session.ID.Hex() == claims.SessionID}
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// SignOutSession signs out one of the caller's devices
+// (DELETE /api/auth/sessions/{id}), closing its live connections.
+func (h *AuthHandler) SignOutSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID := strings.TrimPrefix(r.URL.Path, "/api/auth/sessions/")
+	if err := h.authService.SignOutSession(r.Context(), user.ID, sessionID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			sendJSONError(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		sendJSONError(w, "Failed to sign out session", http.StatusInternalServerError)
+		return
+	}
+
+	h.sessions.Close(r.Context(), sessionID, "This device was signed out")
+
+	sendJSON(w, map[string]string{"message": "Session signed out"}, http.StatusOK)
+}
+
+// Me returns the current user's profile.
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	sendJSON(w, user.ToResponse(), http.StatusOK)
+}
+
+// Deactivate schedules the caller's account for deactivation
+// (DELETE /api/me), confirmed with their password, and signs out all their
+// devices. After the grace period the account is anonymized. Students only;
+// staff accounts are closed by an admin.
+func (h *AuthHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleStudent {
+		sendJSONError(w, "Only students can deactivate their own account; ask an admin", http.StatusForbidden)
+		return
+	}
+
 	var req struct {
-		CurrentPassword string `json:"currentPassword"`
-		NewPassword     string `json:"newPassword"`
+		Password string `json:"password" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+	deactivateAt, signedOut, err := h.authService.Deactivate(r.Context(), user, req.Password, h.deactivationGrace)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			sendJSONError(w, "Password is incorrect", http.StatusUnauthorized)
+			return
+		}
+		sendStoreError(w, "Failed to deactivate account", err)
+		return
+	}
+	log.Printf("[Auth] Account %s scheduled for deactivation at %s", user.ID.Hex(), deactivateAt.Format(time.RFC3339))
+
+	for _, session := range signedOut {
+		h.sessions.Close(r.Context(), session.ID.Hex(), "Your account is being deactivated")
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"message":      "Your account will be deactivated on " + deactivateAt.Format("2 January 2006") + ".",
+		"deactivateAt": deactivateAt,
+		"retention":    retentionNotice,
+	}, http.StatusOK)
+}
+
+// ChangePassword handles password change for authenticated users.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if req.CurrentPassword == "" || req.NewPassword == "" {
-		sendJSONError(w, "Current password and new password are required", http.StatusBadRequest)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
-	if len(req.NewPassword) < 6 {
-		sendJSONError(w, "New password must be at least 6 characters", http.StatusBadRequest)
+	var req struct {
+		CurrentPassword string `json:"currentPassword" validate:"required"`
+		NewPassword     string `json:"newPassword" validate:"required,min=6,max=72"`
+	}
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -164,7 +374,7 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.authService.ChangePassword(r.Context(), claims.UserID, req.CurrentPassword, req.NewPassword)
+	err := h.authService.ChangePassword(r.Context(), user.ID.Hex(), req.CurrentPassword, req.NewPassword)
 	if err != nil {
 		if err.Error() == "invalid email or password" {
 			sendJSONError(w, "Current password is incorrect", http.StatusUnauthorized)
@@ -177,24 +387,19 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, map[string]string{"message": "Password changed successfully"}, http.StatusOK)
 }
 
-// extractToken extracts the JWT token from the Authorization header or query parameter.
-// Query parameter is used for video streaming where browsers can't send custom headers.
+// extractToken extracts the JWT token from the Authorization header.
 func extractToken(r *http.Request) string {
-	// First try Authorization header
-	authHeader := r.Header.Get("Authorization")
-	if authHeader != "" {
-		parts := strings.Split(authHeader, " ")
-		if len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
-			return parts[1]
-		}
-	}
+	return middleware.Token(r)
+}
 
-	// Fall back to query parameter (for video streaming)
-	if token := r.URL.Query().Get("token"); token != "" {
-		return token
+// requestUser returns the user the auth middleware authenticated the
+// request as, writing a 401 if the route isn't behind it.
+func requestUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, ok := middleware.User(r.Context())
+	if !ok {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
 	}
-
-	return ""
+	return user, ok
 }
 
 // sendJSON sends a JSON response.
@@ -209,3 +414,40 @@ func sendJSONError(w http.ResponseWriter, message string, status int) {
 	sendJSON(w, map[string]string{"error": message}, status)
 }
 
+// sendStoreError sends the response for a failed repository call: a 503
+// with Retry-After when the database timed out, so clients try again, and a
+// 500 with message otherwise.
+func sendStoreError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, repository.ErrTimeout) {
+		w.Header().Set("Retry-After", "1")
+		sendJSONError(w, "The database is busy, please try again", http.StatusServiceUnavailable)
+		return
+	}
+	sendJSONError(w, message, http.StatusInternalServerError)
+}
+
+// decodeJSON decodes the request body into v and checks its validate tags.
+// On failure it sends a 400 response, listing each invalid field under
+// "fields", and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	return checkRequest(w, v)
+}
+
+// checkRequest checks the validate tags of a request struct that wasn't
+// decoded from JSON (e.g. multipart form values). On failure it sends the same
+// 400 response as decodeJSON and returns false.
+func checkRequest(w http.ResponseWriter, v interface{}) bool {
+	var fields validate.Errors
+	if err := validate.Struct(v); errors.As(err, &fields) {
+		sendJSON(w, map[string]interface{}{
+			"error":  fields.Error(),
+			"fields": fields,
+		}, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
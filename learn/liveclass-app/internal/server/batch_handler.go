@@ -1,72 +1,28 @@
 package server
 
 import (
-	"encoding/json"
 	"net/http"
 	"strings"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // BatchHandler handles batch-related endpoints.
 type BatchHandler struct {
-	authService *auth.Service
-	batchRepo   *repository.BatchRepository
-	userRepo    *repository.UserRepository
+	batchRepo *repository.BatchRepository
+	userRepo  *repository.UserRepository
+	billing   *BillingHandler
 }
 
 // NewBatchHandler creates a new BatchHandler.
-func NewBatchHandler(authService *auth.Service, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository) *BatchHandler {
+func NewBatchHandler(batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, billing *BillingHandler) *BatchHandler {
 	return &BatchHandler{
-		authService: authService,
-		batchRepo:   batchRepo,
-		userRepo:    userRepo,
-	}
-}
-
-// requireAuth middleware ensures the request is authenticated.
-func (h *BatchHandler) requireAuth(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		token := extractToken(r)
-		if token == "" {
-			sendJSONError(w, "Authorization required", http.StatusUnauthorized)
-			return
-		}
-
-		_, err := h.authService.ValidateToken(token)
-		if err != nil {
-			sendJSONError(w, "Invalid or expired token", http.StatusUnauthorized)
-			return
-		}
-
-		next(w, r)
-	}
-}
-
-// requireAdminOrPresenter middleware ensures the request is from admin or presenter.
-func (h *BatchHandler) requireAdminOrPresenter(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		token := extractToken(r)
-		if token == "" {
-			sendJSONError(w, "Authorization required", http.StatusUnauthorized)
-			return
-		}
-
-		user, err := h.authService.GetUserFromToken(r.Context(), token)
-		if err != nil {
-			sendJSONError(w, "Invalid or expired token", http.StatusUnauthorized)
-			return
-		}
-
-		if user.Role != models.RoleAdmin && user.Role != models.RolePresenter {
-			sendJSONError(w, "Admin or presenter access required", http.StatusForbidden)
-			return
-		}
-
-		next(w, r)
+		batchRepo: batchRepo,
+		userRepo:  userRepo,
+		billing:   billing,
 	}
 }
 
@@ -77,8 +33,10 @@ func (h *BatchHandler) ListBatches(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
-	user, _ := h.authService.GetUserFromToken(r.Context(), token)
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 
 	var batches []models.Batch
 	var err error
@@ -92,22 +50,34 @@ func (h *BatchHandler) ListBatches(w http.ResponseWriter, r *http.Request) {
 		batches, err = h.batchRepo.FindByStudent(r.Context(), user.ID.Hex())
 	}
 
+	// Teaching assistants also see the batches they assist. The lists come
+	// from the repository cache, so copy before appending.
+	if err == nil && user.Role != models.RoleAdmin {
+		assisted, assistErr := h.batchRepo.FindByAssistant(r.Context(), user.ID.Hex())
+		batches = append([]models.Batch(nil), batches...)
+		for _, b := range assisted {
+			if !containsBatch(batches, b.ID) {
+				batches = append(batches, b)
+			}
+		}
+		err = assistErr
+	}
+
 	if err != nil {
-		sendJSONError(w, "Failed to fetch batches", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to fetch batches", err)
 		return
 	}
 
 	// Enrich with presenter names
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
 	response := make([]models.BatchResponse, len(batches))
 	for i, b := range batches {
 		resp := b.ToResponse()
-		if presenter, err := h.userRepo.FindByID(r.Context(), b.PresenterID.Hex()); err == nil {
-			resp.PresenterName = presenter.Name
-		}
+		resp.PresenterName = names.User(b.PresenterID, b.PresenterName)
 		response[i] = resp
 	}
 
-	sendJSON(w, response, http.StatusOK)
+	sendJSONFields(w, r, response, http.StatusOK)
 }
 
 // CreateBatch creates a new batch.
@@ -118,26 +88,14 @@ func (h *BatchHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		PresenterID string `json:"presenterId"`
+		Name        string `json:"name" validate:"required,max=100"`
+		Description string `json:"description" validate:"max=2000"`
+		PresenterID string `json:"presenterId" validate:"required,objectid"`
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.Name == "" || req.PresenterID == "" {
-		sendJSONError(w, "Name and presenter ID are required", http.StatusBadRequest)
-		return
-	}
-
-	presenterObjID, err := primitive.ObjectIDFromHex(req.PresenterID)
-	if err != nil {
-		sendJSONError(w, "Invalid presenter ID", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
+	presenterObjID, _ := primitive.ObjectIDFromHex(req.PresenterID)
 
 	// Verify presenter exists and is a presenter
 	presenter, err := h.userRepo.FindByID(r.Context(), req.PresenterID)
@@ -146,25 +104,26 @@ func (h *BatchHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
-	claims, _ := h.authService.ValidateToken(token)
-	createdByID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
 
 	batch := &models.Batch{
 		Name:        req.Name,
-		Description: req.Description,
+		Description: richtext.Rich.Sanitize(req.Description),
 		PresenterID: presenterObjID,
-		CreatedBy:   createdByID,
+		CreatedBy:   user.ID,
+
+		PresenterName: presenter.Name,
 	}
 
 	if err := h.batchRepo.Create(r.Context(), batch); err != nil {
-		sendJSONError(w, "Failed to create batch", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to create batch", err)
 		return
 	}
 
-	resp := batch.ToResponse()
-	resp.PresenterName = presenter.Name
-	sendJSON(w, resp, http.StatusCreated)
+	sendJSON(w, batch.ToResponse(), http.StatusCreated)
 }
 
 // GetBatch returns a single batch with details.
@@ -228,16 +187,9 @@ func (h *BatchHandler) AddStudentsToBatch(w http.ResponseWriter, r *http.Request
 	batchID := parts[0]
 
 	var req struct {
-		StudentIDs []string `json:"studentIds"`
+		StudentIDs []string `json:"studentIds" validate:"required,max=500,objectid"`
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if len(req.StudentIDs) == 0 {
-		sendJSONError(w, "At least one student ID required", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -250,8 +202,21 @@ func (h *BatchHandler) AddStudentsToBatch(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	batch, err := h.batchRepo.FindByID(r.Context(), batchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+	if err := h.billing.CheckSeats(r.Context(), batch, req.StudentIDs); isBillingLimit(err) {
+		sendJSONError(w, err.Error(), http.StatusPaymentRequired)
+		return
+	} else if err != nil {
+		sendStoreError(w, "Failed to check the plan's seats", err)
+		return
+	}
+
 	if err := h.batchRepo.AddStudents(r.Context(), batchID, req.StudentIDs); err != nil {
-		sendJSONError(w, "Failed to add students", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to add students", err)
 		return
 	}
 
@@ -276,7 +241,7 @@ func (h *BatchHandler) RemoveStudentFromBatch(w http.ResponseWriter, r *http.Req
 	studentID := parts[2]
 
 	if err := h.batchRepo.RemoveStudent(r.Context(), batchID, studentID); err != nil {
-		sendJSONError(w, "Failed to remove student", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to remove student", err)
 		return
 	}
 
@@ -295,7 +260,7 @@ func (h *BatchHandler) DeleteBatch(w http.ResponseWriter, r *http.Request) {
 	batchID := strings.TrimSuffix(path, "/")
 
 	if err := h.batchRepo.Delete(r.Context(), batchID); err != nil {
-		sendJSONError(w, "Failed to delete batch", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to delete batch", err)
 		return
 	}
 
@@ -314,7 +279,7 @@ func (h *BatchHandler) GetAvailableStudents(w http.ResponseWriter, r *http.Reque
 	approvedStatus := models.StatusApproved
 	students, err := h.userRepo.FindAll(r.Context(), &approvedStatus, &studentRole)
 	if err != nil {
-		sendJSONError(w, "Failed to fetch students", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to fetch students", err)
 		return
 	}
 
@@ -326,3 +291,12 @@ func (h *BatchHandler) GetAvailableStudents(w http.ResponseWriter, r *http.Reque
 	sendJSON(w, response, http.StatusOK)
 }
 
+// containsBatch reports whether a batch with the given ID is in the list.
+func containsBatch(batches []models.Batch, id primitive.ObjectID) bool {
+	for _, b := range batches {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
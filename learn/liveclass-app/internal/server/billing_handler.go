@@ -0,0 +1,501 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/webhooks"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Billing errors returned by BillingHandler checks.
+var (
+	errNoSubscription   = errors.New("there's no active subscription for this batch")
+	errNoSeats          = errors.New("the plan has no seats left")
+	errFeatureNotInPlan = errors.New("this feature isn't included in the plan")
+)
+
+// isBillingLimit reports whether err is a plan's limit rather than a failed
+// lookup.
+func isBillingLimit(err error) bool {
+	return errors.Is(err, errNoSubscription) || errors.Is(err, errNoSeats) || errors.Is(err, errFeatureNotInPlan)
+}
+
+// BillingOptions configures billing enforcement.
+type BillingOptions struct {
+	Enabled bool          // Enforce seat limits and plan features; without it everything is allowed
+	Org     string        // Organization this instance bills
+	Grace   time.Duration // How long a paid period is honored past its end
+}
+
+// BillingHandler lets admins manage plans and subscriptions, applies
+// subscription changes reported by payment providers through the webhook
+// inbox, and enforces seat limits and plan features.
+type BillingHandler struct {
+	batchRepo   *repository.BatchRepository
+	billingRepo *repository.BillingRepository
+	options     BillingOptions
+}
+
+// NewBillingHandler creates a new BillingHandler and registers its webhook
+// actions with the dispatcher.
+func NewBillingHandler(batchRepo *repository.BatchRepository, billingRepo *repository.BillingRepository, dispatcher *webhooks.Dispatcher, options BillingOptions) *BillingHandler {
+	h := &BillingHandler{
+		batchRepo:   batchRepo,
+		billingRepo: billingRepo,
+		options:     options,
+	}
+
+	dispatcher.Handle("subscription", h.applySubscription)
+	dispatcher.Handle("cancel-subscription", h.cancelSubscription)
+
+	return h
+}
+
+// Overview returns each subscription's entitlement next to its usage.
+// GET /api/admin/billing
+func (h *BillingHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	subscriptions, err := h.billingRepo.FindSubscriptions(r.Context(), h.options.Org)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch subscriptions", err)
+		return
+	}
+	plans, err := h.billingRepo.FindPlans(r.Context())
+	if err != nil {
+		sendStoreError(w, "Failed to fetch plans", err)
+		return
+	}
+	plansByID := make(map[string]*models.Plan, len(plans))
+	for i := range plans {
+		plansByID[plans[i].ID] = &plans[i]
+	}
+
+	entitlements := make([]models.Entitlement, 0, len(subscriptions))
+	for i := range subscriptions {
+		sub := &subscriptions[i]
+		entitlement := h.entitlement(sub, plansByID[sub.PlanID])
+
+		holders, err := h.seatHolders(r.Context(), sub, subscriptions)
+		if err != nil {
+			log.Printf("[Billing] Failed to count seats of subscription %s: %v", sub.ID.Hex(), err)
+		}
+		entitlement.SeatsUsed = len(holders)
+		if !sub.BatchID.IsZero() {
+			if batch, err := h.batchRepo.FindByID(r.Context(), sub.BatchID.Hex()); err == nil {
+				entitlement.BatchName = batch.Name
+			}
+		}
+		entitlements = append(entitlements, entitlement)
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"enabled":       h.options.Enabled,
+		"org":           h.options.Org,
+		"subscriptions": entitlements,
+		"plans":         plans,
+	}, http.StatusOK)
+}
+
+// Plans lists plans (GET /api/admin/billing/plans) or creates or replaces
+// one (POST /api/admin/billing/plans).
+func (h *BillingHandler) Plans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		plans, err := h.billingRepo.FindPlans(r.Context())
+		if err != nil {
+			sendStoreError(w, "Failed to fetch plans", err)
+			return
+		}
+		sendJSON(w, plans, http.StatusOK)
+
+	case http.MethodPost:
+		var req struct {
+			ID        string   `json:"id" validate:"required,max=50"`
+			Name      string   `json:"name" validate:"required,max=100"`
+			Seats     int      `json:"seats" validate:"min=0,max=1000000"`
+			TrialDays int      `json:"trialDays" validate:"min=0,max=365"`
+			Features  []string `json:"features" validate:"max=10"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		features := make([]models.PlanFeature, 0, len(req.Features))
+		for _, f := range req.Features {
+			feature := models.PlanFeature(f)
+			if feature != models.FeatureRecording && feature != models.FeatureHLS {
+				sendJSONError(w, "Invalid feature: "+f+". Must be: recording or hls", http.StatusBadRequest)
+				return
+			}
+			features = append(features, feature)
+		}
+
+		plan := &models.Plan{
+			ID:        strings.ToLower(strings.TrimSpace(req.ID)),
+			Name:      req.Name,
+			Seats:     req.Seats,
+			TrialDays: req.TrialDays,
+			Features:  features,
+		}
+		if err := h.billingRepo.SavePlan(r.Context(), plan); err != nil {
+			sendStoreError(w, "Failed to save plan", err)
+			return
+		}
+		sendJSON(w, plan, http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DeletePlan deletes a plan no subscription is on.
+// DELETE /api/admin/billing/plans/{id}
+func (h *BillingHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	planID := strings.TrimPrefix(r.URL.Path, "/api/admin/billing/plans/")
+	err := h.billingRepo.DeletePlan(r.Context(), planID)
+	switch {
+	case errors.Is(err, repository.ErrPlanNotFound):
+		sendJSONError(w, "Plan not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrPlanInUse):
+		sendJSONError(w, "Move the subscriptions on this plan to another plan first", http.StatusConflict)
+	case err != nil:
+		sendJSONError(w, "Failed to delete plan", http.StatusInternalServerError)
+	default:
+		sendJSON(w, map[string]string{"message": "Plan deleted"}, http.StatusOK)
+	}
+}
+
+// Subscribe puts the organization, or one batch, on a plan. New
+// subscriptions to plans with a trial start out trialing.
+// POST /api/admin/billing/subscriptions
+func (h *BillingHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		BatchID          string `json:"batchId" validate:"objectid"`
+		PlanID           string `json:"planId" validate:"required"`
+		Status           string `json:"status" validate:"oneof=trialing active past_due cancelled"`
+		Seats            int    `json:"seats" validate:"min=0,max=1000000"`
+		CurrentPeriodEnd string `json:"currentPeriodEnd" validate:"rfc3339"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	subscription, err := h.newSubscription(r.Context(), req.BatchID, req.PlanID, models.SubscriptionStatus(req.Status), req.Seats)
+	if err != nil {
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CurrentPeriodEnd != "" {
+		end, _ := time.Parse(time.RFC3339, req.CurrentPeriodEnd)
+		subscription.CurrentPeriodEnd = &end
+	}
+
+	stored, err := h.billingRepo.SaveSubscription(r.Context(), subscription)
+	if err != nil {
+		sendStoreError(w, "Failed to save subscription", err)
+		return
+	}
+
+	log.Printf("[Billing] %s subscribed to %s (%s)", h.scopeName(stored), stored.PlanID, stored.Status)
+
+	sendJSON(w, stored, http.StatusOK)
+}
+
+// DeleteSubscription removes a subscription. A batch without one falls
+// back to the organization's.
+// DELETE /api/admin/billing/subscriptions/{id}
+func (h *BillingHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/billing/subscriptions/")
+	err := h.billingRepo.DeleteSubscription(r.Context(), id)
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		sendJSONError(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendStoreError(w, "Failed to delete subscription", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Subscription deleted"}, http.StatusOK)
+}
+
+// CheckSeats returns an error unless the batch's plan has seats for the
+// students being added. Students already holding a seat don't need another.
+func (h *BillingHandler) CheckSeats(ctx context.Context, batch *models.Batch, studentIDs []string) error {
+	if !h.options.Enabled {
+		return nil
+	}
+
+	sub, plan, err := h.resolve(ctx, batch.ID)
+	if err != nil {
+		return err
+	}
+	seats := sub.Seats
+	if seats == 0 {
+		seats = plan.Seats
+	}
+	if seats == 0 {
+		return nil // Unlimited
+	}
+
+	var others []models.Subscription
+	if sub.BatchID.IsZero() {
+		if others, err = h.billingRepo.FindSubscriptions(ctx, h.options.Org); err != nil {
+			return err
+		}
+	}
+	holders, err := h.seatHolders(ctx, sub, others)
+	if err != nil {
+		return err
+	}
+	held := make(map[string]bool, len(holders))
+	for _, id := range holders {
+		held[id.Hex()] = true
+	}
+
+	needed := 0
+	for _, id := range studentIDs {
+		if !held[id] {
+			held[id] = true
+			needed++
+		}
+	}
+	if needed > 0 && len(holders)+needed > seats {
+		return fmt.Errorf("%w (%d of %d used, %d more needed)", errNoSeats, len(holders), seats, needed)
+	}
+	return nil
+}
+
+// Allows returns an error unless the plan of a batch includes a feature.
+func (h *BillingHandler) Allows(ctx context.Context, batchID primitive.ObjectID, feature models.PlanFeature) error {
+	if !h.options.Enabled {
+		return nil
+	}
+
+	_, plan, err := h.resolve(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	if !plan.HasFeature(feature) {
+		return errFeatureNotInPlan
+	}
+	return nil
+}
+
+// resolve returns the active subscription covering a batch, its own or
+// else the organization's, and its plan.
+func (h *BillingHandler) resolve(ctx context.Context, batchID primitive.ObjectID) (*models.Subscription, *models.Plan, error) {
+	sub, err := h.billingRepo.FindSubscription(ctx, h.options.Org, batchID)
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		sub, err = h.billingRepo.FindSubscription(ctx, h.options.Org, primitive.NilObjectID)
+	}
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		return nil, nil, errNoSubscription
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !sub.Active(time.Now(), h.options.Grace) {
+		return nil, nil, errNoSubscription
+	}
+
+	plan, err := h.billingRepo.FindPlan(ctx, sub.PlanID)
+	if errors.Is(err, repository.ErrPlanNotFound) {
+		return nil, nil, errNoSubscription
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return sub, plan, nil
+}
+
+// seatHolders returns the students holding a seat of a subscription: the
+// batch's students, or for the organization's subscription the students of
+// every batch without a subscription of its own among others.
+func (h *BillingHandler) seatHolders(ctx context.Context, sub *models.Subscription, others []models.Subscription) ([]primitive.ObjectID, error) {
+	if !sub.BatchID.IsZero() {
+		batch, err := h.batchRepo.FindByID(ctx, sub.BatchID.Hex())
+		if errors.Is(err, repository.ErrBatchNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return batch.StudentIDs, nil
+	}
+
+	var exclude []primitive.ObjectID
+	for _, other := range others {
+		if !other.BatchID.IsZero() {
+			exclude = append(exclude, other.BatchID)
+		}
+	}
+	return h.batchRepo.DistinctStudents(ctx, exclude)
+}
+
+// entitlement describes what a subscription grants, without its usage.
+func (h *BillingHandler) entitlement(sub *models.Subscription, plan *models.Plan) models.Entitlement {
+	entitlement := models.Entitlement{
+		SubscriptionID:   sub.ID.Hex(),
+		Org:              sub.Org,
+		PlanID:           sub.PlanID,
+		Status:           sub.Status,
+		Active:           plan != nil && sub.Active(time.Now(), h.options.Grace),
+		Seats:            sub.Seats,
+		Features:         []models.PlanFeature{},
+		TrialEndsAt:      sub.TrialEndsAt,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+	}
+	if !sub.BatchID.IsZero() {
+		entitlement.BatchID = sub.BatchID.Hex()
+	}
+	if plan != nil {
+		entitlement.PlanName = plan.Name
+		entitlement.Features = plan.Features
+		if entitlement.Seats == 0 {
+			entitlement.Seats = plan.Seats
+		}
+	}
+	return entitlement
+}
+
+// newSubscription builds a subscription to a plan for the organization, or
+// for a batch. Without a status it trials the plan if it has a trial.
+func (h *BillingHandler) newSubscription(ctx context.Context, batchID, planID string, status models.SubscriptionStatus, seats int) (*models.Subscription, error) {
+	plan, err := h.billingRepo.FindPlan(ctx, planID)
+	if err != nil {
+		return nil, errors.New("plan not found")
+	}
+
+	subscription := &models.Subscription{
+		Org:    h.options.Org,
+		PlanID: plan.ID,
+		Status: status,
+		Seats:  seats,
+	}
+	if batchID != "" {
+		batch, err := h.batchRepo.FindByID(ctx, batchID)
+		if err != nil {
+			return nil, errors.New("batch not found")
+		}
+		subscription.BatchID = batch.ID
+	}
+
+	if subscription.Status == "" {
+		subscription.Status = models.SubscriptionActive
+		if plan.TrialDays > 0 {
+			trialEnd := time.Now().AddDate(0, 0, plan.TrialDays)
+			subscription.Status = models.SubscriptionTrialing
+			subscription.TrialEndsAt = &trialEnd
+		}
+	}
+	return subscription, nil
+}
+
+// scopeName names what a subscription covers, for logs.
+func (h *BillingHandler) scopeName(sub *models.Subscription) string {
+	if sub.BatchID.IsZero() {
+		return "Organization " + sub.Org
+	}
+	return "Batch " + sub.BatchID.Hex()
+}
+
+// applySubscription creates or updates a subscription from a payment
+// provider's event. The provider's subscription ID links later events to it.
+func (h *BillingHandler) applySubscription(ctx context.Context, event *webhooks.Event) (map[string]interface{}, error) {
+	var data struct {
+		SubscriptionID   string `json:"subscriptionId" validate:"required,max=200"`
+		BatchID          string `json:"batchId" validate:"objectid"`
+		PlanID           string `json:"planId" validate:"required"`
+		Status           string `json:"status" validate:"required,oneof=trialing active past_due cancelled"`
+		Seats            int    `json:"seats" validate:"min=0,max=1000000"`
+		TrialEndsAt      string `json:"trialEndsAt" validate:"rfc3339"`
+		CurrentPeriodEnd string `json:"currentPeriodEnd" validate:"rfc3339"`
+	}
+	if err := decodeEventData(event, &data); err != nil {
+		return nil, err
+	}
+
+	subscription, err := h.newSubscription(ctx, data.BatchID, data.PlanID, models.SubscriptionStatus(data.Status), data.Seats)
+	if err != nil {
+		return nil, webhooks.Reject("%v", err)
+	}
+	subscription.Provider = event.Source
+	subscription.ExternalID = data.SubscriptionID
+	if data.TrialEndsAt != "" {
+		end, _ := time.Parse(time.RFC3339, data.TrialEndsAt)
+		subscription.TrialEndsAt = &end
+	}
+	if data.CurrentPeriodEnd != "" {
+		end, _ := time.Parse(time.RFC3339, data.CurrentPeriodEnd)
+		subscription.CurrentPeriodEnd = &end
+	}
+
+	stored, err := h.billingRepo.SaveSubscription(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Billing] %s reported %s on %s (%s)", event.Source, h.scopeName(stored), stored.PlanID, stored.Status)
+
+	return map[string]interface{}{
+		"subscriptionId": stored.ID.Hex(),
+		"status":         stored.Status,
+	}, nil
+}
+
+// cancelSubscription cancels the subscription a payment provider's event
+// names.
+func (h *BillingHandler) cancelSubscription(ctx context.Context, event *webhooks.Event) (map[string]interface{}, error) {
+	var data struct {
+		SubscriptionID string `json:"subscriptionId" validate:"required,max=200"`
+	}
+	if err := decodeEventData(event, &data); err != nil {
+		return nil, err
+	}
+
+	sub, err := h.billingRepo.FindSubscriptionByExternalID(ctx, event.Source, data.SubscriptionID)
+	if errors.Is(err, repository.ErrSubscriptionNotFound) {
+		return nil, webhooks.Reject("subscription not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := h.billingRepo.UpdateSubscriptionStatus(ctx, sub.ID, models.SubscriptionCancelled); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Billing] %s cancelled %s", event.Source, h.scopeName(sub))
+
+	return map[string]interface{}{
+		"subscriptionId": sub.ID.Hex(),
+		"status":         models.SubscriptionCancelled,
+	}, nil
+}
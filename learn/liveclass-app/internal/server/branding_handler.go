@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+const (
+	maxLogoSize = 1 << 20 // 1MB
+	brandingDir = "branding"
+)
+
+// logoTypes maps accepted logo types, as sniffed from the content, to file
+// extensions. SVG is not accepted as it can carry scripts.
+var logoTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// BrandingHandler serves and updates the portal branding of this instance's
+// organization, so white-label deployments can be customized without
+// rebuilding the frontend.
+type BrandingHandler struct {
+	authService  *auth.Service
+	brandingRepo *repository.BrandingRepository
+	defaults     models.Branding // Used until an admin saves branding; Org selects the document
+	storagePath  string
+}
+
+// NewBrandingHandler creates a new BrandingHandler. defaults.Org is the
+// organization whose branding is served.
+func NewBrandingHandler(authService *auth.Service, brandingRepo *repository.BrandingRepository, defaults models.Branding, storagePath string) *BrandingHandler {
+	if err := os.MkdirAll(filepath.Join(storagePath, brandingDir), 0755); err != nil {
+		log.Printf("Warning: Could not create branding directory: %v", err)
+	}
+
+	return &BrandingHandler{
+		authService:  authService,
+		brandingRepo: brandingRepo,
+		defaults:     defaults,
+		storagePath:  storagePath,
+	}
+}
+
+// Current returns the branding in effect, falling back to the defaults for
+// anything not set.
+func (h *BrandingHandler) Current(ctx context.Context) models.Branding {
+	branding, err := h.brandingRepo.Find(ctx, h.defaults.Org)
+	if err != nil {
+		if !errors.Is(err, repository.ErrBrandingNotFound) {
+			log.Printf("[Branding] Failed to load branding: %v", err)
+		}
+		return h.defaults
+	}
+
+	if branding.PortalName == "" {
+		branding.PortalName = h.defaults.PortalName
+	}
+	if branding.LogoPath != "" {
+		branding.LogoURL = fmt.Sprintf("/api/branding/logo?v=%d", branding.UpdatedAt.Unix())
+	}
+	return *branding
+}
+
+// EmailBranding returns the portal name and footer for outgoing emails.
+func (h *BrandingHandler) EmailBranding(ctx context.Context) (string, string) {
+	branding := h.Current(ctx)
+	return branding.PortalName, branding.EmailFooter
+}
+
+// Get returns the branding (GET /api/branding). It is public, as the SPA
+// needs it before sign-in.
+func (h *BrandingHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	sendJSON(w, h.Current(r.Context()), http.StatusOK)
+}
+
+// Update changes the portal name, colors and email footer
+// (PUT /api/admin/branding). Access: Admin.
+func (h *BrandingHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PortalName   string `json:"portalName" validate:"required,max=80"`
+		PrimaryColor string `json:"primaryColor" validate:"hexcolor"`
+		AccentColor  string `json:"accentColor" validate:"hexcolor"`
+		EmailFooter  string `json:"emailFooter" validate:"max=1000"`
+	}
+	if !decodeJSON(w, r, &req) || !checkRequest(w, &req) {
+		return
+	}
+
+	branding, ok := h.stored(w, r)
+	if !ok {
+		return
+	}
+	branding.PortalName = req.PortalName
+	branding.PrimaryColor = req.PrimaryColor
+	branding.AccentColor = req.AccentColor
+	branding.EmailFooter = req.EmailFooter
+	branding.UpdatedByName = user.Name
+
+	if err := h.brandingRepo.Save(r.Context(), branding); err != nil {
+		sendStoreError(w, "Failed to save branding", err)
+		return
+	}
+	log.Printf("[Branding] Updated by %s", user.Name)
+
+	sendJSON(w, h.Current(r.Context()), http.StatusOK)
+}
+
+// UploadLogo replaces the logo (POST /api/admin/branding/logo, multipart
+// field "logo"). Access: Admin.
+func (h *BrandingHandler) UploadLogo(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLogoSize+1<<10)
+	if err := r.ParseMultipartForm(maxLogoSize); err != nil {
+		sendJSONError(w, "Logo too large (max 1MB) or invalid form", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("logo")
+	if err != nil {
+		sendJSONError(w, "No logo uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Trust the content, not the declared type
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	mimeType := http.DetectContentType(sniff[:n])
+	ext, allowed := logoTypes[mimeType]
+	if !allowed {
+		sendJSONError(w, "Logo must be PNG, JPEG, WebP or GIF", http.StatusBadRequest)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		sendJSONError(w, "Failed to read logo", http.StatusBadRequest)
+		return
+	}
+
+	branding, ok := h.stored(w, r)
+	if !ok {
+		return
+	}
+	previous := branding.LogoPath
+
+	filePath := filepath.Join(h.storagePath, brandingDir, branding.Org+"_"+time.Now().Format("20060102_150405")+ext)
+	dst, err := os.Create(filePath)
+	if err != nil {
+		log.Printf("[Branding] Failed to create file: %v", err)
+		sendStoreError(w, "Failed to save logo", err)
+		return
+	}
+	_, err = io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("[Branding] Failed to save logo: %v", err)
+		os.Remove(filePath)
+		sendJSONError(w, "Failed to save logo", http.StatusInternalServerError)
+		return
+	}
+
+	branding.LogoPath = filePath
+	branding.LogoMimeType = mimeType
+	branding.UpdatedByName = user.Name
+	if err := h.brandingRepo.Save(r.Context(), branding); err != nil {
+		os.Remove(filePath)
+		sendJSONError(w, "Failed to save branding", http.StatusInternalServerError)
+		return
+	}
+	if previous != "" && previous != filePath {
+		os.Remove(previous)
+	}
+	log.Printf("[Branding] Logo replaced by %s", user.Name)
+
+	sendJSON(w, h.Current(r.Context()), http.StatusOK)
+}
+
+// DeleteLogo removes the logo (DELETE /api/admin/branding/logo). Access: Admin.
+func (h *BrandingHandler) DeleteLogo(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	branding, ok := h.stored(w, r)
+	if !ok {
+		return
+	}
+	if branding.LogoPath == "" {
+		sendJSONError(w, "No logo set", http.StatusNotFound)
+		return
+	}
+	previous := branding.LogoPath
+
+	branding.LogoPath = ""
+	branding.LogoMimeType = ""
+	branding.UpdatedByName = user.Name
+	if err := h.brandingRepo.Save(r.Context(), branding); err != nil {
+		sendStoreError(w, "Failed to save branding", err)
+		return
+	}
+	os.Remove(previous)
+	log.Printf("[Branding] Logo removed by %s", user.Name)
+
+	sendJSON(w, h.Current(r.Context()), http.StatusOK)
+}
+
+// Logo serves the logo (GET /api/branding/logo). It is public; the URL from
+// Get changes whenever the branding does, so it can be cached for long.
+func (h *BrandingHandler) Logo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	branding, err := h.brandingRepo.Find(r.Context(), h.defaults.Org)
+	if err != nil || branding.LogoPath == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", branding.LogoMimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFile(w, r, branding.LogoPath)
+}
+
+// stored returns the saved branding to modify, or the defaults when none is
+// saved yet. It sends an error response and returns false when the branding
+// can't be loaded.
+func (h *BrandingHandler) stored(w http.ResponseWriter, r *http.Request) (*models.Branding, bool) {
+	branding, err := h.brandingRepo.Find(r.Context(), h.defaults.Org)
+	if errors.Is(err, repository.ErrBrandingNotFound) {
+		defaults := h.defaults
+		return &defaults, true
+	}
+	if err != nil {
+		log.Printf("[Branding] Failed to load branding: %v", err)
+		sendStoreError(w, "Failed to load branding", err)
+		return nil, false
+	}
+	return branding, true
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/catchup"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// catchUpHistoryLimit bounds the summaries returned by the history endpoint.
+const catchUpHistoryLimit = 12
+
+// CatchUpHandler handles students' weekly catch-up summaries.
+type CatchUpHandler struct {
+	authService *auth.Service
+	userRepo    *repository.UserRepository
+	catchUpRepo *repository.CatchUpRepository
+	builder     *catchup.Builder
+}
+
+// NewCatchUpHandler creates a new CatchUpHandler.
+func NewCatchUpHandler(authService *auth.Service, userRepo *repository.UserRepository, catchUpRepo *repository.CatchUpRepository, builder *catchup.Builder) *CatchUpHandler {
+	return &CatchUpHandler{
+		authService: authService,
+		userRepo:    userRepo,
+		catchUpRepo: catchUpRepo,
+		builder:     builder,
+	}
+}
+
+// Summary returns the student's catch-up summary of the last seven days, or
+// of the week (Monday to Monday, UTC) containing ?week=2024-01-15
+// (GET /api/catch-up).
+func (h *CatchUpHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.student(w, r)
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -7)
+	if week := r.URL.Query().Get("week"); week != "" {
+		day, err := time.Parse("2006-01-02", week)
+		if err != nil {
+			sendJSONError(w, "week must be a date like 2024-01-15", http.StatusBadRequest)
+			return
+		}
+		start = models.WeekStart(day)
+		end = start.AddDate(0, 0, 7)
+	}
+
+	summary, err := h.builder.Build(r.Context(), user, start, end)
+	if err != nil {
+		sendStoreError(w, "Failed to build summary", err)
+		return
+	}
+	sendJSON(w, summary, http.StatusOK)
+}
+
+// History returns the summaries sent to the student, newest first
+// (GET /api/catch-up/history).
+func (h *CatchUpHandler) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.student(w, r)
+	if !ok {
+		return
+	}
+
+	summaries, err := h.catchUpRepo.FindSent(r.Context(), user.ID, catchUpHistoryLimit)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch summaries", err)
+		return
+	}
+	sendJSON(w, summaries, http.StatusOK)
+}
+
+// Settings returns (GET) or replaces (PUT {"email": true}) whether the
+// student's weekly summary is also emailed (/api/catch-up/settings).
+func (h *CatchUpHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.student(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req struct {
+			Email bool `json:"email"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		updated := *user
+		updated.CatchUpEmail = req.Email
+		if err := h.userRepo.Update(r.Context(), &updated); err != nil {
+			sendStoreError(w, "Failed to save settings", err)
+			return
+		}
+		user = &updated
+	}
+
+	sendJSON(w, map[string]bool{"email": user.CatchUpEmail}, http.StatusOK)
+}
+
+// student authenticates the request and checks the caller is a student. It
+// writes the error response on failure.
+func (h *CatchUpHandler) student(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if user.Role != models.RoleStudent {
+		sendJSONError(w, "Catch-up summaries are only available to students", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Chat history page sizes
+const (
+	defaultChatHistoryLimit = 100
+	maxChatHistoryLimit     = 500
+)
+
+// ChatHistoryHandler serves the chat of past and live classes.
+type ChatHistoryHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	chatRepo     *repository.ChatMessageRepository
+}
+
+// NewChatHistoryHandler creates a new ChatHistoryHandler.
+func NewChatHistoryHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, chatRepo *repository.ChatMessageRepository) *ChatHistoryHandler {
+	return &ChatHistoryHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		chatRepo:     chatRepo,
+	}
+}
+
+// GetChat handles GET /api/schedules/{id}/chat?before=&limit=, the chat of a
+// class newest first. Pass the sentAt of the oldest message as before for
+// the page preceding it. Messages students sent the presenter during an
+// exam are only listed for those teaching the class and their senders.
+func (h *ChatHistoryHandler) GetChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/chat
+	scheduleID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")[0]
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendStoreError(w, "Batch not found", err)
+		return
+	}
+
+	teaches := user.Role == models.RoleAdmin || schedule.PresenterID == user.ID || batch.HasAssistant(user.ID.Hex())
+	if !teaches && !batch.HasStudent(user.ID.Hex()) {
+		sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	var before time.Time
+	if b := query.Get("before"); b != "" {
+		if before, err = time.Parse(time.RFC3339, b); err != nil {
+			sendJSONError(w, "Invalid before timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(defaultChatHistoryLimit)
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = int64(l)
+		if limit > maxChatHistoryLimit {
+			limit = maxChatHistoryLimit
+		}
+	}
+
+	messages, err := h.chatRepo.FindBySchedule(r.Context(), schedule.ID, user.ID.Hex(), teaches, before, limit)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch chat", err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"messages": messages,
+		"hasMore":  int64(len(messages)) == limit,
+	}, http.StatusOK)
+}
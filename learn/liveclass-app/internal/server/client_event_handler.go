@@ -0,0 +1,304 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/validate"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	clientEventsMaxBody   = 256 << 10
+	clientEventsMaxSkew   = 24 * time.Hour // Further off, client clocks aren't trusted
+	clientEventsWindow    = 7 * 24 * time.Hour
+	clientEventsMaxWindow = 31 * 24 * time.Hour
+	clientEventsLimit     = 200
+	clientEventsMaxLimit  = 1000
+	clientEventsTopErrors = 20
+)
+
+// clientEventsReceived counts reported client events by type and outcome
+// (stored, sampled_out, rejected).
+var clientEventsReceived = metrics.NewCounterVec(
+	"liveclass_client_events_total",
+	"Client-side telemetry events by type and outcome (stored, sampled_out, rejected).",
+	"type", "result",
+)
+
+// clientEventRequest is one event of a batch reported by the SPA.
+type clientEventRequest struct {
+	Type        string `json:"type" validate:"required,oneof=page_view player_error join_failure"`
+	Path        string `json:"path" validate:"max=500"`
+	Message     string `json:"message" validate:"max=1000"`
+	Code        string `json:"code" validate:"max=100"`
+	RoomID      string `json:"roomId" validate:"max=50"`
+	RecordingID string `json:"recordingId" validate:"objectid"`
+	SessionID   string `json:"sessionId" validate:"max=100"`
+	Release     string `json:"release" validate:"max=50"`
+	OccurredAt  string `json:"occurredAt" validate:"rfc3339"`
+}
+
+// check applies the rules of the event's type, returning the failure.
+func (e *clientEventRequest) check() validate.Errors {
+	switch {
+	case e.Type == models.ClientEventPageView && strings.TrimSpace(e.Path) == "":
+		return validate.Errors{"path": "is required for page views"}
+	case e.Type == models.ClientEventPlayerError && strings.TrimSpace(e.Message) == "":
+		return validate.Errors{"message": "is required for player errors"}
+	case e.Type == models.ClientEventJoinFailure && strings.TrimSpace(e.Message) == "" && strings.TrimSpace(e.Code) == "":
+		return validate.Errors{"message": "or code is required for join failures"}
+	}
+	return nil
+}
+
+// rejectedClientEvent is an event of a batch that wasn't stored.
+type rejectedClientEvent struct {
+	Index  int             `json:"index"`
+	Error  string          `json:"error"`
+	Fields validate.Errors `json:"fields,omitempty"`
+}
+
+// ClientEventHandler collects telemetry from the SPA, such as player errors
+// and failed joins, and lets admins query it.
+type ClientEventHandler struct {
+	authService *auth.Service
+	eventRepo   *repository.ClientEventRepository
+	sampleRates map[string]float64 // Share of events kept by type; 1 if unset
+	instance    string
+}
+
+// NewClientEventHandler creates a new ClientEventHandler.
+func NewClientEventHandler(authService *auth.Service, eventRepo *repository.ClientEventRepository, sampleRates map[string]float64, instance string) *ClientEventHandler {
+	return &ClientEventHandler{
+		authService: authService,
+		eventRepo:   eventRepo,
+		sampleRates: sampleRates,
+		instance:    instance,
+	}
+}
+
+// Report stores a batch of up to 50 client-side events (POST /api/events
+// {"events": [...]}). Each event is validated on its own: invalid ones are
+// listed under "rejected" and the rest are kept. Events are sampled by
+// type, so a share of them is dropped without error.
+func (h *ClientEventHandler) Report(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, clientEventsMaxBody)
+	var req struct {
+		Events []json.RawMessage `json:"events" validate:"required,max=50"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	now := time.Now()
+	events := make([]models.ClientEvent, 0, len(req.Events))
+	rejected := []rejectedClientEvent{}
+	sampledOut := 0
+	for i, raw := range req.Events {
+		var e clientEventRequest
+		if err := json.Unmarshal(raw, &e); err != nil {
+			rejected = append(rejected, rejectedClientEvent{Index: i, Error: "Invalid event"})
+			clientEventsReceived.WithLabelValues("unknown", "rejected").Inc()
+			continue
+		}
+		var fields validate.Errors
+		if err := validate.Struct(&e); !errors.As(err, &fields) {
+			fields = e.check()
+		}
+		if len(fields) > 0 {
+			rejected = append(rejected, rejectedClientEvent{Index: i, Error: fields.Error(), Fields: fields})
+			clientEventsReceived.WithLabelValues(metricType(e.Type), "rejected").Inc()
+			continue
+		}
+
+		rate := h.sampleRate(e.Type)
+		if rand.Float64() >= rate {
+			sampledOut++
+			clientEventsReceived.WithLabelValues(e.Type, "sampled_out").Inc()
+			continue
+		}
+
+		occurredAt := now
+		if t, err := time.Parse(time.RFC3339, e.OccurredAt); err == nil && t.After(now.Add(-clientEventsMaxSkew)) && t.Before(now.Add(time.Minute)) {
+			occurredAt = t
+		}
+		events = append(events, models.ClientEvent{
+			Type:        e.Type,
+			Path:        e.Path,
+			Message:     e.Message,
+			Code:        e.Code,
+			RoomID:      strings.ToUpper(e.RoomID),
+			RecordingID: e.RecordingID,
+			SessionID:   e.SessionID,
+			Release:     e.Release,
+			UserID:      user.ID,
+			Role:        user.Role,
+			UserAgent:   truncate(r.UserAgent(), 300),
+			Instance:    h.instance,
+			SampleRate:  rate,
+			OccurredAt:  occurredAt,
+			ReceivedAt:  now,
+		})
+	}
+
+	if len(events) > 0 {
+		if err := h.eventRepo.InsertMany(r.Context(), events); err != nil {
+			sendStoreError(w, "Failed to store events", err)
+			return
+		}
+		for _, e := range events {
+			clientEventsReceived.WithLabelValues(e.Type, "stored").Inc()
+		}
+	}
+
+	status := http.StatusAccepted
+	if len(rejected) == len(req.Events) {
+		status = http.StatusBadRequest
+	}
+	sendJSON(w, map[string]interface{}{
+		"accepted":   len(events),
+		"sampledOut": sampledOut,
+		"rejected":   rejected,
+	}, status)
+}
+
+// sampleRate returns the share of events of a type that are kept.
+func (h *ClientEventHandler) sampleRate(eventType string) float64 {
+	if rate, ok := h.sampleRates[eventType]; ok {
+		return rate
+	}
+	return 1
+}
+
+// metricType keeps unknown types out of metric labels.
+func metricType(eventType string) string {
+	switch eventType {
+	case models.ClientEventPageView, models.ClientEventPlayerError, models.ClientEventJoinFailure:
+		return eventType
+	}
+	return "unknown"
+}
+
+// List returns client events, newest first (GET /api/admin/client-events
+// ?type=&userId=&roomId=&from=&to=&limit=). from and to are RFC 3339 and
+// default to the last seven days; limit defaults to 200, at most 1000.
+func (h *ClientEventHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := clientEventFilter(w, r)
+	if !ok {
+		return
+	}
+	limit := int64(clientEventsLimit)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > clientEventsMaxLimit {
+			sendJSONError(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	events, err := h.eventRepo.Find(r.Context(), filter, limit)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch client events", err)
+		return
+	}
+	sendJSON(w, map[string]interface{}{
+		"from":   filter.From,
+		"to":     filter.To,
+		"events": events,
+	}, http.StatusOK)
+}
+
+// Summary returns client events per type and day, and the most frequent
+// errors, with counts estimated for sampling (GET
+// /api/admin/client-events/summary, filtered as List).
+func (h *ClientEventHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := clientEventFilter(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := h.eventRepo.CountByDay(r.Context(), filter)
+	if err != nil {
+		sendStoreError(w, "Failed to summarize client events", err)
+		return
+	}
+	topErrors, err := h.eventRepo.TopErrors(r.Context(), filter, clientEventsTopErrors)
+	if err != nil {
+		sendStoreError(w, "Failed to summarize client events", err)
+		return
+	}
+	sendJSON(w, map[string]interface{}{
+		"from":      filter.From,
+		"to":        filter.To,
+		"days":      days,
+		"topErrors": topErrors,
+	}, http.StatusOK)
+}
+
+// clientEventFilter reads the filter of the admin queries. On failure it
+// sends a 400 response and returns false.
+func clientEventFilter(w http.ResponseWriter, r *http.Request) (repository.ClientEventFilter, bool) {
+	q := r.URL.Query()
+	query := struct {
+		Type   string `json:"type" validate:"oneof=page_view player_error join_failure"`
+		UserID string `json:"userId" validate:"objectid"`
+		RoomID string `json:"roomId" validate:"max=50"`
+		From   string `json:"from" validate:"rfc3339"`
+		To     string `json:"to" validate:"rfc3339"`
+	}{q.Get("type"), q.Get("userId"), q.Get("roomId"), q.Get("from"), q.Get("to")}
+	if !checkRequest(w, &query) {
+		return repository.ClientEventFilter{}, false
+	}
+
+	filter := repository.ClientEventFilter{
+		Type:   query.Type,
+		RoomID: strings.ToUpper(query.RoomID),
+		To:     time.Now(),
+	}
+	if query.UserID != "" {
+		filter.UserID, _ = primitive.ObjectIDFromHex(query.UserID)
+	}
+	if query.To != "" {
+		filter.To, _ = time.Parse(time.RFC3339, query.To)
+	}
+	filter.From = filter.To.Add(-clientEventsWindow)
+	if query.From != "" {
+		filter.From, _ = time.Parse(time.RFC3339, query.From)
+	}
+	if !filter.To.After(filter.From) || filter.To.Sub(filter.From) > clientEventsMaxWindow {
+		sendJSONError(w, "to must be after from and at most 31 days later", http.StatusBadRequest)
+		return repository.ClientEventFilter{}, false
+	}
+	return filter, true
+}
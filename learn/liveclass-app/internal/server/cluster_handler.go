@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/cluster"
+)
+
+// ClusterHandler shows operators the instances of the deployment and lets
+// them drain one, e.g. before taking it down.
+type ClusterHandler struct {
+	authService *auth.Service
+	registry    *cluster.Registry
+	multi       bool // Whether instances share Redis
+}
+
+// NewClusterHandler creates a new ClusterHandler.
+func NewClusterHandler(authService *auth.Service, registry *cluster.Registry, multi bool) *ClusterHandler {
+	return &ClusterHandler{
+		authService: authService,
+		registry:    registry,
+		multi:       multi,
+	}
+}
+
+// Cluster lists the live instances with their load (GET /api/admin/cluster).
+// Without Redis it lists just this instance.
+func (h *ClusterHandler) Cluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	instances, err := h.registry.Instances(r.Context())
+	if err != nil {
+		log.Printf("[Cluster] Failed to list instances: %v", err)
+		sendStoreError(w, "Failed to list instances", err)
+		return
+	}
+	sendJSON(w, map[string]interface{}{
+		"multiInstance": h.multi,
+		"instances":     instances,
+	}, http.StatusOK)
+}
+
+// Drain starts draining an instance (POST /api/admin/cluster/{id}/drain) or
+// cancels it (DELETE). A draining instance fails its readiness check and
+// opens no new rooms; classes already on it carry on until they end.
+func (h *ClusterHandler) Drain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract instance ID from URL: /api/admin/cluster/{id}/drain
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/cluster/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "drain" {
+		sendJSONError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	instanceID, draining := parts[0], r.Method == http.MethodPost
+
+	if err := h.registry.Drain(r.Context(), instanceID, draining); err != nil {
+		if errors.Is(err, cluster.ErrUnknownInstance) {
+			sendJSONError(w, "Instance not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("[Cluster] Failed to send drain command to %s: %v", instanceID, err)
+		sendJSONError(w, "Failed to send the command", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Cluster] %s set draining=%v on instance %s", admin.Name, draining, instanceID)
+	message := "Instance is draining"
+	if !draining {
+		message = "Instance accepts new rooms again"
+	}
+	sendJSON(w, map[string]string{"message": message}, http.StatusAccepted)
+}
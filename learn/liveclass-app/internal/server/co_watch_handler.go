@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/jinshatcp/brightline-academy/learn/internal/rtc"
+)
+
+var (
+	// errClipRecordingUnavailable is returned when a recording can't be
+	// played into a class: it isn't ready, is hidden or is of another batch.
+	errClipRecordingUnavailable = errors.New("recording can't be played into this class")
+	// errClipRestricted is returned for recordings only some students may
+	// watch, which the whole class would see.
+	errClipRestricted = errors.New("recording is restricted to some students")
+	// errInvalidSegment is returned for a segment that isn't in the
+	// recording.
+	errInvalidSegment = errors.New("invalid segment")
+)
+
+// CoWatchHandler lets presenters play a segment of an earlier recording of
+// the batch into their live class, for the class to rewatch together
+// without screen sharing a video player. Clips are played, paused and
+// stopped with the REST API or signaling messages.
+type CoWatchHandler struct {
+	scheduleRepo  *repository.ScheduleRepository
+	recordingRepo *repository.RecordingRepository
+	files         *encryption.Encryptor // nil stores files in plaintext
+	hub           *room.Hub
+	player        *rtc.ClipPlayer
+}
+
+// NewCoWatchHandler creates a new CoWatchHandler.
+func NewCoWatchHandler(scheduleRepo *repository.ScheduleRepository, recordingRepo *repository.RecordingRepository, files *encryption.Encryptor, hub *room.Hub, player *rtc.ClipPlayer) *CoWatchHandler {
+	return &CoWatchHandler{
+		scheduleRepo:  scheduleRepo,
+		recordingRepo: recordingRepo,
+		files:         files,
+		hub:           hub,
+		player:        player,
+	}
+}
+
+// clipRequest is a segment of a recording to play, in seconds into it.
+type clipRequest struct {
+	RecordingID string  `json:"recordingId" validate:"required,objectid"`
+	Start       float64 `json:"start"`
+	End         float64 `json:"end"` // 0 plays to the end of the recording
+}
+
+// CoWatch handles /api/schedules/{id}/co-watch for the class presenter and
+// admins:
+//   - GET: the state of the class's latest clip
+//   - POST: play a segment of a recording {recordingId, start, end}
+//   - PATCH: pause or resume it {paused}
+//   - DELETE: stop it
+//
+// Clips also stop when the presenter leaves or the class ends.
+func (h *CoWatchHandler) CoWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/co-watch
+	scheduleID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")[0]
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the class presenter can play recordings into this class", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		var latest *rtc.ClipStatus // nil until a clip is first played here
+		if schedule.RoomID != "" {
+			if status, ok := h.player.Status(schedule.RoomID); ok {
+				latest = &status
+			}
+		}
+		sendJSON(w, map[string]interface{}{
+			"available": h.player.Available(),
+			"clip":      latest,
+		}, http.StatusOK)
+		return
+	}
+
+	liveRoom, ok := h.liveRoom(schedule)
+	if !ok {
+		sendJSONError(w, "This class isn't live", http.StatusConflict)
+		return
+	}
+
+	var status rtc.ClipStatus
+	switch r.Method {
+	case http.MethodPost:
+		var req clipRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		status, err = h.play(r.Context(), schedule, liveRoom, user.Name, req)
+		if err != nil {
+			msg, code := coWatchError(err)
+			if code == http.StatusInternalServerError {
+				log.Printf("[CoWatch] Failed to play %s into %s: %v", req.RecordingID, scheduleID, err)
+			}
+			sendJSONError(w, msg, code)
+			return
+		}
+		sendJSON(w, status, http.StatusAccepted)
+		return
+
+	case http.MethodPatch:
+		var req struct {
+			Paused bool `json:"paused"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.Paused {
+			status, err = h.player.Pause(liveRoom.ID)
+		} else {
+			status, err = h.player.Resume(liveRoom.ID)
+		}
+
+	case http.MethodDelete:
+		status, err = h.player.Stop(liveRoom.ID)
+	}
+	if err != nil {
+		msg, code := coWatchError(err)
+		sendJSONError(w, msg, code)
+		return
+	}
+	sendJSON(w, status, http.StatusOK)
+}
+
+// liveRoom returns the room of a live class on this instance.
+func (h *CoWatchHandler) liveRoom(schedule *models.ScheduledClass) (*room.Room, bool) {
+	if schedule.Status != models.ClassStatusLive || schedule.RoomID == "" {
+		return nil, false
+	}
+	return h.hub.GetRoom(schedule.RoomID)
+}
+
+// play plays a segment of a recording into a class's room for startedBy.
+// Only recordings of the class's batch that every student of it may watch
+// can be played.
+func (h *CoWatchHandler) play(ctx context.Context, schedule *models.ScheduledClass, liveRoom *room.Room, startedBy string, req clipRequest) (rtc.ClipStatus, error) {
+	if !h.player.Available() {
+		return rtc.ClipStatus{}, rtc.ErrClipsUnavailable
+	}
+	recording, err := h.recordingRepo.FindByID(ctx, req.RecordingID)
+	if err != nil {
+		return rtc.ClipStatus{}, err
+	}
+	if recording.BatchID != schedule.BatchID || !recording.IsReady() || recording.Hidden {
+		return rtc.ClipStatus{}, errClipRecordingUnavailable
+	}
+	if recording.IsRestricted() {
+		return rtc.ClipStatus{}, errClipRestricted
+	}
+	if req.Start < 0 || (req.End != 0 && req.End <= req.Start) ||
+		(recording.Duration > 0 && req.Start >= float64(recording.Duration)) {
+		return rtc.ClipStatus{}, errInvalidSegment
+	}
+
+	// Encrypted files are decrypted into ffmpeg as it reads them
+	var input io.ReadCloser
+	if _, encrypted, err := encryption.KeyID(recording.FilePath); err != nil {
+		return rtc.ClipStatus{}, err
+	} else if encrypted {
+		if input, err = h.files.Open(context.WithoutCancel(ctx), recording.FilePath); err != nil {
+			return rtc.ClipStatus{}, err
+		}
+	}
+
+	return h.player.Play(liveRoom, rtc.Clip{
+		RecordingID: recording.ID.Hex(),
+		Title:       recording.Title,
+		Path:        recording.FilePath,
+		Input:       input,
+		Start:       time.Duration(req.Start * float64(time.Second)),
+		End:         time.Duration(req.End * float64(time.Second)),
+		StartedBy:   startedBy,
+	})
+}
+
+// playInRoom plays a segment of a recording into a room, for a "play-clip"
+// message from its presenter.
+func (h *CoWatchHandler) playInRoom(ctx context.Context, liveRoom *room.Room, startedBy string, req clipRequest) (rtc.ClipStatus, error) {
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, liveRoom.ID)
+	if err != nil {
+		return rtc.ClipStatus{}, errNoScheduledClass
+	}
+	return h.play(ctx, schedule, liveRoom, startedBy, req)
+}
+
+// coWatchError returns the message and status to answer a failure to play,
+// pause or stop a clip with.
+func coWatchError(err error) (string, int) {
+	switch {
+	case errors.Is(err, rtc.ErrClipsUnavailable):
+		return "Playing recordings into classes is not available", http.StatusServiceUnavailable
+	case errors.Is(err, errNoScheduledClass):
+		return "Recordings can only be played into scheduled classes", http.StatusConflict
+	case errors.Is(err, repository.ErrRecordingNotFound):
+		return "Recording not found", http.StatusNotFound
+	case errors.Is(err, errClipRecordingUnavailable):
+		return "Only ready recordings of this class's batch can be played", http.StatusBadRequest
+	case errors.Is(err, errClipRestricted):
+		return "This recording is restricted to some students, so it can't be played to the class", http.StatusForbidden
+	case errors.Is(err, errInvalidSegment):
+		return "The segment isn't within the recording", http.StatusBadRequest
+	case errors.Is(err, rtc.ErrStreamNotReady):
+		return "The presenter isn't streaming yet", http.StatusConflict
+	case errors.Is(err, rtc.ErrClipPlaying):
+		return "A recording is already playing in this class", http.StatusConflict
+	case errors.Is(err, rtc.ErrNoClip):
+		return "No recording is playing in this class", http.StatusNotFound
+	default:
+		return "Failed to play the recording", http.StatusInternalServerError
+	}
+}
@@ -2,33 +2,97 @@
 package server
 
 import (
+	"io"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/jinshatcp/brightline-academy/learn/internal/ice"
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
 	"github.com/jinshatcp/brightline-academy/learn/internal/room"
 )
 
+var wsSentMessages = metrics.NewCounterVec(
+	"liveclass_ws_sent_messages_total",
+	"WebSocket messages sent, by whether permessage-deflate was applied.",
+	"compressed",
+)
+
+// CompressionOptions configures permessage-deflate on WebSocket connections.
+type CompressionOptions struct {
+	Enabled   bool
+	Level     int   // flate level, 1 (fastest) to 9 (smallest)
+	Threshold int   // Messages smaller than this many bytes are sent uncompressed
+	ReadLimit int64 // Largest incoming message accepted, in bytes (0 = unlimited)
+}
+
+// Clients are pinged every wsPingInterval; browsers answer on their own. A
+// connection that hasn't been heard from for wsPongTimeout is dead.
+const (
+	wsPingInterval = 25 * time.Second
+	wsPongTimeout  = 3 * wsPingInterval
+	wsWriteWait    = 10 * time.Second
+)
+
 // Ensure WSConn implements room.Connection interface.
 var _ room.Connection = (*WSConn)(nil)
 
 // WSConn wraps a WebSocket connection with thread-safe operations.
 type WSConn struct {
-	ws   *websocket.Conn
-	send chan []byte
-	mu   sync.Mutex
+	ws     *websocket.Conn
+	send   chan []byte
+	mu     sync.Mutex
+	closed bool
+	sendMu sync.RWMutex
+
+	// Compress messages of at least this many bytes; 0 when compression is off
+	compressThreshold int
+	// Largest incoming message after decompression; 0 = unlimited
+	readLimit int64
+
+	// ICE servers selected for the client's location at upgrade
+	iceServers []ice.Server
+
+	// When the client was last heard from (unix nanoseconds), and whether
+	// the write pump has stopped
+	lastSeen atomic.Int64
+	stopped  atomic.Bool
 }
 
-// NewWSConn creates a new WebSocket connection wrapper.
-func NewWSConn(ws *websocket.Conn) *WSConn {
-	return &WSConn{
-		ws:   ws,
-		send: make(chan []byte, 256),
+// NewWSConn creates a new WebSocket connection wrapper. Messages of at least
+// compressThreshold bytes are compressed; pass 0 if permessage-deflate wasn't
+// negotiated. Incoming messages larger than readLimit bytes are rejected.
+func NewWSConn(ws *websocket.Conn, compressThreshold int, readLimit int64) *WSConn {
+	if readLimit > 0 {
+		// Rejects oversized frames before they're read off the wire
+		ws.SetReadLimit(readLimit)
 	}
+	c := &WSConn{
+		ws:                ws,
+		send:              make(chan []byte, 256),
+		compressThreshold: compressThreshold,
+		readLimit:         readLimit,
+	}
+	c.seen()
+	ws.SetPongHandler(func(string) error {
+		c.seen()
+		return nil
+	})
+	return c
 }
 
 // Send queues a message to be sent to the client.
+// Messages sent after Close are dropped.
 func (c *WSConn) Send(message []byte) {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.closed {
+		return
+	}
+
 	select {
 	case c.send <- message:
 	default:
@@ -36,31 +100,110 @@ func (c *WSConn) Send(message []byte) {
 	}
 }
 
-// WritePump handles writing messages to the WebSocket connection.
-// This should be run in a separate goroutine.
+// WritePump handles writing messages to the WebSocket connection and pings
+// the client. This should be run in a separate goroutine.
 func (c *WSConn) WritePump() {
 	defer c.ws.Close()
+	defer c.stopped.Store(true)
 
-	for message := range c.send {
-		c.mu.Lock()
-		err := c.ws.WriteMessage(websocket.TextMessage, message)
-		c.mu.Unlock()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
 
-		if err != nil {
-			log.Printf("[WS] Write error: %v", err)
-			return
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := c.write(message); err != nil {
+				log.Printf("[WS] Write error: %v", err)
+				return
+			}
+		case <-ping.C:
+			c.mu.Lock()
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			c.mu.Unlock()
+			if err != nil {
+				log.Printf("[WS] Ping error: %v", err)
+				return
+			}
 		}
 	}
 }
 
+// write sends a message, compressed if it is large enough.
+func (c *WSConn) write(message []byte) error {
+	// Small signaling frames aren't worth the deflate overhead
+	compress := c.compressThreshold > 0 && len(message) >= c.compressThreshold
+
+	c.mu.Lock()
+	c.ws.EnableWriteCompression(compress)
+	err := c.ws.WriteMessage(websocket.TextMessage, message)
+	c.mu.Unlock()
+
+	if compress {
+		wsSentMessages.WithLabelValues("true").Inc()
+	} else {
+		wsSentMessages.WithLabelValues("false").Inc()
+	}
+	return err
+}
+
 // ReadMessage reads a message from the WebSocket connection.
+// The wire read limit only counts compressed bytes, so the inflated size is
+// capped here as well.
 func (c *WSConn) ReadMessage() ([]byte, error) {
-	_, message, err := c.ws.ReadMessage()
-	return message, err
+	if c.readLimit <= 0 {
+		_, message, err := c.ws.ReadMessage()
+		if err == nil {
+			c.seen()
+		}
+		return message, err
+	}
+
+	_, r, err := c.ws.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	message, err := io.ReadAll(io.LimitReader(r, c.readLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) > c.readLimit {
+		c.mu.Lock()
+		c.ws.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseMessageTooBig, ""), time.Now().Add(time.Second))
+		c.mu.Unlock()
+		return nil, websocket.ErrReadLimit
+	}
+	c.seen()
+	return message, nil
+}
+
+// Alive reports whether the connection is open and the client answered
+// recently. A client whose read loop stopped no longer answers pings.
+func (c *WSConn) Alive() bool {
+	c.sendMu.RLock()
+	closed := c.closed
+	c.sendMu.RUnlock()
+
+	return !closed && !c.stopped.Load() && time.Since(time.Unix(0, c.lastSeen.Load())) < wsPongTimeout
 }
 
-// Close closes the connection and its send channel.
+// seen records that the client was heard from.
+func (c *WSConn) seen() {
+	c.lastSeen.Store(time.Now().UnixNano())
+}
+
+// Close closes the connection and its send channel. It is safe to call more than once.
 func (c *WSConn) Close() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
 	close(c.send)
 }
 
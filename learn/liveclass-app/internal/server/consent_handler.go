@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConsentHandler keeps the record of recording consent requests and answers
+// for scheduled classes, so it can be attached to the uploaded recording.
+type ConsentHandler struct {
+	scheduleRepo *repository.ScheduleRepository
+	consentRepo  *repository.RecordingConsentRepository
+}
+
+// NewConsentHandler creates a new ConsentHandler.
+func NewConsentHandler(scheduleRepo *repository.ScheduleRepository, consentRepo *repository.RecordingConsentRepository) *ConsentHandler {
+	return &ConsentHandler{
+		scheduleRepo: scheduleRepo,
+		consentRepo:  consentRepo,
+	}
+}
+
+// Begin stores a new consent request for the class running in a room and
+// returns its ID. Rooms without a scheduled class get an ID that isn't
+// stored, as there is no class to attach a recording to.
+func (h *ConsentHandler) Begin(ctx context.Context, roomID string, presenter *room.Participant, excludeNonConsenting bool) string {
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, strings.ToUpper(roomID))
+	if err != nil {
+		return uuid.New().String()
+	}
+
+	consent := &models.RecordingConsent{
+		ScheduleID:           schedule.ID,
+		RoomID:               roomID,
+		RequestedByName:      presenter.Name,
+		ExcludeNonConsenting: excludeNonConsenting,
+	}
+	if userID, err := primitive.ObjectIDFromHex(presenter.UserID); err == nil {
+		consent.RequestedBy = userID
+	}
+	if err := h.consentRepo.Create(ctx, consent); err != nil {
+		log.Printf("[Consent] Failed to store consent request for room %s: %v", roomID, err)
+		return uuid.New().String()
+	}
+	return consent.ID.Hex()
+}
+
+// Record stores a student's answer. The write happens in the background so
+// signaling is never blocked on the database.
+func (h *ConsentHandler) Record(requestID string, p *room.Participant, consented bool) {
+	id, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		return // Not stored, see Begin
+	}
+
+	resp := models.ConsentResponse{
+		ParticipantID: p.ID,
+		Name:          p.Name,
+		Consented:     consented,
+		RespondedAt:   time.Now(),
+	}
+	if userID, err := primitive.ObjectIDFromHex(p.UserID); err == nil {
+		resp.UserID = userID
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := h.consentRepo.SetResponse(ctx, id, resp); err != nil {
+			log.Printf("[Consent] Failed to record answer of %s: %v", p.Name, err)
+		}
+	}()
+}
+
+// ForSchedule returns the latest consent request of a class, or nil if
+// consent was never requested.
+func (h *ConsentHandler) ForSchedule(ctx context.Context, scheduleID primitive.ObjectID) *models.RecordingConsent {
+	consent, err := h.consentRepo.LatestForSchedule(ctx, scheduleID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrConsentNotFound) {
+			log.Printf("[Consent] Failed to load consent for class %s: %v", scheduleID.Hex(), err)
+		}
+		return nil
+	}
+	return consent
+}
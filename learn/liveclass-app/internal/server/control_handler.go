@@ -0,0 +1,287 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chatlog"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// Roles on the control panel of a live class
+const (
+	controlPresenter = "presenter"
+	controlAssistant = "assistant"
+	controlAdmin     = "admin"
+)
+
+// Control panel actions (POST /api/rooms/{id}/control/{action})
+const (
+	actionRemoveParticipant = "remove-participant"
+	actionLowerHand         = "lower-hand"
+	actionDeleteChat        = "delete-chat"
+	actionRequestConsent    = "request-consent"
+	actionRecording         = "recording"
+)
+
+// controlActions are the actions each role may take. Moderation is shared;
+// recording decisions are the presenter's.
+var controlActions = map[string][]string{
+	controlPresenter: {actionRemoveParticipant, actionLowerHand, actionDeleteChat, actionRequestConsent, actionRecording},
+	controlAssistant: {actionRemoveParticipant, actionLowerHand, actionDeleteChat},
+	controlAdmin:     {actionRemoveParticipant, actionLowerHand, actionDeleteChat},
+}
+
+// ControlHandler serves the presenter's control panel of a live class: its
+// state in one call, and an HTTP action for each control, so the console
+// doesn't have to assemble them from WebSocket messages.
+type ControlHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	consent      *ConsentHandler
+	chatLog      *chatlog.Recorder
+	hub          *room.Hub
+}
+
+// NewControlHandler creates a new ControlHandler.
+func NewControlHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, consent *ConsentHandler, chatLog *chatlog.Recorder, hub *room.Hub) *ControlHandler {
+	return &ControlHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		consent:      consent,
+		chatLog:      chatLog,
+		hub:          hub,
+	}
+}
+
+// controlTarget is the live room a control panel request is about, and the
+// caller's role in it.
+type controlTarget struct {
+	room     *room.Room
+	schedule *models.ScheduledClass // nil for rooms without a scheduled class
+	user     *models.User
+	role     string
+}
+
+// ControlPanel is the state of a live class for its control panel.
+type ControlPanel struct {
+	RoomID     string   `json:"roomId"`
+	SessionID  string   `json:"sessionId"`
+	ScheduleID string   `json:"scheduleId,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Role       string   `json:"role"`    // The caller's role: presenter, assistant or admin
+	Actions    []string `json:"actions"` // The actions the caller may take
+
+	// The class's lobby, until it goes live; its viewers are waiting to be let in
+	Lobby *ControlLobby `json:"lobby,omitempty"`
+
+	StreamReady           bool                     `json:"streamReady"`
+	PresenterReconnecting bool                     `json:"presenterReconnecting"`
+	Participants          []room.ParticipantStatus `json:"participants"`
+	RaisedHands           []room.RaisedHand        `json:"raisedHands"`
+
+	Recording bool                `json:"recording"`
+	Consent   *room.ConsentStatus `json:"consent,omitempty"` // The last consent request, if any
+
+	Stats        room.RoomMediaStats `json:"stats"`
+	QualityLimit *room.QualityLimit  `json:"qualityLimit,omitempty"`
+}
+
+// ControlLobby is a class's lobby as seen from its control panel.
+type ControlLobby struct {
+	StartsAt time.Time `json:"startsAt"`
+	Waiting  int       `json:"waiting"`
+}
+
+// Control returns the state of a live class for its control panel
+// (GET /api/rooms/{id}/control). The class presenter, its batch's
+// assistants and admins can see it; actions lists what the caller may do.
+func (h *ControlHandler) Control(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+	liveRoom, role, schedule := target.room, target.role, target.schedule
+
+	panel := ControlPanel{
+		RoomID:                liveRoom.ID,
+		SessionID:             liveRoom.SessionID,
+		Role:                  role,
+		Actions:               controlActions[role],
+		StreamReady:           liveRoom.IsStreamReady(),
+		PresenterReconnecting: liveRoom.IsPresenterReconnecting(),
+		Participants:          liveRoom.ParticipantStatuses(),
+		RaisedHands:           liveRoom.RaisedHands(),
+		Recording:             liveRoom.IsRecording(),
+		Consent:               liveRoom.ConsentStatus(),
+		Stats:                 liveRoom.MediaStats(),
+		QualityLimit:          liveRoom.QualityLimit(),
+	}
+	if schedule != nil {
+		panel.ScheduleID = schedule.ID.Hex()
+		panel.Title = schedule.Title
+	}
+	if startsAt, ok := liveRoom.Lobby(); ok {
+		panel.Lobby = &ControlLobby{StartsAt: startsAt, Waiting: len(liveRoom.GetAllViewers())}
+	}
+
+	sendJSON(w, panel, http.StatusOK)
+}
+
+// Act takes a control panel action (POST /api/rooms/{id}/control/{action}):
+//   - remove-participant {"participantId"}: disconnect a participant, who
+//     can't rejoin; only the presenter and admins can remove assistants
+//   - lower-hand {"participantId"}: lower a raised hand, or every hand
+//     without a participant
+//   - delete-chat {"messageId"}: delete a chat message
+//   - request-consent {"excludeNonConsenting"}: ask students for recording consent
+//   - recording {"recording"}: start or stop recording the class
+//
+// Everyone in the room is told as if the action came over the WebSocket.
+func (h *ControlHandler) Act(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+	liveRoom, role, user := target.room, target.role, target.user
+
+	// Extract the action from URL: /api/rooms/{id}/control/{action}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/rooms/"), "/")
+	action := ""
+	if len(parts) >= 3 {
+		action = parts[2]
+	}
+	allowed := false
+	for _, a := range controlActions[role] {
+		allowed = allowed || a == action
+	}
+	if !allowed {
+		sendJSONError(w, "You can't take this action in this class", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		ParticipantID        string `json:"participantId"`
+		MessageID            string `json:"messageId"`
+		ExcludeNonConsenting bool   `json:"excludeNonConsenting"`
+		Recording            bool   `json:"recording"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Stands in for the caller in the room, whether or not they joined it
+	actor := &room.Participant{Name: user.Name, UserID: user.ID.Hex(), IsPresenter: role == controlPresenter}
+
+	switch action {
+	case actionRemoveParticipant:
+		p, ok := liveRoom.GetParticipant(req.ParticipantID)
+		if !ok {
+			sendJSONError(w, "Participant not found", http.StatusNotFound)
+			return
+		}
+		if p.IsPresenter || (p.IsAssistant && role == controlAssistant) || p.UserID == actor.UserID {
+			sendJSONError(w, "You can't remove this participant", http.StatusForbidden)
+			return
+		}
+		liveRoom.Eject(p, actor)
+
+	case actionLowerHand:
+		if req.ParticipantID == "" {
+			liveRoom.LowerAllHands(user.Name)
+		} else if !liveRoom.LowerHand(req.ParticipantID, user.Name) {
+			sendJSONError(w, "That hand isn't raised", http.StatusNotFound)
+			return
+		}
+
+	case actionDeleteChat:
+		if req.MessageID == "" || !liveRoom.DeleteChatMessage(req.MessageID, user.Name) {
+			sendJSONError(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		h.chatLog.Deleted(req.MessageID, user.Name)
+
+	case actionRequestConsent:
+		if liveRoom.IsRecording() {
+			sendJSONError(w, "Stop the recording before asking for consent again", http.StatusConflict)
+			return
+		}
+		requestID := h.consent.Begin(r.Context(), liveRoom.ID, actor, req.ExcludeNonConsenting)
+		if err := liveRoom.RequestRecordingConsent(requestID, user.Name, req.ExcludeNonConsenting); err != nil {
+			sendJSONError(w, "Stop the recording before asking for consent again", http.StatusConflict)
+			return
+		}
+		liveRoom.SendConsentStatus()
+
+	case actionRecording:
+		if err := liveRoom.SetRecording(req.Recording); err != nil {
+			sendJSONError(w, "Ask the students for recording consent before recording", http.StatusConflict)
+			return
+		}
+		liveRoom.SendConsentStatus()
+	}
+
+	log.Printf("[Control] %s (%s) took %s in room %s", user.Name, role, action, liveRoom.ID)
+	sendJSON(w, map[string]string{"message": "Done"}, http.StatusOK)
+}
+
+// loadRoom finds the live room in the URL and the caller's role in it. It
+// writes the error response on failure.
+func (h *ControlHandler) loadRoom(w http.ResponseWriter, r *http.Request) (*controlTarget, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	// Extract room ID from URL: /api/rooms/{id}/control
+	roomID := strings.ToUpper(strings.Split(strings.TrimPrefix(r.URL.Path, "/api/rooms/"), "/")[0])
+
+	// Lobbies are found by their own room ID until the class goes live
+	schedule, err := h.scheduleRepo.FindByRoomID(r.Context(), roomID)
+	if err != nil {
+		schedule, err = h.scheduleRepo.FindByLobbyRoomID(r.Context(), roomID)
+	}
+	if err != nil {
+		schedule = nil
+	}
+
+	role := ""
+	switch {
+	case schedule != nil && schedule.PresenterID == user.ID:
+		role = controlPresenter
+	case user.Role == models.RoleAdmin:
+		role = controlAdmin
+	case schedule != nil:
+		if batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex()); err == nil && batch.HasAssistant(user.ID.Hex()) {
+			role = controlAssistant
+		}
+	}
+	if role == "" {
+		sendJSONError(w, "Only the class presenter, its assistants or an admin can control this class", http.StatusForbidden)
+		return nil, false
+	}
+
+	liveRoom, exists := h.hub.GetRoom(roomID)
+	if !exists {
+		sendJSONError(w, "Room not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &controlTarget{room: liveRoom, schedule: schedule, user: user, role: role}, true
+}
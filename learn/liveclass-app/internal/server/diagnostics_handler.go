@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/querydiag"
+)
+
+// DiagnosticsHandler reports on the server's database use to admins.
+type DiagnosticsHandler struct {
+	profiler *querydiag.Profiler // nil when slow query logging is disabled
+	analyzer *querydiag.Analyzer
+}
+
+// NewDiagnosticsHandler creates a new DiagnosticsHandler.
+func NewDiagnosticsHandler(profiler *querydiag.Profiler, analyzer *querydiag.Analyzer) *DiagnosticsHandler {
+	return &DiagnosticsHandler{profiler: profiler, analyzer: analyzer}
+}
+
+// Queries lists slow query patterns, slowest in total first, with their plan
+// and a suggested index where one is missing
+// (GET /api/admin/diagnostics/queries). POST explains the slowest patterns
+// now instead of waiting for the next analysis.
+func (h *DiagnosticsHandler) Queries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.profiler == nil {
+		sendJSON(w, map[string]interface{}{"enabled": false, "patterns": []querydiag.Pattern{}}, http.StatusOK)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		h.analyzer.Analyze(r.Context())
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"enabled":     true,
+		"thresholdMs": h.profiler.Threshold().Milliseconds(),
+		"patterns":    h.profiler.Patterns(),
+	}, http.StatusOK)
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Direct message errors
+var (
+	errDMInvalid    = errors.New("invalid direct message")
+	errDMNotAllowed = errors.New("direct messages are only allowed between a batch presenter and its students")
+	errDMDisabled   = errors.New("direct messages are disabled for this batch")
+)
+
+const (
+	defaultConversationLimit = 50
+	maxConversationLimit     = 200
+)
+
+// DirectMessageHandler handles private presenter/student messaging.
+type DirectMessageHandler struct {
+	authService *auth.Service
+	dmRepo      *repository.DirectMessageRepository
+	batchRepo   *repository.BatchRepository
+	userRepo    *repository.UserRepository
+	hub         *room.Hub
+}
+
+// NewDirectMessageHandler creates a new DirectMessageHandler.
+func NewDirectMessageHandler(authService *auth.Service, dmRepo *repository.DirectMessageRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, hub *room.Hub) *DirectMessageHandler {
+	return &DirectMessageHandler{
+		authService: authService,
+		dmRepo:      dmRepo,
+		batchRepo:   batchRepo,
+		userRepo:    userRepo,
+		hub:         hub,
+	}
+}
+
+// Send validates, persists and delivers a direct message. It is shared by the
+// REST endpoint and the WebSocket "dm" message.
+func (h *DirectMessageHandler) Send(ctx context.Context, senderID, batchID, recipientID, body string) (*models.DirectMessage, error) {
+	body = richtext.Chat.Sanitize(strings.TrimSpace(body))
+	if body == "" || len(body) > models.MaxDirectMessageLength || senderID == recipientID {
+		return nil, errDMInvalid
+	}
+
+	sender, err := h.userRepo.FindByID(ctx, senderID)
+	if err != nil {
+		return nil, errDMInvalid
+	}
+	recipient, err := h.userRepo.FindByID(ctx, recipientID)
+	if err != nil {
+		return nil, errDMInvalid
+	}
+	batch, err := h.batchRepo.FindByID(ctx, batchID)
+	if err != nil {
+		return nil, errDMInvalid
+	}
+
+	if !isPresenterStudentPair(batch, sender, recipient) {
+		return nil, errDMNotAllowed
+	}
+
+	// While disabled only the presenter may write (e.g. exam announcements)
+	if batch.DirectMessagesDisabled && sender.ID != batch.PresenterID {
+		return nil, errDMDisabled
+	}
+
+	msg := &models.DirectMessage{
+		BatchID:     batch.ID,
+		SenderID:    sender.ID,
+		RecipientID: recipient.ID,
+		Body:        body,
+	}
+	if err := h.dmRepo.Create(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	resp := msg.ToResponse()
+	resp.SenderName = sender.Name
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "dm",
+		"payload": resp,
+	})
+
+	// Deliver to the recipient and echo to the sender's other sessions
+	h.hub.SendToUser(recipientID, data)
+	h.hub.SendToUser(senderID, data)
+
+	return msg, nil
+}
+
+// MarkRead marks a conversation as read by the reader and notifies the original sender.
+func (h *DirectMessageHandler) MarkRead(ctx context.Context, readerID, batchID, senderID string) (int64, error) {
+	readerObjID, err := primitive.ObjectIDFromHex(readerID)
+	if err != nil {
+		return 0, errDMInvalid
+	}
+	batchObjID, err := primitive.ObjectIDFromHex(batchID)
+	if err != nil {
+		return 0, errDMInvalid
+	}
+	senderObjID, err := primitive.ObjectIDFromHex(senderID)
+	if err != nil {
+		return 0, errDMInvalid
+	}
+
+	updated, err := h.dmRepo.MarkConversationRead(ctx, batchObjID, senderObjID, readerObjID)
+	if err != nil {
+		return 0, err
+	}
+
+	if updated > 0 {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type": "dm-read",
+			"payload": map[string]interface{}{
+				"batchId":  batchID,
+				"readerId": readerID,
+				"count":    updated,
+				"readAt":   time.Now(),
+			},
+		})
+		h.hub.SendToUser(senderID, data)
+	}
+
+	return updated, nil
+}
+
+// SendMessage handles POST /api/messages.
+func (h *DirectMessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BatchID     string `json:"batchId" validate:"required,objectid"`
+		RecipientID string `json:"recipientId" validate:"required,objectid"`
+		Body        string `json:"body" validate:"required,max=4000"` // models.MaxDirectMessageLength
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	msg, err := h.Send(r.Context(), user.ID.Hex(), req.BatchID, req.RecipientID, req.Body)
+	if err != nil {
+		sendDMError(w, err)
+		return
+	}
+
+	sendJSON(w, msg.ToResponse(), http.StatusCreated)
+}
+
+// GetConversation handles GET /api/messages?batchId=&with=&before=&limit=.
+func (h *DirectMessageHandler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	batchID, err := primitive.ObjectIDFromHex(query.Get("batchId"))
+	if err != nil {
+		sendJSONError(w, "Invalid batch ID", http.StatusBadRequest)
+		return
+	}
+	otherID, err := primitive.ObjectIDFromHex(query.Get("with"))
+	if err != nil {
+		sendJSONError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	selfID := user.ID
+
+	var before time.Time
+	if b := query.Get("before"); b != "" {
+		if before, err = time.Parse(time.RFC3339, b); err != nil {
+			sendJSONError(w, "Invalid before timestamp", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := int64(defaultConversationLimit)
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = int64(l)
+		if limit > maxConversationLimit {
+			limit = maxConversationLimit
+		}
+	}
+
+	messages, err := h.dmRepo.FindConversation(r.Context(), batchID, selfID, otherID, before, limit)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch messages", err)
+		return
+	}
+
+	response := make([]models.DirectMessageResponse, len(messages))
+	for i, m := range messages {
+		response[i] = m.ToResponse()
+	}
+
+	sendJSON(w, response, http.StatusOK)
+}
+
+// MarkConversationRead handles POST /api/messages/read.
+func (h *DirectMessageHandler) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BatchID  string `json:"batchId" validate:"required,objectid"`
+		SenderID string `json:"senderId" validate:"required,objectid"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	updated, err := h.MarkRead(r.Context(), user.ID.Hex(), req.BatchID, req.SenderID)
+	if err != nil {
+		sendDMError(w, err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{"updated": updated}, http.StatusOK)
+}
+
+// GetUnreadCounts handles GET /api/messages/unread.
+func (h *DirectMessageHandler) GetUnreadCounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	selfID := user.ID
+
+	counts, err := h.dmRepo.CountUnread(r.Context(), selfID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch unread counts", err)
+		return
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c.Count
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"total":         total,
+		"conversations": counts,
+	}, http.StatusOK)
+}
+
+// UpdateAvailability handles PUT /api/batches/{id}/direct-messages.
+// Only admins and the batch presenter can toggle DM availability.
+func (h *DirectMessageHandler) UpdateAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract batch ID from URL: /api/batches/{id}/direct-messages
+	path := strings.TrimPrefix(r.URL.Path, "/api/batches/")
+	batchID := strings.Split(path, "/")[0]
+
+	batch, err := h.batchRepo.FindByID(r.Context(), batchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the batch presenter can change DM settings", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	batch.DirectMessagesDisabled = !req.Enabled
+	if err := h.batchRepo.Update(r.Context(), batch); err != nil {
+		sendStoreError(w, "Failed to update batch", err)
+		return
+	}
+
+	log.Printf("[DM] Direct messages %s for batch %s by %s",
+		map[bool]string{true: "enabled", false: "disabled"}[req.Enabled], batch.Name, user.Name)
+
+	sendJSON(w, batch.ToResponse(), http.StatusOK)
+}
+
+// isPresenterStudentPair reports whether sender and recipient are the batch
+// presenter and one of its enrolled students, in either direction.
+func isPresenterStudentPair(batch *models.Batch, sender, recipient *models.User) bool {
+	switch {
+	case sender.ID == batch.PresenterID:
+		return batch.HasStudent(recipient.ID.Hex())
+	case recipient.ID == batch.PresenterID:
+		return batch.HasStudent(sender.ID.Hex())
+	default:
+		return false
+	}
+}
+
+// sendDMError maps direct message errors to HTTP responses.
+func sendDMError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errDMInvalid):
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errDMNotAllowed), errors.Is(err, errDMDisabled):
+		sendJSONError(w, err.Error(), http.StatusForbidden)
+	default:
+		sendJSONError(w, "Failed to process message", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,104 @@
+package server_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/testsupport"
+)
+
+// End-to-end tests run the full server against MongoDB and Redis
+// containers, shared by all tests of the package. Without docker they skip,
+// except in CI, where they fail.
+var deps *testsupport.Deps
+
+func TestMain(m *testing.M) {
+	if testsupport.DockerAvailable() {
+		var err error
+		if deps, err = testsupport.StartDeps(context.Background()); err != nil {
+			if testsupport.Required() {
+				log.Fatalf("start dependencies: %v", err)
+			}
+			log.Printf("start dependencies: %v", err)
+		}
+	}
+	code := m.Run()
+	deps.Close()
+	os.Exit(code)
+}
+
+// testContext returns a context for a test's requests, cancelled when it
+// ends or takes too long.
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// liveClass is a class that was started, with a presenter and an enrolled
+// student signed in.
+type liveClass struct {
+	presenter   *testsupport.Client
+	presenterID string
+	student     *testsupport.Client
+	studentID   string
+	scheduleID  string
+	roomID      string
+}
+
+// startClass schedules a class for a new batch and starts it.
+func startClass(t *testing.T, srv *testsupport.Server) *liveClass {
+	t.Helper()
+	return startClassWith(t, srv, nil)
+}
+
+// startClassWith is startClass with setup, if not nil, adjusting the class
+// before it is scheduled.
+func startClassWith(t *testing.T, srv *testsupport.Server, setup func(*testsupport.ScheduleRequest)) *liveClass {
+	t.Helper()
+	ctx := testContext(t)
+
+	presenter, presenterUser := srv.NewUser(t, models.RolePresenter)
+	student, studentUser := srv.NewUser(t, models.RoleStudent)
+
+	batch, err := srv.Admin(t).CreateBatch(ctx, "E2E batch", presenterUser.ID)
+	if err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	if err := presenter.AddStudents(ctx, batch.ID, studentUser.ID); err != nil {
+		t.Fatalf("add student: %v", err)
+	}
+
+	start := time.Now().Add(time.Minute).Truncate(time.Second)
+	req := testsupport.ScheduleRequest{
+		Title:     "E2E class",
+		BatchID:   batch.ID,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+	}
+	if setup != nil {
+		setup(&req)
+	}
+	schedule, err := presenter.CreateSchedule(ctx, req)
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+	roomID, err := presenter.StartClass(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("start class: %v", err)
+	}
+
+	return &liveClass{
+		presenter:   presenter,
+		presenterID: presenterUser.ID,
+		student:     student,
+		studentID:   studentUser.ID,
+		scheduleID:  schedule.ID,
+		roomID:      roomID,
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/middleware"
+	"github.com/jinshatcp/brightline-academy/learn/internal/sse"
+)
+
+// eventsHeartbeat is how often an idle event stream gets a comment, so
+// proxies don't time it out and revoked sessions are noticed.
+const eventsHeartbeat = 25 * time.Second
+
+// EventsHandler streams a user's live events (notifications, messages,
+// session changes) as server-sent events, for clients that can't keep a
+// WebSocket open.
+type EventsHandler struct {
+	authService *auth.Service
+	broker      *sse.Broker
+}
+
+// NewEventsHandler creates a new EventsHandler.
+func NewEventsHandler(authService *auth.Service, broker *sse.Broker) *EventsHandler {
+	return &EventsHandler{
+		authService: authService,
+		broker:      broker,
+	}
+}
+
+// Stream sends the user's events as they happen (GET /api/events/stream).
+// EventSource can't set headers, so the token may be in ?token=. Clients
+// reconnecting send Last-Event-ID (or ?lastEventId=) and get the events they
+// missed; if some can't be replayed, a "resync" event comes first and the
+// client should reload what it shows.
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := middleware.QueryToken(r)
+	_, claims, err := h.authService.Authenticate(r.Context(), token)
+	if err != nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendJSONError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	events, missed, complete, cancel := h.broker.Subscribe(claims.UserID, claims.SessionID, lastEventID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Tell nginx not to buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return // Dropped for falling behind, or the session was signed out
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := h.authService.GetUserFromToken(r.Context(), token); err != nil {
+				log.Printf("[Events] Closing stream of %s: %v", claims.UserID, err)
+				return
+			}
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one server-sent event. The data is a single line of
+// JSON, the same message WebSocket clients get.
+func writeEvent(w http.ResponseWriter, event sse.Event) {
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", event.Data)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxExamEventDetail bounds the detail text a client may attach to an audit event.
+const maxExamEventDetail = 500
+
+// ExamHandler enforces exam mode for live rooms and serves the exam audit trail.
+type ExamHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	auditRepo    *repository.ExamAuditRepository
+}
+
+// NewExamHandler creates a new ExamHandler.
+func NewExamHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, auditRepo *repository.ExamAuditRepository) *ExamHandler {
+	return &ExamHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		auditRepo:    auditRepo,
+	}
+}
+
+// Policy returns the exam policy for a room, or nil if its class isn't in exam mode.
+func (h *ExamHandler) Policy(ctx context.Context, roomID string) *room.ExamPolicy {
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, strings.ToUpper(roomID))
+	if err != nil || !schedule.ExamMode {
+		return nil
+	}
+
+	return &room.ExamPolicy{
+		ScheduleID:        schedule.ID.Hex(),
+		LateEntryDeadline: schedule.LateEntryDeadline(),
+	}
+}
+
+// CanEnter reports whether a student may join an exam. After the late-entry
+// deadline only students who joined before (e.g. after a dropped connection) are let back in.
+func (h *ExamHandler) CanEnter(ctx context.Context, policy *room.ExamPolicy, userID string) bool {
+	if time.Now().Before(policy.LateEntryDeadline) {
+		return true
+	}
+
+	scheduleID, err := primitive.ObjectIDFromHex(policy.ScheduleID)
+	if err != nil {
+		return false
+	}
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return false
+	}
+
+	joined, err := h.auditRepo.HasJoined(ctx, scheduleID, userObjID)
+	if err != nil {
+		log.Printf("[Exam] Failed to check prior joins for %s: %v", userID, err)
+		return false
+	}
+	return joined
+}
+
+// Record adds an event for a participant to the exam audit trail. The write
+// happens in the background so signaling is never blocked on the database.
+func (h *ExamHandler) Record(policy *room.ExamPolicy, p *room.Participant, event, detail, source string) {
+	scheduleID, err := primitive.ObjectIDFromHex(policy.ScheduleID)
+	if err != nil {
+		return
+	}
+
+	if len(detail) > maxExamEventDetail {
+		detail = detail[:maxExamEventDetail]
+	}
+
+	entry := &models.ExamAuditEvent{
+		ScheduleID:    scheduleID,
+		ParticipantID: p.ID,
+		Name:          p.Name,
+		Event:         event,
+		Detail:        detail,
+		Source:        source,
+		At:            time.Now(),
+	}
+	if userID, err := primitive.ObjectIDFromHex(p.UserID); err == nil {
+		entry.UserID = userID
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := h.auditRepo.Record(ctx, entry); err != nil {
+			log.Printf("[Exam] Failed to record %s event for %s: %v", event, p.Name, err)
+		}
+	}()
+}
+
+// GetAuditTrail returns the exam audit trail of a class
+// (GET /api/schedules/{id}/exam-audit). Admin or the class presenter only.
+func (h *ExamHandler) GetAuditTrail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/exam-audit
+	path := strings.TrimPrefix(r.URL.Path, "/api/schedules/")
+	scheduleID := strings.Split(path, "/")[0]
+
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the class presenter can view the exam audit trail", http.StatusForbidden)
+		return
+	}
+
+	events, err := h.auditRepo.FindBySchedule(r.Context(), schedule.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch audit trail", err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"examMode": schedule.ExamMode,
+		"events":   events,
+	}, http.StatusOK)
+}
@@ -0,0 +1,460 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/attendance"
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/export"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/peerreview"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attendance export columns (one row per completed class and enrolled student).
+var attendanceColumns = []export.Column{
+	{Key: "classId", Header: "Class ID"},
+	{Key: "classTitle", Header: "Class"},
+	{Key: "classDate", Header: "Date"},
+	{Key: "classStart", Header: "Start"},
+	{Key: "classEnd", Header: "End"},
+	{Key: "studentId", Header: "Student ID"},
+	{Key: "studentName", Header: "Student Name"},
+	{Key: "studentEmail", Header: "Student Email"},
+	{Key: "attended", Header: "Attended"},
+	{Key: "minutesWatched", Header: "Minutes Watched"},
+}
+
+// Gradebook export columns (one row per enrolled student). A "score:<classId>"
+// column per graded class goes between these and gradebookTotalColumns.
+var gradebookColumns = []export.Column{
+	{Key: "studentId", Header: "Student ID"},
+	{Key: "studentName", Header: "Student Name"},
+	{Key: "studentEmail", Header: "Student Email"},
+	{Key: "status", Header: "Account Status"},
+	{Key: "classesHeld", Header: "Classes Held"},
+}
+
+// Gradebook total columns, over the graded classes.
+var gradebookTotalColumns = []export.Column{
+	{Key: "pointsEarned", Header: "Points Earned"},
+	{Key: "pointsPossible", Header: "Points Possible"},
+	{Key: "percentage", Header: "Percentage"},
+}
+
+// Objective coverage columns (one row per learning objective in the batch's classes).
+var objectiveColumns = []export.Column{
+	{Key: "objectiveId", Header: "Objective ID"},
+	{Key: "objective", Header: "Objective"},
+	{Key: "classesHeld", Header: "Classes Held"},
+	{Key: "classesPlanned", Header: "Classes Planned"},
+	{Key: "lastCovered", Header: "Last Covered"},
+}
+
+// objectiveCoverage tallies the classes that carry one learning objective.
+type objectiveCoverage struct {
+	text        string
+	held        int
+	planned     int
+	lastCovered time.Time
+}
+
+// gradedClass is a completed class whose peer review scores were released,
+// with what each student scored.
+type gradedClass struct {
+	class     models.ScheduledClass
+	maxPoints int
+	scores    map[primitive.ObjectID]float64 // By student; their best hand-in
+}
+
+// ExportHandler handles batch data export endpoints.
+type ExportHandler struct {
+	authService    *auth.Service
+	batchRepo      *repository.BatchRepository
+	scheduleRepo   *repository.ScheduleRepository
+	userRepo       *repository.UserRepository
+	attendanceRepo *repository.AttendanceRepository
+	peerReviewRepo *repository.PeerReviewRepository
+}
+
+// NewExportHandler creates a new ExportHandler.
+func NewExportHandler(authService *auth.Service, batchRepo *repository.BatchRepository, scheduleRepo *repository.ScheduleRepository, userRepo *repository.UserRepository, attendanceRepo *repository.AttendanceRepository, peerReviewRepo *repository.PeerReviewRepository) *ExportHandler {
+	return &ExportHandler{
+		authService:    authService,
+		batchRepo:      batchRepo,
+		scheduleRepo:   scheduleRepo,
+		userRepo:       userRepo,
+		attendanceRepo: attendanceRepo,
+		peerReviewRepo: peerReviewRepo,
+	}
+}
+
+// ExportAttendance streams the batch attendance sheet as CSV
+// (GET /api/batches/{id}/attendance.csv?columns=studentName,classDate).
+// Admin, the batch presenter or its teaching assistants.
+func (h *ExportHandler) ExportAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.authorizeBatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	classes, err := h.completedClasses(r, batch)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch classes", err)
+		return
+	}
+	students := h.batchStudents(r, batch)
+
+	classIDs := make([]primitive.ObjectID, len(classes))
+	for i, class := range classes {
+		classIDs[i] = class.ID
+	}
+	stints, err := h.attendanceRepo.FindBySchedules(r.Context(), classIDs)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch attendance", err)
+		return
+	}
+	byClass := make(map[primitive.ObjectID][]models.Attendance)
+	for _, stint := range stints {
+		byClass[stint.ScheduleID] = append(byClass[stint.ScheduleID], stint)
+	}
+
+	columns := export.SelectColumns(attendanceColumns, export.ParseColumns(r.URL.Query().Get("columns")))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentName(batch.Name, "attendance", "csv"))
+
+	writer := export.NewCSVWriter(w)
+	if err := writer.WriteHeader(columns); err != nil {
+		log.Printf("[Export] Failed to write attendance header: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, class := range classes {
+		summaries := attendance.Summarize(&class, byClass[class.ID], now)
+		for _, student := range students {
+			var watched int64
+			s, attended := summaries[student.ID]
+			if attended {
+				watched = s.WatchedSeconds
+			}
+			record := map[string]string{
+				"classId":        class.ID.Hex(),
+				"classTitle":     class.Title,
+				"classDate":      class.StartTime.Format("2006-01-02"),
+				"classStart":     class.StartTime.Format(time.RFC3339),
+				"classEnd":       class.EndTime.Format(time.RFC3339),
+				"studentId":      student.ID.Hex(),
+				"studentName":    student.Name,
+				"studentEmail":   student.Email,
+				"attended":       strconv.FormatBool(attended),
+				"minutesWatched": strconv.FormatInt(watched/60, 10),
+			}
+			if err := writer.WriteRow(export.Project(columns, record)); err != nil {
+				log.Printf("[Export] Attendance export aborted: %v", err)
+				return
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("[Export] Failed to finish attendance export: %v", err)
+	}
+}
+
+// ExportGradebook streams the batch gradebook as an XLSX workbook
+// (GET /api/batches/{id}/gradebook.xlsx?columns=studentName,studentEmail).
+// Classes are graded by their peer review once its scores are released; a
+// student's score for a class is their best hand-in's, and classes they
+// handed nothing in to count towards the points possible only.
+func (h *ExportHandler) ExportGradebook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.authorizeBatch(w, r, false)
+	if !ok {
+		return
+	}
+
+	classes, err := h.completedClasses(r, batch)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch classes", err)
+		return
+	}
+	graded, err := h.gradedClasses(r, classes)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch scores", err)
+		return
+	}
+	students := h.batchStudents(r, batch)
+
+	available := append([]export.Column{}, gradebookColumns...)
+	possible := 0
+	for _, g := range graded {
+		available = append(available, export.Column{
+			Key:    "score:" + g.class.ID.Hex(),
+			Header: fmt.Sprintf("%s (%s) / %d", g.class.Title, g.class.StartTime.Format("2006-01-02"), g.maxPoints),
+		})
+		possible += g.maxPoints
+	}
+	available = append(available, gradebookTotalColumns...)
+	columns := export.SelectColumns(available, export.ParseColumns(r.URL.Query().Get("columns")))
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", attachmentName(batch.Name, "gradebook", "xlsx"))
+
+	writer, err := export.NewXLSXWriter(w, "Gradebook")
+	if err != nil {
+		log.Printf("[Export] Failed to start gradebook export: %v", err)
+		return
+	}
+	if err := writer.WriteHeader(columns); err != nil {
+		log.Printf("[Export] Failed to write gradebook header: %v", err)
+		return
+	}
+
+	for _, student := range students {
+		record := map[string]string{
+			"studentId":    student.ID.Hex(),
+			"studentName":  student.Name,
+			"studentEmail": student.Email,
+			"status":       string(student.Status),
+			"classesHeld":  strconv.Itoa(len(classes)),
+		}
+		var earned float64
+		for _, g := range graded {
+			if score, ok := g.scores[student.ID]; ok {
+				record["score:"+g.class.ID.Hex()] = formatPoints(score)
+				earned += score
+			}
+		}
+		record["pointsEarned"] = formatPoints(earned)
+		record["pointsPossible"] = strconv.Itoa(possible)
+		if possible > 0 {
+			record["percentage"] = strconv.FormatFloat(earned/float64(possible)*100, 'f', 1, 64)
+		}
+		if err := writer.WriteRow(export.Project(columns, record)); err != nil {
+			log.Printf("[Export] Gradebook export aborted: %v", err)
+			return
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("[Export] Failed to finish gradebook export: %v", err)
+	}
+}
+
+// ExportObjectives streams learning objective coverage for the batch as CSV
+// (GET /api/batches/{id}/objectives.csv?columns=objective,classesHeld). Objectives
+// come from classes scheduled from templates; cancelled classes don't count.
+// Admin, the batch presenter or its teaching assistants.
+func (h *ExportHandler) ExportObjectives(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.authorizeBatch(w, r, true)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	schedules, err := h.scheduleRepo.FindByBatch(r.Context(), batch.ID.Hex(), time.Time{}, now.AddDate(1, 0, 0))
+	if err != nil {
+		sendStoreError(w, "Failed to fetch classes", err)
+		return
+	}
+
+	// Tally in first-seen order so the sheet follows the course
+	var order []string
+	coverage := make(map[string]*objectiveCoverage)
+	for _, class := range schedules {
+		status := class.EffectiveStatusAt(now)
+		if status == models.ClassStatusCancelled {
+			continue
+		}
+		for _, objective := range class.Objectives {
+			c, ok := coverage[objective.ID]
+			if !ok {
+				c = &objectiveCoverage{}
+				coverage[objective.ID] = c
+				order = append(order, objective.ID)
+			}
+			c.text = objective.Text // Latest wording wins
+			if status == models.ClassStatusCompleted {
+				c.held++
+				c.lastCovered = class.StartTime
+			} else {
+				c.planned++
+			}
+		}
+	}
+
+	columns := export.SelectColumns(objectiveColumns, export.ParseColumns(r.URL.Query().Get("columns")))
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", attachmentName(batch.Name, "objectives", "csv"))
+
+	writer := export.NewCSVWriter(w)
+	if err := writer.WriteHeader(columns); err != nil {
+		log.Printf("[Export] Failed to write objectives header: %v", err)
+		return
+	}
+
+	for _, id := range order {
+		c := coverage[id]
+		var lastCovered string
+		if !c.lastCovered.IsZero() {
+			lastCovered = c.lastCovered.Format("2006-01-02")
+		}
+		record := map[string]string{
+			"objectiveId":    id,
+			"objective":      c.text,
+			"classesHeld":    strconv.Itoa(c.held),
+			"classesPlanned": strconv.Itoa(c.planned),
+			"lastCovered":    lastCovered,
+		}
+		if err := writer.WriteRow(export.Project(columns, record)); err != nil {
+			log.Printf("[Export] Objectives export aborted: %v", err)
+			return
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("[Export] Failed to finish objectives export: %v", err)
+	}
+}
+
+// authorizeBatch loads the batch from the URL and verifies the caller is an
+// admin or the batch's presenter (or, with allowAssistants, one of its teaching
+// assistants). It writes the error response on failure.
+func (h *ExportHandler) authorizeBatch(w http.ResponseWriter, r *http.Request, allowAssistants bool) (*models.Batch, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	// Extract batch ID from URL: /api/batches/{id}/attendance.csv
+	path := strings.TrimPrefix(r.URL.Path, "/api/batches/")
+	batchID := strings.Split(path, "/")[0]
+
+	batch, err := h.batchRepo.FindByID(r.Context(), batchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID &&
+		!(allowAssistants && batch.HasAssistant(user.ID.Hex())) {
+		sendJSONError(w, "Only admin or the batch presenter can export data", http.StatusForbidden)
+		return nil, false
+	}
+
+	return batch, true
+}
+
+// completedClasses returns the batch's classes that have finished, oldest first.
+func (h *ExportHandler) completedClasses(r *http.Request, batch *models.Batch) ([]models.ScheduledClass, error) {
+	schedules, err := h.scheduleRepo.FindByBatch(r.Context(), batch.ID.Hex(), time.Time{}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	completed := make([]models.ScheduledClass, 0, len(schedules))
+	for _, s := range schedules {
+		if s.EffectiveStatus() == models.ClassStatusCompleted {
+			completed = append(completed, s)
+		}
+	}
+	return completed, nil
+}
+
+// gradedClasses returns the classes, in order, whose peer review scores
+// were released.
+func (h *ExportHandler) gradedClasses(r *http.Request, classes []models.ScheduledClass) ([]gradedClass, error) {
+	var graded []gradedClass
+	for _, class := range classes {
+		round, err := h.peerReviewRepo.FindRound(r.Context(), class.ID)
+		if errors.Is(err, repository.ErrPeerReviewNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if round.Status != models.PeerReviewReleased {
+			continue
+		}
+
+		reviews, err := h.peerReviewRepo.FindReviews(r.Context(), round.ID)
+		if err != nil {
+			return nil, err
+		}
+		authors := make(map[primitive.ObjectID]primitive.ObjectID, len(reviews))
+		for _, review := range reviews {
+			authors[review.HandInID] = review.AuthorID
+		}
+
+		g := gradedClass{class: class, maxPoints: round.MaxPoints(), scores: make(map[primitive.ObjectID]float64)}
+		for handInID, score := range peerreview.Scores(round, reviews) {
+			if score.Reviews == 0 {
+				continue // Nobody's review of it counted
+			}
+			student := authors[handInID]
+			if best, ok := g.scores[student]; !ok || score.Total > best {
+				g.scores[student] = score.Total
+			}
+		}
+		graded = append(graded, g)
+	}
+	return graded, nil
+}
+
+// formatPoints formats a score without trailing zeros, e.g. "7.5".
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(points, 'f', -1, 64)
+}
+
+// batchStudents resolves the batch's enrolled students, skipping deleted accounts.
+func (h *ExportHandler) batchStudents(r *http.Request, batch *models.Batch) []*models.User {
+	students := make([]*models.User, 0, len(batch.StudentIDs))
+	for _, id := range batch.StudentIDs {
+		if student, err := h.userRepo.FindByID(r.Context(), id.Hex()); err == nil {
+			students = append(students, student)
+		}
+	}
+	return students
+}
+
+// attachmentName builds a Content-Disposition value for an export file.
+func attachmentName(batchName, kind, ext string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == ' ':
+			return '_'
+		default:
+			return -1
+		}
+	}, batchName)
+	if safe == "" {
+		safe = "batch"
+	}
+	return fmt.Sprintf("attachment; filename=\"%s_%s_%s.%s\"", safe, kind, time.Now().Format("20060102"), ext)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// maxFields bounds the fields a request can select.
+const maxFields = 50
+
+// requestedFields returns the fields selected with ?fields=id,title,startTime,
+// or nil if the request doesn't select any.
+func requestedFields(r *http.Request) map[string]bool {
+	param := r.URL.Query().Get("fields")
+	if param == "" {
+		return nil
+	}
+
+	fields := make(map[string]bool)
+	for _, name := range strings.Split(param, ",") {
+		if name = strings.TrimSpace(name); name != "" && len(fields) < maxFields {
+			fields[name] = true
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// sendJSONFields sends a JSON response like sendJSON, keeping only the
+// fields the request selects with ?fields= (sparse fieldsets), so clients
+// such as mobile apps can ask for just what they show. The fields apply to
+// the object sent, or to each object of a list; names are the JSON ones.
+// Without ?fields= everything is sent.
+func sendJSONFields(w http.ResponseWriter, r *http.Request, data interface{}, status int) {
+	fields := requestedFields(r)
+	if fields == nil {
+		sendJSON(w, data, status)
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[Handler] Failed to encode response for %s: %v", r.URL.Path, err)
+		sendStoreError(w, "Failed to encode response", err)
+		return
+	}
+
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for i := range list {
+			list[i] = selectFields(list[i], fields)
+		}
+		sendJSON(w, list, status)
+		return
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err == nil && object != nil {
+		sendJSON(w, selectFields(object, fields), status)
+		return
+	}
+
+	// Neither an object nor a list of them: nothing to select from
+	sendJSON(w, json.RawMessage(raw), status)
+}
+
+// selectFields returns the fields of object that are selected.
+func selectFields(object map[string]json.RawMessage, fields map[string]bool) map[string]json.RawMessage {
+	selected := make(map[string]json.RawMessage, len(fields))
+	for name, value := range object {
+		if fields[name] {
+			selected[name] = value
+		}
+	}
+	return selected
+}
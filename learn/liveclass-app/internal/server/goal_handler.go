@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// streakLookbackWeeks bounds how far back goal streaks are counted.
+const streakLookbackWeeks = 52
+
+// GoalHandler handles student weekly goals and records the activity they are
+// measured against.
+type GoalHandler struct {
+	authService  *auth.Service
+	goalRepo     *repository.GoalRepository
+	scheduleRepo *repository.ScheduleRepository
+}
+
+// NewGoalHandler creates a new GoalHandler.
+func NewGoalHandler(authService *auth.Service, goalRepo *repository.GoalRepository, scheduleRepo *repository.ScheduleRepository) *GoalHandler {
+	return &GoalHandler{
+		authService:  authService,
+		goalRepo:     goalRepo,
+		scheduleRepo: scheduleRepo,
+	}
+}
+
+// Dashboard returns the student's goals with this week's progress and streaks
+// (GET /api/goals).
+func (h *GoalHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.student(w, r)
+	if !ok {
+		return
+	}
+
+	goals, err := h.goalRepo.FindGoals(r.Context(), user.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch goals", err)
+		return
+	}
+
+	thisWeek := models.WeekStart(time.Now())
+	since := thisWeek.AddDate(0, 0, -7*streakLookbackWeeks)
+
+	statuses := make([]models.GoalStatus, 0, len(goals))
+	for _, goal := range goals {
+		counts, err := h.goalRepo.WeeklyCounts(r.Context(), user.ID, goal.Kind.Activity(), since)
+		if err != nil {
+			sendStoreError(w, "Failed to compute goal progress", err)
+			return
+		}
+		statuses = append(statuses, goalStatus(goal, counts, thisWeek))
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"weekStart": thisWeek,
+		"goals":     statuses,
+	}, http.StatusOK)
+}
+
+// Goal sets (PUT {"target": n}) or removes (DELETE) the student's weekly goal
+// of one kind (/api/goals/{kind}).
+func (h *GoalHandler) Goal(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.student(w, r)
+	if !ok {
+		return
+	}
+
+	kind := models.GoalKind(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/goals/"), "/"))
+	if kind.Activity() == "" {
+		sendJSONError(w, "Invalid goal. Must be: attend-classes or watch-recordings", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Target int `json:"target" validate:"min=1,max=50"` // models.MaxGoalTarget
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		goal, err := h.goalRepo.SetGoal(r.Context(), user.ID, kind, req.Target)
+		if err != nil {
+			sendStoreError(w, "Failed to save goal", err)
+			return
+		}
+		sendJSON(w, goal, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := h.goalRepo.DeleteGoal(r.Context(), user.ID, kind); err != nil {
+			if errors.Is(err, repository.ErrGoalNotFound) {
+				sendJSONError(w, "Goal not found", http.StatusNotFound)
+				return
+			}
+			sendJSONError(w, "Failed to delete goal", http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, map[string]string{"message": "Goal removed"}, http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RecordAttendance records that a student joined the class running in a room.
+// Rooms that aren't tied to a scheduled class are ignored.
+func (h *GoalHandler) RecordAttendance(roomID, userID string) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil || roomID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, strings.ToUpper(roomID))
+	if err != nil {
+		return
+	}
+	if err := h.goalRepo.RecordActivity(ctx, objectID, models.ActivityAttended, schedule.ID.Hex(), time.Now()); err != nil {
+		log.Printf("[Goals] Failed to record attendance for %s: %v", userID, err)
+	}
+}
+
+// RecordWatch records that a student completed a recording.
+func (h *GoalHandler) RecordWatch(ctx context.Context, user *models.User, recordingID string) {
+	if user.Role != models.RoleStudent {
+		return
+	}
+	if err := h.goalRepo.RecordActivity(ctx, user.ID, models.ActivityWatched, recordingID, time.Now()); err != nil {
+		log.Printf("[Goals] Failed to record watch for %s: %v", user.ID.Hex(), err)
+	}
+}
+
+// student authenticates the request and checks the caller is a student. It
+// writes the error response on failure.
+func (h *GoalHandler) student(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if user.Role != models.RoleStudent {
+		sendJSONError(w, "Goals are only available to students", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+// goalStatus computes this week's progress and the streak of consecutive weeks
+// the goal was met. An unfinished week only extends the streak once it's met.
+// Past weeks are judged against the current target.
+func goalStatus(goal models.Goal, counts map[time.Time]int, thisWeek time.Time) models.GoalStatus {
+	status := models.GoalStatus{
+		Kind:     goal.Kind,
+		Target:   goal.Target,
+		Progress: counts[thisWeek],
+	}
+	status.Met = status.Progress >= goal.Target
+
+	week := thisWeek
+	if !status.Met {
+		week = week.AddDate(0, 0, -7)
+	}
+	for i := 0; i < streakLookbackWeeks && counts[week] >= goal.Target; i++ {
+		status.Streak++
+		week = week.AddDate(0, 0, -7)
+	}
+	return status
+}
@@ -0,0 +1,371 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxBatchGroups bounds the groups of a batch, which are stored with it.
+const maxBatchGroups = 50
+
+// groupResponse is a batch group as the API returns it. Students only get
+// the groups they're in, without the other members.
+type groupResponse struct {
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	StudentCount int                   `json:"studentCount"`
+	Students     []models.UserResponse `json:"students,omitempty"`
+	CreatedAt    time.Time             `json:"createdAt"`
+}
+
+// GroupHandler manages the groups of a batch, such as teams or lab
+// sections. Notes can be targeted at groups, so only their members among
+// the batch's students see them.
+type GroupHandler struct {
+	authService *auth.Service
+	batchRepo   *repository.BatchRepository
+	userRepo    *repository.UserRepository
+}
+
+// NewGroupHandler creates a new GroupHandler.
+func NewGroupHandler(authService *auth.Service, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository) *GroupHandler {
+	return &GroupHandler{
+		authService: authService,
+		batchRepo:   batchRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// ListGroups returns a batch's groups (GET /api/batches/{id}/groups).
+// Admin, the batch presenter and its assistants get every group with its
+// members; students get the groups they're in.
+func (h *GroupHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, batch, ok := h.loadBatch(w, r)
+	if !ok {
+		return
+	}
+	staff := user.Role == models.RoleAdmin || batch.PresenterID == user.ID || batch.HasAssistant(user.ID.Hex())
+	if !staff && !batch.HasStudent(user.ID.Hex()) {
+		sendJSONError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	groups := make([]groupResponse, 0, len(batch.Groups))
+	for _, g := range batch.Groups {
+		if !staff && !g.HasStudent(user.ID) {
+			continue
+		}
+		resp := groupResponse{
+			ID:           g.ID.Hex(),
+			Name:         g.Name,
+			StudentCount: len(g.StudentIDs),
+			CreatedAt:    g.CreatedAt,
+		}
+		if staff {
+			resp.Students = make([]models.UserResponse, 0, len(g.StudentIDs))
+			for _, id := range g.StudentIDs {
+				if student, err := h.userRepo.FindByID(r.Context(), id.Hex()); err == nil {
+					resp.Students = append(resp.Students, student.ToResponse())
+				}
+			}
+		}
+		groups = append(groups, resp)
+	}
+
+	sendJSON(w, groups, http.StatusOK)
+}
+
+// CreateGroup adds a group to a batch (POST /api/batches/{id}/groups
+// {"name", "studentIds"}). Members must be students of the batch.
+// Admin or the batch presenter only.
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.loadManagedBatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name       string   `json:"name" validate:"required,max=100"`
+		StudentIDs []string `json:"studentIds" validate:"max=500,objectid"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		sendJSONError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Groups) >= maxBatchGroups {
+		sendJSONError(w, "A batch can have at most 50 groups", http.StatusConflict)
+		return
+	}
+	if groupNameTaken(batch, name, primitive.NilObjectID) {
+		sendJSONError(w, "A group with this name already exists", http.StatusConflict)
+		return
+	}
+	students, ok := batchStudents(w, batch, req.StudentIDs)
+	if !ok {
+		return
+	}
+
+	group := &models.BatchGroup{Name: name, StudentIDs: students}
+	if err := h.batchRepo.AddGroup(r.Context(), batch.ID.Hex(), group); err != nil {
+		sendStoreError(w, "Failed to create group", err)
+		return
+	}
+
+	sendJSON(w, groupResponse{
+		ID:           group.ID.Hex(),
+		Name:         group.Name,
+		StudentCount: len(group.StudentIDs),
+		CreatedAt:    group.CreatedAt,
+	}, http.StatusCreated)
+}
+
+// RenameGroup renames a group (PUT /api/batches/{id}/groups/{groupId}
+// {"name"}). Admin or the batch presenter only.
+func (h *GroupHandler) RenameGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.loadManagedBatch(w, r)
+	if !ok {
+		return
+	}
+	group, ok := groupFromPath(w, r, batch)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" validate:"required,max=100"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		sendJSONError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if groupNameTaken(batch, name, group.ID) {
+		sendJSONError(w, "A group with this name already exists", http.StatusConflict)
+		return
+	}
+
+	if err := h.batchRepo.RenameGroup(r.Context(), batch.ID.Hex(), group.ID, name); err != nil {
+		sendGroupError(w, "Failed to rename group", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Group renamed successfully"}, http.StatusOK)
+}
+
+// DeleteGroup removes a group (DELETE /api/batches/{id}/groups/{groupId}).
+// Notes targeted only at it are then seen by staff alone until retargeted.
+// Admin or the batch presenter only.
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.loadManagedBatch(w, r)
+	if !ok {
+		return
+	}
+	group, ok := groupFromPath(w, r, batch)
+	if !ok {
+		return
+	}
+
+	if err := h.batchRepo.DeleteGroup(r.Context(), batch.ID.Hex(), group.ID); err != nil {
+		sendGroupError(w, "Failed to delete group", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Group deleted successfully"}, http.StatusOK)
+}
+
+// AddMembers adds students of the batch to a group (POST
+// /api/batches/{id}/groups/{groupId}/members {"studentIds"}). Students can
+// be in several groups. Admin or the batch presenter only.
+func (h *GroupHandler) AddMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.loadManagedBatch(w, r)
+	if !ok {
+		return
+	}
+	group, ok := groupFromPath(w, r, batch)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		StudentIDs []string `json:"studentIds" validate:"required,max=500,objectid"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	students, ok := batchStudents(w, batch, req.StudentIDs)
+	if !ok {
+		return
+	}
+
+	if err := h.batchRepo.AddGroupMembers(r.Context(), batch.ID.Hex(), group.ID, students); err != nil {
+		sendGroupError(w, "Failed to add members", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Members added successfully"}, http.StatusOK)
+}
+
+// RemoveMember removes a student from a group (DELETE
+// /api/batches/{id}/groups/{groupId}/members/{studentId}). Admin or the
+// batch presenter only.
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	batch, ok := h.loadManagedBatch(w, r)
+	if !ok {
+		return
+	}
+	group, ok := groupFromPath(w, r, batch)
+	if !ok {
+		return
+	}
+
+	// Extract student ID from URL: /api/batches/{id}/groups/{groupId}/members/{studentId}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/batches/"), "/")
+	if len(parts) < 5 {
+		sendJSONError(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	studentID, err := primitive.ObjectIDFromHex(parts[4])
+	if err != nil {
+		sendJSONError(w, "Invalid student ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.batchRepo.RemoveGroupMember(r.Context(), batch.ID.Hex(), group.ID, studentID); err != nil {
+		sendGroupError(w, "Failed to remove member", err)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Member removed successfully"}, http.StatusOK)
+}
+
+// loadBatch authenticates the request and loads the batch from /api/batches/{id}/groups.
+func (h *GroupHandler) loadBatch(w http.ResponseWriter, r *http.Request) (*models.User, *models.Batch, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/batches/")
+	batchID := strings.Split(path, "/")[0]
+
+	batch, err := h.batchRepo.FindByID(r.Context(), batchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return user, batch, true
+}
+
+// loadManagedBatch is loadBatch for admin or the batch presenter only.
+func (h *GroupHandler) loadManagedBatch(w http.ResponseWriter, r *http.Request) (*models.Batch, bool) {
+	user, batch, ok := h.loadBatch(w, r)
+	if !ok {
+		return nil, false
+	}
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the batch presenter can manage groups", http.StatusForbidden)
+		return nil, false
+	}
+	return batch, true
+}
+
+// groupFromPath returns the batch's group named in the URL
+// (/api/batches/{id}/groups/{groupId}/...), or sends a 404 response.
+func groupFromPath(w http.ResponseWriter, r *http.Request, batch *models.Batch) (*models.BatchGroup, bool) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/batches/"), "/")
+	if len(parts) < 3 {
+		sendJSONError(w, "Invalid URL", http.StatusBadRequest)
+		return nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		sendJSONError(w, "Group not found", http.StatusNotFound)
+		return nil, false
+	}
+	group := batch.Group(id)
+	if group == nil {
+		sendJSONError(w, "Group not found", http.StatusNotFound)
+		return nil, false
+	}
+	return group, true
+}
+
+// batchStudents converts student IDs, sending a 400 response if one isn't
+// a student of the batch.
+func batchStudents(w http.ResponseWriter, batch *models.Batch, ids []string) ([]primitive.ObjectID, bool) {
+	students := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if !batch.HasStudent(id) {
+			sendJSONError(w, "Not a student of this batch: "+id, http.StatusBadRequest)
+			return nil, false
+		}
+		oid, _ := primitive.ObjectIDFromHex(id)
+		students = append(students, oid)
+	}
+	return students, true
+}
+
+// groupNameTaken reports whether another group of the batch has the name,
+// ignoring case.
+func groupNameTaken(batch *models.Batch, name string, except primitive.ObjectID) bool {
+	for _, g := range batch.Groups {
+		if g.ID != except && strings.EqualFold(g.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendGroupError sends a 404 for a group removed meanwhile, or a store error.
+func sendGroupError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		sendJSONError(w, "Group not found", http.StatusNotFound)
+		return
+	}
+	sendStoreError(w, message, err)
+}
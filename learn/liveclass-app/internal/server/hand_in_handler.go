@@ -0,0 +1,359 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/filetype"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxHandInsPerStudent bounds how many images a student can hand in to one
+// class.
+const maxHandInsPerStudent = 20
+
+// handInItem is a hand-in as sent to clients, with the URL of its image.
+type handInItem struct {
+	models.HandIn
+	URL string `json:"url"` // Needs the caller's login token
+}
+
+// HandInHandler lets students hand in a photo of their work during a live
+// class. The presenter sees submissions as they arrive and can spotlight one
+// to everyone in the room; hand-ins stay with the class for grading.
+type HandInHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	handInRepo   *repository.HandInRepository
+	hub          *room.Hub
+	files        *encryption.Encryptor // nil stores files in plaintext
+	storagePath  string
+	maxSize      int64
+}
+
+// NewHandInHandler creates a new HandInHandler accepting images of up to
+// maxSize bytes.
+func NewHandInHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, handInRepo *repository.HandInRepository, hub *room.Hub, files *encryption.Encryptor, storagePath string, maxSize int64) *HandInHandler {
+	return &HandInHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		handInRepo:   handInRepo,
+		hub:          hub,
+		files:        files,
+		storagePath:  storagePath,
+		maxSize:      maxSize,
+	}
+}
+
+// HandIns routes /api/schedules/{id}/hand-ins[/{handInId}[/spotlight]]:
+//
+//	POST   .../hand-ins                      a student hands in an image
+//	GET    .../hand-ins                      the class's hand-ins
+//	GET    .../hand-ins/{handInId}           a hand-in's image
+//	POST   .../hand-ins/{handInId}/spotlight show a hand-in to the room
+//	DELETE .../hand-ins/spotlight            clear the spotlight
+//
+// The presenter, the batch's assistants and admins see every hand-in;
+// students see their own and the one in the spotlight.
+func (h *HandInHandler) HandIns(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), parts[0])
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendStoreError(w, "Batch not found", err)
+		return
+	}
+
+	teaches := user.Role == models.RoleAdmin || schedule.PresenterID == user.ID || batch.HasAssistant(user.ID.Hex())
+	if !teaches && !batch.HasStudent(user.ID.Hex()) {
+		sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+		return
+	}
+
+	// /api/schedules/{id}/hand-ins/{handInId}/{action}
+	var handInID, action string
+	if len(parts) > 2 {
+		handInID = parts[2]
+	}
+	if len(parts) > 3 {
+		action = parts[3]
+	}
+
+	switch {
+	case handInID == "":
+		switch r.Method {
+		case http.MethodGet:
+			h.list(w, r, user, schedule, teaches)
+		case http.MethodPost:
+			if !batch.HasStudent(user.ID.Hex()) {
+				sendJSONError(w, "Only students in the batch can hand in work", http.StatusForbidden)
+				return
+			}
+			h.submit(w, r, user, schedule)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case handInID == "spotlight" && action == "":
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !teaches {
+			sendJSONError(w, "Only the presenter can spotlight hand-ins", http.StatusForbidden)
+			return
+		}
+		h.spotlight(w, r, schedule, nil)
+	case action == "spotlight":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !teaches {
+			sendJSONError(w, "Only the presenter can spotlight hand-ins", http.StatusForbidden)
+			return
+		}
+		handIn, ok := h.find(w, r, schedule, handInID)
+		if !ok {
+			return
+		}
+		h.spotlight(w, r, schedule, handIn)
+	case action == "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handIn, ok := h.find(w, r, schedule, handInID)
+		if !ok {
+			return
+		}
+		if !teaches && handIn.StudentID != user.ID && !handIn.Spotlighted {
+			sendJSONError(w, "You can't view this hand-in", http.StatusForbidden)
+			return
+		}
+		h.serveImage(w, r, handIn)
+	default:
+		sendJSONError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// list sends the hand-ins of a class the caller can see.
+func (h *HandInHandler) list(w http.ResponseWriter, r *http.Request, user *models.User, schedule *models.ScheduledClass, teaches bool) {
+	handIns, err := h.handInRepo.FindBySchedule(r.Context(), schedule.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch hand-ins", err)
+		return
+	}
+
+	items := make([]handInItem, 0, len(handIns))
+	for _, handIn := range handIns {
+		if teaches || handIn.StudentID == user.ID || handIn.Spotlighted {
+			items = append(items, h.item(handIn))
+		}
+	}
+	sendJSON(w, map[string]interface{}{"handIns": items}, http.StatusOK)
+}
+
+// submit stores an image handed in by a student (multipart with "file" and
+// an optional "caption") and sends it to the presenter.
+func (h *HandInHandler) submit(w http.ResponseWriter, r *http.Request, user *models.User, schedule *models.ScheduledClass) {
+	if schedule.EffectiveStatusAt(time.Now()) != models.ClassStatusLive || schedule.RoomID == "" {
+		sendJSONError(w, "Work can only be handed in while the class is live", http.StatusConflict)
+		return
+	}
+	liveRoom, ok := h.hub.GetRoom(strings.ToUpper(schedule.RoomID))
+	if !ok {
+		sendJSONError(w, "The class room isn't open", http.StatusConflict)
+		return
+	}
+
+	count, err := h.handInRepo.CountByStudent(r.Context(), schedule.ID, user.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to save hand-in", err)
+		return
+	}
+	if count >= maxHandInsPerStudent {
+		sendJSONError(w, "You've handed in as many images as allowed for this class", http.StatusTooManyRequests)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+1<<10)
+	if err := r.ParseMultipartForm(h.maxSize); err != nil {
+		sendJSONError(w, "Image too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	form := struct {
+		Caption string `json:"caption" validate:"max=200"`
+	}{strings.TrimSpace(r.FormValue("caption"))}
+	if !checkRequest(w, &form) {
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		sendJSONError(w, "No image uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Validate the type by content; the client's Content-Type isn't trusted
+	kind, err := filetype.Photos.Check(file, header.Size, header.Filename, header.Header.Get("Content-Type"))
+	if err != nil {
+		sendJSONError(w, "Image must be JPEG, PNG or WebP", http.StatusBadRequest)
+		return
+	}
+
+	// Stored under the class, for grading afterwards
+	dir := filepath.Join(h.storagePath, "hand-ins", schedule.ID.Hex())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("[HandIns] Failed to create directory: %v", err)
+		sendStoreError(w, "Failed to save hand-in", err)
+		return
+	}
+	filePath := filepath.Join(dir, user.ID.Hex()+"_"+time.Now().Format("20060102_150405.000")+kind.Ext)
+
+	dst, err := h.files.Create(r.Context(), filePath)
+	if err != nil {
+		log.Printf("[HandIns] Failed to create file: %v", err)
+		sendStoreError(w, "Failed to save hand-in", err)
+		return
+	}
+	size, err := io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("[HandIns] Failed to save image: %v", err)
+		os.Remove(filePath)
+		sendJSONError(w, "Failed to save hand-in", http.StatusInternalServerError)
+		return
+	}
+
+	handIn := &models.HandIn{
+		ScheduleID:  schedule.ID,
+		BatchID:     schedule.BatchID,
+		StudentID:   user.ID,
+		StudentName: user.Name,
+		Caption:     form.Caption,
+		FilePath:    filePath,
+		FileSize:    size,
+		MimeType:    kind.MimeType,
+	}
+	if err := h.handInRepo.Create(r.Context(), handIn); err != nil {
+		log.Printf("[HandIns] Failed to create hand-in record: %v", err)
+		os.Remove(filePath)
+		sendJSONError(w, "Failed to save hand-in", http.StatusInternalServerError)
+		return
+	}
+
+	item := h.item(*handIn)
+	if data, err := json.Marshal(map[string]interface{}{"type": "hand-in", "handIn": item}); err == nil {
+		if !liveRoom.SendToPresenter(data) {
+			log.Printf("[HandIns] No presenter in room %s; hand-in from %s kept for later", liveRoom.ID, user.Name)
+		}
+	}
+	log.Printf("[HandIns] %s handed in %s in room %s", user.Name, handIn.ID.Hex(), liveRoom.ID)
+
+	sendJSON(w, item, http.StatusCreated)
+}
+
+// spotlight shows a hand-in to everyone in the class's room, or clears the
+// spotlight when handIn is nil.
+func (h *HandInHandler) spotlight(w http.ResponseWriter, r *http.Request, schedule *models.ScheduledClass, handIn *models.HandIn) {
+	if schedule.EffectiveStatusAt(time.Now()) != models.ClassStatusLive || schedule.RoomID == "" {
+		sendJSONError(w, "Hand-ins can only be spotlighted while the class is live", http.StatusConflict)
+		return
+	}
+	liveRoom, ok := h.hub.GetRoom(strings.ToUpper(schedule.RoomID))
+	if !ok {
+		sendJSONError(w, "The class room isn't open", http.StatusConflict)
+		return
+	}
+
+	var id *primitive.ObjectID
+	var item *handInItem
+	if handIn != nil {
+		handIn.Spotlighted = true
+		spotlit := h.item(*handIn)
+		id, item = &handIn.ID, &spotlit
+	}
+	if err := h.handInRepo.Spotlight(r.Context(), schedule.ID, id); err != nil {
+		log.Printf("[HandIns] Failed to update spotlight for class %s: %v", schedule.ID.Hex(), err)
+		sendStoreError(w, "Failed to update spotlight", err)
+		return
+	}
+
+	// A null hand-in clears the spotlight on viewers
+	liveRoom.BroadcastToAll(map[string]interface{}{
+		"type":   "hand-in-spotlight",
+		"handIn": item,
+	}, "")
+
+	sendJSON(w, map[string]interface{}{"handIn": item}, http.StatusOK)
+}
+
+// find loads a hand-in of a class by the ID in the URL.
+func (h *HandInHandler) find(w http.ResponseWriter, r *http.Request, schedule *models.ScheduledClass, handInID string) (*models.HandIn, bool) {
+	id, err := primitive.ObjectIDFromHex(handInID)
+	if err != nil {
+		sendJSONError(w, "Invalid hand-in ID", http.StatusBadRequest)
+		return nil, false
+	}
+	handIn, err := h.handInRepo.FindByID(r.Context(), schedule.ID, id)
+	if err != nil {
+		sendJSONError(w, "Hand-in not found", http.StatusNotFound)
+		return nil, false
+	}
+	return handIn, true
+}
+
+// serveImage sends a hand-in's image, decrypting it if it is encrypted.
+func (h *HandInHandler) serveImage(w http.ResponseWriter, r *http.Request, handIn *models.HandIn) {
+	file, err := h.files.Open(r.Context(), handIn.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		sendJSONError(w, "Image not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[HandIns] Failed to open %s: %v", handIn.FilePath, err)
+		sendStoreError(w, "Failed to open image", err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", handIn.MimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(file.Size(), 10))
+	w.Header().Set("Cache-Control", "private, no-store")
+	io.Copy(w, file)
+}
+
+// item returns a hand-in with the URL of its image.
+func (h *HandInHandler) item(handIn models.HandIn) handInItem {
+	return handInItem{
+		HandIn: handIn,
+		URL:    "/api/schedules/" + handIn.ScheduleID.Hex() + "/hand-ins/" + handIn.ID.Hex(),
+	}
+}
@@ -1,13 +1,22 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/jinshatcp/brightline-academy/learn/internal/analytics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/captions"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chatlog"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
 	"github.com/jinshatcp/brightline-academy/learn/internal/room"
 	"github.com/jinshatcp/brightline-academy/learn/internal/rtc"
 	"github.com/pion/webrtc/v3"
@@ -19,40 +28,155 @@ type Message struct {
 	RoomID      string          `json:"roomId,omitempty"`
 	Name        string          `json:"name,omitempty"`
 	IsPresenter bool            `json:"isPresenter,omitempty"`
+	Token       string          `json:"token,omitempty"`
+	RoomToken   string          `json:"roomToken,omitempty"` // Issued in "joined"; required on later messages when enforced
+	TakeOver    bool            `json:"takeOver,omitempty"`  // Presenter join: move presenting here from the account's other device
+	Acks        bool            `json:"acks,omitempty"`      // Join: the client acknowledges critical messages
+	Seq         uint64          `json:"seq,omitempty"`       // Number of a critical message, or the one acknowledged
 	Payload     json.RawMessage `json:"payload,omitempty"`
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+// newUpgrader creates the WebSocket upgrader. With compression enabled,
+// permessage-deflate is accepted when the client offers it.
+func newUpgrader(enableCompression bool) websocket.Upgrader {
+	return websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true // Allow all origins for development
+		},
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: enableCompression,
+	}
+}
+
+// offersDeflate reports whether the client offered the permessage-deflate extension.
+func offersDeflate(r *http.Request) bool {
+	for _, ext := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range strings.Split(ext, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(offer), ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
+// Caption limits
+const (
+	maxCaptionLength        = 1000
+	captionTranslateTimeout = 5 * time.Second
+)
+
 // Handler handles WebSocket connections and signaling.
 type Handler struct {
-	hub        *room.Hub
-	rtcService *rtc.Service
+	hub            *room.Hub
+	rtcService     *rtc.Service
+	authService    *auth.Service
+	dmHandler      *DirectMessageHandler
+	examHandler    *ExamHandler
+	verifications  *VerificationHandler
+	lobbies        *LobbyHandler
+	assistants     *AssistantHandler
+	goals          *GoalHandler
+	consent        *ConsentHandler
+	liveRecordings *LiveRecordingHandler
+	coWatch        *CoWatchHandler
+	chatLog        *chatlog.Recorder
+	analytics      *analytics.Exporter
+	captions       *captions.Service
+	ice            *ICEHandler
+	presenterGrace time.Duration
+	compression    CompressionOptions
+	roomTokens     RoomTokenOptions
+	acks           room.AckPolicy
+	upgrader       websocket.Upgrader
+}
+
+// HandlerDeps are the services and handlers the WebSocket handler works
+// with.
+type HandlerDeps struct {
+	Hub            *room.Hub
+	RTC            *rtc.Service
+	Auth           *auth.Service
+	DirectMessages *DirectMessageHandler
+	Exams          *ExamHandler
+	Verifications  *VerificationHandler
+	Lobbies        *LobbyHandler
+	Assistants     *AssistantHandler
+	Goals          *GoalHandler
+	Consent        *ConsentHandler
+	LiveRecordings *LiveRecordingHandler
+	CoWatch        *CoWatchHandler
+	ChatLog        *chatlog.Recorder
+	Analytics      *analytics.Exporter
+	Captions       *captions.Service // nil without a translation provider
+	ICE            *ICEHandler
+}
+
+// HandlerOptions configure the WebSocket handler.
+type HandlerOptions struct {
+	// How long a disconnected presenter has to reconnect before the stream
+	// is ended
+	PresenterGrace time.Duration
+	Compression    CompressionOptions
+	RoomTokens     RoomTokenOptions
+	// Clients that acknowledge critical signaling messages get them
+	// retransmitted under Acks
+	Acks room.AckPolicy
 }
 
 // NewHandler creates a new WebSocket handler.
-func NewHandler(hub *room.Hub, rtcService *rtc.Service) *Handler {
+func NewHandler(deps HandlerDeps, opts HandlerOptions) *Handler {
+	if opts.Compression.Threshold < 1 {
+		opts.Compression.Threshold = 1
+	}
+	if opts.RoomTokens.TTL <= 0 {
+		opts.RoomTokens.TTL = 30 * time.Minute
+	}
 	return &Handler{
-		hub:        hub,
-		rtcService: rtcService,
+		hub:            deps.Hub,
+		rtcService:     deps.RTC,
+		authService:    deps.Auth,
+		dmHandler:      deps.DirectMessages,
+		examHandler:    deps.Exams,
+		verifications:  deps.Verifications,
+		lobbies:        deps.Lobbies,
+		assistants:     deps.Assistants,
+		goals:          deps.Goals,
+		consent:        deps.Consent,
+		liveRecordings: deps.LiveRecordings,
+		coWatch:        deps.CoWatch,
+		chatLog:        deps.ChatLog,
+		analytics:      deps.Analytics,
+		captions:       deps.Captions,
+		ice:            deps.ICE,
+		presenterGrace: opts.PresenterGrace,
+		compression:    opts.Compression,
+		roomTokens:     opts.RoomTokens,
+		acks:           opts.Acks,
+		upgrader:       newUpgrader(opts.Compression.Enabled),
 	}
 }
 
 // ServeHTTP handles WebSocket upgrade and message processing.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+	ws, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("[Handler] WebSocket upgrade error: %v", err)
 		return
 	}
 
-	conn := NewWSConn(ws)
+	compressThreshold := 0
+	if h.compression.Enabled && offersDeflate(r) {
+		if err := ws.SetCompressionLevel(h.compression.Level); err != nil {
+			log.Printf("[Handler] Invalid WebSocket compression level %d: %v", h.compression.Level, err)
+		}
+		compressThreshold = h.compression.Threshold
+	}
+
+	conn := NewWSConn(ws, compressThreshold, h.compression.ReadLimit)
+	conn.iceServers = h.ice.forRequest(r).ICEServers
 	go conn.WritePump()
 
 	var participant *room.Participant
@@ -74,50 +198,134 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 
 		h.handleMessage(conn, msg, &participant, &currentRoom)
+		if currentRoom != nil {
+			currentRoom.Touch()
+		}
 	}
 }
 
 // cleanup handles disconnection cleanup.
 func (h *Handler) cleanup(conn *WSConn, participant **room.Participant, currentRoom **room.Room) {
-	if *currentRoom != nil && *participant != nil {
-		wasPresenter := (*participant).IsPresenter
+	defer conn.Close()
 
-		(*currentRoom).RemoveParticipant((*participant).ID)
+	if *currentRoom == nil || *participant == nil {
+		return
+	}
+	p, r := *participant, *currentRoom
 
-		// Notify others
-		(*currentRoom).BroadcastToAll(Message{
-			Type:    "participant-left",
-			Payload: mustMarshal((*participant).Info()),
-		}, (*participant).ID)
+	// The presenter already resumed on a newer connection
+	if !r.IsBoundTo(p, conn) {
+		return
+	}
 
-		// If presenter left, notify all viewers that stream ended
-		if wasPresenter {
-			(*currentRoom).BroadcastToViewers(rtc.Message{Type: "stream-ended"})
-		}
+	// Give a dropped presenter a chance to come back before ending the stream
+	if p.IsPresenter && h.presenterGrace > 0 {
+		r.BeginPresenterGrace(h.presenterGrace, func() {
+			h.removeParticipant(p, r)
+		})
+		r.BroadcastToAll(Message{
+			Type: "presenter-reconnecting",
+			Payload: mustMarshal(map[string]interface{}{
+				"graceSeconds": int(h.presenterGrace.Seconds()),
+			}),
+		}, p.ID)
+		return
+	}
+
+	h.removeParticipant(p, r)
+}
+
+// removeParticipant removes a participant from the room and notifies the others.
+func (h *Handler) removeParticipant(p *room.Participant, r *room.Room) {
+	wasPresenter := p.IsPresenter
 
-		// Clean up empty rooms
-		h.hub.CleanupEmptyRoom((*currentRoom).ID)
+	r.RemoveParticipant(p.ID)
+
+	if exam := r.ExamPolicy(); exam != nil {
+		h.examHandler.Record(exam, p, models.ExamEventLeave, "", models.ExamSourceServer)
 	}
-	conn.Close()
+
+	// Notify others
+	r.BroadcastToAll(Message{
+		Type:    "participant-left",
+		Payload: mustMarshal(p.Info()),
+	}, p.ID)
+	if !wasPresenter {
+		r.SendConsentStatus()
+	}
+
+	// If presenter left, notify all viewers that stream ended
+	if wasPresenter {
+		r.BroadcastToViewers(rtc.Message{Type: "stream-ended"})
+	}
+
+	// Clean up empty rooms
+	h.hub.CleanupEmptyRoom(r.ID)
 }
 
 // handleMessage routes messages to appropriate handlers.
 func (h *Handler) handleMessage(conn *WSConn, msg Message, participant **room.Participant, currentRoom **room.Room) {
+	if msg.Type != "join" && *participant != nil && *currentRoom != nil && !h.checkRoomToken(conn, msg, *participant, *currentRoom) {
+		return
+	}
+
 	switch msg.Type {
 	case "join":
 		h.handleJoin(conn, msg, participant, currentRoom)
 	case "offer":
-		h.handleOffer(conn, msg, *participant, *currentRoom)
+		if h.acceptCritical(conn, msg, *participant) {
+			h.handleOffer(conn, msg, *participant, *currentRoom)
+		}
 	case "answer":
-		h.handleAnswer(conn, msg, *participant)
+		if h.acceptCritical(conn, msg, *participant) {
+			h.handleAnswer(conn, msg, *participant)
+		}
+	case "ack":
+		if *participant != nil {
+			(*participant).Ack(msg.Seq)
+		}
 	case "ice-candidate":
 		h.handleICECandidate(msg, *participant)
 	case "request-stream":
 		h.handleRequestStream(conn, *participant, *currentRoom)
 	case "chat":
 		h.handleChat(msg, *participant, *currentRoom)
+	case "typing":
+		h.handleTyping(*participant, *currentRoom)
+	case "chat-read":
+		h.handleChatRead(msg, *participant, *currentRoom)
 	case "raise-hand":
 		h.handleRaiseHand(*participant, *currentRoom)
+	case "lower-hand":
+		h.handleLowerHand(conn, msg, *participant, *currentRoom)
+	case "dm":
+		h.handleDirectMessage(conn, msg, *participant)
+	case "dm-read":
+		h.handleDirectMessageRead(conn, msg, *participant)
+	case "exam-event":
+		h.handleExamEvent(msg, *participant, *currentRoom)
+	case "chat-delete":
+		h.handleChatDelete(conn, msg, *participant, *currentRoom)
+	case "remove-participant":
+		h.handleRemoveParticipant(conn, msg, *participant, *currentRoom)
+	case "caption":
+		h.handleCaption(conn, msg, *participant, *currentRoom)
+	case "caption-language":
+		h.handleCaptionLanguage(conn, msg, *participant, *currentRoom)
+	case "recording-consent-request":
+		h.handleRecordingConsentRequest(conn, msg, *participant, *currentRoom)
+	case "recording-consent":
+		h.handleRecordingConsent(msg, *participant, *currentRoom)
+	case "recording-state":
+		h.handleRecordingState(conn, msg, *participant, *currentRoom)
+	case "start-recording":
+		h.handleStartRecording(conn, *participant, *currentRoom)
+	case "stop-recording":
+		h.handleStopRecording(conn, *participant, *currentRoom)
+	case "play-clip":
+		h.handlePlayClip(conn, msg, *participant, *currentRoom)
+	case "pause-clip", "resume-clip", "stop-clip":
+		h.handleClipControl(conn, msg, *participant, *currentRoom)
 	default:
 		log.Printf("[Handler] Unknown message type: %s", msg.Type)
 	}
@@ -130,7 +338,93 @@ func (h *Handler) handleJoin(conn *WSConn, msg Message, participant **room.Parti
 		roomID = generateRoomID()
 	}
 
+	claims, err := h.joinClaims(msg.Token)
+	if err != nil {
+		sendError(conn, "This device was signed out. Sign in again to join")
+		return
+	}
+	userID, sessionID := "", ""
+	if claims != nil {
+		userID, sessionID = claims.UserID, claims.SessionID
+	}
+
+	// Exam mode: students must be signed in and on time (or rejoining)
+	exam := h.examPolicy(roomID)
+	if exam != nil && !msg.IsPresenter {
+		if userID == "" {
+			sendError(conn, "Sign in to join this exam")
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		allowed := h.examHandler.CanEnter(ctx, exam, userID)
+		cancel()
+		if !allowed {
+			h.examHandler.Record(exam, &room.Participant{Name: msg.Name, UserID: userID},
+				models.ExamEventLateEntryDenied, "", models.ExamSourceServer)
+			sendError(conn, "The late entry window for this exam has closed")
+			return
+		}
+	}
+
+	// Proctored classes: students must have had their identity verified,
+	// whichever role they ask to join in
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	verified := h.verifications.CanJoin(ctx, roomID, claims)
+	cancel()
+	if !verified {
+		sendError(conn, "Verify your identity to join this class")
+		return
+	}
+
+	// A draining instance only serves the rooms it already has
+	_, exists := h.hub.GetRoom(roomID)
+	if !exists && h.hub.Draining() {
+		sendError(conn, "This server is being restarted. Reconnect to join")
+		return
+	}
+
+	// A class's lobby has chat but no media until the class starts
+	var lobby *models.ScheduledClass
+	if !exists && exam == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		lobby = h.lobbies.Lobby(ctx, roomID)
+		cancel()
+	}
+
 	*currentRoom = h.hub.GetOrCreateRoom(roomID)
+	(*currentRoom).SetExamPolicy(exam)
+	if lobby != nil {
+		(*currentRoom).OpenLobby(lobby.StartTime)
+	}
+
+	if (*currentRoom).IsEjected(userID) {
+		*currentRoom = nil
+		sendError(conn, "You were removed from this class")
+		return
+	}
+
+	// A presenter within the grace period picks up their existing session,
+	// if the join carries their last room token
+	if msg.IsPresenter && h.canResumePresenter(msg, *currentRoom) {
+		if p := (*currentRoom).ResumePresenter(conn, userID); p != nil {
+			*participant = p
+			h.sendJoined(conn, *currentRoom, p, true, msg.Acks)
+			(*currentRoom).BroadcastToAll(Message{
+				Type:    "presenter-reconnected",
+				Payload: mustMarshal(p.Info()),
+			}, p.ID)
+			return
+		}
+	}
+
+	// The presenter's own account joining from another device chooses
+	// between taking over and staying on the device presenting now
+	if msg.IsPresenter && userID != "" {
+		if presenter := (*currentRoom).GetPresenter(); presenter != nil && presenter.UserID == userID {
+			h.handlePresenterConflict(conn, msg, *currentRoom, presenter, sessionID, participant)
+			return
+		}
+	}
 
 	// Check if room already has a presenter
 	if msg.IsPresenter && (*currentRoom).HasPresenter() {
@@ -145,28 +439,46 @@ func (h *Handler) handleJoin(conn *WSConn, msg Message, participant **room.Parti
 		conn,
 	)
 
+	// Link the connection to an account when a token is supplied (needed for DMs)
+	(*participant).UserID = userID
+	(*participant).SessionID = sessionID
+	if !msg.IsPresenter && userID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		(*participant).IsAssistant = h.assistants.IsRoomAssistant(ctx, roomID, userID)
+		cancel()
+	}
+
 	(*currentRoom).AddParticipant(*participant)
 
-	// Determine if stream is ready for this viewer
-	streamReady := (*currentRoom).IsFullyReady()
+	if !msg.IsPresenter && userID != "" {
+		go h.goals.RecordAttendance(roomID, userID)
+	}
+	h.analytics.Record(analytics.Event{
+		Type:   analytics.EventJoin,
+		UserID: userID,
+		Role:   participantRole(*participant),
+		RoomID: (*currentRoom).ID,
+	})
 
-	// Send room info
-	response := map[string]interface{}{
-		"type":          "joined",
-		"roomId":        (*currentRoom).ID,
-		"participantId": (*participant).ID,
-		"participants":  (*currentRoom).GetParticipantInfoList(),
-		"hasPresenter":  (*currentRoom).HasPresenter(),
-		"streamReady":   streamReady,
+	if exam != nil {
+		h.examHandler.Record(exam, *participant, models.ExamEventJoin, "", models.ExamSourceServer)
+		// Only one device per student: the newest session wins
+		if !msg.IsPresenter {
+			h.replaceOtherSessions(*currentRoom, *participant, exam)
+		}
 	}
-	respData, _ := json.Marshal(response)
-	conn.Send(respData)
+
+	// Determine if stream is ready for this viewer
+	streamReady := h.sendJoined(conn, *currentRoom, *participant, false, msg.Acks)
 
 	// Notify others
 	(*currentRoom).BroadcastToAll(Message{
 		Type:    "participant-joined",
 		Payload: mustMarshal((*participant).Info()),
 	}, (*participant).ID)
+	if !msg.IsPresenter {
+		(*currentRoom).SendConsentStatus()
+	}
 
 	// If viewer joins and stream is already fully ready, push the offer immediately
 	if !msg.IsPresenter && streamReady {
@@ -176,7 +488,7 @@ func (h *Handler) handleJoin(conn *WSConn, msg Message, participant **room.Parti
 				log.Printf("[Handler] Failed to push stream to new viewer %s: %v", p.Name, err)
 			}
 		}(*participant, *currentRoom)
-	} else if !msg.IsPresenter {
+	} else if _, inLobby := (*currentRoom).Lobby(); !msg.IsPresenter && !inLobby {
 		// Viewer joined but stream not ready - they're in waiting state
 		log.Printf("[Handler] Viewer %s joined, waiting for presenter stream", (*participant).Name)
 		// Send waiting status
@@ -188,6 +500,114 @@ func (h *Handler) handleJoin(conn *WSConn, msg Message, participant **room.Parti
 	}
 }
 
+// examPolicy returns the exam policy for the class running in a room, or nil.
+func (h *Handler) examPolicy(roomID string) *room.ExamPolicy {
+	if roomID == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return h.examHandler.Policy(ctx, roomID)
+}
+
+// replaceOtherSessions disconnects older sessions of the participant's account.
+// Closing the connection ends its read loop, which removes it from the room.
+func (h *Handler) replaceOtherSessions(r *room.Room, p *room.Participant, exam *room.ExamPolicy) {
+	for _, old := range r.OtherSessions(p) {
+		log.Printf("[Handler] Exam session of %s replaced by a newer connection", old.Name)
+		h.examHandler.Record(exam, old, models.ExamEventSessionReplaced, "", models.ExamSourceServer)
+
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":    "session-replaced",
+			"message": "You joined this exam from another device",
+		})
+		old.Conn.Send(data)
+		old.Conn.Close()
+	}
+}
+
+// sendJoined sends the room info and a new room token to a participant that
+// joined (or resumed) and returns whether the stream is ready.
+func (h *Handler) sendJoined(conn *WSConn, r *room.Room, p *room.Participant, resumed, acks bool) bool {
+	streamReady := r.IsFullyReady()
+
+	if acks {
+		p.SetAcks(&h.acks)
+	} else {
+		p.SetAcks(nil)
+	}
+
+	response := map[string]interface{}{
+		"type":                  "joined",
+		"roomId":                r.ID,
+		"participantId":         p.ID,
+		"participants":          r.GetParticipantInfoList(),
+		"hasPresenter":          r.HasPresenter(),
+		"presenterReconnecting": r.IsPresenterReconnecting(),
+		"streamReady":           streamReady,
+		"resumed":               resumed,
+		"features":              r.Features(),
+		"serverTime":            time.Now(),
+		"captionTranslation":    h.captions != nil,
+		"iceServers":            conn.iceServers,
+		"iceTransportPolicy":    h.rtcService.ICETransportPolicy(r.ID),
+		"recording":             r.IsRecording(),
+		"acks":                  p.AcksEnabled(),
+	}
+	if token, expiresAt, ok := h.issueRoomToken(conn, r, p, false); ok {
+		response["roomToken"] = token
+		response["roomTokenExpiresAt"] = expiresAt
+	}
+	if startsAt, ok := r.Lobby(); ok {
+		response["lobby"] = map[string]interface{}{
+			"startTime":       startsAt,
+			"startsInSeconds": max(0, int64(time.Until(startsAt).Seconds())),
+		}
+	}
+	if p.IsPresenter {
+		response["qualityLimit"] = r.QualityLimit()
+		response["uplinkAdaptation"] = r.UplinkAdaptation()
+		response["recordingConsent"] = r.ConsentStatus()
+	} else if prompt := r.ConsentPrompt(p); prompt != nil {
+		response["recordingConsent"] = prompt
+	}
+	respData, _ := json.Marshal(response)
+	conn.Send(respData)
+
+	return streamReady
+}
+
+// joinClaims returns the claims of a join token, or nil if it's missing or
+// invalid (the join is anonymous). Tokens of signed-out sessions are refused
+// so a device over the account's limit can't keep joining classes.
+func (h *Handler) joinClaims(token string) (*auth.Claims, error) {
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := h.authService.ValidateToken(token)
+	if errors.Is(err, auth.ErrSessionRevoked) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// acceptCritical acknowledges a critical message from a client that numbers
+// them, and reports whether to handle it: a retransmit of a message already
+// handled is only acknowledged again.
+func (h *Handler) acceptCritical(conn *WSConn, msg Message, participant *room.Participant) bool {
+	if msg.Seq == 0 || participant == nil {
+		return true
+	}
+	data, _ := json.Marshal(Message{Type: "ack", Seq: msg.Seq})
+	conn.Send(data)
+	return participant.ReceiveCritical(msg.Seq)
+}
+
 // handleOffer processes a WebRTC offer from the presenter.
 func (h *Handler) handleOffer(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
 	if participant == nil || currentRoom == nil {
@@ -200,6 +620,11 @@ func (h *Handler) handleOffer(conn *WSConn, msg Message, participant *room.Parti
 		return
 	}
 
+	if _, ok := currentRoom.Lobby(); ok {
+		sendError(conn, "The class hasn't started yet")
+		return
+	}
+
 	var offer webrtc.SessionDescription
 	if err := json.Unmarshal(msg.Payload, &offer); err != nil {
 		sendError(conn, "Invalid offer format")
@@ -262,6 +687,11 @@ func (h *Handler) handleRequestStream(conn *WSConn, participant *room.Participan
 		return
 	}
 
+	if _, ok := currentRoom.Lobby(); ok {
+		sendError(conn, "The class hasn't started yet")
+		return
+	}
+
 	log.Printf("[Handler] 📥 Stream request from viewer %s (%s) in room %s",
 		participant.ID, participant.Name, currentRoom.ID)
 
@@ -317,32 +747,135 @@ func (h *Handler) handleRequestStream(conn *WSConn, participant *room.Participan
 	}
 }
 
+// participantRole names a participant's role in a room for analytics.
+func participantRole(p *room.Participant) string {
+	switch {
+	case p.IsPresenter:
+		return "presenter"
+	case p.IsAssistant:
+		return "assistant"
+	}
+	return "viewer"
+}
+
 // handleChat processes a chat message.
 func (h *Handler) handleChat(msg Message, participant *room.Participant, currentRoom *room.Room) {
 	if participant == nil || currentRoom == nil {
 		return
 	}
 
+	text := richtext.Chat.Sanitize(chatText(msg.Payload))
+
+	messageID := uuid.New().String()
+	currentRoom.Chat.TrackMessage(messageID, participant.ID)
+	h.analytics.CountChat(currentRoom.ID)
+	currentRoom.Transcript.Record(room.TranscriptEntry{
+		Kind:          room.EntryChat,
+		MessageID:     messageID,
+		ParticipantID: participant.ID,
+		UserID:        participant.UserID,
+		Name:          participant.Name,
+		Text:          text,
+	})
+
+	sentAt := time.Now()
 	chatMsg := map[string]interface{}{
 		"type": "chat",
 		"payload": map[string]interface{}{
+			"messageId":  messageID,
 			"senderId":   participant.ID,
 			"senderName": participant.Name,
-			"message":    string(msg.Payload),
+			"message":    string(mustMarshal(text)),
+			"sentAt":     sentAt.UnixMilli(),
 		},
 	}
 	data, _ := json.Marshal(chatMsg)
 
+	// During an exam students can only message the presenter
+	private := currentRoom.ExamPolicy() != nil && !participant.IsPresenter
+	currentRoom.PostChat(room.ChatPost{
+		MessageID:     messageID,
+		ParticipantID: participant.ID,
+		UserID:        participant.UserID,
+		Name:          participant.Name,
+		Text:          text,
+		Private:       private,
+		At:            sentAt,
+	})
+	if private {
+		currentRoom.SendToPresenter(data)
+		participant.Conn.Send(data)
+		return
+	}
+
 	// Broadcast to everyone
 	currentRoom.BroadcastToAll(json.RawMessage(data), "")
 }
 
+// handleTyping relays a throttled typing indicator to everyone else in the room.
+func (h *Handler) handleTyping(participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	// Students' typing would reveal activity to each other during an exam
+	if currentRoom.ExamPolicy() != nil && !participant.IsPresenter {
+		return
+	}
+
+	// Optional features are paused while the server is under load
+	if !currentRoom.Features().Typing {
+		return
+	}
+
+	if !currentRoom.Chat.AllowTyping(participant.ID) {
+		return
+	}
+
+	currentRoom.BroadcastToAll(Message{
+		Type:    "typing",
+		Payload: mustMarshal(participant.Info()),
+	}, participant.ID)
+}
+
+// handleChatRead records read receipts; counts are broadcast in aggregate by the room.
+func (h *Handler) handleChatRead(msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	var req struct {
+		MessageIDs []string `json:"messageIds"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		log.Printf("[Handler] Invalid chat-read payload: %v", err)
+		return
+	}
+
+	if !currentRoom.Features().ReadReceipts {
+		return
+	}
+
+	for _, id := range req.MessageIDs {
+		currentRoom.MarkChatRead(id, participant.ID)
+	}
+}
+
 // handleRaiseHand processes a raise hand event.
 func (h *Handler) handleRaiseHand(participant *room.Participant, currentRoom *room.Room) {
 	if participant == nil || currentRoom == nil {
 		return
 	}
 
+	if !currentRoom.RaiseHand(participant) {
+		return
+	}
+	currentRoom.Transcript.Record(room.TranscriptEntry{
+		Kind:          room.EntryHandRaised,
+		ParticipantID: participant.ID,
+		Name:          participant.Name,
+	})
+
 	handMsg := Message{
 		Type:    "hand-raised",
 		Payload: mustMarshal(participant.Info()),
@@ -350,6 +883,488 @@ func (h *Handler) handleRaiseHand(participant *room.Participant, currentRoom *ro
 	currentRoom.BroadcastToAll(handMsg, "")
 }
 
+// handleLowerHand lowers a raised hand: the participant's own, or, for
+// moderators, the participant named in the payload.
+func (h *Handler) handleLowerHand(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	var req struct {
+		ParticipantID string `json:"participantId"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			log.Printf("[Handler] Invalid lower-hand payload from %s", participant.Name)
+			return
+		}
+	}
+	if req.ParticipantID == "" {
+		req.ParticipantID = participant.ID
+	}
+	if req.ParticipantID != participant.ID && !participant.CanModerate() {
+		sendError(conn, "Only the presenter or an assistant can lower other hands")
+		return
+	}
+
+	currentRoom.LowerHand(req.ParticipantID, participant.Name)
+}
+
+// handleExamEvent records a client-reported event (e.g. the exam tab losing focus)
+// in the exam audit trail.
+func (h *Handler) handleExamEvent(msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	exam := currentRoom.ExamPolicy()
+	if exam == nil {
+		return
+	}
+
+	var req struct {
+		Event  string `json:"event"`
+		Detail string `json:"detail"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || !validExamEvent(req.Event) {
+		log.Printf("[Handler] Invalid exam-event payload from %s", participant.Name)
+		return
+	}
+
+	h.examHandler.Record(exam, participant, req.Event, req.Detail, models.ExamSourceClient)
+}
+
+// handleChatDelete removes a chat message for everyone. Presenter and assistants only.
+func (h *Handler) handleChatDelete(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+	if !participant.CanModerate() {
+		sendError(conn, "Only the presenter or an assistant can delete messages")
+		return
+	}
+
+	var req struct {
+		MessageID string `json:"messageId"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || req.MessageID == "" {
+		log.Printf("[Handler] Invalid chat-delete payload from %s", participant.Name)
+		return
+	}
+
+	if !currentRoom.DeleteChatMessage(req.MessageID, participant.Name) {
+		sendError(conn, "Message not found")
+		return
+	}
+	h.chatLog.Deleted(req.MessageID, participant.Name)
+}
+
+// handleRemoveParticipant removes a participant from the room. Presenter and
+// assistants only; assistants can't remove the presenter or each other.
+func (h *Handler) handleRemoveParticipant(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+	if !participant.CanModerate() {
+		sendError(conn, "Only the presenter or an assistant can remove participants")
+		return
+	}
+
+	var req struct {
+		ParticipantID string `json:"participantId"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || req.ParticipantID == "" {
+		log.Printf("[Handler] Invalid remove-participant payload from %s", participant.Name)
+		return
+	}
+
+	target, ok := currentRoom.GetParticipant(req.ParticipantID)
+	if !ok || target.ID == participant.ID {
+		sendError(conn, "Participant not found")
+		return
+	}
+	if target.IsPresenter || (target.IsAssistant && !participant.IsPresenter) {
+		sendError(conn, "You can't remove this participant")
+		return
+	}
+
+	currentRoom.Eject(target, participant)
+}
+
+// handleCaption relays a live caption from the presenter. Viewers get it in
+// their selected language; final lines are translated once per language.
+func (h *Handler) handleCaption(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can send captions")
+		return
+	}
+
+	var req struct {
+		Text  string `json:"text"`
+		Lang  string `json:"lang"`
+		Final bool   `json:"final"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || req.Text == "" || len(req.Text) > maxCaptionLength {
+		log.Printf("[Handler] Invalid caption payload from %s", participant.Name)
+		return
+	}
+	source, ok := captions.NormalizeLanguage(req.Lang)
+	if !ok {
+		sendError(conn, "Invalid caption language")
+		return
+	}
+
+	captionID := uuid.New().String()
+	caption := func(text, lang string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "caption",
+			"payload": map[string]interface{}{
+				"captionId":  captionID,
+				"text":       text,
+				"lang":       lang,
+				"sourceLang": source,
+				"final":      req.Final,
+			},
+		}
+	}
+
+	currentRoom.SendCaption(caption(req.Text, source), source, source)
+	if req.Final {
+		currentRoom.Transcript.Record(room.TranscriptEntry{
+			Kind:          room.EntryCaption,
+			ParticipantID: participant.ID,
+			UserID:        participant.UserID,
+			Name:          participant.Name,
+			Text:          req.Text,
+		})
+	}
+
+	// Interim results change several times a second; only translate final lines
+	if !req.Final || h.captions == nil {
+		return
+	}
+
+	for _, target := range currentRoom.CaptionLanguages(source) {
+		go func(target string) {
+			ctx, cancel := context.WithTimeout(context.Background(), captionTranslateTimeout)
+			defer cancel()
+
+			text, err := h.captions.Translate(ctx, req.Text, source, target)
+			if err != nil {
+				log.Printf("[Handler] Caption translation %s->%s failed: %v", source, target, err)
+				return
+			}
+			currentRoom.SendCaption(caption(text, target), target, source)
+		}(target)
+	}
+}
+
+// handleCaptionLanguage sets the language a viewer wants captions in. An empty
+// language switches back to the presenter's original captions.
+func (h *Handler) handleCaptionLanguage(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	var req struct {
+		Lang string `json:"lang"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		log.Printf("[Handler] Invalid caption-language payload: %v", err)
+		return
+	}
+
+	lang := ""
+	if req.Lang != "" {
+		if h.captions == nil {
+			sendError(conn, "Caption translation is not available")
+			return
+		}
+		var ok bool
+		if lang, ok = captions.NormalizeLanguage(req.Lang); !ok {
+			sendError(conn, "Invalid caption language")
+			return
+		}
+	}
+
+	currentRoom.SetCaptionLanguage(participant.ID, lang)
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "caption-language",
+		"payload": map[string]interface{}{
+			"lang": lang,
+		},
+	})
+	conn.Send(data)
+}
+
+// validExamEvent reports whether a client event name is short kebab-case, e.g. "focus-lost".
+func validExamEvent(event string) bool {
+	if event == "" || len(event) > 50 {
+		return false
+	}
+	for _, c := range event {
+		if (c < 'a' || c > 'z') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// handleRecordingConsentRequest asks the students for consent to record the
+// class. Presenter only; answers arrive as "recording-consent" messages.
+func (h *Handler) handleRecordingConsentRequest(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can request recording consent")
+		return
+	}
+
+	var req struct {
+		ExcludeNonConsenting bool `json:"excludeNonConsenting"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &req); err != nil {
+			log.Printf("[Handler] Invalid recording-consent-request payload from %s", participant.Name)
+			return
+		}
+	}
+	if currentRoom.IsRecording() {
+		sendError(conn, "Stop the recording before asking for consent again")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	requestID := h.consent.Begin(ctx, currentRoom.ID, participant, req.ExcludeNonConsenting)
+	cancel()
+
+	if err := currentRoom.RequestRecordingConsent(requestID, participant.Name, req.ExcludeNonConsenting); err != nil {
+		sendError(conn, "Stop the recording before asking for consent again")
+		return
+	}
+	currentRoom.SendConsentStatus()
+}
+
+// handleRecordingConsent records a student's answer to the recording consent
+// request and updates the presenter.
+func (h *Handler) handleRecordingConsent(msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil || participant.IsPresenter {
+		return
+	}
+
+	var req struct {
+		RequestID string `json:"requestId"`
+		Consent   bool   `json:"consent"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil || req.RequestID == "" {
+		log.Printf("[Handler] Invalid recording-consent payload from %s", participant.Name)
+		return
+	}
+
+	// Answers to a request that was since replaced are dropped
+	if !currentRoom.RecordConsent(participant.ID, req.RequestID, req.Consent) {
+		return
+	}
+	h.consent.Record(req.RequestID, participant, req.Consent)
+	currentRoom.SendConsentStatus()
+}
+
+// handleRecordingState tells the room the presenter started or stopped
+// recording. Recording can't start before consent was requested.
+func (h *Handler) handleRecordingState(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can record the class")
+		return
+	}
+
+	var req struct {
+		Recording bool `json:"recording"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		log.Printf("[Handler] Invalid recording-state payload from %s", participant.Name)
+		return
+	}
+
+	if err := currentRoom.SetRecording(req.Recording); err != nil {
+		sendError(conn, "Ask the students for recording consent before recording")
+		return
+	}
+	currentRoom.SendConsentStatus()
+}
+
+// handleStartRecording starts recording the class on the server. Presenter
+// only; the presenter is sent the recording's state in a "server-recording"
+// message, and the room is told with "recording-state" as when recording in
+// the browser.
+func (h *Handler) handleStartRecording(conn *WSConn, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can record the class")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	status, err := h.liveRecordings.startInRoom(ctx, currentRoom, participant.Name)
+	cancel()
+	if err != nil {
+		msg, _ := liveRecordingError(err)
+		sendError(conn, msg)
+		return
+	}
+	sendServerRecording(conn, status)
+}
+
+// handleStopRecording stops recording the class on the server. Presenter
+// only.
+func (h *Handler) handleStopRecording(conn *WSConn, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can record the class")
+		return
+	}
+
+	status, err := h.liveRecordings.stop(currentRoom.ID, currentRoom)
+	if err != nil {
+		msg, _ := liveRecordingError(err)
+		sendError(conn, msg)
+		return
+	}
+	sendServerRecording(conn, status)
+}
+
+// sendServerRecording sends the state of a server-side recording.
+func sendServerRecording(conn *WSConn, status rtc.RecordingStatus) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "server-recording",
+		"payload": status,
+	})
+	conn.Send(data)
+}
+
+// handlePlayClip plays a segment of a recording into the class. Presenter
+// only; everyone gets "clip-state" messages as it plays.
+func (h *Handler) handlePlayClip(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can play recordings into the class")
+		return
+	}
+
+	var req clipRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		log.Printf("[Handler] Invalid play-clip payload from %s", participant.Name)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	_, err := h.coWatch.playInRoom(ctx, currentRoom, participant.Name, req)
+	cancel()
+	if err != nil {
+		msg, _ := coWatchError(err)
+		sendError(conn, msg)
+	}
+}
+
+// handleClipControl pauses, resumes or stops the clip playing in the class.
+// Presenter only.
+func (h *Handler) handleClipControl(conn *WSConn, msg Message, participant *room.Participant, currentRoom *room.Room) {
+	if participant == nil || currentRoom == nil {
+		return
+	}
+
+	if !participant.IsPresenter {
+		sendError(conn, "Only the presenter can control recordings played into the class")
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case "pause-clip":
+		_, err = h.coWatch.player.Pause(currentRoom.ID)
+	case "resume-clip":
+		_, err = h.coWatch.player.Resume(currentRoom.ID)
+	case "stop-clip":
+		_, err = h.coWatch.player.Stop(currentRoom.ID)
+	}
+	if err != nil {
+		msg, _ := coWatchError(err)
+		sendError(conn, msg)
+	}
+}
+
+// handleDirectMessage sends a private message from an authenticated participant.
+func (h *Handler) handleDirectMessage(conn *WSConn, msg Message, participant *room.Participant) {
+	if participant == nil {
+		sendError(conn, "Not in a room")
+		return
+	}
+	if participant.UserID == "" {
+		sendError(conn, "Sign in to send direct messages")
+		return
+	}
+
+	var req struct {
+		BatchID     string `json:"batchId"`
+		RecipientID string `json:"recipientId"`
+		Body        string `json:"body"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		sendError(conn, "Invalid direct message format")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.dmHandler.Send(ctx, participant.UserID, req.BatchID, req.RecipientID, req.Body); err != nil {
+		log.Printf("[Handler] Direct message from %s rejected: %v", participant.Name, err)
+		sendError(conn, err.Error())
+	}
+}
+
+// handleDirectMessageRead marks a direct message conversation as read.
+func (h *Handler) handleDirectMessageRead(conn *WSConn, msg Message, participant *room.Participant) {
+	if participant == nil || participant.UserID == "" {
+		return
+	}
+
+	var req struct {
+		BatchID  string `json:"batchId"`
+		SenderID string `json:"senderId"`
+	}
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		log.Printf("[Handler] Invalid dm-read payload: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.dmHandler.MarkRead(ctx, participant.UserID, req.BatchID, req.SenderID); err != nil {
+		log.Printf("[Handler] Failed to mark direct messages read: %v", err)
+	}
+}
+
 // sendError sends an error message to the client.
 func sendError(conn *WSConn, message string) {
 	msg := map[string]string{
@@ -360,6 +1375,15 @@ func sendError(conn *WSConn, message string) {
 	conn.Send(data)
 }
 
+// chatText extracts the chat text from a payload, which clients send as a JSON string.
+func chatText(payload json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(payload, &text); err == nil {
+		return text
+	}
+	return string(payload)
+}
+
 // mustMarshal marshals data or returns empty JSON object.
 func mustMarshal(v interface{}) json.RawMessage {
 	data, err := json.Marshal(v)
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/analytics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// handout is a file shared in a live class, as sent to the room.
+type handout struct {
+	ID        string          `json:"id"`
+	Title     string          `json:"title"`
+	FileName  string          `json:"fileName"`
+	FileType  models.NoteType `json:"fileType"`
+	MimeType  string          `json:"mimeType"`
+	FileSize  int64           `json:"fileSize"`
+	SharedBy  string          `json:"sharedBy"`
+	SharedAt  time.Time       `json:"sharedAt"`
+	URL       string          `json:"url"` // Signed for one user, works without a login token
+	ExpiresAt time.Time       `json:"expiresAt"`
+}
+
+// HandoutHandler lets presenters share files in a live class. Handouts are
+// stored as notes of the class's batch, held back from the batch's note list
+// until the class ends; viewers in the room get signed links meanwhile.
+type HandoutHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	noteRepo     *repository.NoteRepository
+	notes        *NoteHandler
+	analytics    *analytics.Exporter
+	hub          *room.Hub
+	linkTTL      time.Duration
+}
+
+// NewHandoutHandler creates a new HandoutHandler. Handout links are valid
+// for linkTTL.
+func NewHandoutHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, noteRepo *repository.NoteRepository, notes *NoteHandler, exporter *analytics.Exporter, hub *room.Hub, linkTTL time.Duration) *HandoutHandler {
+	return &HandoutHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		noteRepo:     noteRepo,
+		notes:        notes,
+		analytics:    exporter,
+		hub:          hub,
+		linkTTL:      linkTTL,
+	}
+}
+
+// Handouts shares a file in a live class (POST /api/schedules/{id}/handouts,
+// multipart with "file" and an optional "title") or lists the handouts of a
+// class with fresh links (GET), for viewers who joined late.
+//
+// Sharing is for the class's presenter, the batch's assistants and admins;
+// everyone in the room gets a "handout" message with a signed link. Listing
+// is also open to the batch's students.
+func (h *HandoutHandler) Handouts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Links are minted for the caller's login session
+	user, claims, err := h.authService.Authenticate(r.Context(), extractToken(r))
+	if err != nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	scheduleID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")[0]
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendStoreError(w, "Batch not found", err)
+		return
+	}
+
+	teaches := user.Role == models.RoleAdmin || schedule.PresenterID == user.ID || batch.HasAssistant(user.ID.Hex())
+	if r.Method == http.MethodGet {
+		if !teaches && !batch.HasStudent(user.ID.Hex()) {
+			sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+			return
+		}
+		h.list(w, r, claims, schedule)
+		return
+	}
+
+	if !teaches {
+		sendJSONError(w, "Only the presenter can share handouts", http.StatusForbidden)
+		return
+	}
+	h.share(w, r, user, claims, schedule, batch)
+}
+
+// list sends the handouts of a class, with links for the caller.
+func (h *HandoutHandler) list(w http.ResponseWriter, r *http.Request, claims *auth.Claims, schedule *models.ScheduledClass) {
+	notes, err := h.noteRepo.FindHandouts(r.Context(), schedule.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch handouts", err)
+		return
+	}
+
+	handouts := make([]handout, 0, len(notes))
+	for _, note := range notes {
+		if note.Hidden {
+			continue
+		}
+		if item, err := h.handout(note, claims.UserID, claims.SessionID); err == nil {
+			handouts = append(handouts, item)
+		}
+	}
+	sendJSON(w, map[string]interface{}{"handouts": handouts}, http.StatusOK)
+}
+
+// share stores a handout and sends it to everyone in the class's room.
+func (h *HandoutHandler) share(w http.ResponseWriter, r *http.Request, user *models.User, claims *auth.Claims, schedule *models.ScheduledClass, batch *models.Batch) {
+	if schedule.EffectiveStatusAt(time.Now()) != models.ClassStatusLive || schedule.RoomID == "" {
+		sendJSONError(w, "Handouts can only be shared while the class is live", http.StatusConflict)
+		return
+	}
+	liveRoom, ok := h.hub.GetRoom(strings.ToUpper(schedule.RoomID))
+	if !ok {
+		sendJSONError(w, "The class room isn't open", http.StatusConflict)
+		return
+	}
+
+	// Same limit as notes (max 50MB)
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		sendJSONError(w, "File too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	form := struct {
+		Title string `json:"title" validate:"max=200"`
+	}{strings.TrimSpace(r.FormValue("title"))}
+	if !checkRequest(w, &form) {
+		return
+	}
+
+	stored, ok := h.notes.saveUpload(w, r)
+	if !ok {
+		return
+	}
+
+	note := &models.Note{
+		Title:        firstNonEmpty(form.Title, stored.name),
+		FileName:     stored.name,
+		FilePath:     stored.path,
+		FileSize:     stored.size,
+		FileType:     models.GetNoteType(stored.kind.MimeType),
+		MimeType:     stored.kind.MimeType,
+		BatchID:      batch.ID,
+		BatchName:    batch.Name,
+		UploaderID:   user.ID,
+		UploaderName: user.Name,
+		UploaderRole: string(user.Role),
+
+		// Attached to the batch's notes when the class ends
+		Unpublished:       true,
+		PublishScheduleID: &schedule.ID,
+		HandoutScheduleID: &schedule.ID,
+	}
+	if err := h.noteRepo.Create(r.Context(), note); err != nil {
+		log.Printf("[Handouts] Failed to create note record: %v", err)
+		os.Remove(stored.path)
+		sendJSONError(w, "Failed to save handout", http.StatusInternalServerError)
+		return
+	}
+	h.notes.images.Enqueue(note)
+	h.notes.pdfs.Enqueue(note)
+
+	item, err := h.handout(note, claims.UserID, claims.SessionID)
+	if err != nil {
+		log.Printf("[Handouts] Failed to sign link for %s: %v", note.ID.Hex(), err)
+		sendStoreError(w, "Failed to create handout link", err)
+		return
+	}
+
+	// Each participant gets a link of their own; guests can't download
+	liveRoom.SendToEach(func(p *room.Participant) interface{} {
+		if p.UserID == "" {
+			return nil
+		}
+		link, err := h.handout(note, p.UserID, p.SessionID)
+		if err != nil {
+			log.Printf("[Handouts] Failed to sign link for %s: %v", note.ID.Hex(), err)
+			return nil
+		}
+		return map[string]interface{}{
+			"type":    "handout",
+			"handout": link,
+		}
+	})
+	log.Printf("[Handouts] %s shared %s in room %s (%d participant(s))", user.Name, note.FileName, liveRoom.ID, liveRoom.ParticipantCount())
+
+	h.analytics.Record(analytics.Event{
+		Type:    analytics.EventUpload,
+		UserID:  user.ID.Hex(),
+		Role:    string(user.Role),
+		RefType: "note",
+		RefID:   note.ID.Hex(),
+		Value:   note.FileSize,
+	})
+
+	sendJSON(w, item, http.StatusCreated)
+}
+
+// handout returns a note as a handout with a fresh link signed for a user
+// and their login session.
+func (h *HandoutHandler) handout(note *models.Note, userID, sessionID string) (handout, error) {
+	token, expiresAt, err := h.authService.IssueHandoutToken(note.ID.Hex(), userID, sessionID, h.linkTTL)
+	if err != nil {
+		return handout{}, err
+	}
+	return handout{
+		ID:        note.ID.Hex(),
+		Title:     note.Title,
+		FileName:  note.FileName,
+		FileType:  note.FileType,
+		MimeType:  note.MimeType,
+		FileSize:  note.FileSize,
+		SharedBy:  note.UploaderName,
+		SharedAt:  note.CreatedAt,
+		URL:       "/api/handouts/" + note.ID.Hex() + "?token=" + token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Download serves a handout to the user its signed link was minted for
+// (GET /api/handouts/{id}?token=...), while they are signed in to the
+// session it came from and can still see the class. Like note downloads it
+// accepts ?width= for smaller copies of images.
+func (h *HandoutHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	noteID := strings.TrimPrefix(r.URL.Path, "/api/handouts/")
+	user, claims, err := h.authService.GetUserFromHandoutToken(r.Context(), r.URL.Query().Get("token"))
+	if err != nil || claims.NoteID != noteID {
+		sendJSONError(w, "This handout link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(noteID)
+	if err != nil {
+		sendJSONError(w, "Handout not found", http.StatusNotFound)
+		return
+	}
+	note, err := h.noteRepo.FindByID(r.Context(), id)
+	if err != nil || note.HandoutScheduleID == nil {
+		sendJSONError(w, "Handout not found", http.StatusNotFound)
+		return
+	}
+	if note.Hidden {
+		sendJSONError(w, "This handout is hidden pending review", http.StatusForbidden)
+		return
+	}
+	if !h.canSee(r.Context(), user, note) {
+		sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+		return
+	}
+
+	h.notes.serveFile(w, r, note)
+}
+
+// canSee reports whether a user may still download a handout: admins, the
+// class's presenter and the batch's assistants and students.
+func (h *HandoutHandler) canSee(ctx context.Context, user *models.User, note *models.Note) bool {
+	if user.Role == models.RoleAdmin {
+		return true
+	}
+	schedule, err := h.scheduleRepo.FindByID(ctx, note.HandoutScheduleID.Hex())
+	if err != nil {
+		return false
+	}
+	if schedule.PresenterID == user.ID {
+		return true
+	}
+	batch, err := h.batchRepo.FindByID(ctx, schedule.BatchID.Hex())
+	if err != nil {
+		return false
+	}
+	return batch.HasAssistant(user.ID.Hex()) || batch.HasStudent(user.ID.Hex())
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/geoip"
+	"github.com/jinshatcp/brightline-academy/learn/internal/ice"
+)
+
+// ICEHandler selects ICE servers for clients by location.
+type ICEHandler struct {
+	selector *ice.Selector
+	locator  *geoip.Locator
+}
+
+// NewICEHandler creates a new ICEHandler.
+func NewICEHandler(selector *ice.Selector, locator *geoip.Locator) *ICEHandler {
+	return &ICEHandler{selector: selector, locator: locator}
+}
+
+// Config returns the ICE servers for the caller (GET /api/ice-config).
+//
+// For testing a region or location, query parameters override what is
+// derived from the request: region (force a region first), ip (locate this
+// address instead), country (ISO code) and lat/lon.
+func (h *ICEHandler) Config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	ip := h.locator.ClientIP(r)
+	if raw := q.Get("ip"); raw != "" {
+		parsed, err := netip.ParseAddr(raw)
+		if err != nil {
+			sendJSONError(w, "Invalid ip", http.StatusBadRequest)
+			return
+		}
+		ip = parsed
+	}
+
+	loc := h.locator.LocateIP(r, ip)
+	if country := q.Get("country"); country != "" {
+		loc.Country = strings.ToUpper(country)
+	}
+	if q.Has("lat") || q.Has("lon") {
+		lat, latErr := strconv.ParseFloat(q.Get("lat"), 64)
+		lon, lonErr := strconv.ParseFloat(q.Get("lon"), 64)
+		if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			sendJSONError(w, "Invalid lat/lon", http.StatusBadRequest)
+			return
+		}
+		loc.Lat, loc.Lon, loc.HasCoords = lat, lon, true
+	}
+
+	sendJSON(w, h.selector.Select(loc, q.Get("region")), http.StatusOK)
+}
+
+// forRequest returns the ICE configuration for the client of r, without
+// overrides.
+func (h *ICEHandler) forRequest(r *http.Request) ice.Selection {
+	return h.selector.Select(h.locator.Locate(r), "")
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errUnderLegalHold is returned when a change is blocked by a legal hold.
+var errUnderLegalHold = errors.New("this content is under legal hold and can't be changed or deleted")
+
+// LegalHoldHandler lets admins place content under legal hold and guards
+// held content against deletion and edits.
+type LegalHoldHandler struct {
+	authService   *auth.Service
+	holdRepo      *repository.LegalHoldRepository
+	noteRepo      *repository.NoteRepository
+	recordingRepo *repository.RecordingRepository
+	scheduleRepo  *repository.ScheduleRepository
+}
+
+// NewLegalHoldHandler creates a new LegalHoldHandler.
+func NewLegalHoldHandler(authService *auth.Service, holdRepo *repository.LegalHoldRepository, noteRepo *repository.NoteRepository, recordingRepo *repository.RecordingRepository, scheduleRepo *repository.ScheduleRepository) *LegalHoldHandler {
+	return &LegalHoldHandler{
+		authService:   authService,
+		holdRepo:      holdRepo,
+		noteRepo:      noteRepo,
+		recordingRepo: recordingRepo,
+		scheduleRepo:  scheduleRepo,
+	}
+}
+
+// Holds lists holds (GET /api/admin/legal-holds, ?all=true includes released
+// holds) or places a new one (POST /api/admin/legal-holds).
+func (h *LegalHoldHandler) Holds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		holds, err := h.holdRepo.FindAll(r.Context(), r.URL.Query().Get("all") != "true")
+		if err != nil {
+			sendStoreError(w, "Failed to fetch legal holds", err)
+			return
+		}
+		sendJSON(w, map[string]interface{}{"holds": holds}, http.StatusOK)
+
+	case http.MethodPost:
+		h.place(w, r)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// place puts a recording, note or class chat archive under legal hold.
+func (h *LegalHoldHandler) place(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ContentType   models.HoldContentType `json:"contentType" validate:"required,oneof=recording note archive"`
+		ContentID     string                 `json:"contentId" validate:"required,objectid"`
+		Reason        string                 `json:"reason" validate:"required,max=1000"`
+		CaseReference string                 `json:"caseReference" validate:"max=200"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	title, err := h.contentTitle(r.Context(), req.ContentType, req.ContentID)
+	if err != nil {
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hold := &models.LegalHold{
+		ContentType:   req.ContentType,
+		ContentID:     req.ContentID,
+		Title:         title,
+		Reason:        req.Reason,
+		CaseReference: strings.TrimSpace(req.CaseReference),
+		PlacedBy:      admin.ID,
+		PlacedByName:  admin.Name,
+	}
+	if err := h.holdRepo.Place(r.Context(), hold); err != nil {
+		if errors.Is(err, repository.ErrAlreadyOnHold) {
+			sendJSONError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		sendJSONError(w, "Failed to place legal hold", http.StatusInternalServerError)
+		return
+	}
+
+	h.record(r.Context(), &models.LegalHoldEvent{
+		HoldID:      hold.ID,
+		ContentType: hold.ContentType,
+		ContentID:   hold.ContentID,
+		Action:      models.LegalHoldPlaced,
+		ActorID:     admin.ID,
+		ActorName:   admin.Name,
+		Detail:      hold.Reason,
+	})
+
+	log.Printf("[LegalHold] %s placed a hold on %s %s", admin.Name, hold.ContentType, hold.ContentID)
+
+	sendJSON(w, hold, http.StatusCreated)
+}
+
+// Release lifts a hold (POST /api/admin/legal-holds/{id}/release).
+func (h *LegalHoldHandler) Release(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract hold ID from URL: /api/admin/legal-holds/{id}/release
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/legal-holds/"), "/")
+	if len(parts) != 2 || parts[1] != "release" {
+		sendJSONError(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" validate:"required,max=1000"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	hold, err := h.holdRepo.Release(r.Context(), parts[0], admin.ID, admin.Name, req.Reason)
+	if err != nil {
+		if errors.Is(err, repository.ErrLegalHoldNotFound) {
+			sendJSONError(w, "Active legal hold not found", http.StatusNotFound)
+			return
+		}
+		sendJSONError(w, "Failed to release legal hold", http.StatusInternalServerError)
+		return
+	}
+
+	h.record(r.Context(), &models.LegalHoldEvent{
+		HoldID:      hold.ID,
+		ContentType: hold.ContentType,
+		ContentID:   hold.ContentID,
+		Action:      models.LegalHoldReleased,
+		ActorID:     admin.ID,
+		ActorName:   admin.Name,
+		Detail:      req.Reason,
+	})
+
+	log.Printf("[LegalHold] %s released the hold on %s %s", admin.Name, hold.ContentType, hold.ContentID)
+
+	sendJSON(w, hold, http.StatusOK)
+}
+
+// AuditLog returns the hold audit log (GET /api/admin/legal-holds/audit),
+// optionally filtered by ?contentType=&contentId=.
+func (h *LegalHoldHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	events, err := h.holdRepo.FindEvents(r.Context(), models.HoldContentType(query.Get("contentType")), query.Get("contentId"))
+	if err != nil {
+		sendStoreError(w, "Failed to fetch audit log", err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{"events": events}, http.StatusOK)
+}
+
+// Guard returns errUnderLegalHold if the content is held, recording the
+// blocked attempt in the audit log. action is models.LegalHoldDeleteBlocked or
+// models.LegalHoldEditBlocked; actor may be nil for system jobs such as
+// retention purges. Lookup failures also block, so a database outage can't
+// be used to get around a hold.
+func (h *LegalHoldHandler) Guard(ctx context.Context, contentType models.HoldContentType, contentID, action string, actor *models.User) error {
+	hold, err := h.holdRepo.FindActive(ctx, contentType, contentID)
+	if errors.Is(err, repository.ErrLegalHoldNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[LegalHold] Failed to check hold on %s %s: %v", contentType, contentID, err)
+		return errUnderLegalHold
+	}
+
+	event := &models.LegalHoldEvent{
+		HoldID:      hold.ID,
+		ContentType: contentType,
+		ContentID:   contentID,
+		Action:      action,
+		ActorName:   "system",
+	}
+	if actor != nil {
+		event.ActorID = actor.ID
+		event.ActorName = actor.Name
+	}
+	h.record(ctx, event)
+
+	return errUnderLegalHold
+}
+
+// contentTitle checks that the content exists and returns its title.
+func (h *LegalHoldHandler) contentTitle(ctx context.Context, contentType models.HoldContentType, contentID string) (string, error) {
+	notFound := errors.New(string(contentType) + " not found")
+
+	switch contentType {
+	case models.HoldContentNote:
+		noteID, err := primitive.ObjectIDFromHex(contentID)
+		if err != nil {
+			return "", notFound
+		}
+		note, err := h.noteRepo.FindByID(ctx, noteID)
+		if err != nil {
+			return "", notFound
+		}
+		return note.Title, nil
+
+	case models.HoldContentRecording:
+		recording, err := h.recordingRepo.FindByID(ctx, contentID)
+		if err != nil {
+			return "", notFound
+		}
+		return recording.Title, nil
+
+	case models.HoldContentArchive:
+		schedule, err := h.scheduleRepo.FindByID(ctx, contentID)
+		if err != nil {
+			return "", errors.New("class not found")
+		}
+		return schedule.Title, nil
+	}
+	return "", errors.New("invalid contentType. Must be: recording, note, or archive")
+}
+
+// record appends to the audit log. Failures are logged, not returned: the
+// action itself has already happened or been blocked.
+func (h *LegalHoldHandler) record(ctx context.Context, event *models.LegalHoldEvent) {
+	if err := h.holdRepo.RecordEvent(ctx, event); err != nil {
+		log.Printf("[LegalHold] Failed to record %s event for %s %s: %v", event.Action, event.ContentType, event.ContentID, err)
+	}
+}
@@ -0,0 +1,340 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chapters"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/jinshatcp/brightline-academy/learn/internal/rtc"
+	"github.com/jinshatcp/brightline-academy/learn/internal/variants"
+)
+
+var (
+	// errServerRecordingDisabled is returned when server-side recording is
+	// turned off.
+	errServerRecordingDisabled = errors.New("server-side recording is disabled")
+	// errNoScheduledClass is returned when recording a room that isn't a
+	// scheduled class, which there would be nothing to attach the recording to.
+	errNoScheduledClass = errors.New("room is not a scheduled class")
+)
+
+// LiveRecordingHandler records live classes on the server, so presenters
+// don't have to record in the browser and upload. Recording is started and
+// stopped with the REST API or signaling messages, and each take becomes a
+// recording of the class when it ends.
+type LiveRecordingHandler struct {
+	authService   *auth.Service
+	scheduleRepo  *repository.ScheduleRepository
+	recordingRepo *repository.RecordingRepository
+	batchRepo     *repository.BatchRepository
+	userRepo      *repository.UserRepository
+	billing       *BillingHandler
+	consent       *ConsentHandler
+	chapters      *chapters.Generator
+	variants      *variants.Generator
+	files         *encryption.Encryptor // nil stores files in plaintext
+	hub           *room.Hub
+	recorder      *rtc.Recorder
+	enabled       bool
+}
+
+// NewLiveRecordingHandler creates a new LiveRecordingHandler and makes it
+// the recorder's take sink.
+func NewLiveRecordingHandler(
+	authService *auth.Service,
+	scheduleRepo *repository.ScheduleRepository,
+	recordingRepo *repository.RecordingRepository,
+	batchRepo *repository.BatchRepository,
+	userRepo *repository.UserRepository,
+	billing *BillingHandler,
+	consent *ConsentHandler,
+	chapterGenerator *chapters.Generator,
+	variantGenerator *variants.Generator,
+	files *encryption.Encryptor,
+	hub *room.Hub,
+	recorder *rtc.Recorder,
+	enabled bool,
+) *LiveRecordingHandler {
+	h := &LiveRecordingHandler{
+		authService:   authService,
+		scheduleRepo:  scheduleRepo,
+		recordingRepo: recordingRepo,
+		batchRepo:     batchRepo,
+		userRepo:      userRepo,
+		billing:       billing,
+		consent:       consent,
+		chapters:      chapterGenerator,
+		variants:      variantGenerator,
+		files:         files,
+		hub:           hub,
+		recorder:      recorder,
+		enabled:       enabled,
+	}
+	recorder.SetTakeSink(h.saveTakes)
+	return h
+}
+
+// Record handles /api/schedules/{id}/record for the class presenter and
+// admins:
+//   - GET: the state of the class's latest server-side recording
+//   - POST: start recording the live class
+//   - DELETE: stop recording
+//
+// Recording also stops when the class ends, which turns the takes into
+// recordings of the class.
+func (h *LiveRecordingHandler) Record(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/record
+	scheduleID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")[0]
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the class presenter can record this class", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var latest *rtc.RecordingStatus // nil until the class is first recorded here
+		if schedule.RoomID != "" {
+			if status, ok := h.recorder.Status(schedule.RoomID); ok && status.ScheduleID == schedule.ID.Hex() {
+				latest = &status
+			}
+		}
+		sendJSON(w, map[string]interface{}{
+			"available": h.enabled,
+			"recording": latest,
+		}, http.StatusOK)
+
+	case http.MethodPost:
+		liveRoom, ok := h.liveRoom(schedule)
+		if !ok {
+			sendJSONError(w, "This class isn't live", http.StatusConflict)
+			return
+		}
+		status, err := h.start(r.Context(), schedule, liveRoom, user.Name)
+		if err != nil {
+			msg, code := liveRecordingError(err)
+			if code == http.StatusInternalServerError {
+				log.Printf("[LiveRecording] Failed to start recording %s: %v", scheduleID, err)
+			}
+			sendJSONError(w, msg, code)
+			return
+		}
+		sendJSON(w, status, http.StatusAccepted)
+
+	case http.MethodDelete:
+		liveRoom, _ := h.liveRoom(schedule)
+		status, err := h.stop(schedule.RoomID, liveRoom)
+		if err != nil {
+			msg, code := liveRecordingError(err)
+			sendJSONError(w, msg, code)
+			return
+		}
+		sendJSON(w, status, http.StatusOK)
+	}
+}
+
+// liveRoom returns the room of a live class on this instance.
+func (h *LiveRecordingHandler) liveRoom(schedule *models.ScheduledClass) (*room.Room, bool) {
+	if schedule.Status != models.ClassStatusLive || schedule.RoomID == "" {
+		return nil, false
+	}
+	return h.hub.GetRoom(schedule.RoomID)
+}
+
+// start starts recording a class's room for startedBy. Like recording in
+// the browser, it needs the students to have been asked for consent, and
+// tells the room it is being recorded.
+func (h *LiveRecordingHandler) start(ctx context.Context, schedule *models.ScheduledClass, liveRoom *room.Room, startedBy string) (rtc.RecordingStatus, error) {
+	if !h.enabled {
+		return rtc.RecordingStatus{}, errServerRecordingDisabled
+	}
+	if err := h.billing.Allows(ctx, schedule.BatchID, models.FeatureRecording); err != nil {
+		return rtc.RecordingStatus{}, err
+	}
+	if !liveRoom.IsStreamReady() {
+		return rtc.RecordingStatus{}, rtc.ErrStreamNotReady
+	}
+	if status, ok := h.recorder.Status(liveRoom.ID); ok && status.State == rtc.RecordingStateRecording {
+		return status, rtc.ErrAlreadyRecording
+	}
+
+	wasRecording := liveRoom.IsRecording()
+	if err := liveRoom.SetRecording(true); err != nil {
+		return rtc.RecordingStatus{}, err
+	}
+	status, err := h.recorder.Start(liveRoom, schedule.ID.Hex(), startedBy)
+	if err != nil {
+		if !wasRecording && !errors.Is(err, rtc.ErrAlreadyRecording) {
+			liveRoom.SetRecording(false)
+		}
+		return status, err
+	}
+	liveRoom.SendConsentStatus()
+	return status, nil
+}
+
+// startInRoom starts recording the class in a room, for a
+// "start-recording" message from its presenter.
+func (h *LiveRecordingHandler) startInRoom(ctx context.Context, liveRoom *room.Room, startedBy string) (rtc.RecordingStatus, error) {
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, liveRoom.ID)
+	if err != nil {
+		return rtc.RecordingStatus{}, errNoScheduledClass
+	}
+	return h.start(ctx, schedule, liveRoom, startedBy)
+}
+
+// stop stops recording a room and, if it's still open, tells it.
+func (h *LiveRecordingHandler) stop(roomID string, liveRoom *room.Room) (rtc.RecordingStatus, error) {
+	if roomID == "" {
+		return rtc.RecordingStatus{}, rtc.ErrNotRecording
+	}
+	status, err := h.recorder.Stop(roomID)
+	if err != nil {
+		return status, err
+	}
+	if liveRoom != nil {
+		liveRoom.SetRecording(false)
+		liveRoom.SendConsentStatus()
+	}
+	return status, nil
+}
+
+// liveRecordingError returns the message and status to answer a failure to
+// start or stop a recording with.
+func liveRecordingError(err error) (string, int) {
+	switch {
+	case errors.Is(err, errServerRecordingDisabled):
+		return "Server-side recording is not available", http.StatusServiceUnavailable
+	case errors.Is(err, errNoScheduledClass):
+		return "Only scheduled classes can be recorded on the server", http.StatusConflict
+	case isBillingLimit(err):
+		return "Recordings aren't available: " + err.Error(), http.StatusPaymentRequired
+	case errors.Is(err, rtc.ErrStreamNotReady):
+		return "The presenter isn't streaming yet", http.StatusConflict
+	case errors.Is(err, room.ErrConsentNotRequested):
+		return "Ask the students for recording consent before recording", http.StatusConflict
+	case errors.Is(err, rtc.ErrAlreadyRecording):
+		return "This class is already being recorded", http.StatusConflict
+	case errors.Is(err, rtc.ErrNotRecording):
+		return "This class isn't being recorded", http.StatusNotFound
+	default:
+		return "Failed to record the class", http.StatusInternalServerError
+	}
+}
+
+// saveTakes creates a recording of the class for each take, numbering them
+// when there are several. Takes that can't be saved are left on disk.
+func (h *LiveRecordingHandler) saveTakes(scheduleID string, takes []rtc.Take) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	schedule, err := h.scheduleRepo.FindByID(ctx, scheduleID)
+	if err != nil {
+		log.Printf("[LiveRecording] ❌ Class %s not found, %d recordings left on disk: %v", scheduleID, len(takes), err)
+		return
+	}
+
+	names := newNameLookup(ctx, h.batchRepo, h.userRepo)
+	consent := h.consent.ForSchedule(ctx, schedule.ID)
+	for i, take := range takes {
+		title := schedule.Title
+		if len(takes) > 1 {
+			title = fmt.Sprintf("%s (part %d)", schedule.Title, i+1)
+		}
+
+		size, err := h.sealFile(ctx, take.Path)
+		if err != nil {
+			log.Printf("[LiveRecording] ❌ Failed to encrypt %s: %v", take.Path, err)
+			continue
+		}
+
+		recording := &models.Recording{
+			ScheduleID:  schedule.ID,
+			BatchID:     schedule.BatchID,
+			PresenterID: schedule.PresenterID,
+			Title:       title,
+			Description: schedule.Description,
+			FileName:    filepath.Base(take.Path),
+			FilePath:    take.Path,
+			FileSize:    size,
+			Duration:    int(take.Duration.Seconds()),
+			MimeType:    "video/webm",
+			Status:      models.RecordingStatusReady,
+			RecordedAt:  take.StartedAt,
+
+			BatchName:     names.Batch(schedule.BatchID, schedule.BatchName),
+			PresenterName: names.User(schedule.PresenterID, schedule.PresenterName),
+
+			Consent: consent,
+		}
+		if err := h.recordingRepo.Create(ctx, recording); err != nil {
+			log.Printf("[LiveRecording] ❌ Failed to save the recording %s: %v", take.Path, err)
+			continue
+		}
+		h.chapters.Enqueue(recording)
+		h.variants.Enqueue(recording)
+		log.Printf("[LiveRecording] 📼 Saved %s of %s (%ds)", recording.ID.Hex(), scheduleID, recording.Duration)
+	}
+}
+
+// sealFile encrypts a finished take in place when encryption is enabled and
+// returns its size on disk.
+func (h *LiveRecordingHandler) sealFile(ctx context.Context, path string) (int64, error) {
+	if h.files != nil {
+		src, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		defer src.Close()
+
+		sealed := path + ".enc"
+		dst, err := h.files.Create(ctx, sealed)
+		if err != nil {
+			return 0, err
+		}
+		_, err = io.Copy(dst, src)
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(sealed, path)
+		}
+		if err != nil {
+			os.Remove(sealed)
+			return 0, err
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LobbyHandler runs pre-class lobbies: rooms without media where batch
+// members can chat and see the class materials before it starts. The lobby
+// room becomes the live room when the class starts, so nobody has to rejoin.
+type LobbyHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	hub          *room.Hub
+}
+
+// NewLobbyHandler creates a new LobbyHandler.
+func NewLobbyHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, hub *room.Hub) *LobbyHandler {
+	return &LobbyHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		hub:          hub,
+	}
+}
+
+// Enter returns the lobby room of a class, opening it if needed
+// (POST /api/schedules/{id}/lobby). The lobby opens models.LobbyWindow before
+// the class starts; exams and proctored classes have none.
+func (h *LobbyHandler) Enter(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	scheduleID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")[0]
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	switch schedule.EffectiveStatusAt(now) {
+	case models.ClassStatusLive:
+		sendJSON(w, map[string]interface{}{
+			"error": "Class is already live",
+			"live":  true,
+		}, http.StatusConflict)
+		return
+	case models.ClassStatusCompleted, models.ClassStatusCancelled:
+		sendJSONError(w, "Class has ended", http.StatusBadRequest)
+		return
+	}
+	if schedule.ExamMode || schedule.Proctored {
+		sendJSONError(w, "Exams and proctored classes have no lobby", http.StatusBadRequest)
+		return
+	}
+	if opensIn := schedule.SecondsUntilLobbyOpens(now); opensIn > 0 {
+		sendJSON(w, map[string]interface{}{
+			"error":               "Lobby is not open yet",
+			"serverTime":          now,
+			"lobbyOpensInSeconds": opensIn,
+			"startsInSeconds":     schedule.SecondsUntilStart(now),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	isPresenter := schedule.PresenterID == user.ID
+	if user.Role != models.RoleAdmin && !isPresenter {
+		batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+		if err != nil {
+			sendStoreError(w, "Batch not found", err)
+			return
+		}
+		if !batch.HasStudent(user.ID.Hex()) && !batch.HasAssistant(user.ID.Hex()) {
+			sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+			return
+		}
+	}
+
+	roomID, err := h.scheduleRepo.OpenLobby(r.Context(), scheduleID, strings.ToUpper(primitive.NewObjectID().Hex()[:8]))
+	if err != nil {
+		sendStoreError(w, "Failed to open lobby", err)
+		return
+	}
+
+	materials := schedule.Materials
+	if materials == nil {
+		materials = []models.ClassMaterial{}
+	}
+	sendJSON(w, map[string]interface{}{
+		"roomId":          roomID,
+		"lobby":           true,
+		"isPresenter":     isPresenter,
+		"title":           schedule.Title,
+		"startTime":       schedule.StartTime,
+		"materials":       materials,
+		"serverTime":      now,
+		"startsInSeconds": schedule.SecondsUntilStart(now),
+	}, http.StatusOK)
+}
+
+// Lobby returns the class whose lobby is in a room, or nil if the room isn't
+// the lobby of a class that hasn't started.
+func (h *LobbyHandler) Lobby(ctx context.Context, roomID string) *models.ScheduledClass {
+	schedule, err := h.scheduleRepo.FindByLobbyRoomID(ctx, strings.ToUpper(roomID))
+	if err != nil || schedule.EffectiveStatusAt(time.Now()) != models.ClassStatusScheduled {
+		return nil
+	}
+	return schedule
+}
+
+// GoLive turns the lobby in a room into the live class and tells everyone
+// waiting in it, so clients can start media without rejoining.
+func (h *LobbyHandler) GoLive(roomID string) {
+	r, ok := h.hub.GetRoom(roomID)
+	if !ok || !r.GoLive() {
+		return
+	}
+	log.Printf("[Lobby] Room %s is now live with %d participant(s)", roomID, r.ParticipantCount())
+	r.BroadcastToAll(map[string]interface{}{
+		"type":       "class-live",
+		"roomId":     roomID,
+		"serverTime": time.Now(),
+	}, "")
+}
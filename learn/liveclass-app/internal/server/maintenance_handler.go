@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// MaintenanceHandler lets admins declare maintenance windows and checks
+// classes against them: scheduling into a blocking window is refused,
+// classes overlapping any window carry a banner, and presenters whose
+// classes fall in a newly declared window are told.
+type MaintenanceHandler struct {
+	authService     *auth.Service
+	maintenanceRepo *repository.MaintenanceRepository
+	scheduleRepo    *repository.ScheduleRepository
+	userRepo        *repository.UserRepository
+	notifier        *notify.Notifier
+}
+
+// NewMaintenanceHandler creates a new MaintenanceHandler.
+func NewMaintenanceHandler(authService *auth.Service, maintenanceRepo *repository.MaintenanceRepository, scheduleRepo *repository.ScheduleRepository, userRepo *repository.UserRepository, notifier *notify.Notifier) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		authService:     authService,
+		maintenanceRepo: maintenanceRepo,
+		scheduleRepo:    scheduleRepo,
+		userRepo:        userRepo,
+		notifier:        notifier,
+	}
+}
+
+// Upcoming returns the maintenance windows that haven't ended
+// (GET /api/maintenance), for site-wide banners.
+func (h *MaintenanceHandler) Upcoming(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	windows, err := h.maintenanceRepo.FindUpcoming(r.Context())
+	if err != nil {
+		sendStoreError(w, "Failed to fetch maintenance windows", err)
+		return
+	}
+
+	notices := make([]models.MaintenanceNotice, len(windows))
+	for i := range windows {
+		notices[i] = windows[i].Notice()
+	}
+	sendJSON(w, map[string]interface{}{"windows": notices}, http.StatusOK)
+}
+
+// Windows lists the windows that haven't ended (GET /api/admin/maintenance)
+// or declares a new one (POST /api/admin/maintenance).
+func (h *MaintenanceHandler) Windows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		windows, err := h.maintenanceRepo.FindUpcoming(r.Context())
+		if err != nil {
+			sendStoreError(w, "Failed to fetch maintenance windows", err)
+			return
+		}
+		sendJSON(w, map[string]interface{}{"windows": windows}, http.StatusOK)
+
+	case http.MethodPost:
+		h.create(w, r)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// create declares a maintenance window and returns it with the classes
+// already scheduled in it, whose presenters are notified.
+func (h *MaintenanceHandler) create(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title     string `json:"title" validate:"required,max=200"`
+		Message   string `json:"message" validate:"max=1000"`
+		StartTime string `json:"startTime" validate:"required,rfc3339"`
+		EndTime   string `json:"endTime" validate:"required,rfc3339"`
+		Blocking  bool   `json:"blocking"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	startTime, _ := time.Parse(time.RFC3339, req.StartTime)
+	endTime, _ := time.Parse(time.RFC3339, req.EndTime)
+	if !endTime.After(startTime) {
+		sendJSONError(w, "End time must be after start time", http.StatusBadRequest)
+		return
+	}
+	if !endTime.After(time.Now()) {
+		sendJSONError(w, "The maintenance window has already ended", http.StatusBadRequest)
+		return
+	}
+
+	window := &models.MaintenanceWindow{
+		Title:         strings.TrimSpace(req.Title),
+		Message:       strings.TrimSpace(req.Message),
+		StartTime:     startTime,
+		EndTime:       endTime,
+		Blocking:      req.Blocking,
+		CreatedBy:     admin.ID,
+		CreatedByName: admin.Name,
+	}
+	if err := h.maintenanceRepo.Create(r.Context(), window); err != nil {
+		sendStoreError(w, "Failed to create maintenance window", err)
+		return
+	}
+
+	conflicts, err := h.scheduleRepo.FindScheduledBetween(r.Context(), window.StartTime, window.EndTime)
+	if err != nil {
+		log.Printf("[Maintenance] Failed to find classes in window %s: %v", window.ID.Hex(), err)
+	}
+	response := make([]models.ScheduledClassResponse, len(conflicts))
+	for i := range conflicts {
+		response[i] = conflicts[i].ToResponse()
+	}
+	if len(conflicts) > 0 {
+		go h.notifyConflicts(*window, conflicts)
+	}
+
+	log.Printf("[Maintenance] %s declared %q from %s to %s (%d classes affected)",
+		admin.Name, window.Title, window.StartTime.Format(time.RFC3339), window.EndTime.Format(time.RFC3339), len(conflicts))
+
+	sendJSON(w, map[string]interface{}{
+		"window":    window,
+		"conflicts": response,
+	}, http.StatusCreated)
+}
+
+// Delete removes a maintenance window (DELETE /api/admin/maintenance/{id}).
+func (h *MaintenanceHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/maintenance/")
+	if err := h.maintenanceRepo.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrMaintenanceWindowNotFound) {
+			sendJSONError(w, "Maintenance window not found", http.StatusNotFound)
+			return
+		}
+		sendJSONError(w, "Failed to delete maintenance window", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, map[string]string{"message": "Maintenance window deleted"}, http.StatusOK)
+}
+
+// Overlapping returns the notices of the windows overlapping a class from
+// start to end, and whether one of them blocks scheduling it. Lookup
+// failures are logged and don't block, so scheduling keeps working while
+// the windows can't be read.
+func (h *MaintenanceHandler) Overlapping(ctx context.Context, start, end time.Time) ([]models.MaintenanceNotice, bool) {
+	windows, err := h.maintenanceRepo.FindOverlapping(ctx, start, end)
+	if err != nil {
+		log.Printf("[Maintenance] Failed to check maintenance windows: %v", err)
+		return nil, false
+	}
+
+	var notices []models.MaintenanceNotice
+	blocked := false
+	for i := range windows {
+		notices = append(notices, windows[i].Notice())
+		blocked = blocked || windows[i].Blocking
+	}
+	return notices, blocked
+}
+
+// Annotate sets the maintenance banners of classes, with one lookup for all
+// of them.
+func (h *MaintenanceHandler) Annotate(ctx context.Context, classes []models.ScheduledClassResponse) {
+	if len(classes) == 0 {
+		return
+	}
+
+	start, end := classes[0].StartTime, classes[0].EndTime
+	for _, c := range classes[1:] {
+		if c.StartTime.Before(start) {
+			start = c.StartTime
+		}
+		if c.EndTime.After(end) {
+			end = c.EndTime
+		}
+	}
+	windows, err := h.maintenanceRepo.FindOverlapping(ctx, start, end)
+	if err != nil {
+		log.Printf("[Maintenance] Failed to check maintenance windows: %v", err)
+		return
+	}
+
+	for i := range classes {
+		for j := range windows {
+			if windows[j].Overlaps(classes[i].StartTime, classes[i].EndTime) {
+				classes[i].Maintenance = append(classes[i].Maintenance, windows[j].Notice())
+			}
+		}
+	}
+}
+
+// notifyConflicts tells presenters which of their classes fall in a newly
+// declared maintenance window, one notification per presenter.
+func (h *MaintenanceHandler) notifyConflicts(window models.MaintenanceWindow, classes []models.ScheduledClass) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	titles := make(map[string][]string)
+	var presenters []string
+	for _, c := range classes {
+		id := c.PresenterID.Hex()
+		if _, ok := titles[id]; !ok {
+			presenters = append(presenters, id)
+		}
+		titles[id] = append(titles[id], c.Title+" ("+c.StartTime.UTC().Format("Jan 2 15:04 MST")+")")
+	}
+
+	body := "Maintenance \"" + window.Title + "\" runs from " + window.StartTime.UTC().Format("Jan 2 15:04") +
+		" to " + window.EndTime.UTC().Format("Jan 2 15:04 MST") + " and overlaps: "
+	for _, id := range presenters {
+		presenter, err := h.userRepo.FindByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		h.notifier.Notify(ctx, []models.User{*presenter}, notify.Message{
+			Category: models.NotificationMaintenance,
+			Title:    "Maintenance overlaps your classes",
+			Body:     body + strings.Join(titles[id], ", ") + ". Consider rescheduling.",
+			Email:    true,
+		})
+	}
+}
@@ -0,0 +1,511 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chatlog"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Report errors
+var (
+	errReportNotFound  = errors.New("reported content not found")
+	errReportForbidden = errors.New("you don't have access to this content")
+	errReportOwn       = errors.New("you can't report your own content")
+)
+
+// maxReportExcerpt bounds the content excerpt stored with a report.
+const maxReportExcerpt = 500
+
+// reportTarget is the reported content as seen by the moderation queue.
+type reportTarget struct {
+	authorID   primitive.ObjectID
+	authorName string
+	excerpt    string
+	hidden     bool
+}
+
+// ModerationHandler handles content reports and the admin moderation queue.
+type ModerationHandler struct {
+	authService   *auth.Service
+	reportRepo    *repository.ReportRepository
+	noteRepo      *repository.NoteRepository
+	recordingRepo *repository.RecordingRepository
+	batchRepo     *repository.BatchRepository
+	userRepo      *repository.UserRepository
+	chatLog       *chatlog.Recorder
+	hub           *room.Hub
+	notifier      *notify.Notifier
+	legalHolds    *LegalHoldHandler
+	sessions      *SessionCloser
+	hideThreshold int
+}
+
+// NewModerationHandler creates a new ModerationHandler. Content with
+// hideThreshold open reports is hidden until reviewed (0 disables hiding).
+func NewModerationHandler(authService *auth.Service, reportRepo *repository.ReportRepository, noteRepo *repository.NoteRepository, recordingRepo *repository.RecordingRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, chatLog *chatlog.Recorder, hub *room.Hub, notifier *notify.Notifier, legalHolds *LegalHoldHandler, sessions *SessionCloser, hideThreshold int) *ModerationHandler {
+	return &ModerationHandler{
+		authService:   authService,
+		reportRepo:    reportRepo,
+		noteRepo:      noteRepo,
+		recordingRepo: recordingRepo,
+		batchRepo:     batchRepo,
+		userRepo:      userRepo,
+		chatLog:       chatLog,
+		hub:           hub,
+		notifier:      notifier,
+		legalHolds:    legalHolds,
+		sessions:      sessions,
+		hideThreshold: hideThreshold,
+	}
+}
+
+// Report files a report against a chat message, note or recording (POST /api/reports).
+func (h *ModerationHandler) Report(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ContentType models.ReportContentType `json:"contentType" validate:"required,oneof=chat note recording"`
+		ContentID   string                   `json:"contentId" validate:"required,max=100"`
+		RoomID      string                   `json:"roomId" validate:"max=50"` // Chat messages only
+		Reason      string                   `json:"reason" validate:"required"`
+		Details     string                   `json:"details" validate:"max=1000"` // models.MaxReportDetailsLength
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if !models.ValidReportReason(req.Reason) {
+		sendJSONError(w, "reason must be one of: "+strings.Join(models.ReportReasons, ", "), http.StatusBadRequest)
+		return
+	}
+	req.Details = strings.TrimSpace(req.Details)
+	if req.ContentType == models.ReportContentChat && req.RoomID == "" {
+		sendJSONError(w, "Room ID is required for chat messages", http.StatusBadRequest)
+		return
+	}
+
+	target, err := h.lookup(r.Context(), user, req.ContentType, req.ContentID, req.RoomID)
+	if err != nil {
+		sendReportError(w, err)
+		return
+	}
+	if target.authorID == user.ID {
+		sendReportError(w, errReportOwn)
+		return
+	}
+
+	report := &models.Report{
+		ContentType:  req.ContentType,
+		ContentID:    req.ContentID,
+		AuthorID:     target.authorID,
+		AuthorName:   target.authorName,
+		Excerpt:      target.excerpt,
+		ReporterID:   user.ID,
+		ReporterName: user.Name,
+		Reason:       req.Reason,
+		Details:      req.Details,
+	}
+	if req.ContentType == models.ReportContentChat {
+		report.RoomID = strings.ToUpper(req.RoomID)
+	}
+
+	if err := h.reportRepo.Create(r.Context(), report); err != nil {
+		if errors.Is(err, repository.ErrAlreadyReported) {
+			sendJSONError(w, "You have already reported this content", http.StatusConflict)
+			return
+		}
+		log.Printf("[Moderation] Failed to save report: %v", err)
+		sendJSONError(w, "Failed to submit report", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Moderation] %s reported %s %s (%s)", user.Name, report.ContentType, report.ContentID, report.Reason)
+
+	if !target.hidden {
+		h.hideIfOverThreshold(r.Context(), report)
+	}
+
+	sendJSON(w, map[string]string{"message": "Report submitted", "id": report.ID.Hex()}, http.StatusCreated)
+}
+
+// Queue returns reported content with open reports, oldest first (GET /api/admin/moderation).
+func (h *ModerationHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reports, err := h.reportRepo.FindOpen(r.Context())
+	if err != nil {
+		sendStoreError(w, "Failed to fetch reports", err)
+		return
+	}
+
+	items := make([]*models.ModerationItem, 0)
+	byContent := make(map[string]*models.ModerationItem)
+	for _, rep := range reports {
+		key := string(rep.ContentType) + ":" + rep.ContentID
+		item, ok := byContent[key]
+		if !ok {
+			item = &models.ModerationItem{
+				ContentType:     rep.ContentType,
+				ContentID:       rep.ContentID,
+				RoomID:          rep.RoomID,
+				AuthorName:      rep.AuthorName,
+				Excerpt:         rep.Excerpt,
+				Reasons:         make(map[string]int),
+				FirstReportedAt: rep.CreatedAt,
+			}
+			if !rep.AuthorID.IsZero() {
+				item.AuthorID = rep.AuthorID.Hex()
+			}
+			byContent[key] = item
+			items = append(items, item)
+		}
+		item.ReportCount++
+		item.Reasons[rep.Reason]++
+		item.Reports = append(item.Reports, rep)
+		item.LastReportedAt = rep.CreatedAt
+	}
+
+	for _, item := range items {
+		item.Hidden = h.isHidden(r.Context(), item)
+	}
+
+	sendJSON(w, items, http.StatusOK)
+}
+
+// Resolve applies a moderation action to reported content and closes its
+// open reports (POST /api/admin/moderation/{contentType}/{contentId}).
+// Actions: dismiss (restore the content), delete (remove the content),
+// suspend (suspend the author and keep the content hidden).
+func (h *ModerationHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract content from URL: /api/admin/moderation/{contentType}/{contentId}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/moderation/"), "/")
+	if len(parts) < 2 || parts[1] == "" {
+		sendJSONError(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	contentType, contentID := models.ReportContentType(parts[0]), parts[1]
+
+	var req struct {
+		Action string `json:"action" validate:"required,oneof=dismiss delete suspend"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	reports, err := h.reportRepo.FindOpenByContent(r.Context(), contentType, contentID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch reports", err)
+		return
+	}
+	if len(reports) == 0 {
+		sendJSONError(w, "No open reports for this content", http.StatusNotFound)
+		return
+	}
+	first := reports[0]
+
+	status := models.ReportStatusActioned
+	switch req.Action {
+	case models.ModerationDismiss:
+		status = models.ReportStatusDismissed
+		if err := h.setHidden(r.Context(), first, false); err != nil {
+			log.Printf("[Moderation] Failed to restore %s %s: %v", contentType, contentID, err)
+		}
+
+	case models.ModerationDelete:
+		if err := h.deleteContent(r.Context(), first, admin); err != nil {
+			if errors.Is(err, errUnderLegalHold) {
+				sendJSONError(w, err.Error(), http.StatusConflict)
+				return
+			}
+			log.Printf("[Moderation] Failed to delete %s %s: %v", contentType, contentID, err)
+			sendJSONError(w, "Failed to delete content", http.StatusInternalServerError)
+			return
+		}
+
+	case models.ModerationSuspend:
+		if first.AuthorID.IsZero() {
+			sendJSONError(w, "The author of this content is unknown", http.StatusBadRequest)
+			return
+		}
+		author, err := h.userRepo.FindByID(r.Context(), first.AuthorID.Hex())
+		if err != nil {
+			sendJSONError(w, "Author not found", http.StatusNotFound)
+			return
+		}
+		if author.Role == models.RoleAdmin {
+			sendJSONError(w, "Admins can't be suspended from the moderation queue", http.StatusBadRequest)
+			return
+		}
+		if err := h.sessions.Suspend(r.Context(), author.ID.Hex(), admin.ID.Hex()); err != nil {
+			sendStoreError(w, "Failed to suspend user", err)
+			return
+		}
+		if err := h.setHidden(r.Context(), first, true); err != nil {
+			log.Printf("[Moderation] Failed to hide %s %s: %v", contentType, contentID, err)
+		}
+
+	default:
+		sendJSONError(w, "Invalid action. Must be: dismiss, delete, or suspend", http.StatusBadRequest)
+		return
+	}
+
+	resolved, err := h.reportRepo.Resolve(r.Context(), contentType, contentID, status, req.Action, admin.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to resolve reports", err)
+		return
+	}
+
+	log.Printf("[Moderation] %s %s %s: %s (%d reports closed)", admin.Name, req.Action, contentType, contentID, resolved)
+
+	sendJSON(w, map[string]interface{}{
+		"message":  "Reports resolved",
+		"action":   req.Action,
+		"resolved": resolved,
+	}, http.StatusOK)
+}
+
+// lookup loads reported content the user has access to.
+func (h *ModerationHandler) lookup(ctx context.Context, user *models.User, contentType models.ReportContentType, contentID, roomID string) (*reportTarget, error) {
+	switch contentType {
+	case models.ReportContentChat:
+		liveRoom, ok := h.hub.GetRoom(roomID)
+		if !ok {
+			return nil, errReportNotFound
+		}
+		if !liveRoom.HasUser(user.ID.Hex()) {
+			return nil, errReportForbidden
+		}
+		entry, ok := liveRoom.Transcript.Find(contentID)
+		if !ok {
+			return nil, errReportNotFound
+		}
+		target := &reportTarget{authorName: entry.Name, excerpt: truncate(entry.Text, maxReportExcerpt)}
+		target.authorID, _ = primitive.ObjectIDFromHex(entry.UserID)
+		return target, nil
+
+	case models.ReportContentNote:
+		noteID, err := primitive.ObjectIDFromHex(contentID)
+		if err != nil {
+			return nil, errReportNotFound
+		}
+		note, err := h.noteRepo.FindByID(ctx, noteID)
+		if err != nil {
+			return nil, errReportNotFound
+		}
+		if !h.canAccessBatch(ctx, user, note.BatchID) {
+			return nil, errReportForbidden
+		}
+		return &reportTarget{
+			authorID:   note.UploaderID,
+			authorName: note.UploaderName,
+			excerpt:    truncate(note.Title, maxReportExcerpt),
+			hidden:     note.Hidden,
+		}, nil
+
+	case models.ReportContentRecording:
+		recording, err := h.recordingRepo.FindByID(ctx, contentID)
+		if err != nil {
+			return nil, errReportNotFound
+		}
+		if !h.canAccessBatch(ctx, user, recording.BatchID) {
+			return nil, errReportForbidden
+		}
+		target := &reportTarget{
+			authorID: recording.PresenterID,
+			excerpt:  truncate(recording.Title, maxReportExcerpt),
+			hidden:   recording.Hidden,
+		}
+		if presenter, err := h.userRepo.FindByID(ctx, recording.PresenterID.Hex()); err == nil {
+			target.authorName = presenter.Name
+		}
+		return target, nil
+	}
+
+	return nil, errReportNotFound
+}
+
+// canAccessBatch reports whether the user can see content of a batch.
+func (h *ModerationHandler) canAccessBatch(ctx context.Context, user *models.User, batchID primitive.ObjectID) bool {
+	if user.Role == models.RoleAdmin {
+		return true
+	}
+	batch, err := h.batchRepo.FindByID(ctx, batchID.Hex())
+	if err != nil {
+		return false
+	}
+	userID := user.ID.Hex()
+	return batch.PresenterID == user.ID || batch.HasStudent(userID) || batch.HasAssistant(userID)
+}
+
+// hideIfOverThreshold hides the reported content once it has enough open
+// reports and lets the admins know it's waiting for review.
+func (h *ModerationHandler) hideIfOverThreshold(ctx context.Context, report *models.Report) {
+	if h.hideThreshold <= 0 {
+		return
+	}
+
+	count, err := h.reportRepo.CountOpen(ctx, report.ContentType, report.ContentID)
+	if err != nil || count < int64(h.hideThreshold) {
+		return
+	}
+
+	if err := h.setHidden(ctx, *report, true); err != nil {
+		log.Printf("[Moderation] Failed to hide %s %s: %v", report.ContentType, report.ContentID, err)
+		return
+	}
+	log.Printf("[Moderation] Hid %s %s after %d reports", report.ContentType, report.ContentID, count)
+
+	// Only the report that crosses the threshold notifies
+	if count == int64(h.hideThreshold) {
+		go h.notifier.NotifyAdmins(context.Background(), notify.Message{
+			Category: models.NotificationContentHidden,
+			Title:    fmt.Sprintf("A %s was hidden after %d reports", report.ContentType, count),
+			Body:     fmt.Sprintf("%q by %s is hidden until it's reviewed in the moderation queue.", report.Excerpt, report.AuthorName),
+			Link:     "/admin",
+		})
+	}
+}
+
+// setHidden hides or restores reported content. Chat messages are hidden on
+// the connected clients; they're gone once the class ends anyway.
+func (h *ModerationHandler) setHidden(ctx context.Context, report models.Report, hidden bool) error {
+	switch report.ContentType {
+	case models.ReportContentChat:
+		if liveRoom, ok := h.hub.GetRoom(report.RoomID); ok {
+			liveRoom.SetChatMessageHidden(report.ContentID, hidden)
+		}
+		return nil
+	case models.ReportContentNote:
+		noteID, err := primitive.ObjectIDFromHex(report.ContentID)
+		if err != nil {
+			return err
+		}
+		return h.noteRepo.SetHidden(ctx, noteID, hidden)
+	case models.ReportContentRecording:
+		return h.recordingRepo.SetHidden(ctx, report.ContentID, hidden)
+	}
+	return nil
+}
+
+// isHidden reports whether queued content is currently hidden.
+func (h *ModerationHandler) isHidden(ctx context.Context, item *models.ModerationItem) bool {
+	switch item.ContentType {
+	case models.ReportContentNote:
+		if noteID, err := primitive.ObjectIDFromHex(item.ContentID); err == nil {
+			if note, err := h.noteRepo.FindByID(ctx, noteID); err == nil {
+				return note.Hidden
+			}
+		}
+	case models.ReportContentRecording:
+		if recording, err := h.recordingRepo.FindByID(ctx, item.ContentID); err == nil {
+			return recording.Hidden
+		}
+	case models.ReportContentChat:
+		return h.hideThreshold > 0 && item.ReportCount >= h.hideThreshold
+	}
+	return false
+}
+
+// deleteContent removes reported content and its stored file. Notes and
+// recordings under legal hold are left in place.
+func (h *ModerationHandler) deleteContent(ctx context.Context, report models.Report, admin *models.User) error {
+	switch report.ContentType {
+	case models.ReportContentChat:
+		if liveRoom, ok := h.hub.GetRoom(report.RoomID); ok {
+			liveRoom.DeleteChatMessage(report.ContentID, "a moderator")
+		}
+		// Also from the history, after the class too
+		h.chatLog.Deleted(report.ContentID, "a moderator")
+		return nil
+
+	case models.ReportContentNote:
+		noteID, err := primitive.ObjectIDFromHex(report.ContentID)
+		if err != nil {
+			return err
+		}
+		note, err := h.noteRepo.FindByID(ctx, noteID)
+		if err != nil {
+			return nil // Already gone
+		}
+		if err := h.legalHolds.Guard(ctx, models.HoldContentNote, report.ContentID, models.LegalHoldDeleteBlocked, admin); err != nil {
+			return err
+		}
+		for _, path := range note.FilePaths() {
+			if err := os.Remove(path); err != nil {
+				log.Printf("[Moderation] Warning: Failed to delete note file: %v", err)
+			}
+		}
+		return h.noteRepo.Delete(ctx, noteID)
+
+	case models.ReportContentRecording:
+		recording, err := h.recordingRepo.FindByID(ctx, report.ContentID)
+		if err != nil {
+			return nil // Already gone
+		}
+		if err := h.legalHolds.Guard(ctx, models.HoldContentRecording, report.ContentID, models.LegalHoldDeleteBlocked, admin); err != nil {
+			return err
+		}
+		if err := os.Remove(recording.FilePath); err != nil {
+			log.Printf("[Moderation] Warning: Failed to delete recording file: %v", err)
+		}
+		return h.recordingRepo.Delete(ctx, report.ContentID)
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// sendReportError maps report errors to HTTP responses.
+func sendReportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errReportNotFound):
+		sendJSONError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errReportForbidden), errors.Is(err, errReportOwn):
+		sendJSONError(w, err.Error(), http.StatusForbidden)
+	default:
+		sendJSONError(w, "Failed to submit report", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// nameLookup fills batch and user names into responses. Documents carry
+// snapshots of these names, so lookups only happen for documents written
+// before snapshots existed (until the reconciler backfills them), and each ID
+// is looked up at most once per lookup.
+type nameLookup struct {
+	ctx       context.Context
+	batchRepo *repository.BatchRepository
+	userRepo  *repository.UserRepository
+	batches   map[primitive.ObjectID]string
+	users     map[primitive.ObjectID]string
+}
+
+// newNameLookup creates a lookup for one request.
+func newNameLookup(ctx context.Context, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository) *nameLookup {
+	return &nameLookup{
+		ctx:       ctx,
+		batchRepo: batchRepo,
+		userRepo:  userRepo,
+		batches:   make(map[primitive.ObjectID]string),
+		users:     make(map[primitive.ObjectID]string),
+	}
+}
+
+// Batch returns snapshot if set, otherwise the name of batch id.
+func (n *nameLookup) Batch(id primitive.ObjectID, snapshot string) string {
+	if snapshot != "" {
+		return snapshot
+	}
+	name, ok := n.batches[id]
+	if !ok {
+		if batch, err := n.batchRepo.FindByID(n.ctx, id.Hex()); err == nil {
+			name = batch.Name
+		}
+		n.batches[id] = name
+	}
+	return name
+}
+
+// User returns snapshot if set, otherwise the name of user id.
+func (n *nameLookup) User(id primitive.ObjectID, snapshot string) string {
+	if snapshot != "" {
+		return snapshot
+	}
+	name, ok := n.users[id]
+	if !ok {
+		if user, err := n.userRepo.FindByID(n.ctx, id.Hex()); err == nil {
+			name = user.Name
+		}
+		n.users[id] = name
+	}
+	return name
+}
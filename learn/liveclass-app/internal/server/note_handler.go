@@ -1,33 +1,50 @@
 package server
 
 import (
+	"archive/zip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/analytics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/convert"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/filetype"
+	"github.com/jinshatcp/brightline-academy/learn/internal/imaging"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // NoteHandler handles note/document related requests.
 type NoteHandler struct {
-	authService *auth.Service
-	noteRepo    *repository.NoteRepository
-	batchRepo   *repository.BatchRepository
-	userRepo    *repository.UserRepository
-	storagePath string
+	noteRepo     *repository.NoteRepository
+	batchRepo    *repository.BatchRepository
+	scheduleRepo *repository.ScheduleRepository
+	userRepo     *repository.UserRepository
+	legalHolds   *LegalHoldHandler
+	analytics    *analytics.Exporter
+	files        *encryption.Encryptor // nil stores files in plaintext
+	images       *imaging.Optimizer
+	pdfs         *convert.Worker
+	storagePath  string
 }
 
 // NewNoteHandler creates a new note handler.
-func NewNoteHandler(authService *auth.Service, noteRepo *repository.NoteRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, storagePath string) *NoteHandler {
+func NewNoteHandler(noteRepo *repository.NoteRepository, batchRepo *repository.BatchRepository, scheduleRepo *repository.ScheduleRepository, userRepo *repository.UserRepository, legalHolds *LegalHoldHandler, exporter *analytics.Exporter, files *encryption.Encryptor, images *imaging.Optimizer, pdfs *convert.Worker, storagePath string) *NoteHandler {
 	// Ensure notes directory exists
 	notesPath := filepath.Join(storagePath, "notes")
 	if err := os.MkdirAll(notesPath, 0755); err != nil {
@@ -35,27 +52,24 @@ func NewNoteHandler(authService *auth.Service, noteRepo *repository.NoteReposito
 	}
 
 	return &NoteHandler{
-		authService: authService,
-		noteRepo:    noteRepo,
-		batchRepo:   batchRepo,
-		userRepo:    userRepo,
-		storagePath: storagePath,
+		noteRepo:     noteRepo,
+		batchRepo:    batchRepo,
+		scheduleRepo: scheduleRepo,
+		userRepo:     userRepo,
+		legalHolds:   legalHolds,
+		analytics:    exporter,
+		files:        files,
+		images:       images,
+		pdfs:         pdfs,
+		storagePath:  storagePath,
 	}
 }
 
 // Upload handles document upload (POST /api/notes).
-// Access: Admin and Presenter only.
+// Access: Admin, Presenter, and teaching assistants of the target batch.
 func (h *NoteHandler) Upload(w http.ResponseWriter, r *http.Request) {
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
-		return
-	}
-
-	// Only admin and presenter can upload
-	if user.Role != models.RoleAdmin && user.Role != models.RolePresenter {
-		http.Error(w, `{"error":"Permission denied"}`, http.StatusForbidden)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -65,15 +79,30 @@ func (h *NoteHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get form values
-	title := r.FormValue("title")
-	description := r.FormValue("description")
-	batchIDStr := r.FormValue("batchId")
-
-	if title == "" || batchIDStr == "" {
-		http.Error(w, `{"error":"Title and batch ID are required"}`, http.StatusBadRequest)
+	// Get form values. Notes can be held back from students until a class
+	// ends (publishAfterClass, a schedule ID) and/or a time (publishAt),
+	// targeted at groups of the batch (repeated groupIds), and added to a
+	// library for reuse in other batches.
+	form := struct {
+		Title             string   `json:"title" validate:"required,max=200"`
+		Description       string   `json:"description" validate:"max=2000"`
+		BatchID           string   `json:"batchId" validate:"required,objectid"`
+		PublishAfterClass string   `json:"publishAfterClass" validate:"objectid"`
+		PublishAt         string   `json:"publishAt" validate:"rfc3339"`
+		Library           string   `json:"library" validate:"oneof=personal department"`
+		Department        string   `json:"department" validate:"max=100"`
+		Topic             string   `json:"topic" validate:"max=200"`
+		GroupIDs          []string `json:"groupIds" validate:"max=50,objectid"`
+	}{r.FormValue("title"), r.FormValue("description"), r.FormValue("batchId"), r.FormValue("publishAfterClass"), r.FormValue("publishAt"),
+		r.FormValue("library"), strings.TrimSpace(r.FormValue("department")), cleanTopic(r.FormValue("topic")), r.PostForm["groupIds"]}
+	if !checkRequest(w, &form) {
+		return
+	}
+	if form.Library == string(models.NoteLibraryDepartment) && form.Department == "" {
+		http.Error(w, `{"error":"department is required for department library items"}`, http.StatusBadRequest)
 		return
 	}
+	title, description, batchIDStr := form.Title, form.Description, form.BatchID
 
 	// Verify batch exists
 	batch, err := h.batchRepo.FindByID(r.Context(), batchIDStr)
@@ -82,52 +111,56 @@ func (h *NoteHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	batchID := batch.ID
-
-	// Get the file
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, `{"error":"No file uploaded"}`, http.StatusBadRequest)
+	// Only admin, presenter and the batch's assistants can upload
+	if user.Role != models.RoleAdmin && user.Role != models.RolePresenter && !batch.HasAssistant(user.ID.Hex()) {
+		http.Error(w, `{"error":"Permission denied"}`, http.StatusForbidden)
 		return
 	}
-	defer file.Close()
 
-	// Validate file type
-	mimeType := header.Header.Get("Content-Type")
-	if !isAllowedFileType(mimeType) {
-		http.Error(w, `{"error":"File type not allowed. Supported: PDF, Word, Excel, PowerPoint, images, and text files"}`, http.StatusBadRequest)
+	batchID := batch.ID
+
+	groupIDs, ok := batchGroups(w, batch, form.GroupIDs)
+	if !ok {
 		return
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
-	uniqueName := primitive.NewObjectID().Hex() + "_" + time.Now().Format("20060102_150405") + ext
-	filePath := filepath.Join(h.storagePath, "notes", uniqueName)
-
-	// Save file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		log.Printf("[Notes] Failed to create file: %v", err)
-		http.Error(w, `{"error":"Failed to save file"}`, http.StatusInternalServerError)
-		return
+	var publishScheduleID *primitive.ObjectID
+	if form.PublishAfterClass != "" {
+		schedule, err := h.scheduleRepo.FindByID(r.Context(), form.PublishAfterClass)
+		if err != nil || schedule.BatchID != batchID {
+			http.Error(w, `{"error":"Class not found in this batch"}`, http.StatusBadRequest)
+			return
+		}
+		if schedule.Status == models.ClassStatusCompleted || schedule.Status == models.ClassStatusCancelled {
+			http.Error(w, `{"error":"This class has already ended"}`, http.StatusBadRequest)
+			return
+		}
+		publishScheduleID = &schedule.ID
+	}
+	var publishAt *time.Time
+	if form.PublishAt != "" {
+		at, _ := time.Parse(time.RFC3339, form.PublishAt)
+		if !at.After(time.Now()) {
+			http.Error(w, `{"error":"Publish time must be in the future"}`, http.StatusBadRequest)
+			return
+		}
+		publishAt = &at
 	}
-	defer dst.Close()
 
-	fileSize, err := io.Copy(dst, file)
-	if err != nil {
-		log.Printf("[Notes] Failed to save file content: %v", err)
-		os.Remove(filePath)
-		http.Error(w, `{"error":"Failed to save file"}`, http.StatusInternalServerError)
+	stored, ok := h.saveUpload(w, r)
+	if !ok {
 		return
 	}
+	mimeType, filePath := stored.kind.MimeType, stored.path
 
 	// Create note record
 	note := &models.Note{
 		Title:        title,
-		Description:  description,
-		FileName:     header.Filename,
+		Description:  richtext.Rich.Sanitize(description),
+		Topic:        form.Topic,
+		FileName:     stored.name,
 		FilePath:     filePath,
-		FileSize:     fileSize,
+		FileSize:     stored.size,
 		FileType:     models.GetNoteType(mimeType),
 		MimeType:     mimeType,
 		BatchID:      batchID,
@@ -135,6 +168,15 @@ func (h *NoteHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		UploaderID:   user.ID,
 		UploaderName: user.Name,
 		UploaderRole: string(user.Role),
+
+		Unpublished:       publishScheduleID != nil || publishAt != nil,
+		PublishScheduleID: publishScheduleID,
+		PublishAt:         publishAt,
+
+		Library:    models.NoteLibrary(form.Library),
+		Department: form.Department,
+
+		GroupIDs: groupIDs,
 	}
 
 	if err := h.noteRepo.Create(r.Context(), note); err != nil {
@@ -147,26 +189,306 @@ func (h *NoteHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	// Set download URL
 	note.DownloadURL = "/api/notes/" + note.ID.Hex() + "/download"
 
+	// Smaller copies of images and PDFs of documents are made in the background
+	h.images.Enqueue(note)
+	h.pdfs.Enqueue(note)
+
 	log.Printf("[Notes] Uploaded: %s by %s (role: %s) for batch %s",
 		note.Title, user.Name, user.Role, note.BatchName)
+	if note.Unpublished {
+		log.Printf("[Notes] %s is held back from students until published", note.Title)
+	}
+
+	h.analytics.Record(analytics.Event{
+		Type:    analytics.EventUpload,
+		UserID:  user.ID.Hex(),
+		Role:    string(user.Role),
+		RefType: "note",
+		RefID:   note.ID.Hex(),
+		Value:   note.FileSize,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(note)
 }
 
+// Limits of bulk note uploads. Archives are expanded on the server, so what
+// they expand to is bounded as well as the upload.
+const (
+	maxBulkNoteArchive = 200 << 20
+	maxBulkNoteFiles   = 200
+	maxBulkNoteFile    = 50 << 20 // Same as single uploads
+	maxBulkNoteTotal   = 1 << 30
+)
+
+// bulkNoteResult is what happened to one file of a bulk upload.
+type bulkNoteResult struct {
+	Path   string       `json:"path"`
+	Status string       `json:"status"` // "created", "skipped" or "failed"
+	Error  string       `json:"error,omitempty"`
+	Note   *models.Note `json:"note,omitempty"`
+}
+
+// BulkUpload creates a note for each file of a ZIP archive
+// (POST /api/notes/bulk, multipart with "file", "batchId" and optionally
+// "topics"). With topics=true each note's topic is its folder in the archive,
+// less a top-level folder wrapping everything. Files of types notes don't
+// accept are skipped, and the response reports on every file.
+// Access: Admin, Presenter, and teaching assistants of the target batch.
+func (h *NoteHandler) BulkUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkNoteArchive+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		sendJSONError(w, "Archive too large or invalid form", http.StatusBadRequest)
+		return
+	}
+	form := struct {
+		BatchID string `json:"batchId" validate:"required,objectid"`
+		Topics  string `json:"topics" validate:"oneof=true false"`
+	}{r.FormValue("batchId"), r.FormValue("topics")}
+	if !checkRequest(w, &form) {
+		return
+	}
+
+	batch, err := h.batchRepo.FindByID(r.Context(), form.BatchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+	if user.Role != models.RoleAdmin && user.Role != models.RolePresenter && !batch.HasAssistant(user.ID.Hex()) {
+		sendJSONError(w, "Permission denied", http.StatusForbidden)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		sendJSONError(w, "No file uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		sendJSONError(w, "The file isn't a ZIP archive", http.StatusBadRequest)
+		return
+	}
+
+	var entries []*zip.File
+	for _, f := range archive.File {
+		if !f.FileInfo().IsDir() && !systemFile(f.Name) {
+			entries = append(entries, f)
+		}
+	}
+	if len(entries) == 0 {
+		sendJSONError(w, "The archive has no files", http.StatusBadRequest)
+		return
+	}
+	if len(entries) > maxBulkNoteFiles {
+		sendJSONError(w, fmt.Sprintf("An archive can have at most %d files", maxBulkNoteFiles), http.StatusBadRequest)
+		return
+	}
+
+	root := ""
+	if form.Topics == "true" {
+		root = commonFolder(entries)
+	}
+
+	results := make([]bulkNoteResult, 0, len(entries))
+	var total int64
+	created, skipped := 0, 0
+	for _, f := range entries {
+		result := bulkNoteResult{Path: f.Name, Status: "failed"}
+
+		switch {
+		case !fs.ValidPath(f.Name):
+			result.Error = "Invalid path"
+		case f.UncompressedSize64 > maxBulkNoteFile:
+			result.Error = "File is larger than 50 MB"
+		case total >= maxBulkNoteTotal:
+			result.Error = "The archive expands to more than 1 GB"
+		default:
+			topic := ""
+			if form.Topics == "true" {
+				topic = cleanTopic(strings.TrimPrefix(path.Dir(f.Name), root))
+			}
+			note, extracted, err := h.bulkNote(r.Context(), f, user, batch, topic, maxBulkNoteTotal-total)
+			total += extracted
+			switch {
+			case errors.Is(err, filetype.ErrUnsupported):
+				result.Status, result.Error = "skipped", "File type not allowed"
+				skipped++
+			case errors.Is(err, filetype.ErrMismatch):
+				result.Error = "The file extension doesn't match the file's content"
+			case errors.Is(err, errBulkNoteTooLarge):
+				result.Error = "File is larger than 50 MB"
+			case errors.Is(err, errBulkArchiveTooLarge):
+				result.Error = "The archive expands to more than 1 GB"
+			case err != nil:
+				result.Error = "Failed to save file"
+			default:
+				result.Status, result.Note = "created", note
+				created++
+			}
+		}
+		results = append(results, result)
+	}
+
+	log.Printf("[Notes] Bulk upload by %s for batch %s: %d created, %d skipped, %d failed",
+		user.Name, batch.Name, created, skipped, len(results)-created-skipped)
+
+	status := http.StatusOK
+	if created > 0 {
+		status = http.StatusCreated
+	}
+	sendJSON(w, map[string]interface{}{
+		"created": created,
+		"skipped": skipped,
+		"failed":  len(results) - created - skipped,
+		"files":   results,
+	}, status)
+}
+
+var (
+	// errBulkNoteTooLarge is returned for archive files expanding to more
+	// than a single upload allows, whatever size they declare.
+	errBulkNoteTooLarge = errors.New("file too large")
+	// errBulkArchiveTooLarge is returned for the archive file whose
+	// extracted bytes take the archive over maxBulkNoteTotal.
+	errBulkArchiveTooLarge = errors.New("archive too large")
+)
+
+// bulkNote extracts a file of an archive and creates its note. The file is
+// extracted to a temporary file first, as its type is checked by content,
+// and at most remaining bytes of the archive's total are extracted. It
+// returns the bytes extracted, which count towards the total even when the
+// note isn't created.
+func (h *NoteHandler) bulkNote(ctx context.Context, f *zip.File, user *models.User, batch *models.Batch, topic string, remaining int64) (*models.Note, int64, error) {
+	src, err := f.Open()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Join(h.storagePath, "notes"), "bulk-*")
+	if err != nil {
+		log.Printf("[Notes] Failed to create temporary file: %v", err)
+		return nil, 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Declared sizes aren't trusted: only the bytes actually extracted count
+	limit := min(int64(maxBulkNoteFile), remaining)
+	size, err := io.Copy(tmp, io.LimitReader(src, limit+1))
+	if err != nil {
+		return nil, size, err
+	}
+	switch {
+	case size > maxBulkNoteFile:
+		return nil, size, errBulkNoteTooLarge
+	case size > remaining:
+		return nil, size, errBulkArchiveTooLarge
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, size, err
+	}
+
+	name := path.Base(f.Name)
+	stored, err := h.store(ctx, tmp, size, name, "")
+	if err != nil {
+		return nil, size, err
+	}
+
+	note := &models.Note{
+		Title:        strings.TrimSuffix(name, path.Ext(name)),
+		Topic:        topic,
+		FileName:     stored.name,
+		FilePath:     stored.path,
+		FileSize:     stored.size,
+		FileType:     models.GetNoteType(stored.kind.MimeType),
+		MimeType:     stored.kind.MimeType,
+		BatchID:      batch.ID,
+		BatchName:    batch.Name,
+		UploaderID:   user.ID,
+		UploaderName: user.Name,
+		UploaderRole: string(user.Role),
+	}
+	if err := h.noteRepo.Create(ctx, note); err != nil {
+		log.Printf("[Notes] Failed to create note record: %v", err)
+		os.Remove(stored.path)
+		return nil, size, err
+	}
+	note.DownloadURL = "/api/notes/" + note.ID.Hex() + "/download"
+	h.images.Enqueue(note)
+	h.pdfs.Enqueue(note)
+
+	h.analytics.Record(analytics.Event{
+		Type:    analytics.EventUpload,
+		UserID:  user.ID.Hex(),
+		Role:    string(user.Role),
+		RefType: "note",
+		RefID:   note.ID.Hex(),
+		Value:   note.FileSize,
+	})
+	return note, size, nil
+}
+
+// systemFile reports whether an archive path is metadata added by the
+// archiver or the OS rather than a file the presenter meant to upload.
+func systemFile(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") ||
+		strings.EqualFold(base, "Thumbs.db") || strings.EqualFold(base, "desktop.ini")
+}
+
+// commonFolder returns the top-level folder wrapping every entry, with a
+// trailing slash, or "" if there is none.
+func commonFolder(entries []*zip.File) string {
+	root := ""
+	for i, f := range entries {
+		folder, _, nested := strings.Cut(f.Name, "/")
+		if !nested || (i > 0 && folder != root) {
+			return ""
+		}
+		root = folder
+	}
+	return root + "/"
+}
+
+// cleanTopic normalizes a topic path: "/Week 1//Slides/" becomes
+// "Week 1/Slides".
+func cleanTopic(topic string) string {
+	var parts []string
+	for _, part := range strings.Split(strings.ReplaceAll(topic, "\\", "/"), "/") {
+		if part = strings.TrimSpace(part); part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
 // ListNotes handles listing notes (GET /api/notes).
 // Access: Admin sees all, Presenter sees their uploads + batches they teach, Student sees their batch notes.
+// Teaching assistants also see the notes of the batches they assist.
 func (h *NoteHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
 	ctx := r.Context()
 	var notes []*models.Note
+	var err error
 
 	switch user.Role {
 	case models.RoleAdmin:
@@ -180,6 +502,7 @@ func (h *NoteHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 		if batchErr != nil {
 			log.Printf("[Notes] Error finding presenter batches: %v", batchErr)
 		}
+		batches = append(append([]models.Batch(nil), batches...), h.assistedBatches(ctx, user)...)
 
 		// Get notes from presenter's batches
 		var batchIDs []primitive.ObjectID
@@ -203,6 +526,7 @@ func (h *NoteHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, `{"error":"Failed to find batches"}`, http.StatusInternalServerError)
 			return
 		}
+		batches = append(append([]models.Batch(nil), batches...), h.assistedBatches(ctx, user)...)
 
 		if len(batches) == 0 {
 			notes = []*models.Note{}
@@ -225,29 +549,58 @@ func (h *NoteHandler) ListNotes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reported content stays hidden from everyone but admins until reviewed
+	if user.Role != models.RoleAdmin {
+		visible := make([]*models.Note, 0, len(notes))
+		for _, note := range notes {
+			if !note.Hidden {
+				visible = append(visible, note)
+			}
+		}
+		notes = visible
+	}
+
+	// Students only see held-back materials of the batches they assist, and
+	// materials targeted at groups they're in
+	if user.Role == models.RoleStudent {
+		assisted := make(map[primitive.ObjectID]bool)
+		for _, b := range h.assistedBatches(ctx, user) {
+			assisted[b.ID] = true
+		}
+		enrolled, _ := h.batchRepo.FindByStudent(ctx, user.ID.Hex())
+		visible := make([]*models.Note, 0, len(notes))
+		for _, note := range notes {
+			if assistsNote(assisted, note) || (!note.Unpublished && inNoteGroups(enrolled, user.ID, note)) {
+				visible = append(visible, note)
+			}
+		}
+		notes = visible
+	}
+
 	// Set download URLs
 	for _, note := range notes {
 		note.DownloadURL = "/api/notes/" + note.ID.Hex() + "/download"
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(notes)
+	sendJSONFields(w, r, notes, http.StatusOK)
 }
 
-// Download handles file download (GET /api/notes/{id}/download).
-// Access: Admin always, Presenter if in their batches, Student if in their batch.
+// Download handles file download (GET /api/notes/{id}/download?width=).
+// For images, width picks the smallest variant at least that wide, falling
+// back to the original. GET /api/notes/{id}/preview serves what clients
+// show inline instead: the PDF copy of Word and PowerPoint documents.
+// Access: Admin always, Presenter if in their batches, Student if in their batch, assistants of the batch.
+// Library items are accessible in every batch they are linked to, and to presenters who can reuse them.
 func (h *NoteHandler) Download(w http.ResponseWriter, r *http.Request) {
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
 	// Extract note ID from URL
 	path := strings.TrimPrefix(r.URL.Path, "/api/notes/")
 	parts := strings.Split(path, "/")
-	if len(parts) < 2 || parts[1] != "download" {
+	if len(parts) < 2 || (parts[1] != "download" && parts[1] != "preview") {
 		http.Error(w, `{"error":"Invalid URL"}`, http.StatusBadRequest)
 		return
 	}
@@ -264,17 +617,43 @@ func (h *NoteHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check access permissions
+	if note.Hidden && user.Role != models.RoleAdmin {
+		http.Error(w, `{"error":"This note is hidden pending review"}`, http.StatusForbidden)
+		return
+	}
+
+	// Check access permissions (assistants can access their assisted batches)
 	hasAccess := false
+	assists := false
+	for _, b := range h.assistedBatches(r.Context(), user) {
+		if note.InBatch(b.ID) {
+			hasAccess = true
+			assists = true
+			break
+		}
+	}
+
+	if note.Unpublished && user.Role == models.RoleStudent && !assists {
+		http.Error(w, `{"error":"This note hasn't been published yet"}`, http.StatusForbidden)
+		return
+	}
+	if user.Role == models.RoleStudent && !assists {
+		enrolled, _ := h.batchRepo.FindByStudent(r.Context(), user.ID.Hex())
+		if !inNoteGroups(enrolled, user.ID, note) {
+			http.Error(w, `{"error":"Access denied"}`, http.StatusForbidden)
+			return
+		}
+	}
 
 	switch user.Role {
 	case models.RoleAdmin:
 		hasAccess = true
 	case models.RolePresenter:
-		// Presenter can access notes from their batches
+		// Presenter can access notes from their batches and library items they can reuse
+		hasAccess = canReuseNote(user, note)
 		batches, _ := h.batchRepo.FindByPresenter(r.Context(), user.ID.Hex())
 		for _, b := range batches {
-			if b.ID == note.BatchID {
+			if note.InBatch(b.ID) {
 				hasAccess = true
 				break
 			}
@@ -283,7 +662,7 @@ func (h *NoteHandler) Download(w http.ResponseWriter, r *http.Request) {
 		// Student can access notes from batches they're enrolled in
 		batches, _ := h.batchRepo.FindByStudent(r.Context(), user.ID.Hex())
 		for _, b := range batches {
-			if b.ID == note.BatchID {
+			if note.InBatch(b.ID) {
 				hasAccess = true
 				break
 			}
@@ -295,33 +674,47 @@ func (h *NoteHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Open file
-	file, err := os.Open(note.FilePath)
-	if err != nil {
-		log.Printf("[Notes] File not found: %s", note.FilePath)
-		http.Error(w, `{"error":"File not found"}`, http.StatusNotFound)
+	if parts[1] == "preview" {
+		h.servePreview(w, r, note)
 		return
 	}
-	defer file.Close()
-
-	// Set headers for download
-	w.Header().Set("Content-Type", note.MimeType)
-	w.Header().Set("Content-Disposition", "inline; filename=\""+note.FileName+"\"")
-	w.Header().Set("Cache-Control", "private, max-age=3600")
 
 	log.Printf("[Notes] Download: %s by %s (role: %s)", note.Title, user.Name, user.Role)
+	h.serveFile(w, r, note)
+}
 
-	// Stream file
-	io.Copy(w, file)
+// servePreview sends the file clients show for a note inline: PDFs, images
+// (with ?width=) and text as uploaded, and the PDF copy of Word and PowerPoint documents.
+// While the copy is being made it answers 202 with its status, so clients
+// can poll.
+func (h *NoteHandler) servePreview(w http.ResponseWriter, r *http.Request, note *models.Note) {
+	switch {
+	case note.FileType == models.NoteTypePDF, note.FileType == models.NoteTypeImage, note.MimeType == "text/plain":
+		h.serveFile(w, r, note)
+
+	case !note.Convertible() || !h.pdfs.Enabled():
+		sendJSONError(w, "This file can't be previewed; download it instead", http.StatusUnsupportedMediaType)
+
+	case note.PDF == nil || note.PDF.Status == models.NotePDFConverting:
+		sendJSON(w, map[string]string{"status": string(models.NotePDFConverting)}, http.StatusAccepted)
+
+	case note.PDF.Status == models.NotePDFFailed:
+		sendJSONError(w, note.PDF.Error, http.StatusUnprocessableEntity)
+
+	default:
+		preview := *note
+		preview.FilePath, preview.MimeType = note.PDF.FilePath, "application/pdf"
+		preview.FileName = strings.TrimSuffix(note.FileName, filepath.Ext(note.FileName)) + ".pdf"
+		preview.Variants = nil
+		h.serveFile(w, r, &preview)
+	}
 }
 
 // Update handles note update (PUT /api/notes/{id}).
 // Access: Admin only.
 func (h *NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -345,20 +738,38 @@ func (h *NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse update data
+	if err := h.legalHolds.Guard(r.Context(), models.HoldContentNote, note.ID.Hex(), models.LegalHoldEditBlocked, user); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	}
+
+	// Parse update data. groupIds, if given, retargets the note; [] is the
+	// whole batch.
 	var updateData struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		Title       string   `json:"title" validate:"max=200"`
+		Description string   `json:"description" validate:"max=2000"`
+		GroupIDs    []string `json:"groupIds" validate:"max=50,objectid"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
-		http.Error(w, `{"error":"Invalid request body"}`, http.StatusBadRequest)
+	if !decodeJSON(w, r, &updateData) {
 		return
 	}
 
+	if updateData.GroupIDs != nil {
+		batch, err := h.batchRepo.FindByID(r.Context(), note.BatchID.Hex())
+		if err != nil {
+			http.Error(w, `{"error":"Batch not found"}`, http.StatusNotFound)
+			return
+		}
+		groupIDs, ok := batchGroups(w, batch, updateData.GroupIDs)
+		if !ok {
+			return
+		}
+		note.GroupIDs = groupIDs
+	}
 	if updateData.Title != "" {
 		note.Title = updateData.Title
 	}
-	note.Description = updateData.Description
+	note.Description = richtext.Rich.Sanitize(updateData.Description)
 
 	if err := h.noteRepo.Update(r.Context(), note); err != nil {
 		log.Printf("[Notes] Failed to update note: %v", err)
@@ -377,10 +788,8 @@ func (h *NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
 // Delete handles note deletion (DELETE /api/notes/{id}).
 // Access: Admin only.
 func (h *NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -404,9 +813,16 @@ func (h *NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete file from storage
-	if err := os.Remove(note.FilePath); err != nil {
-		log.Printf("[Notes] Warning: Failed to delete file: %v", err)
+	if err := h.legalHolds.Guard(r.Context(), models.HoldContentNote, note.ID.Hex(), models.LegalHoldDeleteBlocked, user); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	}
+
+	// Delete the file and its variants from storage
+	for _, path := range note.FilePaths() {
+		if err := os.Remove(path); err != nil {
+			log.Printf("[Notes] Warning: Failed to delete file: %v", err)
+		}
 	}
 
 	// Delete from database
@@ -422,27 +838,393 @@ func (h *NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Note deleted successfully"})
 }
 
-// isAllowedFileType checks if the MIME type is allowed for upload.
-func isAllowedFileType(mimeType string) bool {
-	allowedTypes := map[string]bool{
-		// PDF
-		"application/pdf": true,
-		// Word
-		"application/msword": true,
-		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
-		// Excel
-		"application/vnd.ms-excel": true,
-		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": true,
-		// PowerPoint
-		"application/vnd.ms-powerpoint":                                             true,
-		"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
-		// Images
-		"image/jpeg": true,
-		"image/png":  true,
-		"image/gif":  true,
-		"image/webp": true,
-		// Text
-		"text/plain": true,
-	}
-	return allowedTypes[mimeType]
+// Library lists the library items a presenter can reuse: their personal
+// items and department items (GET /api/notes/library?department=).
+// Access: Admin, Presenter.
+func (h *NoteHandler) Library(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && user.Role != models.RolePresenter {
+		http.Error(w, `{"error":"Permission denied"}`, http.StatusForbidden)
+		return
+	}
+
+	notes, err := h.noteRepo.FindLibrary(r.Context(), user.ID, strings.TrimSpace(r.URL.Query().Get("department")))
+	if err != nil {
+		log.Printf("[Notes] Error listing library: %v", err)
+		http.Error(w, `{"error":"Failed to fetch library"}`, http.StatusInternalServerError)
+		return
+	}
+
+	visible := make([]*models.Note, 0, len(notes))
+	for _, note := range notes {
+		if note.Hidden && user.Role != models.RoleAdmin {
+			continue
+		}
+		note.DownloadURL = "/api/notes/" + note.ID.Hex() + "/download"
+		visible = append(visible, note)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
+
+// SetLibrary adds a note to the uploader's personal library or a department
+// library, or removes it with an empty library (PUT /api/notes/{id}/library).
+// Access: Admin and the uploader.
+func (h *NoteHandler) SetLibrary(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	note, ok := h.noteFromPath(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && note.UploaderID != user.ID {
+		http.Error(w, `{"error":"Only the uploader can add a note to a library"}`, http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Library    string `json:"library" validate:"oneof=personal department"`
+		Department string `json:"department" validate:"max=100"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Department = strings.TrimSpace(req.Department)
+	if req.Library == string(models.NoteLibraryDepartment) && req.Department == "" {
+		http.Error(w, `{"error":"department is required for department library items"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Library != string(models.NoteLibraryDepartment) {
+		req.Department = ""
+	}
+
+	if err := h.noteRepo.SetLibrary(r.Context(), note.ID, models.NoteLibrary(req.Library), req.Department); err != nil {
+		log.Printf("[Notes] Failed to update library of %s: %v", note.ID.Hex(), err)
+		http.Error(w, `{"error":"Failed to update note"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Notes] Library of %s set to %q by %s", note.Title, req.Library, user.Name)
+	h.sendNote(w, r, note.ID)
+}
+
+// LinkBatch attaches a library item to another batch by reference
+// (POST /api/notes/{id}/batches with {"batchId": "..."}).
+// Access: Admin, or a presenter who can reuse the item and teaches the batch.
+func (h *NoteHandler) LinkBatch(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	note, ok := h.noteFromPath(w, r)
+	if !ok {
+		return
+	}
+	if note.Library == "" {
+		http.Error(w, `{"error":"Only library items can be linked to other batches"}`, http.StatusBadRequest)
+		return
+	}
+	if note.Hidden && user.Role != models.RoleAdmin {
+		http.Error(w, `{"error":"This note is hidden pending review"}`, http.StatusForbidden)
+		return
+	}
+	if !canReuseNote(user, note) {
+		http.Error(w, `{"error":"Permission denied"}`, http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		BatchID string `json:"batchId" validate:"required,objectid"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	batch, err := h.batchRepo.FindByID(r.Context(), req.BatchID)
+	if err != nil {
+		http.Error(w, `{"error":"Batch not found"}`, http.StatusNotFound)
+		return
+	}
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID {
+		http.Error(w, `{"error":"You can only link notes to batches you teach"}`, http.StatusForbidden)
+		return
+	}
+	if note.InBatch(batch.ID) {
+		http.Error(w, `{"error":"Note is already in this batch"}`, http.StatusConflict)
+		return
+	}
+
+	if err := h.noteRepo.LinkBatch(r.Context(), note.ID, batch.ID); err != nil {
+		log.Printf("[Notes] Failed to link %s to batch %s: %v", note.ID.Hex(), batch.ID.Hex(), err)
+		http.Error(w, `{"error":"Failed to link note"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Notes] Linked: %s to batch %s by %s", note.Title, batch.Name, user.Name)
+	h.sendNote(w, r, note.ID)
+}
+
+// UnlinkBatch detaches a library item from a linked batch
+// (DELETE /api/notes/{id}/batches/{batchId}).
+// Access: Admin, the uploader, and the presenter of the batch.
+func (h *NoteHandler) UnlinkBatch(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	note, ok := h.noteFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/notes/"), "/")
+	if len(parts) < 3 {
+		http.Error(w, `{"error":"Invalid URL"}`, http.StatusBadRequest)
+		return
+	}
+	batchID, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		http.Error(w, `{"error":"Invalid batch ID"}`, http.StatusBadRequest)
+		return
+	}
+	if batchID == note.BatchID || !note.InBatch(batchID) {
+		http.Error(w, `{"error":"Note is not linked to this batch"}`, http.StatusNotFound)
+		return
+	}
+
+	if user.Role != models.RoleAdmin && note.UploaderID != user.ID {
+		batch, err := h.batchRepo.FindByID(r.Context(), batchID.Hex())
+		if err != nil || batch.PresenterID != user.ID {
+			http.Error(w, `{"error":"Permission denied"}`, http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := h.noteRepo.UnlinkBatch(r.Context(), note.ID, batchID); err != nil {
+		log.Printf("[Notes] Failed to unlink %s from batch %s: %v", note.ID.Hex(), batchID.Hex(), err)
+		http.Error(w, `{"error":"Failed to unlink note"}`, http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Notes] Unlinked: %s from batch %s by %s", note.Title, batchID.Hex(), user.Name)
+	h.sendNote(w, r, note.ID)
+}
+
+// noteFromPath loads the note whose ID is the first segment after
+// /api/notes/, writing the error response if it can't.
+func (h *NoteHandler) noteFromPath(w http.ResponseWriter, r *http.Request) (*models.Note, bool) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/notes/")
+	noteID, err := primitive.ObjectIDFromHex(strings.Split(path, "/")[0])
+	if err != nil {
+		http.Error(w, `{"error":"Invalid note ID"}`, http.StatusBadRequest)
+		return nil, false
+	}
+
+	note, err := h.noteRepo.FindByID(r.Context(), noteID)
+	if err != nil {
+		http.Error(w, `{"error":"Note not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return note, true
+}
+
+// sendNote responds with the current state of a note.
+func (h *NoteHandler) sendNote(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	note, err := h.noteRepo.FindByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, `{"error":"Note not found"}`, http.StatusNotFound)
+		return
+	}
+	note.DownloadURL = "/api/notes/" + note.ID.Hex() + "/download"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(note)
+}
+
+// canReuseNote checks if the user may attach a library item to batches: the
+// uploader for personal items, any presenter for department items.
+func canReuseNote(user *models.User, note *models.Note) bool {
+	switch note.Library {
+	case models.NoteLibraryPersonal:
+		return user.Role == models.RoleAdmin || note.UploaderID == user.ID
+	case models.NoteLibraryDepartment:
+		return user.Role == models.RoleAdmin || user.Role == models.RolePresenter
+	}
+	return false
+}
+
+// inNoteGroups reports whether a student enrolled in the batches sees a
+// note targeted at groups: they're in one of its groups, or get it through
+// a batch it's linked to. Notes for the whole batch are seen by everyone.
+func inNoteGroups(enrolled []models.Batch, studentID primitive.ObjectID, note *models.Note) bool {
+	if len(note.GroupIDs) == 0 {
+		return true
+	}
+	for i := range enrolled {
+		b := &enrolled[i]
+		if !note.InBatch(b.ID) {
+			continue
+		}
+		if b.ID != note.BatchID || b.InGroups(studentID, note.GroupIDs) {
+			return true
+		}
+	}
+	return false
+}
+
+// batchGroups converts group IDs, sending a 400 response if one isn't a
+// group of the batch.
+func batchGroups(w http.ResponseWriter, batch *models.Batch, ids []string) ([]primitive.ObjectID, bool) {
+	var groups []primitive.ObjectID
+	for _, id := range ids {
+		oid, _ := primitive.ObjectIDFromHex(id)
+		if batch.Group(oid) == nil {
+			http.Error(w, `{"error":"Not a group of this batch: `+id+`"}`, http.StatusBadRequest)
+			return nil, false
+		}
+		groups = append(groups, oid)
+	}
+	return groups, true
+}
+
+// assistsNote checks if any of the note's batches is among the assisted ones.
+func assistsNote(assisted map[primitive.ObjectID]bool, note *models.Note) bool {
+	for _, id := range note.BatchIDs() {
+		if assisted[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// assistedBatches returns the batches the user is a teaching assistant of.
+func (h *NoteHandler) assistedBatches(ctx context.Context, user *models.User) []models.Batch {
+	batches, err := h.batchRepo.FindByAssistant(ctx, user.ID.Hex())
+	if err != nil {
+		log.Printf("[Notes] Error finding assisted batches: %v", err)
+		return nil
+	}
+	return batches
+}
+
+// storedFile is an uploaded file saved to note storage.
+type storedFile struct {
+	kind filetype.Kind
+	name string // Client's file name, with the extension of its content
+	path string
+	size int64
+}
+
+// saveUpload checks the type of the "file" of a parsed multipart form and
+// saves it to note storage, encrypted when enabled. It writes the error
+// response on failure.
+func (h *NoteHandler) saveUpload(w http.ResponseWriter, r *http.Request) (*storedFile, bool) {
+	// Get the file
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, `{"error":"No file uploaded"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	defer file.Close()
+
+	stored, err := h.store(r.Context(), file, header.Size, header.Filename, header.Header.Get("Content-Type"))
+	if errors.Is(err, filetype.ErrMismatch) {
+		http.Error(w, `{"error":"The file extension doesn't match the file's content"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	if errors.Is(err, filetype.ErrUnsupported) {
+		http.Error(w, `{"error":"File type not allowed. Supported: PDF, Word, Excel, PowerPoint, images, and text files"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, `{"error":"Failed to save file"}`, http.StatusInternalServerError)
+		return nil, false
+	}
+	return stored, true
+}
+
+// store checks the type of a file of size bytes and saves it to note
+// storage, encrypted when enabled. Unaccepted types return the filetype
+// errors.
+func (h *NoteHandler) store(ctx context.Context, file interface {
+	io.Reader
+	io.ReaderAt
+}, size int64, filename, hint string) (*storedFile, error) {
+	// Validate file type by content; the client's Content-Type isn't trusted
+	kind, err := filetype.Notes.Check(file, size, filename, hint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate unique filename
+	uniqueName := primitive.NewObjectID().Hex() + "_" + time.Now().Format("20060102_150405") + kind.Ext
+	filePath := filepath.Join(h.storagePath, "notes", uniqueName)
+
+	// Save file, encrypted when enabled
+	dst, err := h.files.Create(ctx, filePath)
+	if err != nil {
+		log.Printf("[Notes] Failed to create file: %v", err)
+		return nil, err
+	}
+
+	fileSize, err := io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("[Notes] Failed to save file content: %v", err)
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	return &storedFile{kind: kind, name: kind.Rename(filename), path: filePath, size: fileSize}, nil
+}
+
+// serveFile sends a note's file, or the variant for ?width= of an image,
+// decrypting it if it is encrypted. Access is up to the caller.
+func (h *NoteHandler) serveFile(w http.ResponseWriter, r *http.Request, note *models.Note) {
+	filePath, mimeType, fileName := note.FilePath, note.MimeType, note.FileName
+	if width, err := strconv.Atoi(r.URL.Query().Get("width")); err == nil && width > 0 {
+		if variant := note.Variant(width); variant != nil {
+			filePath, mimeType = variant.FilePath, variant.MimeType
+			fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + filepath.Ext(variant.FilePath)
+		}
+	}
+
+	// Open file, decrypting it on the fly if it is encrypted
+	file, err := h.files.Open(r.Context(), filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("[Notes] File not found: %s", filePath)
+		http.Error(w, `{"error":"File not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[Notes] Failed to open file %s: %v", filePath, err)
+		http.Error(w, `{"error":"Failed to open file"}`, http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// Set headers for download
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", "inline; filename=\""+fileName+"\"")
+	w.Header().Set("Content-Length", strconv.FormatInt(file.Size(), 10))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+
+	// Stream file
+	io.Copy(w, file)
 }
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// NotificationHandler handles in-app notification endpoints.
+type NotificationHandler struct {
+	authService      *auth.Service
+	notificationRepo *repository.NotificationRepository
+	userRepo         *repository.UserRepository
+	notifier         *notify.Notifier
+}
+
+// NewNotificationHandler creates a new NotificationHandler.
+func NewNotificationHandler(authService *auth.Service, notificationRepo *repository.NotificationRepository, userRepo *repository.UserRepository, notifier *notify.Notifier) *NotificationHandler {
+	return &NotificationHandler{
+		authService:      authService,
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		notifier:         notifier,
+	}
+}
+
+// ListNotifications handles GET /api/notifications?unread=true&limit=.
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	userID := user.ID
+
+	query := r.URL.Query()
+	unreadOnly := query.Get("unread") == "true"
+
+	limit := int64(defaultNotificationLimit)
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = int64(l)
+		if limit > maxNotificationLimit {
+			limit = maxNotificationLimit
+		}
+	}
+
+	notifications, err := h.notificationRepo.FindByUser(r.Context(), userID, unreadOnly, limit)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch notifications", err)
+		return
+	}
+
+	unread, err := h.notificationRepo.CountUnread(r.Context(), userID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch notifications", err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"notifications": notifications,
+		"unreadCount":   unread,
+	}, http.StatusOK)
+}
+
+// MarkRead handles POST /api/notifications/read. With no IDs every notification is marked read.
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+	userID := user.ID
+
+	var req struct {
+		IDs []string `json:"ids" validate:"max=500,objectid"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(req.IDs))
+	for _, id := range req.IDs {
+		objID, _ := primitive.ObjectIDFromHex(id)
+		ids = append(ids, objID)
+	}
+
+	updated, err := h.notificationRepo.MarkRead(r.Context(), userID, ids)
+	if err != nil {
+		sendStoreError(w, "Failed to update notifications", err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{"updated": updated}, http.StatusOK)
+}
+
+// Settings returns (GET) or replaces (PUT) the caller's quiet hours at
+// /api/notifications/settings:
+//
+//	{"enabled": true, "start": "22:00", "end": "07:00", "timezone": "Asia/Kolkata",
+//	 "muteUrgent": false, "doNotDisturbUntil": "2024-01-15T10:00:00Z"}
+//
+// Notifications held back so far are delivered when the new quiet time ends.
+func (h *NotificationHandler) Settings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req struct {
+			Enabled           bool   `json:"enabled"`
+			Start             string `json:"start" validate:"clock"`
+			End               string `json:"end" validate:"clock"`
+			Timezone          string `json:"timezone" validate:"max=64,timezone"`
+			MuteUrgent        bool   `json:"muteUrgent"`
+			DoNotDisturbUntil string `json:"doNotDisturbUntil" validate:"rfc3339"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		if req.Enabled && (req.Start == "" || req.End == "" || req.Start == req.End) {
+			sendJSONError(w, "Quiet hours need a different start and end time", http.StatusBadRequest)
+			return
+		}
+
+		quiet := &models.QuietHours{
+			Enabled:    req.Enabled,
+			Start:      req.Start,
+			End:        req.End,
+			Timezone:   firstNonEmpty(req.Timezone, "UTC"),
+			MuteUrgent: req.MuteUrgent,
+		}
+		if req.DoNotDisturbUntil != "" {
+			until, _ := time.Parse(time.RFC3339, req.DoNotDisturbUntil)
+			quiet.DoNotDisturbUntil = &until
+		}
+
+		updated := *user
+		updated.QuietHours = quiet
+		if err := h.userRepo.Update(r.Context(), &updated); err != nil {
+			sendStoreError(w, "Failed to save settings", err)
+			return
+		}
+		user = &updated
+
+		// Held-back notifications follow the new settings
+		now := time.Now()
+		until := quiet.Until(now)
+		if until.IsZero() {
+			until = now
+		}
+		if err := h.notificationRepo.RescheduleDeferred(r.Context(), user.ID, until); err != nil {
+			log.Printf("[Notify] Failed to reschedule held-back notifications for %s: %v", user.ID.Hex(), err)
+		}
+	}
+
+	quiet := user.QuietHours
+	if quiet == nil {
+		quiet = &models.QuietHours{Timezone: "UTC"}
+	}
+	response := map[string]interface{}{
+		"quietHours":       quiet,
+		"urgentCategories": h.notifier.UrgentCategories(),
+	}
+	if until := quiet.Until(time.Now()); !until.IsZero() {
+		response["quietUntil"] = until
+	}
+	sendJSON(w, response, http.StatusOK)
+}
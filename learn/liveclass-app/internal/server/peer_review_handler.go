@@ -0,0 +1,451 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/peerreview"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// peerReviewItem is a review as the presenter sees it.
+type peerReviewItem struct {
+	models.PeerReview
+	ReviewerName string `json:"reviewerName"`
+	Outlier      bool   `json:"outlier"` // Far from the hand-in's other reviews
+}
+
+// peerReviewHandIn is a hand-in with its reviews, as the presenter sees it.
+type peerReviewHandIn struct {
+	HandIn  handInItem        `json:"handIn"`
+	Score   *peerreview.Score `json:"score,omitempty"`
+	Reviews []peerReviewItem  `json:"reviews"`
+}
+
+// peerReviewAssignment is a hand-in a student was asked to review, without
+// whose it is.
+type peerReviewAssignment struct {
+	ID          primitive.ObjectID `json:"id"`
+	Caption     string             `json:"caption,omitempty"`
+	ImageURL    string             `json:"imageUrl"` // Needs the caller's login token
+	Scores      []int              `json:"scores,omitempty"`
+	Comment     string             `json:"comment,omitempty"`
+	SubmittedAt *time.Time         `json:"submittedAt,omitempty"`
+}
+
+// peerReviewFeedback is a review of a student's hand-in, without who wrote it.
+type peerReviewFeedback struct {
+	Scores  []int  `json:"scores"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// peerReviewResult is what a student's hand-in scored once released.
+type peerReviewResult struct {
+	HandInID primitive.ObjectID   `json:"handInId"`
+	Caption  string               `json:"caption,omitempty"`
+	Score    *peerreview.Score    `json:"score,omitempty"`
+	Feedback []peerReviewFeedback `json:"feedback"`
+}
+
+// PeerReviewHandler runs the peer review of the work handed in to a class.
+// Hand-ins close when the class ends; the presenter then sends each one
+// anonymously to a few classmates with a rubric, moderates the reviews
+// that stand out and releases the scores.
+type PeerReviewHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	handInRepo   *repository.HandInRepository
+	reviewRepo   *repository.PeerReviewRepository
+	handIns      *HandInHandler
+}
+
+// NewPeerReviewHandler creates a new PeerReviewHandler.
+func NewPeerReviewHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, handInRepo *repository.HandInRepository, reviewRepo *repository.PeerReviewRepository, handIns *HandInHandler) *PeerReviewHandler {
+	return &PeerReviewHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		handInRepo:   handInRepo,
+		reviewRepo:   reviewRepo,
+		handIns:      handIns,
+	}
+}
+
+// PeerReview routes /api/schedules/{id}/peer-review[/...]:
+//
+//	POST .../peer-review                             start the peer review
+//	GET  .../peer-review                             the peer review as the caller sees it
+//	POST .../peer-review/release                     release the scores to students
+//	PUT  .../peer-review/reviews/{reviewId}          a reviewer submits their review
+//	GET  .../peer-review/reviews/{reviewId}/image    the image under review
+//	POST .../peer-review/reviews/{reviewId}/moderate exclude a review or take it back
+//
+// The presenter, the batch's assistants and admins run it; students review
+// the hand-ins given to them and see their own scores once released.
+func (h *PeerReviewHandler) PeerReview(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), parts[0])
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendStoreError(w, "Batch not found", err)
+		return
+	}
+
+	teaches := user.Role == models.RoleAdmin || schedule.PresenterID == user.ID || batch.HasAssistant(user.ID.Hex())
+	if !teaches && !batch.HasStudent(user.ID.Hex()) {
+		sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+		return
+	}
+
+	// /api/schedules/{id}/peer-review/reviews/{reviewId}/{action}
+	rest := parts[2:]
+	if len(rest) == 0 || rest[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.get(w, r, user, schedule, teaches)
+		case http.MethodPost:
+			if !teaches {
+				sendJSONError(w, "Only the presenter can start a peer review", http.StatusForbidden)
+				return
+			}
+			h.start(w, r, user, schedule)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	round, err := h.reviewRepo.FindRound(r.Context(), schedule.ID)
+	if errors.Is(err, repository.ErrPeerReviewNotFound) {
+		sendJSONError(w, "This class has no peer review", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendStoreError(w, "Failed to fetch peer review", err)
+		return
+	}
+
+	if rest[0] == "release" && len(rest) == 1 {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !teaches {
+			sendJSONError(w, "Only the presenter can release scores", http.StatusForbidden)
+			return
+		}
+		h.release(w, r, user, schedule, round)
+		return
+	}
+	if rest[0] != "reviews" || len(rest) < 2 || len(rest) > 3 {
+		sendJSONError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	review, err := h.reviewRepo.FindReview(r.Context(), round.ID, rest[1])
+	// Students can't tell others' reviews from missing ones
+	if errors.Is(err, repository.ErrPeerReviewNotFound) || (err == nil && !teaches && review.ReviewerID != user.ID) {
+		sendJSONError(w, "Review not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendStoreError(w, "Failed to fetch review", err)
+		return
+	}
+
+	var action string
+	if len(rest) == 3 {
+		action = rest[2]
+	}
+	switch action {
+	case "":
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if review.ReviewerID != user.ID {
+			sendJSONError(w, "Only the reviewer can submit this review", http.StatusForbidden)
+			return
+		}
+		h.submit(w, r, schedule, round, review)
+	case "image":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handIn, err := h.handInRepo.FindByID(r.Context(), schedule.ID, review.HandInID)
+		if err != nil {
+			sendJSONError(w, "Hand-in not found", http.StatusNotFound)
+			return
+		}
+		h.handIns.serveImage(w, r, handIn)
+	case "moderate":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !teaches {
+			sendJSONError(w, "Only the presenter can moderate reviews", http.StatusForbidden)
+			return
+		}
+		h.moderate(w, r, user, round, review)
+	default:
+		sendJSONError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// start distributes the hand-ins of a class that is over among the
+// classmates who handed in work.
+func (h *PeerReviewHandler) start(w http.ResponseWriter, r *http.Request, user *models.User, schedule *models.ScheduledClass) {
+	if schedule.EffectiveStatusAt(time.Now()) != models.ClassStatusCompleted {
+		sendJSONError(w, "A peer review can start once the class is over", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Rubric           []models.RubricCriterion `json:"rubric" validate:"required,min=1,max=10"`
+		ReviewsPerHandIn int                      `json:"reviewsPerHandIn" validate:"min=1,max=5"`
+		DueAt            string                   `json:"dueAt" validate:"required,rfc3339"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	dueAt, _ := time.Parse(time.RFC3339, req.DueAt)
+	if !dueAt.After(time.Now()) {
+		sendJSONError(w, "The due date must be in the future", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Rubric {
+		req.Rubric[i].Title = strings.TrimSpace(req.Rubric[i].Title)
+		req.Rubric[i].Description = strings.TrimSpace(req.Rubric[i].Description)
+	}
+
+	handIns, err := h.handInRepo.FindBySchedule(r.Context(), schedule.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch hand-ins", err)
+		return
+	}
+	reviews := peerreview.Assign(handIns, req.ReviewsPerHandIn)
+	if len(reviews) == 0 {
+		sendJSONError(w, "At least two students must have handed in work", http.StatusConflict)
+		return
+	}
+
+	round := &models.PeerReviewRound{
+		ScheduleID:       schedule.ID,
+		BatchID:          schedule.BatchID,
+		Rubric:           req.Rubric,
+		ReviewsPerHandIn: req.ReviewsPerHandIn,
+		DueAt:            dueAt,
+		CreatedBy:        user.ID,
+	}
+	if err := h.reviewRepo.CreateRound(r.Context(), round, reviews); err != nil {
+		if errors.Is(err, repository.ErrPeerReviewExists) {
+			sendJSONError(w, "This class already has a peer review", http.StatusConflict)
+			return
+		}
+		log.Printf("[PeerReview] Failed to start peer review of class %s: %v", schedule.ID.Hex(), err)
+		sendStoreError(w, "Failed to start peer review", err)
+		return
+	}
+
+	log.Printf("[PeerReview] %s started peer review of class %s: %d hand-ins, %d reviews", user.Name, schedule.ID.Hex(), len(handIns), len(reviews))
+	sendJSON(w, map[string]interface{}{
+		"round":   round,
+		"reviews": len(reviews),
+	}, http.StatusCreated)
+}
+
+// get sends the peer review of a class: every hand-in with its reviews and
+// score to the presenter, and to a student the hand-ins they review and,
+// once released, what their own work scored.
+func (h *PeerReviewHandler) get(w http.ResponseWriter, r *http.Request, user *models.User, schedule *models.ScheduledClass, teaches bool) {
+	round, err := h.reviewRepo.FindRound(r.Context(), schedule.ID)
+	if errors.Is(err, repository.ErrPeerReviewNotFound) {
+		sendJSON(w, map[string]interface{}{"round": nil}, http.StatusOK)
+		return
+	}
+	if err != nil {
+		sendStoreError(w, "Failed to fetch peer review", err)
+		return
+	}
+	reviews, err := h.reviewRepo.FindReviews(r.Context(), round.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch reviews", err)
+		return
+	}
+	handIns, err := h.handInRepo.FindBySchedule(r.Context(), schedule.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch hand-ins", err)
+		return
+	}
+	scores := peerreview.Scores(round, reviews)
+
+	if teaches {
+		names := make(map[primitive.ObjectID]string, len(handIns))
+		for _, handIn := range handIns {
+			names[handIn.StudentID] = handIn.StudentName
+		}
+		outliers := peerreview.Outliers(round, reviews)
+
+		items := make([]peerReviewHandIn, 0, len(handIns))
+		for _, handIn := range handIns {
+			item := peerReviewHandIn{HandIn: h.handIns.item(handIn), Score: scores[handIn.ID], Reviews: []peerReviewItem{}}
+			for _, review := range reviews {
+				if review.HandInID == handIn.ID {
+					item.Reviews = append(item.Reviews, peerReviewItem{
+						PeerReview:   review,
+						ReviewerName: names[review.ReviewerID],
+						Outlier:      outliers[review.ID],
+					})
+				}
+			}
+			items = append(items, item)
+		}
+		sendJSON(w, map[string]interface{}{
+			"round":   round,
+			"handIns": items,
+		}, http.StatusOK)
+		return
+	}
+
+	captions := make(map[primitive.ObjectID]string, len(handIns))
+	for _, handIn := range handIns {
+		captions[handIn.ID] = handIn.Caption
+	}
+	assignments := []peerReviewAssignment{}
+	for _, review := range reviews {
+		if review.ReviewerID == user.ID {
+			assignments = append(assignments, h.assignment(schedule, review, captions[review.HandInID]))
+		}
+	}
+
+	resp := map[string]interface{}{
+		"round":       round,
+		"assignments": assignments,
+	}
+	if round.Status == models.PeerReviewReleased {
+		results := []peerReviewResult{}
+		for _, handIn := range handIns {
+			if handIn.StudentID != user.ID {
+				continue
+			}
+			result := peerReviewResult{HandInID: handIn.ID, Caption: handIn.Caption, Score: scores[handIn.ID], Feedback: []peerReviewFeedback{}}
+			for _, review := range reviews {
+				if review.HandInID == handIn.ID && review.Submitted() && !review.Excluded {
+					result.Feedback = append(result.Feedback, peerReviewFeedback{Scores: review.Scores, Comment: review.Comment})
+				}
+			}
+			results = append(results, result)
+		}
+		resp["results"] = results
+	}
+	sendJSON(w, resp, http.StatusOK)
+}
+
+// submit saves a reviewer's scores, one per rubric criterion, and comment.
+func (h *PeerReviewHandler) submit(w http.ResponseWriter, r *http.Request, schedule *models.ScheduledClass, round *models.PeerReviewRound, review *models.PeerReview) {
+	if !round.AcceptsReviews(time.Now()) {
+		sendJSONError(w, "Reviews are closed", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Scores  []int  `json:"scores" validate:"required"`
+		Comment string `json:"comment" validate:"max=2000"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Scores) != len(round.Rubric) {
+		sendJSONError(w, "Give one score per rubric criterion", http.StatusBadRequest)
+		return
+	}
+	for i, score := range req.Scores {
+		if score < 0 || score > round.Rubric[i].MaxPoints {
+			sendJSONError(w, "Scores must be between 0 and the criterion's maximum", http.StatusBadRequest)
+			return
+		}
+	}
+
+	updated, err := h.reviewRepo.Submit(r.Context(), round.ID, review.ID, review.ReviewerID, req.Scores, strings.TrimSpace(req.Comment))
+	if err != nil {
+		sendStoreError(w, "Failed to save review", err)
+		return
+	}
+
+	var caption string
+	if handIn, err := h.handInRepo.FindByID(r.Context(), schedule.ID, review.HandInID); err == nil {
+		caption = handIn.Caption
+	}
+	sendJSON(w, h.assignment(schedule, *updated, caption), http.StatusOK)
+}
+
+// moderate leaves a review out of the scores, or takes it back in, until
+// the scores are released.
+func (h *PeerReviewHandler) moderate(w http.ResponseWriter, r *http.Request, user *models.User, round *models.PeerReviewRound, review *models.PeerReview) {
+	if round.Status != models.PeerReviewOpen {
+		sendJSONError(w, "Scores have been released", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Excluded bool `json:"excluded"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	updated, err := h.reviewRepo.Moderate(r.Context(), round.ID, review.ID, req.Excluded, user.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to moderate review", err)
+		return
+	}
+
+	log.Printf("[PeerReview] %s set excluded=%v on review %s", user.Name, req.Excluded, review.ID.Hex())
+	sendJSON(w, updated, http.StatusOK)
+}
+
+// release makes the scores visible to students and closes reviewing.
+func (h *PeerReviewHandler) release(w http.ResponseWriter, r *http.Request, user *models.User, schedule *models.ScheduledClass, round *models.PeerReviewRound) {
+	released, err := h.reviewRepo.Release(r.Context(), round.ID)
+	if errors.Is(err, repository.ErrPeerReviewClosed) {
+		sendJSONError(w, "Scores have already been released", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		sendStoreError(w, "Failed to release scores", err)
+		return
+	}
+
+	log.Printf("[PeerReview] %s released peer review scores of class %s", user.Name, schedule.ID.Hex())
+	sendJSON(w, released, http.StatusOK)
+}
+
+// assignment returns a review as its reviewer sees it.
+func (h *PeerReviewHandler) assignment(schedule *models.ScheduledClass, review models.PeerReview, caption string) peerReviewAssignment {
+	return peerReviewAssignment{
+		ID:          review.ID,
+		Caption:     caption,
+		ImageURL:    "/api/schedules/" + schedule.ID.Hex() + "/peer-review/reviews/" + review.ID.Hex() + "/image",
+		Scores:      review.Scores,
+		Comment:     review.Comment,
+		SubmittedAt: review.SubmittedAt,
+	}
+}
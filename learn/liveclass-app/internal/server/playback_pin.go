@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/cache"
+)
+
+// playbackPins pins playback tokens to the client address that first
+// streams with them. Players make many range requests with one token, so
+// tokens can't be single use; pinning stops one copied from a log or a
+// shared link from being replayed elsewhere. Pins are kept in memory, so
+// each instance pins the tokens it serves.
+type playbackPins struct {
+	mu   sync.Mutex
+	pins *cache.Cache[netip.Addr] // Client address by token ID
+}
+
+func newPlaybackPins() *playbackPins {
+	return &playbackPins{pins: cache.New[netip.Addr](0, time.Minute)}
+}
+
+// use reports whether the token with ID jti may stream for addr, pinning it
+// to addr until it expires if this is its first use.
+func (p *playbackPins) use(jti string, addr netip.Addr, expiresAt time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pinned, ok := p.pins.Get(jti); ok {
+		return pinned == addr
+	}
+	p.pins.SetWithExpiration(jti, addr, time.Until(expiresAt))
+	return true
+}
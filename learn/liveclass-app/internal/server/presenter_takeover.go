@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// handlePresenterConflict handles a presenter join from the account already
+// presenting in the room, such as a second device. Without takeOver, the new
+// connection is asked to choose: take over, by joining again with takeOver
+// set, or stay on the device presenting now, by not joining. With it,
+// presenting moves to the new connection and the old one is closed.
+//
+// Both the conflict and the takeover are recorded in the room's timeline, so
+// a conflict without a takeover after it means the presenter stayed.
+func (h *Handler) handlePresenterConflict(conn *WSConn, msg Message, r *room.Room, presenter *room.Participant, sessionID string, participant **room.Participant) {
+	if !msg.TakeOver {
+		log.Printf("[Handler] Presenter %s joined room %s from another device", presenter.Name, r.ID)
+		r.RecordPresenterConflict()
+
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":    "presenter-conflict",
+			"message": "You're already presenting this class on another device",
+			"choices": []string{"take-over", "stay"},
+		})
+		conn.Send(data)
+
+		data, _ = json.Marshal(map[string]interface{}{
+			"type":    "presenter-conflict-notice",
+			"message": "Your account is joining this class from another device",
+		})
+		presenter.Conn.Send(data)
+		return
+	}
+
+	p, old := r.TakeOverPresenter(conn, presenter.UserID, sessionID)
+	if p == nil {
+		sendError(conn, "Room already has a presenter")
+		return
+	}
+	log.Printf("[Handler] Presenter %s took over room %s from another device", p.Name, r.ID)
+
+	// Closing the old connection ends its read loop; it's no longer bound to
+	// the presenter, so the presenter stays in the room
+	if old != nil {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":    "presenter-taken-over",
+			"message": "Presenting moved to another device",
+		})
+		old.Send(data)
+		old.Close()
+	}
+
+	*participant = p
+	h.sendJoined(conn, r, p, true, msg.Acks)
+	// Viewers keep the stream until the new device's offer replaces it
+	r.BroadcastToAll(Message{
+		Type:    "presenter-reconnected",
+		Payload: mustMarshal(p.Info()),
+	}, p.ID)
+}
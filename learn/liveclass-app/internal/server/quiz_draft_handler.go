@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/quizgen"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// QuizDraftHandler lets presenters review the quiz questions drafted from
+// their classes' transcripts.
+type QuizDraftHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	draftRepo    *repository.QuizDraftRepository
+	drafter      *quizgen.Drafter
+}
+
+// NewQuizDraftHandler creates a new QuizDraftHandler.
+func NewQuizDraftHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, draftRepo *repository.QuizDraftRepository, drafter *quizgen.Drafter) *QuizDraftHandler {
+	return &QuizDraftHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		draftRepo:    draftRepo,
+		drafter:      drafter,
+	}
+}
+
+// QuizDrafts lists the quiz drafts of a class (GET
+// /api/schedules/{id}/quiz-drafts) or saves the review of one (PUT
+// /api/schedules/{id}/quiz-drafts/{draftId} with a status of approved,
+// discarded or pending, and the edited questions if any). Drafts are for the class's
+// presenter, the batch's assistants and admins.
+func (h *QuizDraftHandler) QuizDrafts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract IDs from URL: /api/schedules/{id}/quiz-drafts/{draftId}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), parts[0])
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendStoreError(w, "Batch not found", err)
+		return
+	}
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID && !batch.HasAssistant(user.ID.Hex()) {
+		sendJSONError(w, "Only the presenter can review quiz drafts", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		drafts, err := h.draftRepo.FindBySchedule(r.Context(), schedule.ID)
+		if err != nil {
+			sendStoreError(w, "Failed to fetch quiz drafts", err)
+			return
+		}
+		sendJSON(w, map[string]interface{}{
+			"enabled": h.drafter.Enabled(),
+			"drafts":  drafts,
+		}, http.StatusOK)
+		return
+	}
+
+	if len(parts) < 3 || parts[2] == "" {
+		sendJSONError(w, "Quiz draft ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Questions []models.QuizQuestion  `json:"questions" validate:"max=100"`
+		Status    models.QuizDraftStatus `json:"status" validate:"required,oneof=pending approved discarded"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	for i := range req.Questions {
+		req.Questions[i].Prompt = strings.TrimSpace(req.Questions[i].Prompt)
+		if !req.Questions[i].Valid() {
+			sendJSONError(w, "Each question needs a prompt, and at least two choices with one marked correct if it has choices", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Status == models.QuizDraftApproved && req.Questions != nil && len(req.Questions) == 0 {
+		sendJSONError(w, "An approved quiz needs at least one question", http.StatusBadRequest)
+		return
+	}
+
+	draft, err := h.draftRepo.Review(r.Context(), schedule.ID, parts[2], req.Questions, req.Status, user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrQuizDraftNotFound) {
+			sendJSONError(w, "Quiz draft not found", http.StatusNotFound)
+			return
+		}
+		sendJSONError(w, "Failed to save quiz draft", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[QuizDraft] %s marked draft %s of class %s %s", user.Name, draft.ID.Hex(), schedule.ID.Hex(), req.Status)
+	sendJSON(w, draft, http.StatusOK)
+}
@@ -1,19 +1,34 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jinshatcp/brightline-academy/learn/internal/analytics"
 	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/cdn"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chapters"
+	"github.com/jinshatcp/brightline-academy/learn/internal/coldstorage"
+	"github.com/jinshatcp/brightline-academy/learn/internal/composite"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/filetype"
+	"github.com/jinshatcp/brightline-academy/learn/internal/geoip"
+	"github.com/jinshatcp/brightline-academy/learn/internal/middleware"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/jinshatcp/brightline-academy/learn/internal/variants"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -29,43 +44,96 @@ type RecordingHandler struct {
 	scheduleRepo  *repository.ScheduleRepository
 	batchRepo     *repository.BatchRepository
 	userRepo      *repository.UserRepository
+	legalHolds    *LegalHoldHandler
+	billing       *BillingHandler
+	watch         *WatchHandler
+	consent       *ConsentHandler
+	analytics     *analytics.Exporter
+	uploads       *uploadTracker
+	coldStorage   *coldstorage.Lifecycle
+	chapters      *chapters.Generator
+	composites    *composite.Processor
+	variants      *variants.Generator
+	files         *encryption.Encryptor // nil stores files in plaintext
+	cdn           *cdn.Signer           // nil streams through the server
+	playback      PlaybackTokenOptions
+	playbackPins  *playbackPins
 	storagePath   string
 }
 
-// NewRecordingHandler creates a new RecordingHandler.
-func NewRecordingHandler(
-	authService *auth.Service,
-	recordingRepo *repository.RecordingRepository,
-	scheduleRepo *repository.ScheduleRepository,
-	batchRepo *repository.BatchRepository,
-	userRepo *repository.UserRepository,
-	storagePath string,
-) *RecordingHandler {
+// PlaybackTokenOptions configures playback tokens: short-lived tokens for
+// one recording, put in stream URLs instead of login tokens.
+type PlaybackTokenOptions struct {
+	TTL               time.Duration  // Lifetime on top of the recording's duration
+	LoginTokenInQuery bool           // Still accept ?token= login tokens on the stream endpoint
+	PinClient         *geoip.Locator // Pins tokens to the first client address using them; nil doesn't
+}
+
+// RecordingHandlerDeps are the stores, services and handlers the recording
+// handler works with.
+type RecordingHandlerDeps struct {
+	Auth        *auth.Service
+	Recordings  *repository.RecordingRepository
+	Schedules   *repository.ScheduleRepository
+	Batches     *repository.BatchRepository
+	Users       *repository.UserRepository
+	LegalHolds  *LegalHoldHandler
+	Billing     *BillingHandler
+	Watch       *WatchHandler
+	Consent     *ConsentHandler
+	Analytics   *analytics.Exporter
+	Hub         *room.Hub
+	ColdStorage *coldstorage.Lifecycle
+	Chapters    *chapters.Generator
+	Composites  *composite.Processor
+	Variants    *variants.Generator
+	Files       *encryption.Encryptor // nil stores files in plaintext
+	CDN         *cdn.Signer           // nil streams through the server
+}
+
+// NewRecordingHandler creates a new RecordingHandler storing recordings
+// under storagePath.
+func NewRecordingHandler(deps RecordingHandlerDeps, playback PlaybackTokenOptions, storagePath string) *RecordingHandler {
 	// Create recordings directory if it doesn't exist
 	fullPath := filepath.Join(storagePath, recordingsDir)
 	os.MkdirAll(fullPath, 0755)
 
 	return &RecordingHandler{
-		authService:   authService,
-		recordingRepo: recordingRepo,
-		scheduleRepo:  scheduleRepo,
-		batchRepo:     batchRepo,
-		userRepo:      userRepo,
+		authService:   deps.Auth,
+		recordingRepo: deps.Recordings,
+		scheduleRepo:  deps.Schedules,
+		batchRepo:     deps.Batches,
+		userRepo:      deps.Users,
+		legalHolds:    deps.LegalHolds,
+		billing:       deps.Billing,
+		watch:         deps.Watch,
+		consent:       deps.Consent,
+		analytics:     deps.Analytics,
+		uploads:       newUploadTracker(deps.Hub),
+		coldStorage:   deps.ColdStorage,
+		chapters:      deps.Chapters,
+		composites:    deps.Composites,
+		variants:      deps.Variants,
+		files:         deps.Files,
+		cdn:           deps.CDN,
+		playback:      playback,
+		playbackPins:  newPlaybackPins(),
 		storagePath:   storagePath,
 	}
 }
 
-// Upload handles recording file uploads.
+// Upload handles recording file uploads. Progress is tracked under the
+// client's X-Upload-ID (see UploadStatus) and echoed in the response header.
+// An optional "camera" file recorded alongside a screen share is composited
+// into the recording, which is processing until then.
 func (h *RecordingHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -75,25 +143,35 @@ func (h *RecordingHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	upload := h.uploads.Start(r.Header.Get("X-Upload-ID"), user.ID.Hex(), r.ContentLength)
+	w.Header().Set("X-Upload-ID", upload.ID())
+	fail := func(msg string, status int) {
+		upload.Fail(msg)
+		sendJSONError(w, msg, status)
+	}
+
 	// Limit upload size
-	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	r.Body = http.MaxBytesReader(w, upload.Body(r.Body), maxUploadSize)
 
 	// Parse multipart form
 	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-		sendJSONError(w, "File too large (max 2GB)", http.StatusBadRequest)
+		fail("File too large (max 2GB)", http.StatusBadRequest)
 		return
 	}
+	upload.Stage(UploadValidating)
 
 	// Get form values
-	scheduleID := r.FormValue("scheduleId")
-	title := r.FormValue("title")
-	description := r.FormValue("description")
-	durationStr := r.FormValue("duration")
-
-	if scheduleID == "" || title == "" {
-		sendJSONError(w, "Schedule ID and title are required", http.StatusBadRequest)
+	form := struct {
+		ScheduleID  string `json:"scheduleId" validate:"required,objectid"`
+		Title       string `json:"title" validate:"required,max=200"`
+		Description string `json:"description" validate:"max=2000"`
+	}{r.FormValue("scheduleId"), r.FormValue("title"), r.FormValue("description")}
+	if !checkRequest(w, &form) {
+		upload.Fail("Invalid form fields")
 		return
 	}
+	scheduleID, title, description := form.ScheduleID, form.Title, form.Description
+	durationStr := r.FormValue("duration")
 
 	// Parse duration
 	duration, _ := strconv.Atoi(durationStr)
@@ -101,82 +179,169 @@ func (h *RecordingHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	// Verify schedule exists and belongs to the presenter
 	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
 	if err != nil {
-		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		fail("Schedule not found", http.StatusNotFound)
 		return
 	}
 
 	if user.Role != models.RoleAdmin && schedule.PresenterID.Hex() != user.ID.Hex() {
-		sendJSONError(w, "You can only upload recordings for your own classes", http.StatusForbidden)
+		fail("You can only upload recordings for your own classes", http.StatusForbidden)
+		return
+	}
+
+	if err := h.billing.Allows(r.Context(), schedule.BatchID, models.FeatureRecording); isBillingLimit(err) {
+		fail("Recordings aren't available: "+err.Error(), http.StatusPaymentRequired)
+		return
+	} else if err != nil {
+		fail("Failed to check the plan's features", http.StatusInternalServerError)
 		return
 	}
 
 	// Get the file
 	file, header, err := r.FormFile("recording")
 	if err != nil {
-		sendJSONError(w, "Recording file is required", http.StatusBadRequest)
+		fail("Recording file is required", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
 
-	// Validate file type
-	contentType := header.Header.Get("Content-Type")
-	if !isValidVideoType(contentType) {
-		sendJSONError(w, "Invalid file type. Supported: video/webm, video/mp4", http.StatusBadRequest)
+	// Validate file type by content; the client's Content-Type isn't trusted
+	kind, err := filetype.Recordings.Check(file, header.Size, header.Filename, header.Header.Get("Content-Type"))
+	if errors.Is(err, filetype.ErrMismatch) {
+		fail("The file extension doesn't match the recording's content", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		fail("Invalid file type. Supported: "+filetype.Recordings.Names(), http.StatusBadRequest)
 		return
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(header.Filename)
-	if ext == "" {
-		ext = ".webm"
+	// A camera recording uploaded with a screen recording is inset into it
+	// in the background, while the recording is processing
+	camera, cameraHeader, err := r.FormFile("camera")
+	var cameraKind filetype.Kind
+	if err == nil {
+		defer camera.Close()
+		cameraKind, err = filetype.Recordings.Check(camera, cameraHeader.Size, cameraHeader.Filename, cameraHeader.Header.Get("Content-Type"))
+		if err != nil {
+			fail("Invalid camera recording. Supported: "+filetype.Recordings.Names(), http.StatusBadRequest)
+			return
+		}
+		if !h.composites.Enabled() {
+			log.Printf("[Recording] Compositing is disabled, keeping only the screen recording for %s", scheduleID)
+			camera = nil
+		}
 	}
-	fileName := fmt.Sprintf("%s_%s%s", scheduleID, time.Now().Format("20060102_150405"), ext)
+
+	upload.SetFileSize(header.Size)
+	upload.Stage(UploadProcessing)
+
+	// Generate unique filename
+	stamp := time.Now().Format("20060102_150405")
+	fileName := fmt.Sprintf("%s_%s%s", scheduleID, stamp, kind.Ext)
 	filePath := filepath.Join(h.storagePath, recordingsDir, fileName)
 
-	// Create the file
-	dst, err := os.Create(filePath)
+	// Create the file, encrypted when enabled
+	dst, err := h.files.Create(r.Context(), filePath)
 	if err != nil {
-		sendJSONError(w, "Failed to save recording", http.StatusInternalServerError)
+		log.Printf("[Recording] Failed to create file: %v", err)
+		fail("Failed to save recording", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
 
 	// Copy the uploaded file
-	fileSize, err := io.Copy(dst, file)
+	fileSize, err := io.Copy(upload.Writer(dst), file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		os.Remove(filePath)
-		sendJSONError(w, "Failed to save recording", http.StatusInternalServerError)
+		fail("Failed to save recording", http.StatusInternalServerError)
 		return
 	}
 
+	status, cameraPath := models.RecordingStatusReady, ""
+	if camera != nil {
+		cameraPath = filepath.Join(h.storagePath, recordingsDir, fmt.Sprintf("%s_%s_camera%s", scheduleID, stamp, cameraKind.Ext))
+		if err := h.saveFile(r.Context(), cameraPath, camera); err != nil {
+			log.Printf("[Recording] Failed to save camera recording: %v", err)
+			os.Remove(filePath)
+			os.Remove(cameraPath)
+			fail("Failed to save recording", http.StatusInternalServerError)
+			return
+		}
+		status = models.RecordingStatusProcessing
+	}
+
 	// Create recording record
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
 	scheduleObjID, _ := primitive.ObjectIDFromHex(scheduleID)
 	recording := &models.Recording{
 		ScheduleID:  scheduleObjID,
 		BatchID:     schedule.BatchID,
 		PresenterID: schedule.PresenterID,
 		Title:       title,
-		Description: description,
+		Description: richtext.Rich.Sanitize(description),
 		FileName:    fileName,
 		FilePath:    filePath,
 		FileSize:    fileSize,
 		Duration:    duration,
-		MimeType:    contentType,
-		Status:      models.RecordingStatusReady,
+		MimeType:    kind.MimeType,
+		Status:      status,
 		RecordedAt:  schedule.StartTime,
+		CameraPath:  cameraPath,
+
+		BatchName:     names.Batch(schedule.BatchID, schedule.BatchName),
+		PresenterName: names.User(schedule.PresenterID, schedule.PresenterName),
+
+		Consent: h.consent.ForSchedule(r.Context(), scheduleObjID),
 	}
 
 	if err := h.recordingRepo.Create(r.Context(), recording); err != nil {
 		os.Remove(filePath)
-		sendJSONError(w, "Failed to save recording metadata", http.StatusInternalServerError)
+		if cameraPath != "" {
+			os.Remove(cameraPath)
+		}
+		fail("Failed to save recording metadata", http.StatusInternalServerError)
 		return
 	}
 
+	upload.Complete(recording.ID.Hex())
+	// Chapters and variants of composited recordings are made once the
+	// composite is done
+	if cameraPath != "" {
+		h.composites.Enqueue(recording)
+	} else {
+		h.chapters.Enqueue(recording)
+		h.variants.Enqueue(recording)
+	}
+
+	h.analytics.Record(analytics.Event{
+		Type:    analytics.EventUpload,
+		UserID:  user.ID.Hex(),
+		Role:    string(user.Role),
+		RefType: "recording",
+		RefID:   recording.ID.Hex(),
+		Value:   fileSize,
+	})
+
 	resp := recording.ToResponse()
 	resp.StreamURL = fmt.Sprintf("/api/recordings/%s/stream", recording.ID.Hex())
 	sendJSON(w, resp, http.StatusCreated)
 }
 
+// saveFile copies src to a new file at path, encrypted when enabled.
+func (h *RecordingHandler) saveFile(ctx context.Context, path string, src io.Reader) error {
+	dst, err := h.files.Create(ctx, path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // ListRecordings returns recordings based on user role.
 func (h *RecordingHandler) ListRecordings(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -184,14 +349,13 @@ func (h *RecordingHandler) ListRecordings(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
 	var recordings []models.Recording
+	var err error
 
 	switch user.Role {
 	case models.RoleAdmin:
@@ -211,26 +375,42 @@ func (h *RecordingHandler) ListRecordings(w http.ResponseWriter, r *http.Request
 	}
 
 	if err != nil {
-		sendJSONError(w, "Failed to fetch recordings", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to fetch recordings", err)
 		return
 	}
 
+	// Reported content stays hidden from everyone but admins until reviewed,
+	// and restricted recordings from the students not allowed to watch them
+	if user.Role != models.RoleAdmin {
+		visible := make([]models.Recording, 0, len(recordings))
+		for _, rec := range recordings {
+			if rec.Hidden || (user.Role == models.RoleStudent && !rec.AllowsStudent(user.ID)) {
+				continue
+			}
+			visible = append(visible, rec)
+		}
+		recordings = visible
+	}
+
 	// Enrich response
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
+	progress := h.watch.ProgressFor(r.Context(), user)
 	response := make([]models.RecordingResponse, len(recordings))
 	for i, rec := range recordings {
 		resp := rec.ToResponse()
 		resp.StreamURL = fmt.Sprintf("/api/recordings/%s/stream", rec.ID.Hex())
-
-		if batch, err := h.batchRepo.FindByID(r.Context(), rec.BatchID.Hex()); err == nil {
-			resp.BatchName = batch.Name
+		resp.BatchName = names.Batch(rec.BatchID, rec.BatchName)
+		resp.PresenterName = names.User(rec.PresenterID, rec.PresenterName)
+		if user.Role == models.RoleAdmin || rec.PresenterID == user.ID {
+			resp.AllowedStudents = allowedStudentIDs(&rec)
 		}
-		if presenter, err := h.userRepo.FindByID(r.Context(), rec.PresenterID.Hex()); err == nil {
-			resp.PresenterName = presenter.Name
+		if watched, ok := progress[rec.ID]; ok {
+			resp.Progress = watched.Summary(rec.Duration)
 		}
 		response[i] = resp
 	}
 
-	sendJSON(w, response, http.StatusOK)
+	sendJSONFields(w, r, response, http.StatusOK)
 }
 
 // GetRecording returns a single recording.
@@ -250,20 +430,222 @@ func (h *RecordingHandler) GetRecording(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	if recording.Hidden && user.Role != models.RoleAdmin {
+		sendJSONError(w, "This recording is hidden pending review", http.StatusForbidden)
+		return
+	}
+	if user.Role == models.RoleStudent && !recording.AllowsStudent(user.ID) {
+		sendJSONError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
 	resp := recording.ToResponse()
 	resp.StreamURL = fmt.Sprintf("/api/recordings/%s/stream", recording.ID.Hex())
+	resp.BatchName = names.Batch(recording.BatchID, recording.BatchName)
+	resp.PresenterName = names.User(recording.PresenterID, recording.PresenterName)
+	if user.Role == models.RoleAdmin || recording.PresenterID == user.ID {
+		resp.AllowedStudents = allowedStudentIDs(recording)
+	}
 
-	if batch, err := h.batchRepo.FindByID(r.Context(), recording.BatchID.Hex()); err == nil {
-		resp.BatchName = batch.Name
+	sendJSON(w, resp, http.StatusOK)
+}
+
+// Chapters returns a recording's chapters (GET /api/recordings/{id}/chapters),
+// with those proposed from scene changes for its presenter and admins, or
+// replaces them (PUT), which is how the presenter accepts or edits a
+// proposal. Either way the proposal is then settled.
+func (h *RecordingHandler) Chapters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	if presenter, err := h.userRepo.FindByID(r.Context(), recording.PresenterID.Hex()); err == nil {
-		resp.PresenterName = presenter.Name
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
 	}
 
-	sendJSON(w, resp, http.StatusOK)
+	recordingID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/recordings/"), "/")[0]
+	recording, err := h.recordingRepo.FindByID(r.Context(), recordingID)
+	if err != nil {
+		sendJSONError(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	if recording.Hidden && user.Role != models.RoleAdmin {
+		sendJSONError(w, "This recording is hidden pending review", http.StatusForbidden)
+		return
+	}
+	if user.Role == models.RoleStudent {
+		batch, err := h.batchRepo.FindByID(r.Context(), recording.BatchID.Hex())
+		if err != nil || !batch.HasStudent(user.ID.Hex()) || !recording.AllowsStudent(user.ID) {
+			sendJSONError(w, "Access denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	owns := user.Role == models.RoleAdmin || recording.PresenterID == user.ID
+	if r.Method == http.MethodGet {
+		resp := map[string]interface{}{"chapters": nonNilChapters(recording.Chapters)}
+		if owns {
+			resp["proposed"] = nonNilChapters(recording.ProposedChapters)
+		}
+		sendJSON(w, resp, http.StatusOK)
+		return
+	}
+
+	if !owns {
+		sendJSONError(w, "You can only edit chapters of your own recordings", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Chapters []models.Chapter `json:"chapters" validate:"max=100"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	list := nonNilChapters(req.Chapters)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+	for i, chapter := range list {
+		if i > 0 && chapter.Start == list[i-1].Start {
+			sendJSONError(w, "Two chapters can't start at the same time", http.StatusBadRequest)
+			return
+		}
+		if recording.Duration > 0 && chapter.Start >= recording.Duration {
+			sendJSONError(w, "Chapters must start before the recording ends", http.StatusBadRequest)
+			return
+		}
+		list[i].Title = strings.TrimSpace(chapter.Title)
+	}
+
+	if err := h.recordingRepo.SetChapters(r.Context(), recording.ID, list); err != nil {
+		sendStoreError(w, "Failed to save chapters", err)
+		return
+	}
+	sendJSON(w, map[string]interface{}{"chapters": list}, http.StatusOK)
 }
 
-// StreamRecording streams a recording file.
+// nonNilChapters returns chapters, or an empty list for nil, so responses
+// hold [] rather than null.
+func nonNilChapters(chapters []models.Chapter) []models.Chapter {
+	if chapters == nil {
+		return []models.Chapter{}
+	}
+	return chapters
+}
+
+// Visibility returns (GET /api/recordings/{id}/visibility) or replaces (PUT
+// {"studentIds": [...]}) the students of the batch allowed to watch a
+// recording, such as those of a remedial session. An empty list opens it to
+// the whole batch again. Only its presenter and admins can see or change it.
+func (h *RecordingHandler) Visibility(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	recordingID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/recordings/"), "/")[0]
+	recording, err := h.recordingRepo.FindByID(r.Context(), recordingID)
+	if err != nil {
+		sendJSONError(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	if user.Role != models.RoleAdmin && recording.PresenterID != user.ID {
+		sendJSONError(w, "You can only change who sees your own recordings", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req struct {
+			StudentIDs []string `json:"studentIds" validate:"max=500"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		batch, err := h.batchRepo.FindByID(r.Context(), recording.BatchID.Hex())
+		if err != nil {
+			sendJSONError(w, "Batch not found", http.StatusNotFound)
+			return
+		}
+
+		seen := make(map[primitive.ObjectID]bool, len(req.StudentIDs))
+		allowed := make([]primitive.ObjectID, 0, len(req.StudentIDs))
+		for _, id := range req.StudentIDs {
+			studentID, err := primitive.ObjectIDFromHex(id)
+			if err != nil || !batch.HasStudent(id) {
+				sendJSONError(w, "Only students of the recording's batch can be allowed", http.StatusBadRequest)
+				return
+			}
+			if !seen[studentID] {
+				seen[studentID] = true
+				allowed = append(allowed, studentID)
+			}
+		}
+
+		if err := h.recordingRepo.SetAllowedStudents(r.Context(), recording.ID, allowed); err != nil {
+			sendStoreError(w, "Failed to save visibility", err)
+			return
+		}
+		recording.AllowedStudents = allowed
+		log.Printf("[Recording] %s restricted %s to %d students", user.Name, recording.ID.Hex(), len(allowed))
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"restricted": recording.IsRestricted(),
+		"studentIds": allowedStudentIDs(recording),
+	}, http.StatusOK)
+}
+
+// allowedStudentIDs returns the students a recording is restricted to, for
+// its presenter and admins. It is empty if the whole batch may watch.
+func allowedStudentIDs(recording *models.Recording) []string {
+	ids := make([]string, len(recording.AllowedStudents))
+	for i, id := range recording.AllowedStudents {
+		ids[i] = id.Hex()
+	}
+	return ids
+}
+
+// UploadStatus returns the progress of one of the user's recording uploads
+// (GET /api/recordings/uploads/{uploadId}).
+func (h *RecordingHandler) UploadStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/recordings/uploads/")
+	status, ok := h.uploads.Get(id, user.ID.Hex(), user.Role == models.RoleAdmin)
+	if !ok {
+		sendJSONError(w, "Upload not found", http.StatusNotFound)
+		return
+	}
+
+	sendJSON(w, status, http.StatusOK)
+}
+
+// StreamRecording streams a recording file, or redirects to a signed CDN
+// URL for it when a CDN is configured. The "variant" query parameter picks
+// a smaller copy: 480p video or audio only.
 func (h *RecordingHandler) StreamRecording(w http.ResponseWriter, r *http.Request) {
 	// Extract recording ID from URL: /api/recordings/{id}/stream
 	path := strings.TrimPrefix(r.URL.Path, "/api/recordings/")
@@ -277,17 +659,8 @@ func (h *RecordingHandler) StreamRecording(w http.ResponseWriter, r *http.Reques
 	log.Printf("[Recording] Stream request for recording: %s", recordingID)
 
 	// Verify auth
-	token := extractToken(r)
-	if token == "" {
-		log.Printf("[Recording] No token provided for stream request")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		log.Printf("[Recording] Invalid token: %v", err)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := h.streamUser(w, r, recordingID)
+	if !ok {
 		return
 	}
 	log.Printf("[Recording] Stream access by user: %s (role: %s)", user.Name, user.Role)
@@ -300,36 +673,61 @@ func (h *RecordingHandler) StreamRecording(w http.ResponseWriter, r *http.Reques
 	}
 	log.Printf("[Recording] Found recording: %s, file: %s", recording.Title, recording.FilePath)
 
-	// Check access for students
-	if user.Role == models.RoleStudent {
-		batch, err := h.batchRepo.FindByID(r.Context(), recording.BatchID.Hex())
-		if err != nil || !batch.HasStudent(user.ID.Hex()) {
-			log.Printf("[Recording] Access denied for student %s", user.Name)
-			http.Error(w, "Access denied", http.StatusForbidden)
+	if status, message := h.streamAccess(r.Context(), user, recording); status != 0 {
+		http.Error(w, message, status)
+		return
+	}
+
+	// A smaller variant when asked for (?variant=480p or ?variant=audio),
+	// until it's made the original; the header tells players which they got
+	filePath, fileName, fileSize, mimeType := recording.FilePath, recording.FileName, recording.FileSize, recording.MimeType
+	served := "original"
+	if name := r.URL.Query().Get("variant"); name != "" {
+		if !variants.Known(name) {
+			http.Error(w, "Unknown variant", http.StatusBadRequest)
 			return
 		}
+		if variant, ok := recording.Variant(name); ok {
+			filePath, fileSize, mimeType = variant.FilePath, variant.FileSize, variant.MimeType
+			fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + filepath.Ext(filePath)
+			served = name
+		}
 	}
+	w.Header().Set("X-Recording-Variant", served)
 
-	// Open the file
-	file, err := os.Open(recording.FilePath)
-	if err != nil {
-		log.Printf("[Recording] Failed to open file %s: %v", recording.FilePath, err)
-		http.Error(w, "Recording file not found", http.StatusNotFound)
+	// Players follow the redirect and make their range requests to the CDN
+	if location, ok := h.cdnURL(filePath); ok {
+		h.analytics.Record(analytics.Event{
+			Type:    analytics.EventWatch,
+			UserID:  user.ID.Hex(),
+			Role:    string(user.Role),
+			RefType: "recording",
+			RefID:   recording.ID.Hex(),
+			Value:   rangeStart(r.Header.Get("Range")),
+			Total:   fileSize,
+		})
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, location, http.StatusFound)
 		return
 	}
-	defer file.Close()
 
-	// Get file info
-	stat, err := file.Stat()
+	// Open the file, decrypting ranges on the fly if it is encrypted
+	file, err := h.files.Open(r.Context(), filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("[Recording] Failed to open file %s: %v", filePath, err)
+		http.Error(w, "Recording file not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("[Recording] Failed to stat file: %v", err)
-		http.Error(w, "Failed to get file info", http.StatusInternalServerError)
+		log.Printf("[Recording] Failed to open file %s: %v", filePath, err)
+		http.Error(w, "Failed to open recording", http.StatusInternalServerError)
 		return
 	}
+	defer file.Close()
 
 	// Normalize MIME type - remove codecs parameter for Content-Type header
 	// Browsers handle the codecs internally
-	mimeType := recording.MimeType
+	original := mimeType
 	if idx := strings.Index(mimeType, ";"); idx != -1 {
 		mimeType = strings.TrimSpace(mimeType[:idx])
 	}
@@ -338,7 +736,17 @@ func (h *RecordingHandler) StreamRecording(w http.ResponseWriter, r *http.Reques
 	}
 
 	log.Printf("[Recording] Streaming file: %s, size: %d bytes, type: %s (original: %s)",
-		recording.FileName, stat.Size(), mimeType, recording.MimeType)
+		fileName, file.Size(), mimeType, original)
+
+	h.analytics.Record(analytics.Event{
+		Type:    analytics.EventWatch,
+		UserID:  user.ID.Hex(),
+		Role:    string(user.Role),
+		RefType: "recording",
+		RefID:   recording.ID.Hex(),
+		Value:   rangeStart(r.Header.Get("Range")),
+		Total:   file.Size(),
+	})
 
 	// Set headers for video streaming
 	w.Header().Set("Content-Type", mimeType)
@@ -351,7 +759,233 @@ func (h *RecordingHandler) StreamRecording(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Access-Control-Allow-Headers", "Range")
 
 	// Handle range requests for video seeking
-	http.ServeContent(w, r, recording.FileName, stat.ModTime(), file)
+	http.ServeContent(w, r, fileName, file.ModTime(), file)
+}
+
+// PlaybackToken mints a token for one playback session of a recording
+// (POST /api/recordings/{id}/playback-token) and returns the stream URL
+// carrying it. It lasts the recording's duration plus the configured TTL.
+func (h *RecordingHandler) PlaybackToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, claims, err := h.authService.Authenticate(r.Context(), extractToken(r))
+	if err != nil {
+		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	recordingID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/recordings/"), "/")[0]
+	recording, err := h.recordingRepo.FindByID(r.Context(), recordingID)
+	if err != nil {
+		sendJSONError(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+	if status, message := h.streamAccess(r.Context(), user, recording); status != 0 {
+		sendJSONError(w, message, status)
+		return
+	}
+
+	ttl := h.playback.TTL + time.Duration(recording.Duration)*time.Second
+	token, expiresAt, err := h.authService.IssuePlaybackToken(recording.ID.Hex(), claims, ttl)
+	if err != nil {
+		log.Printf("[Recording] Failed to issue playback token for %s: %v", recording.ID.Hex(), err)
+		sendStoreError(w, "Failed to issue playback token", err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"token":     token,
+		"expiresAt": expiresAt,
+		"streamUrl": fmt.Sprintf("/api/recordings/%s/stream?playback=%s", recording.ID.Hex(), token),
+	}, http.StatusOK)
+}
+
+// streamUser authenticates a stream request. Players send a playback token
+// in ?playback=; a login token is accepted in the Authorization header, and
+// in ?token= only while login tokens in stream URLs are allowed. It writes
+// the error response on failure.
+func (h *RecordingHandler) streamUser(w http.ResponseWriter, r *http.Request, recordingID string) (*models.User, bool) {
+	if token := r.URL.Query().Get("playback"); token != "" {
+		user, claims, err := h.authService.GetUserFromPlaybackToken(r.Context(), token)
+		if err != nil || !strings.EqualFold(claims.RecordingID, recordingID) {
+			log.Printf("[Recording] Invalid playback token for recording %s", recordingID)
+			http.Error(w, "Invalid or expired playback token", http.StatusUnauthorized)
+			return nil, false
+		}
+		if h.playback.PinClient != nil && !h.playbackPins.use(claims.ID, h.playback.PinClient.ClientIP(r), claims.ExpiresAt.Time) {
+			log.Printf("[Recording] Playback token for recording %s replayed from another address", recordingID)
+			http.Error(w, "This playback token is in use elsewhere. Request a new one", http.StatusUnauthorized)
+			return nil, false
+		}
+		return user, true
+	}
+
+	token := middleware.QueryToken(r)
+	if token == "" {
+		log.Printf("[Recording] No token provided for stream request")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if r.Header.Get("Authorization") == "" && !h.playback.LoginTokenInQuery {
+		http.Error(w, "Request a playback token to stream this recording", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user, err := h.authService.GetUserFromToken(r.Context(), token)
+	if err != nil {
+		log.Printf("[Recording] Invalid token: %v", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// streamAccess checks whether the user may stream the recording. It returns
+// the status and message to refuse with, or 0 if they may.
+func (h *RecordingHandler) streamAccess(ctx context.Context, user *models.User, recording *models.Recording) (int, string) {
+	if recording.Hidden && user.Role != models.RoleAdmin {
+		return http.StatusForbidden, "This recording is hidden pending review"
+	}
+
+	if recording.IsArchived() {
+		return http.StatusConflict, "This recording is archived, request a restore to watch it"
+	}
+
+	// Check access for students
+	if user.Role == models.RoleStudent {
+		batch, err := h.batchRepo.FindByID(ctx, recording.BatchID.Hex())
+		if err != nil || !batch.HasStudent(user.ID.Hex()) || !recording.AllowsStudent(user.ID) {
+			log.Printf("[Recording] Access denied for student %s", user.Name)
+			return http.StatusForbidden, "Access denied"
+		}
+	}
+	return 0, ""
+}
+
+// cdnURL returns the signed CDN URL of a recording's file, or one of its
+// variants. Encrypted files are decrypted by the server, so they are never
+// served from the CDN.
+func (h *RecordingHandler) cdnURL(filePath string) (string, bool) {
+	if !h.cdn.Enabled() {
+		return "", false
+	}
+	path, ok := h.originPath(filePath)
+	if !ok {
+		return "", false
+	}
+	if _, encrypted, err := encryption.KeyID(filePath); err != nil || encrypted {
+		return "", false
+	}
+	return h.cdn.URL(path, time.Now()), true
+}
+
+// originPath returns the path a stored file is served at by the CDN origin
+// (e.g. "/recordings/a.webm"), and false for files outside the storage path.
+func (h *RecordingHandler) originPath(filePath string) (string, bool) {
+	rel, err := filepath.Rel(h.storagePath, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return "/" + filepath.ToSlash(rel), true
+}
+
+// Origin serves recording files to the CDN (GET /cdn/recordings/{file}).
+// Requests must carry a valid signed URL token; encrypted files are refused.
+func (h *RecordingHandler) Origin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.cdn.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/cdn")
+	query := r.URL.Query()
+	if err := h.cdn.Verify(path, query.Get("expires"), query.Get("token"), time.Now()); err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	fileName := strings.TrimPrefix(path, "/"+recordingsDir+"/")
+	if fileName == path || fileName == "" || strings.ContainsAny(fileName, `/\`) {
+		http.NotFound(w, r)
+		return
+	}
+	filePath := filepath.Join(h.storagePath, recordingsDir, fileName)
+
+	if _, encrypted, err := encryption.KeyID(filePath); err != nil || encrypted {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Failed to open recording", http.StatusInternalServerError)
+		return
+	}
+
+	// Recording files are never rewritten, so the CDN may keep them as long
+	// as it likes; access is limited by the token on each URL
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, fileName, stat.ModTime(), file)
+}
+
+// RestoreRecording requests an archived recording back from cold storage and
+// returns it with the restore ETA (POST /api/recordings/{id}/restore). The
+// user is notified when it can be watched.
+func (h *RecordingHandler) RestoreRecording(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	recordingID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/recordings/"), "/")[0]
+	recording, err := h.recordingRepo.FindByID(r.Context(), recordingID)
+	if err != nil {
+		sendJSONError(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	if recording.Hidden && user.Role != models.RoleAdmin {
+		sendJSONError(w, "This recording is hidden pending review", http.StatusForbidden)
+		return
+	}
+	if user.Role == models.RoleStudent {
+		batch, err := h.batchRepo.FindByID(r.Context(), recording.BatchID.Hex())
+		if err != nil || !batch.HasStudent(user.ID.Hex()) || !recording.AllowsStudent(user.ID) {
+			sendJSONError(w, "Access denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	updated, err := h.coldStorage.RequestRestore(r.Context(), recording, user)
+	if errors.Is(err, coldstorage.ErrNotArchived) {
+		sendJSONError(w, "Recording is not archived", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[Recording] Failed to restore %s: %v", recordingID, err)
+		sendStoreError(w, "Failed to request restore", err)
+		return
+	}
+
+	sendJSON(w, updated.ToResponse(), http.StatusAccepted)
 }
 
 // DeleteRecording deletes a recording.
@@ -361,10 +995,8 @@ func (h *RecordingHandler) DeleteRecording(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -384,30 +1016,38 @@ func (h *RecordingHandler) DeleteRecording(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if err := h.legalHolds.Guard(r.Context(), models.HoldContentRecording, recordingID, models.LegalHoldDeleteBlocked, user); err != nil {
+		sendJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
+
 	// Delete file
 	os.Remove(recording.FilePath)
+	if recording.CameraPath != "" {
+		os.Remove(recording.CameraPath)
+	}
+	variants.Remove(recording.Variants)
+	h.coldStorage.Delete(r.Context(), recording)
 
 	// Delete record
 	if err := h.recordingRepo.Delete(r.Context(), recordingID); err != nil {
-		sendJSONError(w, "Failed to delete recording", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to delete recording", err)
 		return
 	}
 
 	sendJSON(w, map[string]string{"message": "Recording deleted"}, http.StatusOK)
 }
 
-// isValidVideoType checks if the content type is a valid video type.
-func isValidVideoType(contentType string) bool {
-	validTypes := []string{
-		"video/webm",
-		"video/mp4",
-		"video/x-matroska",
-		"video/quicktime",
-	}
-	for _, t := range validTypes {
-		if strings.HasPrefix(contentType, t) {
-			return true
-		}
+// rangeStart returns the first byte offset of a "bytes=N-" Range header, or 0.
+func rangeStart(header string) int64 {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0
+	}
+	start, _, _ := strings.Cut(spec, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0
 	}
-	return false
+	return n
 }
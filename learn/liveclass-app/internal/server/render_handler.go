@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+)
+
+// renderMarkdownRequest is the body of POST /api/render/markdown.
+type renderMarkdownRequest struct {
+	Markdown string `json:"markdown" validate:"required,max=20000"`
+	// Profile selects the allowlist: "rich" (default) for descriptions,
+	// "chat" for messages.
+	Profile string `json:"profile" validate:"oneof=rich chat"`
+}
+
+// RenderMarkdown renders Markdown to sanitized HTML, so clients preview
+// exactly what the server would keep (POST /api/render/markdown).
+func RenderMarkdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req renderMarkdownRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	policy := richtext.Rich
+	if req.Profile == "chat" {
+		policy = richtext.Chat
+	}
+	sendJSON(w, map[string]string{"html": policy.Markdown(req.Markdown)}, http.StatusOK)
+}
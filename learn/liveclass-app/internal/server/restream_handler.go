@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/egress"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// RestreamHandler lets presenters republish a live class to an external
+// RTMP or SRT target, such as an unlisted YouTube broadcast.
+type RestreamHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	hub          *room.Hub
+	egress       *egress.Manager
+}
+
+// NewRestreamHandler creates a new RestreamHandler.
+func NewRestreamHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, hub *room.Hub, egressManager *egress.Manager) *RestreamHandler {
+	return &RestreamHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		hub:          hub,
+		egress:       egressManager,
+	}
+}
+
+// Restream handles /api/rooms/{id}/restream for the class presenter and
+// admins:
+//   - GET: the state of the room's latest re-stream
+//   - POST {"target"}: start re-streaming to an rtmp://, rtmps:// or srt:// URL
+//   - DELETE: stop re-streaming
+//
+// Re-streams also stop when the class ends.
+func (h *RestreamHandler) Restream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract room ID from URL: /api/rooms/{id}/restream
+	roomID := strings.ToUpper(strings.Split(strings.TrimPrefix(r.URL.Path, "/api/rooms/"), "/")[0])
+
+	if user.Role != models.RoleAdmin {
+		schedule, err := h.scheduleRepo.FindByRoomID(r.Context(), roomID)
+		if err != nil || schedule.PresenterID != user.ID {
+			sendJSONError(w, "Only admin or the class presenter can re-stream this class", http.StatusForbidden)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var latest *egress.Status // nil until the class is first re-streamed
+		if status, ok := h.egress.Status(roomID); ok {
+			latest = &status
+		}
+		sendJSON(w, map[string]interface{}{
+			"available": h.egress.Available(),
+			"restream":  latest,
+		}, http.StatusOK)
+
+	case http.MethodPost:
+		var req struct {
+			Target string `json:"target" validate:"required,max=2048"`
+		}
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		liveRoom, exists := h.hub.GetRoom(roomID)
+		if !exists {
+			sendJSONError(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		if !liveRoom.IsStreamReady() {
+			sendJSONError(w, "The presenter isn't streaming yet", http.StatusConflict)
+			return
+		}
+
+		status, err := h.egress.Start(liveRoom, strings.TrimSpace(req.Target), user.Name)
+		switch {
+		case errors.Is(err, egress.ErrUnavailable):
+			sendJSONError(w, "Re-streaming is not available", http.StatusServiceUnavailable)
+		case errors.Is(err, egress.ErrInvalidTarget), errors.Is(err, egress.ErrTargetNotAllowed):
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, egress.ErrRunning):
+			sendJSONError(w, "This class is already being re-streamed", http.StatusConflict)
+		case err != nil:
+			log.Printf("[Restream] Failed to start re-streaming room %s: %v", roomID, err)
+			sendJSONError(w, "Failed to start re-streaming", http.StatusInternalServerError)
+		default:
+			sendJSON(w, status, http.StatusAccepted)
+		}
+
+	case http.MethodDelete:
+		status, err := h.egress.Stop(roomID, "stopped by "+user.Name)
+		if errors.Is(err, egress.ErrNotRunning) {
+			sendJSONError(w, "This class isn't being re-streamed", http.StatusNotFound)
+			return
+		}
+		sendJSON(w, status, http.StatusOK)
+	}
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/jinshatcp/brightline-academy/learn/internal/timeline"
+)
+
+// Room timeline limits
+const (
+	defaultTimelineWindow = 7 * 24 * time.Hour
+	maxTimelineWindow     = 31 * 24 * time.Hour
+	maxTimelineEvents     = 10000
+)
+
+// RoomHandler handles live room inspection endpoints.
+type RoomHandler struct {
+	authService     *auth.Service
+	scheduleRepo    *repository.ScheduleRepository
+	eventRepo       *repository.RoomEventRepository
+	scheduleHandler *ScheduleHandler
+	hub             *room.Hub
+}
+
+// NewRoomHandler creates a new RoomHandler.
+func NewRoomHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, eventRepo *repository.RoomEventRepository, scheduleHandler *ScheduleHandler, hub *room.Hub) *RoomHandler {
+	return &RoomHandler{
+		authService:     authService,
+		scheduleRepo:    scheduleRepo,
+		eventRepo:       eventRepo,
+		scheduleHandler: scheduleHandler,
+		hub:             hub,
+	}
+}
+
+// LiveRoomInfo summarizes an active room on this instance.
+type LiveRoomInfo struct {
+	RoomID        string `json:"roomId"`
+	Participants  int    `json:"participants"`
+	HasPresenter  bool   `json:"hasPresenter"`
+	StreamReady   bool   `json:"streamReady"`
+	ScheduleID    string `json:"scheduleId,omitempty"`
+	Title         string `json:"title,omitempty"`
+	PresenterName string `json:"presenterName,omitempty"`
+}
+
+// ListRooms returns the active rooms on this instance (GET /api/admin/rooms).
+func (h *RoomHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rooms := h.hub.Rooms()
+	list := make([]LiveRoomInfo, 0, len(rooms))
+	for _, liveRoom := range rooms {
+		info := LiveRoomInfo{
+			RoomID:       liveRoom.ID,
+			Participants: liveRoom.ParticipantCount(),
+			HasPresenter: liveRoom.HasPresenter(),
+			StreamReady:  liveRoom.IsStreamReady(),
+		}
+		if presenter := liveRoom.GetPresenter(); presenter != nil {
+			info.PresenterName = presenter.Name
+		}
+		if schedule, err := h.scheduleRepo.FindByRoomID(r.Context(), liveRoom.ID); err == nil {
+			info.ScheduleID = schedule.ID.Hex()
+			info.Title = schedule.Title
+		}
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].RoomID < list[j].RoomID })
+	sendJSON(w, list, http.StatusOK)
+}
+
+// EndRoom force-ends a live room (POST /api/admin/rooms/{id}/end). The linked
+// class, if still live, is completed and archived, then everyone is disconnected.
+func (h *RoomHandler) EndRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract room ID from URL: /api/admin/rooms/{id}/end
+	path := strings.TrimPrefix(r.URL.Path, "/api/admin/rooms/")
+	roomID := strings.ToUpper(strings.Split(path, "/")[0])
+
+	liveRoom, exists := h.hub.GetRoom(roomID)
+	schedule, err := h.scheduleRepo.FindByRoomID(r.Context(), roomID)
+	if !exists && err != nil {
+		sendJSONError(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	completed := false
+	if err == nil && schedule.Status == models.ClassStatusLive {
+		if err := h.scheduleHandler.completeClass(r.Context(), schedule); err != nil {
+			sendTransitionError(w, "Failed to end class", err)
+			return
+		}
+		completed = true
+	}
+
+	disconnected := 0
+	if exists {
+		disconnected = liveRoom.Close("This class was ended by an administrator")
+	}
+
+	log.Printf("[Room] %s force-ended (class completed: %v, disconnected: %d)", roomID, completed, disconnected)
+	sendJSON(w, map[string]interface{}{
+		"message":      "Room ended",
+		"completed":    completed,
+		"disconnected": disconnected,
+	}, http.StatusOK)
+}
+
+// completeIdleRoom completes the class of a room closed for inactivity, if
+// it is still live.
+func (h *RoomHandler) completeIdleRoom(liveRoom *room.Room) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, liveRoom.ID)
+	if err != nil || schedule.Status != models.ClassStatusLive {
+		return
+	}
+	if err := h.scheduleHandler.completeClass(ctx, schedule); err != nil {
+		log.Printf("[Room] Failed to complete idle class %s: %v", schedule.ID.Hex(), err)
+		return
+	}
+	log.Printf("[Room] %s closed for inactivity, class %q completed", liveRoom.ID, schedule.Title)
+}
+
+// GetStats returns media forwarding stats for a live room (GET /api/rooms/{id}/stats).
+// Admins can inspect any room; presenters only rooms of classes they teach.
+func (h *RoomHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract room ID from URL: /api/rooms/{id}/stats
+	path := strings.TrimPrefix(r.URL.Path, "/api/rooms/")
+	roomID := strings.ToUpper(strings.Split(path, "/")[0])
+
+	if user.Role != models.RoleAdmin {
+		schedule, err := h.scheduleRepo.FindByRoomID(r.Context(), roomID)
+		if err != nil || schedule.PresenterID != user.ID {
+			sendJSONError(w, "Only admin or the class presenter can view room stats", http.StatusForbidden)
+			return
+		}
+	}
+
+	liveRoom, exists := h.hub.GetRoom(roomID)
+	if !exists {
+		sendJSONError(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	sendJSON(w, liveRoom.MediaStats(), http.StatusOK)
+}
+
+// GetTimeline returns the lifecycle events of a room with a report per
+// session (GET /api/rooms/{id}/timeline?from=&to=). from and to are RFC 3339
+// and default to the last seven days. Admins can inspect any room; presenters
+// only rooms of classes they teach.
+func (h *RoomHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract room ID from URL: /api/rooms/{id}/timeline
+	path := strings.TrimPrefix(r.URL.Path, "/api/rooms/")
+	roomID := strings.ToUpper(strings.Split(path, "/")[0])
+
+	query := struct {
+		From string `json:"from" validate:"rfc3339"`
+		To   string `json:"to" validate:"rfc3339"`
+	}{r.URL.Query().Get("from"), r.URL.Query().Get("to")}
+	if !checkRequest(w, &query) {
+		return
+	}
+	to := time.Now()
+	if query.To != "" {
+		to, _ = time.Parse(time.RFC3339, query.To)
+	}
+	from := to.Add(-defaultTimelineWindow)
+	if query.From != "" {
+		from, _ = time.Parse(time.RFC3339, query.From)
+	}
+	if !to.After(from) || to.Sub(from) > maxTimelineWindow {
+		sendJSONError(w, "to must be after from and at most 31 days later", http.StatusBadRequest)
+		return
+	}
+
+	if user.Role != models.RoleAdmin {
+		schedule, err := h.scheduleRepo.FindByRoomID(r.Context(), roomID)
+		if err != nil || schedule.PresenterID != user.ID {
+			sendJSONError(w, "Only admin or the class presenter can view the room timeline", http.StatusForbidden)
+			return
+		}
+	}
+
+	events, err := h.eventRepo.FindByRoom(r.Context(), roomID, from, to, maxTimelineEvents)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch timeline", err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"roomId":    roomID,
+		"from":      from,
+		"to":        to,
+		"events":    events,
+		"sessions":  timeline.Sessions(events),
+		"truncated": len(events) == maxTimelineEvents,
+	}
+	// The session running on this instance, if any
+	if liveRoom, exists := h.hub.GetRoom(roomID); exists {
+		response["currentSessionId"] = liveRoom.SessionID
+	}
+	sendJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// RoomTokenOptions configures room tokens: signed tokens binding a
+// connection's signaling messages to the participant who joined on it.
+type RoomTokenOptions struct {
+	TTL      time.Duration // Lifetime of a token; a new one is sent halfway through
+	Required bool          // Refuse messages without a token, not just those with a bad one
+}
+
+// issueRoomToken gives a participant a new room token and schedules its
+// renewal. Unless the token is a renewal, older tokens stop working: a
+// reconnect or replayed join leaves nothing usable on the old connection.
+func (h *Handler) issueRoomToken(conn *WSConn, r *room.Room, p *room.Participant, renewed bool) (string, time.Time, bool) {
+	token, claims, err := h.authService.IssueRoomToken(r.ID, p.ID, p.UserID, h.roomTokens.TTL)
+	if err != nil {
+		log.Printf("[Handler] Failed to issue room token for %s: %v", p.ID, err)
+		return "", time.Time{}, false
+	}
+	p.SetRoomToken(claims.ID, renewed)
+
+	time.AfterFunc(h.roomTokens.TTL/2, func() {
+		h.renewRoomToken(conn, r, p)
+	})
+	return token, claims.ExpiresAt.Time, true
+}
+
+// renewRoomToken sends a participant still in the room on the same
+// connection a fresh room token, so idle clients never hold an expired one.
+func (h *Handler) renewRoomToken(conn *WSConn, r *room.Room, p *room.Participant) {
+	if current, ok := r.GetParticipant(p.ID); !ok || current != p || !r.IsBoundTo(p, conn) {
+		return
+	}
+	token, expiresAt, ok := h.issueRoomToken(conn, r, p, true)
+	if !ok {
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":               "room-token",
+		"roomToken":          token,
+		"roomTokenExpiresAt": expiresAt,
+	})
+	conn.Send(data)
+}
+
+// checkRoomToken reports whether a signaling message may be handled for the
+// participant: its room token must be one the participant was issued last,
+// for this room and account. Without a token the message is refused only
+// when tokens are required, since older clients don't send one.
+func (h *Handler) checkRoomToken(conn *WSConn, msg Message, p *room.Participant, r *room.Room) bool {
+	if msg.RoomToken == "" {
+		if h.roomTokens.Required {
+			sendError(conn, "Room token required. Rejoin the class")
+			return false
+		}
+		return true
+	}
+
+	claims, err := h.authService.ValidateRoomToken(msg.RoomToken)
+	if err != nil || !claims.Matches(r.ID, p.ID, p.UserID) || !p.UseRoomToken(claims.ID) {
+		log.Printf("[Handler] Refused %s from %s (%s): invalid room token", msg.Type, p.Name, p.ID)
+		sendError(conn, "Invalid room token. Rejoin the class")
+		return false
+	}
+	return true
+}
+
+// canResumePresenter reports whether a presenter join may take over the
+// presenter reconnecting in a room. The join must carry the presenter's
+// last room token; without one it's allowed only while tokens are optional.
+func (h *Handler) canResumePresenter(msg Message, r *room.Room) bool {
+	presenter := r.GetPresenter()
+	if presenter == nil || !r.IsPresenterReconnecting() {
+		return true // Nothing to take over
+	}
+	if msg.RoomToken == "" {
+		return !h.roomTokens.Required
+	}
+	claims, err := h.authService.ValidateRoomToken(msg.RoomToken)
+	return err == nil && claims.RoomID == r.ID && claims.ParticipantID == presenter.ID && presenter.UseRoomToken(claims.ID)
+}
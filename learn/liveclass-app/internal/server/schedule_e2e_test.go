@@ -0,0 +1,131 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/testsupport"
+)
+
+func TestScheduleLifecycle(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	presenter, presenterUser := srv.NewUser(t, models.RolePresenter)
+	student, studentUser := srv.NewUser(t, models.RoleStudent)
+	outsider, _ := srv.NewUser(t, models.RoleStudent)
+
+	batch, err := srv.Admin(t).CreateBatch(ctx, "Lifecycle batch", presenterUser.ID)
+	if err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	if err := presenter.AddStudents(ctx, batch.ID, studentUser.ID); err != nil {
+		t.Fatalf("add student: %v", err)
+	}
+
+	start := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	schedule, err := presenter.CreateSchedule(ctx, testsupport.ScheduleRequest{
+		Title:     "Lifecycle class",
+		BatchID:   batch.ID,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+	if schedule.Status != models.ClassStatusScheduled {
+		t.Fatalf("status after scheduling = %q, want %q", schedule.Status, models.ClassStatusScheduled)
+	}
+
+	if _, err := student.JoinClass(ctx, schedule.ID); testsupport.StatusOf(err) != http.StatusBadRequest {
+		t.Fatalf("join before the class started: got %v, want 400", err)
+	}
+	if _, err := student.StartClass(ctx, schedule.ID); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("student starting the class: got %v, want 403", err)
+	}
+
+	roomID, err := presenter.StartClass(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("start class: %v", err)
+	}
+	if roomID == "" {
+		t.Fatal("start class returned no room")
+	}
+	again, err := presenter.StartClass(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("start class again: %v", err)
+	}
+	if again != roomID {
+		t.Fatalf("starting again moved the class to room %s, want %s", again, roomID)
+	}
+
+	live, err := student.GetSchedule(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("get schedule: %v", err)
+	}
+	if live.Status != models.ClassStatusLive || !live.CanJoin {
+		t.Fatalf("started class: status %q, canJoin %v; want %q, true", live.Status, live.CanJoin, models.ClassStatusLive)
+	}
+
+	join, err := student.JoinClass(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("student join: %v", err)
+	}
+	if join.RoomID != roomID || join.IsPresenter {
+		t.Fatalf("student join = room %s, presenter %v; want room %s, not presenter", join.RoomID, join.IsPresenter, roomID)
+	}
+	join, err = presenter.JoinClass(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("presenter join: %v", err)
+	}
+	if !join.IsPresenter {
+		t.Fatal("presenter join: not the presenter")
+	}
+	if _, err := outsider.JoinClass(ctx, schedule.ID); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("join by a student of another batch: got %v, want 403", err)
+	}
+
+	if err := student.EndClass(ctx, schedule.ID); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("student ending the class: got %v, want 403", err)
+	}
+	if err := presenter.EndClass(ctx, schedule.ID); err != nil {
+		t.Fatalf("end class: %v", err)
+	}
+
+	ended, err := presenter.GetSchedule(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("get schedule: %v", err)
+	}
+	if ended.Status != models.ClassStatusCompleted || ended.CanJoin {
+		t.Fatalf("ended class: status %q, canJoin %v; want %q, false", ended.Status, ended.CanJoin, models.ClassStatusCompleted)
+	}
+	if _, err := student.JoinClass(ctx, schedule.ID); testsupport.StatusOf(err) != http.StatusBadRequest {
+		t.Fatalf("join after the class ended: got %v, want 400", err)
+	}
+}
+
+func TestPresenterCannotScheduleForAnotherBatch(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	ctx := testContext(t)
+
+	_, owner := srv.NewUser(t, models.RolePresenter)
+	other, _ := srv.NewUser(t, models.RolePresenter)
+
+	batch, err := srv.Admin(t).CreateBatch(ctx, "Someone else's batch", owner.ID)
+	if err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err = other.CreateSchedule(ctx, testsupport.ScheduleRequest{
+		Title:     "Not mine",
+		BatchID:   batch.ID,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+	})
+	if testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("scheduling for another presenter's batch: got %v, want 403", err)
+	}
+}
@@ -1,46 +1,112 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/archive"
+	"github.com/jinshatcp/brightline-academy/learn/internal/egress"
+	"github.com/jinshatcp/brightline-academy/learn/internal/lock"
 	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notes"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/quizgen"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"github.com/jinshatcp/brightline-academy/learn/internal/rtc"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // ScheduleHandler handles schedule-related endpoints.
 type ScheduleHandler struct {
-	authService  *auth.Service
-	scheduleRepo *repository.ScheduleRepository
-	batchRepo    *repository.BatchRepository
-	userRepo     *repository.UserRepository
+	scheduleRepo     *repository.ScheduleRepository
+	batchRepo        *repository.BatchRepository
+	userRepo         *repository.UserRepository
+	noteRepo         *repository.NoteRepository
+	verificationRepo *repository.VerificationRepository
+	examAuditRepo    *repository.ExamAuditRepository
+	hub              *room.Hub
+	legalHolds       *LegalHoldHandler
+	lobbies          *LobbyHandler
+	maintenance      *MaintenanceHandler
+	notePublisher    *notes.Publisher
+	quizDrafter      *quizgen.Drafter
+	classEmails      *notify.ClassEmails
+	locks            *lock.Locker
+	egress           *egress.Manager
+	recorder         *rtc.Recorder
+	storagePath      string
 }
 
-// NewScheduleHandler creates a new ScheduleHandler.
-func NewScheduleHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository) *ScheduleHandler {
+// Class status transitions run under a per-class lock, so instances don't
+// start a class in two rooms or end it twice.
+const (
+	classLockTTL  = 30 * time.Second // Longest a transition may hold the lock
+	classLockWait = 5 * time.Second  // Longest a request waits for another transition
+)
+
+// ScheduleHandlerDeps are the stores, services and handlers the schedule
+// handler works with.
+type ScheduleHandlerDeps struct {
+	Schedules     *repository.ScheduleRepository
+	Batches       *repository.BatchRepository
+	Users         *repository.UserRepository
+	Notes         *repository.NoteRepository
+	Verifications *repository.VerificationRepository
+	ExamAudits    *repository.ExamAuditRepository
+	Hub           *room.Hub
+	LegalHolds    *LegalHoldHandler
+	Lobbies       *LobbyHandler
+	Maintenance   *MaintenanceHandler
+	NotePublisher *notes.Publisher
+	QuizDrafter   *quizgen.Drafter
+	ClassEmails   *notify.ClassEmails
+	Locks         *lock.Locker
+	Egress        *egress.Manager
+	Recorder      *rtc.Recorder
+}
+
+// NewScheduleHandler creates a new ScheduleHandler writing class archives
+// under storagePath.
+func NewScheduleHandler(deps ScheduleHandlerDeps, storagePath string) *ScheduleHandler {
 	return &ScheduleHandler{
-		authService:  authService,
-		scheduleRepo: scheduleRepo,
-		batchRepo:    batchRepo,
-		userRepo:     userRepo,
+		scheduleRepo:     deps.Schedules,
+		batchRepo:        deps.Batches,
+		userRepo:         deps.Users,
+		noteRepo:         deps.Notes,
+		verificationRepo: deps.Verifications,
+		examAuditRepo:    deps.ExamAudits,
+		hub:              deps.Hub,
+		legalHolds:       deps.LegalHolds,
+		lobbies:          deps.Lobbies,
+		maintenance:      deps.Maintenance,
+		notePublisher:    deps.NotePublisher,
+		quizDrafter:      deps.QuizDrafter,
+		classEmails:      deps.ClassEmails,
+		locks:            deps.Locks,
+		egress:           deps.Egress,
+		recorder:         deps.Recorder,
+		storagePath:      storagePath,
 	}
 }
 
-// ListSchedules returns scheduled classes based on user role.
+// ListSchedules returns scheduled classes based on user role. Clients can
+// select the fields they need, e.g. ?fields=id,title,startTime.
 func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -62,6 +128,7 @@ func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request)
 	}
 
 	var schedules []models.ScheduledClass
+	var err error
 
 	switch user.Role {
 	case models.RoleAdmin:
@@ -76,35 +143,55 @@ func (h *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request)
 	case models.RolePresenter:
 		schedules, err = h.scheduleRepo.FindByPresenter(r.Context(), user.ID.Hex(), fromDate, toDate)
 
+		// Plus classes of batches they assist another presenter with
+		if assisted, _ := h.batchRepo.FindByAssistant(r.Context(), user.ID.Hex()); err == nil && len(assisted) > 0 {
+			batchIDs := make([]string, len(assisted))
+			for i, b := range assisted {
+				batchIDs[i] = b.ID.Hex()
+			}
+			var more []models.ScheduledClass
+			more, err = h.scheduleRepo.FindByBatches(r.Context(), batchIDs, fromDate, toDate)
+			schedules = append(schedules, more...)
+			sort.Slice(schedules, func(i, j int) bool {
+				return schedules[i].StartTime.Before(schedules[j].StartTime)
+			})
+		}
+
 	case models.RoleStudent:
-		// Get batches the student is in
+		// Get batches the student is in or assists
 		batches, _ := h.batchRepo.FindByStudent(r.Context(), user.ID.Hex())
-		batchIDs := make([]string, len(batches))
-		for i, b := range batches {
-			batchIDs[i] = b.ID.Hex()
+		assisted, _ := h.batchRepo.FindByAssistant(r.Context(), user.ID.Hex())
+		seen := make(map[string]bool, len(batches)+len(assisted))
+		batchIDs := make([]string, 0, len(batches)+len(assisted))
+		for _, list := range [][]models.Batch{batches, assisted} {
+			for _, b := range list {
+				if id := b.ID.Hex(); !seen[id] {
+					seen[id] = true
+					batchIDs = append(batchIDs, id)
+				}
+			}
 		}
 		schedules, err = h.scheduleRepo.FindByBatches(r.Context(), batchIDs, fromDate, toDate)
 	}
 
 	if err != nil {
-		sendJSONError(w, "Failed to fetch schedules", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to fetch schedules", err)
 		return
 	}
 
 	// Enrich response with batch and presenter names
+	now := time.Now()
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
 	response := make([]models.ScheduledClassResponse, len(schedules))
 	for i, s := range schedules {
-		resp := s.ToResponse()
-		if batch, err := h.batchRepo.FindByID(r.Context(), s.BatchID.Hex()); err == nil {
-			resp.BatchName = batch.Name
-		}
-		if presenter, err := h.userRepo.FindByID(r.Context(), s.PresenterID.Hex()); err == nil {
-			resp.PresenterName = presenter.Name
-		}
+		resp := s.ResponseFor(user.Role, now)
+		resp.BatchName = names.Batch(s.BatchID, s.BatchName)
+		resp.PresenterName = names.User(s.PresenterID, s.PresenterName)
 		response[i] = resp
 	}
+	h.maintenance.Annotate(r.Context(), response)
 
-	sendJSON(w, response, http.StatusOK)
+	sendJSONFields(w, r, response, http.StatusOK)
 }
 
 // CreateSchedule creates a new scheduled class.
@@ -114,10 +201,8 @@ func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -127,35 +212,21 @@ func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		BatchID     string `json:"batchId"`
-		StartTime   string `json:"startTime"` // ISO 8601 format
-		EndTime     string `json:"endTime"`   // ISO 8601 format
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
-		return
+		Title       string `json:"title" validate:"required,max=200"`
+		Description string `json:"description" validate:"max=2000"`
+		BatchID     string `json:"batchId" validate:"required,objectid"`
+		StartTime   string `json:"startTime" validate:"required,rfc3339"`
+		EndTime     string `json:"endTime" validate:"required,rfc3339"`
+		Proctored   bool   `json:"proctored"`
+		ExamMode    bool   `json:"examMode"`
+		LateEntry   int    `json:"lateEntryMinutes" validate:"min=0,max=1440"`
 	}
-
-	if req.Title == "" || req.BatchID == "" || req.StartTime == "" || req.EndTime == "" {
-		sendJSONError(w, "Title, batch ID, start time, and end time are required", http.StatusBadRequest)
-		return
-	}
-
-	startTime, err := time.Parse(time.RFC3339, req.StartTime)
-	if err != nil {
-		sendJSONError(w, "Invalid start time format", http.StatusBadRequest)
-		return
-	}
-
-	endTime, err := time.Parse(time.RFC3339, req.EndTime)
-	if err != nil {
-		sendJSONError(w, "Invalid end time format", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
+	startTime, _ := time.Parse(time.RFC3339, req.StartTime)
+	endTime, _ := time.Parse(time.RFC3339, req.EndTime)
 	if endTime.Before(startTime) {
 		sendJSONError(w, "End time must be after start time", http.StatusBadRequest)
 		return
@@ -174,28 +245,37 @@ func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Classes can't be scheduled into blocking maintenance; others get a warning
+	maintenance, blocked := h.maintenance.Overlapping(r.Context(), startTime, endTime)
+	if blocked {
+		sendMaintenanceConflict(w, maintenance)
+		return
+	}
+
 	batchObjID, _ := primitive.ObjectIDFromHex(req.BatchID)
 
 	schedule := &models.ScheduledClass{
 		Title:       req.Title,
-		Description: req.Description,
+		Description: richtext.Rich.Sanitize(req.Description),
 		BatchID:     batchObjID,
 		PresenterID: batch.PresenterID,
 		StartTime:   startTime,
 		EndTime:     endTime,
+		Proctored:   req.Proctored,
+		ExamMode:    req.ExamMode,
+		LateEntry:   req.LateEntry,
+
+		BatchName:     batch.Name,
+		PresenterName: newNameLookup(r.Context(), h.batchRepo, h.userRepo).User(batch.PresenterID, batch.PresenterName),
 	}
 
 	if err := h.scheduleRepo.Create(r.Context(), schedule); err != nil {
-		sendJSONError(w, "Failed to create schedule", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to create schedule", err)
 		return
 	}
 
 	resp := schedule.ToResponse()
-	resp.BatchName = batch.Name
-	if presenter, err := h.userRepo.FindByID(r.Context(), batch.PresenterID.Hex()); err == nil {
-		resp.PresenterName = presenter.Name
-	}
-
+	resp.Maintenance = maintenance
 	sendJSON(w, resp, http.StatusCreated)
 }
 
@@ -206,6 +286,11 @@ func (h *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
 	// Extract schedule ID from URL: /api/schedules/{id}
 	path := strings.TrimPrefix(r.URL.Path, "/api/schedules/")
 	scheduleID := strings.Split(path, "/")[0]
@@ -216,13 +301,11 @@ func (h *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := schedule.ToResponse()
-	if batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex()); err == nil {
-		resp.BatchName = batch.Name
-	}
-	if presenter, err := h.userRepo.FindByID(r.Context(), schedule.PresenterID.Hex()); err == nil {
-		resp.PresenterName = presenter.Name
-	}
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
+	resp := schedule.ResponseFor(user.Role, time.Now())
+	resp.BatchName = names.Batch(schedule.BatchID, schedule.BatchName)
+	resp.PresenterName = names.User(schedule.PresenterID, schedule.PresenterName)
+	resp.Maintenance, _ = h.maintenance.Overlapping(r.Context(), schedule.StartTime, schedule.EndTime)
 
 	sendJSON(w, resp, http.StatusOK)
 }
@@ -234,10 +317,8 @@ func (h *ScheduleHandler) StartClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -258,14 +339,40 @@ func (h *ScheduleHandler) StartClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate room ID
-	roomID := strings.ToUpper(primitive.NewObjectID().Hex()[:8])
+	held, err := h.lockClass(r.Context(), scheduleID)
+	if err != nil {
+		sendTransitionError(w, "Failed to start class", err)
+		return
+	}
+	defer held.Release(context.WithoutCancel(r.Context()))
+
+	// Read again under the lock, since another instance may have started it
+	schedule, err = h.scheduleRepo.Reload(r.Context(), scheduleID)
+	if err != nil {
+		sendStoreError(w, "Failed to start class", err)
+		return
+	}
+	if schedule.Status == models.ClassStatusLive && schedule.RoomID != "" {
+		sendJSON(w, map[string]string{
+			"message": "Class already started",
+			"roomId":  schedule.RoomID,
+		}, http.StatusOK)
+		return
+	}
+
+	// Go live in the class's lobby room if it has one, otherwise in a new room
+	roomID := schedule.LobbyRoomID
+	if roomID == "" {
+		roomID = strings.ToUpper(primitive.NewObjectID().Hex()[:8])
+	}
 
 	// Update schedule status
-	if err := h.scheduleRepo.UpdateStatus(r.Context(), scheduleID, models.ClassStatusLive, roomID); err != nil {
-		sendJSONError(w, "Failed to start class", http.StatusInternalServerError)
+	if err := h.scheduleRepo.UpdateStatusFenced(r.Context(), scheduleID, models.ClassStatusLive, roomID, held.Token()); err != nil {
+		sendTransitionError(w, "Failed to start class", err)
 		return
 	}
+	h.lobbies.GoLive(roomID)
+	go h.notifyClassStarting(schedule)
 
 	sendJSON(w, map[string]string{
 		"message": "Class started",
@@ -273,6 +380,24 @@ func (h *ScheduleHandler) StartClass(w http.ResponseWriter, r *http.Request) {
 	}, http.StatusOK)
 }
 
+// sendMaintenanceConflict refuses a class overlapping blocking maintenance,
+// listing the windows it overlaps.
+func sendMaintenanceConflict(w http.ResponseWriter, maintenance []models.MaintenanceNotice) {
+	sendJSON(w, map[string]interface{}{
+		"error":       "This class overlaps scheduled maintenance. Pick another time",
+		"maintenance": maintenance,
+	}, http.StatusConflict)
+}
+
+// notifyClassStarting tells the students of a class's batch that it has
+// started. It is urgent, so it comes through quiet hours by default.
+func (h *ScheduleHandler) notifyClassStarting(schedule *models.ScheduledClass) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	h.classEmails.ClassStarted(ctx, schedule)
+}
+
 // EndClass ends a live class.
 func (h *ScheduleHandler) EndClass(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -280,10 +405,8 @@ func (h *ScheduleHandler) EndClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -304,14 +427,179 @@ func (h *ScheduleHandler) EndClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.scheduleRepo.UpdateStatus(r.Context(), scheduleID, models.ClassStatusCompleted, schedule.RoomID); err != nil {
-		sendJSONError(w, "Failed to end class", http.StatusInternalServerError)
+	if err := h.completeClass(r.Context(), schedule); err != nil {
+		sendTransitionError(w, "Failed to end class", err)
 		return
 	}
 
 	sendJSON(w, map[string]string{"message": "Class ended"}, http.StatusOK)
 }
 
+// completeClass marks a class as completed, then builds its archive, drafts
+// a quiz from its transcript, saves its server-side recordings and publishes
+// the notes held back until after it in the background. The room's
+// activity log is captured first, so the room may be closed right after.
+// A class that another instance completed meanwhile is left alone.
+func (h *ScheduleHandler) completeClass(ctx context.Context, schedule *models.ScheduledClass) error {
+	held, err := h.lockClass(ctx, schedule.ID.Hex())
+	if err != nil {
+		return err
+	}
+	defer held.Release(context.WithoutCancel(ctx))
+
+	schedule, err = h.scheduleRepo.Reload(ctx, schedule.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if schedule.Status == models.ClassStatusCompleted {
+		return nil
+	}
+	if err := h.scheduleRepo.UpdateStatusFenced(ctx, schedule.ID.Hex(), models.ClassStatusCompleted, schedule.RoomID, held.Token()); err != nil {
+		return err
+	}
+
+	var entries []room.TranscriptEntry
+	var dropped int
+	if schedule.RoomID != "" {
+		if liveRoom, ok := h.hub.GetRoom(schedule.RoomID); ok {
+			entries, dropped = liveRoom.Transcript.Entries()
+			go h.egress.End(liveRoom.ID, liveRoom.SessionID)
+		}
+	}
+
+	go h.recorder.End(schedule.ID.Hex())
+	go h.archiveClass(schedule, entries, dropped)
+	go h.quizDrafter.DraftForClass(schedule, entries)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		h.notePublisher.PublishForClass(ctx, schedule.ID)
+	}()
+	return nil
+}
+
+// lockClass takes the lock on a class's status, waiting a little for a
+// transition running elsewhere to finish.
+func (h *ScheduleHandler) lockClass(ctx context.Context, scheduleID string) (*lock.Lock, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, classLockWait)
+	defer cancel()
+	return h.locks.Acquire(waitCtx, "schedule:"+scheduleID, classLockTTL)
+}
+
+// sendTransitionError reports a failed class status transition: 409 if it
+// lost out to a transition running elsewhere, otherwise as a store error.
+func sendTransitionError(w http.ResponseWriter, message string, err error) {
+	if errors.Is(err, lock.ErrHeld) || errors.Is(err, repository.ErrScheduleFenced) {
+		sendJSONError(w, "The class is being started or ended elsewhere. Try again", http.StatusConflict)
+		return
+	}
+	sendStoreError(w, message, err)
+}
+
+// archiveClass writes the archive bundle for a completed class and links it to the schedule.
+func (h *ScheduleHandler) archiveClass(schedule *models.ScheduledClass, entries []room.TranscriptEntry, dropped int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Never overwrite an archive that is under legal hold
+	if schedule.ArchivePath != "" {
+		if err := h.legalHolds.Guard(ctx, models.HoldContentArchive, schedule.ID.Hex(), models.LegalHoldEditBlocked, nil); err != nil {
+			log.Printf("[Schedule] Not re-archiving class %s: %v", schedule.ID.Hex(), err)
+			return
+		}
+	}
+
+	bundle := archive.New(schedule.ID.Hex(), schedule.Title, schedule.StartTime, time.Now(), entries, dropped)
+
+	names := newNameLookup(ctx, h.batchRepo, h.userRepo)
+	bundle.BatchName = names.Batch(schedule.BatchID, schedule.BatchName)
+	bundle.PresenterName = names.User(schedule.PresenterID, schedule.PresenterName)
+	for _, material := range schedule.Materials {
+		bundle.Materials = append(bundle.Materials, archive.Material{
+			Title:       material.Title,
+			DownloadURL: material.URL,
+		})
+	}
+	if notes, err := h.noteRepo.FindByBatch(ctx, schedule.BatchID); err == nil {
+		for _, note := range notes {
+			// Leave out materials still held back, except those this class
+			// releases, and those for some groups only
+			if note.Unpublished && (note.PublishScheduleID == nil || *note.PublishScheduleID != schedule.ID) {
+				continue
+			}
+			if len(note.GroupIDs) > 0 {
+				continue
+			}
+			bundle.Materials = append(bundle.Materials, archive.Material{
+				Title:       note.Title,
+				FileName:    note.FileName,
+				DownloadURL: "/api/notes/" + note.ID.Hex() + "/download",
+			})
+		}
+	}
+
+	basePath, err := archive.Write(filepath.Join(h.storagePath, "archives"), bundle)
+	if err != nil {
+		log.Printf("[Schedule] Failed to write archive for class %s: %v", schedule.ID.Hex(), err)
+		return
+	}
+
+	if err := h.scheduleRepo.SetArchivePath(ctx, schedule.ID.Hex(), basePath); err != nil {
+		log.Printf("[Schedule] Failed to link archive for class %s: %v", schedule.ID.Hex(), err)
+		return
+	}
+
+	log.Printf("[Schedule] Archived class %s (%d chat, %d raised hands, %d materials)",
+		schedule.Title, len(bundle.Chat), len(bundle.RaisedHands), len(bundle.Materials))
+}
+
+// GetArchive serves a completed class archive (GET /api/schedules/{id}/archive?format=html|json).
+func (h *ScheduleHandler) GetArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/archive
+	path := strings.TrimPrefix(r.URL.Path, "/api/schedules/")
+	scheduleID := strings.Split(path, "/")[0]
+
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	// Admin, the class presenter, or a student of the batch
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID {
+		batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+		if err != nil || !batch.HasStudent(user.ID.Hex()) {
+			sendJSONError(w, "Access denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	if schedule.ArchivePath == "" {
+		sendJSONError(w, "Archive not available", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, schedule.ArchivePath+".json")
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		http.ServeFile(w, r, schedule.ArchivePath+".html")
+	}
+}
+
 // JoinClass allows a student to join a scheduled class.
 func (h *ScheduleHandler) JoinClass(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -319,10 +607,8 @@ func (h *ScheduleHandler) JoinClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -337,9 +623,18 @@ func (h *ScheduleHandler) JoinClass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if class is live
-	if schedule.Status != models.ClassStatusLive {
-		sendJSONError(w, "Class is not live yet", http.StatusBadRequest)
+	// Check if class is live (a live class past its end time counts as completed)
+	now := time.Now()
+	if status := schedule.EffectiveStatusAt(now); status != models.ClassStatusLive {
+		message := "Class is not live yet"
+		if status == models.ClassStatusCompleted || status == models.ClassStatusCancelled {
+			message = "Class has ended"
+		}
+		sendJSON(w, map[string]interface{}{
+			"error":           message,
+			"serverTime":      now,
+			"startsInSeconds": schedule.SecondsUntilStart(now),
+		}, http.StatusBadRequest)
 		return
 	}
 
@@ -347,20 +642,47 @@ func (h *ScheduleHandler) JoinClass(w http.ResponseWriter, r *http.Request) {
 	if user.Role != models.RoleAdmin {
 		batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
 		if err != nil {
-			sendJSONError(w, "Batch not found", http.StatusInternalServerError)
+			sendStoreError(w, "Batch not found", err)
 			return
 		}
 
-		if user.Role == models.RoleStudent && !batch.HasStudent(user.ID.Hex()) {
+		if user.Role == models.RoleStudent && !batch.HasStudent(user.ID.Hex()) && !batch.HasAssistant(user.ID.Hex()) {
 			sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
 			return
 		}
 	}
 
+	// Proctored classes require students to verify their identity first
+	if schedule.Proctored && user.Role == models.RoleStudent {
+		v, err := h.verificationRepo.FindByScheduleAndStudent(r.Context(), schedule.ID, user.ID)
+		if err != nil || !v.IsVerified() {
+			sendJSON(w, map[string]interface{}{
+				"error":                "Identity verification required",
+				"verificationRequired": true,
+			}, http.StatusForbidden)
+			return
+		}
+	}
+
+	// Exams can't be entered after the late-entry window unless rejoining
+	if schedule.ExamMode && user.Role == models.RoleStudent && now.After(schedule.LateEntryDeadline()) {
+		joined, err := h.examAuditRepo.HasJoined(r.Context(), schedule.ID, user.ID)
+		if err != nil || !joined {
+			sendJSON(w, map[string]interface{}{
+				"error":           "The late entry window for this exam has closed",
+				"lateEntryClosed": true,
+			}, http.StatusForbidden)
+			return
+		}
+	}
+
 	sendJSON(w, map[string]interface{}{
-		"message":     "Join approved",
-		"roomId":      schedule.RoomID,
-		"isPresenter": user.Role == models.RolePresenter && schedule.PresenterID.Hex() == user.ID.Hex(),
+		"message":       "Join approved",
+		"roomId":        schedule.RoomID,
+		"isPresenter":   user.Role == models.RolePresenter && schedule.PresenterID.Hex() == user.ID.Hex(),
+		"examMode":      schedule.ExamMode,
+		"serverTime":    now,
+		"endsInSeconds": schedule.SecondsUntilEnd(now),
 	}, http.StatusOK)
 }
 
@@ -371,10 +693,8 @@ func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -394,8 +714,14 @@ func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The class chat archive is keyed by schedule
+	if err := h.legalHolds.Guard(r.Context(), models.HoldContentArchive, scheduleID, models.LegalHoldDeleteBlocked, user); err != nil {
+		sendJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
+
 	if err := h.scheduleRepo.Delete(r.Context(), scheduleID); err != nil {
-		sendJSONError(w, "Failed to delete schedule", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to delete schedule", err)
 		return
 	}
 
@@ -409,10 +735,8 @@ func (h *ScheduleHandler) CancelSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -433,6 +757,20 @@ func (h *ScheduleHandler) CancelSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	held, err := h.lockClass(r.Context(), scheduleID)
+	if err != nil {
+		sendTransitionError(w, "Failed to cancel class", err)
+		return
+	}
+	defer held.Release(context.WithoutCancel(r.Context()))
+
+	// Read again under the lock, since another instance may have changed it
+	schedule, err = h.scheduleRepo.Reload(r.Context(), scheduleID)
+	if err != nil {
+		sendStoreError(w, "Failed to cancel class", err)
+		return
+	}
+
 	// Can't cancel completed classes
 	if schedule.Status == models.ClassStatusCompleted {
 		sendJSONError(w, "Cannot cancel a completed class", http.StatusBadRequest)
@@ -445,8 +783,8 @@ func (h *ScheduleHandler) CancelSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.scheduleRepo.UpdateStatus(r.Context(), scheduleID, models.ClassStatusCancelled, schedule.RoomID); err != nil {
-		sendJSONError(w, "Failed to cancel class", http.StatusInternalServerError)
+	if err := h.scheduleRepo.UpdateStatusFenced(r.Context(), scheduleID, models.ClassStatusCancelled, schedule.RoomID, held.Token()); err != nil {
+		sendTransitionError(w, "Failed to cancel class", err)
 		return
 	}
 
@@ -460,10 +798,8 @@ func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token := extractToken(r)
-	user, err := h.authService.GetUserFromToken(r.Context(), token)
-	if err != nil {
-		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
+	user, ok := requestUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -496,14 +832,15 @@ func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-		StartTime   string `json:"startTime"`
-		EndTime     string `json:"endTime"`
+		Title       string `json:"title" validate:"max=200"`
+		Description string `json:"description" validate:"max=2000"`
+		StartTime   string `json:"startTime" validate:"rfc3339"`
+		EndTime     string `json:"endTime" validate:"rfc3339"`
+		Proctored   *bool  `json:"proctored"`
+		ExamMode    *bool  `json:"examMode"`
+		LateEntry   *int   `json:"lateEntryMinutes" validate:"min=0,max=1440"`
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -512,23 +849,22 @@ func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request)
 		schedule.Title = req.Title
 	}
 	if req.Description != "" {
-		schedule.Description = req.Description
+		schedule.Description = richtext.Rich.Sanitize(req.Description)
 	}
 	if req.StartTime != "" {
-		startTime, err := time.Parse(time.RFC3339, req.StartTime)
-		if err != nil {
-			sendJSONError(w, "Invalid start time format", http.StatusBadRequest)
-			return
-		}
-		schedule.StartTime = startTime
+		schedule.StartTime, _ = time.Parse(time.RFC3339, req.StartTime)
 	}
 	if req.EndTime != "" {
-		endTime, err := time.Parse(time.RFC3339, req.EndTime)
-		if err != nil {
-			sendJSONError(w, "Invalid end time format", http.StatusBadRequest)
-			return
-		}
-		schedule.EndTime = endTime
+		schedule.EndTime, _ = time.Parse(time.RFC3339, req.EndTime)
+	}
+	if req.Proctored != nil {
+		schedule.Proctored = *req.Proctored
+	}
+	if req.ExamMode != nil {
+		schedule.ExamMode = *req.ExamMode
+	}
+	if req.LateEntry != nil {
+		schedule.LateEntry = *req.LateEntry
 	}
 
 	// Validate times
@@ -537,18 +873,23 @@ func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Moving a class into blocking maintenance is refused like scheduling it there
+	maintenance, blocked := h.maintenance.Overlapping(r.Context(), schedule.StartTime, schedule.EndTime)
+	if blocked && (req.StartTime != "" || req.EndTime != "") {
+		sendMaintenanceConflict(w, maintenance)
+		return
+	}
+
 	if err := h.scheduleRepo.Update(r.Context(), schedule); err != nil {
-		sendJSONError(w, "Failed to update schedule", http.StatusInternalServerError)
+		sendStoreError(w, "Failed to update schedule", err)
 		return
 	}
 
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
 	resp := schedule.ToResponse()
-	if batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex()); err == nil {
-		resp.BatchName = batch.Name
-	}
-	if presenter, err := h.userRepo.FindByID(r.Context(), schedule.PresenterID.Hex()); err == nil {
-		resp.PresenterName = presenter.Name
-	}
+	resp.BatchName = names.Batch(schedule.BatchID, schedule.BatchName)
+	resp.PresenterName = names.User(schedule.PresenterID, schedule.PresenterName)
+	resp.Maintenance = maintenance
 
 	sendJSON(w, resp, http.StatusOK)
 }
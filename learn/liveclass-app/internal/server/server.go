@@ -2,45 +2,161 @@ package server
 
 import (
 	"context"
+	"crypto/hmac"
 	"embed"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/jinshatcp/brightline-academy/learn/internal/accounts"
+	"github.com/jinshatcp/brightline-academy/learn/internal/alerts"
+	"github.com/jinshatcp/brightline-academy/learn/internal/analytics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/apiusage"
+	"github.com/jinshatcp/brightline-academy/learn/internal/attendance"
 	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/captions"
+	"github.com/jinshatcp/brightline-academy/learn/internal/catchup"
+	"github.com/jinshatcp/brightline-academy/learn/internal/cdn"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chapters"
+	"github.com/jinshatcp/brightline-academy/learn/internal/chatlog"
+	"github.com/jinshatcp/brightline-academy/learn/internal/cluster"
+	"github.com/jinshatcp/brightline-academy/learn/internal/cohorts"
+	"github.com/jinshatcp/brightline-academy/learn/internal/coldstorage"
+	"github.com/jinshatcp/brightline-academy/learn/internal/composite"
 	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+	"github.com/jinshatcp/brightline-academy/learn/internal/convert"
 	"github.com/jinshatcp/brightline-academy/learn/internal/database"
+	"github.com/jinshatcp/brightline-academy/learn/internal/egress"
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/geoip"
+	"github.com/jinshatcp/brightline-academy/learn/internal/httpcache"
+	"github.com/jinshatcp/brightline-academy/learn/internal/ice"
+	"github.com/jinshatcp/brightline-academy/learn/internal/imaging"
+	"github.com/jinshatcp/brightline-academy/learn/internal/lock"
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
 	"github.com/jinshatcp/brightline-academy/learn/internal/middleware"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/names"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notes"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/pressure"
 	"github.com/jinshatcp/brightline-academy/learn/internal/pubsub"
+	"github.com/jinshatcp/brightline-academy/learn/internal/querydiag"
+	"github.com/jinshatcp/brightline-academy/learn/internal/quizgen"
 	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
 	"github.com/jinshatcp/brightline-academy/learn/internal/room"
 	"github.com/jinshatcp/brightline-academy/learn/internal/rtc"
+	"github.com/jinshatcp/brightline-academy/learn/internal/scheduling"
+	"github.com/jinshatcp/brightline-academy/learn/internal/sse"
+	"github.com/jinshatcp/brightline-academy/learn/internal/storage"
+	"github.com/jinshatcp/brightline-academy/learn/internal/timeline"
+	"github.com/jinshatcp/brightline-academy/learn/internal/variants"
+	"github.com/jinshatcp/brightline-academy/learn/internal/verification"
+	"github.com/jinshatcp/brightline-academy/learn/internal/webhooks"
 )
 
 // Server represents the LiveClass HTTP server.
 type Server struct {
-	config           *config.Config
-	hub              *room.Hub
-	rtcService       *rtc.Service
-	staticFS         fs.FS
-	db               *database.MongoDB
-	pubsub           *pubsub.RedisPubSub
-	userRepo         *repository.UserRepository
-	batchRepo        *repository.BatchRepository
-	scheduleRepo     *repository.ScheduleRepository
-	recordingRepo    *repository.RecordingRepository
-	noteRepo         *repository.NoteRepository
-	authService      *auth.Service
-	authHandler      *AuthHandler
-	adminHandler     *AdminHandler
-	batchHandler     *BatchHandler
-	scheduleHandler  *ScheduleHandler
-	recordingHandler *RecordingHandler
-	noteHandler      *NoteHandler
-	httpServer       *http.Server
+	config              *config.Config
+	hub                 *room.Hub
+	rtcService          *rtc.Service
+	staticFS            fs.FS
+	db                  *database.MongoDB
+	pubsub              *pubsub.RedisPubSub
+	userRepo            *repository.UserRepository
+	batchRepo           *repository.BatchRepository
+	scheduleRepo        *repository.ScheduleRepository
+	recordingRepo       *repository.RecordingRepository
+	noteRepo            *repository.NoteRepository
+	dmRepo              *repository.DirectMessageRepository
+	verificationRepo    *repository.VerificationRepository
+	examAuditRepo       *repository.ExamAuditRepository
+	notificationRepo    *repository.NotificationRepository
+	storageUsageRepo    *repository.StorageUsageRepository
+	reportRepo          *repository.ReportRepository
+	authService         *auth.Service
+	auth                *middleware.Auth
+	authHandler         *AuthHandler
+	adminHandler        *AdminHandler
+	batchHandler        *BatchHandler
+	scheduleHandler     *ScheduleHandler
+	recordingHandler    *RecordingHandler
+	noteHandler         *NoteHandler
+	handoutHandler      *HandoutHandler
+	handInHandler       *HandInHandler
+	peerReviewHandler   *PeerReviewHandler
+	quizDraftHandler    *QuizDraftHandler
+	exportHandler       *ExportHandler
+	templateHandler     *TemplateHandler
+	goalHandler         *GoalHandler
+	catchUpHandler      *CatchUpHandler
+	eventsHandler       *EventsHandler
+	watchHandler        *WatchHandler
+	consentHandler      *ConsentHandler
+	dmHandler           *DirectMessageHandler
+	roomHandler         *RoomHandler
+	controlHandler      *ControlHandler
+	restreamHandler     *RestreamHandler
+	recordHandler       *LiveRecordingHandler
+	coWatchHandler      *CoWatchHandler
+	chatHandler         *ChatHistoryHandler
+	attendanceHandler   *AttendanceHandler
+	verificationHandler *VerificationHandler
+	webhookHandler      *WebhookHandler
+	apiUsageHandler     *APIUsageHandler
+	clientEventHandler  *ClientEventHandler
+	apiUsage            *apiusage.Tracker
+	billingHandler      *BillingHandler
+	examHandler         *ExamHandler
+	lobbyHandler        *LobbyHandler
+	assistantHandler    *AssistantHandler
+	groupHandler        *GroupHandler
+	suggestionHandler   *SuggestionHandler
+	notificationHandler *NotificationHandler
+	moderationHandler   *ModerationHandler
+	legalHoldHandler    *LegalHoldHandler
+	maintenanceHandler  *MaintenanceHandler
+	brandingHandler     *BrandingHandler
+	diagnosticsHandler  *DiagnosticsHandler
+	queryAnalyzer       *querydiag.Analyzer
+	captionService      *captions.Service
+	iceHandler          *ICEHandler
+	analytics           *analytics.Exporter
+	coldStorage         *coldstorage.Lifecycle
+	notifier            *notify.Notifier
+	notifyReleaser      *notify.Releaser
+	classEmails         *notify.ClassEmails
+	storageMonitor      *storage.Monitor
+	nameReconciler      *names.Reconciler
+	notePublisher       *notes.Publisher
+	catchUpSender       *catchup.Sender
+	anonymizer          *accounts.Anonymizer
+	cohortRoller        *cohorts.Roller
+	imageOptimizer      *imaging.Optimizer
+	pdfWorker           *convert.Worker
+	chapterGenerator    *chapters.Generator
+	composites          *composite.Processor
+	variants            *variants.Generator
+	roomEvents          *timeline.Recorder
+	chatLog             *chatlog.Recorder
+	attendance          *attendance.Tracker
+	egress              *egress.Manager
+	liveRecorder        *rtc.Recorder
+	clipPlayer          *rtc.ClipPlayer
+	alerts              *alerts.Engine
+	pressureMonitor     *pressure.Monitor
+	clusterRegistry     *cluster.Registry
+	clusterHandler      *ClusterHandler
+	responseCache       *httpcache.Cache
+	stopJobs            context.CancelFunc
+	accessLog           io.Closer // nil when access logging is off
+	httpServer          *http.Server
 }
 
 // New creates a new Server instance.
@@ -50,6 +166,9 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 		return nil, fmt.Errorf("failed to create static file system: %w", err)
 	}
 
+	// Slow query logging, attached to the connection
+	queryProfiler := querydiag.NewProfiler(cfg.SlowQueryThreshold)
+
 	// Connect to MongoDB with optimized settings
 	log.Println("📦 Connecting to MongoDB...")
 	dbConfig := &database.ConnectionConfig{
@@ -62,6 +181,11 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 		ServerSelectionTimeout: 5 * time.Second,
 		SocketTimeout:          cfg.MongoSocketTimeout,
 		MaxConnecting:          10,
+		ReportReadPreference:   cfg.MongoReportReadPreference,
+		ReportMaxStaleness:     cfg.MongoReportMaxStaleness,
+		ReadTimeout:            cfg.MongoReadTimeout,
+		WriteTimeout:           cfg.MongoWriteTimeout,
+		Monitor:                queryProfiler.Monitor(),
 	}
 
 	db, err := database.NewMongoDBWithConfig(dbConfig)
@@ -83,12 +207,41 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 		log.Println("📝 Running in single-instance mode (Redis disabled)")
 	}
 
+	// Locks for critical sections that must run on one instance at a time
+	locks := lock.NewLocker(ps)
+
 	// Create repositories with caching
 	userRepo := repository.NewUserRepositoryWithCache(db, cfg.UserCacheTTL)
 	batchRepo := repository.NewBatchRepositoryWithCache(db, cfg.BatchCacheTTL)
 	scheduleRepo := repository.NewScheduleRepositoryWithCache(db, cfg.ScheduleCacheTTL)
 	recordingRepo := repository.NewRecordingRepository(db)
-	noteRepo := repository.NewNoteRepository(db.Database)
+	noteRepo := repository.NewNoteRepository(db)
+	dmRepo := repository.NewDirectMessageRepository(db)
+	verificationRepo := repository.NewVerificationRepository(db)
+	examAuditRepo := repository.NewExamAuditRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	storageUsageRepo := repository.NewStorageUsageRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	legalHoldRepo := repository.NewLegalHoldRepository(db)
+	maintenanceRepo := repository.NewMaintenanceRepository(db)
+	templateRepo := repository.NewClassTemplateRepository(db)
+	goalRepo := repository.NewGoalRepository(db)
+	brandingRepo := repository.NewBrandingRepository(db)
+	consentRepo := repository.NewRecordingConsentRepository(db)
+	roomEventRepo := repository.NewRoomEventRepository(db)
+	watchRepo := repository.NewWatchProgressRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	webhookEventRepo := repository.NewWebhookEventRepository(db)
+	apiUsageRepo := repository.NewAPIUsageRepository(db)
+	clientEventRepo := repository.NewClientEventRepository(db)
+	billingRepo := repository.NewBillingRepository(db)
+	rollupRepo := repository.NewClassRollupRepository(db)
+	handInRepo := repository.NewHandInRepository(db)
+	peerReviewRepo := repository.NewPeerReviewRepository(db)
+	catchUpRepo := repository.NewCatchUpRepository(db)
+	quizDraftRepo := repository.NewQuizDraftRepository(db)
+	chatRepo := repository.NewChatMessageRepository(db)
+	attendanceRepo := repository.NewAttendanceRepository(db)
 
 	// Create indexes in background with own context
 	go func() {
@@ -110,6 +263,84 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 		if err := noteRepo.CreateIndexes(indexCtx); err != nil {
 			log.Printf("⚠️ Warning: Failed to create note indexes: %v", err)
 		}
+		if err := dmRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create direct message indexes: %v", err)
+		}
+		if err := verificationRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create verification indexes: %v", err)
+		}
+		if err := examAuditRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create exam audit indexes: %v", err)
+		}
+		if err := notificationRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create notification indexes: %v", err)
+		}
+		if err := storageUsageRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create storage usage indexes: %v", err)
+		}
+		if err := reportRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create content report indexes: %v", err)
+		}
+		if err := legalHoldRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create legal hold indexes: %v", err)
+		}
+		if err := maintenanceRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create maintenance window indexes: %v", err)
+		}
+		if err := templateRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create class template indexes: %v", err)
+		}
+		if err := goalRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create goal indexes: %v", err)
+		}
+		if err := consentRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create recording consent indexes: %v", err)
+		}
+		if err := roomEventRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create room event indexes: %v", err)
+		}
+		if err := sessionRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create session indexes: %v", err)
+		}
+		if err := watchRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create watch progress indexes: %v", err)
+		}
+		if err := webhookEventRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create webhook event indexes: %v", err)
+		}
+		if err := apiUsageRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create API usage indexes: %v", err)
+		}
+		if err := clientEventRepo.CreateCollection(indexCtx, int64(cfg.ClientEventsMaxMB)<<20); err != nil {
+			log.Printf("⚠️ Warning: Failed to create client events collection: %v", err)
+		}
+		if err := clientEventRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create client event indexes: %v", err)
+		}
+		if err := billingRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create subscription indexes: %v", err)
+		}
+		if err := rollupRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create class rollup indexes: %v", err)
+		}
+		if err := handInRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create hand-in indexes: %v", err)
+		}
+		if err := peerReviewRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create peer review indexes: %v", err)
+		}
+		if err := catchUpRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create catch-up summary indexes: %v", err)
+		}
+		if err := quizDraftRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create quiz draft indexes: %v", err)
+		}
+		if err := chatRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create chat message indexes: %v", err)
+		}
+		if err := attendanceRepo.CreateIndexes(indexCtx); err != nil {
+			log.Printf("⚠️ Warning: Failed to create attendance indexes: %v", err)
+		}
 		log.Println("✅ Database indexes created")
 	}()
 
@@ -119,6 +350,12 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 
 	// Create auth service
 	authService := auth.NewService(userRepo, cfg.JWTSecret, cfg.JWTExpiryHours)
+	authService.SetSessions(sessionRepo, map[models.UserRole]int{
+		models.RoleStudent:   cfg.SessionLimitStudent,
+		models.RolePresenter: cfg.SessionLimitPresenter,
+		models.RoleAdmin:     cfg.SessionLimitAdmin,
+	})
+	authService.SetAccessTokenTTL(cfg.AccessTokenTTL)
 
 	// Create default admin
 	if err := authService.CreateDefaultAdmin(ctx, cfg.AdminEmail, cfg.AdminPassword, cfg.AdminName); err != nil {
@@ -127,13 +364,360 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 		log.Printf("👤 Default admin ready: %s", cfg.AdminEmail)
 	}
 
+	// Allowed markup in descriptions and messages
+	richtext.Configure(cfg.SanitizeRichTags, cfg.SanitizeChatTags, cfg.SanitizeURLSchemes)
+
+	// Re-streams of live classes to RTMP/SRT targets, when ffmpeg is installed
+	egressManager, err := egress.NewManager(cfg.RestreamFFmpegPath, cfg.RestreamVideoKbps, cfg.RestreamAllowedHosts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESTREAM_ALLOWED_HOSTS: %w", err)
+	}
+	if cfg.RestreamFFmpegPath != "" && !egressManager.Available() {
+		log.Printf("⚠️ Warning: %s not found, live classes can't be re-streamed", cfg.RestreamFFmpegPath)
+	}
+
+	// Server-side recordings of live classes
+	liveRecorder := rtc.NewRecorder(filepath.Join(cfg.StoragePath, recordingsDir))
+
+	// Create hub, recording the lifecycle of every room; a room's re-stream
+	// and recording stop when the room ends
+	hub := room.NewHub()
+	roomEvents := timeline.NewRecorder(roomEventRepo, cfg.InstanceID)
+	hub.SetLifecycleSink(roomEvents.Record)
+	hub.Hooks().OnClassEnded(func(ev room.LifecycleEvent) {
+		go egressManager.End(ev.RoomID, ev.SessionID)
+		go liveRecorder.StopRoom(ev.RoomID, ev.SessionID)
+	})
+
+	// Chat history of live classes
+	chatLog := chatlog.NewRecorder(chatRepo, scheduleRepo)
+	hub.Hooks().OnChatMessage(chatLog.Posted)
+
+	// Attendance of live classes
+	attendanceTracker := attendance.NewTracker(attendanceRepo, scheduleRepo)
+	attendanceTracker.Register(hub.Hooks())
+
+	// Event streams for clients without a WebSocket get what's sent to users
+	eventBroker := sse.NewBroker(cfg.SSEBacklog, cfg.SSEResumeWindow)
+	hub.SetUserStreams(eventBroker)
+	eventsHandler := NewEventsHandler(authService, eventBroker)
+
+	// Usage analytics export, optional
+	var exporter *analytics.Exporter
+	if sink := newAnalyticsSink(cfg); sink != nil {
+		exporter = analytics.NewExporter(sink, cfg.InstanceID, analytics.Options{
+			BatchSize:     cfg.AnalyticsBatchSize,
+			FlushInterval: cfg.AnalyticsFlushInterval,
+			QueueSize:     cfg.AnalyticsQueueSize,
+			MaxRetries:    cfg.AnalyticsMaxRetries,
+		})
+		log.Printf("📊 Analytics export enabled (%s)", sink.Name())
+	}
+
+	// Portal branding, also applied to emails
+	brandingHandler := NewBrandingHandler(authService, brandingRepo, models.Branding{
+		Org:         cfg.BrandingOrg,
+		PortalName:  cfg.BrandingPortalName,
+		EmailFooter: cfg.BrandingEmailFooter,
+	}, cfg.StoragePath)
+
+	// Notifications (in-app + email)
+	var mailer notify.Mailer = notify.LogMailer{}
+	if cfg.SMTPHost != "" {
+		mailer = notify.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	notifier := notify.NewNotifier(notificationRepo, userRepo, hub, mailer, brandingHandler, cfg.NotifyUrgentCategories)
+	notifyReleaser := notify.NewReleaser(notifier, cfg.NotifyReleaseInterval)
+	classEmails := notify.NewClassEmails(notifier, scheduleRepo, recordingRepo, batchRepo, cfg.NotifyClassEmails, cfg.NotifyClassReminderLead, cfg.NotifyClassInterval)
+
+	// Cold storage for old recordings, optional
+	var coldStorage *coldstorage.Lifecycle
+	if tier := newColdStorageTier(cfg); tier != nil {
+		coldStorage = coldstorage.NewLifecycle(recordingRepo, userRepo, notifier, locks, tier, cfg.RecordingArchiveAfter, cfg.ColdStorageInterval)
+		log.Printf("🧊 Recordings older than %v are archived to %s", cfg.RecordingArchiveAfter, tier.Name())
+	}
+
+	// Encryption at rest for recording and note files, optional
+	files, err := encryption.FromOptions(encryption.Options{
+		Provider:   cfg.StorageEncryption,
+		Keys:       cfg.StorageEncryptionKeys,
+		KeyID:      cfg.StorageEncryptionKeyID,
+		VaultAddr:  cfg.VaultAddr,
+		VaultKey:   cfg.VaultTransitKey,
+		VaultToken: cfg.VaultToken,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up storage encryption: %w", err)
+	}
+	if files != nil {
+		log.Printf("🔐 Recordings and notes are encrypted at rest (keys: %s)", files.Provider())
+	}
+
+	// Recording downloads through a CDN, optional
+	var recordingCDN *cdn.Signer
+	if cfg.CDNBaseURL != "" {
+		if cfg.CDNSigningKey == "" {
+			log.Printf("⚠️ Warning: CDN_SIGNING_KEY is not set, recordings are streamed by the server")
+		} else {
+			recordingCDN = cdn.New(cfg.CDNBaseURL, cfg.CDNSigningKey, cfg.CDNURLTTL)
+			log.Printf("🌐 Recordings are served from %s", cfg.CDNBaseURL)
+		}
+	}
+
+	// Client addresses and locations, for login sessions and TURN regions
+	locator := geoip.NewLocator(openGeoIPDB(cfg.GeoIPDBPath), cfg.GeoIPCountryHeader, cfg.GeoIPTrustForwarded)
+
+	// Webhook sources and routes; handlers register the actions they provide
+	webhookSecrets, err := webhooks.ParseSecrets(cfg.WebhookSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_SECRETS: %w", err)
+	}
+	webhookRoutes, err := webhooks.ParseRoutes(cfg.WebhookRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WEBHOOK_ROUTES: %w", err)
+	}
+	webhookDispatcher := webhooks.NewDispatcher(webhookSecrets, webhookRoutes, cfg.WebhookTolerance)
+
 	// Create handlers
-	authHandler := NewAuthHandler(authService)
-	adminHandler := NewAdminHandler(authService, userRepo)
-	batchHandler := NewBatchHandler(authService, batchRepo, userRepo)
-	scheduleHandler := NewScheduleHandler(authService, scheduleRepo, batchRepo, userRepo)
-	recordingHandler := NewRecordingHandler(authService, recordingRepo, scheduleRepo, batchRepo, userRepo, cfg.StoragePath)
-	noteHandler := NewNoteHandler(authService, noteRepo, batchRepo, userRepo, cfg.StoragePath)
+	sessionCloser := NewSessionCloser(authService, userRepo, hub, ps)
+	authHandler := NewAuthHandler(authService, userRepo, sessionCloser, notifier, locator, cfg.DeactivationGrace)
+	adminHandler := NewAdminHandler(authService, userRepo, storageUsageRepo, sessionCloser)
+	billingHandler := NewBillingHandler(batchRepo, billingRepo, webhookDispatcher, BillingOptions{
+		Enabled: cfg.BillingEnabled,
+		Org:     cfg.BrandingOrg,
+		Grace:   cfg.BillingGrace,
+	})
+	batchHandler := NewBatchHandler(batchRepo, userRepo, billingHandler)
+	var queryAnalyzer *querydiag.Analyzer
+	if queryProfiler != nil {
+		queryAnalyzer = querydiag.NewAnalyzer(queryProfiler, db.Database, cfg.QueryExplainInterval)
+	}
+	diagnosticsHandler := NewDiagnosticsHandler(queryProfiler, queryAnalyzer)
+	legalHoldHandler := NewLegalHoldHandler(authService, legalHoldRepo, noteRepo, recordingRepo, scheduleRepo)
+	notePublisher := notes.NewPublisher(noteRepo, batchRepo, userRepo, classEmails, cfg.NotePublishInterval)
+	lobbyHandler := NewLobbyHandler(authService, scheduleRepo, batchRepo, hub)
+	maintenanceHandler := NewMaintenanceHandler(authService, maintenanceRepo, scheduleRepo, userRepo, notifier)
+	// Quiz drafts from class transcripts, through an external generator if configured
+	var quizGenerator quizgen.Generator
+	if cfg.QuizGenURL != "" {
+		quizGenerator = quizgen.NewHTTPGenerator(cfg.QuizGenURL, cfg.QuizGenAPIKey, cfg.QuizGenTimeout)
+		log.Printf("📝 Quiz drafts enabled (%s)", quizGenerator.Name())
+	}
+	quizDrafter := quizgen.NewDrafter(quizGenerator, quizDraftRepo, cfg.QuizGenMaxQuestions, cfg.QuizGenTimeout)
+	quizDraftHandler := NewQuizDraftHandler(authService, scheduleRepo, batchRepo, quizDraftRepo, quizDrafter)
+	scheduleHandler := NewScheduleHandler(ScheduleHandlerDeps{
+		Schedules:     scheduleRepo,
+		Batches:       batchRepo,
+		Users:         userRepo,
+		Notes:         noteRepo,
+		Verifications: verificationRepo,
+		ExamAudits:    examAuditRepo,
+		Hub:           hub,
+		LegalHolds:    legalHoldHandler,
+		Lobbies:       lobbyHandler,
+		Maintenance:   maintenanceHandler,
+		NotePublisher: notePublisher,
+		QuizDrafter:   quizDrafter,
+		ClassEmails:   classEmails,
+		Locks:         locks,
+		Egress:        egressManager,
+		Recorder:      liveRecorder,
+	}, cfg.StoragePath)
+	goalHandler := NewGoalHandler(authService, goalRepo, scheduleRepo)
+	// Weekly catch-up summaries for students
+	catchUpBuilder := catchup.NewBuilder(batchRepo, scheduleRepo, recordingRepo, noteRepo, goalRepo)
+	catchUpSender := catchup.NewSender(catchUpBuilder, userRepo, catchUpRepo, notifier, cfg.CatchUpInterval)
+	anonymizer := accounts.NewAnonymizer(userRepo, sessionRepo, notificationRepo, attendanceRepo, cfg.DeactivationAnonymizeInterval)
+	catchUpHandler := NewCatchUpHandler(authService, userRepo, catchUpRepo, catchUpBuilder)
+	consentHandler := NewConsentHandler(scheduleRepo, consentRepo)
+	watchHandler := NewWatchHandler(authService, recordingRepo, batchRepo, userRepo, watchRepo, goalHandler, cfg.RecordingCompletionPercent)
+	// Chapter proposals from scene changes, when ffmpeg is installed
+	var sceneDetector *chapters.Detector
+	if cfg.ChaptersFFmpegPath != "" {
+		sceneDetector = chapters.NewDetector(cfg.ChaptersFFmpegPath, float64(cfg.ChaptersSceneThreshold)/100)
+		if !sceneDetector.Available() {
+			log.Printf("⚠️ Warning: %s not found, chapters won't be proposed for recordings", cfg.ChaptersFFmpegPath)
+			sceneDetector = nil
+		}
+	}
+	chapterGenerator := chapters.NewGenerator(recordingRepo, sceneDetector, files, chapters.Policy{
+		MinGap:      int(cfg.ChaptersMinGap.Seconds()),
+		MaxChapters: cfg.ChaptersMax,
+	}, cfg.ChaptersBackfillInterval)
+	// Camera recordings inset into the screen recordings they're uploaded with
+	var compositor *composite.Compositor
+	if cfg.CompositeFFmpegPath != "" {
+		compositor = composite.NewCompositor(cfg.CompositeFFmpegPath, composite.Layout{
+			Position:     cfg.CompositePosition,
+			WidthPercent: cfg.CompositeWidthPercent,
+			Margin:       cfg.CompositeMargin,
+		})
+		if !compositor.Available() {
+			log.Printf("⚠️ Warning: %s not found, camera recordings won't be composited", cfg.CompositeFFmpegPath)
+			compositor = nil
+		}
+	}
+	// 480p and audio-only copies of recordings for slow connections
+	var transcoder *variants.Transcoder
+	if cfg.VariantsFFmpegPath != "" {
+		transcoder = variants.NewTranscoder(cfg.VariantsFFmpegPath)
+		if !transcoder.Available() {
+			log.Printf("⚠️ Warning: %s not found, recording variants won't be made", cfg.VariantsFFmpegPath)
+			transcoder = nil
+		}
+	}
+	variantGenerator := variants.NewGenerator(recordingRepo, transcoder, files, cfg.VariantsBackfillInterval)
+	compositeProcessor := composite.NewProcessor(recordingRepo, compositor, files, chapterGenerator, variantGenerator, cfg.CompositeBackfillInterval)
+	playbackOptions := PlaybackTokenOptions{TTL: cfg.PlaybackTokenTTL, LoginTokenInQuery: cfg.StreamLoginTokenInQuery}
+	if cfg.PlaybackTokenPinClient {
+		playbackOptions.PinClient = locator
+	}
+	recordingHandler := NewRecordingHandler(RecordingHandlerDeps{
+		Auth:        authService,
+		Recordings:  recordingRepo,
+		Schedules:   scheduleRepo,
+		Batches:     batchRepo,
+		Users:       userRepo,
+		LegalHolds:  legalHoldHandler,
+		Billing:     billingHandler,
+		Watch:       watchHandler,
+		Consent:     consentHandler,
+		Analytics:   exporter,
+		Hub:         hub,
+		ColdStorage: coldStorage,
+		Chapters:    chapterGenerator,
+		Composites:  compositeProcessor,
+		Variants:    variantGenerator,
+		Files:       files,
+		CDN:         recordingCDN,
+	}, playbackOptions, cfg.StoragePath)
+	recordHandler := NewLiveRecordingHandler(authService, scheduleRepo, recordingRepo, batchRepo, userRepo, billingHandler, consentHandler, chapterGenerator, variantGenerator, files, hub, liveRecorder, cfg.ServerRecordingEnabled)
+	chatHandler := NewChatHistoryHandler(authService, scheduleRepo, batchRepo, chatRepo)
+	attendanceHandler := NewAttendanceHandler(authService, scheduleRepo, batchRepo, userRepo, attendanceRepo)
+	imageOptimizer := imaging.NewOptimizer(noteRepo, files, cfg.NoteImageWidths, cfg.NoteImageBackfillInterval)
+	// PDF copies of documents, through a conversion service or LibreOffice
+	var pdfConverter convert.Converter
+	switch {
+	case cfg.NotePDFConverterURL != "":
+		pdfConverter = convert.NewGotenberg(cfg.NotePDFConverterURL, 2*time.Minute)
+	case cfg.NotePDFSofficePath != "":
+		if soffice := convert.NewLibreOffice(cfg.NotePDFSofficePath); soffice.Available() {
+			pdfConverter = soffice
+		} else {
+			log.Printf("⚠️ Warning: %s not found, documents won't be converted to PDF", cfg.NotePDFSofficePath)
+		}
+	}
+	pdfWorker := convert.NewWorker(noteRepo, pdfConverter, files, cfg.NotePDFBackfillInterval)
+	noteHandler := NewNoteHandler(noteRepo, batchRepo, scheduleRepo, userRepo, legalHoldHandler, exporter, files, imageOptimizer, pdfWorker, cfg.StoragePath)
+	handoutHandler := NewHandoutHandler(authService, scheduleRepo, batchRepo, noteRepo, noteHandler, exporter, hub, cfg.HandoutLinkTTL)
+	handInHandler := NewHandInHandler(authService, scheduleRepo, batchRepo, handInRepo, hub, files, cfg.StoragePath, cfg.HandInMaxSize)
+	peerReviewHandler := NewPeerReviewHandler(authService, scheduleRepo, batchRepo, handInRepo, peerReviewRepo, handInHandler)
+	exportHandler := NewExportHandler(authService, batchRepo, scheduleRepo, userRepo, attendanceRepo, peerReviewRepo)
+	templateHandler := NewTemplateHandler(authService, templateRepo, scheduleRepo, batchRepo, userRepo, rollupRepo)
+	dmHandler := NewDirectMessageHandler(authService, dmRepo, batchRepo, userRepo, hub)
+	roomHandler := NewRoomHandler(authService, scheduleRepo, roomEventRepo, scheduleHandler, hub)
+	controlHandler := NewControlHandler(authService, scheduleRepo, batchRepo, consentHandler, chatLog, hub)
+	restreamHandler := NewRestreamHandler(authService, scheduleRepo, hub, egressManager)
+	examHandler := NewExamHandler(authService, scheduleRepo, examAuditRepo)
+	assistantHandler := NewAssistantHandler(authService, batchRepo, scheduleRepo, userRepo)
+	groupHandler := NewGroupHandler(authService, batchRepo, userRepo)
+	suggestionHandler := NewSuggestionHandler(authService, scheduleRepo, batchRepo, workingHours(cfg))
+	notificationHandler := NewNotificationHandler(authService, notificationRepo, userRepo, notifier)
+
+	moderationHandler := NewModerationHandler(authService, reportRepo, noteRepo, recordingRepo, batchRepo, userRepo, chatLog, hub, notifier, legalHoldHandler, sessionCloser, cfg.ReportHideThreshold)
+
+	// Response cache for hot read endpoints, invalidated on repository writes
+	responseCache := httpcache.New(ps)
+	batchRepo.OnWrite(func() {
+		// Schedule lists depend on batch membership and names
+		responseCache.Invalidate(cacheTagBatches)
+		responseCache.Invalidate(cacheTagSchedules)
+	})
+	scheduleRepo.OnWrite(func() {
+		responseCache.Invalidate(cacheTagSchedules)
+	})
+
+	// Keep name snapshots on batches, classes and recordings current
+	nameReconciler := names.NewReconciler(batchRepo, userRepo, scheduleRepo, recordingRepo, cfg.NameReconcileInterval)
+	batchRepo.OnWrite(nameReconciler.Trigger)
+	userRepo.OnWrite(nameReconciler.Trigger)
+
+	// Roll finished template classes up for cohort comparisons
+	cohortRoller := cohorts.NewRoller(scheduleRepo, batchRepo, goalRepo, roomEventRepo, recordingRepo, watchRepo, rollupRepo,
+		cfg.CohortRollupInterval, cfg.CohortRollupWindow)
+
+	storageMonitor := storage.NewMonitor(recordingRepo, noteRepo, batchRepo, userRepo, storageUsageRepo, notifier,
+		cfg.StoragePath, cfg.StorageAlertThresholds, cfg.StorageReportInterval)
+
+	// Under sustained CPU pressure, presenters lower their video quality and
+	// rooms pause optional features until the load subsides
+	degradedLimit := &room.QualityLimit{
+		MaxHeight:      cfg.DegradedMaxHeight,
+		MaxFramerate:   cfg.DegradedMaxFramerate,
+		MaxBitrateKbps: cfg.DegradedMaxBitrateKbps,
+		Reason:         "server-load",
+	}
+	pressureMonitor := pressure.NewMonitor(pressure.Config{
+		Interval: cfg.CPUPressureInterval,
+		High:     cfg.CPUPressureHigh,
+		Low:      cfg.CPUPressureLow,
+		Sustain:  cfg.CPUPressureSustain,
+	}, func(underPressure bool, utilization float64) {
+		if underPressure {
+			log.Printf("🔥 Degraded %d live rooms", hub.SetQualityLimit(degradedLimit))
+		} else {
+			log.Printf("✅ Restored %d live rooms", hub.SetQualityLimit(nil))
+		}
+	})
+
+	// Instance heartbeats and drain commands, for the cluster status API
+	clusterRegistry := cluster.NewRegistry(ps, cfg.InstanceID, cfg.Version, cfg.ClusterHeartbeatInterval, func() cluster.Load {
+		load := cluster.Load{
+			CPUUtilization: pressureMonitor.Utilization(),
+			UnderPressure:  pressureMonitor.UnderPressure(),
+		}
+		for _, r := range hub.Rooms() {
+			load.Rooms++
+			load.Participants += r.ParticipantCount()
+		}
+		return load
+	}, hub.SetDraining)
+	clusterHandler := NewClusterHandler(authService, clusterRegistry, ps != nil)
+
+	// Identity verification providers for proctored classes
+	var providers []verification.Provider
+	if cfg.VerificationWebhookSecret != "" {
+		providers = append(providers, verification.NewHMACProvider(cfg.VerificationProvider, cfg.VerificationWebhookSecret))
+	}
+	// Live caption translation, optional
+	var captionService *captions.Service
+	if cfg.TranslationURL != "" {
+		captionService = captions.NewService(captions.NewLibreTranslate(cfg.TranslationURL, cfg.TranslationAPIKey), cfg.TranslationCacheTTL)
+		log.Printf("🌐 Caption translation enabled (%s)", captionService.Provider())
+	}
+
+	// TURN region selection by client location
+	iceHandler := NewICEHandler(
+		ice.NewSelector(cfg.STUNServers, turnRegions(cfg), cfg.TURNRegionsPerClient),
+		locator,
+	)
+
+	verificationHandler := NewVerificationHandler(authService, verificationRepo, scheduleRepo, batchRepo, userRepo, verification.NewRegistry(providers...), cfg.StoragePath)
+
+	// Webhook inbox for external systems
+	webhookHandler := NewWebhookHandler(userRepo, batchRepo, scheduleRepo, webhookEventRepo, billingHandler, sessionCloser, webhookDispatcher, cfg.WebhookRetention)
+	if err := webhookDispatcher.Check(); err != nil {
+		log.Printf("⚠️ Warning: Webhook routes that can't run: %v", err)
+	}
+	if webhookDispatcher.Enabled() {
+		log.Printf("📥 Webhook inbox enabled (%d sources, %d routes)", len(webhookSecrets), len(webhookRoutes))
+	}
+	apiUsage := apiusage.NewTracker(apiUsageRepo, notifier, cfg.APIQuotas, float64(cfg.APIQuotaAlertPercent)/100)
+	apiUsageHandler := NewAPIUsageHandler(apiUsageRepo, apiUsage)
+	clientEventHandler := NewClientEventHandler(authService, clientEventRepo, cfg.ClientEventSampleRates, cfg.InstanceID)
+	if cfg.BillingEnabled {
+		log.Printf("💳 Billing enforced for organization %s", cfg.BrandingOrg)
+	}
 
 	log.Printf("📹 Recordings will be saved to: %s/recordings", cfg.StoragePath)
 	log.Printf("📄 Notes will be saved to: %s/notes", cfg.StoragePath)
@@ -141,45 +725,236 @@ func New(cfg *config.Config, staticFiles embed.FS, staticDir string) (*Server, e
 		log.Printf("⚡ Caching enabled (User: %v, Batch: %v, Schedule: %v)", cfg.UserCacheTTL, cfg.BatchCacheTTL, cfg.ScheduleCacheTTL)
 	}
 
-	return &Server{
-		config:           cfg,
-		hub:              room.NewHub(),
-		rtcService:       rtc.NewService(cfg.STUNServers),
-		staticFS:         staticFS,
-		db:               db,
-		pubsub:           ps,
-		userRepo:         userRepo,
-		batchRepo:        batchRepo,
-		scheduleRepo:     scheduleRepo,
-		recordingRepo:    recordingRepo,
-		noteRepo:         noteRepo,
-		authService:      authService,
-		authHandler:      authHandler,
-		adminHandler:     adminHandler,
-		batchHandler:     batchHandler,
-		scheduleHandler:  scheduleHandler,
-		recordingHandler: recordingHandler,
-		noteHandler:      noteHandler,
-	}, nil
+	rtcService, err := rtc.NewService(cfg.STUNServers, rtc.TURNPolicy{
+		Servers:        cfg.TURNServers,
+		Username:       cfg.TURNUsername,
+		Password:       cfg.TURNPassword,
+		RelayOnlyRooms: cfg.TURNRelayOnlyRooms,
+	}, rtc.RetryPolicy{
+		MaxAttempts:      cfg.ICERestartMaxAttempts,
+		BaseBackoff:      cfg.ICERestartBaseBackoff,
+		MaxBackoff:       cfg.ICERestartMaxBackoff,
+		BreakerThreshold: cfg.ICERestartBreakerThreshold,
+		BreakerCooldown:  cfg.ICERestartBreakerCooldown,
+	}, rtc.AdaptationPolicy{
+		Interval:        cfg.UplinkAdaptInterval,
+		HighLossPercent: float64(cfg.UplinkAdaptHighLossPercent),
+		LowLossPercent:  float64(cfg.UplinkAdaptLowLossPercent),
+		Sustain:         cfg.UplinkAdaptSustain,
+	}, rtc.BitrateCapPolicy{
+		Interval:         cfg.PresenterBitrateCapInterval,
+		MaxKbps:          cfg.PresenterMaxBitrateKbps,
+		MinKbps:          cfg.PresenterMinBitrateKbps,
+		EgressBudgetKbps: cfg.EgressBudgetKbps,
+	}, rtc.SpeakingPolicy{
+		ThresholdDBov: cfg.SpeakingThresholdDBov,
+		Hold:          cfg.SpeakingHold,
+		Interval:      cfg.SpeakingInterval,
+	}, rtc.NetworkPolicy{
+		NetworkTypes:         cfg.ICENetworkTypes,
+		UDPPortMin:           cfg.ICEUDPPortMin,
+		UDPPortMax:           cfg.ICEUDPPortMax,
+		UDPMuxPort:           cfg.ICEUDPMuxPort,
+		TCPPort:              cfg.ICETCPPort,
+		NAT1To1IPs:           cfg.ICENAT1To1IPs,
+		NAT1To1CandidateType: cfg.ICENAT1To1CandidateType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid ICE settings: %w", err)
+	}
+	egressManager.SetKeyframeRequester(rtcService.RequestKeyframe)
+	liveRecorder.SetKeyframeRequester(rtcService.RequestKeyframe)
+	rtcService.Register(hub.Hooks())
+
+	// Alerts when the health of a live class degrades
+	alertEngine := alerts.NewEngine(alerts.Policy{
+		Window:               cfg.AlertWindow,
+		ViewerDropPercent:    cfg.AlertViewerDropPercent,
+		MinViewers:           cfg.AlertViewerDropMinViewers,
+		PresenterICEFailures: cfg.AlertPresenterICEFailures,
+		ForwardErrorPercent:  cfg.AlertForwardErrorPercent,
+		MinForwarded:         cfg.AlertForwardMinPackets,
+		Cooldown:             cfg.AlertCooldown,
+		NotifyPresenter:      cfg.AlertNotifyPresenter,
+	}, notifier, scheduleRepo, userRepo)
+	if alertEngine.Enabled() {
+		alertEngine.Register(hub.Hooks())
+		rtcService.SetHealthSink(alertEngine)
+	}
+
+	// Segments of recordings played into live classes
+	clipPlayer := rtc.NewClipPlayer(rtcService, cfg.CoWatchFFmpegPath, cfg.CoWatchVideoKbps)
+	if cfg.CoWatchFFmpegPath != "" && !clipPlayer.Available() {
+		log.Printf("⚠️ Warning: %s not found, recordings can't be played into live classes", cfg.CoWatchFFmpegPath)
+	}
+	clipPlayer.Register(hub.Hooks())
+	coWatchHandler := NewCoWatchHandler(scheduleRepo, recordingRepo, files, hub, clipPlayer)
+
+	srv := &Server{
+		config:              cfg,
+		hub:                 hub,
+		rtcService:          rtcService,
+		staticFS:            staticFS,
+		db:                  db,
+		pubsub:              ps,
+		userRepo:            userRepo,
+		batchRepo:           batchRepo,
+		scheduleRepo:        scheduleRepo,
+		recordingRepo:       recordingRepo,
+		noteRepo:            noteRepo,
+		dmRepo:              dmRepo,
+		verificationRepo:    verificationRepo,
+		examAuditRepo:       examAuditRepo,
+		notificationRepo:    notificationRepo,
+		storageUsageRepo:    storageUsageRepo,
+		reportRepo:          reportRepo,
+		authService:         authService,
+		auth:                middleware.NewAuth(authService),
+		authHandler:         authHandler,
+		adminHandler:        adminHandler,
+		batchHandler:        batchHandler,
+		scheduleHandler:     scheduleHandler,
+		recordingHandler:    recordingHandler,
+		noteHandler:         noteHandler,
+		handoutHandler:      handoutHandler,
+		handInHandler:       handInHandler,
+		peerReviewHandler:   peerReviewHandler,
+		quizDraftHandler:    quizDraftHandler,
+		exportHandler:       exportHandler,
+		templateHandler:     templateHandler,
+		goalHandler:         goalHandler,
+		catchUpHandler:      catchUpHandler,
+		eventsHandler:       eventsHandler,
+		watchHandler:        watchHandler,
+		consentHandler:      consentHandler,
+		dmHandler:           dmHandler,
+		roomHandler:         roomHandler,
+		controlHandler:      controlHandler,
+		restreamHandler:     restreamHandler,
+		recordHandler:       recordHandler,
+		coWatchHandler:      coWatchHandler,
+		chatHandler:         chatHandler,
+		attendanceHandler:   attendanceHandler,
+		egress:              egressManager,
+		liveRecorder:        liveRecorder,
+		clipPlayer:          clipPlayer,
+		verificationHandler: verificationHandler,
+		webhookHandler:      webhookHandler,
+		apiUsageHandler:     apiUsageHandler,
+		clientEventHandler:  clientEventHandler,
+		apiUsage:            apiUsage,
+		billingHandler:      billingHandler,
+		examHandler:         examHandler,
+		lobbyHandler:        lobbyHandler,
+		assistantHandler:    assistantHandler,
+		groupHandler:        groupHandler,
+		suggestionHandler:   suggestionHandler,
+		notificationHandler: notificationHandler,
+		moderationHandler:   moderationHandler,
+		legalHoldHandler:    legalHoldHandler,
+		maintenanceHandler:  maintenanceHandler,
+		brandingHandler:     brandingHandler,
+		diagnosticsHandler:  diagnosticsHandler,
+		queryAnalyzer:       queryAnalyzer,
+		captionService:      captionService,
+		iceHandler:          iceHandler,
+		analytics:           exporter,
+		coldStorage:         coldStorage,
+		notifier:            notifier,
+		storageMonitor:      storageMonitor,
+		nameReconciler:      nameReconciler,
+		notePublisher:       notePublisher,
+		catchUpSender:       catchUpSender,
+		anonymizer:          anonymizer,
+		notifyReleaser:      notifyReleaser,
+		classEmails:         classEmails,
+		cohortRoller:        cohortRoller,
+		imageOptimizer:      imageOptimizer,
+		pdfWorker:           pdfWorker,
+		chapterGenerator:    chapterGenerator,
+		composites:          compositeProcessor,
+		variants:            variantGenerator,
+		alerts:              alertEngine,
+		roomEvents:          roomEvents,
+		chatLog:             chatLog,
+		attendance:          attendanceTracker,
+		pressureMonitor:     pressureMonitor,
+		clusterRegistry:     clusterRegistry,
+		clusterHandler:      clusterHandler,
+		responseCache:       responseCache,
+	}
+
+	// Cache clears requested on another instance (e.g. via liveclassctl)
+	if ps != nil {
+		ps.Subscribe(cacheClearChannel, func(msg *pubsub.Message) {
+			srv.clearCaches()
+		})
+	}
+
+	return srv, nil
 }
 
 // Run starts the HTTP server and blocks until it exits.
 func (s *Server) Run() error {
-	handler := NewHandler(s.hub, s.rtcService)
+	handler := NewHandler(HandlerDeps{
+		Hub:            s.hub,
+		RTC:            s.rtcService,
+		Auth:           s.authService,
+		DirectMessages: s.dmHandler,
+		Exams:          s.examHandler,
+		Verifications:  s.verificationHandler,
+		Lobbies:        s.lobbyHandler,
+		Assistants:     s.assistantHandler,
+		Goals:          s.goalHandler,
+		Consent:        s.consentHandler,
+		LiveRecordings: s.recordHandler,
+		CoWatch:        s.coWatchHandler,
+		ChatLog:        s.chatLog,
+		Analytics:      s.analytics,
+		Captions:       s.captionService,
+		ICE:            s.iceHandler,
+	}, HandlerOptions{
+		PresenterGrace: s.config.PresenterGracePeriod,
+		Compression: CompressionOptions{
+			Enabled:   s.config.WSCompressionEnabled,
+			Level:     s.config.WSCompressionLevel,
+			Threshold: s.config.WSCompressionThreshold,
+			ReadLimit: s.config.WSMaxMessageBytes,
+		},
+		RoomTokens: RoomTokenOptions{
+			TTL:      s.config.RoomTokenTTL,
+			Required: s.config.RoomTokenRequired,
+		},
+		Acks: room.AckPolicy{
+			Timeout:        s.config.SignalingAckTimeout,
+			MaxRetransmits: s.config.SignalingMaxRetransmits,
+		},
+	})
 
 	mux := http.NewServeMux()
 
+	// Route guards; the user they authenticate is in the request context
+	requireAuth := s.auth.RequireAuth
+	requireAdmin := s.auth.RequireRole(models.RoleAdmin)
+	requireAdminOrPresenter := s.auth.RequireRole(models.RoleAdmin, models.RolePresenter)
+
 	// Auth routes
 	mux.HandleFunc("/api/auth/register", s.authHandler.Register)
 	mux.HandleFunc("/api/auth/login", s.authHandler.Login)
-	mux.HandleFunc("/api/auth/me", s.authHandler.Me)
-	mux.HandleFunc("/api/auth/change-password", s.authHandler.ChangePassword)
+	mux.HandleFunc("/api/auth/reactivate", s.authHandler.Reactivate)
+	mux.HandleFunc("/api/auth/refresh", s.authHandler.Refresh)
+	mux.HandleFunc("/api/auth/logout", requireAuth(s.authHandler.Logout))
+	mux.HandleFunc("/api/auth/me", requireAuth(s.authHandler.Me))
+	mux.HandleFunc("/api/auth/change-password", requireAuth(s.authHandler.ChangePassword))
+	mux.HandleFunc("/api/auth/sessions", requireAuth(s.authHandler.ListSessions))
+	mux.HandleFunc("/api/auth/sessions/", requireAuth(s.authHandler.SignOutSession))
+	mux.HandleFunc("/api/me", requireAuth(s.authHandler.Deactivate))
 
 	// Admin routes
-	mux.HandleFunc("/api/admin/users", s.adminHandler.requireAdmin(s.adminHandler.ListUsers))
-	mux.HandleFunc("/api/admin/users/pending", s.adminHandler.requireAdmin(s.adminHandler.GetPendingUsers))
-	mux.HandleFunc("/api/admin/stats", s.adminHandler.requireAdmin(s.adminHandler.GetStats))
-	mux.HandleFunc("/api/admin/users/", s.adminHandler.requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/admin/users", requireAdmin(s.adminHandler.ListUsers))
+	mux.HandleFunc("/api/admin/users/pending", requireAdmin(s.adminHandler.GetPendingUsers))
+	mux.HandleFunc("/api/admin/stats", requireAdmin(s.adminHandler.GetStats))
+	mux.HandleFunc("/api/admin/users/", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
 		if strings.Contains(path, "/status") {
 			s.adminHandler.UpdateUserStatus(w, r)
@@ -190,27 +965,129 @@ func (s *Server) Run() error {
 		}
 	}))
 
+	mux.HandleFunc("/api/admin/rooms", requireAdmin(s.roomHandler.ListRooms))
+	mux.HandleFunc("/api/admin/rooms/", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/end") {
+			s.roomHandler.EndRoom(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	mux.HandleFunc("/api/admin/moderation", requireAdmin(s.moderationHandler.Queue))
+	mux.HandleFunc("/api/admin/moderation/", requireAdmin(s.moderationHandler.Resolve))
+	mux.HandleFunc("/api/admin/legal-holds", requireAdmin(s.legalHoldHandler.Holds))
+	mux.HandleFunc("/api/admin/legal-holds/audit", requireAdmin(s.legalHoldHandler.AuditLog))
+	mux.HandleFunc("/api/admin/legal-holds/", requireAdmin(s.legalHoldHandler.Release))
+	mux.HandleFunc("/api/admin/maintenance", requireAdmin(s.maintenanceHandler.Windows))
+	mux.HandleFunc("/api/admin/maintenance/", requireAdmin(s.maintenanceHandler.Delete))
+	mux.HandleFunc("/api/admin/billing", requireAdmin(s.billingHandler.Overview))
+	mux.HandleFunc("/api/admin/billing/plans", requireAdmin(s.billingHandler.Plans))
+	mux.HandleFunc("/api/admin/billing/plans/", requireAdmin(s.billingHandler.DeletePlan))
+	mux.HandleFunc("/api/admin/billing/subscriptions", requireAdmin(s.billingHandler.Subscribe))
+	mux.HandleFunc("/api/admin/billing/subscriptions/", requireAdmin(s.billingHandler.DeleteSubscription))
+	mux.HandleFunc("/api/admin/diagnostics/queries", requireAdmin(s.diagnosticsHandler.Queries))
+	mux.HandleFunc("/api/admin/cluster", requireAdmin(s.clusterHandler.Cluster))
+	mux.HandleFunc("/api/admin/cluster/", requireAdmin(s.clusterHandler.Drain))
+	mux.HandleFunc("/api/admin/cache/clear", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.clearCaches()
+		if s.pubsub != nil {
+			if err := s.pubsub.Publish(r.Context(), cacheClearChannel, &pubsub.Message{Type: "clear"}); err != nil {
+				log.Printf("⚠️ Failed to publish cache clear: %v", err)
+			}
+		}
+		sendJSON(w, map[string]string{"message": "Caches cleared"}, http.StatusOK)
+	}))
+	// Effective configuration of this instance, secrets redacted, for support
+	mux.HandleFunc("/api/admin/config", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		sendJSON(w, map[string]interface{}{
+			"instanceId": s.config.InstanceID,
+			"version":    s.config.Version,
+			"settings":   s.config.Redacted(),
+		}, http.StatusOK)
+	}))
+
 	// Batch routes
-	mux.HandleFunc("/api/batches", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/batches", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			s.batchHandler.ListBatches(w, r)
+			s.cached(cacheTagBatches, s.config.HTTPCacheBatchesTTL, s.batchHandler.ListBatches)(w, r)
 		case http.MethodPost:
-			s.batchHandler.requireAdminOrPresenter(s.batchHandler.CreateBatch)(w, r)
+			requireAdminOrPresenter(s.batchHandler.CreateBatch)(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
-	mux.HandleFunc("/api/batches/students", s.batchHandler.requireAdminOrPresenter(s.batchHandler.GetAvailableStudents))
-	mux.HandleFunc("/api/batches/", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/batches/students", requireAdminOrPresenter(s.batchHandler.GetAvailableStudents))
+	mux.HandleFunc("/api/batches/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/api/batches/")
 		parts := strings.Split(path, "/")
 
+		if len(parts) >= 2 {
+			switch parts[1] {
+			case "attendance.csv":
+				s.exportHandler.ExportAttendance(w, r)
+				return
+			case "gradebook.xlsx":
+				s.exportHandler.ExportGradebook(w, r)
+				return
+			case "objectives.csv":
+				s.exportHandler.ExportObjectives(w, r)
+				return
+			case "direct-messages":
+				s.dmHandler.UpdateAvailability(w, r)
+				return
+			}
+		}
+
+		if len(parts) >= 2 && parts[1] == "assistants" {
+			switch {
+			case r.Method == http.MethodGet:
+				s.assistantHandler.ListAssistants(w, r)
+			case r.Method == http.MethodPost:
+				s.assistantHandler.AddAssistants(w, r)
+			case r.Method == http.MethodDelete && len(parts) >= 3:
+				s.assistantHandler.RemoveAssistant(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if len(parts) >= 2 && parts[1] == "groups" {
+			switch {
+			case len(parts) >= 4 && parts[3] == "members" && r.Method == http.MethodPost:
+				s.groupHandler.AddMembers(w, r)
+			case len(parts) >= 5 && parts[3] == "members" && r.Method == http.MethodDelete:
+				s.groupHandler.RemoveMember(w, r)
+			case len(parts) >= 4:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			case r.Method == http.MethodGet && len(parts) == 2:
+				s.groupHandler.ListGroups(w, r)
+			case r.Method == http.MethodPost && len(parts) == 2:
+				s.groupHandler.CreateGroup(w, r)
+			case r.Method == http.MethodPut && len(parts) == 3:
+				s.groupHandler.RenameGroup(w, r)
+			case r.Method == http.MethodDelete && len(parts) == 3:
+				s.groupHandler.DeleteGroup(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
 		if len(parts) >= 2 && parts[1] == "students" {
 			if r.Method == http.MethodPost {
-				s.batchHandler.requireAdminOrPresenter(s.batchHandler.AddStudentsToBatch)(w, r)
+				requireAdminOrPresenter(s.batchHandler.AddStudentsToBatch)(w, r)
 			} else if r.Method == http.MethodDelete && len(parts) >= 3 {
-				s.batchHandler.requireAdminOrPresenter(s.batchHandler.RemoveStudentFromBatch)(w, r)
+				requireAdminOrPresenter(s.batchHandler.RemoveStudentFromBatch)(w, r)
 			} else {
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
@@ -221,24 +1098,80 @@ func (s *Server) Run() error {
 		case http.MethodGet:
 			s.batchHandler.GetBatch(w, r)
 		case http.MethodDelete:
-			s.batchHandler.requireAdminOrPresenter(s.batchHandler.DeleteBatch)(w, r)
+			requireAdminOrPresenter(s.batchHandler.DeleteBatch)(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
 
 	// Schedule routes
-	mux.HandleFunc("/api/schedules", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/schedules", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			s.scheduleHandler.ListSchedules(w, r)
+			s.cached(cacheTagSchedules, s.config.HTTPCacheSchedulesTTL, s.scheduleHandler.ListSchedules)(w, r)
 		case http.MethodPost:
 			s.scheduleHandler.CreateSchedule(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
-	mux.HandleFunc("/api/schedules/", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	// Open slots for make-up classes
+	mux.HandleFunc("/api/schedules/suggest", requireAdminOrPresenter(s.suggestionHandler.Suggest))
+	// Class template library (presenters manage their own, admins all)
+	mux.HandleFunc("/api/templates", requireAdminOrPresenter(s.templateHandler.Templates))
+	mux.HandleFunc("/api/templates/", requireAdminOrPresenter(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/templates/"), "/")
+		if len(parts) >= 2 && parts[1] == "schedule" {
+			s.templateHandler.ScheduleFromTemplate(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "cohorts" {
+			s.templateHandler.Cohorts(w, r)
+			return
+		}
+		s.templateHandler.Template(w, r)
+	}))
+	// Upcoming maintenance windows, for banners
+	mux.HandleFunc("/api/maintenance", requireAuth(s.maintenanceHandler.Upcoming))
+	// Student weekly goals
+	mux.HandleFunc("/api/goals", requireAuth(s.goalHandler.Dashboard))
+	mux.HandleFunc("/api/goals/", requireAuth(s.goalHandler.Goal))
+	// Student weekly catch-up summaries
+	mux.HandleFunc("/api/catch-up", requireAuth(s.catchUpHandler.Summary))
+	mux.HandleFunc("/api/catch-up/history", requireAuth(s.catchUpHandler.History))
+	mux.HandleFunc("/api/catch-up/settings", requireAuth(s.catchUpHandler.Settings))
+	// Student's own attendance of live classes
+	mux.HandleFunc("/api/attendance/me", requireAuth(s.attendanceHandler.MyAttendance))
+
+	// Live events over server-sent events, for clients without a WebSocket
+	mux.HandleFunc("/api/events/stream", s.auth.RequireAuthAllowingQuery(s.eventsHandler.Stream))
+
+	// Client-side telemetry from the SPA
+	mux.HandleFunc("/api/events", requireAuth(s.clientEventHandler.Report))
+	mux.HandleFunc("/api/admin/client-events", requireAdmin(s.clientEventHandler.List))
+	mux.HandleFunc("/api/admin/client-events/summary", requireAdmin(s.clientEventHandler.Summary))
+
+	// Rich text
+	mux.HandleFunc("/api/render/markdown", requireAuth(RenderMarkdown))
+
+	// Portal branding, public so the SPA can style the sign-in page
+	mux.HandleFunc("/api/branding", s.brandingHandler.Get)
+	mux.HandleFunc("/api/branding/logo", s.brandingHandler.Logo)
+	mux.HandleFunc("/api/admin/branding", requireAdmin(s.brandingHandler.Update))
+	mux.HandleFunc("/api/admin/branding/logo", requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			s.brandingHandler.UploadLogo(w, r)
+		case http.MethodDelete:
+			s.brandingHandler.DeleteLogo(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	// ICE servers for the caller's location
+	mux.HandleFunc("/api/ice-config", requireAuth(s.iceHandler.Config))
+	mux.HandleFunc("/api/schedules/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/api/schedules/")
 		parts := strings.Split(path, "/")
 
@@ -253,9 +1186,60 @@ func (s *Server) Run() error {
 			case "join":
 				s.scheduleHandler.JoinClass(w, r)
 				return
+			case "lobby":
+				s.lobbyHandler.Enter(w, r)
+				return
+			case "handouts":
+				s.handoutHandler.Handouts(w, r)
+				return
+			case "hand-ins":
+				s.handInHandler.HandIns(w, r)
+				return
+			case "peer-review":
+				s.peerReviewHandler.PeerReview(w, r)
+				return
+			case "quiz-drafts":
+				s.quizDraftHandler.QuizDrafts(w, r)
+				return
 			case "cancel":
 				s.scheduleHandler.CancelSchedule(w, r)
 				return
+			case "archive":
+				s.scheduleHandler.GetArchive(w, r)
+				return
+			case "record":
+				s.recordHandler.Record(w, r)
+				return
+			case "co-watch":
+				s.coWatchHandler.CoWatch(w, r)
+				return
+			case "chat":
+				s.chatHandler.GetChat(w, r)
+				return
+			case "attendance", "attendance.csv":
+				s.attendanceHandler.ClassAttendance(w, r)
+				return
+			case "verification":
+				if len(parts) >= 3 && parts[2] == "photo" {
+					s.verificationHandler.UploadPhoto(w, r)
+				} else if r.Method == http.MethodPost {
+					s.verificationHandler.StartProviderVerification(w, r)
+				} else {
+					s.verificationHandler.GetMyVerification(w, r)
+				}
+				return
+			case "verifications":
+				if len(parts) >= 4 && parts[3] == "photo" {
+					s.verificationHandler.GetPhoto(w, r)
+				} else if len(parts) >= 3 && parts[2] != "" {
+					s.verificationHandler.ReviewVerification(w, r)
+				} else {
+					s.verificationHandler.ListVerifications(w, r)
+				}
+				return
+			case "exam-audit":
+				s.examHandler.GetAuditTrail(w, r)
+				return
 			}
 		}
 
@@ -272,7 +1256,7 @@ func (s *Server) Run() error {
 	}))
 
 	// Recording routes
-	mux.HandleFunc("/api/recordings", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/recordings", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
 			s.recordingHandler.ListRecordings(w, r)
@@ -282,12 +1266,40 @@ func (s *Server) Run() error {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
-	mux.HandleFunc("/api/recordings/", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	recordingRoutes := requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/api/recordings/")
 		parts := strings.Split(path, "/")
 
-		if len(parts) >= 2 && parts[1] == "stream" {
-			s.recordingHandler.StreamRecording(w, r)
+		if len(parts) >= 2 && parts[1] == "playback-token" {
+			s.recordingHandler.PlaybackToken(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "restore" {
+			s.recordingHandler.RestoreRecording(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "chapters" {
+			s.recordingHandler.Chapters(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "visibility" {
+			s.recordingHandler.Visibility(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "heartbeat" {
+			s.watchHandler.Heartbeat(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "progress" {
+			s.watchHandler.Progress(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "engagement" {
+			s.watchHandler.Engagement(w, r)
+			return
+		}
+		if len(parts) == 2 && parts[0] == "uploads" {
+			s.recordingHandler.UploadStatus(w, r)
 			return
 		}
 
@@ -299,10 +1311,91 @@ func (s *Server) Run() error {
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
+	})
+	mux.HandleFunc("/api/recordings/", func(w http.ResponseWriter, r *http.Request) {
+		// Streams authenticate themselves, with a playback token or a login token
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/recordings/"), "/")
+		if len(parts) >= 2 && parts[1] == "stream" {
+			s.recordingHandler.StreamRecording(w, r)
+			return
+		}
+		recordingRoutes(w, r)
+	})
+
+	// Files shared in live classes (authenticated by the signed link)
+	mux.HandleFunc("/api/handouts/", s.handoutHandler.Download)
+
+	// CDN origin for recording files (authenticated by the signed URL)
+	mux.HandleFunc("/cdn/", s.recordingHandler.Origin)
+
+	// Identity verification provider webhooks (authenticated by the provider signature)
+	mux.HandleFunc("/api/verification/webhook/", s.verificationHandler.Webhook)
+
+	// Webhook inbox (authenticated by each source's signature)
+	mux.HandleFunc("/api/webhooks/events", requireAdmin(s.webhookHandler.ListEvents))
+	mux.Handle("/api/webhooks/", middleware.Usage(s.recordWebhookUsage)(http.HandlerFunc(s.webhookHandler.Receive)))
+
+	// Usage of the API by external clients
+	mux.HandleFunc("/api/admin/api-usage", requireAdmin(s.apiUsageHandler.Usage))
+
+	// Direct message routes
+	mux.HandleFunc("/api/messages", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			s.dmHandler.GetConversation(w, r)
+		case http.MethodPost:
+			s.dmHandler.SendMessage(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/messages/read", requireAuth(s.dmHandler.MarkConversationRead))
+	mux.HandleFunc("/api/messages/unread", requireAuth(s.dmHandler.GetUnreadCounts))
+
+	// Content reports
+	mux.HandleFunc("/api/reports", requireAuth(s.moderationHandler.Report))
+
+	// Notification routes
+	mux.HandleFunc("/api/notifications", requireAuth(s.notificationHandler.ListNotifications))
+	mux.HandleFunc("/api/notifications/read", requireAuth(s.notificationHandler.MarkRead))
+	mux.HandleFunc("/api/notifications/settings", requireAuth(s.notificationHandler.Settings))
+
+	// Live room routes
+	roomRoutes := requireAdminOrPresenter(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/rooms/")
+		parts := strings.Split(path, "/")
+
+		if len(parts) >= 2 && parts[1] == "stats" {
+			s.roomHandler.GetStats(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "timeline" {
+			s.roomHandler.GetTimeline(w, r)
+			return
+		}
+		if len(parts) == 2 && parts[1] == "restream" {
+			s.restreamHandler.Restream(w, r)
+			return
+		}
+
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/api/rooms/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		// Assistants use the control panel too; it checks roles itself
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/rooms/"), "/")
+		if len(parts) == 2 && parts[1] == "control" {
+			s.controlHandler.Control(w, r)
+			return
+		}
+		if len(parts) == 3 && parts[1] == "control" {
+			s.controlHandler.Act(w, r)
+			return
+		}
+		roomRoutes(w, r)
 	}))
 
 	// Notes routes
-	mux.HandleFunc("/api/notes", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/notes", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
 			s.noteHandler.ListNotes(w, r)
@@ -312,14 +1405,31 @@ func (s *Server) Run() error {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
-	mux.HandleFunc("/api/notes/", s.batchHandler.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/notes/library", requireAuth(s.noteHandler.Library))
+	mux.HandleFunc("/api/notes/bulk", requireAuth(s.noteHandler.BulkUpload))
+	mux.HandleFunc("/api/notes/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/api/notes/")
 		parts := strings.Split(path, "/")
 
-		if len(parts) >= 2 && parts[1] == "download" {
+		if len(parts) >= 2 && (parts[1] == "download" || parts[1] == "preview") {
 			s.noteHandler.Download(w, r)
 			return
 		}
+		if len(parts) == 2 && parts[1] == "library" && r.Method == http.MethodPut {
+			s.noteHandler.SetLibrary(w, r)
+			return
+		}
+		if len(parts) >= 2 && parts[1] == "batches" {
+			switch {
+			case r.Method == http.MethodPost && len(parts) == 2:
+				s.noteHandler.LinkBatch(w, r)
+			case r.Method == http.MethodDelete && len(parts) == 3:
+				s.noteHandler.UnlinkBatch(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
 
 		switch r.Method {
 		case http.MethodPut:
@@ -336,6 +1446,16 @@ func (s *Server) Run() error {
 		sendJSON(w, map[string]string{"status": "healthy"}, http.StatusOK)
 	})
 
+	// Server clock for client countdowns (clients estimate their offset from this)
+	mux.HandleFunc("/api/time", func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		w.Header().Set("Cache-Control", "no-store")
+		sendJSON(w, map[string]interface{}{
+			"serverTime": now,
+			"unixMillis": now.UnixMilli(),
+		}, http.StatusOK)
+	})
+
 	// Readiness check endpoint (readiness probe for K8s)
 	mux.HandleFunc("/api/ready", func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
@@ -361,12 +1481,24 @@ func (s *Server) Run() error {
 			}
 		}
 
+		// Draining instances take no new traffic
+		if s.clusterRegistry.Draining() {
+			sendJSON(w, map[string]interface{}{
+				"status":     "draining",
+				"instanceId": s.config.InstanceID,
+			}, http.StatusServiceUnavailable)
+			return
+		}
+
 		sendJSON(w, map[string]interface{}{
 			"status":     "ready",
 			"instanceId": s.config.InstanceID,
 		}, http.StatusOK)
 	})
 
+	// Metrics endpoint (Prometheus scrape target), for the scrape token or admins
+	mux.HandleFunc("/metrics", s.scrapeAuth(metrics.Handler().ServeHTTP, requireAdmin))
+
 	// WebSocket route
 	mux.Handle("/ws", handler)
 
@@ -402,9 +1534,15 @@ func (s *Server) Run() error {
 
 	// Apply middleware in order (last added = first executed)
 	middlewares := []func(http.Handler) http.Handler{
+		middleware.RequestID,
+	}
+	if accessLogger := s.openAccessLog(mux); accessLogger != nil {
+		middlewares = append(middlewares, accessLogger.Handler)
+	}
+	middlewares = append(middlewares,
 		middleware.CORS([]string{"*"}),
 		middleware.Recovery,
-	}
+	)
 
 	// Add compression if enabled
 	if s.config.EnableCompression {
@@ -431,16 +1569,311 @@ func (s *Server) Run() error {
 	addr := s.config.Address()
 	log.Printf("🚀 LiveClass server starting on http://localhost%s", addr)
 	log.Printf("📺 Open in browser to start or join a class")
-	log.Printf("⚡ Performance optimizations: Compression=%v, Timeout=%v", s.config.EnableCompression, s.config.RequestTimeout)
+	log.Printf("⚡ Performance optimizations: Compression=%v, WebSocket compression=%v, Timeout=%v", s.config.EnableCompression, s.config.WSCompressionEnabled, s.config.RequestTimeout)
 	if s.pubsub != nil {
 		log.Printf("🔄 Multi-instance mode: Redis pub/sub enabled")
 	}
 
+	// Background jobs
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	s.stopJobs = stopJobs
+	if s.config.StorageReportInterval > 0 {
+		go s.storageMonitor.Run(jobCtx)
+	}
+	if s.config.NameReconcileInterval > 0 {
+		go s.nameReconciler.Run(jobCtx)
+	}
+	if s.config.NotePublishInterval > 0 {
+		go s.notePublisher.Run(jobCtx)
+	}
+	if s.config.NotifyReleaseInterval > 0 {
+		go s.notifyReleaser.Run(jobCtx)
+	}
+	if s.config.NotifyClassInterval > 0 {
+		go s.classEmails.Run(jobCtx)
+	}
+	if s.config.CatchUpInterval > 0 {
+		go s.catchUpSender.Run(jobCtx)
+	}
+	if s.config.DeactivationAnonymizeInterval > 0 {
+		go s.anonymizer.Run(jobCtx)
+	}
+	if s.config.RoomReapInterval > 0 {
+		reaper := room.NewReaper(s.hub, s.config.RoomIdleTimeout, s.config.RoomReapInterval, handler.removeParticipant, s.roomHandler.completeIdleRoom)
+		go reaper.Run(jobCtx)
+	}
+	if s.config.QualitySampleInterval > 0 {
+		go room.NewQualitySampler(s.hub, s.config.QualitySampleInterval).Run(jobCtx)
+	}
+	if s.config.CohortRollupInterval > 0 {
+		go s.cohortRoller.Run(jobCtx)
+	}
+	if s.config.CPUPressureInterval > 0 {
+		go s.pressureMonitor.Run(jobCtx)
+	}
+	if s.analytics != nil {
+		go s.analytics.Run(jobCtx)
+	}
+	if s.coldStorage != nil {
+		go s.coldStorage.Run(jobCtx)
+	}
+	go s.roomEvents.Run(jobCtx)
+	go s.chatLog.Run(jobCtx)
+	go s.attendance.Run(jobCtx)
+	go s.clusterRegistry.Run(jobCtx)
+	go s.imageOptimizer.Run(jobCtx)
+	go s.pdfWorker.Run(jobCtx)
+	go s.chapterGenerator.Run(jobCtx)
+	go s.composites.Run(jobCtx)
+	go s.variants.Run(jobCtx)
+	go s.alerts.Run(jobCtx)
+	if s.queryAnalyzer != nil && s.config.QueryExplainInterval > 0 {
+		go s.queryAnalyzer.Run(jobCtx)
+	}
+
+	if s.config.CacheEnabled && s.config.CacheWarmupEnabled {
+		s.warmCaches(s.config.CacheWarmupBudget)
+	}
+
 	return s.httpServer.ListenAndServe()
 }
 
+// newColdStorageTier returns the configured cold storage tier, or nil when
+// archiving is disabled or misconfigured.
+func newColdStorageTier(cfg *config.Config) coldstorage.Tier {
+	switch cfg.ColdStorageBackend {
+	case "":
+		return nil
+	case "dir":
+		if cfg.ColdStorageDir == "" {
+			log.Printf("⚠️ Warning: COLD_STORAGE_DIR is not set, cold storage disabled")
+			return nil
+		}
+		return coldstorage.NewDir(cfg.ColdStorageDir)
+	case "s3":
+		if cfg.ColdStorageS3Bucket == "" {
+			log.Printf("⚠️ Warning: COLD_STORAGE_S3_BUCKET is not set, cold storage disabled")
+			return nil
+		}
+		return coldstorage.NewS3(cfg.ColdStorageS3Endpoint, cfg.ColdStorageS3Bucket, cfg.ColdStorageS3Region,
+			cfg.ColdStorageS3AccessKey, cfg.ColdStorageS3SecretKey, cfg.ColdStorageS3Class,
+			cfg.ColdStorageRestoreTier, cfg.ColdStorageRestoreDays)
+	}
+	log.Printf("⚠️ Warning: Unknown COLD_STORAGE_BACKEND %q, cold storage disabled", cfg.ColdStorageBackend)
+	return nil
+}
+
+// turnRegions returns the configured TURN regions. Servers in TURN_SERVERS
+// form a "default" region without a location, offered when no closer region
+// is known.
+func turnRegions(cfg *config.Config) []ice.Region {
+	var regions []ice.Region
+	for _, r := range cfg.TURNRegions {
+		regions = append(regions, ice.Region{
+			Name:       r.Name,
+			URLs:       r.URLs,
+			Username:   r.Username,
+			Credential: r.Password,
+			Lat:        r.Lat,
+			Lon:        r.Lon,
+			HasCoords:  r.HasCoords,
+			Countries:  r.Countries,
+		})
+	}
+	if len(cfg.TURNServers) > 0 {
+		regions = append(regions, ice.Region{
+			Name:       "default",
+			URLs:       cfg.TURNServers,
+			Username:   cfg.TURNUsername,
+			Credential: cfg.TURNPassword,
+		})
+	}
+	return regions
+}
+
+// workingHours returns the configured working hours for schedule
+// suggestions, falling back to weekdays 09:00-18:00 UTC when misconfigured.
+func workingHours(cfg *config.Config) scheduling.WorkingHours {
+	wh, err := scheduling.ParseWorkingHours(cfg.WorkingDays, cfg.WorkingHoursStart, cfg.WorkingHoursEnd, cfg.WorkingTimezone)
+	if err != nil {
+		log.Printf("⚠️ Warning: Invalid working hours, using weekdays 09:00-18:00 UTC: %v", err)
+		wh, _ = scheduling.ParseWorkingHours([]string{"mon", "tue", "wed", "thu", "fri"}, "09:00", "18:00", "")
+	}
+	return wh
+}
+
+// openGeoIPDB loads the GeoIP database at path, or returns nil when none is
+// configured or it can't be loaded.
+func openGeoIPDB(path string) *geoip.DB {
+	if path == "" {
+		return nil
+	}
+	db, err := geoip.Open(path)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to load GeoIP database: %v", err)
+		return nil
+	}
+	log.Printf("🌍 Loaded GeoIP database with %d networks", db.Len())
+	return db
+}
+
+// newAnalyticsSink returns the configured analytics sink, or nil when export
+// is disabled or misconfigured.
+func newAnalyticsSink(cfg *config.Config) analytics.Sink {
+	switch cfg.AnalyticsSink {
+	case "":
+		return nil
+	case "clickhouse":
+		return analytics.NewClickHouse(cfg.AnalyticsClickHouseURL, cfg.AnalyticsClickHouseTable, cfg.AnalyticsClickHouseUser, cfg.AnalyticsClickHousePassword)
+	case "bigquery":
+		if cfg.AnalyticsBigQueryProject == "" {
+			log.Printf("⚠️ Warning: ANALYTICS_BIGQUERY_PROJECT is not set, analytics export disabled")
+			return nil
+		}
+		return analytics.NewBigQuery(cfg.AnalyticsBigQueryProject, cfg.AnalyticsBigQueryDataset, cfg.AnalyticsBigQueryTable, cfg.AnalyticsBigQueryToken)
+	}
+	log.Printf("⚠️ Warning: Unknown ANALYTICS_SINK %q, analytics export disabled", cfg.AnalyticsSink)
+	return nil
+}
+
+// Response cache tags
+const (
+	cacheTagBatches   = "batches"
+	cacheTagSchedules = "schedules"
+)
+
+// recordWebhookUsage counts a request to the webhook inbox as usage of the
+// source it posts for. Requests for unknown sources aren't any client's.
+func (s *Server) recordWebhookUsage(r *http.Request, status int, bytesIn, bytesOut int64) {
+	source := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
+	if !s.webhookHandler.dispatcher.Known(source) {
+		return
+	}
+	s.apiUsage.Record("webhook:"+source, status, bytesIn, bytesOut)
+}
+
+// openAccessLog opens the configured access log output and returns a logger
+// for the requests mux routes, or nil when it's off or can't be opened.
+func (s *Server) openAccessLog(mux *http.ServeMux) *middleware.AccessLogger {
+	if s.config.AccessLog == "" {
+		return nil
+	}
+	out, err := middleware.OpenAccessLog(s.config.AccessLog, s.config.AccessLogFile, s.config.AccessLogSyslogAddr)
+	if err != nil {
+		log.Printf("⚠️ Access log disabled: %v", err)
+		return nil
+	}
+	s.accessLog = out
+	log.Printf("📝 Access log: %s", s.config.AccessLog)
+
+	route := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	userID := func(r *http.Request) string {
+		user, ok := middleware.User(r.Context())
+		if !ok {
+			return ""
+		}
+		return user.ID.Hex()
+	}
+	return middleware.NewAccessLogger(out, route, userID, s.config.AccessLogSampling)
+}
+
+// cacheClearChannel is the pub/sub channel used to clear caches on every instance.
+const cacheClearChannel = "cache:clear"
+
+// clearCaches drops the repository caches and cached API responses on this instance.
+func (s *Server) clearCaches() {
+	s.userRepo.ClearCache()
+	s.batchRepo.ClearCache()
+	s.scheduleRepo.ClearCache()
+	s.recordingRepo.ClearCache()
+	s.noteRepo.ClearCache()
+	s.responseCache.Clear()
+	log.Println("🧹 Caches cleared")
+}
+
+// warmCaches preloads the repository caches before the server takes traffic,
+// so the first requests after a deploy don't all go to the database. It
+// stops when the budget runs out; the rest is fetched on demand.
+func (s *Server) warmCaches(budget time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	start := time.Now()
+	steps := []struct {
+		name string
+		warm func(context.Context) (int, error)
+	}{
+		{"batches", s.batchRepo.Warm},
+		{"classes", func(ctx context.Context) (int, error) {
+			return s.scheduleRepo.Warm(ctx, start.AddDate(0, 0, -1), start.AddDate(0, 0, 7))
+		}},
+		{"users", s.userRepo.Warm},
+	}
+	for _, step := range steps {
+		if ctx.Err() != nil {
+			log.Printf("⚠️ Warning: Cache warm-up ran out of time before %s", step.name)
+			return
+		}
+		n, err := step.warm(ctx)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to warm %s cache: %v", step.name, err)
+			continue
+		}
+		log.Printf("🔥 Warmed %d %s", n, step.name)
+	}
+	log.Printf("🔥 Caches warmed in %v", time.Since(start).Round(time.Millisecond))
+}
+
+// scrapeAuth lets requests with the metrics scrape token through to next,
+// and leaves the rest to next guarded by otherwise.
+func (s *Server) scrapeAuth(next http.HandlerFunc, otherwise func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
+	guarded := otherwise(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.config.MetricsToken
+		if token != "" && hmac.Equal([]byte(middleware.Token(r)), []byte(token)) {
+			next(w, r)
+			return
+		}
+		guarded(w, r)
+	}
+}
+
+// cached serves GET requests of next from the response cache, scoped per user and role.
+func (s *Server) cached(tag string, ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if !s.config.HTTPCacheEnabled {
+		return next
+	}
+
+	return s.responseCache.Wrap(tag, ttl, func(r *http.Request) string {
+		user, ok := middleware.User(r.Context())
+		if !ok {
+			return ""
+		}
+		return user.ID.Hex() + ":" + string(user.Role)
+	}, next)
+}
+
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown(ctx context.Context) error {
+	// End re-streams cleanly rather than leaving the targets to time out
+	s.egress.StopAll()
+	// Stop clips, then finish recordings and save them as recordings of
+	// their classes
+	s.clipPlayer.StopAll()
+	s.liveRecorder.StopAll()
+
+	if s.stopJobs != nil {
+		s.stopJobs()
+		// Write out buffered analytics, room events, chat and attendance
+		s.analytics.Wait(ctx)
+		s.roomEvents.Wait(ctx)
+		s.chatLog.Wait(ctx)
+		s.attendance.Wait(ctx)
+	}
+
 	log.Println("🔄 Shutting down HTTP server...")
 	if s.httpServer != nil {
 		if err := s.httpServer.Shutdown(ctx); err != nil {
@@ -448,6 +1881,10 @@ func (s *Server) Shutdown(ctx context.Context) error {
 		}
 	}
 
+	if s.accessLog != nil {
+		s.accessLog.Close()
+	}
+
 	log.Println("🔄 Closing database connections...")
 	if s.db != nil {
 		if err := s.db.Close(); err != nil {
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/pubsub"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sessionRevokedChannel is the pub/sub channel telling every instance about
+// signed-out sessions.
+const sessionRevokedChannel = "session:revoked"
+
+// SessionCloser closes the connections of signed-out sessions. With pub/sub
+// it tells the other instances too, so they stop accepting the sessions at
+// once instead of when their cached copies expire.
+type SessionCloser struct {
+	authService *auth.Service
+	userRepo    *repository.UserRepository
+	hub         *room.Hub
+	pubsub      *pubsub.RedisPubSub // nil on a single instance
+}
+
+// NewSessionCloser creates a SessionCloser, listening for sessions signed out
+// on other instances if ps is set.
+func NewSessionCloser(authService *auth.Service, userRepo *repository.UserRepository, hub *room.Hub, ps *pubsub.RedisPubSub) *SessionCloser {
+	c := &SessionCloser{authService: authService, userRepo: userRepo, hub: hub, pubsub: ps}
+	if ps != nil {
+		ps.Subscribe(sessionRevokedChannel, func(msg *pubsub.Message) {
+			c.authService.ForgetSession(msg.Target)
+			c.hub.CloseSession(msg.Target, msg.Payload)
+		})
+	}
+	return c
+}
+
+// Close sends a session-revoked message with text to the connections of a
+// signed-out session on every instance, then closes them.
+func (c *SessionCloser) Close(ctx context.Context, sessionID, text string) {
+	if sessionID == "" {
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "session-revoked",
+		"message": text,
+	})
+	c.hub.CloseSession(sessionID, data)
+
+	if c.pubsub == nil {
+		return
+	}
+	if err := c.pubsub.Publish(ctx, sessionRevokedChannel, &pubsub.Message{Type: "revoked", Target: sessionID, Payload: data}); err != nil {
+		log.Printf("⚠️ Failed to publish session revocation: %v", err)
+	}
+}
+
+// Suspend suspends a user's account and signs them out at once: their
+// sessions are revoked, so they can't refresh their tokens, and their live
+// connections are closed. suspendedBy is the admin suspending them, empty
+// for automated suspensions. Every path suspending users goes through it.
+func (c *SessionCloser) Suspend(ctx context.Context, userID, suspendedBy string) error {
+	if err := c.userRepo.UpdateStatus(ctx, userID, models.StatusSuspended, suspendedBy); err != nil {
+		return err
+	}
+
+	objectID, _ := primitive.ObjectIDFromHex(userID) // Valid, or UpdateStatus would have failed
+	signedOut, err := c.authService.SignOutUser(ctx, objectID, models.SessionRevokedSuspended)
+	if err != nil {
+		log.Printf("⚠️ Failed to sign out suspended user %s: %v", userID, err)
+	}
+	for _, session := range signedOut {
+		c.Close(ctx, session.ID.Hex(), "Your account has been suspended")
+	}
+	return nil
+}
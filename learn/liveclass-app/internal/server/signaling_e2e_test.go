@@ -0,0 +1,212 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/server"
+	"github.com/jinshatcp/brightline-academy/learn/internal/testsupport"
+)
+
+// joined is the part of a "joined" message the tests check.
+type joined struct {
+	RoomID        string `json:"roomId"`
+	ParticipantID string `json:"participantId"`
+	HasPresenter  bool   `json:"hasPresenter"`
+	RoomToken     string `json:"roomToken"`
+	Resumed       bool   `json:"resumed"`
+}
+
+// join dials the server and joins a room, failing the test if it can't.
+func join(t *testing.T, srv *testsupport.Server, roomID, token string, isPresenter bool) (*testsupport.Signal, joined) {
+	t.Helper()
+	ctx := testContext(t)
+
+	sig, err := srv.Dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { sig.Close() })
+
+	ev, err := sig.Join(ctx, roomID, token, isPresenter)
+	if err != nil {
+		t.Fatalf("join %s: %v", roomID, err)
+	}
+	var info joined
+	if err := ev.Decode(&info); err != nil {
+		t.Fatalf("decode joined: %v", err)
+	}
+	return sig, info
+}
+
+// chat sends a chat message over sig with the given room token.
+func chat(t *testing.T, sig *testsupport.Signal, roomToken, text string) {
+	t.Helper()
+	payload, _ := json.Marshal(text)
+	if err := sig.SendRaw(server.Message{Type: "chat", RoomToken: roomToken, Payload: payload}); err != nil {
+		t.Fatalf("send chat: %v", err)
+	}
+}
+
+func TestJoinLiveClass(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+	ctx := testContext(t)
+
+	presenter, presenterInfo := join(t, srv, class.roomID, class.presenter.Token, true)
+	if presenterInfo.RoomID != class.roomID || presenterInfo.ParticipantID == "" {
+		t.Fatalf("presenter joined room %q as %q, want room %s", presenterInfo.RoomID, presenterInfo.ParticipantID, class.roomID)
+	}
+	if presenterInfo.RoomToken == "" {
+		t.Fatal("presenter joined without a room token")
+	}
+
+	student, studentInfo := join(t, srv, class.roomID, class.student.Token, false)
+	if !studentInfo.HasPresenter {
+		t.Fatal("student joined a room without its presenter")
+	}
+	if studentInfo.RoomToken == "" || studentInfo.RoomToken == presenterInfo.RoomToken {
+		t.Fatal("student wasn't given a room token of their own")
+	}
+	if _, err := student.Expect(ctx, "waiting-for-stream"); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := presenter.Expect(ctx, "participant-joined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var participant struct {
+		Payload struct {
+			ID string `json:"id"`
+		} `json:"payload"`
+	}
+	if err := ev.Decode(&participant); err != nil {
+		t.Fatalf("decode participant-joined: %v", err)
+	}
+	if participant.Payload.ID != studentInfo.ParticipantID {
+		t.Fatalf("participant-joined for %q, want %q", participant.Payload.ID, studentInfo.ParticipantID)
+	}
+}
+
+func TestSecondPresenterIsRefused(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+	ctx := testContext(t)
+
+	join(t, srv, class.roomID, class.presenter.Token, true)
+
+	sig, err := srv.Dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer sig.Close()
+	_, err = sig.Join(ctx, class.roomID, class.student.Token, true)
+	if err == nil || !strings.Contains(err.Error(), "already has a presenter") {
+		t.Fatalf("student joining as presenter: got %v, want refused", err)
+	}
+}
+
+func TestSignedOutTokenCannotJoin(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+	ctx := testContext(t)
+
+	token := class.student.Token
+	if err := class.student.Do(ctx, http.MethodPost, "/api/auth/logout", nil, nil); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+
+	sig, err := srv.Dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer sig.Close()
+	if _, err := sig.Join(ctx, class.roomID, token, false); err == nil || !strings.Contains(err.Error(), "signed out") {
+		t.Fatalf("join with a signed out token: got %v, want refused", err)
+	}
+}
+
+func TestUnverifiedStudentCannotJoinProctoredClass(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClassWith(t, srv, func(req *testsupport.ScheduleRequest) { req.Proctored = true })
+	ctx := testContext(t)
+
+	schedule, err := class.student.GetSchedule(ctx, class.scheduleID)
+	if err != nil {
+		t.Fatalf("get schedule: %v", err)
+	}
+	if schedule.RoomID != "" {
+		t.Fatal("student was told the room of a proctored class")
+	}
+	if _, err := class.student.JoinClass(ctx, class.scheduleID); testsupport.StatusOf(err) != http.StatusForbidden {
+		t.Fatalf("unverified student joining: got %v, want 403", err)
+	}
+
+	// Knowing the room isn't enough, whichever role the student claims
+	for _, isPresenter := range []bool{false, true} {
+		sig, err := srv.Dial(ctx)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer sig.Close()
+		_, err = sig.Join(ctx, class.roomID, class.student.Token, isPresenter)
+		if err == nil || !strings.Contains(err.Error(), "Verify your identity") {
+			t.Fatalf("unverified student joining the room (presenter %v): got %v, want refused", isPresenter, err)
+		}
+	}
+
+	join(t, srv, class.roomID, class.presenter.Token, true)
+}
+
+func TestMessagesNeedRoomToken(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+	ctx := testContext(t)
+
+	presenter, presenterInfo := join(t, srv, class.roomID, class.presenter.Token, true)
+	student, studentInfo := join(t, srv, class.roomID, class.student.Token, false)
+
+	tests := []struct {
+		name      string
+		roomToken string
+		want      string
+	}{
+		{"missing", "", "Room token required"},
+		{"forged", "not-a-room-token", "Invalid room token"},
+		{"another participant's", presenterInfo.RoomToken, "Invalid room token"},
+	}
+	for _, tt := range tests {
+		chat(t, student, tt.roomToken, "refused")
+		ev, err := student.Expect(ctx, "error")
+		if err != nil {
+			t.Fatalf("%s room token: %v", tt.name, err)
+		}
+		var msg struct {
+			Message string `json:"message"`
+		}
+		ev.Decode(&msg)
+		if !strings.Contains(msg.Message, tt.want) {
+			t.Errorf("%s room token: error %q, want %q", tt.name, msg.Message, tt.want)
+		}
+	}
+
+	chat(t, student, student.RoomToken(), "accepted")
+	ev, err := presenter.Expect(ctx, "chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg struct {
+		Payload struct {
+			SenderID string `json:"senderId"`
+		} `json:"payload"`
+	}
+	if err := ev.Decode(&msg); err != nil {
+		t.Fatalf("decode chat: %v", err)
+	}
+	if msg.Payload.SenderID != studentInfo.ParticipantID {
+		t.Fatalf("chat from %q, want %q", msg.Payload.SenderID, studentInfo.ParticipantID)
+	}
+}
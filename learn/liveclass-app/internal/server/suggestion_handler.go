@@ -0,0 +1,196 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/scheduling"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schedule suggestion limits
+const (
+	defaultSuggestWindow = 14 * 24 * time.Hour
+	maxSuggestWindow     = 60 * 24 * time.Hour
+	defaultSuggestLimit  = 10
+	// Classes starting this long before the window can still overlap it
+	maxClassLength = 24 * time.Hour
+)
+
+// SuggestionHandler suggests times for new classes, e.g. make-up classes,
+// that fit the presenter's calendar and clash with as few students' other
+// classes as possible.
+type SuggestionHandler struct {
+	authService  *auth.Service
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	workingHours scheduling.WorkingHours
+}
+
+// NewSuggestionHandler creates a new SuggestionHandler suggesting slots
+// within workingHours.
+func NewSuggestionHandler(authService *auth.Service, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, workingHours scheduling.WorkingHours) *SuggestionHandler {
+	return &SuggestionHandler{
+		authService:  authService,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		workingHours: workingHours,
+	}
+}
+
+// Suggest returns open slots for a batch
+// (GET /api/schedules/suggest?batchId=&duration=&from=&to=&limit=).
+// duration is in minutes (default 60); from and to are RFC 3339 and default
+// to the next two weeks. Admin or the batch presenter only.
+func (h *SuggestionHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	req := struct {
+		BatchID  string `json:"batchId" validate:"required,objectid"`
+		Duration int    `json:"duration" validate:"min=15,max=480"`
+		From     string `json:"from" validate:"rfc3339"`
+		To       string `json:"to" validate:"rfc3339"`
+		Limit    int    `json:"limit" validate:"min=1,max=50"`
+	}{BatchID: query.Get("batchId"), Duration: 60, From: query.Get("from"), To: query.Get("to"), Limit: defaultSuggestLimit}
+	var err error
+	if v := query.Get("duration"); v != "" {
+		if req.Duration, err = strconv.Atoi(v); err != nil {
+			sendJSONError(w, "duration must be a number of minutes", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			sendJSONError(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	if !checkRequest(w, &req) {
+		return
+	}
+
+	from := time.Now().Truncate(time.Minute)
+	if req.From != "" {
+		from, _ = time.Parse(time.RFC3339, req.From)
+	}
+	to := from.Add(defaultSuggestWindow)
+	if req.To != "" {
+		to, _ = time.Parse(time.RFC3339, req.To)
+	}
+	if !to.After(from) || to.Sub(from) > maxSuggestWindow {
+		sendJSONError(w, "to must be after from and at most 60 days later", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.batchRepo.FindByID(r.Context(), req.BatchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+	if user.Role != models.RoleAdmin && batch.PresenterID != user.ID {
+		sendJSONError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	busy, err := h.busy(r, batch, from.Add(-maxClassLength), to)
+	if err != nil {
+		sendStoreError(w, "Failed to load schedules", err)
+		return
+	}
+
+	slots := scheduling.Suggest(h.workingHours, busy, scheduling.Request{
+		Duration: time.Duration(req.Duration) * time.Minute,
+		From:     from,
+		To:       to,
+		Limit:    req.Limit,
+		Students: len(batch.StudentIDs),
+	})
+	if slots == nil {
+		slots = []scheduling.Slot{}
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"batchId":  batch.ID.Hex(),
+		"timezone": h.workingHours.Location.String(),
+		"slots":    slots,
+	}, http.StatusOK)
+}
+
+// busy returns the classes between from and to that a new class of the batch
+// must avoid (the presenter's and the batch's own) or would clash with for
+// some students (those of other batches sharing students).
+func (h *SuggestionHandler) busy(r *http.Request, batch *models.Batch, from, to time.Time) ([]scheduling.Busy, error) {
+	var busy []scheduling.Busy
+	add := func(classes []models.ScheduledClass, blocks bool, students map[primitive.ObjectID][]string) {
+		for _, class := range classes {
+			if class.Status == models.ClassStatusCancelled {
+				continue
+			}
+			busy = append(busy, scheduling.Busy{
+				Start:    class.StartTime,
+				End:      class.EndTime,
+				Blocks:   blocks,
+				Students: students[class.BatchID],
+			})
+		}
+	}
+
+	presenterClasses, err := h.scheduleRepo.FindByPresenter(r.Context(), batch.PresenterID.Hex(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	add(presenterClasses, true, nil)
+
+	batchClasses, err := h.scheduleRepo.FindByBatch(r.Context(), batch.ID.Hex(), from, to)
+	if err != nil {
+		return nil, err
+	}
+	add(batchClasses, true, nil)
+
+	// Other batches sharing students, with the students they share
+	members := make(map[primitive.ObjectID]bool, len(batch.StudentIDs))
+	for _, id := range batch.StudentIDs {
+		members[id] = true
+	}
+	batches, err := h.batchRepo.FindAll(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	shared := make(map[primitive.ObjectID][]string)
+	var sharedIDs []string
+	for _, other := range batches {
+		if other.ID == batch.ID {
+			continue
+		}
+		for _, id := range other.StudentIDs {
+			if members[id] {
+				shared[other.ID] = append(shared[other.ID], id.Hex())
+			}
+		}
+		if len(shared[other.ID]) > 0 {
+			sharedIDs = append(sharedIDs, other.ID.Hex())
+		}
+	}
+	if len(sharedIDs) > 0 {
+		otherClasses, err := h.scheduleRepo.FindByBatches(r.Context(), sharedIDs, from, to)
+		if err != nil {
+			return nil, err
+		}
+		add(otherClasses, false, shared)
+	}
+
+	return busy, nil
+}
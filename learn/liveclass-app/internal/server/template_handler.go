@@ -0,0 +1,307 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/cohorts"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// templateRequest is the editable part of a class template. Objectives and
+// materials are limited to models.MaxTemplateObjectives and
+// models.MaxTemplateMaterials.
+type templateRequest struct {
+	Title           string                     `json:"title" validate:"required,max=200"`
+	Description     string                     `json:"description" validate:"max=2000"`
+	DurationMinutes int                        `json:"durationMinutes" validate:"min=1,max=720"`
+	Objectives      []models.LearningObjective `json:"objectives" validate:"max=20"`
+	Materials       []models.ClassMaterial     `json:"materials" validate:"max=20"`
+}
+
+// TemplateHandler handles the class template library.
+type TemplateHandler struct {
+	authService  *auth.Service
+	templateRepo *repository.ClassTemplateRepository
+	scheduleRepo *repository.ScheduleRepository
+	batchRepo    *repository.BatchRepository
+	userRepo     *repository.UserRepository
+	rollupRepo   *repository.ClassRollupRepository
+}
+
+// NewTemplateHandler creates a new TemplateHandler.
+func NewTemplateHandler(authService *auth.Service, templateRepo *repository.ClassTemplateRepository, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, rollupRepo *repository.ClassRollupRepository) *TemplateHandler {
+	return &TemplateHandler{
+		authService:  authService,
+		templateRepo: templateRepo,
+		scheduleRepo: scheduleRepo,
+		batchRepo:    batchRepo,
+		userRepo:     userRepo,
+		rollupRepo:   rollupRepo,
+	}
+}
+
+// Templates lists the caller's templates (GET /api/templates; admins see all)
+// or creates one (POST /api/templates).
+func (h *TemplateHandler) Templates(w http.ResponseWriter, r *http.Request) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var templates []models.ClassTemplate
+		var err error
+		if user.Role == models.RoleAdmin {
+			templates, err = h.templateRepo.FindAll(r.Context())
+		} else {
+			templates, err = h.templateRepo.FindByPresenter(r.Context(), user.ID)
+		}
+		if err != nil {
+			sendStoreError(w, "Failed to fetch templates", err)
+			return
+		}
+		sendJSON(w, map[string]interface{}{"templates": templates}, http.StatusOK)
+
+	case http.MethodPost:
+		var req templateRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		template := &models.ClassTemplate{PresenterID: user.ID}
+		applyTemplateRequest(template, req)
+
+		if err := h.templateRepo.Create(r.Context(), template); err != nil {
+			sendStoreError(w, "Failed to create template", err)
+			return
+		}
+		sendJSON(w, template, http.StatusCreated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Template returns, updates or deletes a template (GET/PUT/DELETE /api/templates/{id}).
+// Presenters can only manage their own templates.
+func (h *TemplateHandler) Template(w http.ResponseWriter, r *http.Request) {
+	_, template, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, template, http.StatusOK)
+
+	case http.MethodPut:
+		var req templateRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		applyTemplateRequest(template, req)
+		if err := h.templateRepo.Update(r.Context(), template); err != nil {
+			sendStoreError(w, "Failed to update template", err)
+			return
+		}
+		sendJSON(w, template, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := h.templateRepo.Delete(r.Context(), template.ID); err != nil {
+			sendStoreError(w, "Failed to delete template", err)
+			return
+		}
+		sendJSON(w, map[string]string{"message": "Template deleted"}, http.StatusOK)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ScheduleFromTemplate schedules a class from a template
+// (POST /api/templates/{id}/schedule). The class gets a copy of the template's
+// objectives and materials; the end time defaults to the template duration.
+func (h *TemplateHandler) ScheduleFromTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, template, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BatchID     string `json:"batchId" validate:"required,objectid"`
+		StartTime   string `json:"startTime" validate:"required,rfc3339"`
+		EndTime     string `json:"endTime" validate:"rfc3339"` // Defaults to start + duration
+		Title       string `json:"title" validate:"max=200"`
+		Description string `json:"description" validate:"max=2000"`
+		Proctored   bool   `json:"proctored"`
+		ExamMode    bool   `json:"examMode"`
+		LateEntry   int    `json:"lateEntryMinutes" validate:"min=0,max=1440"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	startTime, _ := time.Parse(time.RFC3339, req.StartTime)
+	endTime := startTime.Add(template.Duration())
+	if req.EndTime != "" {
+		endTime, _ = time.Parse(time.RFC3339, req.EndTime)
+	}
+	if !endTime.After(startTime) {
+		sendJSONError(w, "End time must be after start time", http.StatusBadRequest)
+		return
+	}
+
+	batch, err := h.batchRepo.FindByID(r.Context(), req.BatchID)
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusBadRequest)
+		return
+	}
+	if user.Role == models.RolePresenter && batch.PresenterID != user.ID {
+		sendJSONError(w, "You can only schedule classes for your own batches", http.StatusForbidden)
+		return
+	}
+
+	schedule := &models.ScheduledClass{
+		Title:       firstNonEmpty(req.Title, template.Title),
+		Description: richtext.Rich.Sanitize(firstNonEmpty(req.Description, template.Description)),
+		BatchID:     batch.ID,
+		PresenterID: batch.PresenterID,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Proctored:   req.Proctored,
+		ExamMode:    req.ExamMode,
+		LateEntry:   req.LateEntry,
+		TemplateID:  template.ID,
+		Objectives:  template.Objectives,
+		Materials:   template.Materials,
+
+		BatchName:     batch.Name,
+		PresenterName: newNameLookup(r.Context(), h.batchRepo, h.userRepo).User(batch.PresenterID, batch.PresenterName),
+	}
+
+	if err := h.scheduleRepo.Create(r.Context(), schedule); err != nil {
+		sendStoreError(w, "Failed to create schedule", err)
+		return
+	}
+
+	sendJSON(w, schedule.ToResponse(), http.StatusCreated)
+}
+
+// Cohorts compares the batches that ran a template's classes
+// (GET /api/templates/{id}/cohorts), from the rollups of classes that have
+// ended. Cohorts attending well below the average are flagged.
+func (h *TemplateHandler) Cohorts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, template, ok := h.loadTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	rollups, err := h.rollupRepo.FindByTemplate(r.Context(), template.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch class rollups", err)
+		return
+	}
+
+	stats, average := cohorts.Compare(rollups)
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
+	for i := range stats {
+		id, _ := primitive.ObjectIDFromHex(stats[i].BatchID)
+		stats[i].BatchName = names.Batch(id, "")
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"templateId":            template.ID.Hex(),
+		"title":                 template.Title,
+		"cohorts":               stats,
+		"averageAttendanceRate": average,
+	}, http.StatusOK)
+}
+
+// loadTemplate loads the template named in the URL and checks the caller may
+// manage it. It writes the error response on failure.
+func (h *TemplateHandler) loadTemplate(w http.ResponseWriter, r *http.Request) (*models.User, *models.ClassTemplate, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// Extract template ID from URL: /api/templates/{id}[/schedule|/cohorts]
+	templateID := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/templates/"), "/")[0]
+
+	template, err := h.templateRepo.FindByID(r.Context(), templateID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			sendJSONError(w, "Template not found", http.StatusNotFound)
+			return nil, nil, false
+		}
+		sendJSONError(w, "Failed to fetch template", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if user.Role != models.RoleAdmin && template.PresenterID != user.ID {
+		sendJSONError(w, "You can only manage your own templates", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return user, template, true
+}
+
+// applyTemplateRequest copies a validated req onto template. Objectives that
+// keep the ID of one of the template's existing objectives keep that ID; new
+// ones get a fresh ID.
+func applyTemplateRequest(template *models.ClassTemplate, req templateRequest) {
+	existing := make(map[string]bool, len(template.Objectives))
+	for _, o := range template.Objectives {
+		existing[o.ID] = true
+	}
+
+	objectives := make([]models.LearningObjective, 0, len(req.Objectives))
+	for _, o := range req.Objectives {
+		id := o.ID
+		if !existing[id] {
+			id = uuid.New().String()[:8]
+		}
+		existing[id] = false // Each ID may only be used once
+		objectives = append(objectives, models.LearningObjective{ID: id, Text: strings.TrimSpace(o.Text)})
+	}
+
+	materials := make([]models.ClassMaterial, 0, len(req.Materials))
+	for _, m := range req.Materials {
+		materials = append(materials, models.ClassMaterial{Title: strings.TrimSpace(m.Title), URL: m.URL})
+	}
+
+	template.Title = strings.TrimSpace(req.Title)
+	template.Description = richtext.Rich.Sanitize(strings.TrimSpace(req.Description))
+	template.DurationMinutes = req.DurationMinutes
+	template.Objectives = objectives
+	template.Materials = materials
+}
+
+// firstNonEmpty returns the first non-blank value.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
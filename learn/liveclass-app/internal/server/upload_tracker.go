@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// Upload stages, in order. An upload ends in UploadComplete or UploadFailed.
+const (
+	UploadReceiving  = "receiving"  // Request body is arriving
+	UploadValidating = "validating" // Form, class and file type are checked
+	UploadProcessing = "processing" // File is written to storage
+	UploadComplete   = "complete"
+	UploadFailed     = "failed"
+)
+
+const (
+	// uploadEventInterval limits how often byte progress is pushed to the uploader.
+	uploadEventInterval = time.Second
+	// uploadRetention is how long finished uploads stay queryable.
+	uploadRetention = 15 * time.Minute
+)
+
+// uploadIDPattern restricts client-chosen upload IDs.
+var uploadIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// UploadStatus is the progress of an upload, returned by the status API and
+// pushed as "upload_progress" WebSocket events.
+type UploadStatus struct {
+	ID             string    `json:"uploadId"`
+	Stage          string    `json:"stage"`
+	BytesReceived  int64     `json:"bytesReceived"`
+	TotalBytes     int64     `json:"totalBytes,omitempty"` // Request size, when the client sent it
+	BytesProcessed int64     `json:"bytesProcessed"`
+	FileSize       int64     `json:"fileSize,omitempty"`
+	RecordingID    string    `json:"recordingId,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	StartedAt      time.Time `json:"startedAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// uploadSession is an upload in flight or recently finished.
+type uploadSession struct {
+	tracker  *uploadTracker
+	userID   string
+	mu       sync.Mutex
+	status   UploadStatus
+	lastSent time.Time
+}
+
+// uploadTracker keeps upload sessions in memory. Sessions live on the instance
+// receiving the upload, so status requests need the same instance (as with the
+// WebSocket the events are delivered on).
+type uploadTracker struct {
+	hub *room.Hub
+
+	mu       sync.Mutex
+	sessions map[string]*uploadSession
+}
+
+// newUploadTracker creates a tracker delivering events through hub.
+func newUploadTracker(hub *room.Hub) *uploadTracker {
+	return &uploadTracker{
+		hub:      hub,
+		sessions: make(map[string]*uploadSession),
+	}
+}
+
+// Start begins tracking an upload. id is the client's X-Upload-ID, letting it
+// poll before the upload response arrives; a new ID is generated when it is
+// missing, invalid or already in use.
+func (t *uploadTracker) Start(id, userID string, totalBytes int64) *uploadSession {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Drop finished sessions past retention
+	for key, s := range t.sessions {
+		s.mu.Lock()
+		expired := s.finished() && now.Sub(s.status.UpdatedAt) > uploadRetention
+		s.mu.Unlock()
+		if expired {
+			delete(t.sessions, key)
+		}
+	}
+
+	if _, taken := t.sessions[id]; taken || !uploadIDPattern.MatchString(id) {
+		id = uuid.New().String()
+	}
+	if totalBytes < 0 {
+		totalBytes = 0
+	}
+
+	s := &uploadSession{
+		tracker: t,
+		userID:  userID,
+		status: UploadStatus{
+			ID:         id,
+			Stage:      UploadReceiving,
+			TotalBytes: totalBytes,
+			StartedAt:  now,
+			UpdatedAt:  now,
+		},
+	}
+	t.sessions[id] = s
+	s.publish()
+	return s
+}
+
+// Get returns the status of an upload owned by userID.
+func (t *uploadTracker) Get(id, userID string, isAdmin bool) (UploadStatus, bool) {
+	t.mu.Lock()
+	s, ok := t.sessions[id]
+	t.mu.Unlock()
+	if !ok || (!isAdmin && s.userID != userID) {
+		return UploadStatus{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status, true
+}
+
+// ID returns the upload ID.
+func (s *uploadSession) ID() string {
+	return s.status.ID
+}
+
+// Body wraps a request body to count bytes received.
+func (s *uploadSession) Body(body io.ReadCloser) io.ReadCloser {
+	return &countingBody{ReadCloser: body, add: func(n int64) {
+		s.update(false, func(st *UploadStatus) { st.BytesReceived += n })
+	}}
+}
+
+// Writer wraps the storage writer to count bytes processed.
+func (s *uploadSession) Writer(w io.Writer) io.Writer {
+	return &countingWriter{Writer: w, add: func(n int64) {
+		s.update(false, func(st *UploadStatus) { st.BytesProcessed += n })
+	}}
+}
+
+// Stage moves the upload to a new stage.
+func (s *uploadSession) Stage(stage string) {
+	s.update(true, func(st *UploadStatus) { st.Stage = stage })
+}
+
+// SetFileSize records the size of the uploaded file once it is known.
+func (s *uploadSession) SetFileSize(size int64) {
+	s.update(false, func(st *UploadStatus) { st.FileSize = size })
+}
+
+// Complete marks the upload as done.
+func (s *uploadSession) Complete(recordingID string) {
+	s.update(true, func(st *UploadStatus) {
+		st.Stage = UploadComplete
+		st.RecordingID = recordingID
+	})
+}
+
+// Fail marks the upload as failed with the message sent to the client.
+func (s *uploadSession) Fail(msg string) {
+	s.update(true, func(st *UploadStatus) {
+		if st.Stage != UploadComplete {
+			st.Stage = UploadFailed
+			st.Error = msg
+		}
+	})
+}
+
+// finished reports whether the upload has ended. Callers hold s.mu.
+func (s *uploadSession) finished() bool {
+	return s.status.Stage == UploadComplete || s.status.Stage == UploadFailed
+}
+
+// update applies fn and pushes an event on stage changes (force) or when
+// uploadEventInterval has passed since the last one.
+func (s *uploadSession) update(force bool, fn func(*UploadStatus)) {
+	s.mu.Lock()
+	if s.finished() {
+		s.mu.Unlock()
+		return
+	}
+	fn(&s.status)
+	now := time.Now()
+	s.status.UpdatedAt = now
+	send := force || now.Sub(s.lastSent) >= uploadEventInterval
+	s.mu.Unlock()
+
+	if send {
+		s.publish()
+	}
+}
+
+// publish sends the current status to the uploader's live connections.
+func (s *uploadSession) publish() {
+	s.mu.Lock()
+	s.lastSent = time.Now()
+	status := s.status
+	s.mu.Unlock()
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":    "upload_progress",
+		"payload": status,
+	})
+	s.tracker.hub.SendToUser(s.userID, data)
+}
+
+// countingBody reports bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	add func(int64)
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		c.add(int64(n))
+	}
+	return n, err
+}
+
+// countingWriter reports bytes written.
+type countingWriter struct {
+	io.Writer
+	add func(int64)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.Writer.Write(p)
+	if n > 0 {
+		c.add(int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,481 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/verification"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxVerificationPhotoSize is the largest accepted identity photo.
+const maxVerificationPhotoSize = 5 << 20
+
+// VerificationHandler handles identity verification for proctored classes.
+type VerificationHandler struct {
+	authService      *auth.Service
+	verificationRepo *repository.VerificationRepository
+	scheduleRepo     *repository.ScheduleRepository
+	batchRepo        *repository.BatchRepository
+	userRepo         *repository.UserRepository
+	providers        *verification.Registry
+	storagePath      string
+}
+
+// NewVerificationHandler creates a new VerificationHandler.
+func NewVerificationHandler(authService *auth.Service, verificationRepo *repository.VerificationRepository, scheduleRepo *repository.ScheduleRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, providers *verification.Registry, storagePath string) *VerificationHandler {
+	// Ensure verification photo directory exists
+	if err := os.MkdirAll(filepath.Join(storagePath, "verifications"), 0755); err != nil {
+		log.Printf("[Verification] Warning: Failed to create storage directory: %v", err)
+	}
+
+	return &VerificationHandler{
+		authService:      authService,
+		verificationRepo: verificationRepo,
+		scheduleRepo:     scheduleRepo,
+		batchRepo:        batchRepo,
+		userRepo:         userRepo,
+		providers:        providers,
+		storagePath:      storagePath,
+	}
+}
+
+// CanJoin reports whether a user may join a room as a participant. Students
+// of a proctored class must have had their identity verified; rooms without
+// a class are open. A class that can't be looked up keeps everyone out.
+func (h *VerificationHandler) CanJoin(ctx context.Context, roomID string, claims *auth.Claims) bool {
+	schedule, err := h.scheduleRepo.FindByRoomID(ctx, strings.ToUpper(roomID))
+	if errors.Is(err, repository.ErrScheduleNotFound) {
+		return true
+	}
+	if err != nil {
+		log.Printf("[Verification] Failed to look up the class in room %s: %v", roomID, err)
+		return false
+	}
+	if !schedule.Proctored {
+		return true
+	}
+	if claims == nil {
+		return false
+	}
+	if claims.Role != models.RoleStudent {
+		return true
+	}
+
+	studentID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return false
+	}
+	v, err := h.verificationRepo.FindByScheduleAndStudent(ctx, schedule.ID, studentID)
+	return err == nil && v.IsVerified()
+}
+
+// GetMyVerification returns the caller's verification for a class
+// (GET /api/schedules/{id}/verification).
+func (h *VerificationHandler) GetMyVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, schedule, ok := h.loadSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	v, err := h.verificationRepo.FindByScheduleAndStudent(r.Context(), schedule.ID, user.ID)
+	if err != nil {
+		sendJSON(w, map[string]interface{}{
+			"proctored": schedule.Proctored,
+			"status":    "not-started",
+		}, http.StatusOK)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"proctored":    schedule.Proctored,
+		"status":       v.Status,
+		"verification": v,
+	}, http.StatusOK)
+}
+
+// StartProviderVerification starts a verification with an external provider and
+// returns the reference the client hands to the provider
+// (POST /api/schedules/{id}/verification {"provider": "..."}).
+func (h *VerificationHandler) StartProviderVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, schedule, ok := h.loadStudentSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Provider string `json:"provider" validate:"required,max=50"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if _, exists := h.providers.Get(req.Provider); !exists {
+		sendJSONError(w, "Unknown verification provider", http.StatusBadRequest)
+		return
+	}
+
+	v := &models.IdentityVerification{
+		ScheduleID: schedule.ID,
+		StudentID:  user.ID,
+		Method:     models.VerificationMethodProvider,
+		Status:     models.VerificationPending,
+		Provider:   req.Provider,
+		Reference:  uuid.New().String(),
+	}
+	if err := h.verificationRepo.Save(r.Context(), v); err != nil {
+		sendStoreError(w, "Failed to start verification", err)
+		return
+	}
+
+	sendJSON(w, v, http.StatusCreated)
+}
+
+// UploadPhoto stores an identity photo captured by the student
+// (POST /api/schedules/{id}/verification/photo, multipart field "photo").
+// A submitted photo counts as verified unless the presenter rejects it.
+func (h *VerificationHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, schedule, ok := h.loadStudentSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVerificationPhotoSize+1<<10)
+	if err := r.ParseMultipartForm(maxVerificationPhotoSize); err != nil {
+		sendJSONError(w, "Photo too large or invalid form", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		sendJSONError(w, "No photo uploaded", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	ext, allowed := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/webp": ".webp",
+	}[header.Header.Get("Content-Type")]
+	if !allowed {
+		sendJSONError(w, "Photo must be JPEG, PNG or WebP", http.StatusBadRequest)
+		return
+	}
+
+	fileName := schedule.ID.Hex() + "_" + user.ID.Hex() + "_" + time.Now().Format("20060102_150405") + ext
+	filePath := filepath.Join(h.storagePath, "verifications", fileName)
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		log.Printf("[Verification] Failed to create file: %v", err)
+		sendStoreError(w, "Failed to save photo", err)
+		return
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		log.Printf("[Verification] Failed to save photo: %v", err)
+		os.Remove(filePath)
+		sendJSONError(w, "Failed to save photo", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	v := &models.IdentityVerification{
+		ScheduleID: schedule.ID,
+		StudentID:  user.ID,
+		Method:     models.VerificationMethodPhoto,
+		Status:     models.VerificationVerified,
+		PhotoPath:  filePath,
+		VerifiedAt: &now,
+	}
+
+	// Replace any earlier photo
+	if previous, err := h.verificationRepo.FindByScheduleAndStudent(r.Context(), schedule.ID, user.ID); err == nil && previous.PhotoPath != "" {
+		os.Remove(previous.PhotoPath)
+	}
+
+	if err := h.verificationRepo.Save(r.Context(), v); err != nil {
+		os.Remove(filePath)
+		sendJSONError(w, "Failed to save verification", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Verification] Photo submitted by %s for class %s", user.Name, schedule.Title)
+
+	sendJSON(w, v, http.StatusCreated)
+}
+
+// ListVerifications returns every enrolled student's verification state for a
+// class (GET /api/schedules/{id}/verifications). Admin or the class presenter only.
+func (h *VerificationHandler) ListVerifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, schedule, ok := h.loadPresenterSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil {
+		sendJSONError(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+
+	verifications, err := h.verificationRepo.FindBySchedule(r.Context(), schedule.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch verifications", err)
+		return
+	}
+	byStudent := make(map[primitive.ObjectID]models.IdentityVerification, len(verifications))
+	for _, v := range verifications {
+		byStudent[v.StudentID] = v
+	}
+
+	type entry struct {
+		StudentID    string                       `json:"studentId"`
+		StudentName  string                       `json:"studentName"`
+		Status       string                       `json:"status"`
+		HasPhoto     bool                         `json:"hasPhoto"`
+		Verification *models.IdentityVerification `json:"verification,omitempty"`
+	}
+
+	response := make([]entry, 0, len(batch.StudentIDs))
+	for _, studentID := range batch.StudentIDs {
+		e := entry{StudentID: studentID.Hex(), Status: "not-started"}
+		if student, err := h.userRepo.FindByID(r.Context(), studentID.Hex()); err == nil {
+			e.StudentName = student.Name
+		}
+		if v, ok := byStudent[studentID]; ok {
+			e.Status = string(v.Status)
+			e.HasPhoto = v.PhotoPath != ""
+			e.Verification = &v
+		}
+		response = append(response, e)
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"proctored":     schedule.Proctored,
+		"verifications": response,
+	}, http.StatusOK)
+}
+
+// GetPhoto serves a student's identity photo
+// (GET /api/schedules/{id}/verifications/{studentId}/photo). Admin or the class presenter only.
+func (h *VerificationHandler) GetPhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, schedule, ok := h.loadPresenterSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	v, ok := h.studentVerification(w, r, schedule)
+	if !ok {
+		return
+	}
+	if v.PhotoPath == "" {
+		sendJSONError(w, "No photo submitted", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeFile(w, r, v.PhotoPath)
+}
+
+// ReviewVerification lets the presenter approve or reject a verification
+// (PUT /api/schedules/{id}/verifications/{studentId} {"status": "...", "reason": "..."}).
+func (h *VerificationHandler) ReviewVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, schedule, ok := h.loadPresenterSchedule(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status models.VerificationStatus `json:"status" validate:"required,oneof=verified rejected"`
+		Reason string                    `json:"reason" validate:"max=500"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	v, ok := h.studentVerification(w, r, schedule)
+	if !ok {
+		return
+	}
+
+	if err := h.verificationRepo.UpdateStatus(r.Context(), v.ID, req.Status, req.Reason, user.ID); err != nil {
+		sendStoreError(w, "Failed to update verification", err)
+		return
+	}
+
+	log.Printf("[Verification] %s marked student %s as %s for class %s", user.Name, v.StudentID.Hex(), req.Status, schedule.Title)
+
+	sendJSON(w, map[string]string{"message": "Verification updated"}, http.StatusOK)
+}
+
+// Webhook receives results from an external provider
+// (POST /api/verification/webhook/{provider}). Requests are authenticated by the provider.
+func (h *VerificationHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/verification/webhook/")
+	provider, exists := h.providers.Get(name)
+	if !exists {
+		sendJSONError(w, "Unknown verification provider", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		sendJSONError(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := provider.ParseWebhook(r.Header, body)
+	if err != nil {
+		log.Printf("[Verification] Rejected %s webhook: %v", name, err)
+		sendJSONError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	v, err := h.verificationRepo.FindByReference(r.Context(), name, result.Reference)
+	if err != nil {
+		sendJSONError(w, "Unknown reference", http.StatusNotFound)
+		return
+	}
+
+	status := models.VerificationRejected
+	if result.Verified {
+		status = models.VerificationVerified
+	}
+	if err := h.verificationRepo.UpdateStatus(r.Context(), v.ID, status, result.Reason, primitive.NilObjectID); err != nil {
+		sendStoreError(w, "Failed to update verification", err)
+		return
+	}
+
+	log.Printf("[Verification] %s reported %s for reference %s", name, status, result.Reference)
+
+	sendJSON(w, map[string]string{"status": string(status)}, http.StatusOK)
+}
+
+// loadSchedule authenticates the caller and loads the schedule from the URL.
+func (h *VerificationHandler) loadSchedule(w http.ResponseWriter, r *http.Request) (*models.User, *models.ScheduledClass, bool) {
+	user, ok := requestUser(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// Extract schedule ID from URL: /api/schedules/{id}/verification...
+	path := strings.TrimPrefix(r.URL.Path, "/api/schedules/")
+	scheduleID := strings.Split(path, "/")[0]
+
+	schedule, err := h.scheduleRepo.FindByID(r.Context(), scheduleID)
+	if err != nil {
+		sendJSONError(w, "Schedule not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return user, schedule, true
+}
+
+// loadStudentSchedule loads a proctored schedule for an enrolled student.
+func (h *VerificationHandler) loadStudentSchedule(w http.ResponseWriter, r *http.Request) (*models.User, *models.ScheduledClass, bool) {
+	user, schedule, ok := h.loadSchedule(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !schedule.Proctored {
+		sendJSONError(w, "This class does not require verification", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	batch, err := h.batchRepo.FindByID(r.Context(), schedule.BatchID.Hex())
+	if err != nil || !batch.HasStudent(user.ID.Hex()) {
+		sendJSONError(w, "You are not enrolled in this class", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return user, schedule, true
+}
+
+// loadPresenterSchedule loads a schedule for an admin or its presenter.
+func (h *VerificationHandler) loadPresenterSchedule(w http.ResponseWriter, r *http.Request) (*models.User, *models.ScheduledClass, bool) {
+	user, schedule, ok := h.loadSchedule(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if user.Role != models.RoleAdmin && schedule.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the class presenter can view verifications", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return user, schedule, true
+}
+
+// studentVerification loads the verification for the student in the URL:
+// /api/schedules/{id}/verifications/{studentId}[/photo].
+func (h *VerificationHandler) studentVerification(w http.ResponseWriter, r *http.Request, schedule *models.ScheduledClass) (*models.IdentityVerification, bool) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	if len(parts) < 3 {
+		sendJSONError(w, "Student ID required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	studentID, err := primitive.ObjectIDFromHex(parts[2])
+	if err != nil {
+		sendJSONError(w, "Invalid student ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	v, err := h.verificationRepo.FindByScheduleAndStudent(r.Context(), schedule.ID, studentID)
+	if err != nil {
+		sendJSONError(w, "Verification not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return v, true
+}
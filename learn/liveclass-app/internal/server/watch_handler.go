@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/middleware"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Heartbeat checks. Players are expected to send a heartbeat every 10-15
+// seconds while playing.
+const (
+	maxHeartbeatGap = time.Minute // A longer gap starts a new run of playback
+	maxPlaybackRate = 2.0         // Faster position changes are seeks
+	positionSlack   = 2.0         // Seconds of drift between position and clock
+)
+
+// WatchHandler measures how much of a recording students actually watch,
+// from heartbeats the player sends while playing.
+type WatchHandler struct {
+	authService       *auth.Service
+	recordingRepo     *repository.RecordingRepository
+	batchRepo         *repository.BatchRepository
+	userRepo          *repository.UserRepository
+	watchRepo         *repository.WatchProgressRepository
+	goals             *GoalHandler
+	completionPercent float64
+}
+
+// NewWatchHandler creates a new WatchHandler. A recording is completed once
+// completionPercent of it was watched.
+func NewWatchHandler(authService *auth.Service, recordingRepo *repository.RecordingRepository, batchRepo *repository.BatchRepository, userRepo *repository.UserRepository, watchRepo *repository.WatchProgressRepository, goals *GoalHandler, completionPercent int) *WatchHandler {
+	return &WatchHandler{
+		authService:       authService,
+		recordingRepo:     recordingRepo,
+		batchRepo:         batchRepo,
+		userRepo:          userRepo,
+		watchRepo:         watchRepo,
+		goals:             goals,
+		completionPercent: float64(completionPercent),
+	}
+}
+
+// Heartbeat records the playback position of a student watching a recording
+// (POST /api/recordings/{id}/heartbeat with {"position": seconds}). The time
+// since the previous heartbeat is credited only if the position moved on as
+// playback would; pauses, seeks and gaps between sessions aren't. Heartbeats
+// from other roles are accepted but not tracked.
+func (h *WatchHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Position float64 `json:"position" validate:"min=0"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	recording, ok := h.recording(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleStudent {
+		sendJSON(w, map[string]bool{"tracked": false}, http.StatusOK)
+		return
+	}
+	batch, err := h.batchRepo.FindByID(r.Context(), recording.BatchID.Hex())
+	if err != nil || !batch.HasStudent(user.ID.Hex()) || !recording.AllowsStudent(user.ID) {
+		sendJSONError(w, "Access denied", http.StatusForbidden)
+		return
+	}
+	if recording.Duration > 0 && req.Position > float64(recording.Duration)+positionSlack {
+		sendJSONError(w, "position is past the end of the recording", http.StatusBadRequest)
+		return
+	}
+
+	progress, err := h.watchRepo.Find(r.Context(), user.ID, recording.ID)
+	if errors.Is(err, repository.ErrWatchProgressNotFound) {
+		progress = &models.WatchProgress{UserID: user.ID, RecordingID: recording.ID, Watched: []models.WatchedRange{}}
+	} else if err != nil {
+		sendStoreError(w, "Failed to load watch progress", err)
+		return
+	}
+
+	previous := progress.HeartbeatAt
+	now := time.Now()
+	if start, end, ok := verifiedPlayback(progress, req.Position, now); ok {
+		progress.WatchedSeconds += math.Min(end-start, now.Sub(previous).Seconds())
+		progress.AddWatched(start, end)
+	}
+	progress.Position = req.Position
+	progress.HeartbeatAt = now
+
+	completed := !progress.Completed && progress.Coverage(recording.Duration) >= h.completionPercent
+	if completed {
+		progress.Completed = true
+		progress.CompletedAt = &now
+	}
+
+	if err := h.watchRepo.Save(r.Context(), progress, previous); err != nil {
+		if errors.Is(err, repository.ErrWatchProgressChanged) {
+			sendJSONError(w, "Another player is sending heartbeats for this recording", http.StatusConflict)
+			return
+		}
+		log.Printf("[Watch] Failed to save progress of %s on %s: %v", user.ID.Hex(), recording.ID.Hex(), err)
+		sendJSONError(w, "Failed to save watch progress", http.StatusInternalServerError)
+		return
+	}
+
+	if completed {
+		log.Printf("[Watch] %s completed recording %s", user.Name, recording.Title)
+		h.goals.RecordWatch(r.Context(), user, recording.ID.Hex())
+	}
+
+	sendJSON(w, progress.Summary(recording.Duration), http.StatusOK)
+}
+
+// Progress returns the caller's progress through a recording
+// (GET /api/recordings/{id}/progress).
+func (h *WatchHandler) Progress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	recording, ok := h.recording(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := h.watchRepo.Find(r.Context(), user.ID, recording.ID)
+	if errors.Is(err, repository.ErrWatchProgressNotFound) {
+		progress = &models.WatchProgress{}
+	} else if err != nil {
+		sendStoreError(w, "Failed to load watch progress", err)
+		return
+	}
+
+	sendJSON(w, progress.Summary(recording.Duration), http.StatusOK)
+}
+
+// StudentEngagement is a student's engagement with a recording.
+type StudentEngagement struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	models.WatchSummary
+	LastWatchedAt time.Time `json:"lastWatchedAt"`
+}
+
+// Engagement returns how much of a recording each student watched, with
+// totals (GET /api/recordings/{id}/engagement). Admin or the presenter only.
+func (h *WatchHandler) Engagement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := requestUser(w, r)
+	if !ok {
+		return
+	}
+
+	recording, ok := h.recording(w, r)
+	if !ok {
+		return
+	}
+	if user.Role != models.RoleAdmin && recording.PresenterID != user.ID {
+		sendJSONError(w, "Only admin or the presenter can view engagement", http.StatusForbidden)
+		return
+	}
+
+	entries, err := h.watchRepo.FindByRecording(r.Context(), recording.ID)
+	if err != nil {
+		sendStoreError(w, "Failed to load engagement", err)
+		return
+	}
+
+	names := newNameLookup(r.Context(), h.batchRepo, h.userRepo)
+	students := make([]StudentEngagement, 0, len(entries))
+	completed := 0
+	var totalPercent, totalSeconds float64
+	for _, entry := range entries {
+		summary := entry.Summary(recording.Duration)
+		students = append(students, StudentEngagement{
+			UserID:        entry.UserID.Hex(),
+			Name:          names.User(entry.UserID, ""),
+			WatchSummary:  *summary,
+			LastWatchedAt: entry.HeartbeatAt,
+		})
+		if summary.Completed {
+			completed++
+		}
+		totalPercent += summary.Percent
+		totalSeconds += summary.WatchedSeconds
+	}
+	sort.Slice(students, func(i, j int) bool { return students[i].Percent > students[j].Percent })
+
+	response := map[string]interface{}{
+		"recordingId":       recording.ID.Hex(),
+		"duration":          recording.Duration,
+		"completionPercent": h.completionPercent,
+		"viewers":           len(students),
+		"completed":         completed,
+		"students":          students,
+	}
+	if len(students) > 0 {
+		response["averagePercent"] = math.Round(totalPercent/float64(len(students))*10) / 10
+		response["averageWatchedSeconds"] = math.Round(totalSeconds / float64(len(students)))
+	}
+	sendJSON(w, response, http.StatusOK)
+}
+
+// ProgressFor returns a student's progress through their recordings by
+// recording ID, for listing recordings.
+func (h *WatchHandler) ProgressFor(ctx context.Context, user *models.User) map[primitive.ObjectID]models.WatchProgress {
+	if user.Role != models.RoleStudent {
+		return nil
+	}
+	entries, err := h.watchRepo.FindByUser(ctx, user.ID)
+	if err != nil {
+		log.Printf("[Watch] Failed to load progress of %s: %v", user.ID.Hex(), err)
+		return nil
+	}
+	progress := make(map[primitive.ObjectID]models.WatchProgress, len(entries))
+	for _, entry := range entries {
+		progress[entry.RecordingID] = entry
+	}
+	return progress
+}
+
+// recording loads the recording from /api/recordings/{id}/..., writing the
+// error response if it can't. Hidden recordings are only available to admins.
+func (h *WatchHandler) recording(w http.ResponseWriter, r *http.Request) (*models.Recording, bool) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/recordings/")
+	recording, err := h.recordingRepo.FindByID(r.Context(), strings.Split(path, "/")[0])
+	if err != nil {
+		sendJSONError(w, "Recording not found", http.StatusNotFound)
+		return nil, false
+	}
+	if recording.Hidden {
+		if user, ok := middleware.User(r.Context()); !ok || user.Role != models.RoleAdmin {
+			sendJSONError(w, "This recording is hidden pending review", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return recording, true
+}
+
+// verifiedPlayback returns the part of the recording played since the
+// previous heartbeat, if the new position is consistent with playing it.
+func verifiedPlayback(progress *models.WatchProgress, position float64, now time.Time) (start, end float64, ok bool) {
+	if progress.HeartbeatAt.IsZero() {
+		return 0, 0, false
+	}
+	elapsed := now.Sub(progress.HeartbeatAt)
+	if elapsed <= 0 || elapsed > maxHeartbeatGap {
+		return 0, 0, false
+	}
+	advanced := position - progress.Position
+	if advanced <= 0 || advanced > elapsed.Seconds()*maxPlaybackRate+positionSlack {
+		return 0, 0, false // Paused, seeked back or seeked ahead
+	}
+	return progress.Position, position, true
+}
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/richtext"
+	"github.com/jinshatcp/brightline-academy/learn/internal/validate"
+	"github.com/jinshatcp/brightline-academy/learn/internal/webhooks"
+)
+
+// webhookStaleAfter is how long an event may stay in processing before a
+// retried delivery takes it over, e.g. after the server restarted mid-way.
+const webhookStaleAfter = 5 * time.Minute
+
+// WebhookHandler receives events from external systems (payments, an LMS)
+// and applies them through the actions the dispatcher routes them to.
+type WebhookHandler struct {
+	userRepo     *repository.UserRepository
+	batchRepo    *repository.BatchRepository
+	scheduleRepo *repository.ScheduleRepository
+	eventRepo    *repository.WebhookEventRepository
+	billing      *BillingHandler
+	sessions     *SessionCloser
+	dispatcher   *webhooks.Dispatcher
+	retention    time.Duration
+}
+
+// NewWebhookHandler creates a new WebhookHandler and registers its actions
+// with the dispatcher.
+func NewWebhookHandler(userRepo *repository.UserRepository, batchRepo *repository.BatchRepository, scheduleRepo *repository.ScheduleRepository, eventRepo *repository.WebhookEventRepository, billing *BillingHandler, sessions *SessionCloser, dispatcher *webhooks.Dispatcher, retention time.Duration) *WebhookHandler {
+	h := &WebhookHandler{
+		userRepo:     userRepo,
+		batchRepo:    batchRepo,
+		scheduleRepo: scheduleRepo,
+		eventRepo:    eventRepo,
+		billing:      billing,
+		sessions:     sessions,
+		dispatcher:   dispatcher,
+		retention:    retention,
+	}
+
+	dispatcher.Handle("enroll", h.enroll)
+	dispatcher.Handle("suspend", h.suspend)
+	dispatcher.Handle("create-schedule", h.createSchedule)
+
+	return h
+}
+
+// Receive handles an event posted by a source.
+// POST /api/webhooks/{source}
+func (h *WebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	source := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		sendJSONError(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.dispatcher.Verify(source, r.Header, body)
+	switch {
+	case errors.Is(err, webhooks.ErrUnknownSource):
+		sendJSONError(w, "Unknown webhook source", http.StatusNotFound)
+		return
+	case errors.Is(err, webhooks.ErrInvalidPayload):
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("[Webhook] Rejected %s webhook: %v", source, err)
+		sendJSONError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	name, action, ok := h.dispatcher.Action(event)
+	if !ok {
+		// Sources often send every event type; acknowledge the ones we
+		// don't act on so they aren't retried
+		sendJSON(w, map[string]string{"status": "ignored"}, http.StatusOK)
+		return
+	}
+
+	stored, claimed, err := h.eventRepo.Claim(r.Context(), &models.WebhookEvent{
+		Source:    source,
+		EventID:   event.ID,
+		Type:      event.Type,
+		Action:    name,
+		ExpiresAt: time.Now().Add(h.retention),
+	}, webhookStaleAfter)
+	if err != nil {
+		log.Printf("[Webhook] Failed to record %s event %s: %v", source, event.ID, err)
+		sendStoreError(w, "Failed to record event", err)
+		return
+	}
+	if !claimed {
+		if stored.Status == models.WebhookEventProcessed {
+			sendJSON(w, map[string]interface{}{
+				"status":    stored.Status,
+				"duplicate": true,
+				"result":    stored.Result,
+			}, http.StatusOK)
+			return
+		}
+		sendJSONError(w, "Event is already being processed", http.StatusConflict)
+		return
+	}
+
+	result, err := action(r.Context(), event)
+	if err != nil {
+		// Record the failure even if the sender hung up
+		ctx := context.WithoutCancel(r.Context())
+		if failErr := h.eventRepo.Fail(ctx, stored.ID, err.Error()); failErr != nil {
+			log.Printf("[Webhook] Failed to record failure of %s event %s: %v", source, event.ID, failErr)
+		}
+
+		var rejected *webhooks.RejectedError
+		if errors.As(err, &rejected) {
+			log.Printf("[Webhook] %s event %s (%s) rejected: %v", source, event.ID, event.Type, err)
+			sendJSONError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("[Webhook] %s event %s (%s) failed: %v", source, event.ID, event.Type, err)
+		sendJSONError(w, "Failed to process event", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.eventRepo.Complete(context.WithoutCancel(r.Context()), stored.ID, result); err != nil {
+		log.Printf("[Webhook] Failed to record completion of %s event %s: %v", source, event.ID, err)
+	}
+
+	log.Printf("[Webhook] %s event %s (%s) applied by %s", source, event.ID, event.Type, name)
+
+	sendJSON(w, map[string]interface{}{
+		"status": models.WebhookEventProcessed,
+		"result": result,
+	}, http.StatusOK)
+}
+
+// ListEvents returns recently received events (admin only), optionally
+// filtered by source and status.
+// GET /api/webhooks/events
+func (h *WebhookHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 50
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	events, err := h.eventRepo.FindRecent(r.Context(), query.Get("source"), models.WebhookEventStatus(query.Get("status")), limit)
+	if err != nil {
+		sendStoreError(w, "Failed to fetch events", err)
+		return
+	}
+
+	sendJSON(w, events, http.StatusOK)
+}
+
+// decodeEventData decodes an event's data and checks its validate tags.
+func decodeEventData(event *webhooks.Event, v interface{}) error {
+	if err := json.Unmarshal(event.Data, v); err != nil {
+		return webhooks.Reject("invalid event data")
+	}
+	if err := validate.Struct(v); err != nil {
+		return webhooks.Reject("%v", err)
+	}
+	return nil
+}
+
+// findEventUser looks up the user an event names, by ID or by email.
+func (h *WebhookHandler) findEventUser(ctx context.Context, userID, email string) (*models.User, error) {
+	var user *models.User
+	var err error
+	switch {
+	case userID != "":
+		user, err = h.userRepo.FindByID(ctx, userID)
+	case email != "":
+		user, err = h.userRepo.FindByEmail(ctx, email)
+	default:
+		return nil, webhooks.Reject("userId or email is required")
+	}
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, webhooks.Reject("user not found")
+	}
+	return user, err
+}
+
+// enroll adds a student to a batch, e.g. once their payment went through.
+func (h *WebhookHandler) enroll(ctx context.Context, event *webhooks.Event) (map[string]interface{}, error) {
+	var data struct {
+		BatchID string `json:"batchId" validate:"required,objectid"`
+		UserID  string `json:"userId" validate:"objectid"`
+		Email   string `json:"email" validate:"email"`
+	}
+	if err := decodeEventData(event, &data); err != nil {
+		return nil, err
+	}
+
+	student, err := h.findEventUser(ctx, data.UserID, data.Email)
+	if err != nil {
+		return nil, err
+	}
+	if student.Role != models.RoleStudent {
+		return nil, webhooks.Reject("only students can be enrolled")
+	}
+
+	batch, err := h.batchRepo.FindByID(ctx, data.BatchID)
+	if errors.Is(err, repository.ErrBatchNotFound) {
+		return nil, webhooks.Reject("batch not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"batchId":   batch.ID.Hex(),
+		"studentId": student.ID.Hex(),
+	}
+	if batch.HasStudent(student.ID.Hex()) {
+		result["alreadyEnrolled"] = true
+		return result, nil
+	}
+	if err := h.billing.CheckSeats(ctx, batch, []string{student.ID.Hex()}); isBillingLimit(err) {
+		return nil, webhooks.Reject("%v", err)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := h.batchRepo.AddStudents(ctx, data.BatchID, []string{student.ID.Hex()}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// suspend suspends a user, e.g. when their subscription was cancelled.
+func (h *WebhookHandler) suspend(ctx context.Context, event *webhooks.Event) (map[string]interface{}, error) {
+	var data struct {
+		UserID string `json:"userId" validate:"objectid"`
+		Email  string `json:"email" validate:"email"`
+		Reason string `json:"reason" validate:"max=500"`
+	}
+	if err := decodeEventData(event, &data); err != nil {
+		return nil, err
+	}
+
+	user, err := h.findEventUser(ctx, data.UserID, data.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == models.RoleAdmin {
+		return nil, webhooks.Reject("admins can't be suspended by webhooks")
+	}
+
+	if err := h.sessions.Suspend(ctx, user.ID.Hex(), ""); err != nil {
+		return nil, err
+	}
+	if data.Reason != "" {
+		log.Printf("[Webhook] Suspended %s (%s): %s", user.Name, user.ID.Hex(), data.Reason)
+	}
+
+	return map[string]interface{}{
+		"userId": user.ID.Hex(),
+		"status": models.StatusSuspended,
+	}, nil
+}
+
+// createSchedule schedules a class for a batch, e.g. from an LMS timetable.
+func (h *WebhookHandler) createSchedule(ctx context.Context, event *webhooks.Event) (map[string]interface{}, error) {
+	var data struct {
+		Title       string `json:"title" validate:"required,max=200"`
+		Description string `json:"description" validate:"max=2000"`
+		BatchID     string `json:"batchId" validate:"required,objectid"`
+		StartTime   string `json:"startTime" validate:"required,rfc3339"`
+		EndTime     string `json:"endTime" validate:"required,rfc3339"`
+	}
+	if err := decodeEventData(event, &data); err != nil {
+		return nil, err
+	}
+
+	startTime, _ := time.Parse(time.RFC3339, data.StartTime)
+	endTime, _ := time.Parse(time.RFC3339, data.EndTime)
+	if endTime.Before(startTime) {
+		return nil, webhooks.Reject("end time must be after start time")
+	}
+
+	batch, err := h.batchRepo.FindByID(ctx, data.BatchID)
+	if errors.Is(err, repository.ErrBatchNotFound) {
+		return nil, webhooks.Reject("batch not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &models.ScheduledClass{
+		Title:       data.Title,
+		Description: richtext.Rich.Sanitize(data.Description),
+		BatchID:     batch.ID,
+		PresenterID: batch.PresenterID,
+		StartTime:   startTime,
+		EndTime:     endTime,
+
+		BatchName:     batch.Name,
+		PresenterName: newNameLookup(ctx, h.batchRepo, h.userRepo).User(batch.PresenterID, batch.PresenterName),
+	}
+	if err := h.scheduleRepo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"scheduleId": schedule.ID.Hex(),
+		"batchId":    batch.ID.Hex(),
+	}, nil
+}
@@ -0,0 +1,249 @@
+package server_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/server"
+	"github.com/jinshatcp/brightline-academy/learn/internal/testsupport"
+	"github.com/pion/webrtc/v3"
+)
+
+// Packets of each kind a viewer must receive for the stream to count as
+// delivered: about a second of media.
+const mediaPackets = 30
+
+// publish joins a class as its presenter and streams synthetic media until
+// the test ends. setup, if not nil, configures the presenter before it
+// offers.
+func publish(t *testing.T, srv *testsupport.Server, class *liveClass, setup func(*testsupport.Presenter)) (*testsupport.Presenter, *testsupport.Signal) {
+	t.Helper()
+	sig, _ := join(t, srv, class.roomID, class.presenter.Token, true)
+	return stream(t, sig, setup), sig
+}
+
+// stream publishes over a presenter's signaling connection, streaming until
+// the test ends.
+func stream(t *testing.T, sig *testsupport.Signal, setup func(*testsupport.Presenter)) *testsupport.Presenter {
+	t.Helper()
+	ctx := testContext(t)
+
+	presenter, err := testsupport.NewPresenter(sig)
+	if err != nil {
+		t.Fatalf("new presenter: %v", err)
+	}
+	t.Cleanup(func() { presenter.Close() })
+	if setup != nil {
+		setup(presenter)
+	}
+	if err := presenter.Publish(ctx); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		presenter.Stream(streamCtx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return presenter
+}
+
+// watch joins a class as its student, answering the offers the server
+// pushes. The viewer is set up before joining, since a stream that is
+// ready is offered right away.
+func watch(t *testing.T, srv *testsupport.Server, class *liveClass, setup func(*testsupport.Viewer)) (*testsupport.Viewer, *testsupport.Signal) {
+	t.Helper()
+	ctx := testContext(t)
+
+	sig, err := srv.Dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { sig.Close() })
+
+	viewer, err := testsupport.NewViewer(sig)
+	if err != nil {
+		t.Fatalf("new viewer: %v", err)
+	}
+	t.Cleanup(func() { viewer.Close() })
+	if setup != nil {
+		setup(viewer)
+	}
+
+	if _, err := sig.Join(ctx, class.roomID, class.student.Token, false); err != nil {
+		t.Fatalf("join %s: %v", class.roomID, err)
+	}
+	return viewer, sig
+}
+
+// waitForStream waits until a viewer received media of both kinds, at least
+// mediaPackets more than it had already.
+func waitForStream(t *testing.T, viewer *testsupport.Viewer) {
+	t.Helper()
+	ctx := testContext(t)
+
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeVideo, webrtc.RTPCodecTypeAudio} {
+		if err := viewer.WaitForMedia(ctx, kind, viewer.Packets(kind)+mediaPackets); err != nil {
+			t.Fatalf("waiting for %s: %v", kind, err)
+		}
+	}
+}
+
+func TestPresenterOfferAnswer(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+
+	presenter, _ := publish(t, srv, class, nil)
+
+	answer := presenter.RemoteDescription()
+	if answer == nil || answer.Type != webrtc.SDPTypeAnswer {
+		t.Fatalf("presenter's remote description = %v, want an answer", answer)
+	}
+	for _, media := range []string{"m=video", "m=audio"} {
+		if !strings.Contains(answer.SDP, media) {
+			t.Errorf("answer has no %s section:\n%s", media, answer.SDP)
+		}
+	}
+	if strings.Contains(answer.SDP, "a=inactive") {
+		t.Errorf("answer declines a track:\n%s", answer.SDP)
+	}
+	if err := presenter.Err(); err != nil {
+		t.Fatalf("presenter signaling: %v", err)
+	}
+}
+
+func TestViewerReceivesStream(t *testing.T) {
+	t.Run("viewer joins first", func(t *testing.T) {
+		srv := testsupport.StartWith(t, deps, nil)
+		class := startClass(t, srv)
+		ctx := testContext(t)
+
+		viewer, sig := watch(t, srv, class, nil)
+		if _, err := sig.Expect(ctx, "waiting-for-stream"); err != nil {
+			t.Fatal(err)
+		}
+
+		publish(t, srv, class, nil)
+		if _, err := sig.Expect(ctx, "stream-available"); err != nil {
+			t.Fatal(err)
+		}
+		waitForStream(t, viewer)
+
+		offer := viewer.RemoteDescription()
+		if offer == nil || offer.Type != webrtc.SDPTypeOffer {
+			t.Fatalf("viewer's remote description = %v, want an offer", offer)
+		}
+	})
+
+	t.Run("presenter streams first", func(t *testing.T) {
+		srv := testsupport.StartWith(t, deps, nil)
+		class := startClass(t, srv)
+
+		publish(t, srv, class, nil)
+		viewer, _ := watch(t, srv, class, nil)
+		waitForStream(t, viewer)
+	})
+}
+
+func TestRequestStreamWhileWatching(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+
+	publish(t, srv, class, nil)
+	viewer, _ := watch(t, srv, class, nil)
+	waitForStream(t, viewer)
+	offer := viewer.RemoteDescription().SDP
+
+	// A connected viewer's retry is ignored rather than renegotiated
+	if err := viewer.RequestStream(); err != nil {
+		t.Fatalf("request stream: %v", err)
+	}
+	waitForStream(t, viewer)
+	if viewer.RemoteDescription().SDP != offer {
+		t.Fatal("requesting the stream while watching it renegotiated the connection")
+	}
+}
+
+func TestEarlyICECandidatesAreQueued(t *testing.T) {
+	t.Run("presenter", func(t *testing.T) {
+		srv := testsupport.StartWith(t, deps, nil)
+		class := startClass(t, srv)
+
+		// Publish only connects if the candidates sent ahead of the offer
+		// were kept for it
+		publish(t, srv, class, func(p *testsupport.Presenter) { p.SendCandidatesFirst() })
+		viewer, _ := watch(t, srv, class, nil)
+		waitForStream(t, viewer)
+	})
+
+	t.Run("viewer", func(t *testing.T) {
+		srv := testsupport.StartWith(t, deps, nil)
+		class := startClass(t, srv)
+
+		publish(t, srv, class, nil)
+		viewer, _ := watch(t, srv, class, func(v *testsupport.Viewer) { v.SendCandidatesFirst() })
+		waitForStream(t, viewer)
+	})
+}
+
+func TestPresenterReconnects(t *testing.T) {
+	srv := testsupport.StartWith(t, deps, nil)
+	class := startClass(t, srv)
+	ctx := testContext(t)
+
+	presenter, presenterSig := publish(t, srv, class, nil)
+	viewer, viewerSig := watch(t, srv, class, nil)
+	waitForStream(t, viewer)
+
+	// The presenter's network drops
+	roomToken := presenterSig.RoomToken()
+	presenterSig.Close()
+	presenter.Close()
+	if _, err := viewerSig.Expect(ctx, "presenter-reconnecting"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without their last room token the presenter's account can't resume
+	// the session, only ask to take it over
+	intruder, err := srv.Dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer intruder.Close()
+	if err := intruder.Send(server.Message{Type: "join", RoomID: class.roomID, Token: class.presenter.Token, IsPresenter: true}); err != nil {
+		t.Fatalf("join without room token: %v", err)
+	}
+	if _, err := intruder.Expect(ctx, "presenter-conflict"); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := srv.Dial(ctx)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { sig.Close() })
+	ev, err := sig.Rejoin(ctx, class.roomID, class.presenter.Token, true, roomToken)
+	if err != nil {
+		t.Fatalf("rejoin: %v", err)
+	}
+	var info joined
+	if err := ev.Decode(&info); err != nil {
+		t.Fatalf("decode joined: %v", err)
+	}
+	if !info.Resumed {
+		t.Fatal("presenter rejoining with their room token didn't resume")
+	}
+	if _, err := viewerSig.Expect(ctx, "presenter-reconnected"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new offer feeds the tracks the viewer is already attached to
+	stream(t, sig, nil)
+	waitForStream(t, viewer)
+}
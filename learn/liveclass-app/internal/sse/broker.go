@@ -0,0 +1,198 @@
+// Package sse streams users' live events, the same ones pushed to their
+// WebSocket connections, as server-sent events for clients that can't keep a
+// WebSocket open, such as those behind proxies that break them.
+package sse
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a stream can fall behind before it is
+// closed; the client reconnects and resumes from its last event.
+const subscriberBuffer = 64
+
+// Event is one event for a user's streams.
+type Event struct {
+	ID   string // "<epoch>-<sequence>", sent as the SSE id and resumed from with Last-Event-ID
+	Data []byte
+
+	seq uint64
+}
+
+// Broker delivers events to users' open streams and keeps each user's latest
+// events for a while after their streams close, so a client reconnecting
+// with Last-Event-ID gets what it missed.
+//
+// Events are kept in memory, so resuming works on the instance the client
+// was connected to. Event IDs carry the broker's epoch: IDs from another
+// instance, or from before a restart, can't be resumed from.
+type Broker struct {
+	epoch   string
+	backlog int
+	keep    time.Duration
+
+	mu    sync.Mutex
+	seq   uint64
+	users map[string]*userEvents
+}
+
+// userEvents are a user's open streams and latest events.
+type userEvents struct {
+	recent      []Event
+	from        uint64 // Events after this sequence are all in recent
+	subscribers map[*subscriber]struct{}
+	idleSince   time.Time // When the last stream closed
+}
+
+// subscriber is one open stream.
+type subscriber struct {
+	sessionID string
+	events    chan Event
+}
+
+// NewBroker creates a broker keeping up to backlog events per user, for up
+// to keep after the user's last stream closed.
+func NewBroker(backlog int, keep time.Duration) *Broker {
+	return &Broker{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		backlog: backlog,
+		keep:    keep,
+		users:   make(map[string]*userEvents),
+	}
+}
+
+// Publish delivers data to the user's open streams, or keeps it for them to
+// resume. It reports whether the user had an open stream.
+func (b *Broker) Publish(userID string, data []byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u, ok := b.users[userID]
+	if !ok {
+		return false
+	}
+	if len(u.subscribers) == 0 && time.Since(u.idleSince) > b.keep {
+		delete(b.users, userID)
+		return false
+	}
+
+	b.seq++
+	event := Event{ID: b.epoch + "-" + strconv.FormatUint(b.seq, 10), Data: data, seq: b.seq}
+	u.recent = append(u.recent, event)
+	if len(u.recent) > b.backlog {
+		dropped := len(u.recent) - b.backlog
+		u.from = u.recent[dropped-1].seq
+		u.recent = append([]Event(nil), u.recent[dropped:]...)
+	}
+
+	for s := range u.subscribers {
+		select {
+		case s.events <- event:
+		default:
+			// Too far behind: the client resumes from its last event
+			b.closeLocked(u, s)
+		}
+	}
+	return len(u.subscribers) > 0
+}
+
+// Subscribe opens a stream of the user's events. It returns the events after
+// lastEventID the client missed, and whether those are all of them; if not
+// (the ID is unknown or too old) the client should reload what it shows. The
+// channel is closed when the stream is dropped; cancel closes the stream.
+func (b *Broker) Subscribe(userID, sessionID, lastEventID string) (events <-chan Event, missed []Event, complete bool, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pruneLocked()
+
+	u, ok := b.users[userID]
+	if !ok {
+		u = &userEvents{from: b.seq, subscribers: make(map[*subscriber]struct{})}
+		b.users[userID] = u
+	}
+
+	complete = true
+	if lastEventID != "" {
+		after, known := b.parseID(lastEventID)
+		complete = known && after >= u.from
+		for _, event := range u.recent {
+			if event.seq > after {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	s := &subscriber{sessionID: sessionID, events: make(chan Event, subscriberBuffer)}
+	u.subscribers[s] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.closeLocked(u, s)
+	}
+	return s.events, missed, complete, cancel
+}
+
+// CloseSession sends data to the streams opened with a login session, then
+// closes them. It returns how many were closed.
+func (b *Broker) CloseSession(sessionID string, data []byte) int {
+	if sessionID == "" {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	closed := 0
+	for _, u := range b.users {
+		for s := range u.subscribers {
+			if s.sessionID != sessionID {
+				continue
+			}
+			select {
+			case s.events <- Event{Data: data}:
+			default:
+			}
+			b.closeLocked(u, s)
+			closed++
+		}
+	}
+	return closed
+}
+
+// parseID returns the sequence of an event ID, and false if it isn't one of
+// this broker's.
+func (b *Broker) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != b.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil && n <= b.seq
+}
+
+// closeLocked closes a stream if it's still open. Callers must hold b.mu.
+func (b *Broker) closeLocked(u *userEvents, s *subscriber) {
+	if _, ok := u.subscribers[s]; !ok {
+		return
+	}
+	delete(u.subscribers, s)
+	close(s.events)
+	if len(u.subscribers) == 0 {
+		u.idleSince = time.Now()
+	}
+}
+
+// pruneLocked forgets the events of users whose streams closed longer than
+// keep ago. Callers must hold b.mu.
+func (b *Broker) pruneLocked() {
+	for userID, u := range b.users {
+		if len(u.subscribers) == 0 && time.Since(u.idleSince) > b.keep {
+			delete(b.users, userID)
+		}
+	}
+}
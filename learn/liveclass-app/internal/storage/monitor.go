@@ -0,0 +1,288 @@
+// Package storage monitors disk usage of uploaded content (recordings, notes,
+// archives) and alerts admins when it grows past configured thresholds.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/notify"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Monitor computes a daily storage usage report per batch and per presenter,
+// stores it as history and alerts admins when total usage crosses a threshold.
+type Monitor struct {
+	recordingRepo *repository.RecordingRepository
+	noteRepo      *repository.NoteRepository
+	batchRepo     *repository.BatchRepository
+	userRepo      *repository.UserRepository
+	usageRepo     *repository.StorageUsageRepository
+	notifier      *notify.Notifier
+	storagePath   string
+	thresholds    []int64 // Alert thresholds in bytes, ascending
+	interval      time.Duration
+}
+
+// NewMonitor creates a new Monitor. thresholds are in bytes.
+func NewMonitor(
+	recordingRepo *repository.RecordingRepository,
+	noteRepo *repository.NoteRepository,
+	batchRepo *repository.BatchRepository,
+	userRepo *repository.UserRepository,
+	usageRepo *repository.StorageUsageRepository,
+	notifier *notify.Notifier,
+	storagePath string,
+	thresholds []int64,
+	interval time.Duration,
+) *Monitor {
+	sorted := append([]int64(nil), thresholds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &Monitor{
+		recordingRepo: recordingRepo,
+		noteRepo:      noteRepo,
+		batchRepo:     batchRepo,
+		userRepo:      userRepo,
+		usageRepo:     usageRepo,
+		notifier:      notifier,
+		storagePath:   storagePath,
+		thresholds:    sorted,
+		interval:      interval,
+	}
+}
+
+// Run reports usage immediately and then every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	m.runOnce(ctx)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.runOnce(ctx)
+		}
+	}
+}
+
+// runOnce computes, stores and checks one report.
+func (m *Monitor) runOnce(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	snapshot, err := m.Snapshot(ctx)
+	if err != nil {
+		log.Printf("[Storage] Failed to compute usage: %v", err)
+		return
+	}
+
+	if err := m.usageRepo.Save(ctx, snapshot); err != nil {
+		log.Printf("[Storage] Failed to save usage report: %v", err)
+		return
+	}
+
+	log.Printf("[Storage] Usage: %s total (recordings %s, notes %s, other %s)",
+		FormatBytes(snapshot.TotalBytes), FormatBytes(snapshot.RecordingBytes),
+		FormatBytes(snapshot.NoteBytes), FormatBytes(snapshot.OtherBytes))
+
+	m.checkThresholds(ctx, snapshot)
+}
+
+// Snapshot computes the current usage report.
+func (m *Monitor) Snapshot(ctx context.Context) (*models.StorageUsageSnapshot, error) {
+	recordings, err := m.recordingRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recordings: %w", err)
+	}
+	notes, err := m.noteRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notes: %w", err)
+	}
+	batches, err := m.batchRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load batches: %w", err)
+	}
+
+	batchNames := make(map[primitive.ObjectID]string, len(batches))
+	batchPresenters := make(map[primitive.ObjectID]primitive.ObjectID, len(batches))
+	for _, b := range batches {
+		batchNames[b.ID] = b.Name
+		batchPresenters[b.ID] = b.PresenterID
+	}
+
+	byBatch := make(map[primitive.ObjectID]*models.StorageUsageEntry)
+	byPresenter := make(map[primitive.ObjectID]*models.StorageUsageEntry)
+	add := func(entries map[primitive.ObjectID]*models.StorageUsageEntry, id primitive.ObjectID, bytes int64) {
+		e, ok := entries[id]
+		if !ok {
+			e = &models.StorageUsageEntry{ID: id}
+			entries[id] = e
+		}
+		e.Bytes += bytes
+		e.Files++
+	}
+
+	snapshot := &models.StorageUsageSnapshot{
+		Date: today(),
+	}
+
+	for _, rec := range recordings {
+		if rec.IsArchived() {
+			continue // Not on local disk
+		}
+		snapshot.RecordingBytes += rec.FileSize
+		add(byBatch, rec.BatchID, rec.FileSize)
+		add(byPresenter, rec.PresenterID, rec.FileSize)
+	}
+
+	// Notes count against the batch's presenter, whoever uploaded them
+	for _, note := range notes {
+		snapshot.NoteBytes += note.FileSize
+		add(byBatch, note.BatchID, note.FileSize)
+		presenterID, ok := batchPresenters[note.BatchID]
+		if !ok {
+			presenterID = note.UploaderID
+		}
+		add(byPresenter, presenterID, note.FileSize)
+	}
+
+	tracked := snapshot.RecordingBytes + snapshot.NoteBytes
+	onDisk, err := dirSize(m.storagePath)
+	if err != nil {
+		log.Printf("[Storage] Failed to measure %s, using tracked usage: %v", m.storagePath, err)
+		onDisk = tracked
+	}
+	snapshot.TotalBytes = onDisk
+	if onDisk > tracked {
+		snapshot.OtherBytes = onDisk - tracked
+	}
+
+	snapshot.Batches = sortedEntries(byBatch, func(id primitive.ObjectID) string {
+		return batchNames[id]
+	})
+
+	presenterRole := models.RolePresenter
+	presenters, _ := m.userRepo.FindAll(ctx, nil, &presenterRole)
+	presenterNames := make(map[primitive.ObjectID]string, len(presenters))
+	for _, p := range presenters {
+		presenterNames[p.ID] = p.Name
+	}
+	snapshot.Presenters = sortedEntries(byPresenter, func(id primitive.ObjectID) string {
+		if name, ok := presenterNames[id]; ok {
+			return name
+		}
+		if user, err := m.userRepo.FindByID(ctx, id.Hex()); err == nil {
+			return user.Name
+		}
+		return ""
+	})
+
+	return snapshot, nil
+}
+
+// checkThresholds alerts admins when usage crossed a threshold since the
+// previous day's report (or on the first report, when it's already above one).
+func (m *Monitor) checkThresholds(ctx context.Context, snapshot *models.StorageUsageSnapshot) {
+	var previousTotal int64
+	if previous, err := m.usageRepo.LatestBefore(ctx, snapshot.Date); err == nil {
+		previousTotal = previous.TotalBytes
+	}
+
+	// Highest threshold crossed since the previous report
+	var crossed int64
+	for _, t := range m.thresholds {
+		if snapshot.TotalBytes >= t && previousTotal < t {
+			crossed = t
+		}
+	}
+	if crossed == 0 {
+		return
+	}
+
+	claimed, err := m.usageRepo.ClaimAlert(ctx, snapshot.Date, crossed)
+	if err != nil {
+		log.Printf("[Storage] Failed to record usage alert: %v", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	log.Printf("[Storage] ⚠️ Usage %s crossed the %s threshold", FormatBytes(snapshot.TotalBytes), FormatBytes(crossed))
+
+	body := fmt.Sprintf("Storage usage is %s, above the %s alert threshold (recordings %s, notes %s, other %s).",
+		FormatBytes(snapshot.TotalBytes), FormatBytes(crossed),
+		FormatBytes(snapshot.RecordingBytes), FormatBytes(snapshot.NoteBytes), FormatBytes(snapshot.OtherBytes))
+	if len(snapshot.Batches) > 0 {
+		top := snapshot.Batches[0]
+		body += fmt.Sprintf(" The largest batch is %s with %s.", top.Name, FormatBytes(top.Bytes))
+	}
+
+	m.notifier.NotifyAdmins(ctx, notify.Message{
+		Category: models.NotificationStorageAlert,
+		Title:    "Storage usage above " + FormatBytes(crossed),
+		Body:     body,
+		Link:     "/admin",
+		Email:    true,
+	})
+}
+
+// FormatBytes formats a byte count for humans, e.g. "1.5 GB".
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// dirSize returns the total size of regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return nil // File removed while walking
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// sortedEntries names the entries and sorts them by size, largest first.
+func sortedEntries(entries map[primitive.ObjectID]*models.StorageUsageEntry, name func(primitive.ObjectID) string) []models.StorageUsageEntry {
+	list := make([]models.StorageUsageEntry, 0, len(entries))
+	for id, e := range entries {
+		e.Name = name(id)
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Bytes > list[j].Bytes })
+	return list
+}
+
+// today returns midnight UTC of the current day.
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
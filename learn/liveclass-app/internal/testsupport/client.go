@@ -0,0 +1,258 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/auth"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// APIError is a response with an error status.
+type APIError struct {
+	Status  int
+	Message string // The "error" field of the response, if any
+	Body    []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+	}
+	return fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+}
+
+// StatusOf returns the status of an APIError, or 0 for other errors.
+func StatusOf(err error) int {
+	if apiErr, ok := err.(*APIError); ok {
+		return apiErr.Status
+	}
+	return 0
+}
+
+// Client calls the API of a test server, as the user it logged in as.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// NewClient creates a client without credentials.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: baseURL, HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Do sends a request with body encoded as JSON (nil for none) and decodes
+// the response into out (nil to discard it). Error statuses are returned
+// as *APIError.
+func (c *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		apiErr := &APIError{Status: resp.StatusCode, Body: data}
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &msg) == nil {
+			apiErr.Message = msg.Error
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Register creates an account, pending approval.
+func (c *Client) Register(ctx context.Context, req auth.RegisterRequest) (*models.UserResponse, error) {
+	var resp struct {
+		User models.UserResponse `json:"user"`
+	}
+	if err := c.Do(ctx, http.MethodPost, "/api/auth/register", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+// Login signs in and uses the token for later requests.
+func (c *Client) Login(ctx context.Context, email, password string) (*auth.AuthResponse, error) {
+	var resp auth.AuthResponse
+	req := auth.LoginRequest{Email: email, Password: password}
+	if err := c.Do(ctx, http.MethodPost, "/api/auth/login", req, &resp); err != nil {
+		return nil, err
+	}
+	c.Token = resp.Token
+	return &resp, nil
+}
+
+// Me returns the signed in user.
+func (c *Client) Me(ctx context.Context) (*models.UserResponse, error) {
+	var user models.UserResponse
+	if err := c.Do(ctx, http.MethodGet, "/api/auth/me", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserStatus approves, rejects or suspends an account. Admin only.
+func (c *Client) SetUserStatus(ctx context.Context, userID string, status models.UserStatus) error {
+	req := map[string]models.UserStatus{"status": status}
+	return c.Do(ctx, http.MethodPut, "/api/admin/users/"+userID+"/status", req, nil)
+}
+
+// CreateBatch creates a batch taught by the presenter. Admin or presenter.
+func (c *Client) CreateBatch(ctx context.Context, name, presenterID string) (*models.BatchResponse, error) {
+	var batch models.BatchResponse
+	req := map[string]string{"name": name, "presenterId": presenterID}
+	if err := c.Do(ctx, http.MethodPost, "/api/batches", req, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// AddStudents enrolls students in a batch. Admin or presenter.
+func (c *Client) AddStudents(ctx context.Context, batchID string, studentIDs ...string) error {
+	req := map[string][]string{"studentIds": studentIDs}
+	return c.Do(ctx, http.MethodPost, "/api/batches/"+batchID+"/students", req, nil)
+}
+
+// ScheduleRequest is a class to schedule.
+type ScheduleRequest struct {
+	Title     string    `json:"title"`
+	BatchID   string    `json:"batchId"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Proctored bool      `json:"proctored,omitempty"`
+	ExamMode  bool      `json:"examMode,omitempty"`
+}
+
+// CreateSchedule schedules a class.
+func (c *Client) CreateSchedule(ctx context.Context, req ScheduleRequest) (*models.ScheduledClassResponse, error) {
+	var schedule models.ScheduledClassResponse
+	if err := c.Do(ctx, http.MethodPost, "/api/schedules", req, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetSchedule returns a scheduled class.
+func (c *Client) GetSchedule(ctx context.Context, scheduleID string) (*models.ScheduledClassResponse, error) {
+	var schedule models.ScheduledClassResponse
+	if err := c.Do(ctx, http.MethodGet, "/api/schedules/"+scheduleID, nil, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// StartClass takes a class live and returns its room ID. Its presenter only.
+func (c *Client) StartClass(ctx context.Context, scheduleID string) (string, error) {
+	var resp struct {
+		RoomID string `json:"roomId"`
+	}
+	if err := c.Do(ctx, http.MethodPost, "/api/schedules/"+scheduleID+"/start", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.RoomID, nil
+}
+
+// JoinResponse is the outcome of joining a live class.
+type JoinResponse struct {
+	RoomID        string `json:"roomId"`
+	IsPresenter   bool   `json:"isPresenter"`
+	ExamMode      bool   `json:"examMode"`
+	EndsInSeconds int64  `json:"endsInSeconds"`
+}
+
+// JoinClass asks to join a live class, returning the room to signal in.
+func (c *Client) JoinClass(ctx context.Context, scheduleID string) (*JoinResponse, error) {
+	var resp JoinResponse
+	if err := c.Do(ctx, http.MethodPost, "/api/schedules/"+scheduleID+"/join", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// EndClass ends a live class. Its presenter only.
+func (c *Client) EndClass(ctx context.Context, scheduleID string) error {
+	return c.Do(ctx, http.MethodPost, "/api/schedules/"+scheduleID+"/end", nil, nil)
+}
+
+// userSeq numbers the accounts created by NewUser.
+var userSeq atomic.Int64
+
+// Admin returns a client signed in as the server's admin.
+func (s *Server) Admin(tb testing.TB) *Client {
+	tb.Helper()
+	c := s.Client()
+	if _, err := c.Login(context.Background(), AdminEmail, AdminPassword); err != nil {
+		tb.Fatalf("admin login: %v", err)
+	}
+	return c
+}
+
+// NewUser registers an approved presenter or student and returns a client
+// signed in as them, with their account.
+func (s *Server) NewUser(tb testing.TB, role models.UserRole) (*Client, *models.UserResponse) {
+	tb.Helper()
+	ctx := context.Background()
+
+	n := userSeq.Add(1)
+	req := auth.RegisterRequest{
+		Email:    fmt.Sprintf("%s%d@testsupport.local", role, n),
+		Password: "testsupport-user",
+		Name:     fmt.Sprintf("Test %s %d", role, n),
+		Role:     role,
+	}
+	c := s.Client()
+	user, err := c.Register(ctx, req)
+	if err != nil {
+		tb.Fatalf("register %s: %v", req.Email, err)
+	}
+	if err := s.Admin(tb).SetUserStatus(ctx, user.ID, models.StatusApproved); err != nil {
+		tb.Fatalf("approve %s: %v", req.Email, err)
+	}
+	resp, err := c.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		tb.Fatalf("login %s: %v", req.Email, err)
+	}
+	return c, &resp.User
+}
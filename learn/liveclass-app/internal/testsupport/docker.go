@@ -0,0 +1,107 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Container is a throwaway container started with the docker CLI, with one
+// port published on the loopback interface.
+type Container struct {
+	ID   string
+	Host string // Address the published port is reachable on
+	Port int
+}
+
+// Addr returns the host:port the container's port is published on.
+func (c *Container) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// DockerAvailable reports whether the docker CLI can reach a daemon.
+func DockerAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}").Run() == nil
+}
+
+// StartContainer runs image in the background, publishes port on a random
+// loopback port and waits until ready, called with the published address,
+// succeeds. Docker accepts connections on the port before the service does,
+// so ready should speak its protocol. Containers are removed when stopped,
+// and labelled so leftovers of killed test runs can be found with
+// `docker ps --filter label=liveclass.testsupport`.
+func StartContainer(ctx context.Context, image string, port int, ready func(ctx context.Context, addr string) error, args ...string) (*Container, error) {
+	runArgs := []string{"run", "-d", "--rm",
+		"--label", "liveclass.testsupport=1",
+		"-p", "127.0.0.1::" + strconv.Itoa(port),
+		image}
+	id, err := docker(ctx, append(runArgs, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("start %s: %w", image, err)
+	}
+	c := &Container{ID: id, Host: "127.0.0.1"}
+
+	mapped, err := docker(ctx, "port", id, strconv.Itoa(port)+"/tcp")
+	if err != nil {
+		c.Stop()
+		return nil, fmt.Errorf("find the port of %s: %w", image, err)
+	}
+	// One line per address family, e.g. "127.0.0.1:49153"
+	line, _, _ := strings.Cut(mapped, "\n")
+	_, portStr, err := net.SplitHostPort(strings.TrimSpace(line))
+	if err == nil {
+		c.Port, err = strconv.Atoi(portStr)
+	}
+	if err != nil {
+		c.Stop()
+		return nil, fmt.Errorf("unexpected port of %s: %q", image, mapped)
+	}
+
+	if err := poll(ctx, func() error { return ready(ctx, c.Addr()) }); err != nil {
+		c.Stop()
+		return nil, fmt.Errorf("%s not ready: %w", image, err)
+	}
+	return c, nil
+}
+
+// Stop removes the container.
+func (c *Container) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := docker(ctx, "rm", "-f", c.ID)
+	return err
+}
+
+// docker runs the docker CLI and returns its trimmed output.
+func docker(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// poll calls check until it succeeds or ctx is done, returning the last
+// failure.
+func poll(ctx context.Context, check func() error) error {
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
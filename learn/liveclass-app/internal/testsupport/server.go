@@ -0,0 +1,294 @@
+// Package testsupport runs the full LiveClass server against throwaway
+// MongoDB and Redis containers, for end-to-end tests of the HTTP API and
+// WebSocket signaling. It needs only the docker CLI; tests using Start are
+// skipped where docker isn't available, except in CI (see Required).
+//
+// Containers take a few seconds to start, so packages with many tests
+// share them from TestMain and give each test its own server and database:
+//
+//	var deps *testsupport.Deps
+//
+//	func TestMain(m *testing.M) {
+//		if testsupport.DockerAvailable() {
+//			var err error
+//			if deps, err = testsupport.StartDeps(context.Background()); err != nil && testsupport.Required() {
+//				log.Fatalf("start dependencies: %v", err)
+//			}
+//		}
+//		code := m.Run()
+//		deps.Close()
+//		os.Exit(code)
+//	}
+//
+//	func TestLogin(t *testing.T) {
+//		srv := testsupport.StartWith(t, deps, nil)
+//		admin := srv.Admin(t)
+//		...
+//	}
+package testsupport
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinshatcp/brightline-academy/learn/internal/config"
+	"github.com/jinshatcp/brightline-academy/learn/internal/server"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Images the dependencies run from
+const (
+	MongoImage = "mongo:7"
+	RedisImage = "redis:7-alpine"
+)
+
+// Credentials of the admin account every test server creates
+const (
+	AdminEmail    = "admin@testsupport.local"
+	AdminPassword = "testsupport-admin"
+)
+
+const (
+	depsTimeout  = 2 * time.Minute // Includes pulling images on first use
+	startTimeout = 30 * time.Second
+)
+
+// A stand-in for the SPA, which tests don't need built
+//
+//go:embed static
+var staticFiles embed.FS
+
+// Deps are the MongoDB and Redis containers test servers run against.
+type Deps struct {
+	Mongo *Container
+	Redis *Container
+}
+
+// StartDeps starts MongoDB and Redis and waits until they serve requests.
+func StartDeps(ctx context.Context) (*Deps, error) {
+	ctx, cancel := context.WithTimeout(ctx, depsTimeout)
+	defer cancel()
+
+	mongoC, err := StartContainer(ctx, MongoImage, 27017, pingMongo)
+	if err != nil {
+		return nil, err
+	}
+	redisC, err := StartContainer(ctx, RedisImage, 6379, pingRedis)
+	if err != nil {
+		mongoC.Stop()
+		return nil, err
+	}
+	return &Deps{Mongo: mongoC, Redis: redisC}, nil
+}
+
+// MongoURI returns the connection string of the MongoDB container.
+func (d *Deps) MongoURI() string {
+	return "mongodb://" + d.Mongo.Addr()
+}
+
+// RedisURL returns the connection URL of the Redis container.
+func (d *Deps) RedisURL() string {
+	return "redis://" + d.Redis.Addr()
+}
+
+// Close removes the containers. It is a no-op on nil.
+func (d *Deps) Close() error {
+	if d == nil {
+		return nil
+	}
+	return errors.Join(d.Mongo.Stop(), d.Redis.Stop())
+}
+
+// pingMongo reports whether MongoDB at addr answers a ping.
+func pingMongo(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+addr).SetServerSelectionTimeout(time.Second))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+	return client.Ping(ctx, nil)
+}
+
+// pingRedis reports whether Redis at addr answers a ping.
+func pingRedis(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	return client.Ping(ctx).Err()
+}
+
+// Server is a LiveClass server running in the test process on a random
+// port, with a database of its own.
+type Server struct {
+	URL    string // e.g. http://127.0.0.1:41234
+	Config *config.Config
+
+	srv  *server.Server
+	done chan error
+}
+
+// StartServer boots the full server against deps. It starts from
+// config.Default, with its own database, storage under dir and the admin
+// account AdminEmail; configure, if not nil, changes the config before the
+// server is created.
+func StartServer(ctx context.Context, deps *Deps, dir string, configure func(*config.Config)) (*Server, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.Default()
+	cfg.Host, cfg.Port = "127.0.0.1", port
+	cfg.InstanceID = "test-" + uuid.NewString()[:8]
+	cfg.MongoURI = deps.MongoURI()
+	cfg.MongoDBName = "liveclass_test_" + uuid.NewString()[:8]
+	cfg.RedisEnabled, cfg.RedisURL = true, deps.RedisURL()
+	cfg.JWTSecret = uuid.NewString()
+	cfg.AdminEmail, cfg.AdminPassword, cfg.AdminName = AdminEmail, AdminPassword, "Test Admin"
+	cfg.StoragePath = dir
+	cfg.ColdStorageDir = dir + "/cold"
+	cfg.AccessLog = ""
+	if configure != nil {
+		configure(cfg)
+	}
+
+	srv, err := server.New(cfg, staticFiles, "static")
+	if err != nil {
+		return nil, fmt.Errorf("create server: %w", err)
+	}
+	s := &Server{
+		URL:    "http://" + cfg.Address(),
+		Config: cfg,
+		srv:    srv,
+		done:   make(chan error, 1),
+	}
+	go func() { s.done <- srv.Run() }()
+
+	if err := s.waitReady(ctx); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("server didn't start: %w", err)
+	}
+	return s, nil
+}
+
+// waitReady waits until the server's readiness check passes, or it exits.
+func (s *Server) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+	for {
+		resp, err := http.Get(s.URL + "/api/ready")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("not ready: %s", resp.Status)
+		}
+		select {
+		case runErr := <-s.done:
+			return runErr
+		case <-ctx.Done():
+			return err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Close shuts the server down. Its database is left for inspection and
+// goes away with the MongoDB container.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+// WebSocketURL returns the URL of the signaling endpoint.
+func (s *Server) WebSocketURL() string {
+	return "ws://" + s.Config.Address() + "/ws"
+}
+
+// Client returns an API client without credentials.
+func (s *Server) Client() *Client {
+	return NewClient(s.URL)
+}
+
+// Start starts the dependencies and a server for a single test, removing
+// them when it ends. The test is skipped without docker, or fails when
+// end-to-end tests are Required.
+func Start(tb testing.TB) *Server {
+	tb.Helper()
+	requireDocker(tb)
+
+	deps, err := StartDeps(context.Background())
+	if err != nil {
+		tb.Fatalf("start dependencies: %v", err)
+	}
+	tb.Cleanup(func() { deps.Close() })
+	return StartWith(tb, deps, nil)
+}
+
+// StartWith starts a server against shared dependencies for a single test,
+// shutting it down when it ends. A nil deps, from a TestMain that found no
+// docker, skips the test.
+func StartWith(tb testing.TB, deps *Deps, configure func(*config.Config)) *Server {
+	tb.Helper()
+	if deps == nil {
+		requireDocker(tb)
+		tb.Fatal("testsupport: dependencies weren't started")
+	}
+
+	s, err := StartServer(context.Background(), deps, tb.TempDir(), configure)
+	if err != nil {
+		tb.Fatalf("start server: %v", err)
+	}
+	tb.Cleanup(func() { s.Close() })
+	return s
+}
+
+// Required reports whether end-to-end tests must run rather than skip
+// without docker: when LIVECLASS_E2E_REQUIRED is true, or in CI (CI set to
+// true, as CI services do) unless LIVECLASS_E2E_REQUIRED is false.
+func Required() bool {
+	if required, err := strconv.ParseBool(os.Getenv("LIVECLASS_E2E_REQUIRED")); err == nil {
+		return required
+	}
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci
+}
+
+// requireDocker skips the test without docker, or fails it when end-to-end
+// tests are required.
+func requireDocker(tb testing.TB) {
+	tb.Helper()
+	if DockerAvailable() {
+		return
+	}
+	if Required() {
+		tb.Fatal("docker is required for end-to-end tests")
+	}
+	tb.Skip("docker not available")
+}
+
+// freePort returns a loopback TCP port that was free a moment ago.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,205 @@
+package testsupport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jinshatcp/brightline-academy/learn/internal/server"
+)
+
+// Event is a message the server sent over the signaling connection.
+type Event struct {
+	Type string `json:"type"`
+	Seq  uint64 `json:"seq"` // Of a critical message, acknowledged on arrival
+	Raw  json.RawMessage
+}
+
+// Decode decodes the whole message into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// Signal is a signaling connection to a test server. Messages are read in
+// the background and queued until Next takes them, unless a handler takes
+// their type. Critical messages are acknowledged, and retransmits of them
+// dropped.
+type Signal struct {
+	conn    *websocket.Conn
+	events  chan Event
+	writeMu sync.Mutex
+
+	mu        sync.Mutex
+	err       error                  // Why reading stopped
+	roomToken string                 // From "joined" or its renewal, sent on later messages
+	lastSeq   uint64                 // Highest critical message received
+	handlers  map[string]func(Event) // By message type
+}
+
+// Dial opens a signaling connection.
+func (s *Server) Dial(ctx context.Context) (*Signal, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.WebSocketURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	sig := &Signal{conn: conn, events: make(chan Event, 256), handlers: make(map[string]func(Event))}
+	go sig.readPump()
+	return sig, nil
+}
+
+// readPump queues incoming messages until the connection closes.
+func (sig *Signal) readPump() {
+	defer close(sig.events)
+	for {
+		_, data, err := sig.conn.ReadMessage()
+		if err != nil {
+			sig.mu.Lock()
+			sig.err = err
+			sig.mu.Unlock()
+			return
+		}
+		ev := Event{Raw: data}
+		if err := json.Unmarshal(data, &ev); err != nil {
+			continue
+		}
+		if ev.Seq > 0 {
+			sig.Send(server.Message{Type: "ack", Seq: ev.Seq})
+		}
+		sig.mu.Lock()
+		if ev.Seq > 0 && ev.Seq <= sig.lastSeq {
+			sig.mu.Unlock()
+			continue
+		}
+		sig.lastSeq = max(sig.lastSeq, ev.Seq)
+		if ev.Type == "room-token" {
+			var renewed struct {
+				RoomToken string `json:"roomToken"`
+			}
+			if ev.Decode(&renewed) == nil {
+				sig.roomToken = renewed.RoomToken
+			}
+		}
+		handler := sig.handlers[ev.Type]
+		sig.mu.Unlock()
+		if handler != nil {
+			handler(ev)
+			continue
+		}
+		sig.events <- ev
+	}
+}
+
+// Send sends a message, adding the room token once joined.
+func (sig *Signal) Send(msg server.Message) error {
+	sig.mu.Lock()
+	if msg.RoomToken == "" {
+		msg.RoomToken = sig.roomToken
+	}
+	sig.mu.Unlock()
+
+	return sig.SendRaw(msg)
+}
+
+// SendRaw sends a message as is, without adding the room token.
+func (sig *Signal) SendRaw(msg server.Message) error {
+	sig.writeMu.Lock()
+	defer sig.writeMu.Unlock()
+	return sig.conn.WriteJSON(msg)
+}
+
+// RoomToken returns the room token from the last "joined" or its renewal.
+func (sig *Signal) RoomToken() string {
+	sig.mu.Lock()
+	defer sig.mu.Unlock()
+	return sig.roomToken
+}
+
+// Handle calls handler, on the reading goroutine, for every message of type
+// typ instead of queueing it.
+func (sig *Signal) Handle(typ string, handler func(Event)) {
+	sig.mu.Lock()
+	defer sig.mu.Unlock()
+	sig.handlers[typ] = handler
+}
+
+// Join joins a room as the user token belongs to and waits for "joined".
+// An "error" message in reply is returned as an error.
+func (sig *Signal) Join(ctx context.Context, roomID, token string, isPresenter bool) (Event, error) {
+	return sig.join(ctx, server.Message{Type: "join", RoomID: roomID, Token: token, IsPresenter: isPresenter, Acks: true})
+}
+
+// Rejoin joins a room again on a new connection with the room token of the
+// connection it replaces, as a client reconnecting does. A presenter
+// reconnecting within the grace period resumes their session.
+func (sig *Signal) Rejoin(ctx context.Context, roomID, token string, isPresenter bool, roomToken string) (Event, error) {
+	return sig.join(ctx, server.Message{Type: "join", RoomID: roomID, Token: token, IsPresenter: isPresenter, Acks: true, RoomToken: roomToken})
+}
+
+func (sig *Signal) join(ctx context.Context, msg server.Message) (Event, error) {
+	if err := sig.Send(msg); err != nil {
+		return Event{}, err
+	}
+	ev, err := sig.Expect(ctx, "joined")
+	if err != nil {
+		return Event{}, err
+	}
+	var joined struct {
+		RoomToken string `json:"roomToken"`
+	}
+	if err := ev.Decode(&joined); err != nil {
+		return Event{}, err
+	}
+	sig.mu.Lock()
+	sig.roomToken = joined.RoomToken
+	sig.mu.Unlock()
+	return ev, nil
+}
+
+// Next returns the next message.
+func (sig *Signal) Next(ctx context.Context) (Event, error) {
+	select {
+	case ev, ok := <-sig.events:
+		if !ok {
+			sig.mu.Lock()
+			defer sig.mu.Unlock()
+			return Event{}, fmt.Errorf("connection closed: %w", sig.err)
+		}
+		return ev, nil
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// Expect skips messages until one of type typ. An "error" message on the
+// way is returned as an error.
+func (sig *Signal) Expect(ctx context.Context, typ string) (Event, error) {
+	for {
+		ev, err := sig.Next(ctx)
+		if err != nil {
+			return Event{}, fmt.Errorf("waiting for %q: %w", typ, err)
+		}
+		if ev.Type == typ {
+			return ev, nil
+		}
+		if ev.Type == "error" {
+			var msg struct {
+				Message string `json:"message"`
+			}
+			ev.Decode(&msg)
+			return Event{}, fmt.Errorf("waiting for %q: server error: %s", typ, msg.Message)
+		}
+	}
+}
+
+// Close closes the connection, as a client leaving would.
+func (sig *Signal) Close() error {
+	deadline := time.Now().Add(time.Second)
+	sig.writeMu.Lock()
+	defer sig.writeMu.Unlock()
+	sig.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	return sig.conn.Close()
+}
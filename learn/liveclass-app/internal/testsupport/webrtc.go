@@ -0,0 +1,393 @@
+package testsupport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/server"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// Synthetic media the presenter sends. The SFU forwards it without decoding,
+// so it only needs to look right to what parses it: a VP8 keyframe header
+// for the recorder and Opus silence.
+var (
+	// VP8 payload descriptor (start of partition), then a 320x240 keyframe header
+	vp8Keyframe   = []byte{0x10, 0x50, 0x00, 0x00, 0x9D, 0x01, 0x2A, 0x40, 0x01, 0xF0, 0x00, 0x00}
+	opusSilence   = []byte{0xF8, 0xFF, 0xFE}
+	videoFrameGap = time.Second / 30
+	audioFrameGap = 20 * time.Millisecond
+)
+
+// peer is a pion peer connection signaled over a Signal, the way the web
+// client signals: trickled ICE, queued until the remote description is set.
+type peer struct {
+	signal *Signal
+	pc     *webrtc.PeerConnection
+
+	mu      sync.Mutex
+	pending []webrtc.ICECandidateInit
+	early   bool  // Candidates go out ahead of the description; the server's are ignored
+	err     error // The latest signaling failure
+}
+
+func newPeer(sig *Signal) (*peer, error) {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		return nil, err
+	}
+	p := &peer{signal: sig, pc: pc}
+
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		payload, _ := json.Marshal(c.ToJSON())
+		p.fail(sig.Send(server.Message{Type: "ice-candidate", Payload: payload}))
+	})
+	sig.Handle("ice-candidate", func(ev Event) {
+		var msg struct {
+			Payload webrtc.ICECandidateInit `json:"payload"`
+		}
+		if err := ev.Decode(&msg); err != nil {
+			p.fail(err)
+			return
+		}
+		p.addCandidate(msg.Payload)
+	})
+	return p, nil
+}
+
+// addCandidate adds a remote candidate, or queues it until the remote
+// description is set.
+func (p *peer) addCandidate(candidate webrtc.ICECandidateInit) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.early {
+		return
+	}
+	if p.pc.RemoteDescription() == nil {
+		p.pending = append(p.pending, candidate)
+		return
+	}
+	if err := p.pc.AddICECandidate(candidate); err != nil {
+		p.err = err
+	}
+}
+
+// setRemote sets the remote description and adds the queued candidates.
+func (p *peer) setRemote(desc webrtc.SessionDescription) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.pc.SetRemoteDescription(desc); err != nil {
+		return err
+	}
+	for _, candidate := range p.pending {
+		if err := p.pc.AddICECandidate(candidate); err != nil {
+			return err
+		}
+	}
+	p.pending = nil
+	return nil
+}
+
+// setLocal sets the local description. Sending candidates first, it waits
+// until all of them were gathered, and so sent.
+func (p *peer) setLocal(desc webrtc.SessionDescription) error {
+	p.mu.Lock()
+	early := p.early
+	p.mu.Unlock()
+	if !early {
+		return p.pc.SetLocalDescription(desc)
+	}
+
+	gathered := webrtc.GatheringCompletePromise(p.pc)
+	if err := p.pc.SetLocalDescription(desc); err != nil {
+		return err
+	}
+	<-gathered
+	return nil
+}
+
+// sendDescription sends a description as a message of type typ. It is the
+// one created, without candidates, which trickle separately.
+func (p *peer) sendDescription(typ string, desc webrtc.SessionDescription) error {
+	payload, err := json.Marshal(desc)
+	if err != nil {
+		return err
+	}
+	return p.signal.Send(server.Message{Type: typ, Payload: payload})
+}
+
+// SendCandidatesFirst makes the peer send all its ICE candidates ahead of
+// its offer or answer and ignore the server's, so it only connects if the
+// server queues the candidates that arrive early and checks them once the
+// description is set. Call it before signaling starts.
+func (p *peer) SendCandidatesFirst() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.early = true
+}
+
+// RemoteDescription returns the description the server sent, or nil.
+func (p *peer) RemoteDescription() *webrtc.SessionDescription {
+	return p.pc.RemoteDescription()
+}
+
+func (p *peer) fail(err error) {
+	if err == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Err returns the latest signaling failure, if any.
+func (p *peer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// waitConnected waits until the peer connection is connected.
+func (p *peer) waitConnected(ctx context.Context) error {
+	return poll(ctx, func() error {
+		switch state := p.pc.ConnectionState(); state {
+		case webrtc.PeerConnectionStateConnected:
+			return nil
+		default:
+			if err := p.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("peer connection %s", state)
+		}
+	})
+}
+
+// Close closes the peer connection.
+func (p *peer) Close() error {
+	return p.pc.Close()
+}
+
+// Presenter publishes synthetic VP8 and Opus media to a room, as a
+// presenter's browser would.
+type Presenter struct {
+	*peer
+	video *webrtc.TrackLocalStaticRTP
+	audio *webrtc.TrackLocalStaticRTP
+
+	videoSeq, audioSeq uint16
+	videoTS, audioTS   uint32
+}
+
+// NewPresenter creates a presenter signaling over sig, which should have
+// joined a room as the presenter.
+func NewPresenter(sig *Signal) (*Presenter, error) {
+	p, err := newPeer(sig)
+	if err != nil {
+		return nil, err
+	}
+	video, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "video", "presenter")
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	audio, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "presenter")
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	for _, track := range []webrtc.TrackLocal{video, audio} {
+		sender, err := p.pc.AddTrack(track)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		go drainRTCP(sender)
+	}
+
+	sig.Handle("answer", func(ev Event) {
+		var msg struct {
+			Payload webrtc.SessionDescription `json:"payload"`
+		}
+		if err := ev.Decode(&msg); err != nil {
+			p.fail(err)
+			return
+		}
+		p.fail(p.setRemote(msg.Payload))
+	})
+	return &Presenter{peer: p, video: video, audio: audio}, nil
+}
+
+// Publish offers the presenter's tracks to the server and waits until the
+// connection is up.
+func (p *Presenter) Publish(ctx context.Context) error {
+	offer, err := p.pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := p.setLocal(offer); err != nil {
+		return err
+	}
+	if err := p.sendDescription("offer", offer); err != nil {
+		return err
+	}
+	return p.waitConnected(ctx)
+}
+
+// WriteVideoFrame sends one video frame, a keyframe.
+func (p *Presenter) WriteVideoFrame() error {
+	p.videoSeq++
+	p.videoTS += 90000 / 30
+	return p.video.WriteRTP(&rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         true,
+			SequenceNumber: p.videoSeq,
+			Timestamp:      p.videoTS,
+		},
+		Payload: vp8Keyframe,
+	})
+}
+
+// WriteAudioFrame sends 20ms of silence.
+func (p *Presenter) WriteAudioFrame() error {
+	p.audioSeq++
+	p.audioTS += 48000 / 50
+	return p.audio.WriteRTP(&rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			SequenceNumber: p.audioSeq,
+			Timestamp:      p.audioTS,
+		},
+		Payload: opusSilence,
+	})
+}
+
+// Stream sends video at 30fps and audio in 20ms frames until ctx is done.
+func (p *Presenter) Stream(ctx context.Context) error {
+	video := time.NewTicker(videoFrameGap)
+	defer video.Stop()
+	audio := time.NewTicker(audioFrameGap)
+	defer audio.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-video.C:
+			if err := p.WriteVideoFrame(); err != nil {
+				return err
+			}
+		case <-audio.C:
+			if err := p.WriteAudioFrame(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Viewer receives a room's stream, answering the offers the server pushes
+// as a viewer's browser would, and counts the RTP packets that arrive.
+type Viewer struct {
+	*peer
+	video atomic.Int64
+	audio atomic.Int64
+}
+
+// NewViewer creates a viewer signaling over sig, which should have joined a
+// room as a viewer.
+func NewViewer(sig *Signal) (*Viewer, error) {
+	p, err := newPeer(sig)
+	if err != nil {
+		return nil, err
+	}
+	v := &Viewer{peer: p}
+
+	p.pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		counter := &v.audio
+		if track.Kind() == webrtc.RTPCodecTypeVideo {
+			counter = &v.video
+		}
+		go func() {
+			buf := make([]byte, 1500)
+			for {
+				if _, _, err := track.Read(buf); err != nil {
+					return
+				}
+				counter.Add(1)
+			}
+		}()
+	})
+
+	sig.Handle("offer", func(ev Event) {
+		var msg struct {
+			Payload webrtc.SessionDescription `json:"payload"`
+		}
+		if err := ev.Decode(&msg); err != nil {
+			p.fail(err)
+			return
+		}
+		p.fail(v.answer(msg.Payload))
+	})
+	return v, nil
+}
+
+// answer answers an offer pushed by the server.
+func (v *Viewer) answer(offer webrtc.SessionDescription) error {
+	if err := v.setRemote(offer); err != nil {
+		return err
+	}
+	answer, err := v.pc.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := v.setLocal(answer); err != nil {
+		return err
+	}
+	return v.sendDescription("answer", answer)
+}
+
+// RequestStream asks for the stream, which the server otherwise pushes once
+// the presenter is ready.
+func (v *Viewer) RequestStream() error {
+	return v.signal.Send(server.Message{Type: "request-stream"})
+}
+
+// Packets returns how many RTP packets of a kind have arrived.
+func (v *Viewer) Packets(kind webrtc.RTPCodecType) int64 {
+	if kind == webrtc.RTPCodecTypeVideo {
+		return v.video.Load()
+	}
+	return v.audio.Load()
+}
+
+// WaitForMedia waits until at least n RTP packets of a kind have arrived.
+func (v *Viewer) WaitForMedia(ctx context.Context, kind webrtc.RTPCodecType, n int64) error {
+	return poll(ctx, func() error {
+		if got := v.Packets(kind); got < n {
+			if err := v.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("%d of %d %s packets", got, n, kind)
+		}
+		return nil
+	})
+}
+
+// drainRTCP reads a sender's RTCP, which pion needs read for its
+// interceptors to run.
+func drainRTCP(sender *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := sender.Read(buf); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package timeline
+
+import (
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// Causes of poor quality in a heatmap minute
+const (
+	CausePresenter = "presenter" // The presenter's uplink was poor or stalled
+	CauseViewers   = "viewers"   // Some viewers' connections, with the uplink fine
+)
+
+// HeatmapBin is one minute of a session's network quality heatmap. The
+// viewer counts are those of the minute's worst sample, the one with the
+// most viewers poor or disconnected.
+type HeatmapBin struct {
+	Minute            int       `json:"minute"` // Since the session started
+	At                time.Time `json:"at"`
+	Samples           int       `json:"samples"`
+	UplinkLossPercent float64   `json:"uplinkLossPercent"` // Worst in the minute
+	Uplink            string    `json:"uplink"`            // Worst band in the minute
+	Good              int       `json:"good"`
+	Fair              int       `json:"fair"`
+	Poor              int       `json:"poor"`
+	Disconnected      int       `json:"disconnected"`
+	Cause             string    `json:"cause,omitempty"` // Why viewers had trouble, if they did
+}
+
+// bandRank orders quality bands from best to worst.
+var bandRank = map[string]int{
+	string(room.QualityGood):    0,
+	string(room.QualityUnknown): 1,
+	string(room.QualityFair):    2,
+	string(room.QualityPoor):    3,
+}
+
+// heatmap bins a session's quality samples, which must be in time order, by
+// minute since start.
+func heatmap(start time.Time, samples []models.RoomEvent) []HeatmapBin {
+	var bins []HeatmapBin
+	for _, event := range samples {
+		q := event.Quality
+		minute := int(event.At.Sub(start) / time.Minute)
+
+		if len(bins) == 0 || bins[len(bins)-1].Minute != minute {
+			bins = append(bins, HeatmapBin{
+				Minute: minute,
+				At:     start.Add(time.Duration(minute) * time.Minute),
+				Uplink: q.Uplink,
+			})
+		}
+		bin := &bins[len(bins)-1]
+
+		if bin.Samples == 0 || q.Poor+q.Disconnected > bin.Poor+bin.Disconnected {
+			bin.Good, bin.Fair, bin.Poor, bin.Disconnected = q.Good, q.Fair, q.Poor, q.Disconnected
+		}
+		if q.UplinkLossPercent > bin.UplinkLossPercent {
+			bin.UplinkLossPercent = q.UplinkLossPercent
+		}
+		if bandRank[q.Uplink] > bandRank[bin.Uplink] {
+			bin.Uplink = q.Uplink
+		}
+		bin.Samples++
+	}
+
+	for i := range bins {
+		bins[i].Cause = cause(bins[i])
+	}
+	return bins
+}
+
+// cause tells whether trouble in a minute came from the presenter or from
+// some viewers. A poor uplink is the presenter's; so is an uplink carrying
+// almost nothing while at least half the viewers are affected, as when the
+// presenter's connection stalls.
+func cause(bin HeatmapBin) string {
+	affected := bin.Poor + bin.Disconnected
+	viewers := affected + bin.Good + bin.Fair
+	switch {
+	case bin.Uplink == string(room.QualityPoor):
+		return CausePresenter
+	case affected == 0:
+		return ""
+	case bin.Uplink == string(room.QualityUnknown) && affected*2 >= viewers:
+		return CausePresenter
+	default:
+		return CauseViewers
+	}
+}
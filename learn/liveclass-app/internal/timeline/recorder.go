@@ -0,0 +1,148 @@
+// Package timeline records the lifecycle of live rooms as an append-only
+// event stream and derives per-session reports from it, so what happened
+// during a class can be looked up after the fact.
+package timeline
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/metrics"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Write batching
+const (
+	flushInterval = time.Second
+	batchSize     = 200
+	queueSize     = 10000
+)
+
+// recordedEvents counts room lifecycle events by outcome (stored, dropped).
+var recordedEvents = metrics.NewCounterVec(
+	"liveclass_room_events_total",
+	"Room lifecycle events by outcome (stored, dropped).",
+	"result",
+)
+
+// Recorder appends room lifecycle events to the database in the background.
+type Recorder struct {
+	repo     *repository.RoomEventRepository
+	instance string
+	queue    chan models.RoomEvent
+	done     chan struct{}
+}
+
+// NewRecorder creates a recorder writing to repo. instance is recorded on
+// every event to tell instances apart.
+func NewRecorder(repo *repository.RoomEventRepository, instance string) *Recorder {
+	return &Recorder{
+		repo:     repo,
+		instance: instance,
+		queue:    make(chan models.RoomEvent, queueSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// Record queues a lifecycle event. It never blocks; events are dropped when
+// the queue is full. It is a room.LifecycleSink.
+func (r *Recorder) Record(event room.LifecycleEvent) {
+	entry := models.RoomEvent{
+		ID:            primitive.NewObjectID(), // Orders events recorded in the same instant
+		Type:          event.Type,
+		RoomID:        event.RoomID,
+		SessionID:     event.SessionID,
+		Instance:      r.instance,
+		ParticipantID: event.ParticipantID,
+		UserID:        event.UserID,
+		Name:          event.Name,
+		Viewers:       event.Viewers,
+		At:            event.At,
+	}
+	if q := event.Quality; q != nil {
+		entry.Quality = &models.QualitySample{
+			UplinkLossPercent: q.UplinkLossPercent,
+			Uplink:            string(q.Uplink),
+			Good:              q.Good,
+			Fair:              q.Fair,
+			Poor:              q.Poor,
+			Disconnected:      q.Disconnected,
+		}
+	}
+
+	select {
+	case r.queue <- entry:
+	default:
+		recordedEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Run writes queued events until ctx is cancelled, then writes what is left.
+func (r *Recorder) Run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.RoomEvent, 0, batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			r.drain(batch)
+			return
+		case event := <-r.queue:
+			batch = append(batch, event)
+			if len(batch) >= batchSize {
+				r.write(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.write(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// Wait blocks until Run has written the remaining events after cancellation,
+// or ctx expires.
+func (r *Recorder) Wait(ctx context.Context) {
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+	}
+}
+
+// drain writes the pending batch and the queued events.
+func (r *Recorder) drain(batch []models.RoomEvent) {
+	for {
+		select {
+		case event := <-r.queue:
+			batch = append(batch, event)
+		default:
+			r.write(batch)
+			return
+		}
+	}
+}
+
+// write stores a batch. Failed batches are dropped rather than retried, so
+// an unavailable database can't back up signaling.
+func (r *Recorder) write(batch []models.RoomEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.repo.Append(ctx, batch); err != nil {
+		log.Printf("[Timeline] Failed to store %d room events: %v", len(batch), err)
+		recordedEvents.WithLabelValues("dropped").Add(uint64(len(batch)))
+		return
+	}
+	recordedEvents.WithLabelValues("stored").Add(uint64(len(batch)))
+}
@@ -0,0 +1,145 @@
+package timeline
+
+import (
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/room"
+)
+
+// Session reports on one session of a room, from its creation until the
+// last participant left, as derived from its lifecycle events.
+type Session struct {
+	SessionID string     `json:"sessionId"`
+	RoomID    string     `json:"roomId"`
+	Instance  string     `json:"instance"`
+	StartedAt time.Time  `json:"startedAt"` // First event seen, if the creation is outside the window
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// Sessions on an instance that crashed never end
+	Ended           bool    `json:"ended"`
+	DurationSeconds float64 `json:"durationSeconds"` // Until the end or the last event
+
+	PresenterJoinedAt    *time.Time `json:"presenterJoinedAt,omitempty"`
+	FirstStreamAt        *time.Time `json:"firstStreamAt,omitempty"`
+	SecondsToFirstStream float64    `json:"secondsToFirstStream,omitempty"` // From the presenter joining
+	StreamStarts         int        `json:"streamStarts"`                   // More than one means the stream dropped and came back
+	PresenterLeaves      int        `json:"presenterLeaves"`
+	PresenterTakeovers   int        `json:"presenterTakeovers"` // Presenting moved to another device of the same account
+
+	ViewerJoins   int        `json:"viewerJoins"`
+	UniqueViewers int        `json:"uniqueViewers"`
+	PeakViewers   int        `json:"peakViewers"`
+	PeakAt        *time.Time `json:"peakAt,omitempty"`
+	Viewers       int        `json:"viewers"` // At the last event; live viewers while the session runs
+
+	// Network quality by minute, from the room's quality samples
+	Heatmap []HeatmapBin `json:"heatmap,omitempty"`
+}
+
+// Sessions derives a report for each session in events, which must be in
+// time order, in the order the sessions started.
+func Sessions(events []models.RoomEvent) []Session {
+	var sessions []*Session
+	byID := make(map[string]*Session)
+	viewers := make(map[string]map[string]bool)
+	samples := make(map[string][]models.RoomEvent)
+
+	for _, event := range events {
+		s, ok := byID[event.SessionID]
+		if !ok {
+			s = &Session{
+				SessionID: event.SessionID,
+				RoomID:    event.RoomID,
+				Instance:  event.Instance,
+				StartedAt: event.At,
+			}
+			byID[event.SessionID] = s
+			sessions = append(sessions, s)
+			viewers[event.SessionID] = make(map[string]bool)
+		}
+
+		at := event.At
+		switch event.Type {
+		case room.LifecyclePresenterJoined:
+			if s.PresenterJoinedAt == nil {
+				s.PresenterJoinedAt = &at
+			}
+		case room.LifecyclePresenterLeft:
+			s.PresenterLeaves++
+		case room.LifecyclePresenterTakeover:
+			s.PresenterTakeovers++
+		case room.LifecycleStreamReady:
+			s.StreamStarts++
+			if s.FirstStreamAt == nil {
+				s.FirstStreamAt = &at
+				if s.PresenterJoinedAt != nil {
+					s.SecondsToFirstStream = at.Sub(*s.PresenterJoinedAt).Seconds()
+				}
+			}
+		case room.LifecycleViewerJoined:
+			s.ViewerJoins++
+			viewer := event.UserID
+			if viewer == "" {
+				viewer = event.ParticipantID
+			}
+			viewers[event.SessionID][viewer] = true
+		case room.LifecycleEnded:
+			s.Ended = true
+			s.EndedAt = &at
+		case room.LifecycleQualitySample:
+			if event.Quality != nil {
+				samples[event.SessionID] = append(samples[event.SessionID], event)
+			}
+		}
+
+		s.Viewers = event.Viewers
+		if event.Viewers > s.PeakViewers {
+			s.PeakViewers = event.Viewers
+			s.PeakAt = &at
+		}
+		s.DurationSeconds = at.Sub(s.StartedAt).Seconds()
+	}
+
+	reports := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		s.UniqueViewers = len(viewers[s.SessionID])
+		s.Heatmap = heatmap(s.StartedAt, samples[s.SessionID])
+		reports = append(reports, *s)
+	}
+	return reports
+}
+
+// ViewerSeconds returns how long each signed-in viewer spent in the room
+// across the sessions in events, which must be in time order. Viewers
+// still present at the last event of a session count until that event.
+func ViewerSeconds(events []models.RoomEvent) map[string]float64 {
+	type presence struct {
+		session string
+		userID  string
+		since   time.Time
+	}
+	seconds := make(map[string]float64)
+	present := make(map[string]presence) // By session and participant
+	last := make(map[string]time.Time)   // Last event of each session
+
+	for _, event := range events {
+		last[event.SessionID] = event.At
+		key := event.SessionID + "/" + event.ParticipantID
+		switch event.Type {
+		case room.LifecycleViewerJoined:
+			if event.UserID != "" {
+				present[key] = presence{session: event.SessionID, userID: event.UserID, since: event.At}
+			}
+		case room.LifecycleViewerLeft:
+			if p, ok := present[key]; ok {
+				seconds[p.userID] += event.At.Sub(p.since).Seconds()
+				delete(present, key)
+			}
+		}
+	}
+
+	for _, p := range present {
+		seconds[p.userID] += last[p.session].Sub(p.since).Seconds()
+	}
+	return seconds
+}
@@ -0,0 +1,277 @@
+// Package validate checks request structs against rules declared in
+// `validate` struct tags, reporting failures per JSON field.
+//
+// Rules are comma separated:
+//
+//	required   the value must not be zero (strings must not be blank)
+//	min=N      strings: at least N characters; numbers: at least N; slices: at least N items
+//	max=N      strings: at most N characters; numbers: at most N; slices: at most N items
+//	email      a bare email address
+//	objectid   a MongoDB ObjectID in hex; applies to each element of a []string
+//	rfc3339    a time in RFC 3339 format
+//	url        an absolute http(s) URL
+//	hexcolor   a CSS hex color, #rgb or #rrggbb
+//	clock      a 24-hour time of day, e.g. 22:30
+//	timezone   an IANA time zone name, e.g. Asia/Kolkata
+//	oneof=a b  one of the space separated values
+//
+// Format rules and min on strings skip empty values, so optional fields only
+// need `required` when they must be present. Nested structs and slices of
+// structs are validated recursively.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Errors maps JSON field paths (e.g. "title" or "objectives[2].text") to
+// what is wrong with them.
+type Errors map[string]string
+
+// Error lists the failures sorted by field.
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, field := range fields {
+		msgs[i] = field + " " + e[field]
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct validates v, a struct or pointer to a struct. It returns Errors when
+// any field fails its rules. Malformed tags panic, as they are programming
+// errors.
+func Struct(v interface{}) error {
+	errs := Errors{}
+	checkStruct(reflect.Indirect(reflect.ValueOf(v)), "", errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkStruct validates the fields of a struct value.
+func checkStruct(v reflect.Value, prefix string, errs Errors) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + fieldName(field)
+		value := v.Field(i)
+
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			if msg := checkRules(value, tag); msg != "" {
+				errs[name] = msg
+				continue
+			}
+		}
+		checkNested(value, name, errs)
+	}
+}
+
+// checkNested validates structs nested in a field.
+func checkNested(v reflect.Value, name string, errs Errors) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			checkNested(v.Elem(), name, errs)
+		}
+	case reflect.Struct:
+		if v.Type() != reflect.TypeOf(time.Time{}) {
+			checkStruct(v, name+".", errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if reflect.Indirect(reflect.New(v.Type().Elem())).Kind() != reflect.Struct {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			checkNested(v.Index(i), fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+	}
+}
+
+// fieldName returns the JSON name of a struct field.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// checkRules applies the rules in tag to v and returns the first failure.
+func checkRules(v reflect.Value, tag string) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if strings.Contains(","+tag+",", ",required,") {
+				return "is required"
+			}
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if msg := checkRule(v, name, param); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// checkRule applies a single rule.
+func checkRule(v reflect.Value, rule, param string) string {
+	switch rule {
+	case "required":
+		if isBlank(v) {
+			return "is required"
+		}
+	case "min":
+		return checkBound(v, rule, param)
+	case "max":
+		return checkBound(v, rule, param)
+	case "email":
+		if s := v.String(); s != "" {
+			if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+				return "must be a valid email address"
+			}
+		}
+	case "objectid":
+		if v.Kind() == reflect.Slice {
+			for i := 0; i < v.Len(); i++ {
+				if !primitive.IsValidObjectID(v.Index(i).String()) {
+					return "must only contain valid IDs"
+				}
+			}
+		} else if s := v.String(); s != "" && !primitive.IsValidObjectID(s) {
+			return "must be a valid ID"
+		}
+	case "rfc3339":
+		if s := v.String(); s != "" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return "must be an RFC 3339 time (e.g. 2024-01-15T10:00:00Z)"
+			}
+		}
+	case "url":
+		if s := v.String(); s != "" {
+			u, err := url.Parse(s)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return "must be an http(s) URL"
+			}
+		}
+	case "hexcolor":
+		if s := v.String(); s != "" && !isHexColor(s) {
+			return "must be a hex color (e.g. #1a73e8)"
+		}
+	case "clock":
+		if s := v.String(); s != "" {
+			if _, err := time.Parse("15:04", s); err != nil {
+				return "must be a time of day (e.g. 22:30)"
+			}
+		}
+	case "timezone":
+		if s := v.String(); s != "" {
+			if _, err := time.LoadLocation(s); err != nil {
+				return "must be a time zone (e.g. Asia/Kolkata)"
+			}
+		}
+	case "oneof":
+		if s := v.String(); s != "" {
+			allowed := strings.Fields(param)
+			for _, a := range allowed {
+				if s == a {
+					return ""
+				}
+			}
+			return "must be one of: " + strings.Join(allowed, ", ")
+		}
+	default:
+		panic("validate: unknown rule " + strconv.Quote(rule))
+	}
+	return ""
+}
+
+// isHexColor reports whether s is #rgb or #rrggbb.
+func isHexColor(s string) bool {
+	if len(s) != 4 && len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkBound applies a min or max rule.
+func checkBound(v reflect.Value, rule, param string) string {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic("validate: invalid " + rule + " parameter " + strconv.Quote(param))
+	}
+	tooSmall := func(got int64) bool { return rule == "min" && got < int64(n) }
+	tooLarge := func(got int64) bool { return rule == "max" && got > int64(n) }
+	bound := "at least"
+	if rule == "max" {
+		bound = "at most"
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if s == "" && rule == "min" {
+			return ""
+		}
+		if got := int64(utf8.RuneCountInString(s)); tooSmall(got) || tooLarge(got) {
+			return fmt.Sprintf("must be %s %d characters", bound, n)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if got := v.Int(); tooSmall(got) || tooLarge(got) {
+			return fmt.Sprintf("must be %s %d", bound, n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if got := int64(v.Uint()); tooSmall(got) || tooLarge(got) {
+			return fmt.Sprintf("must be %s %d", bound, n)
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if got := int64(v.Len()); tooSmall(got) || tooLarge(got) {
+			return fmt.Sprintf("must have %s %d items", bound, n)
+		}
+	default:
+		panic("validate: " + rule + " does not apply to " + v.Kind().String())
+	}
+	return ""
+}
+
+// isBlank reports whether v is the zero value or a blank string.
+func isBlank(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
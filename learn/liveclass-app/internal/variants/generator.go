@@ -0,0 +1,244 @@
+package variants
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/encryption"
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+	"github.com/jinshatcp/brightline-academy/learn/internal/repository"
+)
+
+// Limits of variant generation
+const (
+	queueSize        = 64
+	backfillMax      = 10 // Recordings processed per backfill pass
+	transcodeTimeout = 2 * time.Hour
+)
+
+// Generator makes the variants of recordings in the background: once they
+// are ready after upload or compositing, and in periodic passes that pick up
+// recordings made before variants existed, missed while the queue was full,
+// or restored from cold storage.
+//
+// Recordings are claimed with conditional updates, so instances sharing the
+// database can all run it.
+type Generator struct {
+	recordingRepo *repository.RecordingRepository
+	transcoder    *Transcoder
+	files         *encryption.Encryptor // nil stores files in plaintext
+	interval      time.Duration
+	queue         chan *models.Recording
+}
+
+// NewGenerator creates a generator with a backfill pass every interval (0
+// for none). A nil transcoder disables it.
+func NewGenerator(recordingRepo *repository.RecordingRepository, transcoder *Transcoder, files *encryption.Encryptor, interval time.Duration) *Generator {
+	return &Generator{
+		recordingRepo: recordingRepo,
+		transcoder:    transcoder,
+		files:         files,
+		interval:      interval,
+		queue:         make(chan *models.Recording, queueSize),
+	}
+}
+
+// Enqueue schedules variants for a recording that just became ready. It
+// never blocks; if the queue is full the next backfill pass picks the
+// recording up.
+func (g *Generator) Enqueue(recording *models.Recording) {
+	if g.transcoder == nil {
+		return
+	}
+	select {
+	case g.queue <- recording:
+	default:
+		log.Printf("[Variants] Queue full, %s left for the next pass", recording.ID.Hex())
+	}
+}
+
+// Run processes queued recordings, and any still without variants
+// immediately and then every interval, until ctx is cancelled.
+func (g *Generator) Run(ctx context.Context) {
+	if g.transcoder == nil {
+		return
+	}
+
+	var tick <-chan time.Time
+	if g.interval > 0 {
+		g.backfill(ctx)
+		ticker := time.NewTicker(g.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case recording := <-g.queue:
+			g.process(ctx, recording)
+		case <-tick:
+			g.backfill(ctx)
+		}
+	}
+}
+
+// backfill processes recordings that have no variants yet.
+func (g *Generator) backfill(ctx context.Context) {
+	findCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	recordings, err := g.recordingRepo.FindWithoutVariants(findCtx, backfillMax)
+	cancel()
+	if err != nil {
+		log.Printf("[Variants] Failed to load recordings without variants: %v", err)
+		return
+	}
+	for i := range recordings {
+		if ctx.Err() != nil {
+			return
+		}
+		g.process(ctx, &recordings[i])
+	}
+}
+
+// process claims a recording and makes its variants. The recording stays
+// claimed on failure, so a broken file isn't transcoded every pass; the
+// variants that could be made are kept.
+func (g *Generator) process(ctx context.Context, recording *models.Recording) {
+	claimed, err := g.recordingRepo.ClaimVariants(ctx, recording.ID)
+	if err != nil {
+		log.Printf("[Variants] Failed to claim %s: %v", recording.ID.Hex(), err)
+		return
+	}
+	if !claimed {
+		return // Handled by another instance
+	}
+
+	transcodeCtx, cancel := context.WithTimeout(ctx, transcodeTimeout)
+	defer cancel()
+
+	started := time.Now()
+	made, err := g.transcode(transcodeCtx, recording)
+	if err != nil {
+		log.Printf("[Variants] Failed to make variants of %s (%s): %v", recording.Title, recording.ID.Hex(), err)
+	}
+	if len(made) == 0 {
+		return
+	}
+
+	saved, err := g.recordingRepo.SetVariants(context.WithoutCancel(ctx), recording.ID, recording.FilePath, made)
+	if err != nil || !saved {
+		if err != nil {
+			log.Printf("[Variants] Failed to save variants of %s: %v", recording.ID.Hex(), err)
+		}
+		Remove(made)
+		return
+	}
+	log.Printf("[Variants] Made %d variant(s) of %s in %v", len(made), recording.Title, time.Since(started).Round(time.Second))
+}
+
+// transcode makes each variant of a recording next to it, encrypted when
+// enabled. ffmpeg reads and writes plaintext, so temporary files are used
+// throughout. It returns the variants made before any failure.
+func (g *Generator) transcode(ctx context.Context, recording *models.Recording) ([]models.RecordingVariant, error) {
+	in, cleanup, err := g.plaintext(ctx, recording.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	base := strings.TrimSuffix(recording.FilePath, filepath.Ext(recording.FilePath))
+	var made []models.RecordingVariant
+	for _, spec := range Specs {
+		out, err := os.CreateTemp("", "variant-*"+spec.Ext)
+		if err != nil {
+			return made, err
+		}
+		out.Close()
+
+		err = g.transcoder.Transcode(ctx, spec, in, out.Name())
+		filePath := base + "_" + spec.Name + spec.Ext
+		var fileSize int64
+		if err == nil {
+			fileSize, err = g.store(ctx, out.Name(), filePath)
+		}
+		os.Remove(out.Name())
+		if err != nil {
+			os.Remove(filePath)
+			return made, err
+		}
+
+		made = append(made, models.RecordingVariant{
+			Name:     spec.Name,
+			FilePath: filePath,
+			FileSize: fileSize,
+			MimeType: spec.MimeType,
+		})
+	}
+	return made, nil
+}
+
+// store copies the plaintext file at src to dst, encrypted when enabled.
+func (g *Generator) store(ctx context.Context, src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := g.files.Create(ctx, dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// plaintext returns a path ffmpeg can read the file at path from: the file
+// itself, or a decrypted temporary copy removed by cleanup.
+func (g *Generator) plaintext(ctx context.Context, path string) (string, func(), error) {
+	_, encrypted, err := encryption.KeyID(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !encrypted {
+		return path, func() {}, nil
+	}
+
+	src, err := g.files.Open(ctx, path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "variant-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// Remove deletes the files of a recording's variants.
+func Remove(variants []models.RecordingVariant) {
+	for _, variant := range variants {
+		os.Remove(variant.FilePath)
+	}
+}
@@ -0,0 +1,88 @@
+// Package variants makes smaller copies of recordings, a 480p video and an
+// audio-only track, for students on slow or metered connections.
+package variants
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/jinshatcp/brightline-academy/learn/internal/models"
+)
+
+// Spec describes how a variant is made.
+type Spec struct {
+	Name     string
+	Ext      string // File extension, with the dot
+	MimeType string
+	args     []string // ffmpeg output options
+}
+
+// Specs are the variants made of every recording. Both are written with
+// their index first, so players can start before downloading everything.
+var Specs = []Spec{
+	{
+		Name:     models.RecordingVariant480p,
+		Ext:      ".mp4",
+		MimeType: "video/mp4",
+		args: []string{
+			"-vf", "scale=-2:'min(480,ih)'",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "28", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-b:a", "96k",
+			"-movflags", "+faststart",
+		},
+	},
+	{
+		Name:     models.RecordingVariantAudio,
+		Ext:      ".m4a",
+		MimeType: "audio/mp4",
+		args: []string{
+			"-vn",
+			"-c:a", "aac", "-b:a", "64k",
+			"-movflags", "+faststart",
+		},
+	},
+}
+
+// Known reports whether name is the name of a variant.
+func Known(name string) bool {
+	for _, spec := range Specs {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Transcoder makes variants with ffmpeg.
+type Transcoder struct {
+	ffmpeg string
+}
+
+// NewTranscoder creates a transcoder running the ffmpeg binary at path.
+func NewTranscoder(path string) *Transcoder {
+	return &Transcoder{ffmpeg: path}
+}
+
+// Available reports whether the ffmpeg binary can be found.
+func (t *Transcoder) Available() bool {
+	_, err := exec.LookPath(t.ffmpeg)
+	return err == nil
+}
+
+// Transcode writes the variant spec of the plaintext recording at in to out.
+func (t *Transcoder) Transcode(ctx context.Context, spec Spec, in, out string) error {
+	args := []string{"-hide_banner", "-nostats", "-loglevel", "error", "-y", "-i", in, "-sn", "-dn"}
+	args = append(args, spec.args...)
+	args = append(args, out)
+
+	cmd := exec.CommandContext(ctx, t.ffmpeg, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package verification defines pluggable identity-verification providers used
+// to gate proctored classes.
+package verification
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrInvalidSignature is returned when a webhook signature doesn't match.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrInvalidPayload is returned when a webhook body can't be parsed.
+	ErrInvalidPayload = errors.New("invalid webhook payload")
+)
+
+// Result is the outcome an external provider reports for a verification.
+type Result struct {
+	Reference string
+	Verified  bool
+	Reason    string
+}
+
+// Provider verifies identities through an external service that reports
+// results back via webhook.
+type Provider interface {
+	// Name identifies the provider in URLs and stored records.
+	Name() string
+	// ParseWebhook authenticates a webhook request and returns its result.
+	ParseWebhook(header http.Header, body []byte) (*Result, error)
+}
+
+// Registry holds the configured providers by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry creates a registry with the given providers.
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+// Get returns the provider with the given name.
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// HMACProvider accepts webhooks signed with a shared secret. The signature is
+// the hex HMAC-SHA256 of the raw body, sent in the X-Signature header. The body
+// is {"reference": "...", "status": "verified"|"rejected", "reason": "..."}.
+type HMACProvider struct {
+	name   string
+	secret []byte
+}
+
+// NewHMACProvider creates a shared-secret webhook provider.
+func NewHMACProvider(name, secret string) *HMACProvider {
+	return &HMACProvider{name: name, secret: []byte(secret)}
+}
+
+// Name returns the provider name.
+func (p *HMACProvider) Name() string {
+	return p.name
+}
+
+// ParseWebhook verifies the signature and decodes the result.
+func (p *HMACProvider) ParseWebhook(header http.Header, body []byte) (*Result, error) {
+	signature, err := hex.DecodeString(header.Get("X-Signature"))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload struct {
+		Reference string `json:"reference"`
+		Status    string `json:"status"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Reference == "" {
+		return nil, ErrInvalidPayload
+	}
+
+	return &Result{
+		Reference: payload.Reference,
+		Verified:  payload.Status == "verified",
+		Reason:    payload.Reason,
+	}, nil
+}
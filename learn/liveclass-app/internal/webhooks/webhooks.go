@@ -0,0 +1,225 @@
+// Package webhooks authenticates events that external systems (payments, an
+// LMS) post to the webhook inbox and routes them to actions.
+//
+// Each source has a shared secret. Requests carry the Unix time they were
+// signed at in the X-Signature-Timestamp header and the hex HMAC-SHA256 of
+// "<timestamp>.<raw body>" in the X-Signature header, and the body is
+// {"id": "...", "type": "...", "data": {...}}. Requests signed outside the
+// dispatcher's tolerance are refused, so a captured request can't be
+// replayed later. Which action handles an event type is configured per
+// source, so each system keeps its own event names.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownSource is returned for sources without a configured secret.
+	ErrUnknownSource = errors.New("unknown webhook source")
+	// ErrInvalidSignature is returned when a webhook signature doesn't match.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleSignature is returned for webhooks without a signing time or
+	// signed outside the tolerance.
+	ErrStaleSignature = errors.New("webhook signature timestamp missing or outside tolerance")
+	// ErrInvalidPayload is returned when a webhook body can't be parsed.
+	ErrInvalidPayload = errors.New("invalid webhook payload")
+)
+
+// Event is an authenticated event from a source.
+type Event struct {
+	Source string          `json:"-"`
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Action applies an event and returns a result that's stored and sent back
+// to the source. Errors from Reject mean the event itself can't be applied;
+// other errors are treated as temporary.
+type Action func(ctx context.Context, event *Event) (map[string]interface{}, error)
+
+// RejectedError is an event the action refused, such as one naming an
+// unknown user. Sources shouldn't retry it unchanged.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return e.Reason
+}
+
+// Reject returns a RejectedError with a formatted reason.
+func Reject(format string, args ...interface{}) error {
+	return &RejectedError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Route sends events of one type from a source to an action.
+type Route struct {
+	Source string
+	Type   string
+	Action string
+}
+
+// Dispatcher verifies events and routes them to registered actions.
+type Dispatcher struct {
+	secrets   map[string][]byte
+	routes    map[string]map[string]string // Source, then event type, to action
+	actions   map[string]Action
+	tolerance time.Duration // How far a signing time may be from now
+}
+
+// NewDispatcher creates a dispatcher for sources with the given secrets,
+// accepting requests signed within tolerance of the time they arrive.
+func NewDispatcher(secrets map[string]string, routes []Route, tolerance time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		secrets:   make(map[string][]byte, len(secrets)),
+		routes:    make(map[string]map[string]string),
+		actions:   make(map[string]Action),
+		tolerance: tolerance,
+	}
+	for source, secret := range secrets {
+		d.secrets[source] = []byte(secret)
+	}
+	for _, route := range routes {
+		if d.routes[route.Source] == nil {
+			d.routes[route.Source] = make(map[string]string)
+		}
+		d.routes[route.Source][route.Type] = route.Action
+	}
+	return d
+}
+
+// Handle registers an action under a name routes can refer to.
+func (d *Dispatcher) Handle(name string, action Action) {
+	d.actions[name] = action
+}
+
+// Enabled reports whether any source is configured.
+func (d *Dispatcher) Enabled() bool {
+	return len(d.secrets) > 0
+}
+
+// Known reports whether source is configured.
+func (d *Dispatcher) Known(source string) bool {
+	_, ok := d.secrets[source]
+	return ok
+}
+
+// Check returns an error describing routes that can never run: those of
+// sources without a secret or naming unregistered actions.
+func (d *Dispatcher) Check() error {
+	var problems []string
+	for source, types := range d.routes {
+		if _, ok := d.secrets[source]; !ok {
+			problems = append(problems, fmt.Sprintf("source %q has routes but no secret", source))
+		}
+		for eventType, action := range types {
+			if _, ok := d.actions[action]; !ok {
+				problems = append(problems, fmt.Sprintf("%s:%s routes to unknown action %q", source, eventType, action))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// Verify authenticates a request from source and decodes its event.
+func (d *Dispatcher) Verify(source string, header http.Header, body []byte) (*Event, error) {
+	secret, ok := d.secrets[source]
+	if !ok {
+		return nil, ErrUnknownSource
+	}
+
+	timestamp := header.Get("X-Signature-Timestamp")
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrStaleSignature
+	}
+	if skew := time.Since(time.Unix(signedAt, 0)); skew > d.tolerance || skew < -d.tolerance {
+		return nil, ErrStaleSignature
+	}
+
+	signature, err := hex.DecodeString(strings.TrimPrefix(header.Get("X-Signature"), "sha256="))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if !hmac.Equal(signature, Sign(secret, timestamp, body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.Type == "" {
+		return nil, ErrInvalidPayload
+	}
+	event.Source = source
+	return &event, nil
+}
+
+// Sign returns the HMAC-SHA256 a source sends for body signed at timestamp,
+// a Unix time in seconds.
+func Sign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// Action returns the name and action an event is routed to, or false if
+// the source doesn't route its type anywhere.
+func (d *Dispatcher) Action(event *Event) (string, Action, bool) {
+	name, ok := d.routes[event.Source][event.Type]
+	if !ok {
+		return "", nil, false
+	}
+	action, ok := d.actions[name]
+	return name, action, ok
+}
+
+// ParseSecrets parses "source=secret" entries.
+func ParseSecrets(entries []string) (map[string]string, error) {
+	secrets := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		source, secret, ok := strings.Cut(entry, "=")
+		source = strings.TrimSpace(source)
+		if !ok || source == "" || secret == "" {
+			return nil, fmt.Errorf("invalid webhook secret %q, want source=secret", entry)
+		}
+		secrets[source] = secret
+	}
+	return secrets, nil
+}
+
+// ParseRoutes parses "source:type=action" entries.
+func ParseRoutes(entries []string) ([]Route, error) {
+	routes := make([]Route, 0, len(entries))
+	for _, entry := range entries {
+		key, action, ok := strings.Cut(entry, "=")
+		source, eventType, ok2 := strings.Cut(key, ":")
+		route := Route{
+			Source: strings.TrimSpace(source),
+			Type:   strings.TrimSpace(eventType),
+			Action: strings.TrimSpace(action),
+		}
+		if !ok || !ok2 || route.Source == "" || route.Type == "" || route.Action == "" {
+			return nil, fmt.Errorf("invalid webhook route %q, want source:type=action", entry)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
@@ -107,6 +107,30 @@ export function Notes() {
     }
   };
 
+  // Notes are fetched with the Authorization header and opened from memory,
+  // so the login token never ends up in a URL
+  const handleOpen = async (note: Note) => {
+    // Open the tab now, while the click still allows pop-ups
+    const tab = window.open('', '_blank');
+    try {
+      const response = await fetch(`${API_BASE}${note.downloadUrl}`, {
+        headers: { Authorization: `Bearer ${token}` },
+      });
+      if (!response.ok) throw new Error('Failed to open note');
+
+      const url = URL.createObjectURL(await response.blob());
+      if (tab) {
+        tab.location.href = url;
+      } else {
+        window.location.href = url;
+      }
+      setTimeout(() => URL.revokeObjectURL(url), 60_000);
+    } catch (err) {
+      tab?.close();
+      setError(err instanceof Error ? err.message : 'Failed to open note');
+    }
+  };
+
   const handleDelete = async (noteId: string) => {
     if (!confirm('Are you sure you want to delete this note?')) return;
 
@@ -382,10 +406,8 @@ export function Notes() {
                 </div>
               </div>
               <div className="note-actions">
-                <a
-                  href={`${API_BASE}${note.downloadUrl}?token=${token}`}
-                  target="_blank"
-                  rel="noopener noreferrer"
+                <button
+                  onClick={() => handleOpen(note)}
                   className="btn-icon"
                   title="View/Download"
                 >
@@ -394,7 +416,7 @@ export function Notes() {
                     <polyline points="7 10 12 15 17 10" />
                     <line x1="12" y1="15" x2="12" y2="3" />
                   </svg>
-                </a>
+                </button>
                 {canEdit && (
                   <button
                     className="btn-icon"
@@ -1,4 +1,4 @@
-import { useState, useEffect, useCallback } from 'react';
+import { useState, useEffect, useCallback, useRef } from 'react';
 import type { Recording } from '../types';
 import { useAuth } from '../context/AuthContext';
 
@@ -14,6 +14,8 @@ export function Recordings() {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
   const [selectedRecording, setSelectedRecording] = useState<Recording | null>(null);
+  const [streamUrl, setStreamUrl] = useState<string | null>(null);
+  const selectedIdRef = useRef<string | null>(null);
 
   const fetchRecordings = useCallback(async () => {
     if (!token) return;
@@ -42,6 +44,36 @@ export function Recordings() {
     fetchRecordings();
   }, [fetchRecordings]);
 
+  // Stream with a playback token for the selected recording, so the login
+  // token never ends up in a stream URL
+  const selectRecording = async (recording: Recording) => {
+    const recordingId = recording.id;
+    selectedIdRef.current = recordingId;
+    setSelectedRecording(recording);
+    setStreamUrl(null);
+    try {
+      const response = await fetch(`${API_BASE}/api/recordings/${recordingId}/playback-token`, {
+        method: 'POST',
+        headers: {
+          Authorization: `Bearer ${token}`,
+        },
+      });
+
+      if (!response.ok) {
+        const data = await response.json().catch(() => ({}));
+        throw new Error(data.error || 'Failed to start playback');
+      }
+
+      const data: { streamUrl: string } = await response.json();
+      // Another recording may have been picked meanwhile
+      if (selectedIdRef.current === recordingId) {
+        setStreamUrl(data.streamUrl);
+      }
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to start playback');
+    }
+  };
+
   const formatDuration = (seconds: number): string => {
     const hrs = Math.floor(seconds / 3600);
     const mins = Math.floor((seconds % 3600) / 60);
@@ -151,7 +183,7 @@ export function Recordings() {
               <div 
                 key={recording.id} 
                 className={`recording-card ${selectedRecording?.id === recording.id ? 'selected' : ''}`}
-                onClick={() => setSelectedRecording(recording)}
+                onClick={() => selectRecording(recording)}
               >
                 <div className="recording-thumbnail">
                   <svg width="32" height="32" viewBox="0 0 24 24" fill="currentColor">
@@ -203,9 +235,9 @@ export function Recordings() {
                 <div className="p-4 text-center text-red-500">
                   Error: No authentication token available
                 </div>
-              ) : !selectedRecording.streamUrl ? (
-                <div className="p-4 text-center text-red-500">
-                  Error: No stream URL available for this recording
+              ) : !streamUrl ? (
+                <div className="p-4 text-center">
+                  Loading stream...
                 </div>
               ) : (
                 <video
@@ -215,7 +247,7 @@ export function Recordings() {
                   playsInline
                   preload="auto"
                   className="video-player"
-                  src={`${API_BASE}${selectedRecording.streamUrl}?token=${token}`}
+                  src={`${API_BASE}${streamUrl}`}
                   onError={(e) => {
                     const video = e.target as HTMLVideoElement;
                     console.error('Video playback error:', {
@@ -4,6 +4,24 @@ import type { User, AuthResponse } from '../types';
 
 const API_BASE = '/api';
 
+// Access tokens are renewed this long before they expire
+const REFRESH_AHEAD_MS = 60 * 1000;
+
+/** Stores the tokens of a login or refresh. */
+const storeTokens = (auth: AuthResponse) => {
+  localStorage.setItem('token', auth.token);
+  localStorage.setItem('tokenExpiresAt', auth.expiresAt);
+  if (auth.refreshToken) {
+    localStorage.setItem('refreshToken', auth.refreshToken);
+  }
+};
+
+const clearTokens = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('tokenExpiresAt');
+  localStorage.removeItem('refreshToken');
+};
+
 interface AuthContextType {
   user: User | null;
   token: string | null;
@@ -22,8 +40,58 @@ const AuthContext = createContext<AuthContextType | null>(null);
 export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) => {
   const [user, setUser] = useState<User | null>(null);
   const [token, setToken] = useState<string | null>(() => localStorage.getItem('token'));
+  const [expiresAt, setExpiresAt] = useState<string | null>(() => localStorage.getItem('tokenExpiresAt'));
   const [isLoading, setIsLoading] = useState(true);
 
+  /**
+   * Renews the access token with the refresh token. Returns false, signed
+   * out, when the session can't be renewed.
+   */
+  const refresh = useCallback(async () => {
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (!refreshToken) {
+      return false;
+    }
+
+    try {
+      const res = await fetch(`${API_BASE}/auth/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refreshToken }),
+      });
+
+      if (res.ok) {
+        const authData = (await res.json()) as AuthResponse;
+        storeTokens(authData);
+        setToken(authData.token);
+        setExpiresAt(authData.expiresAt);
+        setUser(authData.user);
+        return true;
+      }
+      if (res.status === 401) {
+        clearTokens();
+        setToken(null);
+        setExpiresAt(null);
+        setUser(null);
+      }
+    } catch {
+      console.error('Failed to refresh session');
+    }
+    return false;
+  }, []);
+
+  // Renew the access token shortly before it expires
+  useEffect(() => {
+    if (!token || !expiresAt || !localStorage.getItem('refreshToken')) {
+      return;
+    }
+    const delay = Math.max(new Date(expiresAt).getTime() - Date.now() - REFRESH_AHEAD_MS, 0);
+    const timer = setTimeout(() => {
+      refresh();
+    }, delay);
+    return () => clearTimeout(timer);
+  }, [token, expiresAt, refresh]);
+
   // Fetch current user on mount if token exists
   useEffect(() => {
     const fetchUser = async () => {
@@ -40,10 +108,11 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
         if (res.ok) {
           const userData = await res.json();
           setUser(userData);
-        } else {
-          // Token invalid, clear it
-          localStorage.removeItem('token');
+        } else if (res.status !== 401 || !(await refresh())) {
+          // Token invalid and not renewable, clear it
+          clearTokens();
           setToken(null);
+          setExpiresAt(null);
         }
       } catch {
         console.error('Failed to fetch user');
@@ -53,7 +122,7 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
     };
 
     fetchUser();
-  }, [token]);
+  }, [token, refresh]);
 
   const login = useCallback(async (email: string, password: string) => {
     try {
@@ -70,8 +139,9 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
       }
 
       const authData = data as AuthResponse;
-      localStorage.setItem('token', authData.token);
+      storeTokens(authData);
       setToken(authData.token);
+      setExpiresAt(authData.expiresAt);
       setUser(authData.user);
 
       return { success: true };
@@ -101,10 +171,18 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({ children }) =>
   }, []);
 
   const logout = useCallback(() => {
-    localStorage.removeItem('token');
+    // Sign the session out on the server too, so its tokens stop working
+    if (token) {
+      fetch(`${API_BASE}/auth/logout`, {
+        method: 'POST',
+        headers: { Authorization: `Bearer ${token}` },
+      }).catch(() => {});
+    }
+    clearTokens();
     setToken(null);
+    setExpiresAt(null);
     setUser(null);
-  }, []);
+  }, [token]);
 
   return (
     <AuthContext.Provider
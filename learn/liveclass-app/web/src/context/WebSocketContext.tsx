@@ -63,6 +63,9 @@ export const WebSocketProvider: React.FC<Props> = ({ children }) => {
   const [chatMessages, setChatMessages] = useState<ChatMessage[]>([]);
   const [error, setError] = useState<string | null>(null);
 
+  // Room token of the current join; the server refuses signaling messages without it
+  const roomTokenRef = useRef<string | null>(null);
+
   // Callbacks for WebRTC events
   const onOfferRef = useRef<((offer: RTCSessionDescriptionInit) => void) | null>(null);
   const onAnswerRef = useRef<((answer: RTCSessionDescriptionInit) => void) | null>(null);
@@ -122,6 +125,7 @@ export const WebSocketProvider: React.FC<Props> = ({ children }) => {
     setViewerConnectionState('idle');
     setChatMessages([]);
     setError(null);
+    roomTokenRef.current = null;
     
     // Clear callback refs and pending data
     onOfferRef.current = null;
@@ -141,6 +145,7 @@ export const WebSocketProvider: React.FC<Props> = ({ children }) => {
 
     switch (msg.type) {
       case 'joined':
+        roomTokenRef.current = msg.roomToken || null;
         setRoomId(msg.roomId || null);
         setParticipantId(msg.participantId || null);
         setParticipants(msg.participants || []);
@@ -149,6 +154,11 @@ export const WebSocketProvider: React.FC<Props> = ({ children }) => {
         setIsStreamReady((msg as { streamReady?: boolean }).streamReady || false);
         break;
 
+      case 'room-token':
+        // Renewed halfway through the old token's lifetime
+        roomTokenRef.current = msg.roomToken || null;
+        break;
+
       case 'participant-joined': {
         const newParticipant = msg.payload as Participant;
         setParticipants(prev => [...prev, newParticipant]);
@@ -260,7 +270,9 @@ export const WebSocketProvider: React.FC<Props> = ({ children }) => {
 
   const sendMessage = useCallback((message: WSMessage) => {
     if (ws.current?.readyState === WebSocket.OPEN) {
-      ws.current.send(JSON.stringify(message));
+      // A presenter rejoining after a drop resumes with its last token too
+      const roomToken = message.roomToken ?? roomTokenRef.current ?? undefined;
+      ws.current.send(JSON.stringify({ ...message, roomToken }));
     }
   }, []);
 
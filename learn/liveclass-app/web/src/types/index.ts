@@ -38,6 +38,7 @@ export type MessageType =
   | 'hand-raised'
   | 'raise-hand'
   | 'request-stream'
+  | 'room-token'
   | 'error';
 
 export interface WSMessage {
@@ -50,6 +51,8 @@ export interface WSMessage {
   hasPresenter?: boolean;
   payload?: unknown;
   message?: string;
+  roomToken?: string; // Issued in 'joined' and renewed by 'room-token'; sent back on every message
+  roomTokenExpiresAt?: string;
 }
 
 // Auth types
@@ -67,6 +70,8 @@ export interface User {
 
 export interface AuthResponse {
   token: string;
+  expiresAt: string;
+  refreshToken?: string;
   user: User;
 }
 